@@ -4,23 +4,30 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
 
 	"github.com/hop-/cachydb/pkg/db"
 )
 
 func main() {
-	// Create a database manager
-	dbManager := db.NewDatabaseManager()
-	storage, err := db.NewStorageManager("/tmp/cachydb-example")
+	dataDir, err := os.MkdirTemp("", "cachydb-example")
 	if err != nil {
 		log.Fatal(err)
 	}
+	defer os.RemoveAll(dataDir)
+
+	// Open the data directory (loads existing databases, replays the WAL)
+	database, err := db.Open(dataDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer database.Close()
 
 	// Create multiple databases
-	userDB := dbManager.CreateDatabase("users_db")
-	productsDB := dbManager.CreateDatabase("products_db")
+	userDB := database.CreateDatabase("users_db")
+	productsDB := database.CreateDatabase("products_db")
 
-	fmt.Println("Created databases:", dbManager.ListDatabases())
+	fmt.Println("Created databases:", database.ListDatabases())
 
 	// === Work with users database ===
 
@@ -179,18 +186,17 @@ func main() {
 
 	// === Save all databases ===
 
-	err = storage.SaveAllDatabases(dbManager)
+	err = database.Save()
 	if err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("\nAll databases saved to disk")
 
-	// Load all databases
-	loadedManager, err := storage.LoadAllDatabases()
+	stored, err := database.Storage.ListStoredDatabases()
 	if err != nil {
 		log.Fatal(err)
 	}
-	fmt.Printf("Databases loaded from disk: %v\n", loadedManager.ListDatabases())
+	fmt.Printf("Databases on disk: %v\n", stored)
 
 	// Delete a document
 	err = users.Delete(doc2.ID)
@@ -2,15 +2,50 @@ package app
 
 import (
 	"context"
+	"log"
 
 	mcpserver "github.com/hop-/cachydb/internal/mcp"
 )
 
 type App struct {
 	mcpServer *mcpserver.Server
+	// listeners are served next to the MCP transport
+	listeners []listener
+	// workers run in the background next to the MCP transport
+	workers []worker
+}
+
+// listener is an API served on its own address, such as gRPC
+type listener struct {
+	name  string
+	addr  string
+	serve func(ctx context.Context, addr string) error
+}
+
+// worker is a background task, such as the MongoDB sync
+type worker struct {
+	name string
+	run  func(ctx context.Context) error
 }
 
 func (a *App) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for _, l := range a.listeners {
+		go func() {
+			if err := l.serve(ctx, l.addr); err != nil {
+				log.Printf("Failed to serve %s: %v\n", l.name, err)
+			}
+		}()
+	}
+	for _, w := range a.workers {
+		go func() {
+			if err := w.run(ctx); err != nil {
+				log.Printf("%s failed: %v\n", w.name, err)
+			}
+		}()
+	}
 	return a.mcpServer.Start(ctx)
 }
 
@@ -2,8 +2,15 @@ package app
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/hop-/cachydb/internal/grpcserver"
 	mcpserver "github.com/hop-/cachydb/internal/mcp"
+	"github.com/hop-/cachydb/internal/mongosync"
+	"github.com/hop-/cachydb/internal/resp"
+	"github.com/hop-/cachydb/internal/rest"
+	"github.com/hop-/cachydb/pkg/db"
 )
 
 type Builder struct {
@@ -11,6 +18,38 @@ type Builder struct {
 	rootDir   string
 	transport string
 	port      int
+	// grpcPort serves the gRPC API on this port (0: disabled)
+	grpcPort int
+	// respPort serves the keys of a key-value collection over the Redis
+	// protocol on this port (0: disabled)
+	respPort int
+	tenant   string
+	profile  string
+	// durability overrides the profile's WAL sync policy when set
+	durability string
+	walArchive string
+	// walConfig overrides the profile's WAL settings that are not zero
+	walConfig db.WALConfig
+	// checkpoint overrides the profile's checkpoint thresholds that are not zero
+	checkpoint db.CheckpointPolicy
+	embedder   db.Embedder
+	// leader makes the server a read replica of the server at this address
+	leader string
+	// replicationToken is sent to the leader, and required from replicas of
+	// this server, which are not served without it
+	replicationToken string
+	// auditLog records tool calls that change data, keeping them for
+	// auditRetention (0: forever)
+	auditLog       bool
+	auditRetention time.Duration
+	// tools restricts the MCP tools offered (see mcpserver.Server.SetTools)
+	tools []string
+	// mongoSync mirrors collections into MongoDB when its URI is set
+	mongoSync mongosync.Config
+
+	requireTenant bool
+	// version is reported by the ping and server_stats tools
+	version string
 }
 
 func NewBuilder() *Builder {
@@ -37,12 +76,192 @@ func (b *Builder) WithPort(port int) *Builder {
 	return b
 }
 
+func (b *Builder) WithGRPCPort(port int) *Builder {
+	b.grpcPort = port
+	return b
+}
+
+func (b *Builder) WithRESPPort(port int) *Builder {
+	b.respPort = port
+	return b
+}
+
+func (b *Builder) WithTenant(tenant string) *Builder {
+	b.tenant = tenant
+	return b
+}
+
+func (b *Builder) WithRequireTenant(require bool) *Builder {
+	b.requireTenant = require
+	return b
+}
+
+func (b *Builder) WithProfile(profile string) *Builder {
+	b.profile = profile
+	return b
+}
+
+func (b *Builder) WithDurability(mode string) *Builder {
+	b.durability = mode
+	return b
+}
+
+func (b *Builder) WithWALArchive(dir string) *Builder {
+	b.walArchive = dir
+	return b
+}
+
+func (b *Builder) WithWALConfig(config db.WALConfig) *Builder {
+	b.walConfig = config
+	return b
+}
+
+func (b *Builder) WithCheckpointPolicy(policy db.CheckpointPolicy) *Builder {
+	b.checkpoint = policy
+	return b
+}
+
+func (b *Builder) WithEmbedder(embedder db.Embedder) *Builder {
+	b.embedder = embedder
+	return b
+}
+
+func (b *Builder) WithLeader(leader string) *Builder {
+	b.leader = leader
+	return b
+}
+
+func (b *Builder) WithReplicationToken(token string) *Builder {
+	b.replicationToken = token
+	return b
+}
+
+func (b *Builder) WithAuditLog(enabled bool, retention time.Duration) *Builder {
+	b.auditLog = enabled
+	b.auditRetention = retention
+	return b
+}
+
+func (b *Builder) WithTools(tools []string) *Builder {
+	b.tools = tools
+	return b
+}
+
+func (b *Builder) WithMongoSync(config mongosync.Config) *Builder {
+	b.mongoSync = config
+	return b
+}
+
+func (b *Builder) WithVersion(version string) *Builder {
+	b.version = version
+	return b
+}
+
 func (b *Builder) Build() (*App, error) {
+	profileName := b.profile
+	if profileName == "" {
+		profileName = db.DefaultProfile
+	}
+	profile, exists := db.GetProfile(profileName)
+	if !exists {
+		return nil, fmt.Errorf("unknown profile '%s' (available: %s)", profileName, strings.Join(db.ListProfiles(), ", "))
+	}
+	if b.durability != "" {
+		policy, err := db.ParseDurability(b.durability)
+		if err != nil {
+			return nil, err
+		}
+		profile.WALSync = policy
+	}
+	if b.walConfig.Dir != "" {
+		profile.WAL.Dir = b.walConfig.Dir
+	}
+	if b.walConfig.MaxSize != 0 {
+		profile.WAL.MaxSize = b.walConfig.MaxSize
+	}
+	if b.walConfig.RetentionCount != 0 {
+		profile.WAL.RetentionCount = b.walConfig.RetentionCount
+	}
+	if b.walConfig.BatchSize != 0 {
+		profile.WAL.BatchSize = b.walConfig.BatchSize
+	}
+	if b.walConfig.FlushInterval != 0 {
+		profile.WAL.FlushInterval = b.walConfig.FlushInterval
+	}
+	if b.checkpoint.Entries < 0 || b.checkpoint.Bytes < 0 {
+		return nil, fmt.Errorf("checkpoint thresholds must not be negative")
+	}
+	if b.checkpoint.Entries != 0 {
+		profile.Checkpoint.Entries = b.checkpoint.Entries
+	}
+	if b.checkpoint.Bytes != 0 {
+		profile.Checkpoint.Bytes = b.checkpoint.Bytes
+	}
+
+	var archiver *db.DirWALArchiver
+	if b.walArchive != "" {
+		var err error
+		if archiver, err = db.NewDirWALArchiver(b.walArchive); err != nil {
+			return nil, err
+		}
+	}
+
 	httpAddr := fmt.Sprintf(":%d", b.port)
-	mcpServer, err := mcpserver.NewServer(b.dbName, b.rootDir, b.transport, httpAddr)
+	var mcpServer *mcpserver.Server
+	var err error
+	if b.leader != "" {
+		mcpServer, err = mcpserver.NewReplicaServer(b.dbName, b.rootDir, b.transport, httpAddr, b.tenant, profile, b.leader, b.replicationToken)
+	} else {
+		mcpServer, err = mcpserver.NewServer(b.dbName, b.rootDir, b.transport, httpAddr, b.tenant, profile)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MCP server: %w", err)
 	}
 
-	return &App{mcpServer: mcpServer}, nil
+	mcpServer.SetRequireTenant(b.requireTenant)
+	mcpServer.SetReplicationToken(b.replicationToken)
+	mcpServer.SetVersion(b.version)
+	if err := mcpServer.SetTools(b.tools); err != nil {
+		return nil, err
+	}
+	if archiver != nil {
+		mcpServer.SetWALArchiver(archiver)
+	}
+	if b.embedder != nil {
+		mcpServer.SetEmbedder(b.embedder)
+	}
+	if b.auditLog {
+		if b.auditRetention < 0 {
+			return nil, fmt.Errorf("audit retention must not be negative")
+		}
+		mcpServer.SetAuditLog(db.NewAuditLog(mcpServer.DatabaseManager(), mcpServer.StorageManager(), b.auditRetention))
+	}
+
+	// The REST API shares the HTTP listener of the MCP transport. Clients
+	// limited to some tools must not write through it instead.
+	if b.transport == "http" || b.transport == "both" {
+		api := rest.NewHandler(mcpServer.DatabaseManager(), mcpServer.StorageManager(), b.tenant)
+		api.SetRequireTenant(b.requireTenant)
+		api.SetReadOnly(mcpServer.ToolsRestricted())
+		mcpServer.Handle(rest.PathPrefix, "REST API", api)
+	}
+
+	application := &App{mcpServer: mcpServer}
+	if b.grpcPort != 0 {
+		grpcServer := grpcserver.NewServer(mcpServer.DatabaseManager(), mcpServer.StorageManager(), b.tenant)
+		grpcServer.SetRequireTenant(b.requireTenant)
+		application.listeners = append(application.listeners, listener{"gRPC", fmt.Sprintf(":%d", b.grpcPort), grpcServer.Serve})
+	}
+	if b.respPort != 0 {
+		respServer := resp.NewServer(mcpServer.DatabaseManager(), mcpServer.StorageManager(), b.tenant, b.dbName, resp.DefaultCollection)
+		application.listeners = append(application.listeners, listener{"RESP", fmt.Sprintf(":%d", b.respPort), respServer.Serve})
+	}
+	if b.mongoSync.URI != "" {
+		syncer, err := mongosync.NewSyncer(mcpServer.DatabaseManager(), mcpServer.StorageManager(), b.tenant, b.mongoSync)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up MongoDB sync: %w", err)
+		}
+		application.workers = append(application.workers, worker{"MongoDB sync", syncer.Run})
+	}
+	return application, nil
 }
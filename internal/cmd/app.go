@@ -2,9 +2,12 @@ package cmd
 
 import (
 	"context"
+	"fmt"
+	"os"
 
 	"github.com/hop-/cachydb/internal/app"
 	"github.com/hop-/cachydb/internal/config"
+	"github.com/hop-/cachydb/pkg/db"
 	"github.com/spf13/cobra"
 )
 
@@ -31,6 +34,18 @@ func setAllFlagsToCmd(cmd *cobra.Command) {
 		config.GetConfig().Port,
 		"port on which connection listener will be started",
 	)
+	cmd.Flags().IntVar(
+		&generalGRPCPort,
+		"grpc-port",
+		config.GetConfig().GRPCPort,
+		"port on which the gRPC API will be served alongside the transport (0 to disable)",
+	)
+	cmd.Flags().IntVar(
+		&generalRESPPort,
+		"resp-port",
+		config.GetConfig().RESPPort,
+		"port on which a Redis protocol listener for the kv collection will be served (0 to disable)",
+	)
 	cmd.Flags().StringVarP(
 		&generalRootDir,
 		"root", "R",
@@ -41,15 +56,141 @@ func setAllFlagsToCmd(cmd *cobra.Command) {
 		&generalTransport,
 		"transport", "t",
 		"",
-		"transport type: stdio or http",
+		"transport type: stdio, http, or both (stdio and http at once)",
+	)
+	cmd.Flags().StringVar(
+		&generalProfile,
+		"profile",
+		config.GetConfig().Profile,
+		"durability profile: dev (fast, may lose recent writes) or prod (fully durable)",
+	)
+	cmd.Flags().StringVar(
+		&generalDurability,
+		"durability",
+		config.GetConfig().Durability,
+		"WAL durability overriding the profile's: sync, group (shared fsyncs) or async",
+	)
+	cmd.Flags().StringVar(
+		&generalWALArchive,
+		"wal-archive",
+		config.GetConfig().WALArchive,
+		"move old WAL files into this directory instead of deleting them, for point-in-time recovery",
+	)
+	cmd.Flags().StringVar(
+		&generalWALConfig.Dir,
+		"wal-dir",
+		config.GetConfig().WALDir,
+		"directory of the WAL files, e.g. on a faster disk (default: the root directory)",
+	)
+	cmd.Flags().Int64Var(
+		&generalWALConfig.MaxSize,
+		"wal-max-size",
+		config.GetConfig().WALMaxSize,
+		"size in bytes at which WAL files are rotated (0: 64MB)",
+	)
+	cmd.Flags().IntVar(
+		&generalWALConfig.RetentionCount,
+		"wal-retention",
+		config.GetConfig().WALRetention,
+		"number of WAL files kept after rotation (0: 2)",
+	)
+	cmd.Flags().IntVar(
+		&generalWALConfig.BatchSize,
+		"wal-batch-size",
+		config.GetConfig().WALBatchSize,
+		"batched WAL entries buffered before they are written (0: 100)",
+	)
+	cmd.Flags().DurationVar(
+		&generalWALConfig.FlushInterval,
+		"wal-flush-interval",
+		config.GetConfig().WALFlushInterval,
+		"how often batched WAL entries are written (0: 100ms)",
+	)
+	cmd.Flags().IntVar(
+		&generalCheckpoint.Entries,
+		"checkpoint-entries",
+		config.GetConfig().CheckpointEntries,
+		"checkpoint once this many WAL entries were logged since the last one (0: only every sync interval)",
+	)
+	cmd.Flags().Int64Var(
+		&generalCheckpoint.Bytes,
+		"checkpoint-bytes",
+		config.GetConfig().CheckpointBytes,
+		"checkpoint once this many bytes were written to the WAL since the last one (0: only every sync interval)",
+	)
+	cmd.Flags().BoolVar(
+		&generalAuditLog,
+		"audit-log",
+		config.GetConfig().AuditLog,
+		"record every tool call that changes data in the audit log (see query_audit_log)",
+	)
+	cmd.Flags().DurationVar(
+		&generalAuditTTL,
+		"audit-retention",
+		config.GetConfig().AuditRetention,
+		"how long audit log entries are kept (0: forever)",
+	)
+	cmd.Flags().StringSliceVar(
+		&generalTools,
+		"tools",
+		config.GetConfig().Tools,
+		"only offer these MCP tools: tool names or the sets read (tools that only read data) and all",
+	)
+	cmd.Flags().StringVar(
+		&generalMongoSync.URI,
+		"mongo-sync-uri",
+		config.GetConfig().MongoSyncURI,
+		"mirror the collections of --mongo-sync-collections into the MongoDB at this URI",
+	)
+	cmd.Flags().StringSliceVar(
+		&generalMongoSync.Collections,
+		"mongo-sync-collections",
+		config.GetConfig().MongoSyncCollections,
+		"collections to mirror into MongoDB, as database.collection",
+	)
+	cmd.Flags().StringVar(
+		&generalMongoSync.Database,
+		"mongo-sync-database",
+		config.GetConfig().MongoSyncDatabase,
+		"MongoDB database receiving every mirrored collection (default: one named like each collection's database)",
+	)
+	cmd.Flags().StringVar(
+		&generalTenant,
+		"tenant",
+		config.GetConfig().Tenant,
+		"restrict the server to the databases of a single tenant",
+	)
+	cmd.Flags().BoolVar(
+		&generalReqTenant,
+		"require-tenant",
+		config.GetConfig().RequireTenant,
+		"reject HTTP and gRPC requests that do not name a tenant",
+	)
+	cmd.Flags().StringVar(
+		&generalEmbedderURL,
+		"embedder-url",
+		config.GetConfig().EmbedderURL,
+		"embedding endpoint used by semantic_search",
+	)
+	cmd.Flags().StringVar(
+		&generalEmbedderModel,
+		"embedder-model",
+		config.GetConfig().EmbedderModel,
+		"model name sent to the embedding endpoint",
+	)
+	cmd.Flags().StringVar(
+		&generalEmbedderAPIKey,
+		"embedder-api-key",
+		config.GetConfig().EmbedderAPIKey,
+		"bearer token for the embedding endpoint",
 	)
 }
 
 func executeApp() {
 	application, err := buildApp()
 	if err != nil {
-		// TODO: handle error appropriately
-		return
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
 	}
 
 	ctx := context.Background()
@@ -72,7 +213,26 @@ func buildApp() (*app.App, error) {
 		WithDBName(config.GetConfig().DBName).
 		WithRootDir(generalRootDir).
 		WithTransport(generalTransport).
-		WithPort(generalServerPort)
+		WithPort(generalServerPort).
+		WithGRPCPort(generalGRPCPort).
+		WithRESPPort(generalRESPPort).
+		WithTenant(generalTenant).
+		WithProfile(generalProfile).
+		WithDurability(generalDurability).
+		WithWALArchive(generalWALArchive).
+		WithWALConfig(generalWALConfig).
+		WithCheckpointPolicy(generalCheckpoint).
+		WithLeader(generalLeader).
+		WithReplicationToken(config.GetConfig().ReplicationToken).
+		WithAuditLog(generalAuditLog, generalAuditTTL).
+		WithTools(generalTools).
+		WithMongoSync(generalMongoSync).
+		WithVersion(getVersion()).
+		WithRequireTenant(generalReqTenant)
+
+	if generalEmbedderURL != "" {
+		builder.WithEmbedder(db.NewHTTPEmbedder(generalEmbedderURL, generalEmbedderModel, generalEmbedderAPIKey))
+	}
 
 	return builder.Build()
 }
@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/spf13/cobra"
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the audit log of tool calls that changed data",
+	Long: `Print the entries of the audit log, newest first: when each tool call that
+changed data was made, by which client and tenant, the tool, the database,
+collection and document it targeted, and whether it succeeded. Calls are
+only recorded while the server runs with --audit-log.
+
+The audit log is read from the root directory; use --read-only to inspect
+the directory of a running server, or pass its HTTP endpoint with --server
+to ask the server itself, which also sees entries not yet checkpointed.
+
+--since and --until take an RFC 3339 time or a duration back from now,
+e.g. --since 24h.`,
+	RunE: runAudit,
+}
+
+var (
+	auditQuery  db.AuditQuery
+	auditSince  string
+	auditUntil  string
+	auditJSON   bool
+	auditServer string
+)
+
+func init() {
+	utilsCmd.AddCommand(auditCmd)
+
+	auditCmd.Flags().StringVarP(&auditQuery.Database, "database", "d", "", "Only show entries for this database")
+	auditCmd.Flags().StringVarP(&auditQuery.Collection, "collection", "c", "", "Only show entries for this collection")
+	auditCmd.Flags().StringVar(&auditQuery.DocumentID, "document", "", "Only show entries for this document ID")
+	auditCmd.Flags().StringVar(&auditQuery.Tool, "tool", "", "Only show calls to this tool, e.g. delete_document")
+	auditCmd.Flags().StringVar(&auditQuery.Actor, "actor", "", "Only show calls made by this client")
+	auditCmd.Flags().StringVar(&auditQuery.Tenant, "tenant", "", "Only show entries of this tenant")
+	auditCmd.Flags().StringVar(&auditQuery.Outcome, "outcome", "", "Only show successful (success) or failed (error) calls")
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "Only show entries at or after this time")
+	auditCmd.Flags().StringVar(&auditUntil, "until", "", "Only show entries at or before this time")
+	auditCmd.Flags().IntVarP(&auditQuery.Limit, "limit", "n", 50, "Maximum number of entries to show (0: all)")
+	auditCmd.Flags().BoolVar(&auditJSON, "json", false, "Print one JSON object per entry")
+	auditCmd.Flags().StringVar(&auditServer, "server", "", "MCP endpoint of a running server to query, e.g. http://localhost:7601/mcp")
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	if auditQuery.Outcome != "" && auditQuery.Outcome != db.AuditSuccess && auditQuery.Outcome != db.AuditFailure {
+		return fmt.Errorf("--outcome must be %s or %s", db.AuditSuccess, db.AuditFailure)
+	}
+	var err error
+	if auditQuery.Since, err = parseAuditTime(auditSince); err != nil {
+		return fmt.Errorf("invalid --since: %w", err)
+	}
+	if auditQuery.Until, err = parseAuditTime(auditUntil); err != nil {
+		return fmt.Errorf("invalid --until: %w", err)
+	}
+
+	var entries []*db.AuditEntry
+	if auditServer != "" {
+		entries, err = auditOnline(cmd.Context())
+	} else {
+		entries, err = auditOffline()
+	}
+	if err != nil {
+		return err
+	}
+
+	if auditJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if len(entries) == 0 {
+		fmt.Println("No audit entries found")
+		return nil
+	}
+	for _, entry := range entries {
+		target := entry.Database
+		if entry.Tenant != "" {
+			target = entry.Tenant + ":" + target
+		}
+		if entry.Collection != "" {
+			target += "/" + entry.Collection
+		}
+		if entry.DocumentID != "" {
+			target += " " + entry.DocumentID
+		}
+		actor := entry.Actor
+		if actor == "" {
+			actor = "-"
+		}
+		fmt.Printf("%s  %-7s %-20s %s  by %s\n", entry.Timestamp.Format("2006-01-02T15:04:05.000Z07:00"), entry.Outcome, entry.Tool, target, actor)
+		if entry.Error != "" {
+			fmt.Printf("    %s\n", entry.Error)
+		}
+	}
+	fmt.Printf("\n%d entries\n", len(entries))
+	return nil
+}
+
+// parseAuditTime reads an RFC 3339 time or a duration back from now; an
+// empty value is the zero time
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if ago, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(-ago), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// auditOffline loads the data directory and reads its audit log
+func auditOffline() ([]*db.AuditEntry, error) {
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	dbManager, err := storage.LoadAllDatabases()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load databases: %w", err)
+	}
+	return db.NewAuditLog(dbManager, storage, 0).Query(auditQuery)
+}
+
+// auditOnline asks a running server through its query_audit_log tool
+func auditOnline(ctx context.Context) ([]*db.AuditEntry, error) {
+	arguments := map[string]any{"limit": auditQuery.Limit}
+	for name, value := range map[string]string{
+		"database":    auditQuery.Database,
+		"collection":  auditQuery.Collection,
+		"document_id": auditQuery.DocumentID,
+		"tool":        auditQuery.Tool,
+		"actor":       auditQuery.Actor,
+		"tenant":      auditQuery.Tenant,
+		"outcome":     auditQuery.Outcome,
+	} {
+		if value != "" {
+			arguments[name] = value
+		}
+	}
+	if !auditQuery.Since.IsZero() {
+		arguments["since"] = auditQuery.Since.Format(time.RFC3339)
+	}
+	if !auditQuery.Until.IsZero() {
+		arguments["until"] = auditQuery.Until.Format(time.RFC3339)
+	}
+	if auditQuery.Limit == 0 {
+		// The tool caps results unless given a limit
+		arguments["limit"] = math.MaxInt32
+	}
+
+	var output struct {
+		Entries []*db.AuditEntry `json:"entries"`
+	}
+	if err := callServerTool(ctx, auditServer, "query_audit_log", arguments, &output); err != nil {
+		return nil, err
+	}
+	return output.Entries, nil
+}
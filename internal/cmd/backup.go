@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/spf13/cobra"
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Write a compressed archive of a consistent snapshot of the data",
+	Long: `Write a gzipped tar archive holding the data, index and metadata files of
+databases, together with the WAL entries not yet saved into them, as of a
+single point in time. Restore it with "utils restore".
+
+By default every database under the root directory is backed up; this fails
+while a server holds the directory's lock. To back up a live server without
+stopping it, pass its HTTP endpoint with --server: the server then writes
+the archive itself, to the --out path on its own host, while it keeps
+serving writes. Without --database the server backs up its default
+database.`,
+	RunE: runBackup,
+}
+
+var (
+	backupOut      string
+	backupDatabase string
+	backupServer   string
+)
+
+func init() {
+	utilsCmd.AddCommand(backupCmd)
+
+	backupCmd.Flags().StringVarP(&backupOut, "out", "o", "", "Path of the archive to write, e.g. backup.tar.gz (required)")
+	backupCmd.Flags().StringVarP(&backupDatabase, "database", "d", "", "Only back up this database")
+	backupCmd.Flags().StringVar(&backupServer, "server", "", "MCP endpoint of a running server to back up online, e.g. http://localhost:7601/mcp")
+}
+
+func runBackup(cmd *cobra.Command, args []string) error {
+	if backupOut == "" {
+		return fmt.Errorf("--out is required")
+	}
+
+	var manifest *db.BackupManifest
+	var err error
+	if backupServer != "" {
+		manifest, err = backupOnline(cmd.Context())
+	} else {
+		manifest, err = backupOffline()
+	}
+	if err != nil {
+		return err
+	}
+
+	databases := make([]string, 0, len(manifest.Databases))
+	for name := range manifest.Databases {
+		databases = append(databases, name)
+	}
+	sort.Strings(databases)
+
+	for _, name := range databases {
+		documents := 0
+		for _, count := range manifest.Databases[name] {
+			documents += count
+		}
+		fmt.Printf("%s: %d collection(s), %d document(s)\n", name, len(manifest.Databases[name]), documents)
+	}
+	fmt.Printf("\nBacked up %d database(s) to %s, with %d WAL entries not yet saved\n", len(databases), backupOut, manifest.WALEntries)
+	return nil
+}
+
+// backupOffline opens the data directory and writes the archive in this process
+func backupOffline() (*db.BackupManifest, error) {
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	manifest, err := storage.BackupFile(backupOut, backupDatabase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up: %w", err)
+	}
+	return manifest, nil
+}
+
+// backupOnline asks a running server to write the archive through its backup tool
+func backupOnline(ctx context.Context) (*db.BackupManifest, error) {
+	arguments := map[string]any{"out": backupOut}
+	if backupDatabase != "" {
+		arguments["database"] = backupDatabase
+	}
+
+	var output struct {
+		Database    string         `json:"database"`
+		Collections map[string]int `json:"collections"`
+		WALEntries  int            `json:"wal_entries"`
+	}
+	if err := callServerTool(ctx, backupServer, "backup", arguments, &output); err != nil {
+		return nil, err
+	}
+	return &db.BackupManifest{
+		Databases:  map[string]map[string]int{output.Database: output.Collections},
+		WALEntries: output.WALEntries,
+	}, nil
+}
@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// checkpointCmd represents the checkpoint command
+var checkpointCmd = &cobra.Command{
+	Use:   "checkpoint",
+	Short: "Save all changes to the data files and move the WAL checkpoint",
+	Long: `Save every changed collection to its data files and move the WAL checkpoint
+past the entries logged so far, so they are not replayed on the next start.
+
+Servers checkpoint on their own every sync interval, and earlier with
+--checkpoint-entries or --checkpoint-bytes. To force one on a live server,
+e.g. before copying its files, pass its HTTP endpoint with --server. Without
+--server the root directory is opened, which replays and checkpoints its
+WAL; this fails while a server holds the directory's lock.`,
+	RunE: runCheckpoint,
+}
+
+var checkpointServer string
+
+func init() {
+	utilsCmd.AddCommand(checkpointCmd)
+
+	checkpointCmd.Flags().StringVar(&checkpointServer, "server", "", "MCP endpoint of a running server to checkpoint, e.g. http://localhost:7601/mcp")
+}
+
+// checkpointResult is what a checkpoint reports
+type checkpointResult struct {
+	Offset     uint64  `json:"offset"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+func runCheckpoint(cmd *cobra.Command, args []string) error {
+	var result *checkpointResult
+	var err error
+	if checkpointServer != "" {
+		result, err = checkpointOnline(cmd.Context())
+	} else {
+		result, err = checkpointOffline()
+	}
+	if err != nil {
+		return err
+	}
+
+	duration := time.Duration(result.DurationMS * float64(time.Millisecond))
+	fmt.Printf("Checkpointed at WAL offset %d in %s\n", result.Offset, duration.Round(time.Microsecond))
+	return nil
+}
+
+// checkpointOffline opens the data directory and checkpoints it in this process
+func checkpointOffline() (*checkpointResult, error) {
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	if _, err := storage.LoadAllDatabases(); err != nil {
+		return nil, fmt.Errorf("failed to load databases: %w", err)
+	}
+	stats, err := storage.ForceCheckpoint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to checkpoint: %w", err)
+	}
+	return &checkpointResult{
+		Offset:     stats.Offset,
+		DurationMS: float64(stats.LastDuration.Microseconds()) / 1000,
+	}, nil
+}
+
+// checkpointOnline asks a running server to checkpoint through its checkpoint tool
+func checkpointOnline(ctx context.Context) (*checkpointResult, error) {
+	var output checkpointResult
+	if err := callServerTool(ctx, checkpointServer, "checkpoint", map[string]any{}, &output); err != nil {
+		return nil, err
+	}
+	return &output, nil
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/spf13/cobra"
+)
+
+// cloneCmd represents the clone command
+var cloneCmd = &cobra.Command{
+	Use:   "clone <source> <target>",
+	Short: "Copy a collection into a new collection of the same database",
+	Long: `Copy the documents of a collection into a new collection of the same
+database, created with the source's schema, settings and indexes. Documents
+keep their IDs and revisions. Pass a filter, as in find_documents, to only
+copy the documents matching it, e.g. for a test fixture:
+
+  cachydb utils clone -d shop orders orders_sample --filter '{"field": "total", "operator": "gt", "value": 100}'
+
+This fails while a server holds the directory's lock. To clone in a live
+server instead, pass its HTTP endpoint with --server; without --database the
+server uses its default database.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runClone,
+}
+
+var (
+	cloneDatabase string
+	cloneFilter   string
+	cloneServer   string
+)
+
+func init() {
+	utilsCmd.AddCommand(cloneCmd)
+
+	cloneCmd.Flags().StringVarP(&cloneDatabase, "database", "d", "", "Database of the collection (required without --server)")
+	cloneCmd.Flags().StringVar(&cloneFilter, "filter", "", "Only copy the documents matching this JSON filter")
+	cloneCmd.Flags().StringVar(&cloneServer, "server", "", "MCP endpoint of a running server to clone in, e.g. http://localhost:7601/mcp")
+}
+
+func runClone(cmd *cobra.Command, args []string) error {
+	source, target := args[0], args[1]
+
+	var copied int
+	var err error
+	if cloneServer != "" {
+		copied, err = cloneOnline(cmd, source, target)
+	} else {
+		copied, err = cloneOffline(source, target)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Cloned %d document(s) of collection '%s' into '%s'\n", copied, source, target)
+	return nil
+}
+
+// cloneOnline asks the running server to clone the collection
+func cloneOnline(cmd *cobra.Command, source, target string) (int, error) {
+	arguments := map[string]any{"collection": source, "name": target}
+	if cloneDatabase != "" {
+		arguments["database"] = cloneDatabase
+	}
+	if cloneFilter != "" {
+		var filter map[string]any
+		if err := json.Unmarshal([]byte(cloneFilter), &filter); err != nil {
+			return 0, fmt.Errorf("invalid --filter: %w", err)
+		}
+		arguments["filter"] = filter
+	}
+
+	var output struct {
+		Copied int `json:"copied"`
+	}
+	if err := callServerTool(cmd.Context(), cloneServer, "clone_collection", arguments, &output); err != nil {
+		return 0, err
+	}
+	return output.Copied, nil
+}
+
+// cloneOffline loads the data directory, clones the collection in this
+// process and saves the clone
+func cloneOffline(source, target string) (int, error) {
+	if cloneDatabase == "" {
+		return 0, fmt.Errorf("--database is required. Use 'cachydb utils list' to see available databases")
+	}
+
+	var filter *db.Filter
+	if cloneFilter != "" {
+		filter = &db.Filter{}
+		if err := json.Unmarshal([]byte(cloneFilter), filter); err != nil {
+			return 0, fmt.Errorf("invalid --filter: %w", err)
+		}
+	}
+
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	dbManager, err := storage.LoadAllDatabases()
+	if err != nil {
+		return 0, fmt.Errorf("failed to load databases: %w", err)
+	}
+	database := dbManager.GetDatabase(cloneDatabase)
+	if database == nil {
+		return 0, fmt.Errorf("database '%s' not found", cloneDatabase)
+	}
+
+	copied, err := database.CloneCollection(source, target, filter)
+	if err != nil {
+		return 0, err
+	}
+	clone, err := database.GetCollection(target)
+	if err != nil {
+		return 0, err
+	}
+	if err := storage.SaveCollection(cloneDatabase, clone); err != nil {
+		return 0, fmt.Errorf("failed to save collection '%s': %w", target, err)
+	}
+	return copied, nil
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/spf13/cobra"
+)
+
+// compactCmd represents the compact command
+var compactCmd = &cobra.Command{
+	Use:   "compact",
+	Short: "Reclaim disk space taken by deleted and superseded documents",
+	Long: `Rewrite the data and index files of collections, keeping only the current
+version of each document, and report how many bytes were reclaimed.
+
+By default every collection of every database under the root directory is
+compacted; this fails while a server holds the directory's lock. To
+compact a live server instead, pass its HTTP endpoint with --server: the
+server compacts its collections itself, locking each one only while its
+files are rewritten. Without --database the server compacts its default
+database.`,
+	RunE: runCompact,
+}
+
+var (
+	compactDatabase   string
+	compactCollection string
+	compactServer     string
+)
+
+func init() {
+	utilsCmd.AddCommand(compactCmd)
+
+	compactCmd.Flags().StringVarP(&compactDatabase, "database", "d", "", "Only compact this database")
+	compactCmd.Flags().StringVarP(&compactCollection, "collection", "c", "", "Only compact this collection (requires --database)")
+	compactCmd.Flags().StringVar(&compactServer, "server", "", "MCP endpoint of a running server to compact online, e.g. http://localhost:7601/mcp")
+}
+
+func runCompact(cmd *cobra.Command, args []string) error {
+	if compactCollection != "" && compactDatabase == "" {
+		return fmt.Errorf("--collection requires --database")
+	}
+
+	var results []*db.CompactionResult
+	var err error
+	if compactServer != "" {
+		results, err = compactOnline(cmd.Context())
+	} else {
+		results, err = compactOffline(interruptContext(cmd.Context()))
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		fmt.Println("No collections found")
+		return nil
+	}
+
+	var reclaimed int64
+	for _, result := range results {
+		fmt.Printf("%s/%s: %s -> %s (reclaimed %s)\n", result.Database, result.Collection,
+			formatBytes(result.BytesBefore), formatBytes(result.BytesAfter), formatBytes(result.Reclaimed()))
+		reclaimed += result.Reclaimed()
+	}
+	fmt.Printf("\nCompacted %d collection(s), reclaimed %s\n", len(results), formatBytes(reclaimed))
+	return nil
+}
+
+// compactOffline loads the data directory and compacts it in this process,
+// until ctx is canceled
+func compactOffline(ctx context.Context) ([]*db.CompactionResult, error) {
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	dbManager, err := storage.LoadAllDatabases()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load databases: %w", err)
+	}
+
+	databases := []string{compactDatabase}
+	if compactDatabase == "" {
+		databases = dbManager.ListDatabases()
+		sort.Strings(databases)
+	}
+
+	var results []*db.CompactionResult
+	for _, dbName := range databases {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		database := dbManager.GetDatabase(dbName)
+		if database == nil {
+			return nil, fmt.Errorf("database '%s' not found", dbName)
+		}
+
+		if compactCollection != "" {
+			coll, err := database.GetCollection(compactCollection)
+			if err != nil {
+				return nil, err
+			}
+			result, err := storage.CompactCollection(dbName, coll)
+			if err != nil {
+				return nil, fmt.Errorf("failed to compact collection '%s': %w", compactCollection, err)
+			}
+			results = append(results, result)
+			continue
+		}
+
+		bar := newProgressBar(fmt.Sprintf("Compacting %s", dbName))
+		compacted, err := storage.CompactDatabaseWithProgress(ctx, database, bar.Update)
+		bar.Done()
+		if err != nil {
+			return nil, fmt.Errorf("failed to compact database '%s': %w", dbName, err)
+		}
+		results = append(results, compacted...)
+	}
+	return results, nil
+}
+
+// compactOnline asks a running server to compact through its compact tool
+func compactOnline(ctx context.Context) ([]*db.CompactionResult, error) {
+	arguments := map[string]any{}
+	if compactDatabase != "" {
+		arguments["database"] = compactDatabase
+	}
+	if compactCollection != "" {
+		arguments["collection"] = compactCollection
+	}
+
+	var output struct {
+		Collections []*db.CompactionResult `json:"collections"`
+	}
+	if err := callServerTool(ctx, compactServer, "compact", arguments, &output); err != nil {
+		return nil, err
+	}
+	return output.Collections, nil
+}
@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/spf13/cobra"
+)
+
+// copyCmd represents the copy command
+var copyCmd = &cobra.Command{
+	Use:   "copy",
+	Short: "Copy or merge a database from one root directory into another",
+	Long: `Copy a database from the root directory --from into the root directory --to.
+If the database already exists there, the two are merged: missing
+collections are created with the source's schema, options and indexes, and
+the documents of the source are added to existing collections.
+
+Documents whose ID already exists in the target are handled by --on-conflict:
+  error      fail before anything is written (default)
+  skip       keep the target's document
+  overwrite  replace it with the source's document
+
+The source is only read, without locking it; stop any server writing to it
+for a consistent copy, or copy from a snapshot or restored backup. The
+target fails to open while a server holds its lock.`,
+	RunE: runCopy,
+}
+
+var (
+	copyFrom       string
+	copyTo         string
+	copyDatabase   string
+	copyInto       string
+	copyCollection string
+	copyOnConflict string
+)
+
+func init() {
+	utilsCmd.AddCommand(copyCmd)
+
+	copyCmd.Flags().StringVar(&copyFrom, "from", "", "Root directory to copy from (required)")
+	copyCmd.Flags().StringVar(&copyTo, "to", "", "Root directory to copy into (required)")
+	copyCmd.Flags().StringVarP(&copyDatabase, "database", "d", "", "Database to copy (required)")
+	copyCmd.Flags().StringVar(&copyInto, "into", "", "Name of the database in the target (default: the same name)")
+	copyCmd.Flags().StringVarP(&copyCollection, "collection", "c", "", "Only copy this collection")
+	copyCmd.Flags().StringVar(&copyOnConflict, "on-conflict", string(db.ConflictFail), "What to do with IDs that exist in both: error, skip or overwrite")
+}
+
+func runCopy(cmd *cobra.Command, args []string) error {
+	if copyFrom == "" || copyTo == "" {
+		return fmt.Errorf("both --from and --to must be specified")
+	}
+	if copyDatabase == "" {
+		return fmt.Errorf("--database is required")
+	}
+	if copyInto == "" {
+		copyInto = copyDatabase
+	}
+	from, err := filepath.Abs(copyFrom)
+	if err != nil {
+		return err
+	}
+	to, err := filepath.Abs(copyTo)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return fmt.Errorf("--from and --to are the same directory")
+	}
+
+	source, err := db.NewReadOnlyStorageManager(copyFrom)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", copyFrom, err)
+	}
+	defer source.Close()
+	// Only the copied database's collections are loaded
+	source.LazyLoad = true
+
+	sourceManager, err := source.LoadAllDatabases()
+	if err != nil {
+		return fmt.Errorf("failed to load databases of %s: %w", copyFrom, err)
+	}
+	src := sourceManager.GetDatabase(copyDatabase)
+	if src == nil {
+		return fmt.Errorf("database '%s' not found in %s", copyDatabase, copyFrom)
+	}
+
+	target, err := db.NewStorageManager(copyTo)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", copyTo, err)
+	}
+	defer target.Close()
+
+	targetManager, err := target.LoadAllDatabases()
+	if err != nil {
+		return fmt.Errorf("failed to load databases of %s: %w", copyTo, err)
+	}
+	dst := targetManager.CreateDatabase(copyInto)
+
+	opts := db.CopyOptions{OnConflict: db.ConflictStrategy(copyOnConflict)}
+	if copyCollection != "" {
+		opts.Collections = []string{copyCollection}
+	}
+	results, err := target.CopyDatabase(src, dst, opts)
+	if err != nil {
+		// Nothing is saved, so the target is left as it was
+		return fmt.Errorf("copy failed: %w", err)
+	}
+
+	if err := target.SaveDatabase(dst); err != nil {
+		return fmt.Errorf("failed to save database: %w", err)
+	}
+
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	total := 0
+	for _, name := range names {
+		counts := results[name]
+		status := "merged"
+		if counts.Created {
+			status = "created"
+		}
+		fmt.Printf("%s/%s (%s): %d copied, %d skipped, %d overwritten\n",
+			copyInto, name, status, counts.Copied, counts.Skipped, counts.Overwritten)
+		total += counts.Copied + counts.Overwritten
+	}
+	fmt.Printf("\nCopied %d document(s) in %d collection(s) from %s to %s\n", total, len(names), copyFrom, copyTo)
+	return nil
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/spf13/cobra"
+)
+
+// dedupeCmd represents the dedupe command
+var dedupeCmd = &cobra.Command{
+	Use:   "dedupe",
+	Short: "Find and remove duplicate documents",
+	Long: `Group the documents of a collection by one or more fields and report every
+group (cluster) with more than one document. Documents missing any of the
+fields are ignored.
+
+By default only a report is printed. With --delete, one document per cluster
+is kept and the others are deleted; --merge also copies fields the kept
+document lacks from the others first. The kept document is chosen by
+--survivor:
+  first          lowest document ID (default)
+  last           highest document ID
+  most-complete  most fields, ties going to the lowest ID`,
+	RunE: runDedupe,
+}
+
+var (
+	dedupeDatabase   string
+	dedupeCollection string
+	dedupeFields     []string
+	dedupeSurvivor   string
+	dedupeDelete     bool
+	dedupeMerge      bool
+)
+
+func init() {
+	utilsCmd.AddCommand(dedupeCmd)
+
+	dedupeCmd.Flags().StringVarP(&dedupeDatabase, "database", "d", "", "Database name")
+	dedupeCmd.Flags().StringVarP(&dedupeCollection, "collection", "c", "", "Collection name")
+	dedupeCmd.Flags().StringSliceVarP(&dedupeFields, "fields", "f", nil, "Comma-separated fields that identify duplicates")
+	dedupeCmd.Flags().StringVarP(&dedupeSurvivor, "survivor", "s", db.SurvivorFirst, "Which document of a cluster to keep (first, last, most-complete)")
+	dedupeCmd.Flags().BoolVar(&dedupeDelete, "delete", false, "Delete all but the surviving document of each cluster")
+	dedupeCmd.Flags().BoolVar(&dedupeMerge, "merge", false, "Like --delete, but first copy missing fields into the survivor")
+}
+
+func runDedupe(cmd *cobra.Command, args []string) error {
+	if dedupeDatabase == "" || dedupeCollection == "" {
+		return fmt.Errorf("both --database and --collection must be specified")
+	}
+	if len(dedupeFields) == 0 {
+		return fmt.Errorf("--fields is required")
+	}
+
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	dbManager, err := storage.LoadAllDatabases()
+	if err != nil {
+		return fmt.Errorf("failed to load databases: %w", err)
+	}
+
+	database := dbManager.GetDatabase(dedupeDatabase)
+	if database == nil {
+		return fmt.Errorf("database '%s' not found", dedupeDatabase)
+	}
+	coll, err := database.GetCollection(dedupeCollection)
+	if err != nil {
+		return err
+	}
+
+	clusters, err := db.FindDuplicates(coll, dedupeFields)
+	if err != nil {
+		return err
+	}
+	if len(clusters) == 0 {
+		fmt.Println("No duplicates found")
+		return nil
+	}
+
+	if !dedupeDelete && !dedupeMerge {
+		for _, cluster := range clusters {
+			printDuplicateCluster(cluster)
+		}
+		fmt.Printf("\n%d duplicate cluster(s). Run with --delete or --merge to resolve them.\n", len(clusters))
+		return nil
+	}
+
+	removed, err := db.ResolveDuplicates(coll, clusters, db.DedupeOptions{Survivor: dedupeSurvivor, Merge: dedupeMerge})
+	if err != nil {
+		return fmt.Errorf("failed to resolve duplicates: %w", err)
+	}
+	for _, cluster := range clusters {
+		printDuplicateCluster(cluster)
+	}
+
+	if err := storage.SaveCollection(database.Name, coll); err != nil {
+		return fmt.Errorf("failed to save collection: %w", err)
+	}
+
+	fmt.Printf("\nRemoved %d document(s) from %d cluster(s)\n", len(removed), len(clusters))
+	return nil
+}
+
+func printDuplicateCluster(cluster db.DuplicateCluster) {
+	key := make([]string, len(cluster.Key))
+	for i, value := range cluster.Key {
+		key[i] = fmt.Sprintf("%v", value)
+	}
+
+	ids := make([]string, len(cluster.DocumentIDs))
+	for i, id := range cluster.DocumentIDs {
+		ids[i] = id
+		if id == cluster.Survivor {
+			ids[i] += " (kept)"
+		}
+	}
+	fmt.Printf("(%s): %s\n", strings.Join(key, ", "), strings.Join(ids, ", "))
+}
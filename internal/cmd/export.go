@@ -0,0 +1,284 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/hop-/cachydb/pkg/db/exporter"
+	"github.com/hop-/cachydb/pkg/db/importer"
+	"github.com/spf13/cobra"
+)
+
+// exportCmd represents the export command
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a database to another format",
+	Long: `Export the collections of a database to another format.
+
+Supported formats:
+  sqlite  One SQLite table per collection: typed columns for schema fields and a
+          JSON "data" column for everything else. If --out ends with .sql (or is
+          "-"), the SQL script is written as is; otherwise it is loaded into the
+          given database file with the sqlite3 command-line tool.
+  ndjson  One JSON document per line, for a single --collection. Documents are
+          streamed one at a time, so collections larger than memory can be
+          exported. Read the file back with "utils import".
+  csv     One row per document of a single --collection, with a header line:
+          "_id", the schema fields, then every other top-level field found.
+          Objects and arrays are written as JSON. --mapping takes the JSON
+          file used by "utils import" ({"Column": "field"}) to name columns.
+  arrow   An Arrow IPC stream of a single --collection, with the columns of the
+          csv format typed from the schema and the values (see README). --query
+          takes a JSON query to export only the matching documents, and
+          --aggregate a JSON aggregation to export its groups instead.`,
+	RunE: runExport,
+}
+
+var (
+	exportDatabase   string
+	exportCollection string
+	exportFormat     string
+	exportOut        string
+	exportMapping    string
+	exportDelimiter  string
+	exportQuery      string
+	exportAggregate  string
+)
+
+func init() {
+	utilsCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVarP(&exportDatabase, "database", "d", "", "Database name to export")
+	exportCmd.Flags().StringVarP(&exportCollection, "collection", "c", "", "Export only this collection")
+	exportCmd.Flags().StringVarP(&exportFormat, "format", "f", "sqlite", "Export format (sqlite, ndjson, csv, arrow)")
+	exportCmd.Flags().StringVarP(&exportOut, "out", "o", "", "Output file (\"-\" for stdout)")
+	exportCmd.Flags().StringVar(&exportMapping, "mapping", "", "CSV only: JSON file mapping column names to fields")
+	exportCmd.Flags().StringVar(&exportDelimiter, "delimiter", ",", "CSV only: column delimiter (a single character, or \\t)")
+	exportCmd.Flags().StringVar(&exportQuery, "query", "", "Arrow only: JSON query selecting the documents to export")
+	exportCmd.Flags().StringVar(&exportAggregate, "aggregate", "", "Arrow only: JSON aggregation whose groups are exported")
+}
+
+func runExport(cmd *cobra.Command, args []string) error {
+	if exportDatabase == "" {
+		return fmt.Errorf("--database is required. Use 'cachydb utils list' to see available databases")
+	}
+	if exportOut == "" {
+		return fmt.Errorf("--out is required")
+	}
+
+	if (exportFormat == "ndjson" || exportFormat == "csv" || exportFormat == "arrow") && exportCollection == "" {
+		return fmt.Errorf("--collection is required for the %s format", exportFormat)
+	}
+
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+	// Only the exported collections are loaded
+	storage.LazyLoad = true
+
+	dbManager, err := storage.LoadAllDatabases()
+	if err != nil {
+		return fmt.Errorf("failed to load databases: %w", err)
+	}
+
+	database := dbManager.GetDatabase(exportDatabase)
+	if database == nil {
+		return fmt.Errorf("database '%s' not found", exportDatabase)
+	}
+
+	var collections []string
+	if exportCollection != "" {
+		collections = []string{exportCollection}
+	}
+
+	switch exportFormat {
+	case "sqlite":
+		return exportSQLite(database, collections)
+	case "ndjson":
+		return exportNDJSON(database, exportCollection)
+	case "csv":
+		return exportCSV(database, exportCollection)
+	case "arrow":
+		return exportArrow(database, exportCollection)
+	default:
+		return fmt.Errorf("unsupported export format '%s'", exportFormat)
+	}
+}
+
+func exportSQLite(database *db.Database, collections []string) error {
+	if exportOut == "-" {
+		return exporter.WriteSQLite(os.Stdout, database, collections)
+	}
+
+	if strings.HasSuffix(exportOut, ".sql") {
+		f, err := os.Create(exportOut)
+		if err != nil {
+			return fmt.Errorf("failed to create output file: %w", err)
+		}
+		defer f.Close()
+
+		if err := exporter.WriteSQLite(f, database, collections); err != nil {
+			return err
+		}
+		fmt.Printf("Wrote SQLite script for database '%s' to %s\n", database.Name, exportOut)
+		return nil
+	}
+
+	sqlitePath, err := exec.LookPath("sqlite3")
+	if err != nil {
+		return fmt.Errorf("sqlite3 not found in PATH; use an --out file ending in .sql to write the SQL script instead")
+	}
+
+	pr, pw := io.Pipe()
+	sqlite := exec.Command(sqlitePath, exportOut)
+	sqlite.Stdin = pr
+	sqlite.Stdout = os.Stdout
+	sqlite.Stderr = os.Stderr
+	if err := sqlite.Start(); err != nil {
+		return fmt.Errorf("failed to start sqlite3: %w", err)
+	}
+
+	writeErr := exporter.WriteSQLite(pw, database, collections)
+	pw.CloseWithError(writeErr)
+
+	if err := sqlite.Wait(); err != nil {
+		return fmt.Errorf("sqlite3 failed: %w", err)
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+
+	fmt.Printf("Exported database '%s' to SQLite file %s\n", database.Name, exportOut)
+	return nil
+}
+
+func exportNDJSON(database *db.Database, collName string) error {
+	coll, err := database.GetCollection(collName)
+	if err != nil {
+		return err
+	}
+
+	if exportOut == "-" {
+		_, err := exporter.WriteNDJSON(os.Stdout, coll)
+		return err
+	}
+
+	f, err := os.Create(exportOut)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer f.Close()
+
+	count, err := exporter.WriteNDJSON(f, coll)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d document(s) of '%s.%s' to %s\n", count, database.Name, collName, exportOut)
+	return nil
+}
+
+func exportCSV(database *db.Database, collName string) error {
+	opts := &exporter.CSVOptions{}
+	var err error
+	if opts.Delimiter, err = parseDelimiter(exportDelimiter); err != nil {
+		return err
+	}
+	if exportMapping != "" {
+		if opts.Mapping, err = importer.LoadCSVMapping(exportMapping); err != nil {
+			return err
+		}
+	}
+
+	coll, err := database.GetCollection(collName)
+	if err != nil {
+		return err
+	}
+
+	if exportOut == "-" {
+		_, err := exporter.WriteCSV(os.Stdout, coll, opts)
+		return err
+	}
+
+	f, err := os.Create(exportOut)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer f.Close()
+
+	count, err := exporter.WriteCSV(f, coll, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Exported %d document(s) of '%s.%s' to %s\n", count, database.Name, collName, exportOut)
+	return nil
+}
+
+func exportArrow(database *db.Database, collName string) error {
+	if exportQuery != "" && exportAggregate != "" {
+		return fmt.Errorf("--query and --aggregate cannot be used together")
+	}
+	var query *db.Query
+	if exportQuery != "" {
+		query = &db.Query{}
+		if err := json.Unmarshal([]byte(exportQuery), query); err != nil {
+			return fmt.Errorf("invalid --query: %w", err)
+		}
+		if query.Filter != nil {
+			if err := query.Filter.Validate(); err != nil {
+				return fmt.Errorf("invalid --query filter: %w", err)
+			}
+		}
+	}
+	var agg *db.Aggregation
+	if exportAggregate != "" {
+		agg = &db.Aggregation{}
+		if err := json.Unmarshal([]byte(exportAggregate), agg); err != nil {
+			return fmt.Errorf("invalid --aggregate: %w", err)
+		}
+	}
+
+	coll, err := database.GetCollection(collName)
+	if err != nil {
+		return err
+	}
+
+	write := func(w io.Writer) (int, error) {
+		if agg == nil {
+			return exporter.WriteArrow(w, coll, &exporter.ArrowOptions{Query: query})
+		}
+		groups, err := coll.Aggregate(agg)
+		if err != nil {
+			return 0, err
+		}
+		return len(groups), exporter.WriteArrowAggregate(w, agg, groups)
+	}
+
+	if exportOut == "-" {
+		_, err := write(os.Stdout)
+		return err
+	}
+
+	f, err := os.Create(exportOut)
+	if err != nil {
+		return fmt.Errorf("failed to create output file: %w", err)
+	}
+	defer f.Close()
+
+	count, err := write(f)
+	if err != nil {
+		return err
+	}
+	if agg != nil {
+		fmt.Printf("Exported %d group(s) of '%s.%s' to %s\n", count, database.Name, collName, exportOut)
+		return nil
+	}
+	fmt.Printf("Exported %d document(s) of '%s.%s' to %s\n", count, database.Name, collName, exportOut)
+	return nil
+}
@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/spf13/cobra"
+)
+
+// fsckCmd represents the fsck command
+var fsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check data, index and WAL files for corruption",
+	Long: `Check the files under the root directory without loading them: every
+document in binary data files is read back and verified against its checksum
+and offset index entry, persisted indexes are compared with the documents in
+both directions, segment and JSON files are parsed, and every WAL entry is
+read and checksummed.
+
+With --repair, index files that disagree with the documents are rebuilt from
+the documents that could be read. Damaged documents and WAL files are only
+reported. The command exits with status 1 if problems remain.`,
+	RunE: runFsck,
+}
+
+var (
+	fsckDatabase   string
+	fsckCollection string
+	fsckRepair     bool
+)
+
+func init() {
+	utilsCmd.AddCommand(fsckCmd)
+
+	fsckCmd.Flags().StringVarP(&fsckDatabase, "database", "d", "", "Only check this database")
+	fsckCmd.Flags().StringVarP(&fsckCollection, "collection", "c", "", "Only check this collection (requires --database)")
+	fsckCmd.Flags().BoolVar(&fsckRepair, "repair", false, "Rebuild index files that disagree with the documents")
+}
+
+func runFsck(cmd *cobra.Command, args []string) error {
+	if fsckCollection != "" && fsckDatabase == "" {
+		return fmt.Errorf("--collection requires --database")
+	}
+
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	report, err := storage.Fsck(db.FsckOptions{
+		Database:   fsckDatabase,
+		Collection: fsckCollection,
+		Repair:     fsckRepair,
+	})
+	if err != nil {
+		return err
+	}
+
+	repaired := 0
+	for _, issue := range report.Issues {
+		location := issue.File
+		if issue.DocumentID != "" {
+			location += fmt.Sprintf(" (document %s)", issue.DocumentID)
+		}
+		status := ""
+		if issue.Repaired {
+			status = " [repaired]"
+			repaired++
+		}
+		fmt.Printf("%s: %s%s\n", location, issue.Problem, status)
+	}
+	if len(report.Issues) > 0 {
+		fmt.Println()
+	}
+
+	fmt.Printf("Checked %d collection(s) with %d intact document(s), and %d WAL file(s) with %d entries\n",
+		report.Collections, report.Documents, report.WALFiles, report.WALEntries)
+	switch {
+	case len(report.Issues) == 0:
+		fmt.Println("No problems found")
+	case report.OK():
+		fmt.Printf("Repaired all %d problem(s)\n", repaired)
+	default:
+		fmt.Printf("Found %d problem(s), %d repaired\n", len(report.Issues), repaired)
+		if !fsckRepair {
+			fmt.Println("Run again with --repair to rebuild damaged index files")
+		}
+		storage.Close()
+		os.Exit(1)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/hop-/cachydb/pkg/db/importer"
+	"github.com/spf13/cobra"
+)
+
+// importCmd represents the import command
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "Import documents into a collection from another format",
+	Long: `Import documents into a collection from another format. The database and
+collection are created if they do not exist yet.
+
+Supported formats:
+  ndjson  One JSON document per line, as written by "utils export --format
+          ndjson". The input is read line by line. A string "_id" member
+          becomes the document ID; documents without one get a new ID.
+  csv     Rows of a CSV file with a header line. Columns are renamed to
+          fields with --mapping, a JSON file of the form {"Column": "field"}
+          (map a column to "" to skip it). Cells are converted to the type of
+          the schema field they fill; for other fields the type is inferred
+          (booleans, numbers, JSON objects and arrays, otherwise strings).
+          Empty cells are left out. The --id-column column holds the ID.
+  mongodump
+          MongoDB data. --in names either a mongodump database directory,
+          whose collections (or only --collection) are imported with their
+          single-field indexes; a single .bson or .bson.gz file; or the
+          Extended JSON output of mongoexport (any other file, or stdin). The
+          collection defaults to the file name. ObjectIDs become hex strings,
+          the _id the document ID, and dates RFC 3339 strings; reserved
+          fields such as __v are dropped with a warning.
+
+Lines and rows that cannot be imported (bad JSON, schema violations,
+duplicate IDs) are reported and skipped, unless --stop-on-error is set. This
+fails while a server holds the directory's lock.`,
+	RunE: runImport,
+}
+
+var (
+	importDatabase    string
+	importCollection  string
+	importFormat      string
+	importIn          string
+	importStopOnError bool
+	importMapping     string
+	importDelimiter   string
+	importIDColumn    string
+)
+
+// maxReportedImportErrors bounds the bad rows listed after an import
+const maxReportedImportErrors = 20
+
+func init() {
+	utilsCmd.AddCommand(importCmd)
+
+	importCmd.Flags().StringVarP(&importDatabase, "database", "d", "", "Database to import into")
+	importCmd.Flags().StringVarP(&importCollection, "collection", "c", "", "Collection to import into (mongodump: defaults to the file name)")
+	importCmd.Flags().StringVarP(&importFormat, "format", "f", "ndjson", "Import format (ndjson, csv, mongodump)")
+	importCmd.Flags().StringVarP(&importIn, "in", "i", "", "Input file (\"-\" for stdin), or a mongodump directory")
+	importCmd.Flags().BoolVar(&importStopOnError, "stop-on-error", false, "Abort at the first document that cannot be imported")
+	importCmd.Flags().StringVar(&importMapping, "mapping", "", "CSV only: JSON file mapping column names to fields")
+	importCmd.Flags().StringVar(&importDelimiter, "delimiter", ",", "CSV only: column delimiter (a single character, or \\t)")
+	importCmd.Flags().StringVar(&importIDColumn, "id-column", "_id", "CSV only: field (after mapping) holding the document ID")
+}
+
+func runImport(cmd *cobra.Command, args []string) error {
+	if importDatabase == "" {
+		return fmt.Errorf("--database is required")
+	}
+	if importIn == "" {
+		return fmt.Errorf("--in is required")
+	}
+	if importCollection == "" && importFormat != "mongodump" {
+		return fmt.Errorf("--collection is required for the %s format", importFormat)
+	}
+
+	csvOpts := &importer.CSVOptions{IDColumn: importIDColumn, StopOnError: importStopOnError}
+	switch importFormat {
+	case "ndjson", "mongodump":
+	case "csv":
+		var err error
+		if csvOpts.Delimiter, err = parseDelimiter(importDelimiter); err != nil {
+			return err
+		}
+		if importMapping != "" {
+			if csvOpts.Mapping, err = importer.LoadCSVMapping(importMapping); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported import format '%s'", importFormat)
+	}
+
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	dbManager, err := storage.LoadAllDatabases()
+	if err != nil {
+		return fmt.Errorf("failed to load databases: %w", err)
+	}
+	database := dbManager.CreateDatabase(importDatabase)
+	ctx := interruptContext(cmd.Context())
+
+	var result *importer.Result
+	target := fmt.Sprintf("'%s.%s'", importDatabase, importCollection)
+	switch importFormat {
+	case "ndjson":
+		result, err = importStream(ctx, database, importCollection, func(coll *db.Collection, in io.Reader) (*importer.Result, error) {
+			return importer.ImportNDJSON(coll, in, &importer.NDJSONOptions{StopOnError: importStopOnError})
+		})
+	case "csv":
+		result, err = importStream(ctx, database, importCollection, func(coll *db.Collection, in io.Reader) (*importer.Result, error) {
+			return importer.ImportCSV(coll, in, csvOpts)
+		})
+	case "mongodump":
+		var collName string
+		result, collName, err = importMongo(ctx, database)
+		if collName != "" {
+			target = fmt.Sprintf("'%s.%s'", importDatabase, collName)
+		} else if result != nil {
+			target = fmt.Sprintf("%d collection(s) of '%s'", result.Collections, importDatabase)
+		}
+	}
+	if err != nil {
+		// Keep the documents imported before the failure out of storage
+		return fmt.Errorf("import failed: %w", err)
+	}
+
+	if err := storage.SaveDatabase(database); err != nil {
+		return fmt.Errorf("failed to save database: %w", err)
+	}
+
+	printImportResult(result, target)
+	return nil
+}
+
+// importStream imports the --in file (or stdin) into the named collection,
+// creating it if needed, until ctx is canceled
+func importStream(ctx context.Context, database *db.Database, collName string, load func(*db.Collection, io.Reader) (*importer.Result, error)) (*importer.Result, error) {
+	var in io.Reader = os.Stdin
+	var size int64
+	var bar *progressBar
+	if importIn != "-" {
+		f, err := os.Open(importIn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open input file: %w", err)
+		}
+		defer f.Close()
+		in = f
+
+		// The size of a regular file tells how far the import got
+		if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+			size = info.Size()
+			if bar = newProgressBar(fmt.Sprintf("Importing %s", filepath.Base(importIn))); bar != nil {
+				bar.format = formatBytes
+				defer bar.Done()
+			}
+		}
+	}
+	in = db.NewProgressReader(ctx, in, size, bar.Update)
+
+	coll, err := database.GetCollection(collName)
+	if err != nil {
+		if err := database.CreateCollection(collName, nil); err != nil {
+			return nil, fmt.Errorf("failed to create collection: %w", err)
+		}
+		if coll, err = database.GetCollection(collName); err != nil {
+			return nil, err
+		}
+	}
+	return load(coll, in)
+}
+
+// importMongo imports a mongodump directory, a single BSON file, or
+// mongoexport Extended JSON, depending on what --in names. It returns the
+// collection imported into, or "" for a whole dump directory.
+func importMongo(ctx context.Context, database *db.Database) (*importer.Result, string, error) {
+	if importIn == "-" {
+		if importCollection == "" {
+			return nil, "", fmt.Errorf("--collection is required to import Extended JSON from stdin")
+		}
+		result, err := importStream(ctx, database, importCollection, importer.ImportMongoExport)
+		return result, importCollection, err
+	}
+
+	info, err := os.Stat(importIn)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open input: %w", err)
+	}
+	if info.IsDir() {
+		if importCollection == "" {
+			result, err := importer.ImportMongoDump(database, importIn)
+			return result, "", err
+		}
+		// Only the collection's file of the dump
+		for _, name := range []string{importCollection + ".bson", importCollection + ".bson.gz"} {
+			path := filepath.Join(importIn, name)
+			if _, err := os.Stat(path); err == nil {
+				result, err := importer.ImportBSONFile(database, importCollection, path)
+				return result, importCollection, err
+			}
+		}
+		return nil, "", fmt.Errorf("collection '%s' is not in the dump directory %s", importCollection, importIn)
+	}
+
+	base := filepath.Base(importIn)
+	collName := importCollection
+	if strings.HasSuffix(base, ".bson") || strings.HasSuffix(base, ".bson.gz") {
+		if collName == "" {
+			collName = strings.TrimSuffix(strings.TrimSuffix(base, ".gz"), ".bson")
+		}
+		result, err := importer.ImportBSONFile(database, collName, importIn)
+		return result, collName, err
+	}
+	if collName == "" {
+		collName = strings.TrimSuffix(base, filepath.Ext(base))
+	}
+	result, err := importStream(ctx, database, collName, importer.ImportMongoExport)
+	return result, collName, err
+}
+
+// printImportResult reports the documents imported into target and the rows skipped
+func printImportResult(result *importer.Result, target string) {
+	for _, warning := range result.Warnings {
+		fmt.Printf("Warning: %s\n", warning)
+	}
+	for i, rowErr := range result.Errors {
+		if i == maxReportedImportErrors {
+			fmt.Printf("... and %d more\n", len(result.Errors)-i)
+			break
+		}
+		fmt.Printf("Skipped line %d: %s\n", rowErr.Row, rowErr.Err)
+	}
+
+	fmt.Printf("Imported %d document(s) into %s", result.Documents, target)
+	if result.Indexes > 0 {
+		fmt.Printf(", with %d index(es)", result.Indexes)
+	}
+	if len(result.Errors) > 0 {
+		fmt.Printf(", skipped %d", len(result.Errors))
+	}
+	fmt.Println()
+}
+
+// parseDelimiter reads a --delimiter flag: a single character, or \t for tab
+func parseDelimiter(value string) (rune, error) {
+	if value == `\t` {
+		return '\t', nil
+	}
+	runes := []rune(value)
+	if len(runes) != 1 || runes[0] == '"' || runes[0] == '\n' || runes[0] == '\r' {
+		return 0, fmt.Errorf("invalid delimiter '%s': use a single character other than a quote or newline", value)
+	}
+	return runes[0], nil
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// jobsCmd represents the jobs command
+var jobsCmd = &cobra.Command{
+	Use:   "jobs",
+	Short: "List the background jobs of a running server",
+	Long: `List the jobs of a running server, newest first: the compactions,
+reindexes, index builds and imports started with "background": true, with
+their status and progress. Finished jobs are listed for an hour.
+
+Jobs live in the server, so pass its HTTP endpoint with --server. Use
+"utils jobs cancel" to stop one.`,
+	RunE: runJobs,
+}
+
+// jobsCancelCmd represents the jobs cancel command
+var jobsCancelCmd = &cobra.Command{
+	Use:   "cancel <job-id>",
+	Short: "Cancel a background job of a running server",
+	Long: `Stop a running job. It stops at its next document or collection and is
+listed as canceled; the work it completed before is kept.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runJobsCancel,
+}
+
+var (
+	jobsServer string
+	jobsStatus string
+)
+
+// jobOutput is a job as listed by the list_jobs tool
+type jobOutput struct {
+	JobID      string `json:"job_id"`
+	Tool       string `json:"tool"`
+	Status     string `json:"status"`
+	Done       int64  `json:"done"`
+	Total      int64  `json:"total"`
+	Unit       string `json:"unit"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at"`
+	Error      *struct {
+		Error string `json:"error"`
+	} `json:"error"`
+}
+
+func init() {
+	utilsCmd.AddCommand(jobsCmd)
+	jobsCmd.AddCommand(jobsCancelCmd)
+
+	jobsCmd.PersistentFlags().StringVar(&jobsServer, "server", "", "MCP endpoint of the running server, e.g. http://localhost:7601/mcp (required)")
+	jobsCmd.Flags().StringVar(&jobsStatus, "status", "", "Only list jobs with this status (running, succeeded, failed, canceled)")
+}
+
+func runJobs(cmd *cobra.Command, args []string) error {
+	if jobsServer == "" {
+		return fmt.Errorf("--server is required")
+	}
+
+	arguments := map[string]any{}
+	if jobsStatus != "" {
+		arguments["status"] = jobsStatus
+	}
+	var output struct {
+		Jobs []jobOutput `json:"jobs"`
+	}
+	if err := callServerTool(cmd.Context(), jobsServer, "list_jobs", arguments, &output); err != nil {
+		return err
+	}
+
+	if len(output.Jobs) == 0 {
+		fmt.Println("No jobs found")
+		return nil
+	}
+	for _, job := range output.Jobs {
+		progress := fmt.Sprintf("%d/%d %s", job.Done, job.Total, job.Unit)
+		if job.Unit == "bytes" {
+			progress = fmt.Sprintf("%s/%s", formatBytes(job.Done), formatBytes(job.Total))
+		}
+		fmt.Printf("%s  %-16s %-9s %-24s started %s\n", job.JobID, job.Tool, job.Status, progress, job.StartedAt)
+		if job.Error != nil {
+			fmt.Printf("    %s\n", job.Error.Error)
+		}
+	}
+	fmt.Printf("\n%d job(s)\n", len(output.Jobs))
+	return nil
+}
+
+func runJobsCancel(cmd *cobra.Command, args []string) error {
+	if jobsServer == "" {
+		return fmt.Errorf("--server is required")
+	}
+
+	var output struct {
+		Message string `json:"message"`
+	}
+	if err := callServerTool(cmd.Context(), jobsServer, "cancel_job", map[string]any{"job_id": args[0]}, &output); err != nil {
+		return err
+	}
+	fmt.Println(output.Message)
+	return nil
+}
@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/hop-/cachydb/pkg/db"
 	"github.com/spf13/cobra"
 )
 
@@ -27,7 +26,7 @@ func init() {
 }
 
 func runList(cmd *cobra.Command, args []string) error {
-	storage, err := db.NewStorageManager(generalRootDir)
+	storage, err := newStorageManager(generalRootDir)
 	if err != nil {
 		return fmt.Errorf("failed to create storage manager: %w", err)
 	}
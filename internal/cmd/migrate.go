@@ -15,7 +15,8 @@ var migrateCmd = &cobra.Command{
 The migration system applies schema updates iteratively.
 
 Each migration step is registered in code and can perform schema transformations,
-data migrations, or any other necessary updates.`,
+data migrations, or any other necessary updates. Collections stored in an older
+binary format version are rewritten in the current version.`,
 	RunE: runMigrate,
 }
 
@@ -25,6 +26,8 @@ var (
 	targetVersion   int
 	showVersion     bool
 	listMigrations  bool
+	migrateDryRun   bool
+	migrateVerify   bool
 )
 
 func init() {
@@ -35,6 +38,8 @@ func init() {
 	migrateCmd.Flags().IntVarP(&targetVersion, "target", "t", db.CurrentSchemaVersion, "Target schema version (default: latest)")
 	migrateCmd.Flags().BoolVarP(&showVersion, "show-version", "v", false, "Show current schema version of database")
 	migrateCmd.Flags().BoolVarP(&listMigrations, "list", "l", false, "List all registered migrations")
+	migrateCmd.Flags().BoolVar(&migrateVerify, "verify", false, "Compare JSON documents with the binary output and fail on any divergence")
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "Report what would be migrated without writing anything")
 }
 
 func runMigrate(cmd *cobra.Command, args []string) error {
@@ -71,13 +76,18 @@ func runMigrate(cmd *cobra.Command, args []string) error {
 	}
 
 	// Create storage manager
-	storage, err := db.NewStorageManager(generalRootDir)
+	storage, err := newStorageManager(generalRootDir)
 	if err != nil {
 		return fmt.Errorf("failed to create storage manager: %w", err)
 	}
 	defer storage.Close()
 
 	migrator := db.NewMigrationManager(storage)
+	// Migrations print their steps, so the bar is printed between them
+	if bar := newProgressBar("Migration"); bar != nil {
+		bar.perLine = true
+		migrator.SetProgress(bar.Update)
+	}
 
 	// Show version
 	if showVersion {
@@ -90,10 +100,41 @@ func runMigrate(cmd *cobra.Command, args []string) error {
 		return nil
 	}
 
+	// Verify JSON to binary conversion
+	if migrateVerify {
+		dbNames := []string{migrateDatabase}
+		if migrateAll {
+			if dbNames, err = storage.ListStoredDatabases(); err != nil {
+				return err
+			}
+		}
+		return verifyDatabases(migrator, dbNames)
+	}
+
+	// Dry run
+	if migrateDryRun {
+		var plans []*db.MigrationPlan
+		if migrateAll {
+			plans, err = migrator.PlanAllDatabases(targetVersion)
+		} else {
+			var plan *db.MigrationPlan
+			plan, err = migrator.PlanDatabase(migrateDatabase, targetVersion)
+			plans = append(plans, plan)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to plan migration: %w", err)
+		}
+		printMigrationPlans(plans)
+		return nil
+	}
+
+	// Ctrl-C stops the migration before its next step and rolls it back
+	ctx := interruptContext(cmd.Context())
+
 	// Migrate all databases
 	if migrateAll {
 		fmt.Printf("Migrating all databases to version %d...\n", targetVersion)
-		if err := migrator.MigrateAllDatabases(targetVersion); err != nil {
+		if err := migrator.MigrateAllDatabasesContext(ctx, targetVersion); err != nil {
 			return fmt.Errorf("migration failed: %w", err)
 		}
 		fmt.Println("All databases migrated successfully!")
@@ -101,10 +142,58 @@ func runMigrate(cmd *cobra.Command, args []string) error {
 	}
 
 	// Migrate single database
-	if err := migrator.MigrateDatabase(migrateDatabase, targetVersion); err != nil {
+	if err := migrator.MigrateDatabaseContext(ctx, migrateDatabase, targetVersion); err != nil {
 		return fmt.Errorf("migration failed: %w", err)
 	}
 
 	fmt.Printf("Database '%s' migrated successfully!\n", migrateDatabase)
 	return nil
 }
+
+func printMigrationPlans(plans []*db.MigrationPlan) {
+	if len(plans) == 0 {
+		fmt.Println("No databases found")
+		return
+	}
+
+	fmt.Println("Dry run, no changes will be written:")
+	for _, plan := range plans {
+		switch {
+		case plan.Err() != nil:
+			fmt.Printf("  %s: version %d -> %d, cannot migrate: %v\n", plan.Database, plan.CurrentVersion, plan.TargetVersion, plan.Err())
+		case !plan.NeedsMigration():
+			fmt.Printf("  %s: already at version %d\n", plan.Database, plan.CurrentVersion)
+		default:
+			fmt.Printf("  %s: version %d -> %d\n", plan.Database, plan.CurrentVersion, plan.TargetVersion)
+			for _, version := range plan.Steps {
+				fmt.Printf("    apply migration %d -> %d\n", version, version+1)
+			}
+		}
+	}
+}
+
+func verifyDatabases(migrator *db.MigrationManager, dbNames []string) error {
+	mismatches := 0
+	for _, dbName := range dbNames {
+		report, err := migrator.VerifyMigration(dbName)
+		if err != nil {
+			return fmt.Errorf("failed to verify database '%s': %w", dbName, err)
+		}
+
+		fmt.Printf("Database '%s': compared %d document(s) in %d collection(s)\n", dbName, report.Documents, report.Collections)
+		for _, m := range report.Mismatches {
+			if m.DocumentID == "" {
+				fmt.Printf("  %s: %s\n", m.Collection, m.Reason)
+			} else {
+				fmt.Printf("  %s/%s: %s\n", m.Collection, m.DocumentID, m.Reason)
+			}
+		}
+		mismatches += len(report.Mismatches)
+	}
+
+	if mismatches > 0 {
+		return fmt.Errorf("verification failed: %d mismatch(es) found", mismatches)
+	}
+	fmt.Println("Verification passed, no divergence found")
+	return nil
+}
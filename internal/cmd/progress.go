@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// progressBarWidth is the number of cells of a progress bar
+const progressBarWidth = 30
+
+// progressRedrawInterval is the least time between two redraws of a bar
+const progressRedrawInterval = 100 * time.Millisecond
+
+// progressBar draws the progress of a long operation on stderr. Bars are
+// only drawn when stderr is a terminal, so scripts and logs get the
+// command's usual output; a nil *progressBar draws nothing.
+type progressBar struct {
+	label string
+	// format formats the counts of units, e.g. as bytes
+	format func(int64) string
+	// perLine prints each update on a line of its own, for operations that
+	// print their own lines in between
+	perLine bool
+
+	last  time.Time
+	drawn bool
+}
+
+// newProgressBar returns a bar labelled label, or nil if stderr is not a
+// terminal
+func newProgressBar(label string) *progressBar {
+	info, err := os.Stderr.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return &progressBar{
+		label:  label,
+		format: func(n int64) string { return fmt.Sprintf("%d", n) },
+	}
+}
+
+// Update draws done of total units; it is a db.ProgressFunc
+func (b *progressBar) Update(done, total int64) {
+	if b == nil || (done < total && time.Since(b.last) < progressRedrawInterval) {
+		return
+	}
+	b.last = time.Now()
+
+	filled, percent := progressBarWidth, int64(100)
+	if total > 0 {
+		filled = int(done * progressBarWidth / total)
+		percent = done * 100 / total
+	}
+	bar := fmt.Sprintf("%s [%s%s] %3d%% %s/%s", b.label,
+		strings.Repeat("#", filled), strings.Repeat("-", progressBarWidth-filled),
+		percent, b.format(done), b.format(total))
+
+	if b.perLine {
+		fmt.Fprintln(os.Stderr, bar)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "\r\033[K%s", bar)
+	b.drawn = true
+}
+
+// Done clears the bar, so the command's output starts on a clean line
+func (b *progressBar) Done() {
+	if b == nil || !b.drawn {
+		return
+	}
+	fmt.Fprint(os.Stderr, "\r\033[K")
+	b.drawn = false
+}
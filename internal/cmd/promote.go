@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// promoteCmd represents the promote command
+var promoteCmd = &cobra.Command{
+	Use:   "promote",
+	Short: "Promote a running read replica to take writes",
+	Long: `Stop a running read replica (see "cachydb replica") from following its leader
+and let it take writes. Entries the leader logged but did not stream yet are
+not applied, so stop writing to the old leader first.`,
+	RunE: runPromote,
+}
+
+var promoteServer string
+
+func init() {
+	utilsCmd.AddCommand(promoteCmd)
+
+	promoteCmd.Flags().StringVar(&promoteServer, "server", "", "MCP endpoint of the replica, e.g. http://localhost:7602/mcp (required)")
+}
+
+func runPromote(cmd *cobra.Command, args []string) error {
+	if promoteServer == "" {
+		return fmt.Errorf("--server is required")
+	}
+
+	var output struct {
+		Offset uint64 `json:"offset"`
+	}
+	if err := callServerTool(cmd.Context(), promoteServer, "promote", map[string]any{}, &output); err != nil {
+		return err
+	}
+
+	fmt.Printf("Promoted; taking writes from WAL offset %d\n", output.Offset)
+	return nil
+}
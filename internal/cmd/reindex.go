@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+)
+
+// reindexCmd represents the reindex command
+var reindexCmd = &cobra.Command{
+	Use:   "reindex",
+	Short: "Rebuild indexes from the documents of collections",
+	Long: `Discard the contents of every index of a database's collections and
+build them again from the documents, then save them. Use it when index files
+have drifted from the document data, e.g. after a crash (see "utils fsck").
+
+Without --collection every collection of the database is reindexed. This
+fails while a server holds the directory's lock.`,
+	RunE: runReindex,
+}
+
+var (
+	reindexDatabase   string
+	reindexCollection string
+)
+
+func init() {
+	utilsCmd.AddCommand(reindexCmd)
+
+	reindexCmd.Flags().StringVarP(&reindexDatabase, "database", "d", "", "Database to reindex (required)")
+	reindexCmd.Flags().StringVarP(&reindexCollection, "collection", "c", "", "Only reindex this collection")
+}
+
+func runReindex(cmd *cobra.Command, args []string) error {
+	if reindexDatabase == "" {
+		return fmt.Errorf("--database is required. Use 'cachydb utils list' to see available databases")
+	}
+
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	dbManager, err := storage.LoadAllDatabases()
+	if err != nil {
+		return fmt.Errorf("failed to load databases: %w", err)
+	}
+
+	database := dbManager.GetDatabase(reindexDatabase)
+	if database == nil {
+		return fmt.Errorf("database '%s' not found", reindexDatabase)
+	}
+
+	collections := []string{reindexCollection}
+	if reindexCollection == "" {
+		collections = database.ListCollections()
+		sort.Strings(collections)
+	}
+	if len(collections) == 0 {
+		fmt.Println("No collections found")
+		return nil
+	}
+
+	ctx := interruptContext(cmd.Context())
+	for _, collName := range collections {
+		coll, err := database.GetCollection(collName)
+		if err != nil {
+			return err
+		}
+		bar := newProgressBar(fmt.Sprintf("Reindexing %s/%s", reindexDatabase, collName))
+		err = coll.RebuildIndexesWithProgress(ctx, bar.Update)
+		bar.Done()
+		if err != nil {
+			return fmt.Errorf("failed to rebuild indexes of collection '%s': %w", collName, err)
+		}
+		if err := storage.SaveCollection(reindexDatabase, coll); err != nil {
+			return fmt.Errorf("failed to save collection '%s': %w", collName, err)
+		}
+
+		indexes := coll.ListIndexes()
+		names := make([]string, 0, len(indexes))
+		for name := range indexes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("%s/%s: rebuilt %d index(es) %v\n", reindexDatabase, collName, len(names), names)
+	}
+	fmt.Printf("\nReindexed %d collection(s)\n", len(collections))
+	return nil
+}
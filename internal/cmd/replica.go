@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// replicaCmd represents the replica command
+var replicaCmd = &cobra.Command{
+	Use:   "replica",
+	Short: "Run the application as a read replica of another server",
+	Long: `Run the server as a read replica of the server at --leader. The replica
+streams the leader's WAL over HTTP from the offset it has reached and applies
+the entries to its own root directory, reconnecting when the stream breaks.
+
+It serves the read-only tools; tools that change data fail until the replica
+is promoted with the promote tool or "cachydb utils promote". The leader must
+use the HTTP transport, which serves its WAL at /replication/wal, and both
+must share the same REPLICATION_TOKEN environment variable.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if generalLeader == "" {
+			fmt.Fprintln(os.Stderr, "Error: --leader is required")
+			os.Exit(1)
+		}
+		executeApp()
+	},
+}
+
+func init() {
+	setAllFlagsToCmd(replicaCmd)
+	replicaCmd.Flags().StringVar(&generalLeader, "leader", "", "address of the leader, e.g. http://leader:7601 (required)")
+
+	rootCmd.AddCommand(replicaCmd)
+}
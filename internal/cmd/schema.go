@@ -0,0 +1,188 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/spf13/cobra"
+)
+
+// schemaCmd represents the schema command group
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Inspect collection schemas",
+}
+
+// schemaDiffCmd represents the schema diff command
+var schemaDiffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Compare collection schemas",
+	Long: `Compare the collection schemas of a database (--from) with those of another
+database (--to) or of a JSON Schema file (--to-file), and report collections
+and fields that were added, removed, retyped, or changed between optional and
+required. Changes are described going from --from to the target.
+
+The JSON Schema file holds either one object schema with "properties" (compared
+with --collection) or an object mapping collection names to object schemas.
+
+Use --exit-code to exit with status 1 when the schemas differ, e.g. in CI
+before promoting schema changes to another environment.`,
+	RunE: runSchemaDiff,
+}
+
+var (
+	schemaDiffFrom       string
+	schemaDiffTo         string
+	schemaDiffToRoot     string
+	schemaDiffToFile     string
+	schemaDiffCollection string
+	schemaDiffExitCode   bool
+)
+
+func init() {
+	schemaCmd.AddCommand(schemaDiffCmd)
+	utilsCmd.AddCommand(schemaCmd)
+
+	schemaDiffCmd.Flags().StringVar(&schemaDiffFrom, "from", "", "Source database")
+	schemaDiffCmd.Flags().StringVar(&schemaDiffTo, "to", "", "Target database")
+	schemaDiffCmd.Flags().StringVar(&schemaDiffToRoot, "to-root", "", "Root directory of the target database (default: --root)")
+	schemaDiffCmd.Flags().StringVar(&schemaDiffToFile, "to-file", "", "Target JSON Schema file")
+	schemaDiffCmd.Flags().StringVarP(&schemaDiffCollection, "collection", "c", "", "Only compare this collection")
+	schemaDiffCmd.Flags().BoolVar(&schemaDiffExitCode, "exit-code", false, "Exit with status 1 if the schemas differ")
+}
+
+func runSchemaDiff(cmd *cobra.Command, args []string) error {
+	if schemaDiffFrom == "" {
+		return fmt.Errorf("--from is required")
+	}
+	if (schemaDiffTo == "") == (schemaDiffToFile == "") {
+		return fmt.Errorf("exactly one of --to and --to-file must be specified")
+	}
+
+	from, err := loadDatabaseSchemas(generalRootDir, schemaDiffFrom)
+	if err != nil {
+		return err
+	}
+
+	var to map[string]*db.Schema
+	if schemaDiffToFile != "" {
+		data, err := os.ReadFile(schemaDiffToFile)
+		if err != nil {
+			return fmt.Errorf("failed to read schema file: %w", err)
+		}
+		if to, err = db.ParseJSONSchema(data); err != nil {
+			return err
+		}
+		if single, ok := to[""]; ok {
+			if schemaDiffCollection == "" {
+				return fmt.Errorf("%s describes a single collection; use --collection to choose which one to compare", schemaDiffToFile)
+			}
+			to = map[string]*db.Schema{schemaDiffCollection: single}
+		}
+	} else {
+		root := schemaDiffToRoot
+		if root == "" {
+			root = generalRootDir
+		}
+		if to, err = loadDatabaseSchemas(root, schemaDiffTo); err != nil {
+			return err
+		}
+	}
+
+	if schemaDiffCollection != "" {
+		from = onlyCollection(from, schemaDiffCollection)
+		to = onlyCollection(to, schemaDiffCollection)
+	}
+
+	diff := db.DiffCollectionSchemas(from, to)
+	if diff.Empty() {
+		fmt.Println("Schemas are identical")
+		return nil
+	}
+	printSchemaDiff(diff)
+
+	if schemaDiffExitCode {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// loadDatabaseSchemas loads one database from a root directory and returns its collection schemas
+func loadDatabaseSchemas(rootDir, dbName string) (map[string]*db.Schema, error) {
+	storage, err := newStorageManager(rootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	database, err := storage.LoadDatabase(dbName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load database '%s': %w", dbName, err)
+	}
+	return database.CollectionSchemas(), nil
+}
+
+func onlyCollection(schemas map[string]*db.Schema, name string) map[string]*db.Schema {
+	schema, exists := schemas[name]
+	if !exists {
+		return map[string]*db.Schema{}
+	}
+	return map[string]*db.Schema{name: schema}
+}
+
+func printSchemaDiff(diff *db.SchemaDiff) {
+	for _, name := range diff.AddedCollections {
+		fmt.Printf("+ collection %s\n", name)
+	}
+	for _, name := range diff.RemovedCollections {
+		fmt.Printf("- collection %s\n", name)
+	}
+
+	for _, coll := range diff.Collections {
+		fmt.Printf("%s:\n", coll.Collection)
+		for _, change := range coll.Changes {
+			switch change.Kind {
+			case db.FieldAdded:
+				fmt.Printf("  + %s (%s)\n", change.Field, describeField(*change.To))
+			case db.FieldRemoved:
+				fmt.Printf("  - %s (%s)\n", change.Field, describeField(*change.From))
+			default:
+				fmt.Printf("  ~ %s: %s -> %s\n", change.Field, describeField(*change.From), describeField(*change.To))
+			}
+		}
+	}
+}
+
+func describeField(field db.Field) string {
+	parts := []string{string(field.Type)}
+	if field.Required {
+		parts = append(parts, "required")
+	}
+	if field.Unique {
+		parts = append(parts, "unique")
+	}
+	if len(field.Enum) > 0 {
+		parts = append(parts, fmt.Sprintf("enum %v", field.Enum))
+	}
+	if field.Min != nil {
+		parts = append(parts, fmt.Sprintf("min %v", *field.Min))
+	}
+	if field.Max != nil {
+		parts = append(parts, fmt.Sprintf("max %v", *field.Max))
+	}
+	if field.MinLength > 0 {
+		parts = append(parts, fmt.Sprintf("min length %d", field.MinLength))
+	}
+	if field.MaxLength > 0 {
+		parts = append(parts, fmt.Sprintf("max length %d", field.MaxLength))
+	}
+	if field.Pattern != "" {
+		parts = append(parts, fmt.Sprintf("pattern %q", field.Pattern))
+	}
+	if field.Items != nil {
+		parts = append(parts, fmt.Sprintf("items (%s)", describeField(*field.Items)))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db/fixtures"
+	"github.com/spf13/cobra"
+)
+
+// seedCmd represents the seed command
+var seedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "Fill a collection with generated sample documents",
+	Long: `Generate realistic fake documents and insert them into a collection.
+Documents follow the collection schema when one is defined. The database and
+collection are created if they do not exist yet.`,
+	RunE: runSeed,
+}
+
+var (
+	seedDatabase   string
+	seedCollection string
+	seedCount      int
+	seedValue      uint64
+)
+
+func init() {
+	utilsCmd.AddCommand(seedCmd)
+
+	seedCmd.Flags().StringVarP(&seedDatabase, "database", "d", "", "Database name to seed")
+	seedCmd.Flags().StringVarP(&seedCollection, "collection", "c", "", "Collection name to seed")
+	seedCmd.Flags().IntVarP(&seedCount, "count", "n", 100, "Number of documents to generate")
+	seedCmd.Flags().Uint64VarP(&seedValue, "seed", "s", 0, "Random seed (default: current time)")
+}
+
+func runSeed(cmd *cobra.Command, args []string) error {
+	if seedDatabase == "" || seedCollection == "" {
+		return fmt.Errorf("both --database and --collection must be specified")
+	}
+	if seedCount <= 0 {
+		return fmt.Errorf("--count must be positive")
+	}
+	if seedValue == 0 {
+		seedValue = uint64(time.Now().UnixNano())
+	}
+
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	dbManager, err := storage.LoadAllDatabases()
+	if err != nil {
+		return fmt.Errorf("failed to load databases: %w", err)
+	}
+
+	database := dbManager.CreateDatabase(seedDatabase)
+	coll, err := database.GetCollection(seedCollection)
+	if err != nil {
+		if err := database.CreateCollection(seedCollection, nil); err != nil {
+			return fmt.Errorf("failed to create collection: %w", err)
+		}
+		if coll, err = database.GetCollection(seedCollection); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fixtures.Seed(coll, seedCount, seedValue); err != nil {
+		return fmt.Errorf("failed to seed collection: %w", err)
+	}
+
+	if err := storage.SaveDatabase(database); err != nil {
+		return fmt.Errorf("failed to save database: %w", err)
+	}
+
+	fmt.Printf("Inserted %d document(s) into '%s.%s' (seed %d)\n", seedCount, seedDatabase, seedCollection, seedValue)
+	return nil
+}
@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hop-/cachydb/internal/service"
+	"github.com/spf13/cobra"
+)
+
+// serviceCmd represents the service command
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "Run CachyDB as a managed background service",
+	Long: `Install, uninstall, or run CachyDB as a background service.
+On Linux a systemd unit (Type=notify) is installed; on Windows the server is
+registered with the Service Control Manager. The service always uses the HTTP
+transport.`,
+}
+
+var serviceInstallCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install and enable the service",
+	RunE:  runServiceInstall,
+}
+
+var serviceUninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Stop and remove the service",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := service.Uninstall(serviceName); err != nil {
+			return err
+		}
+		fmt.Printf("Service '%s' uninstalled\n", serviceName)
+		return nil
+	},
+}
+
+var serviceRunCmd = &cobra.Command{
+	Use:   "run",
+	Short: "Run the server under the service manager",
+	Long: `Run the server in the foreground, signalling readiness and shutdown to the
+service manager. This is the command the installed service executes.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		generalTransport = "http"
+		return service.Run(serviceName, runApp)
+	},
+}
+
+var serviceName string
+
+func init() {
+	serviceCmd.PersistentFlags().StringVar(&serviceName, "name", service.DefaultName, "service name")
+
+	for _, cmd := range []*cobra.Command{serviceInstallCmd, serviceRunCmd} {
+		setAllFlagsToCmd(cmd)
+		cmd.Flags().MarkHidden("transport") //nolint:errcheck // services always use HTTP
+	}
+
+	serviceCmd.AddCommand(serviceInstallCmd, serviceUninstallCmd, serviceRunCmd)
+	rootCmd.AddCommand(serviceCmd)
+}
+
+func runServiceInstall(cmd *cobra.Command, args []string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return fmt.Errorf("failed to locate executable: %w", err)
+	}
+
+	rootDir, err := filepath.Abs(generalRootDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve root directory: %w", err)
+	}
+
+	runArgs := []string{
+		"service", "run",
+		"--name", serviceName,
+		"--root", rootDir,
+		"--port", strconv.Itoa(generalServerPort),
+	}
+	if generalTenant != "" {
+		runArgs = append(runArgs, "--tenant", generalTenant)
+	}
+	if generalReqTenant {
+		runArgs = append(runArgs, "--require-tenant")
+	}
+	if len(generalTools) > 0 {
+		runArgs = append(runArgs, "--tools", strings.Join(generalTools, ","))
+	}
+	if generalMongoSync.URI != "" {
+		runArgs = append(runArgs, "--mongo-sync-uri", generalMongoSync.URI,
+			"--mongo-sync-collections", strings.Join(generalMongoSync.Collections, ","))
+		if generalMongoSync.Database != "" {
+			runArgs = append(runArgs, "--mongo-sync-database", generalMongoSync.Database)
+		}
+	}
+	if generalEmbedderURL != "" {
+		runArgs = append(runArgs, "--embedder-url", generalEmbedderURL)
+		if generalEmbedderModel != "" {
+			runArgs = append(runArgs, "--embedder-model", generalEmbedderModel)
+		}
+		if generalEmbedderAPIKey != "" {
+			runArgs = append(runArgs, "--embedder-api-key", generalEmbedderAPIKey)
+		}
+	}
+	if generalWALConfig.Dir != "" {
+		walDir, err := filepath.Abs(generalWALConfig.Dir)
+		if err != nil {
+			return fmt.Errorf("failed to resolve WAL directory: %w", err)
+		}
+		runArgs = append(runArgs, "--wal-dir", walDir)
+	}
+	if generalWALArchive != "" {
+		archiveDir, err := filepath.Abs(generalWALArchive)
+		if err != nil {
+			return fmt.Errorf("failed to resolve WAL archive directory: %w", err)
+		}
+		runArgs = append(runArgs, "--wal-archive", archiveDir)
+	}
+
+	err = service.Install(service.Config{
+		Name:        serviceName,
+		Description: "CachyDB document database (MCP server)",
+		Executable:  executable,
+		Args:        runArgs,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Service '%s' installed, serving data from %s on port %d\n", serviceName, rootDir, generalServerPort)
+	return nil
+}
+
+// runApp builds and runs the application until ctx is cancelled, calling
+// ready once databases are loaded and the server is about to accept requests
+func runApp(ctx context.Context, ready func()) error {
+	application, err := buildApp()
+	if err != nil {
+		return err
+	}
+	defer application.Stop() //nolint:errcheck
+
+	ready()
+	return application.Start(ctx)
+}
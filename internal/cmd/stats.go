@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/spf13/cobra"
+)
+
+// statsCmd represents the stats command
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show disk usage of databases and collections",
+	Long: `Show how much disk space each database and collection uses, split into
+data files, index files, metadata, and its share of the write-ahead log.`,
+	RunE: runStats,
+}
+
+var (
+	statsDatabase   string
+	statsCollection string
+)
+
+func init() {
+	utilsCmd.AddCommand(statsCmd)
+
+	statsCmd.Flags().StringVarP(&statsDatabase, "database", "d", "", "Only show this database")
+	statsCmd.Flags().StringVarP(&statsCollection, "collection", "c", "", "Only show this collection (requires --database)")
+}
+
+func runStats(cmd *cobra.Command, args []string) error {
+	if statsCollection != "" && statsDatabase == "" {
+		return fmt.Errorf("--collection requires --database")
+	}
+
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	databases := []string{statsDatabase}
+	if statsDatabase == "" {
+		if databases, err = storage.ListStoredDatabases(); err != nil {
+			return err
+		}
+		sort.Strings(databases)
+	}
+	if len(databases) == 0 {
+		fmt.Println("No databases found")
+		return nil
+	}
+
+	for _, dbName := range databases {
+		size, err := storage.SizeOf(dbName, "")
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%s: %s\n", dbName, formatStorageSize(size))
+
+		collections, err := storedCollections(storage.RootDir, dbName)
+		if err != nil {
+			return err
+		}
+		for _, collName := range collections {
+			if statsCollection != "" && collName != statsCollection {
+				continue
+			}
+			size, err := storage.SizeOf(dbName, collName)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("  └─ %s: %s\n", collName, formatStorageSize(size))
+
+			indexes := make([]string, 0, len(size.Indexes))
+			for name := range size.Indexes {
+				indexes = append(indexes, name)
+			}
+			sort.Strings(indexes)
+			for _, name := range indexes {
+				fmt.Printf("       index %s: %s\n", name, formatBytes(size.Indexes[name]))
+			}
+		}
+	}
+
+	return nil
+}
+
+// storedCollections lists the collection directories of a database on disk
+func storedCollections(rootDir, dbName string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(rootDir, dbName))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+func formatStorageSize(size *db.StorageSize) string {
+	return fmt.Sprintf("%s total (data %s, indexes %s, metadata %s, blobs %s, WAL %s)",
+		formatBytes(size.Total()), formatBytes(size.DataBytes), formatBytes(size.IndexBytes),
+		formatBytes(size.MetaBytes), formatBytes(size.BlobBytes), formatBytes(size.WALBytes))
+}
+
+// formatBytes renders a byte count with a binary unit suffix
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
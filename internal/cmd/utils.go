@@ -1,7 +1,16 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
 	"github.com/hop-/cachydb/internal/config"
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
 	"github.com/spf13/cobra"
 )
 
@@ -21,6 +30,80 @@ func init() {
 		config.GetConfig().RootDir,
 		"root directory for application data and configurations",
 	)
+	utilsCmd.PersistentFlags().StringVar(
+		&generalWALConfig.Dir,
+		"wal-dir",
+		config.GetConfig().WALDir,
+		"directory of the WAL files, if the server keeps them outside the root directory",
+	)
+	utilsCmd.PersistentFlags().BoolVar(
+		&generalReadOnly,
+		"read-only",
+		false,
+		"open the root directory without locking it, e.g. while a server is using it; commands that write fail",
+	)
 
 	rootCmd.AddCommand(utilsCmd)
 }
+
+// newStorageManager opens a root directory for a utils command, with its
+// WAL in --wal-dir if set, without locking it when --read-only is set
+func newStorageManager(rootDir string) (*db.StorageManager, error) {
+	walConfig := db.WALConfig{Dir: generalWALConfig.Dir}
+	if generalReadOnly {
+		return db.NewReadOnlyStorageManagerWithWAL(rootDir, walConfig)
+	}
+	return db.NewStorageManagerWithWAL(rootDir, walConfig)
+}
+
+// interruptContext returns a context canceled by the first Ctrl-C, for
+// commands that stop cleanly; a second Ctrl-C kills the process as usual
+func interruptContext(ctx context.Context) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx
+}
+
+// callServerTool calls a tool of the running server at the MCP endpoint
+// and decodes its structured result into output
+func callServerTool(ctx context.Context, endpoint, tool string, arguments map[string]any, output any) error {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "cachydb", Version: getVersion()}, nil)
+	session, err := client.Connect(ctx, &mcp.StreamableClientTransport{Endpoint: endpoint}, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", endpoint, err)
+	}
+	defer session.Close()
+
+	res, err := session.CallTool(ctx, &mcp.CallToolParams{Name: tool, Arguments: arguments})
+	if err != nil {
+		return fmt.Errorf("%s call failed: %w", tool, err)
+	}
+	if res.IsError {
+		var messages []string
+		for _, content := range res.Content {
+			if text, ok := content.(*mcp.TextContent); ok {
+				messages = append(messages, text.Text)
+			}
+		}
+		return fmt.Errorf("%s failed on the server: %s", tool, strings.Join(messages, "; "))
+	}
+
+	data, err := json.Marshal(res.StructuredContent)
+	if err != nil {
+		return fmt.Errorf("unexpected %s result: %w", tool, err)
+	}
+	if err := json.Unmarshal(data, output); err != nil {
+		return fmt.Errorf("unexpected %s result: %w", tool, err)
+	}
+	return nil
+}
@@ -1,9 +1,35 @@
 package cmd
 
+import (
+	"time"
+
+	"github.com/hop-/cachydb/internal/mongosync"
+	"github.com/hop-/cachydb/pkg/db"
+)
+
 var (
 	Version           = "" // This will be set during build time using -ldflags "-X github.com/hop-/cachydb/internal/cmd.Version=$(git describe --tags --always)"
 	defaultVersion    = "v0.0.0-dev"
 	generalRootDir    string
 	generalServerPort int
+	generalGRPCPort   int
+	generalRESPPort   int
 	generalTransport  string
+	generalTenant     string
+	generalReqTenant  bool
+	generalProfile    string
+	generalDurability string
+	generalWALArchive string
+	generalWALConfig  db.WALConfig
+	generalCheckpoint db.CheckpointPolicy
+	generalReadOnly   bool
+	generalLeader     string
+	generalAuditLog   bool
+	generalAuditTTL   time.Duration
+	generalTools      []string
+	generalMongoSync  mongosync.Config
+
+	generalEmbedderURL    string
+	generalEmbedderModel  string
+	generalEmbedderAPIKey string
 )
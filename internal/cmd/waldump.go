@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/spf13/cobra"
+)
+
+// walDumpCmd represents the wal-dump command
+var walDumpCmd = &cobra.Command{
+	Use:   "wal-dump",
+	Short: "Print the entries of the write-ahead log",
+	Long: `Decode the WAL files of the root directory and print every entry: its
+offset, the time it was logged, the operation, the database and collection,
+and the document ID. The operations of a transaction entry are listed below
+it. Entries below the checkpoint offset are already saved to the data files.
+
+With --json, each entry is printed as one JSON object per line, including
+its data. The WAL is only read, so use --read-only to inspect the directory
+of a running server.`,
+	RunE: runWALDump,
+}
+
+var (
+	walDumpFromOffset uint64
+	walDumpDatabase   string
+	walDumpJSON       bool
+)
+
+func init() {
+	utilsCmd.AddCommand(walDumpCmd)
+
+	walDumpCmd.Flags().Uint64Var(&walDumpFromOffset, "from-offset", 0, "Only print entries at or after this offset")
+	walDumpCmd.Flags().StringVarP(&walDumpDatabase, "database", "d", "", "Only print entries of this database")
+	walDumpCmd.Flags().BoolVar(&walDumpJSON, "json", false, "Print one JSON object per entry")
+}
+
+// walDumpEntry is the JSON form of a WAL entry printed by wal-dump
+type walDumpEntry struct {
+	Offset     uint64    `json:"offset"`
+	Timestamp  time.Time `json:"timestamp"`
+	Operation  string    `json:"operation"`
+	Database   string    `json:"database"`
+	Collection string    `json:"collection,omitempty"`
+	DocumentID string    `json:"document_id,omitempty"`
+	Saved      bool      `json:"saved"` // below the checkpoint
+	Data       any       `json:"data,omitempty"`
+}
+
+func runWALDump(cmd *cobra.Command, args []string) error {
+	storage, err := newStorageManager(generalRootDir)
+	if err != nil {
+		return fmt.Errorf("failed to create storage manager: %w", err)
+	}
+	defer storage.Close()
+
+	entries, err := storage.WAL.ReadFrom(walDumpFromOffset)
+	if err != nil {
+		return fmt.Errorf("failed to read WAL: %w", err)
+	}
+	checkpoint := storage.WAL.GetCheckpoint().Offset
+
+	if walDumpJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			if walDumpDatabase != "" && entry.Database != walDumpDatabase {
+				continue
+			}
+			out := walDumpEntry{
+				Offset:     entry.Offset,
+				Timestamp:  entry.Timestamp,
+				Operation:  entry.Operation,
+				Database:   entry.Database,
+				Collection: entry.Collection,
+				DocumentID: entry.DocumentID,
+				Saved:      entry.Offset < checkpoint,
+			}
+			if len(entry.Data) > 0 {
+				if json.Valid(entry.Data) {
+					out.Data = json.RawMessage(entry.Data)
+				} else {
+					out.Data = entry.Data // base64
+				}
+			}
+			if err := encoder.Encode(out); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fmt.Printf("Checkpoint: offset %d\n\n", checkpoint)
+	printed := 0
+	for _, entry := range entries {
+		if walDumpDatabase != "" && entry.Database != walDumpDatabase {
+			continue
+		}
+		printed++
+
+		target := entry.Database
+		if entry.Collection != "" {
+			target += "/" + entry.Collection
+		}
+		if entry.DocumentID != "" {
+			target += " " + entry.DocumentID
+		}
+		fmt.Printf("%d  %s  %-17s %s (%s)\n", entry.Offset, entry.Timestamp.Format("2006-01-02T15:04:05.000000Z07:00"),
+			entry.Operation, target, formatBytes(int64(len(entry.Data))))
+
+		if entry.Operation == db.WALOpTransaction {
+			var results []db.TxResult
+			if err := json.Unmarshal(entry.Data, &results); err != nil {
+				fmt.Printf("    (unreadable operations: %v)\n", err)
+				continue
+			}
+			for _, result := range results {
+				fmt.Printf("    %-6s %s %s\n", result.Op, result.Collection, result.ID)
+			}
+		}
+	}
+	fmt.Printf("\n%d entries\n", printed)
+	return nil
+}
@@ -3,25 +3,69 @@ package config
 import (
 	"os"
 	"path"
+	"sync"
+	"time"
 
 	"github.com/kelseyhightower/envconfig"
 )
 
+// Config holds the settings read from the environment. envconfig ignores the
+// env tags, so every field names its documented variable in an envconfig tag.
 type Config struct {
-	Port        int    `env:"PORT" default:"7601"`
-	RootDir     string `env:"ROOT_DIR" default:""`
+	Port        int    `env:"PORT" envconfig:"PORT" default:"7601"`
+	GRPCPort    int    `env:"GRPC_PORT" envconfig:"GRPC_PORT" default:"0"`
+	RESPPort    int    `env:"RESP_PORT" envconfig:"RESP_PORT" default:"0"`
+	RootDir     string `env:"ROOT_DIR" envconfig:"ROOT_DIR" default:""`
 	RootDirName string `default:".cachydb"`
-	DBName      string `env:"DB_NAME" default:"main"`
-	Transport   string `env:"TRANSPORT" default:"stdio"`
+	DBName      string `env:"DB_NAME" envconfig:"DB_NAME" default:"main"`
+	Transport   string `env:"TRANSPORT" envconfig:"TRANSPORT" default:"stdio"`
+	Profile     string `env:"PROFILE" envconfig:"PROFILE" default:"prod"`
+	Durability  string `env:"DURABILITY" envconfig:"DURABILITY" default:""`
+	WALArchive  string `env:"WAL_ARCHIVE_DIR" envconfig:"WAL_ARCHIVE_DIR" default:""`
+
+	WALDir           string        `env:"WAL_DIR" envconfig:"WAL_DIR" default:""`
+	WALMaxSize       int64         `env:"WAL_MAX_SIZE" envconfig:"WAL_MAX_SIZE" default:"0"`
+	WALRetention     int           `env:"WAL_RETENTION_COUNT" envconfig:"WAL_RETENTION_COUNT" default:"0"`
+	WALBatchSize     int           `env:"WAL_BATCH_SIZE" envconfig:"WAL_BATCH_SIZE" default:"0"`
+	WALFlushInterval time.Duration `env:"WAL_FLUSH_INTERVAL" envconfig:"WAL_FLUSH_INTERVAL" default:"0"`
+
+	CheckpointEntries int   `env:"CHECKPOINT_ENTRIES" envconfig:"CHECKPOINT_ENTRIES" default:"0"`
+	CheckpointBytes   int64 `env:"CHECKPOINT_BYTES" envconfig:"CHECKPOINT_BYTES" default:"0"`
+
+	AuditLog       bool          `env:"AUDIT_LOG" envconfig:"AUDIT_LOG" default:"false"`
+	AuditRetention time.Duration `env:"AUDIT_RETENTION" envconfig:"AUDIT_RETENTION" default:"720h"`
+
+	Tools []string `env:"TOOLS" envconfig:"TOOLS" default:""`
+
+	ReplicationToken string `env:"REPLICATION_TOKEN" envconfig:"REPLICATION_TOKEN" default:""`
+
+	MongoSyncURI         string   `env:"MONGO_SYNC_URI" envconfig:"MONGO_SYNC_URI" default:""`
+	MongoSyncCollections []string `env:"MONGO_SYNC_COLLECTIONS" envconfig:"MONGO_SYNC_COLLECTIONS" default:""`
+	MongoSyncDatabase    string   `env:"MONGO_SYNC_DATABASE" envconfig:"MONGO_SYNC_DATABASE" default:""`
+
+	Tenant        string `env:"TENANT" envconfig:"TENANT" default:""`
+	RequireTenant bool   `env:"REQUIRE_TENANT" envconfig:"REQUIRE_TENANT" default:"false"`
+
+	EmbedderURL    string `env:"EMBEDDER_URL" envconfig:"EMBEDDER_URL" default:""`
+	EmbedderModel  string `env:"EMBEDDER_MODEL" envconfig:"EMBEDDER_MODEL" default:""`
+	EmbedderAPIKey string `env:"EMBEDDER_API_KEY" envconfig:"EMBEDDER_API_KEY" default:""`
 }
 
 var cfg Config
+var initOnce sync.Once
 var (
 	// Windows specific
 	windowsRootDirName string
 )
 
+// Init reads the configuration from the environment. It runs once, on the
+// first call to Init or GetConfig, so flags registered by init functions
+// that run before the root command's see the environment too.
 func Init() {
+	initOnce.Do(load)
+}
+
+func load() {
 	envconfig.Process("", &cfg)
 
 	if windowsRootDirName != "" {
@@ -40,5 +84,6 @@ func Init() {
 }
 
 func GetConfig() Config {
+	Init()
 	return cfg
 }
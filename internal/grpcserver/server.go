@@ -0,0 +1,416 @@
+// Package grpcserver serves the CachyDB gRPC service defined in
+// proto/cachydb/v1/cachydb.proto. Writes are logged to the WAL like those
+// of the MCP tools and the REST API.
+package grpcserver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+
+	"github.com/hop-/cachydb/pkg/cachydbpb"
+	"github.com/hop-/cachydb/pkg/db"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// TenantMetadata is the metadata key selecting the tenant of a call, like
+// the X-CachyDB-Tenant header of the HTTP transports
+const TenantMetadata = "x-cachydb-tenant"
+
+// Server implements cachydbpb.CachyDBServer
+type Server struct {
+	cachydbpb.UnimplementedCachyDBServer
+
+	databases *db.DatabaseManager
+	storage   *db.StorageManager
+	// tenant, if set, restricts every call to that tenant's databases
+	tenant        string
+	requireTenant bool
+}
+
+// NewServer creates a gRPC service for the databases of storage. If tenant
+// is not empty, all calls are restricted to that tenant's databases.
+func NewServer(databases *db.DatabaseManager, storage *db.StorageManager, tenant string) *Server {
+	return &Server{databases: databases, storage: storage, tenant: tenant}
+}
+
+// SetRequireTenant makes the server reject calls without tenant metadata even
+// while no tenant has databases
+func (s *Server) SetRequireTenant(require bool) {
+	s.requireTenant = require
+}
+
+// Serve listens on addr and serves the service until ctx ends
+func (s *Server) Serve(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for gRPC: %w", err)
+	}
+
+	grpcServer := grpc.NewServer()
+	cachydbpb.RegisterCachyDBServer(grpcServer, s)
+
+	go func() {
+		<-ctx.Done()
+		grpcServer.GracefulStop()
+	}()
+
+	log.Printf("CachyDB gRPC server listening on %s\n", listener.Addr())
+	if err := grpcServer.Serve(listener); err != nil {
+		return fmt.Errorf("gRPC server error: %w", err)
+	}
+	return nil
+}
+
+// grpcError converts an error to a status with the matching code
+func grpcError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := codes.InvalidArgument
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, db.ErrConflict):
+		code = codes.Aborted
+	case errors.Is(err, db.ErrDuplicateKey):
+		code = codes.AlreadyExists
+	case errors.Is(err, db.ErrReplica):
+		code = codes.FailedPrecondition
+	}
+	return status.Error(code, err.Error())
+}
+
+// scope returns the databases a call may use
+func (s *Server) scope(ctx context.Context) (*db.TenantScope, error) {
+	if s.tenant != "" {
+		return s.databases.Tenant(s.tenant), nil
+	}
+	var tenant string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(TenantMetadata); len(values) > 0 {
+			tenant = values[0]
+		}
+	}
+	if tenant == "" {
+		// Once tenants have databases, the unscoped view would expose them
+		if s.requireTenant || s.databases.HasTenants() {
+			return nil, status.Errorf(codes.Unauthenticated, "the %s metadata is required", TenantMetadata)
+		}
+		return s.databases.Tenant(""), nil
+	}
+	if err := db.ValidateTenantName(tenant); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return s.databases.Tenant(tenant), nil
+}
+
+func (s *Server) database(ctx context.Context, name string) (*db.Database, error) {
+	scope, err := s.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	database := scope.GetDatabase(name)
+	if database == nil {
+		return nil, status.Errorf(codes.NotFound, "database '%s' not found", name)
+	}
+	return database, nil
+}
+
+func (s *Server) collection(ctx context.Context, dbName, collName string) (*db.Database, *db.Collection, error) {
+	database, err := s.database(ctx, dbName)
+	if err != nil {
+		return nil, nil, err
+	}
+	coll, err := database.GetCollection(collName)
+	if err != nil {
+		return nil, nil, status.Error(codes.NotFound, err.Error())
+	}
+	return database, coll, nil
+}
+
+// writable fails on a read replica, whose data only changes through its leader
+func (s *Server) writable() error {
+	if s.storage.IsReplica() {
+		return status.Error(codes.FailedPrecondition, "this server is a read replica; write to the leader")
+	}
+	return nil
+}
+
+// toStruct converts a document to a Struct with its ID in "_id"
+func toStruct(doc *db.Document) (*structpb.Struct, error) {
+	fields := make(map[string]any, len(doc.Data)+1)
+	for k, v := range doc.Data {
+		fields[k] = v
+	}
+	fields["_id"] = doc.ID
+
+	result, err := structpb.NewStruct(fields)
+	if err == nil {
+		return result, nil
+	}
+	// Values of other Go types, e.g. from embedded users, go through JSON
+	raw, err := json.Marshal(fields)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode document '%s': %v", doc.ID, err)
+	}
+	fields = nil
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to encode document '%s': %v", doc.ID, err)
+	}
+	return structpb.NewStruct(fields)
+}
+
+// fromStruct converts a Struct to a document, taking its ID from "_id"
+func fromStruct(s *structpb.Struct) *db.Document {
+	doc := &db.Document{Data: s.AsMap()}
+	if id, ok := doc.Data["_id"].(string); ok {
+		doc.ID = id
+		delete(doc.Data, "_id")
+	}
+	return doc
+}
+
+// toFilter converts a filter message to a filter tree
+func toFilter(f *cachydbpb.Filter) *db.Filter {
+	if f == nil {
+		return nil
+	}
+	filter := &db.Filter{QueryFilter: db.QueryFilter{Field: f.Field, Operator: f.Operator}}
+	if f.Value != nil {
+		filter.Value = f.Value.AsInterface()
+	}
+	for _, child := range f.And {
+		filter.And = append(filter.And, *toFilter(child))
+	}
+	for _, child := range f.Or {
+		filter.Or = append(filter.Or, *toFilter(child))
+	}
+	return filter
+}
+
+func (s *Server) ListDatabases(ctx context.Context, req *cachydbpb.ListDatabasesRequest) (*cachydbpb.ListDatabasesResponse, error) {
+	scope, err := s.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := scope.ListDatabases()
+	slices.Sort(names)
+	return &cachydbpb.ListDatabasesResponse{Databases: names}, nil
+}
+
+func (s *Server) CreateDatabase(ctx context.Context, req *cachydbpb.CreateDatabaseRequest) (*cachydbpb.CreateDatabaseResponse, error) {
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+	scope, err := s.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	database, err := scope.CreateDatabase(req.Name)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if err := s.storage.LogCreateDatabase(database.Name); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to log create database: %v", err)
+	}
+	return &cachydbpb.CreateDatabaseResponse{}, nil
+}
+
+func (s *Server) DeleteDatabase(ctx context.Context, req *cachydbpb.DeleteDatabaseRequest) (*cachydbpb.DeleteDatabaseResponse, error) {
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+	scope, err := s.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !scope.DeleteDatabase(req.Name) {
+		return nil, status.Errorf(codes.NotFound, "database '%s' not found", req.Name)
+	}
+	qualifiedName := scope.QualifiedName(req.Name)
+	if err := s.storage.LogDeleteDatabase(qualifiedName); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to log delete database: %v", err)
+	}
+	if err := s.storage.DeleteDatabase(qualifiedName); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to delete database files: %v", err)
+	}
+	return &cachydbpb.DeleteDatabaseResponse{}, nil
+}
+
+func (s *Server) ListCollections(ctx context.Context, req *cachydbpb.ListCollectionsRequest) (*cachydbpb.ListCollectionsResponse, error) {
+	database, err := s.database(ctx, req.Database)
+	if err != nil {
+		return nil, err
+	}
+	names := database.ListCollections()
+	slices.Sort(names)
+	return &cachydbpb.ListCollectionsResponse{Collections: names}, nil
+}
+
+func (s *Server) CreateCollection(ctx context.Context, req *cachydbpb.CreateCollectionRequest) (*cachydbpb.CreateCollectionResponse, error) {
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+	database, err := s.database(ctx, req.Database)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := database.GetCollection(req.Name); err == nil {
+		return nil, status.Errorf(codes.AlreadyExists, "collection '%s' already exists", req.Name)
+	}
+	if err := database.CreateCollection(req.Name, nil); err != nil {
+		return nil, grpcError(err)
+	}
+	if err := s.storage.LogCreateCollection(database.Name, req.Name, nil); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to log create collection: %v", err)
+	}
+	return &cachydbpb.CreateCollectionResponse{}, nil
+}
+
+func (s *Server) InsertDocument(ctx context.Context, req *cachydbpb.InsertDocumentRequest) (*cachydbpb.InsertDocumentResponse, error) {
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+	database, coll, err := s.collection(ctx, req.Database, req.Collection)
+	if err != nil {
+		return nil, err
+	}
+	if req.Document == nil {
+		return nil, status.Error(codes.InvalidArgument, "document is required")
+	}
+
+	doc := fromStruct(req.Document)
+	if doc.ID != "" {
+		if _, err := coll.FindByID(doc.ID); err == nil {
+			return nil, status.Errorf(codes.AlreadyExists, "document with ID '%s' already exists", doc.ID)
+		}
+	}
+	if err := coll.Insert(doc); err != nil {
+		return nil, grpcError(err)
+	}
+	if err := s.storage.LogInsert(database.Name, coll.Name, doc); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to log insert: %v", err)
+	}
+
+	result, err := toStruct(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &cachydbpb.InsertDocumentResponse{Document: result}, nil
+}
+
+func (s *Server) GetDocument(ctx context.Context, req *cachydbpb.GetDocumentRequest) (*cachydbpb.GetDocumentResponse, error) {
+	_, coll, err := s.collection(ctx, req.Database, req.Collection)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := coll.FindByID(req.Id)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	result, err := toStruct(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &cachydbpb.GetDocumentResponse{Document: result}, nil
+}
+
+func (s *Server) UpdateDocument(ctx context.Context, req *cachydbpb.UpdateDocumentRequest) (*cachydbpb.UpdateDocumentResponse, error) {
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+	database, coll, err := s.collection(ctx, req.Database, req.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := req.Updates.AsMap()
+	if req.ExpectedRev != nil {
+		err = coll.UpdateIfRev(req.Id, *req.ExpectedRev, updates)
+	} else {
+		err = coll.Update(req.Id, updates)
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	doc, err := coll.FindByID(req.Id)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get updated document: %v", err)
+	}
+	if err := s.storage.LogUpdate(database.Name, coll.Name, doc); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to log update: %v", err)
+	}
+
+	result, err := toStruct(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &cachydbpb.UpdateDocumentResponse{Document: result}, nil
+}
+
+func (s *Server) DeleteDocument(ctx context.Context, req *cachydbpb.DeleteDocumentRequest) (*cachydbpb.DeleteDocumentResponse, error) {
+	if err := s.writable(); err != nil {
+		return nil, err
+	}
+	database, coll, err := s.collection(ctx, req.Database, req.Collection)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ExpectedRev != nil {
+		err = coll.DeleteIfRev(req.Id, *req.ExpectedRev)
+	} else {
+		err = coll.Delete(req.Id)
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if err := s.storage.LogDelete(database.Name, coll.Name, req.Id); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to log delete: %v", err)
+	}
+	return &cachydbpb.DeleteDocumentResponse{}, nil
+}
+
+// Find streams the matching documents one at a time, reading each when it
+// is sent rather than copying them all first (see db.Collection.FindIter)
+func (s *Server) Find(req *cachydbpb.FindRequest, stream grpc.ServerStreamingServer[cachydbpb.FindResponse]) error {
+	_, coll, err := s.collection(stream.Context(), req.Database, req.Collection)
+	if err != nil {
+		return err
+	}
+
+	query := &db.Query{Filter: toFilter(req.Filter), Limit: int(req.Limit), Skip: int(req.Skip)}
+	if query.Filter != nil {
+		if err := query.Filter.Validate(); err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid filter: %v", err)
+		}
+	}
+	for _, field := range req.Sort {
+		query.Sort = append(query.Sort, db.SortField{Field: field.Field, Descending: field.Desc})
+	}
+
+	docs, err := coll.FindIter(query)
+	if err != nil {
+		return grpcError(err)
+	}
+	for doc := range docs {
+		result, err := toStruct(doc)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(&cachydbpb.FindResponse{Document: result}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// systemCollectionTools are the tools that write to a system collection,
+// with the argument naming the document they write
+var systemCollectionTools = map[string]struct{ collection, idArgument string }{
+	"next_sequence": {db.SequencesCollection, "name"},
+	"acquire_lease": {db.LeasesCollection, "name"},
+	"renew_lease":   {db.LeasesCollection, "name"},
+	"release_lease": {db.LeasesCollection, "name"},
+}
+
+// auditTrail records the calls to tools that change data in the audit
+// log, if it is enabled. The tools that a read replica serves are not
+// recorded, as they do not change data.
+func (s *Server) auditTrail(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		call, ok := req.(*mcp.CallToolRequest)
+		if !ok || s.audit == nil || s.storage.IsReplica() || replicaTools[call.Params.Name] {
+			return next(ctx, method, req)
+		}
+
+		entry := s.auditEntry(call)
+		var result mcp.Result
+		var err error
+		if entry.Tenant == "" && entry.Database == db.AuditDatabase {
+			// The audit log is append-only: its database is never written by
+			// tools, and attempts are recorded as failed
+			result = errorResult(readOnlyError("database '%s' holds the audit log and cannot be changed", db.AuditDatabase))
+		} else {
+			result, err = next(ctx, method, req)
+		}
+
+		entry.Timestamp = time.Now()
+		entry.Outcome = db.AuditSuccess
+		if err != nil {
+			entry.Outcome, entry.Error = db.AuditFailure, err.Error()
+		} else if res, ok := result.(*mcp.CallToolResult); ok {
+			if res.IsError {
+				entry.Outcome, entry.Error = db.AuditFailure, toolErrorMessage(res)
+			} else if entry.DocumentID == "" {
+				// e.g. the ID generated for an inserted document
+				entry.DocumentID, _ = structuredOutput(res)["id"].(string)
+			}
+		}
+		if err := s.audit.Record(entry); err != nil {
+			log.Printf("Warning: failed to record %s call in the audit log: %v\n", entry.Tool, err)
+		}
+		return result, err
+	}
+}
+
+// auditEntry describes a tool call for the audit log
+func (s *Server) auditEntry(call *mcp.CallToolRequest) *db.AuditEntry {
+	var args map[string]interface{}
+	json.Unmarshal(call.Params.Arguments, &args) //nolint:errcheck
+	arg := func(name string) string {
+		value, _ := args[name].(string)
+		return value
+	}
+
+	entry := &db.AuditEntry{
+		Tenant:     s.databases.Name(),
+		Tool:       call.Params.Name,
+		Database:   arg("database"),
+		Collection: arg("collection"),
+		DocumentID: arg("id"),
+	}
+	if session := call.Session; session != nil {
+		entry.Session = session.ID()
+		if params := session.InitializeParams(); params != nil && params.ClientInfo != nil {
+			entry.Actor = strings.TrimSpace(params.ClientInfo.Name + " " + params.ClientInfo.Version)
+		}
+	}
+
+	switch entry.Tool {
+	case "create_database", "delete_database":
+		entry.Database = arg("name")
+	case "create_collection", "delete_collection":
+		entry.Collection = arg("name")
+	case "commit_transaction", "rollback_transaction":
+		s.transactionsMu.Lock()
+		if open, exists := s.transactions[arg("transaction_id")]; exists {
+			entry.Database = s.databases.DisplayName(open.database.Name)
+		}
+		s.transactionsMu.Unlock()
+	}
+	if target, ok := systemCollectionTools[entry.Tool]; ok {
+		entry.Collection, entry.DocumentID = target.collection, arg(target.idArgument)
+	}
+	if entry.Database == "" {
+		entry.Database = s.defaultDBName
+	}
+	return entry
+}
+
+// toolErrorMessage returns the message of a failed tool result
+func toolErrorMessage(res *mcp.CallToolResult) string {
+	if message, ok := structuredOutput(res)["error"].(string); ok {
+		return message
+	}
+	var messages []string
+	for _, content := range res.Content {
+		if text, ok := content.(*mcp.TextContent); ok {
+			messages = append(messages, text.Text)
+		}
+	}
+	return strings.Join(messages, "; ")
+}
+
+// structuredOutput decodes the structured content of a tool result, which
+// is nil if it has none
+func structuredOutput(res *mcp.CallToolResult) map[string]interface{} {
+	if res.StructuredContent == nil {
+		return nil
+	}
+	data, err := json.Marshal(res.StructuredContent)
+	if err != nil {
+		return nil
+	}
+	var output map[string]interface{}
+	json.Unmarshal(data, &output) //nolint:errcheck
+	return output
+}
+
+func (s *Server) queryAuditLogTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input QueryAuditLogInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	query := db.AuditQuery{
+		Tenant:     input.Tenant,
+		Actor:      input.Actor,
+		Tool:       input.Tool,
+		Database:   input.Database,
+		Collection: input.Collection,
+		DocumentID: input.DocumentID,
+		Outcome:    input.Outcome,
+		Limit:      input.Limit,
+	}
+	// Tenants only see their own operations
+	if tenant := s.databases.Name(); tenant != "" {
+		query.Tenant = tenant
+	}
+	for bound, value := range map[*time.Time]string{&query.Since: input.Since, &query.Until: input.Until} {
+		if value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid time '%s': use RFC 3339, e.g. 2026-01-02T15:04:05Z", value)
+		}
+		*bound = t
+	}
+	if query.Limit <= 0 {
+		query.Limit = 100
+	}
+
+	auditLog := s.audit
+	if auditLog == nil {
+		// Entries recorded while the audit log was enabled remain readable
+		auditLog = db.NewAuditLog(s.dbManager, s.storage, 0)
+	}
+	entries, err := auditLog.Query(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return nil, map[string]interface{}{
+		"entries": entries,
+		"count":   len(entries),
+		"enabled": s.audit != nil,
+	}, nil
+}
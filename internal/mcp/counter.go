@@ -0,0 +1,97 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *Server) incrementTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input IncrementInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	logOpts, err := durabilityOptions(input.Durability)
+	if err != nil {
+		return nil, nil, err
+	}
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	delta := 1.0
+	if input.Delta != nil {
+		delta = *input.Delta
+	}
+	value, err := coll.Increment(input.ID, input.Field, delta)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Logged whole, so replaying the WAL never applies the increment twice
+	doc, err := coll.FindByID(input.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get incremented document: %w", err)
+	}
+	if err := s.storage.LogUpsert(database.Name, coll.Name, doc, logOpts...); err != nil {
+		return nil, nil, fmt.Errorf("failed to log increment: %w", err)
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"value":   value,
+		"rev":     doc.Rev(),
+	}, nil
+}
+
+func (s *Server) nextSequenceTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input NextSequenceInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	logOpts, err := durabilityOptions(input.Durability)
+	if err != nil {
+		return nil, nil, err
+	}
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if _, err := database.GetCollection(db.SequencesCollection); err != nil {
+		if err := database.CreateCollection(db.SequencesCollection, nil); err == nil {
+			if err := s.storage.LogCreateCollection(database.Name, db.SequencesCollection, nil); err != nil {
+				return nil, nil, fmt.Errorf("failed to log create collection: %w", err)
+			}
+		}
+	}
+	value, err := database.NextSequence(input.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(db.SequencesCollection)
+	if err != nil {
+		return nil, nil, err
+	}
+	doc, err := coll.FindByID(input.Name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get sequence: %w", err)
+	}
+	if err := s.storage.LogUpsert(database.Name, coll.Name, doc, logOpts...); err != nil {
+		return nil, nil, fmt.Errorf("failed to log sequence: %w", err)
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"name":    input.Name,
+		"value":   value,
+	}, nil
+}
@@ -0,0 +1,149 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// Error codes of failed tool results, in the "code" field of their
+// structured content, so clients can tell failures apart without parsing
+// messages. Other failures have no code.
+const (
+	// ErrorCodeNotFound: a database, collection or document does not exist
+	ErrorCodeNotFound = "NOT_FOUND"
+	// ErrorCodeDuplicateKey: a document ID or unique index value is taken
+	ErrorCodeDuplicateKey = "DUPLICATE_KEY"
+	// ErrorCodeSchemaViolation: a document does not match its collection's schema
+	ErrorCodeSchemaViolation = "SCHEMA_VIOLATION"
+	// ErrorCodeConflict: a document changed since the expected revision
+	ErrorCodeConflict = "CONFLICT"
+	// ErrorCodeReadOnly: data cannot be changed here, e.g. on a read replica
+	ErrorCodeReadOnly = "READ_ONLY"
+)
+
+// codedError is an error of this package with its error code and details
+type codedError struct {
+	code    string
+	details map[string]interface{}
+	err     error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// databaseNotFound reports a database missing from the server's databases
+func databaseNotFound(name string) error {
+	return &codedError{
+		code:    ErrorCodeNotFound,
+		details: map[string]interface{}{"database": name},
+		err:     fmt.Errorf("database '%s' not found", name),
+	}
+}
+
+// readOnlyError reports a tool call refused because the data cannot change
+func readOnlyError(format string, args ...any) error {
+	return &codedError{code: ErrorCodeReadOnly, err: fmt.Errorf(format, args...)}
+}
+
+// addTool registers a tool like mcp.AddTool, turning the errors of its
+// handler into results with the error code (see toolError)
+func addTool[In any](server *mcp.Server, tool *mcp.Tool, handler mcp.ToolHandlerFor[In, map[string]interface{}]) {
+	mcp.AddTool(server, tool, func(ctx context.Context, req *mcp.CallToolRequest, input In) (*mcp.CallToolResult, map[string]interface{}, error) {
+		result, output, err := handler(ctx, req, input)
+		if err != nil {
+			return toolError(err)
+		}
+		return result, output, nil
+	})
+}
+
+// toolError returns a failed tool result whose structured content carries
+// the error's message, code and details
+func toolError(err error) (*mcp.CallToolResult, map[string]interface{}, error) {
+	return &mcp.CallToolResult{IsError: true}, errorOutput(err), nil
+}
+
+// errorResult is toolError for the middlewares, which build results whole
+func errorResult(err error) *mcp.CallToolResult {
+	return &mcp.CallToolResult{
+		IsError:           true,
+		Content:           []mcp.Content{&mcp.TextContent{Text: err.Error()}},
+		StructuredContent: errorOutput(err),
+	}
+}
+
+// errorOutput describes an error for tool output: its "error" message and,
+// if it has one, its "code" and the "details" of what it is about
+func errorOutput(err error) map[string]interface{} {
+	output := map[string]interface{}{
+		"success": false,
+		"error":   err.Error(),
+	}
+	code, details := errorCode(err)
+	if code != "" {
+		output["code"] = code
+	}
+	if len(details) > 0 {
+		output["details"] = details
+	}
+	return output
+}
+
+// errorCode returns the code of an error and its details
+func errorCode(err error) (string, map[string]interface{}) {
+	var (
+		coded     *codedError
+		notFound  *db.NotFoundError
+		noColl    *db.CollectionNotFoundError
+		duplicate *db.DuplicateKeyError
+		exists    *db.DocumentExistsError
+		violation *db.SchemaViolationError
+		conflict  *db.ConflictError
+	)
+	switch {
+	case errors.As(err, &coded):
+		return coded.code, coded.details
+	case errors.As(err, &notFound):
+		return ErrorCodeNotFound, map[string]interface{}{"document_id": notFound.DocumentID}
+	case errors.As(err, &noColl):
+		return ErrorCodeNotFound, map[string]interface{}{"collection": noColl.Collection}
+	case errors.As(err, &duplicate):
+		return ErrorCodeDuplicateKey, map[string]interface{}{
+			"index":       duplicate.Index,
+			"field":       duplicate.Field,
+			"value":       duplicate.Value,
+			"document_id": duplicate.DocumentID,
+		}
+	case errors.As(err, &exists):
+		return ErrorCodeDuplicateKey, map[string]interface{}{"field": "_id", "document_id": exists.DocumentID}
+	case errors.As(err, &violation):
+		details := map[string]interface{}{"field": violation.Field}
+		if violation.Expected != "" {
+			details["expected_type"] = string(violation.Expected)
+		}
+		if violation.Constraint != "" {
+			details["constraint"] = violation.Constraint
+			details["limit"] = violation.Limit
+			details["value"] = violation.Value
+		}
+		return ErrorCodeSchemaViolation, details
+	case errors.As(err, &conflict):
+		return ErrorCodeConflict, map[string]interface{}{
+			"document_id":  conflict.DocumentID,
+			"expected_rev": conflict.Expected,
+			"actual_rev":   conflict.Actual,
+		}
+	case errors.Is(err, db.ErrReplica), errors.Is(err, db.ErrReadOnly):
+		return ErrorCodeReadOnly, nil
+	}
+	return "", nil
+}
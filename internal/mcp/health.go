@@ -0,0 +1,107 @@
+package mcpserver
+
+import (
+	"context"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// role is "replica" for a read replica that was not promoted, and
+// "leader" otherwise
+func (s *Server) role() string {
+	if s.follower != nil && !s.follower.Status().Promoted {
+		return "replica"
+	}
+	return "leader"
+}
+
+func (s *Server) pingTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input PingInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	result := map[string]interface{}{
+		"success":        true,
+		"status":         "ok",
+		"version":        s.version,
+		"role":           s.role(),
+		"uptime_seconds": int64(time.Since(s.started).Seconds()),
+		"time":           time.Now().UTC().Format(time.RFC3339Nano),
+	}
+	if s.follower != nil {
+		result["connected"] = s.follower.Status().Connected
+	}
+	return nil, result, nil
+}
+
+func (s *Server) serverStatsTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input ServerStatsInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	names := s.databases.ListDatabases()
+	sort.Strings(names)
+	databases := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		database := s.databases.GetDatabase(name)
+		if database == nil {
+			continue // deleted since it was listed
+		}
+		loaded, total := database.LoadedCollections()
+		databases = append(databases, map[string]interface{}{
+			"name":               name,
+			"collections":        total,
+			"loaded_collections": loaded,
+		})
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	wal := s.storage.WAL.Stats()
+	dirty := s.storage.DirtyStats()
+
+	result := map[string]interface{}{
+		"success":        true,
+		"version":        s.version,
+		"go_version":     runtime.Version(),
+		"role":           s.role(),
+		"started_at":     s.started.UTC().Format(time.RFC3339Nano),
+		"uptime_seconds": int64(time.Since(s.started).Seconds()),
+		"databases":      databases,
+		"memory": map[string]interface{}{
+			"heap_alloc_bytes": mem.HeapAlloc,
+			"heap_sys_bytes":   mem.HeapSys,
+			"sys_bytes":        mem.Sys,
+			"gc_cycles":        mem.NumGC,
+			"goroutines":       runtime.NumGoroutine(),
+		},
+		"wal": map[string]interface{}{
+			"offset":               wal.Offset,
+			"checkpoint_offset":    wal.CheckpointOffset,
+			"checkpoint_lag":       wal.CheckpointLag,
+			"checkpoint_lag_bytes": wal.CheckpointLagBytes,
+			"checkpoint_age_ms":    wal.CheckpointAge.Milliseconds(),
+		},
+		"dirty": map[string]interface{}{
+			"entries":   dirty.Entries,
+			"documents": dirty.Documents,
+		},
+	}
+	if s.follower != nil {
+		status := s.follower.Status()
+		replication := map[string]interface{}{
+			"leader":    status.Leader,
+			"connected": status.Connected,
+			"offset":    status.Offset,
+		}
+		if !status.LastEntry.IsZero() {
+			replication["last_entry_age_ms"] = time.Since(status.LastEntry).Milliseconds()
+		}
+		result["replication"] = replication
+	}
+	return nil, result, nil
+}
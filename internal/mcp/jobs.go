@@ -0,0 +1,167 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// progressInterval is the least time between two progress notifications of
+// a tool call, so long operations do not flood the client
+const progressInterval = 250 * time.Millisecond
+
+// jobFunc is the work of a long tool call, run by runJob
+type jobFunc func(ctx context.Context, progress db.ProgressFunc) (map[string]interface{}, error)
+
+// runJob runs a long tool call. In the background it is started as a job
+// and the result has its ID, to poll with get_operation_status; otherwise
+// it runs to completion, sending progress notifications counting units if
+// the request asked for them.
+func (s *Server) runJob(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	background bool,
+	unit string,
+	run jobFunc,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if !background {
+		output, err := run(ctx, progressNotifier(ctx, req, unit))
+		return nil, output, err
+	}
+
+	job := s.jobs.Start(req.Params.Name, unit, func(ctx context.Context, progress db.ProgressFunc) (any, error) {
+		output, err := run(ctx, progress)
+		if err != nil {
+			return nil, err
+		}
+		return output, nil
+	})
+	return nil, map[string]interface{}{
+		"success": true,
+		"job_id":  job.ID,
+		"status":  db.JobRunning,
+		"message": fmt.Sprintf("Job %s started; poll get_operation_status for its progress and result", job.ID),
+	}, nil
+}
+
+// progressNotifier returns a ProgressFunc sending the progress of a tool
+// call to its client, or nil if the call has no progress token
+func progressNotifier(ctx context.Context, req *mcp.CallToolRequest, unit string) db.ProgressFunc {
+	token := req.Params.GetProgressToken()
+	if token == nil || req.Session == nil {
+		return nil
+	}
+
+	var last time.Time
+	return func(done, total int64) {
+		if done < total && time.Since(last) < progressInterval {
+			return
+		}
+		last = time.Now()
+		// A client that stopped listening must not fail the operation
+		_ = req.Session.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+			ProgressToken: token,
+			Progress:      float64(done),
+			Total:         float64(total),
+			Message:       fmt.Sprintf("%d of %d %s", done, total, unit),
+		})
+	}
+}
+
+// jobOutput describes a job for tool output, with the error code and
+// details of a job that failed
+func jobOutput(job *db.Job) map[string]interface{} {
+	info := job.Info()
+	output := map[string]interface{}{
+		"job_id":     info.ID,
+		"tool":       info.Kind,
+		"status":     info.Status,
+		"done":       info.Done,
+		"total":      info.Total,
+		"unit":       info.Unit,
+		"started_at": info.StartedAt.Format(time.RFC3339Nano),
+	}
+	if info.FinishedAt != nil {
+		output["finished_at"] = info.FinishedAt.Format(time.RFC3339Nano)
+	}
+	if info.Result != nil {
+		output["result"] = info.Result
+	}
+	if err := job.Err(); err != nil {
+		failure := errorOutput(err)
+		delete(failure, "success")
+		output["error"] = failure
+	}
+	return output
+}
+
+// getJob returns a job of the server
+func (s *Server) getJob(id string) (*db.Job, error) {
+	job, err := s.jobs.Get(id)
+	if errors.Is(err, db.ErrJobNotFound) {
+		return nil, &codedError{
+			code:    ErrorCodeNotFound,
+			details: map[string]interface{}{"job_id": id},
+			err:     fmt.Errorf("job '%s' not found", id),
+		}
+	}
+	return job, err
+}
+
+func (s *Server) getOperationStatusTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input JobInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	job, err := s.getJob(input.JobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	output := jobOutput(job)
+	output["success"] = true
+	return nil, output, nil
+}
+
+func (s *Server) listJobsTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input ListJobsInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	jobs := make([]map[string]interface{}, 0)
+	for _, job := range s.jobs.List() {
+		output := jobOutput(job)
+		if input.Status == "" || output["status"] == input.Status {
+			jobs = append(jobs, output)
+		}
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"jobs":    jobs,
+		"count":   len(jobs),
+	}, nil
+}
+
+func (s *Server) cancelJobTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input JobInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	job, err := s.getJob(input.JobID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.jobs.Cancel(job.ID); err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"job_id":  job.ID,
+		"message": fmt.Sprintf("Job %s is being canceled; it stops at the next document or collection", job.ID),
+	}, nil
+}
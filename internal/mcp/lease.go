@@ -0,0 +1,141 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *Server) acquireLeaseTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input AcquireLeaseInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	ttl, err := leaseTTL(input.TTLSeconds)
+	if err != nil {
+		return nil, nil, err
+	}
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+	coll, err := s.leasesCollection(database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	lease, err := database.AcquireLease(input.Name, input.Owner, ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.logLease(database, coll, lease.Name); err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"name":    lease.Name,
+		"token":   lease.Token,
+		"expires": lease.Expires.UTC().Format(time.RFC3339Nano),
+	}, nil
+}
+
+func (s *Server) renewLeaseTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input RenewLeaseInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	ttl, err := leaseTTL(input.TTLSeconds)
+	if err != nil {
+		return nil, nil, err
+	}
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expires, err := database.RenewLease(input.Name, input.Token, ttl)
+	if err != nil {
+		return nil, nil, err
+	}
+	coll, err := database.GetCollection(db.LeasesCollection)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.logLease(database, coll, input.Name); err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"name":    input.Name,
+		"expires": expires.UTC().Format(time.RFC3339Nano),
+	}, nil
+}
+
+func (s *Server) releaseLeaseTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input ReleaseLeaseInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := database.ReleaseLease(input.Name, input.Token); err != nil {
+		return nil, nil, err
+	}
+	if err := s.storage.LogDelete(database.Name, db.LeasesCollection, input.Name); err != nil {
+		return nil, nil, fmt.Errorf("failed to log lease release: %w", err)
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"name":    input.Name,
+	}, nil
+}
+
+// leasesCollection returns the lease collection of a database, creating
+// and logging it with its TTL index on first use
+func (s *Server) leasesCollection(database *db.Database) (*db.Collection, error) {
+	if coll, err := database.GetCollection(db.LeasesCollection); err == nil {
+		return coll, nil
+	}
+	if err := database.CreateCollection(db.LeasesCollection, nil); err == nil {
+		coll, err := database.GetCollection(db.LeasesCollection)
+		if err != nil {
+			return nil, err
+		}
+		if err := coll.CreateTTLIndex(db.LeaseExpiresField, db.LeaseExpiryGrace); err != nil {
+			return nil, err
+		}
+		if err := s.storage.LogLeasesCollection(database.Name); err != nil {
+			return nil, err
+		}
+	}
+	return database.GetCollection(db.LeasesCollection)
+}
+
+// logLease logs a lease document as it is stored now
+func (s *Server) logLease(database *db.Database, coll *db.Collection, name string) error {
+	doc, err := coll.FindByID(name)
+	if err != nil {
+		return fmt.Errorf("failed to get lease: %w", err)
+	}
+	if err := s.storage.LogUpsert(database.Name, coll.Name, doc); err != nil {
+		return fmt.Errorf("failed to log lease: %w", err)
+	}
+	return nil
+}
+
+// leaseTTL converts the TTL of a lease tool input
+func leaseTTL(seconds float64) (time.Duration, error) {
+	if seconds <= 0 {
+		return 0, fmt.Errorf("ttl_seconds must be positive")
+	}
+	return time.Duration(seconds * float64(time.Second)), nil
+}
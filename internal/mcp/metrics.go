@@ -0,0 +1,36 @@
+package mcpserver
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// metricsHandler serves the storage statistics in the Prometheus text
+// exposition format
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	wal := s.storage.WAL.Stats()
+	checkpoints := s.storage.CheckpointStats()
+	dirty := s.storage.DirtyStats()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	writeMetric(w, "cachydb_wal_appended_entries_total", "counter", "Entries appended to the WAL since the server started", float64(wal.Appended))
+	writeMetric(w, "cachydb_wal_append_rate", "gauge", "Entries appended to the WAL per second, over the last 10 seconds", wal.AppendRate)
+	writeMetric(w, "cachydb_wal_offset", "gauge", "Offset of the next WAL entry", float64(wal.Offset))
+	writeMetric(w, "cachydb_wal_segment_size_bytes", "gauge", "Size of the WAL file being appended to", float64(wal.SegmentSize))
+	writeMetric(w, "cachydb_wal_batch_length", "gauge", "Batched WAL entries not yet written to the file", float64(wal.BatchLength))
+	writeMetric(w, "cachydb_wal_checkpoint_offset", "gauge", "First WAL offset not yet saved to the data files", float64(wal.CheckpointOffset))
+	writeMetric(w, "cachydb_wal_checkpoint_lag_entries", "gauge", "WAL entries logged since the last checkpoint", float64(wal.CheckpointLag))
+	writeMetric(w, "cachydb_wal_checkpoint_lag_bytes", "gauge", "WAL bytes written since the last checkpoint", float64(wal.CheckpointLagBytes))
+	writeMetric(w, "cachydb_wal_checkpoint_age_seconds", "gauge", "Time since the last checkpoint", wal.CheckpointAge.Seconds())
+	writeMetric(w, "cachydb_checkpoints_total", "counter", "Checkpoints since the server started", float64(checkpoints.Count))
+	writeMetric(w, "cachydb_checkpoint_duration_seconds_total", "counter", "Time spent saving changes and checkpointing since the server started", checkpoints.TotalDuration.Seconds())
+	writeMetric(w, "cachydb_checkpoint_last_duration_seconds", "gauge", "Duration of the last checkpoint", checkpoints.LastDuration.Seconds())
+	writeMetric(w, "cachydb_dirty_entries", "gauge", "Databases and collections with changes not yet saved to the data files", float64(dirty.Entries))
+	writeMetric(w, "cachydb_dirty_documents", "gauge", "Changed documents not yet saved to the data files", float64(dirty.Documents))
+}
+
+// writeMetric writes a single unlabeled metric with its help and type lines
+func writeMetric(w io.Writer, name, kind, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %g\n", name, help, name, kind, name, value)
+}
@@ -0,0 +1,166 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// databasePromptArgument is the optional database argument of the prompts
+var databasePromptArgument = &mcp.PromptArgument{
+	Name:        "database",
+	Description: "Database name (optional, defaults to configured database)",
+}
+
+// registerPrompts adds the prompts for common workflows, which fill in the
+// live schema and sample documents of the collections they are about
+func (s *Server) registerPrompts(server *mcp.Server) {
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "design_schema",
+		Title:       "Design a schema",
+		Description: "Design collections, schemas and indexes for something to store, next to the database's existing collections",
+		Arguments: []*mcp.PromptArgument{
+			{Name: "description", Description: "What the data is and how it will be used", Required: true},
+			databasePromptArgument,
+		},
+	}, s.designSchemaPrompt)
+
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "build_query",
+		Title:       "Build a query",
+		Description: "Build the find_documents or aggregate arguments that answer a question about a collection",
+		Arguments: []*mcp.PromptArgument{
+			{Name: "collection", Description: "Name of the collection", Required: true},
+			{Name: "goal", Description: "What the query should find, e.g. the 10 most recent orders over 100", Required: true},
+			databasePromptArgument,
+		},
+	}, s.buildQueryPrompt)
+
+	server.AddPrompt(&mcp.Prompt{
+		Name:        "summarize_collection",
+		Title:       "Summarize a collection",
+		Description: "Summarize what a collection holds, from its schema, indexes and sample documents",
+		Arguments: []*mcp.PromptArgument{
+			{Name: "collection", Description: "Name of the collection", Required: true},
+			databasePromptArgument,
+		},
+	}, s.summarizeCollectionPrompt)
+}
+
+func (s *Server) designSchemaPrompt(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	args := req.Params.Arguments
+	if args["description"] == "" {
+		return nil, fmt.Errorf("description is required")
+	}
+	database, err := s.getDatabase(args["database"])
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas []map[string]interface{}
+	for _, name := range database.ListCollections() {
+		if coll, err := database.GetCollection(name); err == nil {
+			schemas = append(schemas, s.schemaDescription(database, coll))
+		}
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Design how to store the following in the CachyDB database '%s':\n\n%s\n\n", s.databases.DisplayName(database.Name), args["description"])
+	if len(schemas) > 0 {
+		fmt.Fprintf(&text, "The database already has these collections, with their schemas and indexes:\n\n%s\n\n", promptJSON(schemas))
+	} else {
+		text.WriteString("The database has no collections yet.\n\n")
+	}
+	text.WriteString(`A collection's schema lists fields with a type (string, number, boolean, object, array or date) and whether they are required, e.g. {"fields": {"email": {"type": "string", "required": true}}}. Documents may have fields the schema does not list. Indexes are hash indexes for equality lookups or ordered indexes that also serve range filters and sorting, and may be unique.
+
+Propose:
+1. The collections to create with create_collection, each with its name and schema. Reuse or extend an existing collection where it fits.
+2. The indexes to create with create_index, for the lookups and sorts the data will need.
+3. An example document for each collection.
+Explain each choice briefly.`)
+
+	return promptResult("Design a schema", text.String()), nil
+}
+
+func (s *Server) buildQueryPrompt(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	args := req.Params.Arguments
+	if args["goal"] == "" {
+		return nil, fmt.Errorf("goal is required")
+	}
+	database, coll, err := s.promptCollection(args)
+	if err != nil {
+		return nil, err
+	}
+	sample, err := sampleDocuments(coll, resourceSampleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Build a CachyDB query on the collection '%s' of the database '%s' that finds: %s\n\n", coll.Name, s.databases.DisplayName(database.Name), args["goal"])
+	fmt.Fprintf(&text, "Schema and indexes:\n\n%s\n\nSample documents:\n\n%s\n\n", promptJSON(s.schemaDescription(database, coll)), promptJSON(sample))
+	text.WriteString(`The query argument of find_documents has:
+- filters: conditions all documents must match, each {"field", "operator", "value"}. Operators: eq, ne, gt, gte, lt, lte, in, nin, regex, prefix, suffix, contains. Nested fields are named by dotted paths, e.g. address.city.
+- filter: a nested condition combining filters and groups with {"and": [...]} or {"or": [...]}.
+- sort: [{"field", "desc"}], limit and skip.
+Filters and sorts on indexed fields are fastest. If the goal asks for counts, sums, averages or other statistics, use the aggregate tool instead, with filters, group_by and accumulators.
+
+Reply with the tool to call and its arguments as JSON, then explain the query in one or two sentences.`)
+
+	return promptResult("Build a query", text.String()), nil
+}
+
+func (s *Server) summarizeCollectionPrompt(ctx context.Context, req *mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+	database, coll, err := s.promptCollection(req.Params.Arguments)
+	if err != nil {
+		return nil, err
+	}
+	sample, err := sampleDocuments(coll, resourceSampleSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "Summarize the collection '%s' of the CachyDB database '%s'.\n\n", coll.Name, s.databases.DisplayName(database.Name))
+	fmt.Fprintf(&text, "Schema and indexes:\n\n%s\n\nSample documents:\n\n%s\n\n", promptJSON(s.schemaDescription(database, coll)), promptJSON(sample))
+	text.WriteString(`Describe what the documents represent, their fields and types, how consistent they look across the sample, and how the collection is indexed. Point out anything notable, such as fields missing from some documents or lookups that lack an index. Use find_documents or aggregate if the sample is not enough to tell.`)
+
+	return promptResult("Summarize a collection", text.String()), nil
+}
+
+// promptCollection returns the database and collection named by the
+// arguments of a prompt
+func (s *Server) promptCollection(args map[string]string) (*db.Database, *db.Collection, error) {
+	database, err := s.getDatabase(args["database"])
+	if err != nil {
+		return nil, nil, err
+	}
+	coll, err := database.GetCollection(args["collection"])
+	if err != nil {
+		return nil, nil, err
+	}
+	return database, coll, nil
+}
+
+// promptResult returns a prompt of one user message
+func promptResult(description, text string) *mcp.GetPromptResult {
+	return &mcp.GetPromptResult{
+		Description: description,
+		Messages: []*mcp.PromptMessage{
+			{Role: "user", Content: &mcp.TextContent{Text: text}},
+		},
+	}
+}
+
+// promptJSON formats a value for a prompt
+func promptJSON(v any) string {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
@@ -0,0 +1,186 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// MessagesLogger is the logger name of the notifications subscribe_topic sends
+const MessagesLogger = "cachydb.messages"
+
+// topicSubscription is a subscription started with subscribe_topic
+type topicSubscription struct {
+	session *mcp.ServerSession
+	cancel  func()
+}
+
+func (s *Server) publishTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input PublishInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if input.Persist {
+		if _, err := database.GetCollection(db.MessagesCollection); err != nil {
+			if err := database.CreateCollection(db.MessagesCollection, nil); err == nil {
+				if err := s.storage.LogCreateCollection(database.Name, db.MessagesCollection, nil); err != nil {
+					return nil, nil, fmt.Errorf("failed to log create collection: %w", err)
+				}
+			}
+		}
+	}
+	msg, err := database.Publish(input.Topic, input.Payload, input.Persist)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.storage.LogMessage(database, msg); err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success":   true,
+		"id":        msg.ID,
+		"topic":     msg.Topic,
+		"persisted": msg.Persisted,
+	}, nil
+}
+
+func (s *Server) subscribeTopicTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input SubscribeTopicInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+	messages, cancel, err := database.Subscribe(input.Topic)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	session := req.Session
+	id := uuid.New().String()
+	s.subscriptionsMu.Lock()
+	s.subscriptions[id] = &topicSubscription{session: session, cancel: cancel}
+	s.subscriptionsMu.Unlock()
+
+	// The subscription ends with the session
+	go func() {
+		session.Wait()
+		s.endSubscription(id)
+		cancel()
+	}()
+
+	go func() {
+		defer s.endSubscription(id)
+		for msg := range messages {
+			err := session.Log(context.Background(), &mcp.LoggingMessageParams{
+				Level:  "info",
+				Logger: MessagesLogger,
+				Data:   messageToJSON(id, msg),
+			})
+			if err != nil {
+				cancel()
+				return
+			}
+		}
+		if s.hasSubscription(id) {
+			log.Printf("Subscription %s to topic %s of %s dropped for falling behind\n", id, input.Topic, database.Name)
+		}
+	}()
+
+	return nil, map[string]interface{}{
+		"success":         true,
+		"subscription_id": id,
+		"message":         fmt.Sprintf("Subscribed to topic '%s'; messages arrive as log notifications from logger %s", input.Topic, MessagesLogger),
+	}, nil
+}
+
+func (s *Server) unsubscribeTopicTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input UnsubscribeTopicInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	s.subscriptionsMu.Lock()
+	sub, exists := s.subscriptions[input.SubscriptionID]
+	s.subscriptionsMu.Unlock()
+	// Sessions only see their own subscriptions
+	if !exists || sub.session != req.Session {
+		return nil, nil, fmt.Errorf("subscription '%s' not found", input.SubscriptionID)
+	}
+
+	s.endSubscription(input.SubscriptionID)
+	sub.cancel()
+	return nil, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Subscription %s stopped", input.SubscriptionID),
+	}, nil
+}
+
+func (s *Server) readMessagesTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input ReadMessagesInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+	limit := input.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+
+	messages, err := database.Messages(input.Topic, input.After, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	results := make([]map[string]interface{}, len(messages))
+	for i, msg := range messages {
+		results[i] = messageToJSON("", msg)
+	}
+	return nil, map[string]interface{}{
+		"messages": results,
+		"count":    len(results),
+	}, nil
+}
+
+// endSubscription forgets a subscription that stopped
+func (s *Server) endSubscription(id string) {
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+	delete(s.subscriptions, id)
+}
+
+// hasSubscription reports whether a subscription was not stopped
+func (s *Server) hasSubscription(id string) bool {
+	s.subscriptionsMu.Lock()
+	defer s.subscriptionsMu.Unlock()
+	_, exists := s.subscriptions[id]
+	return exists
+}
+
+// messageToJSON converts a message to the data of a notification, or to a
+// result of read_messages without a subscription ID
+func messageToJSON(subscriptionID string, msg *db.Message) map[string]interface{} {
+	data := map[string]interface{}{
+		"id":        msg.ID,
+		"topic":     msg.Topic,
+		"payload":   msg.Payload,
+		"timestamp": msg.Timestamp,
+	}
+	if subscriptionID != "" {
+		data["subscription_id"] = subscriptionID
+	}
+	return data
+}
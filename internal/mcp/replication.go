@@ -0,0 +1,111 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// replicaTools are the tools a read replica serves; the others change data
+// and are refused until it is promoted
+var replicaTools = map[string]bool{
+	"list_databases":       true,
+	"use_database":         true,
+	"current_database":     true,
+	"list_collections":     true,
+	"collection_stats":     true,
+	"describe_collection":  true,
+	"compact":              true,
+	"reindex":              true,
+	"get_operation_status": true,
+	"list_jobs":            true,
+	"cancel_job":           true,
+	"backup":               true,
+	"checkpoint":           true,
+	"wal_stats":            true,
+	"replication_status":   true,
+	"ping":                 true,
+	"server_stats":         true,
+	"promote":              true,
+	"get_document":         true,
+	"find_documents":       true,
+	"sample_documents":     true,
+	"aggregate":            true,
+	"semantic_search":      true,
+	"text_search":          true,
+	"get_attachment":       true,
+	"watch_collection":     true,
+	"unwatch_collection":   true,
+	"subscribe_topic":      true,
+	"unsubscribe_topic":    true,
+	"read_messages":        true,
+	"list_webhooks":        true,
+	"query_audit_log":      true,
+}
+
+// replicaGuard refuses calls to tools that change data while the storage
+// follows a leader
+func (s *Server) replicaGuard(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		call, ok := req.(*mcp.CallToolRequest)
+		if !ok || !s.storage.IsReplica() || replicaTools[call.Params.Name] {
+			return next(ctx, method, req)
+		}
+
+		if s.follower != nil {
+			return errorResult(readOnlyError("%s is not available on a read replica; call it on the leader at %s", call.Params.Name, s.follower.Status().Leader)), nil
+		}
+		return errorResult(readOnlyError("%s is not available on a read replica; call it on the leader", call.Params.Name)), nil
+	}
+}
+
+func (s *Server) replicationStatusTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input ReplicationStatusInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if s.follower == nil {
+		return nil, map[string]interface{}{
+			"success": true,
+			"role":    "leader",
+			"offset":  s.storage.WAL.NextOffset(),
+		}, nil
+	}
+
+	status := s.follower.Status()
+	result := map[string]interface{}{
+		"success":   true,
+		"role":      s.role(),
+		"leader":    status.Leader,
+		"connected": status.Connected,
+		"offset":    status.Offset,
+		"applied":   status.Applied,
+		"promoted":  status.Promoted,
+	}
+	if !status.LastEntry.IsZero() {
+		result["last_entry"] = status.LastEntry
+	}
+	if status.LastError != "" {
+		result["last_error"] = status.LastError
+	}
+	return nil, result, nil
+}
+
+func (s *Server) promoteTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input PromoteInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if s.follower == nil {
+		return nil, nil, fmt.Errorf("this server is not a read replica")
+	}
+	if err := s.follower.Promote(); err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"offset":  s.storage.WAL.NextOffset(),
+	}, nil
+}
@@ -0,0 +1,250 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ResourceScheme is the URI scheme of the resources describing databases:
+// cachydb://{database} lists its collections, and
+// cachydb://{database}/{collection}/schema and .../sample describe one
+const ResourceScheme = "cachydb"
+
+// resourceSampleSize is how many documents a sample resource holds, and
+// describe_collection and sample_documents return by default
+const resourceSampleSize = 5
+
+// maxSampleSize is the most sample documents describe_collection and
+// sample_documents return
+const maxSampleSize = 100
+
+// resourceMIMEType is the MIME type of every resource
+const resourceMIMEType = "application/json"
+
+// registerResources adds the resource templates of the databases
+func (s *Server) registerResources(server *mcp.Server) {
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: ResourceScheme + "://{database}",
+		Name:        "database",
+		Description: "The collections of a database, with their document counts and the URIs of their schema and sample",
+		MIMEType:    resourceMIMEType,
+	}, s.readDatabaseResource)
+
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: ResourceScheme + "://{database}/{collection}/schema",
+		Name:        "collection schema",
+		Description: "The schema and indexes of a collection",
+		MIMEType:    resourceMIMEType,
+	}, s.readSchemaResource)
+
+	server.AddResourceTemplate(&mcp.ResourceTemplate{
+		URITemplate: ResourceScheme + "://{database}/{collection}/sample",
+		Name:        "collection sample",
+		Description: fmt.Sprintf("Up to %d documents of a collection, to show the shape of its data", resourceSampleSize),
+		MIMEType:    resourceMIMEType,
+	}, s.readSampleResource)
+}
+
+// resourceList adds the resources of every database and collection to the
+// resources listed by the server, which only knows the templates
+func (s *Server) resourceList(next mcp.MethodHandler) mcp.MethodHandler {
+	return func(ctx context.Context, method string, req mcp.Request) (mcp.Result, error) {
+		result, err := next(ctx, method, req)
+		list, ok := result.(*mcp.ListResourcesResult)
+		if err != nil || !ok || list.NextCursor != "" {
+			return result, err
+		}
+
+		for _, name := range s.databases.ListDatabases() {
+			database := s.databases.GetDatabase(name)
+			if database == nil {
+				continue
+			}
+			list.Resources = append(list.Resources, &mcp.Resource{
+				URI:      databaseURI(name),
+				Name:     name,
+				Title:    fmt.Sprintf("Database %s", name),
+				MIMEType: resourceMIMEType,
+			})
+			for _, collName := range database.ListCollections() {
+				list.Resources = append(list.Resources,
+					&mcp.Resource{
+						URI:      collectionURI(name, collName, "schema"),
+						Name:     name + "/" + collName + "/schema",
+						Title:    fmt.Sprintf("Schema of %s.%s", name, collName),
+						MIMEType: resourceMIMEType,
+					},
+					&mcp.Resource{
+						URI:      collectionURI(name, collName, "sample"),
+						Name:     name + "/" + collName + "/sample",
+						Title:    fmt.Sprintf("Sample documents of %s.%s", name, collName),
+						MIMEType: resourceMIMEType,
+					})
+			}
+		}
+		return list, nil
+	}
+}
+
+func (s *Server) readDatabaseResource(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	database, _, err := s.resourceTarget(req.Params.URI, 0)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResource(req.Params.URI, s.databaseDescription(database))
+}
+
+func (s *Server) readSchemaResource(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	database, coll, err := s.resourceTarget(req.Params.URI, 2)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResource(req.Params.URI, s.schemaDescription(database, coll))
+}
+
+func (s *Server) readSampleResource(ctx context.Context, req *mcp.ReadResourceRequest) (*mcp.ReadResourceResult, error) {
+	database, coll, err := s.resourceTarget(req.Params.URI, 2)
+	if err != nil {
+		return nil, err
+	}
+	documents, err := sampleDocuments(coll, resourceSampleSize)
+	if err != nil {
+		return nil, err
+	}
+	return jsonResource(req.Params.URI, map[string]interface{}{
+		"database":   s.databases.DisplayName(database.Name),
+		"collection": coll.Name,
+		"documents":  documents,
+	})
+}
+
+// databaseDescription describes the collections of a database
+func (s *Server) databaseDescription(database *db.Database) map[string]interface{} {
+	name := s.databases.DisplayName(database.Name)
+	collections := make([]map[string]interface{}, 0)
+	for _, collName := range database.ListCollections() {
+		coll, err := database.GetCollection(collName)
+		if err != nil {
+			continue
+		}
+		collections = append(collections, map[string]interface{}{
+			"name":       coll.Name,
+			"documents":  coll.Count(),
+			"ephemeral":  coll.Ephemeral,
+			"schema_uri": collectionURI(name, coll.Name, "schema"),
+			"sample_uri": collectionURI(name, coll.Name, "sample"),
+		})
+	}
+	return map[string]interface{}{
+		"database":    name,
+		"collections": collections,
+	}
+}
+
+// schemaDescription describes the schema and indexes of a collection
+func (s *Server) schemaDescription(database *db.Database, coll *db.Collection) map[string]interface{} {
+	return map[string]interface{}{
+		"database":   s.databases.DisplayName(database.Name),
+		"collection": coll.Name,
+		"schema":     coll.Schema,
+		"indexes":    coll.ListIndexes(),
+		"documents":  coll.Count(),
+	}
+}
+
+// sampleDocuments returns up to size documents of a collection
+func sampleDocuments(coll *db.Collection, size int) ([]interface{}, error) {
+	docs, err := coll.Find(&db.Query{Limit: size})
+	if err != nil {
+		return nil, err
+	}
+	documents := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		documents[i] = documentToJSON(doc)
+	}
+	return documents, nil
+}
+
+// indexDescriptions describes the indexes of a collection by name, with
+// the options that decide which filters and sorts they serve
+func indexDescriptions(coll *db.Collection) map[string]interface{} {
+	indexes := make(map[string]interface{})
+	for name := range coll.ListIndexes() {
+		idx, exists := coll.GetIndex(name)
+		if !exists {
+			continue
+		}
+		opts := idx.Options()
+		index := map[string]interface{}{
+			"field":  idx.FieldName,
+			"type":   opts.Type,
+			"unique": opts.Unique,
+		}
+		if opts.TTL > 0 {
+			index["ttl_seconds"] = int64(opts.TTL.Seconds())
+		}
+		indexes[name] = index
+	}
+	return indexes
+}
+
+// resourceTarget returns the database of a resource URI whose path has
+// segments segments and, if it has any, the collection named by the first
+func (s *Server) resourceTarget(uri string, segments int) (*db.Database, *db.Collection, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != ResourceScheme {
+		return nil, nil, mcp.ResourceNotFoundError(uri)
+	}
+	path := strings.Trim(u.EscapedPath(), "/")
+	parts := []string{}
+	if path != "" {
+		parts = strings.Split(path, "/")
+	}
+	if len(parts) != segments {
+		return nil, nil, mcp.ResourceNotFoundError(uri)
+	}
+
+	database := s.databases.GetDatabase(u.Host)
+	if database == nil {
+		return nil, nil, mcp.ResourceNotFoundError(uri)
+	}
+	if segments == 0 {
+		return database, nil, nil
+	}
+	collName, err := url.PathUnescape(parts[0])
+	if err != nil {
+		return nil, nil, mcp.ResourceNotFoundError(uri)
+	}
+	coll, err := database.GetCollection(collName)
+	if err != nil {
+		return nil, nil, mcp.ResourceNotFoundError(uri)
+	}
+	return database, coll, nil
+}
+
+// databaseURI returns the URI of the resource of a database
+func databaseURI(database string) string {
+	return ResourceScheme + "://" + url.PathEscape(database)
+}
+
+// collectionURI returns the URI of a resource of a collection
+func collectionURI(database, collection, resource string) string {
+	return databaseURI(database) + "/" + url.PathEscape(collection) + "/" + resource
+}
+
+// jsonResource returns the contents of a resource as indented JSON
+func jsonResource(uri string, v any) (*mcp.ReadResourceResult, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode resource: %w", err)
+	}
+	return &mcp.ReadResourceResult{
+		Contents: []*mcp.ResourceContents{{URI: uri, MIMEType: resourceMIMEType, Text: string(data)}},
+	}, nil
+}
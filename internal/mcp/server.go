@@ -1,32 +1,139 @@
 package mcpserver
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"github.com/hop-/cachydb/internal/replication"
+	"github.com/hop-/cachydb/internal/webhook"
 	"github.com/hop-/cachydb/pkg/db"
+	"github.com/hop-/cachydb/pkg/db/importer"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
 
+// TenantHeader is the HTTP header selecting the tenant for a Streamable HTTP request
+const TenantHeader = "X-CachyDB-Tenant"
+
 // Server represents the MCP server state
 type Server struct {
 	dbManager     *db.DatabaseManager
+	databases     *db.TenantScope
 	storage       *db.StorageManager
 	server        *mcp.Server
 	defaultDBName string
 	transport     string
 	httpAddr      string
+	embedder      db.Embedder
+	requireTenant bool
+	tenants       map[string]*Server // per-tenant servers for HTTP requests
+	tenantsMu     sync.Mutex
+
+	// follower replicates the leader of a read replica (see NewReplicaServer)
+	follower *replication.Follower
+	// replicationToken is required from replicas of this server; without
+	// it, the WAL and snapshots are not served (see SetReplicationToken)
+	replicationToken string
+	// webhooks sends the changes matching webhook rules
+	webhooks *webhook.Dispatcher
+	// audit records the calls to tools that change data, if enabled
+	audit *db.AuditLog
+	// allowedTools are the only tools registered, if set (see SetTools)
+	allowedTools map[string]bool
+
+	transactions   map[string]*openTransaction
+	transactionsMu sync.Mutex
+
+	watches   map[string]*collectionWatch
+	watchesMu sync.Mutex
+
+	subscriptions   map[string]*topicSubscription
+	subscriptionsMu sync.Mutex
+
+	// jobs are the tool calls run in the background
+	jobs *db.JobManager
+
+	// handlers are served by the HTTP transport next to /mcp (see Handle)
+	handlers []httpHandler
+
+	// version and started are reported by ping and server_stats
+	version string
+	started time.Time
+}
+
+// httpHandler is a handler added with Handle
+type httpHandler struct {
+	pattern string
+	name    string
+	handler http.Handler
+}
+
+// importLogBatchSize is how many documents import_documents and
+// clone_collection log to the WAL in one entry
+const importLogBatchSize = 1000
+
+// TransactionTimeout is how long a transaction may stay open before it is
+// discarded
+const TransactionTimeout = 30 * time.Minute
+
+// openTransaction is a transaction started with begin_transaction
+type openTransaction struct {
+	tx       *db.Transaction
+	database *db.Database
+}
+
+// NewServer creates a new MCP server.
+// If tenant is not empty, all tools are restricted to that tenant's databases.
+func NewServer(defaultDBName, rootDir, transport, httpAddr, tenant string, profile db.Profile) (*Server, error) {
+	return newServer(defaultDBName, rootDir, transport, httpAddr, tenant, profile, "", "")
+}
+
+// NewReplicaServer creates an MCP server for a read replica of the server
+// at leader, which requires token. Once started, it applies the changes the
+// leader streams and serves reads; tools that write fail until it is
+// promoted.
+func NewReplicaServer(defaultDBName, rootDir, transport, httpAddr, tenant string, profile db.Profile, leader, token string) (*Server, error) {
+	return newServer(defaultDBName, rootDir, transport, httpAddr, tenant, profile, leader, token)
 }
 
-// NewServer creates a new MCP server
-func NewServer(defaultDBName, rootDir, transport, httpAddr string) (*Server, error) {
-	storage, err := db.NewStorageManager(rootDir)
+func newServer(defaultDBName, rootDir, transport, httpAddr, tenant string, profile db.Profile, leader, token string) (*Server, error) {
+	if tenant != "" {
+		if err := db.ValidateTenantName(tenant); err != nil {
+			return nil, err
+		}
+	}
+
+	// A new replica starts from a snapshot of the leader
+	if leader != "" {
+		empty, err := db.IsEmptyRoot(rootDir, profile.WAL)
+		if err != nil {
+			return nil, err
+		}
+		if empty {
+			info, err := replication.Bootstrap(leader, token, rootDir, profile.WAL)
+			if err != nil {
+				return nil, fmt.Errorf("failed to bootstrap the replica from %s: %w", leader, err)
+			}
+			log.Printf("Copied a snapshot of %s at WAL offset %d (%d files, %d bytes)\n", leader, info.WALOffset, info.Files, info.Bytes)
+		}
+	}
+
+	storage, err := db.NewStorageManagerWithWAL(rootDir, profile.WAL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create storage manager: %w", err)
 	}
+	storage.ApplyProfile(profile)
 
 	// Load all existing databases (this will also replay WAL)
 	dbManager, err := storage.LoadAllDatabases()
@@ -37,9 +144,33 @@ func NewServer(defaultDBName, rootDir, transport, httpAddr string) (*Server, err
 	// Start background storage syncer
 	storage.StartBackgroundSync(dbManager)
 
-	// Ensure default database exists
-	if dbManager.GetDatabase(defaultDBName) == nil {
-		defaultDB := dbManager.CreateDatabase(defaultDBName)
+	var follower *replication.Follower
+	if leader != "" {
+		follower = replication.NewFollower(leader, token, storage)
+	}
+
+	s, err := newScopedServer(dbManager, storage, defaultDBName, tenant, follower)
+	if err != nil {
+		return nil, err
+	}
+	s.transport = transport
+	s.httpAddr = httpAddr
+	s.tenants = make(map[string]*Server)
+	s.webhooks = webhook.NewDispatcher(dbManager, storage)
+
+	return s, nil
+}
+
+// newScopedServer creates an MCP server limited to one tenant's databases
+func newScopedServer(dbManager *db.DatabaseManager, storage *db.StorageManager, defaultDBName, tenant string, follower *replication.Follower) (*Server, error) {
+	databases := dbManager.Tenant(tenant)
+
+	// Ensure default database exists; a replica gets it from the leader
+	if databases.GetDatabase(defaultDBName) == nil && !storage.IsReplica() {
+		defaultDB, err := databases.CreateDatabase(defaultDBName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create default database: %w", err)
+		}
 		if err := storage.LogCreateDatabase(defaultDB.Name); err != nil {
 			return nil, fmt.Errorf("failed to log create database: %w", err)
 		}
@@ -47,10 +178,15 @@ func NewServer(defaultDBName, rootDir, transport, httpAddr string) (*Server, err
 
 	s := &Server{
 		dbManager:     dbManager,
+		databases:     databases,
 		storage:       storage,
 		defaultDBName: defaultDBName,
-		transport:     transport,
-		httpAddr:      httpAddr,
+		transactions:  make(map[string]*openTransaction),
+		watches:       make(map[string]*collectionWatch),
+		subscriptions: make(map[string]*topicSubscription),
+		jobs:          db.NewJobManager(),
+		follower:      follower,
+		started:       time.Now(),
 	}
 
 	// Create MCP server with implementation info
@@ -61,21 +197,161 @@ func NewServer(defaultDBName, rootDir, transport, httpAddr string) (*Server, err
 
 	// Register all tools
 	s.registerTools(mcpServer)
+	s.registerResources(mcpServer)
+	s.registerPrompts(mcpServer)
+	mcpServer.AddReceivingMiddleware(s.auditTrail)
+	mcpServer.AddReceivingMiddleware(s.replicaGuard)
+	mcpServer.AddReceivingMiddleware(s.resourceList)
 
 	s.server = mcpServer
 	return s, nil
 }
 
+// SetRequireTenant makes the HTTP transport reject requests without a tenant
+// header even while no tenant has databases
+func (s *Server) SetRequireTenant(require bool) {
+	s.requireTenant = require
+}
+
+// SetReplicationToken makes the HTTP transport serve the WAL and snapshots
+// to replicas that send token. Without a token they are not served.
+func (s *Server) SetReplicationToken(token string) {
+	s.replicationToken = token
+}
+
+// tenantServer returns the MCP server scoped to the given tenant, creating it on first use
+func (s *Server) tenantServer(tenant string) (*Server, error) {
+	s.tenantsMu.Lock()
+	defer s.tenantsMu.Unlock()
+
+	if ts, exists := s.tenants[tenant]; exists {
+		return ts, nil
+	}
+
+	if err := db.ValidateTenantName(tenant); err != nil {
+		return nil, err
+	}
+
+	ts, err := newScopedServer(s.dbManager, s.storage, s.defaultDBName, tenant, s.follower)
+	if err != nil {
+		return nil, err
+	}
+	ts.embedder = s.embedder
+	ts.audit = s.audit
+	ts.version = s.version
+	ts.started = s.started
+	if s.allowedTools != nil {
+		registered, err := ts.toolNames()
+		if err != nil {
+			return nil, err
+		}
+		ts.allowedTools = s.allowedTools
+		ts.removeDisallowedTools(registered)
+	}
+
+	s.tenants[tenant] = ts
+	return ts, nil
+}
+
+// serverForRequest picks the MCP server for an HTTP request based on its tenant header.
+// A server started with a fixed tenant ignores the header.
+func (s *Server) serverForRequest(r *http.Request) *mcp.Server {
+	tenant := r.Header.Get(TenantHeader)
+	if s.databases.Name() != "" {
+		return s.server
+	}
+	if tenant == "" {
+		// Once tenants have databases, the unscoped view would expose them
+		if s.requireTenant || s.dbManager.HasTenants() {
+			return nil
+		}
+		return s.server
+	}
+
+	ts, err := s.tenantServer(tenant)
+	if err != nil {
+		log.Printf("Rejecting request for tenant '%s': %v\n", tenant, err)
+		return nil
+	}
+	return ts.server
+}
+
+// Handle serves handler under pattern on the HTTP transport, next to the
+// MCP endpoint. name describes it in the startup log.
+func (s *Server) Handle(pattern, name string, handler http.Handler) {
+	s.handlers = append(s.handlers, httpHandler{pattern: pattern, name: name, handler: handler})
+}
+
+// DatabaseManager returns the databases the server serves
+func (s *Server) DatabaseManager() *db.DatabaseManager {
+	return s.dbManager
+}
+
+// StorageManager returns the storage of the server's databases
+func (s *Server) StorageManager() *db.StorageManager {
+	return s.storage
+}
+
+// SetWALArchiver archives the WAL files past retention instead of deleting them
+func (s *Server) SetWALArchiver(archiver db.WALArchiver) {
+	s.storage.WAL.Archiver = archiver
+}
+
+// SetEmbedder sets the embedding provider used by the semantic_search tool
+func (s *Server) SetEmbedder(embedder db.Embedder) {
+	s.embedder = embedder
+}
+
+// SetAuditLog records the calls to tools that change data in auditLog
+func (s *Server) SetAuditLog(auditLog *db.AuditLog) {
+	s.audit = auditLog
+}
+
+// SetVersion sets the version of the server reported by ping and server_stats
+func (s *Server) SetVersion(version string) {
+	s.version = version
+}
+
 // Start starts the MCP server using the configured transport.
 func (s *Server) Start(ctx context.Context) error {
+	if s.follower != nil {
+		s.follower.Start(ctx)
+	}
+	s.webhooks.Start(ctx)
+
 	switch s.transport {
 	case "http":
 		return s.startHTTP(ctx)
+	case "both":
+		return s.startBoth(ctx)
 	default:
 		return s.startStdio(ctx)
 	}
 }
 
+// startBoth serves MCP over stdio and HTTP at once, until stdin is closed
+// or either transport fails
+func (s *Server) startBoth(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	httpErr := make(chan error, 1)
+	go func() {
+		httpErr <- s.startHTTP(ctx)
+		cancel()
+	}()
+
+	err := s.startStdio(ctx)
+	cancel()
+	if herr := <-httpErr; herr != nil {
+		return herr
+	}
+	if err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
 // startStdio starts the MCP server using the stdio transport.
 func (s *Server) startStdio(ctx context.Context) error {
 	return s.server.Run(ctx, &mcp.StdioTransport{})
@@ -84,12 +360,18 @@ func (s *Server) startStdio(ctx context.Context) error {
 // startHTTP starts the MCP server using the Streamable HTTP transport (MCP spec 2025-03-26+).
 // It exposes an HTTP endpoint at /mcp that clients can connect to via SSE.
 func (s *Server) startHTTP(ctx context.Context) error {
-	handler := mcp.NewStreamableHTTPHandler(func(r *http.Request) *mcp.Server {
-		return s.server
-	}, nil)
+	handler := mcp.NewStreamableHTTPHandler(s.serverForRequest, nil)
 
 	mux := http.NewServeMux()
 	mux.Handle("/mcp", handler)
+	mux.HandleFunc("/metrics", s.metricsHandler)
+	if s.replicationToken != "" {
+		mux.Handle(replication.StreamPath, replication.Handler(s.storage, s.replicationToken))
+		mux.Handle(replication.SnapshotPath, replication.SnapshotHandler(s.storage, s.replicationToken))
+	}
+	for _, h := range s.handlers {
+		mux.Handle(h.pattern, h.handler)
+	}
 
 	httpServer := &http.Server{
 		Addr:    s.httpAddr,
@@ -109,6 +391,13 @@ func (s *Server) startHTTP(ctx context.Context) error {
 		addr = "localhost" + addr
 	}
 	log.Printf("CachyDB MCP server listening on http://%s/mcp (Streamable HTTP transport)\n", addr)
+	log.Printf("Prometheus metrics at http://%s/metrics\n", addr)
+	if s.replicationToken != "" {
+		log.Printf("Replication WAL stream at http://%s%s\n", addr, replication.StreamPath)
+	}
+	for _, h := range s.handlers {
+		log.Printf("%s at http://%s%s\n", h.name, addr, h.pattern)
+	}
 
 	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		return fmt.Errorf("HTTP server error: %w", err)
@@ -119,65 +408,298 @@ func (s *Server) startHTTP(ctx context.Context) error {
 // registerTools registers all MCP tools
 func (s *Server) registerTools(server *mcp.Server) {
 	// Database management tools
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "create_database",
 		Description: "Create a new database",
 	}, s.createDatabaseTool)
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "list_databases",
 		Description: "List all databases",
 	}, s.listDatabasesTool)
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "delete_database",
 		Description: "Delete a database",
 	}, s.deleteDatabaseTool)
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "use_database",
 		Description: "Switch default database for subsequent operations",
 	}, s.useDatabaseTool)
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "current_database",
 		Description: "Get the current default database name",
 	}, s.currentDatabaseTool)
 
 	// Collection management tools
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "create_collection",
 		Description: "Create a new collection with optional schema",
 	}, s.createCollectionTool)
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
+		Name:        "delete_collection",
+		Description: "Delete a collection with its documents, indexes and blobs",
+	}, s.deleteCollectionTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "truncate_collection",
+		Description: "Remove every document of a collection, keeping its schema, indexes and settings",
+	}, s.truncateCollectionTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "clone_collection",
+		Description: "Copy a collection, or the documents of it matching a filter, into a new collection with the same schema, settings and indexes",
+	}, s.cloneCollectionTool)
+
+	addTool(server, &mcp.Tool{
 		Name:        "list_collections",
 		Description: "List all collections in a database",
 	}, s.listCollectionsTool)
 
+	addTool(server, &mcp.Tool{
+		Name:        "collection_stats",
+		Description: "Show document count, indexes, and disk usage of a collection",
+	}, s.collectionStatsTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "describe_collection",
+		Description: "Describe the shape of a collection before querying it: its schema, indexes, settings, document count, and sample documents",
+	}, s.describeCollectionTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "compact",
+		Description: "Rewrite the files of a collection, or of every collection in a database, without deleted and superseded document versions",
+	}, s.compactTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "reindex",
+		Description: "Rebuild the indexes of a collection, or of every collection in a database, from their documents and save them",
+	}, s.reindexTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "import_documents",
+		Description: "Import documents into a collection from an NDJSON or CSV file on the server",
+	}, s.importDocumentsTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "get_operation_status",
+		Description: "Show the progress of a job, a tool call run in the background, and its result once it finished",
+	}, s.getOperationStatusTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "list_jobs",
+		Description: "List the jobs of the server, running and recently finished, newest first",
+	}, s.listJobsTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "cancel_job",
+		Description: "Stop a running job; the work it completed is kept",
+	}, s.cancelJobTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "backup",
+		Description: "Write a compressed archive of a consistent snapshot of a database to a file on the server, without pausing writes",
+	}, s.backupTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "checkpoint",
+		Description: "Save every changed collection now and move the WAL checkpoint past the entries logged so far, instead of waiting for the next sync",
+	}, s.checkpointTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "wal_stats",
+		Description: "Show WAL statistics: append rate, current file size, unwritten batch, and how far the checkpoint lags behind",
+	}, s.walStatsTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "ping",
+		Description: "Check that the server is up, with its version, role and uptime",
+	}, s.pingTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "server_stats",
+		Description: "Show the health of the server: version, uptime, loaded databases, memory usage, WAL lag behind the last checkpoint, and the backlog of changes waiting to be saved",
+	}, s.serverStatsTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "replication_status",
+		Description: "Show whether this server is a read replica, and how far it has applied its leader's WAL",
+	}, s.replicationStatusTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "promote",
+		Description: "Stop following the leader and take writes, making this read replica a leader",
+	}, s.promoteTool)
+
 	// Document management tools
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "insert_document",
 		Description: "Insert a document into a collection",
 	}, s.insertDocumentTool)
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
+		Name:        "get_document",
+		Description: "Get a document by ID",
+	}, s.getDocumentTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "insert_documents",
+		Description: "Insert several documents into a collection, reporting the ID or error of each",
+	}, s.insertDocumentsTool)
+
+	addTool(server, &mcp.Tool{
 		Name:        "find_documents",
 		Description: "Find documents in a collection",
 	}, s.findDocumentsTool)
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
+		Name:        "sample_documents",
+		Description: "Return documents of a collection picked at random, to inspect representative data of a large collection without scanning it",
+	}, s.sampleDocumentsTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "aggregate",
+		Description: "Group documents and compute count, sum, avg, min, max, variance, stddev, median, and percentiles",
+	}, s.aggregateTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "semantic_search",
+		Description: "Search documents by meaning using vector embeddings",
+	}, s.semanticSearchTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "text_search",
+		Description: "Full-text search over document fields, ranked by BM25 relevance",
+	}, s.textSearchTool)
+
+	addTool(server, &mcp.Tool{
 		Name:        "update_document",
-		Description: "Update a document by ID",
+		Description: "Update a document by ID with field updates or a JSON Patch",
 	}, s.updateDocumentTool)
 
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "delete_document",
 		Description: "Delete a document by ID",
 	}, s.deleteDocumentTool)
 
+	// Counter tools
+	addTool(server, &mcp.Tool{
+		Name:        "increment",
+		Description: "Atomically add to a numeric field of a document and return the new value, creating the document if needed",
+	}, s.incrementTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "next_sequence",
+		Description: "Advance a named sequence of the database and return its new value (1, 2, 3, ...), e.g. for auto-increment IDs",
+	}, s.nextSequenceTool)
+
+	// Lease tools
+	addTool(server, &mcp.Tool{
+		Name:        "acquire_lease",
+		Description: "Take an exclusive, expiring lease on a name so only one worker does the work it names; fails while another holder's lease is unexpired",
+	}, s.acquireLeaseTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "renew_lease",
+		Description: "Extend a lease you hold, using the token from acquire_lease; fails if it expired or was taken over",
+	}, s.renewLeaseTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "release_lease",
+		Description: "Give up a lease you hold so others can acquire it at once",
+	}, s.releaseLeaseTool)
+
+	// Transaction tools
+	addTool(server, &mcp.Tool{
+		Name:        "begin_transaction",
+		Description: "Start a transaction; pass its ID as transaction_id to insert_document, update_document and delete_document to stage writes",
+	}, s.beginTransactionTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "commit_transaction",
+		Description: "Apply all writes staged in a transaction atomically",
+	}, s.commitTransactionTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "rollback_transaction",
+		Description: "Discard all writes staged in a transaction",
+	}, s.rollbackTransactionTool)
+
+	// Attachment tools
+	addTool(server, &mcp.Tool{
+		Name:        "put_attachment",
+		Description: "Attach a file to a document, replacing any attachment with the same name",
+	}, s.putAttachmentTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "get_attachment",
+		Description: "Read a document attachment",
+	}, s.getAttachmentTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "delete_attachment",
+		Description: "Remove an attachment from a document",
+	}, s.deleteAttachmentTool)
+
+	// Change notification tools
+	addTool(server, &mcp.Tool{
+		Name:        "watch_collection",
+		Description: "Send the changes to the documents of a collection to this session as MCP log notifications (logger \"cachydb.changes\", level info; set the logging level to receive them) until unwatch_collection is called or the session ends",
+	}, s.watchCollectionTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "unwatch_collection",
+		Description: "Stop a watch started with watch_collection",
+	}, s.unwatchCollectionTool)
+
+	// Pub/sub tools
+	addTool(server, &mcp.Tool{
+		Name:        "publish",
+		Description: "Publish a message to a topic of the database, delivered to its current subscribers; set persist to also keep it for read_messages",
+	}, s.publishTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "subscribe_topic",
+		Description: "Send the messages published to a topic to this session as MCP log notifications (logger \"cachydb.messages\", level info; set the logging level to receive them) until unsubscribe_topic is called or the session ends",
+	}, s.subscribeTopicTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "unsubscribe_topic",
+		Description: "Stop a subscription started with subscribe_topic",
+	}, s.unsubscribeTopicTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "read_messages",
+		Description: "Read the persisted messages of a topic in the order they were published, e.g. those missed while not subscribed",
+	}, s.readMessagesTool)
+
+	// Audit tools
+	addTool(server, &mcp.Tool{
+		Name:        "query_audit_log",
+		Description: "List recorded calls to tools that change data, newest first: who made them, the tool, database, collection and document, and whether they succeeded. Calls are only recorded while the server runs with --audit-log",
+	}, s.queryAuditLogTool)
+
+	// Webhook tools
+	addTool(server, &mcp.Tool{
+		Name:        "create_webhook",
+		Description: "POST the changes to the documents of a collection to an HTTP endpoint, retrying until it responds with a 2xx status",
+	}, s.createWebhookTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "list_webhooks",
+		Description: "List the webhooks of a database with their pending and failed deliveries",
+	}, s.listWebhooksTool)
+
+	addTool(server, &mcp.Tool{
+		Name:        "delete_webhook",
+		Description: "Delete a webhook and drop its pending deliveries",
+	}, s.deleteWebhookTool)
+
 	// Index management tools
-	mcp.AddTool(server, &mcp.Tool{
+	addTool(server, &mcp.Tool{
 		Name:        "create_index",
 		Description: "Create an index on a collection field",
 	}, s.createIndexTool)
@@ -204,34 +726,194 @@ type CurrentDatabaseInput struct{}
 
 // Collection management inputs
 type CreateCollectionInput struct {
-	Database string                 `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
-	Name     string                 `json:"name" jsonschema:"Name of the collection"`
-	Schema   map[string]interface{} `json:"schema,omitempty" jsonschema:"Optional schema definition with fields"`
+	Database   string                 `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Name       string                 `json:"name" jsonschema:"Name of the collection"`
+	Schema     map[string]interface{} `json:"schema,omitempty" jsonschema:"Optional schema definition: fields maps each field name to {type, required, unique}, plus enum, min and max (numbers), min_length, max_length and pattern (strings) to constrain its values, schema ({fields}) for the fields of an object, and items (a field definition) for the elements of an array"`
+	IDStrategy string                 `json:"id_strategy,omitempty" jsonschema:"How IDs are generated for documents inserted without one: uuid (default), uuidv7, ulid, snowflake, or a custom registered strategy"`
+	MaxInline  int                    `json:"max_inline_size,omitempty" jsonschema:"Largest document size in bytes kept inline; bigger documents have their largest fields moved to blob files (optional)"`
+	BlobFields []string               `json:"blob_fields,omitempty" jsonschema:"Fields always stored in blob files (optional)"`
+	Collation  *db.Collation          `json:"collation,omitempty" jsonschema:"Default collation for sorting and comparing strings: {locale, case_insensitive, numeric} (optional)"`
+	// MemoryBudget is in bytes, 0 keeps every document in memory
+	Ephemeral    bool   `json:"ephemeral,omitempty" jsonschema:"Keep the collection only in memory: nothing is written to disk or the WAL, and it is gone after a restart (optional)"`
+	MemoryBudget int64  `json:"memory_budget,omitempty" jsonschema:"Approximate bytes of documents kept in memory; least recently used saved documents beyond it are read back from disk on access (optional)"`
+	Codec        string `json:"codec,omitempty" jsonschema:"Compression of stored documents: none, gzip, zstd or lz4 (optional, defaults to the server's storage profile)"`
+}
+
+type DeleteCollectionInput struct {
+	Database string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Name     string `json:"name" jsonschema:"Name of the collection to delete"`
+}
+
+type TruncateCollectionInput struct {
+	Database   string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string `json:"collection" jsonschema:"Name of the collection to empty"`
+}
+
+type CloneCollectionInput struct {
+	Database   string                 `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string                 `json:"collection" jsonschema:"Name of the collection to clone"`
+	Name       string                 `json:"name" jsonschema:"Name of the new collection"`
+	Filter     map[string]interface{} `json:"filter,omitempty" jsonschema:"Only clone the documents matching this filter, as in find_documents (optional)"`
 }
 
 type InsertDocumentInput struct {
 	Database   string                 `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
 	Collection string                 `json:"collection" jsonschema:"Name of the collection"`
 	Document   map[string]interface{} `json:"document" jsonschema:"Document data to insert"`
+	// TransactionID stages the write in a transaction instead of applying it
+	TransactionID string `json:"transaction_id,omitempty" jsonschema:"Stage the insert in this transaction (from begin_transaction)"`
+	Durability    string `json:"durability,omitempty" jsonschema:"When the write is on disk: sync (fsynced before returning), group (fsynced before returning, sharing fsyncs with concurrent writes) or async (written in the background; may be lost in a crash). Optional, defaults to the server's durability"`
+}
+
+type GetDocumentInput struct {
+	Database   string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string `json:"collection" jsonschema:"Collection name"`
+	ID         string `json:"id" jsonschema:"Document ID"`
+}
+
+type InsertDocumentsInput struct {
+	Database   string                   `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string                   `json:"collection" jsonschema:"Collection name"`
+	Documents  []map[string]interface{} `json:"documents" jsonschema:"Documents to insert; each may set its own _id"`
+	Durability string                   `json:"durability,omitempty" jsonschema:"When the write is on disk: sync (fsynced before returning), group (fsynced before returning, sharing fsyncs with concurrent writes) or async (written in the background; may be lost in a crash). Optional, defaults to the server's durability"`
 }
 
 type FindDocumentsInput struct {
-	Database   string                 `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
-	Collection string                 `json:"collection" jsonschema:"Name of the collection"`
-	Query      map[string]interface{} `json:"query,omitempty" jsonschema:"Query filters, limit, and skip"`
+	Database     string                 `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection   string                 `json:"collection" jsonschema:"Name of the collection"`
+	Query        map[string]interface{} `json:"query,omitempty" jsonschema:"Query filters, nested filter ({and: [...], or: [...]} whose items are filters or groups), sort ([{field, desc}]), collation ({locale, case_insensitive, numeric}), limit, and skip"`
+	ResolveBlobs bool                   `json:"resolve_blobs,omitempty" jsonschema:"Return the contents of fields stored in blob files instead of {$blob, $size} references"`
+}
+
+type SampleDocumentsInput struct {
+	Database     string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection   string `json:"collection" jsonschema:"Name of the collection"`
+	Size         int    `json:"size,omitempty" jsonschema:"Number of documents to return, up to 100 (optional, defaults to 5)"`
+	ResolveBlobs bool   `json:"resolve_blobs,omitempty" jsonschema:"Return the contents of fields stored in blob files instead of {$blob, $size} references"`
+}
+
+type AggregateInput struct {
+	Database     string                    `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection   string                    `json:"collection" jsonschema:"Name of the collection"`
+	Filters      []db.QueryFilter          `json:"filters,omitempty" jsonschema:"Filters selecting the documents to aggregate (optional)"`
+	GroupBy      string                    `json:"group_by,omitempty" jsonschema:"Field to group by (optional, defaults to a single group)"`
+	Accumulators map[string]db.Accumulator `json:"accumulators" jsonschema:"Output name -> {op, field, percentile}. Ops: count, sum, avg, min, max, variance, stddev, var_samp, stddev_samp, median, percentile, or shorthands like p95"`
+}
+
+type SemanticSearchInput struct {
+	Database   string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string `json:"collection" jsonschema:"Name of the collection"`
+	Query      string `json:"query" jsonschema:"Text to search for"`
+	Field      string `json:"field,omitempty" jsonschema:"Field holding document embeddings (optional, defaults to 'embedding')"`
+	Limit      int    `json:"limit,omitempty" jsonschema:"Maximum number of results (optional, defaults to 10)"`
+}
+
+type TextSearchInput struct {
+	Database   string   `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string   `json:"collection" jsonschema:"Name of the collection"`
+	Query      string   `json:"query" jsonschema:"Search terms"`
+	Fields     []string `json:"fields,omitempty" jsonschema:"Fields to search (optional, defaults to all string fields)"`
+	MinScore   float64  `json:"min_score,omitempty" jsonschema:"Minimum relevance score for a result to be returned (optional)"`
+	Limit      int      `json:"limit,omitempty" jsonschema:"Maximum number of results (optional)"`
 }
 
 type UpdateDocumentInput struct {
 	Database   string                 `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
 	Collection string                 `json:"collection" jsonschema:"Name of the collection"`
 	ID         string                 `json:"id" jsonschema:"Document ID"`
-	Updates    map[string]interface{} `json:"updates" jsonschema:"Fields to update"`
+	Updates    map[string]interface{} `json:"updates,omitempty" jsonschema:"Fields to update, or update operators such as {\"$inc\": {\"count\": 1}}"`
+	Patch      []interface{}          `json:"patch,omitempty" jsonschema:"JSON Patch (RFC 6902) operations to apply instead of updates"`
+	Mode       string                 `json:"mode,omitempty" jsonschema:"How updates are applied: 'set' replaces top-level fields (default), 'merge' deep-merges objects and deletes fields set to null"`
+	Upsert     bool                   `json:"upsert,omitempty" jsonschema:"Insert a document with this ID and the updates as its fields if it does not exist (set mode only)"`
+	Rev        *int64                 `json:"rev,omitempty" jsonschema:"Only update if the document is still at this revision (its _rev field); fails with a conflict otherwise (set mode only)"`
+	// TransactionID stages the write in a transaction instead of applying it
+	TransactionID string `json:"transaction_id,omitempty" jsonschema:"Stage the update in this transaction (from begin_transaction; set mode only)"`
+	Durability    string `json:"durability,omitempty" jsonschema:"When the write is on disk: sync (fsynced before returning), group (fsynced before returning, sharing fsyncs with concurrent writes) or async (written in the background; may be lost in a crash). Optional, defaults to the server's durability"`
 }
 
 type DeleteDocumentInput struct {
 	Database   string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
 	Collection string `json:"collection" jsonschema:"Name of the collection"`
 	ID         string `json:"id" jsonschema:"Document ID"`
+	Rev        *int64 `json:"rev,omitempty" jsonschema:"Only delete if the document is still at this revision (its _rev field); fails with a conflict otherwise"`
+	// TransactionID stages the write in a transaction instead of applying it
+	TransactionID string `json:"transaction_id,omitempty" jsonschema:"Stage the delete in this transaction (from begin_transaction)"`
+	Durability    string `json:"durability,omitempty" jsonschema:"When the write is on disk: sync (fsynced before returning), group (fsynced before returning, sharing fsyncs with concurrent writes) or async (written in the background; may be lost in a crash). Optional, defaults to the server's durability"`
+}
+
+// Counter inputs
+type IncrementInput struct {
+	Database   string   `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string   `json:"collection" jsonschema:"Name of the collection"`
+	ID         string   `json:"id" jsonschema:"Document ID; the document is created if it does not exist"`
+	Field      string   `json:"field" jsonschema:"Numeric field to increment, e.g. views or stats.views"`
+	Delta      *float64 `json:"delta,omitempty" jsonschema:"Amount to add, negative to decrement (optional, defaults to 1)"`
+	Durability string   `json:"durability,omitempty" jsonschema:"When the write is on disk: sync, group or async, as for update_document. Optional, defaults to the server's durability"`
+}
+
+type NextSequenceInput struct {
+	Database   string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Name       string `json:"name" jsonschema:"Sequence name, e.g. orders"`
+	Durability string `json:"durability,omitempty" jsonschema:"When the write is on disk: sync, group or async, as for update_document. Optional, defaults to the server's durability"`
+}
+
+// Lease inputs
+type AcquireLeaseInput struct {
+	Database   string  `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Name       string  `json:"name" jsonschema:"Lease name, e.g. the job it guards"`
+	TTLSeconds float64 `json:"ttl_seconds" jsonschema:"Seconds until the lease expires unless renewed"`
+	Owner      string  `json:"owner,omitempty" jsonschema:"Who holds the lease, shown to others that fail to acquire it (optional)"`
+}
+
+type RenewLeaseInput struct {
+	Database   string  `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Name       string  `json:"name" jsonschema:"Lease name"`
+	Token      string  `json:"token" jsonschema:"Token returned by acquire_lease"`
+	TTLSeconds float64 `json:"ttl_seconds" jsonschema:"Seconds from now until the lease expires"`
+}
+
+type ReleaseLeaseInput struct {
+	Database string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Name     string `json:"name" jsonschema:"Lease name"`
+	Token    string `json:"token" jsonschema:"Token returned by acquire_lease"`
+}
+
+// Transaction inputs
+type BeginTransactionInput struct {
+	Database string `json:"database,omitempty" jsonschema:"Database the transaction writes to (optional, defaults to configured database)"`
+}
+
+type TransactionInput struct {
+	TransactionID string `json:"transaction_id" jsonschema:"Transaction ID from begin_transaction"`
+}
+
+type CommitTransactionInput struct {
+	TransactionID string `json:"transaction_id" jsonschema:"Transaction ID from begin_transaction"`
+	Durability    string `json:"durability,omitempty" jsonschema:"When the write is on disk: sync (fsynced before returning), group (fsynced before returning, sharing fsyncs with concurrent writes) or async (written in the background; may be lost in a crash). Optional, defaults to the server's durability"`
+}
+
+type PutAttachmentInput struct {
+	Database   string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string `json:"collection" jsonschema:"Name of the collection"`
+	ID         string `json:"id" jsonschema:"Document ID"`
+	Name       string `json:"name" jsonschema:"Attachment name, e.g. a file name"`
+	Content    string `json:"content" jsonschema:"Attachment content"`
+	Encoding   string `json:"encoding,omitempty" jsonschema:"Content encoding: 'text' (default) or 'base64' for binary data"`
+}
+
+type GetAttachmentInput struct {
+	Database   string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string `json:"collection" jsonschema:"Name of the collection"`
+	ID         string `json:"id" jsonschema:"Document ID"`
+	Name       string `json:"name" jsonschema:"Attachment name"`
+	Encoding   string `json:"encoding,omitempty" jsonschema:"Content encoding of the result: 'text' (default) or 'base64' for binary data"`
+}
+
+type DeleteAttachmentInput struct {
+	Database   string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string `json:"collection" jsonschema:"Name of the collection"`
+	ID         string `json:"id" jsonschema:"Document ID"`
+	Name       string `json:"name" jsonschema:"Attachment name"`
 }
 
 type CreateIndexInput struct {
@@ -239,12 +921,139 @@ type CreateIndexInput struct {
 	Collection string `json:"collection" jsonschema:"Name of the collection"`
 	IndexName  string `json:"index_name" jsonschema:"Name for the index"`
 	FieldName  string `json:"field_name" jsonschema:"Field to index"`
+	IndexType  string `json:"index_type,omitempty" jsonschema:"Index type: hash (default) for equality lookups, or ordered to also serve range filters and sorting"`
+	Unique     bool   `json:"unique,omitempty" jsonschema:"Reject documents whose field value another document already has"`
+	TTLSeconds int64  `json:"ttl_seconds,omitempty" jsonschema:"Make this a TTL index: delete documents this many seconds after the time in the field (RFC 3339 string or Unix seconds)"`
+	Background bool   `json:"background,omitempty" jsonschema:"Build the index in the background and return a job_id to poll with get_operation_status"`
+}
+
+type WatchCollectionInput struct {
+	Database   string   `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string   `json:"collection" jsonschema:"Name of the collection"`
+	Operations []string `json:"operations,omitempty" jsonschema:"Operations to send: insert, update, upsert and delete (optional, defaults to all)"`
+}
+
+type UnwatchCollectionInput struct {
+	WatchID string `json:"watch_id" jsonschema:"ID returned by watch_collection"`
+}
+
+// Pub/sub inputs
+type PublishInput struct {
+	Database string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Topic    string `json:"topic" jsonschema:"Topic to publish to, e.g. jobs.done"`
+	Payload  any    `json:"payload" jsonschema:"Message payload, any JSON value"`
+	Persist  bool   `json:"persist,omitempty" jsonschema:"Also store the message in the _messages collection for read_messages (optional, defaults to false)"`
+}
+
+type SubscribeTopicInput struct {
+	Database string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Topic    string `json:"topic" jsonschema:"Topic to subscribe to; a trailing * matches every topic with that prefix, and * alone all topics"`
+}
+
+type UnsubscribeTopicInput struct {
+	SubscriptionID string `json:"subscription_id" jsonschema:"ID returned by subscribe_topic"`
+}
+
+type ReadMessagesInput struct {
+	Database string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Topic    string `json:"topic" jsonschema:"Topic to read; a trailing * matches every topic with that prefix, and * alone all topics"`
+	After    string `json:"after,omitempty" jsonschema:"Only return messages published after the message with this ID (optional)"`
+	Limit    int    `json:"limit,omitempty" jsonschema:"Maximum number of messages to return (optional, defaults to 100)"`
+}
+
+type QueryAuditLogInput struct {
+	Tenant     string `json:"tenant,omitempty" jsonschema:"Only entries of this tenant (optional; tenant-scoped sessions only see their own)"`
+	Actor      string `json:"actor,omitempty" jsonschema:"Only entries of this client, as recorded, e.g. 'my-agent 1.0.0' (optional)"`
+	Tool       string `json:"tool,omitempty" jsonschema:"Only calls to this tool, e.g. delete_document (optional)"`
+	Database   string `json:"database,omitempty" jsonschema:"Only entries for this database (optional)"`
+	Collection string `json:"collection,omitempty" jsonschema:"Only entries for this collection (optional)"`
+	DocumentID string `json:"document_id,omitempty" jsonschema:"Only entries for this document (optional)"`
+	Outcome    string `json:"outcome,omitempty" jsonschema:"Only successful (success) or failed (error) calls (optional)"`
+	Since      string `json:"since,omitempty" jsonschema:"Only entries at or after this RFC 3339 time (optional)"`
+	Until      string `json:"until,omitempty" jsonschema:"Only entries at or before this RFC 3339 time (optional)"`
+	Limit      int    `json:"limit,omitempty" jsonschema:"Maximum number of entries (optional, defaults to 100)"`
+}
+
+type CreateWebhookInput struct {
+	Database   string                 `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string                 `json:"collection" jsonschema:"Name of the collection"`
+	URL        string                 `json:"url" jsonschema:"HTTP or HTTPS endpoint the change events are POSTed to"`
+	Operations []string               `json:"operations,omitempty" jsonschema:"Operations to send: insert, update, upsert and delete (optional, defaults to all)"`
+	Filter     map[string]interface{} `json:"filter,omitempty" jsonschema:"Filter the changed document must match, as in find_documents (optional; deletes never match a filter)"`
+	Secret     string                 `json:"secret,omitempty" jsonschema:"Key to sign each request with an HMAC-SHA256 of its body, sent in the X-CachyDB-Signature header (optional)"`
+}
+
+type ListWebhooksInput struct {
+	Database string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+}
+
+type DeleteWebhookInput struct {
+	Database  string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	WebhookID string `json:"webhook_id" jsonschema:"ID returned by create_webhook"`
 }
 
 type ListCollectionsInput struct {
 	Database string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
 }
 
+type CollectionStatsInput struct {
+	Database   string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string `json:"collection" jsonschema:"Name of the collection"`
+}
+
+type DescribeCollectionInput struct {
+	Database   string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string `json:"collection" jsonschema:"Name of the collection"`
+	SampleSize int    `json:"sample_size,omitempty" jsonschema:"Number of sample documents to return, up to 100 (optional, defaults to 5)"`
+}
+
+type CompactInput struct {
+	Database   string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string `json:"collection,omitempty" jsonschema:"Name of the collection (optional, defaults to every collection of the database)"`
+	Background bool   `json:"background,omitempty" jsonschema:"Compact in the background and return a job_id to poll with get_operation_status"`
+}
+
+type ReindexInput struct {
+	Database   string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection string `json:"collection,omitempty" jsonschema:"Name of the collection (optional, defaults to every collection of the database)"`
+	Background bool   `json:"background,omitempty" jsonschema:"Reindex in the background and return a job_id to poll with get_operation_status"`
+}
+
+type ImportDocumentsInput struct {
+	Database    string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Collection  string `json:"collection" jsonschema:"Name of the collection, created if it does not exist"`
+	Path        string `json:"path" jsonschema:"Path of the file to import, on the server"`
+	Format      string `json:"format,omitempty" jsonschema:"File format: ndjson (default) or csv"`
+	IDColumn    string `json:"id_column,omitempty" jsonschema:"CSV column holding the document IDs (default _id)"`
+	StopOnError bool   `json:"stop_on_error,omitempty" jsonschema:"Stop at the first line that cannot be imported instead of skipping it"`
+	Background  bool   `json:"background,omitempty" jsonschema:"Import in the background and return a job_id to poll with get_operation_status"`
+}
+
+type JobInput struct {
+	JobID string `json:"job_id" jsonschema:"Job ID returned by a tool called with background set"`
+}
+
+type ListJobsInput struct {
+	Status string `json:"status,omitempty" jsonschema:"Only list jobs with this status: running, succeeded, failed or canceled"`
+}
+
+type BackupInput struct {
+	Database string `json:"database,omitempty" jsonschema:"Database name (optional, defaults to configured database)"`
+	Out      string `json:"out" jsonschema:"Path of the archive to write on the server, e.g. /backups/users.tar.gz"`
+}
+
+type CheckpointInput struct{}
+
+type WALStatsInput struct{}
+
+type ReplicationStatusInput struct{}
+
+type PingInput struct{}
+
+type ServerStatsInput struct{}
+
+type PromoteInput struct{}
+
 // Helper methods
 
 // getDatabase retrieves the database by name, using default if not specified
@@ -253,14 +1062,36 @@ func (s *Server) getDatabase(dbName string) (*db.Database, error) {
 		dbName = s.defaultDBName
 	}
 
-	database := s.dbManager.GetDatabase(dbName)
+	database := s.databases.GetDatabase(dbName)
 	if database == nil {
-		return nil, fmt.Errorf("database '%s' not found", dbName)
+		return nil, databaseNotFound(dbName)
 	}
 
 	return database, nil
 }
 
+// documentToJSON converts a document to a flat map for tool output
+func documentToJSON(doc *db.Document) map[string]interface{} {
+	docMap := make(map[string]interface{})
+	docMap["_id"] = doc.ID
+	for k, v := range doc.Data {
+		docMap[k] = v
+	}
+	return docMap
+}
+
+// searchResultsToJSON converts ranked search results for tool output
+func searchResultsToJSON(results []db.SearchResult) []interface{} {
+	resultsJSON := make([]interface{}, len(results))
+	for i, result := range results {
+		resultsJSON[i] = map[string]interface{}{
+			"score":    result.Score,
+			"document": documentToJSON(result.Document),
+		}
+	}
+	return resultsJSON
+}
+
 // Tool handlers
 
 // Database management handlers
@@ -269,10 +1100,13 @@ func (s *Server) createDatabaseTool(
 	req *mcp.CallToolRequest,
 	input CreateDatabaseInput,
 ) (*mcp.CallToolResult, map[string]interface{}, error) {
-	s.dbManager.CreateDatabase(input.Name)
+	database, err := s.databases.CreateDatabase(input.Name)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// Log to WAL (sync) - storage save happens async in background
-	if err := s.storage.LogCreateDatabase(input.Name); err != nil {
+	if err := s.storage.LogCreateDatabase(database.Name); err != nil {
 		return nil, nil, fmt.Errorf("failed to log create database: %w", err)
 	}
 
@@ -287,7 +1121,7 @@ func (s *Server) listDatabasesTool(
 	req *mcp.CallToolRequest,
 	input ListDatabasesInput,
 ) (*mcp.CallToolResult, map[string]interface{}, error) {
-	databases := s.dbManager.ListDatabases()
+	databases := s.databases.ListDatabases()
 
 	return nil, map[string]interface{}{
 		"success":   true,
@@ -300,17 +1134,18 @@ func (s *Server) deleteDatabaseTool(
 	req *mcp.CallToolRequest,
 	input DeleteDatabaseInput,
 ) (*mcp.CallToolResult, map[string]interface{}, error) {
-	if !s.dbManager.DeleteDatabase(input.Name) {
-		return nil, nil, fmt.Errorf("database '%s' not found", input.Name)
+	if !s.databases.DeleteDatabase(input.Name) {
+		return nil, nil, databaseNotFound(input.Name)
 	}
+	qualifiedName := s.databases.QualifiedName(input.Name)
 
 	// Log to WAL (sync)
-	if err := s.storage.LogDeleteDatabase(input.Name); err != nil {
+	if err := s.storage.LogDeleteDatabase(qualifiedName); err != nil {
 		return nil, nil, fmt.Errorf("failed to log delete database: %w", err)
 	}
 
 	// Delete database files immediately (this is a destructive operation)
-	if err := s.storage.DeleteDatabase(input.Name); err != nil {
+	if err := s.storage.DeleteDatabase(qualifiedName); err != nil {
 		return nil, nil, fmt.Errorf("failed to delete database files: %w", err)
 	}
 
@@ -326,9 +1161,9 @@ func (s *Server) useDatabaseTool(
 	input UseDatabaseInput,
 ) (*mcp.CallToolResult, map[string]interface{}, error) {
 	// Check if database exists
-	database := s.dbManager.GetDatabase(input.Name)
+	database := s.databases.GetDatabase(input.Name)
 	if database == nil {
-		return nil, nil, fmt.Errorf("database '%s' not found", input.Name)
+		return nil, nil, databaseNotFound(input.Name)
 	}
 
 	// Update default database
@@ -352,6 +1187,63 @@ func (s *Server) currentDatabaseTool(
 	}, nil
 }
 
+// schemaFromMap builds a schema from the schema argument of
+// create_collection
+func schemaFromMap(schemaMap map[string]interface{}) *db.Schema {
+	schema := &db.Schema{
+		Fields: make(map[string]db.Field),
+	}
+	if fields, ok := schemaMap["fields"].(map[string]interface{}); ok {
+		for fieldName, fieldData := range fields {
+			if fieldMap, ok := fieldData.(map[string]interface{}); ok {
+				schema.Fields[fieldName] = fieldFromMap(fieldMap)
+			}
+		}
+	}
+	return schema
+}
+
+// fieldFromMap builds a schema field, with its nested schema or element
+// type, from its definition in a schema argument
+func fieldFromMap(fieldMap map[string]interface{}) db.Field {
+	field := db.Field{}
+	if t, ok := fieldMap["type"].(string); ok {
+		field.Type = db.FieldType(t)
+	}
+	if r, ok := fieldMap["required"].(bool); ok {
+		field.Required = r
+	}
+	if u, ok := fieldMap["unique"].(bool); ok {
+		field.Unique = u
+	}
+	if enum, ok := fieldMap["enum"].([]interface{}); ok {
+		field.Enum = enum
+	}
+	if minimum, ok := fieldMap["min"].(float64); ok {
+		field.Min = &minimum
+	}
+	if maximum, ok := fieldMap["max"].(float64); ok {
+		field.Max = &maximum
+	}
+	if minLength, ok := fieldMap["min_length"].(float64); ok {
+		field.MinLength = int(minLength)
+	}
+	if maxLength, ok := fieldMap["max_length"].(float64); ok {
+		field.MaxLength = int(maxLength)
+	}
+	if pattern, ok := fieldMap["pattern"].(string); ok {
+		field.Pattern = pattern
+	}
+	if nested, ok := fieldMap["schema"].(map[string]interface{}); ok {
+		field.Schema = schemaFromMap(nested)
+	}
+	if items, ok := fieldMap["items"].(map[string]interface{}); ok {
+		element := fieldFromMap(items)
+		field.Items = &element
+	}
+	return field
+}
+
 // Collection management handlers
 func (s *Server) createCollectionTool(
 	ctx context.Context,
@@ -365,71 +1257,1327 @@ func (s *Server) createCollectionTool(
 
 	var schema *db.Schema
 	if input.Schema != nil {
-		schema = &db.Schema{
-			Fields: make(map[string]db.Field),
-		}
-		if fields, ok := input.Schema["fields"].(map[string]interface{}); ok {
-			for fieldName, fieldData := range fields {
-				if fieldMap, ok := fieldData.(map[string]interface{}); ok {
-					field := db.Field{}
-					if t, ok := fieldMap["type"].(string); ok {
-						field.Type = db.FieldType(t)
-					}
-					if r, ok := fieldMap["required"].(bool); ok {
-						field.Required = r
-					}
-					schema.Fields[fieldName] = field
-				}
-			}
+		schema = schemaFromMap(input.Schema)
+	}
+
+	if input.IDStrategy != "" {
+		if _, exists := db.GetIDGenerator(input.IDStrategy); !exists {
+			return nil, nil, fmt.Errorf("unknown ID strategy '%s' (available: %s)", input.IDStrategy, strings.Join(db.ListIDStrategies(), ", "))
 		}
 	}
 
-	if err := database.CreateCollection(input.Name, schema); err != nil {
+	if input.Collation != nil {
+		if err := input.Collation.Validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if input.MemoryBudget < 0 {
+		return nil, nil, fmt.Errorf("memory budget cannot be negative")
+	}
+	codec, err := db.ParseCodec(input.Codec)
+	if err != nil {
 		return nil, nil, err
 	}
 
-	// Log to WAL (sync) - storage save happens async in background
-	if err := s.storage.LogCreateCollection(database.Name, input.Name, schema); err != nil {
-		return nil, nil, fmt.Errorf("failed to log create collection: %w", err)
+	create := database.CreateCollection
+	if input.Ephemeral {
+		create = database.CreateEphemeralCollection
 	}
-
+	if err := create(input.Name, schema); err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(input.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if input.IDStrategy != "" {
+		if err := coll.SetIDStrategy(input.IDStrategy); err != nil {
+			return nil, nil, err
+		}
+	}
+	if input.MaxInline > 0 || len(input.BlobFields) > 0 {
+		s.storage.AttachBlobStore(database.Name, coll)
+		if err := coll.SetBlobPolicy(&db.BlobPolicy{MaxInlineSize: input.MaxInline, Fields: input.BlobFields}); err != nil {
+			return nil, nil, err
+		}
+	}
+	if input.Collation != nil {
+		if err := coll.SetCollation(input.Collation); err != nil {
+			return nil, nil, err
+		}
+	}
+	if input.MemoryBudget != 0 {
+		if err := coll.SetMemoryBudget(input.MemoryBudget); err != nil {
+			return nil, nil, err
+		}
+	}
+	if codec != "" {
+		if err := coll.SetCodec(codec); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	// Log to WAL (sync) - storage save happens async in background
+	if err := s.storage.LogCreateCollection(database.Name, input.Name, schema); err != nil {
+		return nil, nil, fmt.Errorf("failed to log create collection: %w", err)
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Collection '%s' created in database '%s'", input.Name, s.databases.DisplayName(database.Name)),
+	}, nil
+}
+
+func (s *Server) deleteCollectionTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input DeleteCollectionInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.DeleteCollection(input.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Ephemeral collections were never logged or saved
+	if !coll.Ephemeral {
+		// Log to WAL (sync)
+		if err := s.storage.LogDeleteCollection(database.Name, input.Name); err != nil {
+			return nil, nil, fmt.Errorf("failed to log delete collection: %w", err)
+		}
+
+		// Delete collection files immediately (this is a destructive operation)
+		if err := s.storage.DeleteCollection(database.Name, input.Name); err != nil {
+			return nil, nil, fmt.Errorf("failed to delete collection files: %w", err)
+		}
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Collection '%s' deleted from database '%s'", input.Name, s.databases.DisplayName(database.Name)),
+	}, nil
+}
+
+func (s *Server) truncateCollectionTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input TruncateCollectionInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	removed := coll.Truncate()
+
+	// Log to WAL (sync) - storage save happens async in background
+	if err := s.storage.LogTruncateCollection(database.Name, input.Collection); err != nil {
+		return nil, nil, fmt.Errorf("failed to log truncate collection: %w", err)
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"removed": removed,
+		"message": fmt.Sprintf("Removed %d document(s) from collection '%s'", removed, input.Collection),
+	}, nil
+}
+
+func (s *Server) cloneCollectionTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input CloneCollectionInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var filter *db.Filter
+	if input.Filter != nil {
+		encoded, err := json.Marshal(input.Filter)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		filter = &db.Filter{}
+		if err := json.Unmarshal(encoded, filter); err != nil {
+			return nil, nil, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+
+	copied, err := database.CloneCollection(input.Collection, input.Name, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	clone, err := database.GetCollection(input.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.logClone(database.Name, clone); err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"copied":  copied,
+		"message": fmt.Sprintf("Cloned %d document(s) of collection '%s' into '%s'", copied, input.Collection, input.Name),
+	}, nil
+}
+
+// logClone logs a cloned collection to the WAL: its creation, its indexes
+// and its documents, in batches
+func (s *Server) logClone(dbName string, clone *db.Collection) error {
+	if err := s.storage.LogCreateCollection(dbName, clone.Name, clone.Schema); err != nil {
+		return fmt.Errorf("failed to log create collection: %w", err)
+	}
+
+	names := make([]string, 0)
+	for name := range clone.ListIndexes() {
+		if name != "_id" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		idx, exists := clone.GetIndex(name)
+		if !exists {
+			continue
+		}
+		if err := s.storage.LogCreateIndex(dbName, clone.Name, name, idx.FieldName, idx.Options()); err != nil {
+			return fmt.Errorf("failed to log create index: %w", err)
+		}
+	}
+
+	docs, err := clone.FindIter(&db.Query{Sort: []db.SortField{{Field: "_id"}}})
+	if err != nil {
+		return err
+	}
+	var batch []db.TxResult
+	for doc := range docs {
+		batch = append(batch, db.TxResult{
+			Collection: clone.Name,
+			Op:         db.TxInsert,
+			ID:         doc.ID,
+			Document:   doc.Clone(),
+		})
+		if len(batch) == importLogBatchSize {
+			if err := s.storage.LogTransaction(dbName, batch); err != nil {
+				return fmt.Errorf("failed to log cloned documents: %w", err)
+			}
+			batch = nil
+		}
+	}
+	if len(batch) > 0 {
+		if err := s.storage.LogTransaction(dbName, batch); err != nil {
+			return fmt.Errorf("failed to log cloned documents: %w", err)
+		}
+	}
+	return nil
+}
+
+func (s *Server) listCollectionsTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input ListCollectionsInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	collections := database.ListCollections()
+
+	ephemeral := make([]string, 0)
+	for _, name := range collections {
+		if coll, err := database.GetCollection(name); err == nil && coll.Ephemeral {
+			ephemeral = append(ephemeral, name)
+		}
+	}
+
+	return nil, map[string]interface{}{
+		"success":     true,
+		"collections": collections,
+		"ephemeral":   ephemeral,
+		"database":    s.databases.DisplayName(database.Name),
+	}, nil
+}
+
+func (s *Server) collectionStatsTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input CollectionStatsInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := map[string]interface{}{
+		"success":    true,
+		"database":   s.databases.DisplayName(database.Name),
+		"collection": coll.Name,
+		"documents":  coll.Count(),
+		"indexes":    coll.ListIndexes(),
+	}
+	if coll.MemoryBudget > 0 {
+		result["memory_budget"] = coll.MemoryBudget
+		result["evicted_documents"] = coll.EvictedCount()
+	}
+	if coll.Codec != "" {
+		result["codec"] = coll.Codec
+	}
+
+	// The collection may not have been synced to disk yet
+	if size, err := s.storage.SizeOf(database.Name, coll.Name); err == nil {
+		result["disk"] = size
+		result["disk_total_bytes"] = size.Total()
+	} else {
+		result["disk"] = nil
+	}
+
+	return nil, result, nil
+}
+
+func (s *Server) describeCollectionTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input DescribeCollectionInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if input.SampleSize < 0 || input.SampleSize > maxSampleSize {
+		return nil, nil, fmt.Errorf("sample_size must be between 0 and %d", maxSampleSize)
+	}
+	size := input.SampleSize
+	if size == 0 {
+		size = resourceSampleSize
+	}
+
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	samples, err := sampleDocuments(coll, size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := s.schemaDescription(database, coll)
+	result["success"] = true
+	result["indexes"] = indexDescriptions(coll)
+	result["samples"] = samples
+	result["ephemeral"] = coll.Ephemeral
+	if coll.IDStrategy != "" {
+		result["id_strategy"] = coll.IDStrategy
+	}
+	if coll.Collation != nil {
+		result["collation"] = coll.Collation
+	}
+	return nil, result, nil
+}
+
+func (s *Server) backupTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input BackupInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if input.Out == "" {
+		return nil, nil, fmt.Errorf("out is required")
+	}
+
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	manifest, err := s.storage.BackupFile(input.Out, database.Name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success":     true,
+		"out":         input.Out,
+		"database":    s.databases.DisplayName(database.Name),
+		"collections": manifest.Databases[database.Name],
+		"wal_entries": manifest.WALEntries,
+		"wal_offset":  manifest.WALOffset,
+	}, nil
+}
+
+func (s *Server) checkpointTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input CheckpointInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	stats, err := s.storage.ForceCheckpoint()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success":           true,
+		"offset":            stats.Offset,
+		"duration_ms":       float64(stats.LastDuration.Microseconds()) / 1000,
+		"checkpoints":       stats.Count,
+		"total_duration_ms": float64(stats.TotalDuration.Microseconds()) / 1000,
+	}, nil
+}
+
+func (s *Server) walStatsTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input WALStatsInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	wal := s.storage.WAL.Stats()
+	checkpoints := s.storage.CheckpointStats()
+
+	return nil, map[string]interface{}{
+		"success":              true,
+		"appended":             wal.Appended,
+		"append_rate":          wal.AppendRate,
+		"offset":               wal.Offset,
+		"segment_size":         wal.SegmentSize,
+		"batch_length":         wal.BatchLength,
+		"checkpoint_offset":    wal.CheckpointOffset,
+		"checkpoint_lag":       wal.CheckpointLag,
+		"checkpoint_lag_bytes": wal.CheckpointLagBytes,
+		"checkpoint_age_ms":    wal.CheckpointAge.Milliseconds(),
+		"checkpoints":          checkpoints.Count,
+		"last_checkpoint_ms":   float64(checkpoints.LastDuration.Microseconds()) / 1000,
+		"last_trigger":         checkpoints.LastTrigger,
+	}, nil
+}
+
+func (s *Server) compactTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input CompactInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var coll *db.Collection
+	if input.Collection != "" {
+		if coll, err = database.GetCollection(input.Collection); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return s.runJob(ctx, req, input.Background, "collections", func(ctx context.Context, progress db.ProgressFunc) (map[string]interface{}, error) {
+		var results []*db.CompactionResult
+		if coll != nil {
+			progress.Report(0, 1)
+			result, err := s.storage.CompactCollection(database.Name, coll)
+			if err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+			progress.Report(1, 1)
+		} else {
+			var err error
+			if results, err = s.storage.CompactDatabaseWithProgress(ctx, database, progress); err != nil {
+				return nil, err
+			}
+		}
+
+		var reclaimed int64
+		for _, result := range results {
+			result.Database = s.databases.DisplayName(database.Name)
+			reclaimed += result.Reclaimed()
+		}
+
+		return map[string]interface{}{
+			"success":         true,
+			"collections":     results,
+			"reclaimed_bytes": reclaimed,
+		}, nil
+	})
+}
+
+func (s *Server) reindexTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input ReindexInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names := []string{input.Collection}
+	if input.Collection == "" {
+		names = database.ListCollections()
+		sort.Strings(names)
+	}
+	collections := make([]*db.Collection, len(names))
+	for i, name := range names {
+		if collections[i], err = database.GetCollection(name); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return s.runJob(ctx, req, input.Background, "documents", func(ctx context.Context, progress db.ProgressFunc) (map[string]interface{}, error) {
+		// Progress counts the documents of every collection
+		var total int64
+		for _, coll := range collections {
+			total += int64(coll.Count())
+		}
+
+		var indexed int64
+		reindexed := make([]map[string]interface{}, 0, len(collections))
+		for _, coll := range collections {
+			var count int64
+			err := coll.RebuildIndexesWithProgress(ctx, func(done, collTotal int64) {
+				count = collTotal
+				progress.Report(min(indexed+done, total), total)
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to rebuild indexes of collection '%s': %w", coll.Name, err)
+			}
+			indexed += count
+
+			if err := s.storage.SaveCollection(database.Name, coll); err != nil {
+				return nil, fmt.Errorf("failed to save collection '%s': %w", coll.Name, err)
+			}
+			reindexed = append(reindexed, map[string]interface{}{
+				"collection": coll.Name,
+				"indexes":    coll.ListIndexes(),
+			})
+		}
+
+		return map[string]interface{}{
+			"success":     true,
+			"collections": reindexed,
+		}, nil
+	})
+}
+
+func (s *Server) importDocumentsTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input ImportDocumentsInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	format := input.Format
+	if format == "" {
+		format = "ndjson"
+	}
+	if format != "ndjson" && format != "csv" {
+		return nil, nil, fmt.Errorf("unsupported import format '%s' (ndjson, csv)", format)
+	}
+
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := os.Open(input.Path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open input file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, fmt.Errorf("failed to read input file: %w", err)
+	}
+
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		if err := database.CreateCollection(input.Collection, nil); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+		if err := s.storage.LogCreateCollection(database.Name, input.Collection, nil); err != nil {
+			f.Close()
+			return nil, nil, fmt.Errorf("failed to log create collection: %w", err)
+		}
+		if coll, err = database.GetCollection(input.Collection); err != nil {
+			f.Close()
+			return nil, nil, err
+		}
+	}
+
+	return s.runJob(ctx, req, input.Background, "bytes", func(ctx context.Context, progress db.ProgressFunc) (map[string]interface{}, error) {
+		defer f.Close()
+		in := db.NewProgressReader(ctx, f, info.Size(), progress)
+
+		// Imported documents are logged to the WAL in batches, including
+		// those imported before a failure, which stay in the collection
+		var batch []db.TxResult
+		flush := func() error {
+			if len(batch) == 0 {
+				return nil
+			}
+			err := s.storage.LogTransaction(database.Name, batch)
+			batch = nil
+			if err != nil {
+				return fmt.Errorf("failed to log imported documents: %w", err)
+			}
+			return nil
+		}
+		inserted := func(doc *db.Document) error {
+			batch = append(batch, db.TxResult{
+				Collection: coll.Name,
+				Op:         db.TxInsert,
+				ID:         doc.ID,
+				Document:   doc.Clone(),
+			})
+			if len(batch) < importLogBatchSize {
+				return nil
+			}
+			return flush()
+		}
+
+		var result *importer.Result
+		var err error
+		if format == "csv" {
+			result, err = importer.ImportCSV(coll, in, &importer.CSVOptions{
+				IDColumn:    input.IDColumn,
+				StopOnError: input.StopOnError,
+				Inserted:    inserted,
+			})
+		} else {
+			result, err = importer.ImportNDJSON(coll, in, &importer.NDJSONOptions{
+				StopOnError: input.StopOnError,
+				Inserted:    inserted,
+			})
+		}
+		if flushErr := flush(); err == nil {
+			err = flushErr
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		output := map[string]interface{}{
+			"success":   true,
+			"documents": result.Documents,
+			"failed":    len(result.Errors),
+			"message":   fmt.Sprintf("Imported %d document(s) into '%s'", result.Documents, coll.Name),
+		}
+		if len(result.Errors) > 0 {
+			output["errors"] = result.Errors
+		}
+		return output, nil
+	})
+}
+
+// Document management handlers
+func (s *Server) insertDocumentTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input InsertDocumentInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if input.TransactionID != "" {
+		return s.stageInsert(input)
+	}
+
+	logOpts, err := durabilityOptions(input.Durability)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc := &db.Document{
+		Data: input.Document,
+	}
+	if id, ok := input.Document["_id"].(string); ok {
+		doc.ID = id
+		delete(input.Document, "_id")
+	}
+
+	if err := coll.Insert(doc); err != nil {
+		return nil, nil, err
+	}
+
+	// Log to WAL (sync) - storage save happens async in background
+	if err := s.storage.LogInsert(database.Name, input.Collection, doc, logOpts...); err != nil {
+		return nil, nil, fmt.Errorf("failed to log insert: %w", err)
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"id":      doc.ID,
+		"rev":     doc.Rev(),
+		"message": fmt.Sprintf("Document inserted with ID: %s", doc.ID),
+	}, nil
+}
+
+func (s *Server) getDocumentTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input GetDocumentInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := coll.FindByID(input.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success":  true,
+		"document": documentToJSON(doc),
+	}, nil
+}
+
+func (s *Server) insertDocumentsTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input InsertDocumentsInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	logOpts, err := durabilityOptions(input.Durability)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Each document is inserted on its own, so one failing does not stop the
+	// others; the ones inserted are logged together
+	var inserted []db.TxResult
+	results := make([]map[string]interface{}, len(input.Documents))
+	for i, data := range input.Documents {
+		doc := &db.Document{
+			Data: data,
+		}
+		if id, ok := data["_id"].(string); ok {
+			doc.ID = id
+			delete(data, "_id")
+		}
+
+		if err := coll.Insert(doc); err != nil {
+			results[i] = errorOutput(err)
+			continue
+		}
+
+		inserted = append(inserted, db.TxResult{
+			Collection: coll.Name,
+			Op:         db.TxInsert,
+			ID:         doc.ID,
+			Document:   doc.Clone(),
+		})
+		results[i] = map[string]interface{}{
+			"success": true,
+			"id":      doc.ID,
+			"rev":     doc.Rev(),
+		}
+	}
+
+	// Log to WAL (sync) as one entry - storage save happens async in background
+	if len(inserted) > 0 {
+		if err := s.storage.LogTransaction(database.Name, inserted, logOpts...); err != nil {
+			return nil, nil, fmt.Errorf("failed to log inserts: %w", err)
+		}
+	}
+
+	return nil, map[string]interface{}{
+		"success":  len(inserted) == len(input.Documents),
+		"results":  results,
+		"inserted": len(inserted),
+		"failed":   len(input.Documents) - len(inserted),
+		"message":  fmt.Sprintf("Inserted %d of %d documents", len(inserted), len(input.Documents)),
+	}, nil
+}
+
+func (s *Server) findDocumentsTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input FindDocumentsInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	query := &db.Query{}
+	if input.Query != nil {
+		if filters, ok := input.Query["filters"].([]interface{}); ok {
+			for _, f := range filters {
+				if filterMap, ok := f.(map[string]interface{}); ok {
+					filter := db.QueryFilter{}
+					if field, ok := filterMap["field"].(string); ok {
+						filter.Field = field
+					}
+					if op, ok := filterMap["operator"].(string); ok {
+						filter.Operator = op
+					}
+					if val, ok := filterMap["value"]; ok {
+						filter.Value = val
+					}
+					query.Filters = append(query.Filters, filter)
+				}
+			}
+		}
+		if limit, ok := input.Query["limit"].(float64); ok {
+			query.Limit = int(limit)
+		}
+		if skip, ok := input.Query["skip"].(float64); ok {
+			query.Skip = int(skip)
+		}
+		if sortFields, ok := input.Query["sort"].([]interface{}); ok {
+			for _, sf := range sortFields {
+				if sortMap, ok := sf.(map[string]interface{}); ok {
+					field := db.SortField{}
+					if name, ok := sortMap["field"].(string); ok {
+						field.Field = name
+					}
+					if desc, ok := sortMap["desc"].(bool); ok {
+						field.Descending = desc
+					}
+					query.Sort = append(query.Sort, field)
+				}
+			}
+		}
+		if rawFilter, ok := input.Query["filter"]; ok && rawFilter != nil {
+			encoded, err := json.Marshal(rawFilter)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid filter: %w", err)
+			}
+			var filter db.Filter
+			if err := json.Unmarshal(encoded, &filter); err != nil {
+				return nil, nil, fmt.Errorf("invalid filter: %w", err)
+			}
+			query.Filter = &filter
+		}
+		if collationMap, ok := input.Query["collation"].(map[string]interface{}); ok {
+			collation := &db.Collation{}
+			if locale, ok := collationMap["locale"].(string); ok {
+				collation.Locale = locale
+			}
+			if caseInsensitive, ok := collationMap["case_insensitive"].(bool); ok {
+				collation.CaseInsensitive = caseInsensitive
+			}
+			if numeric, ok := collationMap["numeric"].(bool); ok {
+				collation.Numeric = numeric
+			}
+			query.Collation = collation
+		}
+	}
+
+	docs, err := coll.Find(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if input.ResolveBlobs {
+		for i, doc := range docs {
+			if docs[i], err = coll.ResolveBlobs(doc); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	// Convert documents to JSON for output
+	docsJSON := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		docMap := make(map[string]interface{})
+		docMap["_id"] = doc.ID
+		for k, v := range doc.Data {
+			docMap[k] = v
+		}
+		docsJSON[i] = docMap
+	}
+
+	return nil, map[string]interface{}{
+		"success":   true,
+		"count":     len(docs),
+		"documents": docsJSON,
+	}, nil
+}
+
+func (s *Server) sampleDocumentsTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input SampleDocumentsInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if input.Size < 0 || input.Size > maxSampleSize {
+		return nil, nil, fmt.Errorf("size must be between 0 and %d", maxSampleSize)
+	}
+	size := input.Size
+	if size == 0 {
+		size = resourceSampleSize
+	}
+
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	docs, err := coll.Sample(size)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	docsJSON := make([]interface{}, len(docs))
+	for i, doc := range docs {
+		if input.ResolveBlobs {
+			if doc, err = coll.ResolveBlobs(doc); err != nil {
+				return nil, nil, err
+			}
+		}
+		docsJSON[i] = documentToJSON(doc)
+	}
+
+	return nil, map[string]interface{}{
+		"success":   true,
+		"count":     len(docs),
+		"total":     coll.Count(),
+		"documents": docsJSON,
+	}, nil
+}
+
+func (s *Server) aggregateTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input AggregateInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	groups, err := coll.Aggregate(&db.Aggregation{
+		Filters:      input.Filters,
+		GroupBy:      input.GroupBy,
+		Accumulators: input.Accumulators,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"groups":  groups,
+		"count":   len(groups),
+	}, nil
+}
+
+func (s *Server) semanticSearchTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input SemanticSearchInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if s.embedder == nil {
+		return nil, nil, fmt.Errorf("semantic search is not available: no embedding provider configured")
+	}
+
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	vector, err := s.embedder.Embed(ctx, input.Query)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+
+	limit := input.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	results, err := coll.VectorSearch(input.Field, vector, limit)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"count":   len(results),
+		"results": searchResultsToJSON(results),
+	}, nil
+}
+
+func (s *Server) textSearchTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input TextSearchInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, err := coll.TextSearch(&db.TextQuery{
+		Text:     input.Query,
+		Fields:   input.Fields,
+		MinScore: input.MinScore,
+		Limit:    input.Limit,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"count":   len(results),
+		"results": searchResultsToJSON(results),
+	}, nil
+}
+
+func (s *Server) updateDocumentTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input UpdateDocumentInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if input.TransactionID != "" {
+		return s.stageUpdate(input)
+	}
+
+	logOpts, err := durabilityOptions(input.Durability)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if input.Upsert {
+		return s.upsertDocument(database, coll, input, logOpts)
+	}
+	if input.Rev != nil && input.Mode != "" && input.Mode != "set" {
+		return nil, nil, fmt.Errorf("rev is only supported in set mode")
+	}
+
+	updates := input.Updates
+	if input.Patch != nil {
+		if input.Updates != nil {
+			return nil, nil, fmt.Errorf("specify either updates or patch, not both")
+		}
+		if input.Mode != "" && input.Mode != "set" {
+			return nil, nil, fmt.Errorf("patch cannot be combined with %s mode", input.Mode)
+		}
+		// A patch goes through Update like any other update operator
+		updates = map[string]interface{}{db.UpdateOpPatch: input.Patch}
+	}
+
+	switch input.Mode {
+	case "", "set":
+		if input.Rev != nil {
+			err = coll.UpdateIfRev(input.ID, *input.Rev, updates)
+		} else {
+			err = coll.Update(input.ID, updates)
+		}
+	case "merge":
+		if db.HasUpdateOperators(updates) {
+			return nil, nil, fmt.Errorf("update operators are only supported in set mode")
+		}
+		err = coll.MergePatch(input.ID, updates)
+	default:
+		err = fmt.Errorf("unknown update mode '%s'", input.Mode)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Get updated document for WAL
+	updatedDoc, err := coll.FindByID(input.ID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get updated document: %w", err)
+	}
+
+	// Log to WAL (sync) - storage save happens async in background
+	if err := s.storage.LogUpdate(database.Name, input.Collection, updatedDoc, logOpts...); err != nil {
+		return nil, nil, fmt.Errorf("failed to log update: %w", err)
+	}
+
 	return nil, map[string]interface{}{
 		"success": true,
-		"message": fmt.Sprintf("Collection '%s' created in database '%s'", input.Name, database.Name),
+		"rev":     updatedDoc.Rev(),
+		"message": fmt.Sprintf("Document %s updated", input.ID),
 	}, nil
 }
 
-func (s *Server) listCollectionsTool(
+// upsertDocument handles update_document with upsert set
+func (s *Server) upsertDocument(
+	database *db.Database,
+	coll *db.Collection,
+	input UpdateDocumentInput,
+	logOpts []db.LogOption,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if input.Patch != nil || (input.Mode != "" && input.Mode != "set") {
+		return nil, nil, fmt.Errorf("upsert only supports updates in set mode")
+	}
+
+	query := &db.Query{Filters: []db.QueryFilter{{Field: "_id", Operator: "eq", Value: input.ID}}}
+	doc, inserted, err := coll.Upsert(query, &db.Document{ID: input.ID, Data: input.Updates})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Log to WAL (sync) - storage save happens async in background
+	if err := s.storage.LogUpsert(database.Name, input.Collection, doc, logOpts...); err != nil {
+		return nil, nil, fmt.Errorf("failed to log upsert: %w", err)
+	}
+
+	message := fmt.Sprintf("Document %s updated", doc.ID)
+	if inserted {
+		message = fmt.Sprintf("Document %s inserted", doc.ID)
+	}
+	return nil, map[string]interface{}{
+		"success":  true,
+		"id":       doc.ID,
+		"rev":      doc.Rev(),
+		"inserted": inserted,
+		"message":  message,
+	}, nil
+}
+
+func (s *Server) deleteDocumentTool(
 	ctx context.Context,
 	req *mcp.CallToolRequest,
-	input ListCollectionsInput,
+	input DeleteDocumentInput,
 ) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if input.TransactionID != "" {
+		return s.stageDelete(input)
+	}
+
+	logOpts, err := durabilityOptions(input.Durability)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	database, err := s.getDatabase(input.Database)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	collections := database.ListCollections()
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if input.Rev != nil {
+		err = coll.DeleteIfRev(input.ID, *input.Rev)
+	} else {
+		err = coll.Delete(input.ID)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Log to WAL (sync) - storage save happens async in background
+	if err := s.storage.LogDelete(database.Name, input.Collection, input.ID, logOpts...); err != nil {
+		return nil, nil, fmt.Errorf("failed to log delete: %w", err)
+	}
 
 	return nil, map[string]interface{}{
-		"success":     true,
-		"collections": collections,
-		"database":    database.Name,
+		"success": true,
+		"message": fmt.Sprintf("Document %s deleted", input.ID),
 	}, nil
 }
 
-// Document management handlers
-func (s *Server) insertDocumentTool(
+// Transaction handlers
+func (s *Server) beginTransactionTool(
 	ctx context.Context,
 	req *mcp.CallToolRequest,
-	input InsertDocumentInput,
+	input BeginTransactionInput,
 ) (*mcp.CallToolResult, map[string]interface{}, error) {
 	database, err := s.getDatabase(input.Database)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	coll, err := database.GetCollection(input.Collection)
+	tx := db.NewTransaction(database.Name)
+
+	s.transactionsMu.Lock()
+	for id, open := range s.transactions {
+		if time.Since(open.tx.Started) > TransactionTimeout {
+			delete(s.transactions, id)
+		}
+	}
+	s.transactions[tx.ID] = &openTransaction{tx: tx, database: database}
+	s.transactionsMu.Unlock()
+
+	return nil, map[string]interface{}{
+		"success":        true,
+		"transaction_id": tx.ID,
+		"message":        fmt.Sprintf("Transaction %s started; it is discarded if not committed within %s", tx.ID, TransactionTimeout),
+	}, nil
+}
+
+func (s *Server) commitTransactionTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input CommitTransactionInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	logOpts, err := durabilityOptions(input.Durability)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	open, err := s.takeTransaction(input.TransactionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	results, err := open.tx.Commit()
+	if err != nil {
+		return nil, nil, fmt.Errorf("transaction rolled back: %w", err)
+	}
+
+	// Log to WAL (sync) - storage save happens async in background
+	if err := s.storage.LogTransaction(open.database.Name, results, logOpts...); err != nil {
+		return nil, nil, fmt.Errorf("failed to log transaction: %w", err)
+	}
+
+	operations := make([]map[string]interface{}, len(results))
+	for i, result := range results {
+		operations[i] = map[string]interface{}{
+			"collection": result.Collection,
+			"op":         result.Op,
+			"id":         result.ID,
+		}
+		if result.Document != nil {
+			operations[i]["rev"] = result.Document.Rev()
+		}
+	}
+
+	return nil, map[string]interface{}{
+		"success":    true,
+		"operations": operations,
+		"message":    fmt.Sprintf("Transaction %s committed %d operation(s)", input.TransactionID, len(results)),
+	}, nil
+}
+
+func (s *Server) rollbackTransactionTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input TransactionInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	open, err := s.takeTransaction(input.TransactionID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	discarded := open.tx.Len()
+	open.tx.Rollback()
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Transaction %s rolled back, %d staged operation(s) discarded", input.TransactionID, discarded),
+	}, nil
+}
+
+// getTransaction returns an open transaction and the collection a staged
+// write targets. dbName, if set, must be the transaction's database.
+func (s *Server) getTransaction(id, dbName, collName string) (*db.Transaction, *db.Collection, error) {
+	s.transactionsMu.Lock()
+	open, exists := s.transactions[id]
+	if exists && time.Since(open.tx.Started) > TransactionTimeout {
+		delete(s.transactions, id)
+		exists = false
+	}
+	s.transactionsMu.Unlock()
+	if !exists {
+		return nil, nil, fmt.Errorf("transaction '%s' not found or expired", id)
+	}
+
+	if dbName != "" {
+		if database := s.databases.GetDatabase(dbName); database != open.database {
+			return nil, nil, fmt.Errorf("transaction '%s' writes to another database", id)
+		}
+	}
+	coll, err := open.database.GetCollection(collName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return open.tx, coll, nil
+}
+
+// takeTransaction removes an open transaction so it can be finished
+func (s *Server) takeTransaction(id string) (*openTransaction, error) {
+	s.transactionsMu.Lock()
+	defer s.transactionsMu.Unlock()
+
+	open, exists := s.transactions[id]
+	if !exists || time.Since(open.tx.Started) > TransactionTimeout {
+		delete(s.transactions, id)
+		return nil, fmt.Errorf("transaction '%s' not found or expired", id)
+	}
+	delete(s.transactions, id)
+	return open, nil
+}
+
+func (s *Server) stageInsert(input InsertDocumentInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	tx, coll, err := s.getTransaction(input.TransactionID, input.Database, input.Collection)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -442,26 +2590,73 @@ func (s *Server) insertDocumentTool(
 		delete(input.Document, "_id")
 	}
 
-	if err := coll.Insert(doc); err != nil {
+	id, err := tx.Insert(coll, doc)
+	if err != nil {
 		return nil, nil, err
 	}
 
-	// Log to WAL (sync) - storage save happens async in background
-	if err := s.storage.LogInsert(database.Name, input.Collection, doc); err != nil {
-		return nil, nil, fmt.Errorf("failed to log insert: %w", err)
+	return nil, map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"staged":  true,
+		"message": fmt.Sprintf("Insert of document %s staged in transaction %s", id, tx.ID),
+	}, nil
+}
+
+func (s *Server) stageUpdate(input UpdateDocumentInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if input.Upsert || input.Rev != nil || (input.Mode != "" && input.Mode != "set") {
+		return nil, nil, fmt.Errorf("transactions only support updates in set mode, without upsert or rev")
+	}
+	updates := input.Updates
+	if input.Patch != nil {
+		if input.Updates != nil {
+			return nil, nil, fmt.Errorf("specify either updates or patch, not both")
+		}
+		updates = map[string]interface{}{db.UpdateOpPatch: input.Patch}
+	}
+
+	tx, coll, err := s.getTransaction(input.TransactionID, input.Database, input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Update(coll, input.ID, updates); err != nil {
+		return nil, nil, err
 	}
 
 	return nil, map[string]interface{}{
 		"success": true,
-		"id":      doc.ID,
-		"message": fmt.Sprintf("Document inserted with ID: %s", doc.ID),
+		"staged":  true,
+		"message": fmt.Sprintf("Update of document %s staged in transaction %s", input.ID, tx.ID),
 	}, nil
 }
 
-func (s *Server) findDocumentsTool(
+func (s *Server) stageDelete(input DeleteDocumentInput) (*mcp.CallToolResult, map[string]interface{}, error) {
+	if input.Rev != nil {
+		return nil, nil, fmt.Errorf("transactions do not support rev")
+	}
+
+	tx, coll, err := s.getTransaction(input.TransactionID, input.Database, input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := tx.Delete(coll, input.ID); err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"staged":  true,
+		"message": fmt.Sprintf("Delete of document %s staged in transaction %s", input.ID, tx.ID),
+	}, nil
+}
+
+// Attachment handlers
+func (s *Server) putAttachmentTool(
 	ctx context.Context,
 	req *mcp.CallToolRequest,
-	input FindDocumentsInput,
+	input PutAttachmentInput,
 ) (*mcp.CallToolResult, map[string]interface{}, error) {
 	database, err := s.getDatabase(input.Database)
 	if err != nil {
@@ -473,60 +2668,39 @@ func (s *Server) findDocumentsTool(
 		return nil, nil, err
 	}
 
-	query := &db.Query{}
-	if input.Query != nil {
-		if filters, ok := input.Query["filters"].([]interface{}); ok {
-			for _, f := range filters {
-				if filterMap, ok := f.(map[string]interface{}); ok {
-					filter := db.QueryFilter{}
-					if field, ok := filterMap["field"].(string); ok {
-						filter.Field = field
-					}
-					if op, ok := filterMap["operator"].(string); ok {
-						filter.Operator = op
-					}
-					if val, ok := filterMap["value"]; ok {
-						filter.Value = val
-					}
-					query.Filters = append(query.Filters, filter)
-				}
-			}
-		}
-		if limit, ok := input.Query["limit"].(float64); ok {
-			query.Limit = int(limit)
-		}
-		if skip, ok := input.Query["skip"].(float64); ok {
-			query.Skip = int(skip)
+	var content []byte
+	switch input.Encoding {
+	case "", "text":
+		content = []byte(input.Content)
+	case "base64":
+		if content, err = base64.StdEncoding.DecodeString(input.Content); err != nil {
+			return nil, nil, fmt.Errorf("invalid base64 content: %w", err)
 		}
+	default:
+		return nil, nil, fmt.Errorf("unknown encoding '%s'", input.Encoding)
 	}
 
-	docs, err := coll.Find(query)
+	s.storage.AttachBlobStore(database.Name, coll)
+	attachment, err := coll.PutAttachment(input.ID, input.Name, bytes.NewReader(content))
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// Convert documents to JSON for output
-	docsJSON := make([]interface{}, len(docs))
-	for i, doc := range docs {
-		docMap := make(map[string]interface{})
-		docMap["_id"] = doc.ID
-		for k, v := range doc.Data {
-			docMap[k] = v
-		}
-		docsJSON[i] = docMap
+	if err := s.logDocumentUpdate(database.Name, coll, input.ID); err != nil {
+		return nil, nil, err
 	}
 
 	return nil, map[string]interface{}{
-		"success":   true,
-		"count":     len(docs),
-		"documents": docsJSON,
+		"success":    true,
+		"attachment": attachment,
+		"message":    fmt.Sprintf("Attachment '%s' stored on document %s", input.Name, input.ID),
 	}, nil
 }
 
-func (s *Server) updateDocumentTool(
+func (s *Server) getAttachmentTool(
 	ctx context.Context,
 	req *mcp.CallToolRequest,
-	input UpdateDocumentInput,
+	input GetAttachmentInput,
 ) (*mcp.CallToolResult, map[string]interface{}, error) {
 	database, err := s.getDatabase(input.Database)
 	if err != nil {
@@ -538,31 +2712,40 @@ func (s *Server) updateDocumentTool(
 		return nil, nil, err
 	}
 
-	if err := coll.Update(input.ID, input.Updates); err != nil {
+	r, attachment, err := coll.GetAttachment(input.ID, input.Name)
+	if err != nil {
 		return nil, nil, err
 	}
+	defer r.Close()
 
-	// Get updated document for WAL
-	updatedDoc, err := coll.FindByID(input.ID)
+	content, err := io.ReadAll(r)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to get updated document: %w", err)
+		return nil, nil, fmt.Errorf("failed to read attachment: %w", err)
 	}
 
-	// Log to WAL (sync) - storage save happens async in background
-	if err := s.storage.LogUpdate(database.Name, input.Collection, updatedDoc); err != nil {
-		return nil, nil, fmt.Errorf("failed to log update: %w", err)
+	result := map[string]interface{}{
+		"success":    true,
+		"attachment": attachment,
+	}
+	switch input.Encoding {
+	case "", "text":
+		if !utf8.Valid(content) {
+			return nil, nil, fmt.Errorf("attachment '%s' is not valid text, request it with encoding 'base64'", input.Name)
+		}
+		result["content"] = string(content)
+	case "base64":
+		result["content"] = base64.StdEncoding.EncodeToString(content)
+	default:
+		return nil, nil, fmt.Errorf("unknown encoding '%s'", input.Encoding)
 	}
 
-	return nil, map[string]interface{}{
-		"success": true,
-		"message": fmt.Sprintf("Document %s updated", input.ID),
-	}, nil
+	return nil, result, nil
 }
 
-func (s *Server) deleteDocumentTool(
+func (s *Server) deleteAttachmentTool(
 	ctx context.Context,
 	req *mcp.CallToolRequest,
-	input DeleteDocumentInput,
+	input DeleteAttachmentInput,
 ) (*mcp.CallToolResult, map[string]interface{}, error) {
 	database, err := s.getDatabase(input.Database)
 	if err != nil {
@@ -574,21 +2757,47 @@ func (s *Server) deleteDocumentTool(
 		return nil, nil, err
 	}
 
-	if err := coll.Delete(input.ID); err != nil {
+	if err := coll.DeleteAttachment(input.ID, input.Name); err != nil {
 		return nil, nil, err
 	}
 
-	// Log to WAL (sync) - storage save happens async in background
-	if err := s.storage.LogDelete(database.Name, input.Collection, input.ID); err != nil {
-		return nil, nil, fmt.Errorf("failed to log delete: %w", err)
+	if err := s.logDocumentUpdate(database.Name, coll, input.ID); err != nil {
+		return nil, nil, err
 	}
 
 	return nil, map[string]interface{}{
 		"success": true,
-		"message": fmt.Sprintf("Document %s deleted", input.ID),
+		"message": fmt.Sprintf("Attachment '%s' removed from document %s", input.Name, input.ID),
 	}, nil
 }
 
+// durabilityOptions returns the WAL options for a write that requests a
+// durability mode; "" keeps the server's
+func durabilityOptions(durability string) ([]db.LogOption, error) {
+	if durability == "" {
+		return nil, nil
+	}
+	policy, err := db.ParseDurability(durability)
+	if err != nil {
+		return nil, err
+	}
+	return []db.LogOption{db.LogSync(policy)}, nil
+}
+
+// logDocumentUpdate writes the current state of a document to the WAL
+func (s *Server) logDocumentUpdate(dbName string, coll *db.Collection, id string) error {
+	doc, err := coll.FindByID(id)
+	if err != nil {
+		return fmt.Errorf("failed to get updated document: %w", err)
+	}
+
+	// Log to WAL (sync) - storage save happens async in background
+	if err := s.storage.LogUpdate(dbName, coll.Name, doc); err != nil {
+		return fmt.Errorf("failed to log update: %w", err)
+	}
+	return nil
+}
+
 func (s *Server) createIndexTool(
 	ctx context.Context,
 	req *mcp.CallToolRequest,
@@ -604,17 +2813,28 @@ func (s *Server) createIndexTool(
 		return nil, nil, err
 	}
 
-	if err := coll.CreateIndex(input.IndexName, input.FieldName); err != nil {
+	opts := db.IndexOptions{
+		Type:   db.IndexType(input.IndexType),
+		Unique: input.Unique,
+		TTL:    time.Duration(input.TTLSeconds) * time.Second,
+	}
+	if err := opts.Validate(); err != nil {
 		return nil, nil, err
 	}
 
-	// Log to WAL (sync) - storage save happens async in background
-	if err := s.storage.LogCreateIndex(database.Name, input.Collection, input.IndexName, input.FieldName); err != nil {
-		return nil, nil, fmt.Errorf("failed to log create index: %w", err)
-	}
+	return s.runJob(ctx, req, input.Background, "documents", func(ctx context.Context, progress db.ProgressFunc) (map[string]interface{}, error) {
+		if err := coll.CreateIndexWithProgress(ctx, input.IndexName, input.FieldName, opts, progress); err != nil {
+			return nil, err
+		}
 
-	return nil, map[string]interface{}{
-		"success": true,
-		"message": fmt.Sprintf("Index '%s' created on field '%s'", input.IndexName, input.FieldName),
-	}, nil
+		// Log to WAL (sync) - storage save happens async in background
+		if err := s.storage.LogCreateIndex(database.Name, input.Collection, input.IndexName, input.FieldName, opts); err != nil {
+			return nil, fmt.Errorf("failed to log create index: %w", err)
+		}
+
+		return map[string]interface{}{
+			"success": true,
+			"message": fmt.Sprintf("Index '%s' created on field '%s'", input.IndexName, input.FieldName),
+		}, nil
+	})
 }
@@ -0,0 +1,121 @@
+package mcpserver
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ToolSetRead names the tools that read data without changing it or the
+// server, for exposing databases to untrusted clients
+const ToolSetRead = "read"
+
+// ToolSetAll names every tool, the default
+const ToolSetAll = "all"
+
+// readTools are the tools of ToolSetRead. Unlike replicaTools, they leave
+// out the tools that manage the server, such as backup and promote.
+var readTools = []string{
+	"list_databases",
+	"use_database",
+	"current_database",
+	"list_collections",
+	"collection_stats",
+	"describe_collection",
+	"get_document",
+	"find_documents",
+	"sample_documents",
+	"aggregate",
+	"semantic_search",
+	"text_search",
+	"get_attachment",
+	"watch_collection",
+	"unwatch_collection",
+	"subscribe_topic",
+	"unsubscribe_topic",
+	"read_messages",
+	"ping",
+}
+
+// SetTools restricts the tools the server offers to those named by tools,
+// each a tool name or a tool set (ToolSetRead or ToolSetAll). The other
+// tools are not registered, so clients neither see nor call them. An empty
+// list keeps every tool.
+func (s *Server) SetTools(tools []string) error {
+	registered, err := s.toolNames()
+	if err != nil {
+		return err
+	}
+
+	allowed := make(map[string]bool)
+	for _, name := range tools {
+		switch name = strings.TrimSpace(name); name {
+		case "":
+		case ToolSetAll:
+			return nil
+		case ToolSetRead:
+			for _, tool := range readTools {
+				allowed[tool] = true
+			}
+		default:
+			if !slices.Contains(registered, name) {
+				return fmt.Errorf("unknown tool '%s' (tool sets: %s, %s)", name, ToolSetRead, ToolSetAll)
+			}
+			allowed[name] = true
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	s.allowedTools = allowed
+	s.removeDisallowedTools(registered)
+	return nil
+}
+
+// ToolsRestricted reports whether SetTools left out any tools
+func (s *Server) ToolsRestricted() bool {
+	return s.allowedTools != nil
+}
+
+// removeDisallowedTools unregisters the tools not in allowedTools
+func (s *Server) removeDisallowedTools(registered []string) {
+	var removed []string
+	for _, name := range registered {
+		if !s.allowedTools[name] {
+			removed = append(removed, name)
+		}
+	}
+	s.server.RemoveTools(removed...)
+}
+
+// toolNames returns the names of the tools registered on the server, as a
+// client lists them
+func (s *Server) toolNames() ([]string, error) {
+	ctx := context.Background()
+	clientTransport, serverTransport := mcp.NewInMemoryTransports()
+	serverSession, err := s.server.Connect(ctx, serverTransport, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tools: %w", err)
+	}
+	defer serverSession.Close()
+
+	client := mcp.NewClient(&mcp.Implementation{Name: "cachydb", Version: "1.0.0"}, nil)
+	session, err := client.Connect(ctx, clientTransport, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tools: %w", err)
+	}
+	defer session.Close()
+
+	var names []string
+	for tool, err := range session.Tools(ctx, nil) {
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tools: %w", err)
+		}
+		names = append(names, tool.Name)
+	}
+	return names, nil
+}
@@ -0,0 +1,131 @@
+package mcpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// ChangesLogger is the logger name of the notifications watch_collection sends
+const ChangesLogger = "cachydb.changes"
+
+// collectionWatch is a watch started with watch_collection
+type collectionWatch struct {
+	session *mcp.ServerSession
+	cancel  context.CancelFunc
+}
+
+func (s *Server) watchCollectionTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input WatchCollectionInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+	coll, err := database.GetCollection(input.Collection)
+	if err != nil {
+		return nil, nil, err
+	}
+	if coll.Ephemeral {
+		return nil, nil, fmt.Errorf("collection '%s' is ephemeral; its changes are not logged, so they cannot be watched", input.Collection)
+	}
+	for _, op := range input.Operations {
+		switch op {
+		case db.WALOpInsert, db.WALOpUpdate, db.WALOpUpsert, db.WALOpDelete:
+		default:
+			return nil, nil, fmt.Errorf("unknown operation '%s' (use insert, update, upsert or delete)", op)
+		}
+	}
+
+	session := req.Session
+	watchCtx, cancel := context.WithCancel(context.Background())
+	id := uuid.New().String()
+	s.watchesMu.Lock()
+	s.watches[id] = &collectionWatch{session: session, cancel: cancel}
+	s.watchesMu.Unlock()
+
+	// The watch ends with the session
+	go func() {
+		session.Wait()
+		cancel()
+	}()
+
+	go func() {
+		defer s.endWatch(id)
+		err := s.storage.WatchChanges(watchCtx, func(event db.ChangeEvent) error {
+			if event.Database != database.Name || event.Collection != input.Collection {
+				return nil
+			}
+			if len(input.Operations) > 0 && !slices.Contains(input.Operations, event.Operation) {
+				return nil
+			}
+			return session.Log(watchCtx, &mcp.LoggingMessageParams{
+				Level:  "info",
+				Logger: ChangesLogger,
+				Data:   s.changeToJSON(id, event),
+			})
+		})
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("Watch %s of %s/%s ended: %v\n", id, database.Name, input.Collection, err)
+		}
+	}()
+
+	return nil, map[string]interface{}{
+		"success":  true,
+		"watch_id": id,
+		"message":  fmt.Sprintf("Watching collection '%s'; changes arrive as log notifications from logger %s", input.Collection, ChangesLogger),
+	}, nil
+}
+
+func (s *Server) unwatchCollectionTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input UnwatchCollectionInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	s.watchesMu.Lock()
+	watch, exists := s.watches[input.WatchID]
+	s.watchesMu.Unlock()
+	// Sessions only see their own watches
+	if !exists || watch.session != req.Session {
+		return nil, nil, fmt.Errorf("watch '%s' not found", input.WatchID)
+	}
+
+	watch.cancel()
+	s.endWatch(input.WatchID)
+	return nil, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Watch %s stopped", input.WatchID),
+	}, nil
+}
+
+// endWatch forgets a watch that stopped
+func (s *Server) endWatch(id string) {
+	s.watchesMu.Lock()
+	defer s.watchesMu.Unlock()
+	delete(s.watches, id)
+}
+
+// changeToJSON converts a change event to the data of a notification
+func (s *Server) changeToJSON(watchID string, event db.ChangeEvent) map[string]interface{} {
+	change := map[string]interface{}{
+		"watch_id":    watchID,
+		"offset":      event.Offset,
+		"timestamp":   event.Timestamp,
+		"database":    s.databases.DisplayName(event.Database),
+		"collection":  event.Collection,
+		"operation":   event.Operation,
+		"document_id": event.DocumentID,
+	}
+	if event.Document != nil {
+		change["document"] = documentToJSON(event.Document)
+	}
+	return change
+}
@@ -0,0 +1,133 @@
+package mcpserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hop-/cachydb/internal/webhook"
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+func (s *Server) createWebhookTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input CreateWebhookInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rule := &webhook.Rule{
+		Database:   s.databases.DisplayName(database.Name),
+		Collection: input.Collection,
+		Operations: input.Operations,
+		URL:        input.URL,
+		Secret:     input.Secret,
+	}
+	if input.Filter != nil {
+		encoded, err := json.Marshal(input.Filter)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		var filter db.Filter
+		if err := json.Unmarshal(encoded, &filter); err != nil {
+			return nil, nil, fmt.Errorf("invalid filter: %w", err)
+		}
+		rule.Filter = &filter
+	}
+
+	id, err := webhook.AddRule(s.storage, database, rule)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success":    true,
+		"webhook_id": id,
+		"since":      rule.Since,
+	}, nil
+}
+
+func (s *Server) listWebhooksTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input ListWebhooksInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+	rules, err := webhook.Rules(database)
+	if err != nil {
+		return nil, nil, err
+	}
+	deliveries, err := webhook.Deliveries(database)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pending := make(map[string]int)
+	failed := make(map[string]int)
+	lastErrors := make(map[string]string)
+	for _, delivery := range deliveries {
+		if delivery.Failed {
+			failed[delivery.Webhook]++
+		} else {
+			pending[delivery.Webhook]++
+		}
+		if delivery.LastError != "" {
+			lastErrors[delivery.Webhook] = delivery.LastError
+		}
+	}
+
+	webhooks := make([]map[string]interface{}, 0, len(rules))
+	for _, rule := range rules {
+		info := map[string]interface{}{
+			"webhook_id": rule.ID,
+			"collection": rule.Collection,
+			"url":        rule.URL,
+			"signed":     rule.Secret != "",
+			"created":    rule.Created,
+			"pending":    pending[rule.ID],
+			"failed":     failed[rule.ID],
+		}
+		if len(rule.Operations) > 0 {
+			info["operations"] = rule.Operations
+		}
+		if rule.Filter != nil {
+			info["filter"] = rule.Filter
+		}
+		if lastError, ok := lastErrors[rule.ID]; ok {
+			info["last_error"] = lastError
+		}
+		webhooks = append(webhooks, info)
+	}
+
+	return nil, map[string]interface{}{
+		"success":  true,
+		"webhooks": webhooks,
+		"count":    len(webhooks),
+	}, nil
+}
+
+func (s *Server) deleteWebhookTool(
+	ctx context.Context,
+	req *mcp.CallToolRequest,
+	input DeleteWebhookInput,
+) (*mcp.CallToolResult, map[string]interface{}, error) {
+	database, err := s.getDatabase(input.Database)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := webhook.DeleteRule(s.storage, database, input.WebhookID); err != nil {
+		return nil, nil, err
+	}
+
+	return nil, map[string]interface{}{
+		"success": true,
+		"message": fmt.Sprintf("Webhook '%s' deleted", input.WebhookID),
+	}, nil
+}
@@ -0,0 +1,411 @@
+// Package mongosync mirrors selected collections into MongoDB, for
+// deployments that keep CachyDB at the edge and MongoDB in the cloud. A
+// Syncer copies each selected collection once, then follows the change
+// stream of the WAL: documents written are upserted and documents removed
+// are deleted, so MongoDB holds a one-way copy that catches up after every
+// disconnection.
+package mongosync
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// StateFile, in the root directory, holds the WAL offset the syncer
+// mirrored up to
+const StateFile = "mongosync.json"
+
+// Reconnection backoff of a syncer whose MongoDB writes failed
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = time.Minute
+)
+
+const (
+	// batchSize bounds the documents sent to MongoDB in one bulk write
+	batchSize = 1000
+	// saveInterval is how often the mirrored offset is saved while entries
+	// arrive; entries mirrored again after a crash are upserts and deletes
+	// MongoDB already has, so they change nothing
+	saveInterval = time.Second
+)
+
+// Config selects what a Syncer mirrors and where to
+type Config struct {
+	// URI of the MongoDB deployment, e.g. mongodb://mongo:27017
+	URI string
+	// Collections are mirrored, each as "database.collection"
+	Collections []string
+	// Database receives every mirrored collection if set; otherwise each
+	// lands in the MongoDB database named like its own
+	Database string
+}
+
+// target is a mirrored collection
+type target struct {
+	// database is the qualified name of the CachyDB database
+	database   string
+	collection string
+	mongo      *mongo.Collection
+}
+
+// state is what StateFile holds
+type state struct {
+	// Offset is the next WAL offset to mirror
+	Offset uint64 `json:"offset"`
+	// Collections are the selection the offset applies to; a different
+	// selection is copied again
+	Collections []string `json:"collections"`
+}
+
+// Syncer mirrors collections into MongoDB
+type Syncer struct {
+	databases *db.DatabaseManager
+	storage   *db.StorageManager
+	client    *mongo.Client
+	targets   []*target
+	selection []string
+	statePath string
+}
+
+// NewSyncer creates a syncer of the collections config selects among the
+// databases of tenant (every database if empty). It connects to MongoDB
+// lazily, on its first write.
+func NewSyncer(databases *db.DatabaseManager, storage *db.StorageManager, tenant string, config Config) (*Syncer, error) {
+	if config.URI == "" {
+		return nil, fmt.Errorf("a MongoDB URI is required")
+	}
+	if len(config.Collections) == 0 {
+		return nil, fmt.Errorf("no collections selected to sync to MongoDB")
+	}
+
+	client, err := mongo.Connect(options.Client().ApplyURI(config.URI))
+	if err != nil {
+		return nil, fmt.Errorf("invalid MongoDB URI: %w", err)
+	}
+
+	scope := databases.Tenant(tenant)
+	s := &Syncer{
+		databases: databases,
+		storage:   storage,
+		client:    client,
+		statePath: filepath.Join(storage.RootDir, StateFile),
+	}
+	for _, selector := range config.Collections {
+		database, collection, ok := strings.Cut(selector, ".")
+		if !ok || database == "" || collection == "" {
+			return nil, fmt.Errorf("invalid collection '%s': expected database.collection", selector)
+		}
+		mongoDatabase := config.Database
+		if mongoDatabase == "" {
+			mongoDatabase = database
+		}
+		if strings.ContainsAny(mongoDatabase, `/\. "$`) {
+			return nil, fmt.Errorf("'%s' cannot be a MongoDB database name; set a target database", mongoDatabase)
+		}
+		s.targets = append(s.targets, &target{
+			database:   scope.QualifiedName(database),
+			collection: collection,
+			mongo:      client.Database(mongoDatabase).Collection(collection),
+		})
+		s.selection = append(s.selection, scope.QualifiedName(database)+"/"+collection)
+	}
+	slices.Sort(s.selection)
+	return s, nil
+}
+
+// Run mirrors the selected collections until ctx ends, retrying with
+// backoff when MongoDB cannot be reached
+func (s *Syncer) Run(ctx context.Context) error {
+	defer s.client.Disconnect(context.Background()) //nolint:errcheck
+
+	delay := minRetryDelay
+	for {
+		mirrored, err := s.sync(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if mirrored > 0 {
+			delay = minRetryDelay
+		}
+		log.Printf("MongoDB sync stopped: %v; retrying in %s\n", err, delay)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// sync copies the collections if the saved state does not cover them, then
+// mirrors WAL entries until ctx ends or a write fails. It returns the
+// number of entries mirrored.
+func (s *Syncer) sync(ctx context.Context) (int, error) {
+	saved, err := s.loadState()
+	if err != nil {
+		return 0, err
+	}
+	if saved == nil || !slices.Equal(saved.Collections, s.selection) {
+		// Entries logged during the copy are mirrored again afterwards,
+		// which leaves the documents they wrote as they are
+		from := s.storage.WAL.NextOffset()
+		for _, t := range s.targets {
+			if err := s.copyCollection(ctx, t); err != nil {
+				return 0, fmt.Errorf("failed to copy %s/%s: %w", t.database, t.collection, err)
+			}
+		}
+		saved = &state{Offset: from, Collections: s.selection}
+		if err := s.saveState(saved); err != nil {
+			return 0, err
+		}
+		log.Printf("Copied %d collections to MongoDB\n", len(s.targets))
+	}
+
+	log.Printf("Syncing %d collections to MongoDB from WAL offset %d\n", len(s.targets), saved.Offset)
+
+	mirrored := 0
+	lastSave := time.Now()
+	err = s.storage.WAL.Follow(ctx, saved.Offset, func(entry *db.WALEntry) error {
+		if err := s.mirror(ctx, entry); err != nil {
+			return fmt.Errorf("failed to mirror WAL entry at offset %d: %w", entry.Offset, err)
+		}
+		saved.Offset = entry.Offset + 1
+		mirrored++
+
+		if time.Since(lastSave) >= saveInterval {
+			lastSave = time.Now()
+			return s.saveState(saved)
+		}
+		return nil
+	})
+	if saveErr := s.saveState(saved); err == nil {
+		err = saveErr
+	}
+	if errors.Is(err, db.ErrWALTruncated) {
+		// The entries between the saved offset and the WAL are gone; copy
+		// the collections again on the next attempt
+		if removeErr := os.Remove(s.statePath); removeErr != nil && !os.IsNotExist(removeErr) {
+			log.Printf("Warning: failed to reset MongoDB sync state: %v\n", removeErr)
+		}
+	}
+	return mirrored, err
+}
+
+// mirror applies the changes of a WAL entry to the selected collections
+func (s *Syncer) mirror(ctx context.Context, entry *db.WALEntry) error {
+	switch entry.Operation {
+	case db.WALOpDeleteDatabase, db.WALOpDeleteCollection:
+		for _, t := range s.targets {
+			if t.database == entry.Database && (entry.Operation == db.WALOpDeleteDatabase || t.collection == entry.Collection) {
+				if err := t.mongo.Drop(ctx); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+
+	case db.WALOpTruncateCollection:
+		if t := s.target(entry.Database, entry.Collection); t != nil {
+			_, err := t.mongo.DeleteMany(ctx, bson.D{})
+			return err
+		}
+		return nil
+	}
+
+	events, err := db.ChangeEvents(entry)
+	if err != nil {
+		return err
+	}
+
+	// A transaction can write several collections; each gets its changes
+	// in one bulk write, in order
+	var order []*target
+	writes := make(map[*target][]mongo.WriteModel)
+	for _, event := range events {
+		t := s.target(event.Database, event.Collection)
+		if t == nil {
+			continue
+		}
+		model, err := s.writeModel(event)
+		if err != nil {
+			return err
+		}
+		if model == nil {
+			continue
+		}
+		if _, exists := writes[t]; !exists {
+			order = append(order, t)
+		}
+		writes[t] = append(writes[t], model)
+	}
+	for _, t := range order {
+		if _, err := t.mongo.BulkWrite(ctx, writes[t], options.BulkWrite().SetOrdered(true)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeModel returns the MongoDB write that mirrors a document change, or
+// nil if there is nothing to write
+func (s *Syncer) writeModel(event db.ChangeEvent) (mongo.WriteModel, error) {
+	if event.Document == nil {
+		return mongo.NewDeleteOneModel().SetFilter(bson.D{{Key: "_id", Value: event.DocumentID}}), nil
+	}
+
+	database := s.databases.GetDatabase(event.Database)
+	if database == nil {
+		return nil, nil // deleted later in the WAL, which drops the copy
+	}
+	coll, err := database.GetCollection(event.Collection)
+	if err != nil {
+		return nil, nil
+	}
+	doc, err := coll.ResolveBlobs(event.Document)
+	if err != nil {
+		// The blob belongs to a value overwritten later in the WAL, whose
+		// entry brings the document as it is now
+		log.Printf("Warning: skipping a past version of document '%s' in MongoDB sync: %v\n", event.DocumentID, err)
+		return nil, nil
+	}
+	return replaceModel(doc), nil
+}
+
+// target returns the mirrored collection of a CachyDB collection, if selected
+func (s *Syncer) target(database, collection string) *target {
+	for _, t := range s.targets {
+		if t.database == database && t.collection == collection {
+			return t
+		}
+	}
+	return nil
+}
+
+// copyCollection makes the MongoDB collection of t hold the documents of
+// its CachyDB collection: every document is upserted, and documents
+// MongoDB has that the collection lacks are deleted
+func (s *Syncer) copyCollection(ctx context.Context, t *target) error {
+	ids := make(map[string]bool)
+	database := s.databases.GetDatabase(t.database)
+	if database != nil {
+		if coll, err := database.GetCollection(t.collection); err == nil {
+			docs, err := coll.FindIter(&db.Query{})
+			if err != nil {
+				return err
+			}
+			batch := make([]mongo.WriteModel, 0, batchSize)
+			for doc := range docs {
+				resolved, err := coll.ResolveBlobs(doc)
+				if err != nil {
+					return err
+				}
+				ids[doc.ID] = true
+				batch = append(batch, replaceModel(resolved))
+				if len(batch) == batchSize {
+					if _, err := t.mongo.BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(false)); err != nil {
+						return err
+					}
+					batch = batch[:0]
+				}
+			}
+			if len(batch) > 0 {
+				if _, err := t.mongo.BulkWrite(ctx, batch, options.BulkWrite().SetOrdered(false)); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	cursor, err := t.mongo.Find(ctx, bson.D{}, options.Find().SetProjection(bson.D{{Key: "_id", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var stale []any
+	for cursor.Next(ctx) {
+		var doc struct {
+			ID any `bson:"_id"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return err
+		}
+		if id, ok := doc.ID.(string); !ok || !ids[id] {
+			stale = append(stale, doc.ID)
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return err
+	}
+	for len(stale) > 0 {
+		n := min(len(stale), batchSize)
+		if _, err := t.mongo.DeleteMany(ctx, bson.D{{Key: "_id", Value: bson.D{{Key: "$in", Value: stale[:n]}}}}); err != nil {
+			return err
+		}
+		stale = stale[n:]
+	}
+	return nil
+}
+
+// replaceModel returns the upsert of a document, stored under its _id with
+// its fields as they are, reserved ones such as _rev included
+func replaceModel(doc *db.Document) mongo.WriteModel {
+	replacement := make(bson.M, len(doc.Data)+1)
+	for field, value := range doc.Data {
+		replacement[field] = value
+	}
+	replacement["_id"] = doc.ID
+	return mongo.NewReplaceOneModel().
+		SetFilter(bson.D{{Key: "_id", Value: doc.ID}}).
+		SetReplacement(replacement).
+		SetUpsert(true)
+}
+
+// loadState reads the saved state, or returns nil if there is none
+func (s *Syncer) loadState() (*state, error) {
+	data, err := os.ReadFile(s.statePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read MongoDB sync state: %w", err)
+	}
+	var saved state
+	if err := json.Unmarshal(data, &saved); err != nil {
+		log.Printf("Warning: ignoring invalid MongoDB sync state: %v\n", err)
+		return nil, nil
+	}
+	return &saved, nil
+}
+
+// saveState replaces the saved state, through a temporary file so a crash
+// leaves the old state or the new one
+func (s *Syncer) saveState(saved *state) error {
+	data, err := json.Marshal(saved)
+	if err != nil {
+		return err
+	}
+	tmp := s.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to save MongoDB sync state: %w", err)
+	}
+	if err := os.Rename(tmp, s.statePath); err != nil {
+		return fmt.Errorf("failed to save MongoDB sync state: %w", err)
+	}
+	return nil
+}
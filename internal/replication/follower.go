@@ -0,0 +1,241 @@
+package replication
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// Reconnection backoff of a follower whose stream failed
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+// Status reports the state of a follower
+type Status struct {
+	Leader    string `json:"leader"`
+	Connected bool   `json:"connected"`
+	// Offset is the next leader WAL offset the follower applies, or, once
+	// promoted, the offset it stopped following at
+	Offset uint64 `json:"offset"`
+	// Applied counts the entries applied since the follower started
+	Applied uint64 `json:"applied"`
+	// LastEntry is when the leader logged the last applied entry
+	LastEntry time.Time `json:"last_entry,omitzero"`
+	LastError string    `json:"last_error,omitempty"`
+	Promoted  bool      `json:"promoted"`
+}
+
+// Follower keeps a storage manager in sync with a leader by applying the
+// WAL entries the leader streams. While it follows, the storage manager is
+// a replica and refuses writes of its own.
+type Follower struct {
+	leader  string
+	token   string
+	storage *db.StorageManager
+	client  *http.Client
+
+	mu     sync.Mutex
+	status Status
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// NewFollower creates a follower of the server at leader, e.g.
+// http://leader:7601, for storage, whose databases must be loaded. token is
+// the leader's replication token. It makes storage a replica right away.
+func NewFollower(leader, token string, storage *db.StorageManager) *Follower {
+	leader = LeaderURL(leader)
+	storage.SetReplica(true)
+	return &Follower{
+		leader:  leader,
+		token:   token,
+		storage: storage,
+		client:  &http.Client{},
+		status:  Status{Leader: leader},
+	}
+}
+
+// LeaderURL normalizes the address of a leader: a missing scheme means
+// http, and an /mcp endpoint path is dropped
+func LeaderURL(leader string) string {
+	if !strings.Contains(leader, "://") {
+		leader = "http://" + leader
+	}
+	leader = strings.TrimRight(leader, "/")
+	return strings.TrimSuffix(leader, "/mcp")
+}
+
+// Start follows the leader in the background until ctx ends or Promote is
+// called, reconnecting with backoff when the stream fails
+func (f *Follower) Start(ctx context.Context) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.done != nil || f.status.Promoted {
+		return
+	}
+
+	ctx, f.cancel = context.WithCancel(ctx)
+	f.done = make(chan struct{})
+	go f.run(ctx)
+}
+
+// Promote stops following the leader and lets the storage manager take
+// writes. Entries the leader logged but did not stream yet are not applied.
+func (f *Follower) Promote() error {
+	f.mu.Lock()
+	if f.status.Promoted {
+		f.mu.Unlock()
+		return fmt.Errorf("already promoted")
+	}
+	f.status.Promoted = true
+	cancel, done := f.cancel, f.done
+	f.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	if err := f.storage.WAL.Flush(); err != nil {
+		return fmt.Errorf("failed to flush replicated entries: %w", err)
+	}
+	f.mu.Lock()
+	f.status.Connected = false
+	f.status.Offset = f.storage.WAL.NextOffset()
+	f.mu.Unlock()
+
+	f.storage.SetReplica(false)
+	log.Printf("Promoted: no longer following %s, taking writes at WAL offset %d\n", f.leader, f.storage.WAL.NextOffset())
+	return nil
+}
+
+// Status returns the state of the follower
+func (f *Follower) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	status := f.status
+	if !status.Promoted {
+		status.Offset = f.storage.WAL.NextOffset()
+	}
+	return status
+}
+
+// run follows the leader until ctx ends
+func (f *Follower) run(ctx context.Context) {
+	defer close(f.done)
+
+	delay := minRetryDelay
+	for {
+		applied, err := f.follow(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if applied > 0 {
+			delay = minRetryDelay
+		}
+
+		f.mu.Lock()
+		f.status.Connected = false
+		if err != nil {
+			f.status.LastError = err.Error()
+		}
+		f.mu.Unlock()
+		log.Printf("Replication from %s stopped: %v; retrying in %s\n", f.leader, err, delay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// follow streams and applies entries from the next offset this storage
+// manager lacks, until the stream ends. It returns the number of entries
+// applied.
+func (f *Follower) follow(ctx context.Context) (int, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	from := f.storage.WAL.NextOffset()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s%s?from=%d", f.leader, StreamPath, from), nil)
+	if err != nil {
+		return 0, err
+	}
+	authorize(req, f.token)
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		err := fmt.Errorf("leader responded %s: %s", resp.Status, bytes.TrimSpace(message))
+		if resp.StatusCode == http.StatusGone {
+			err = fmt.Errorf("%w (%s)", db.ErrWALTruncated, bytes.TrimSpace(message))
+		}
+		return 0, err
+	}
+
+	f.mu.Lock()
+	f.status.Connected = true
+	f.status.LastError = ""
+	f.mu.Unlock()
+	log.Printf("Following %s from WAL offset %d\n", f.leader, from)
+
+	// A leader that stops sending, even heartbeats, is given up on
+	var timedOut atomic.Bool
+	watchdog := time.AfterFunc(3*HeartbeatInterval, func() {
+		timedOut.Store(true)
+		cancel()
+	})
+	defer watchdog.Stop()
+
+	reader := bufio.NewReader(resp.Body)
+	applied := 0
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = fmt.Errorf("the leader closed the stream")
+			} else if timedOut.Load() {
+				err = fmt.Errorf("no data from the leader for %s", 3*HeartbeatInterval)
+			}
+			return applied, err
+		}
+		watchdog.Reset(3 * HeartbeatInterval)
+
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue // heartbeat
+		}
+		var entry db.WALEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return applied, fmt.Errorf("invalid WAL entry from the leader: %w", err)
+		}
+		if err := f.storage.ApplyReplicated(&entry); err != nil {
+			return applied, fmt.Errorf("failed to apply WAL entry at offset %d: %w", entry.Offset, err)
+		}
+		applied++
+
+		f.mu.Lock()
+		f.status.Applied++
+		f.status.LastEntry = entry.Timestamp
+		f.mu.Unlock()
+	}
+}
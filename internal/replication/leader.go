@@ -0,0 +1,139 @@
+// Package replication ships the WAL of a server to read replicas over HTTP.
+// The leader streams its WAL entries from the offset a follower asks for;
+// the follower logs them under the same offsets and applies them like a
+// replayed WAL (see db.StorageManager.ApplyReplicated). A new follower
+// first copies a snapshot of the leader's data (see Bootstrap). Both
+// endpoints require a token shared by the leader and its followers.
+package replication
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// StreamPath is the HTTP path under which a server streams its WAL
+const StreamPath = "/replication/wal"
+
+// HeartbeatInterval is how often an idle stream sends an empty line, so a
+// follower notices a leader that went away
+const HeartbeatInterval = 5 * time.Second
+
+// Handler streams the WAL of storage to followers that send token (see
+// Authorized). A GET of StreamPath with ?from=<offset> responds with the
+// WAL entries from that offset on, one JSON object per line, and keeps the
+// response open for the entries written later. If the entries from that
+// offset were already removed by WAL retention, it responds 410 Gone.
+func Handler(storage *db.StorageManager, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !Authorized(r, token) {
+			http.Error(w, "missing or invalid replication token", http.StatusUnauthorized)
+			return
+		}
+		from, err := strconv.ParseUint(r.URL.Query().Get("from"), 10, 64)
+		if err != nil {
+			http.Error(w, "from must be a WAL offset", http.StatusBadRequest)
+			return
+		}
+
+		oldest, err := storage.WAL.OldestOffset()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if next := storage.WAL.NextOffset(); from < oldest && from < next {
+			http.Error(w, fmt.Sprintf("%v: the WAL starts at offset %d, after the requested offset %d", db.ErrWALTruncated, oldest, from),
+				http.StatusGone)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		flusher.Flush()
+
+		if err := stream(r.Context(), storage.WAL, from, w, flusher); err != nil && !errors.Is(err, context.Canceled) {
+			// The status is sent already; the follower sees the stream end
+			log.Printf("Failed to stream the WAL to %s: %v\n", r.RemoteAddr, err)
+		}
+	})
+}
+
+// Authorized reports whether a request carries token as its bearer token.
+// No request is authorized by an empty token.
+func Authorized(r *http.Request, token string) bool {
+	sent, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && token != "" && subtle.ConstantTimeCompare([]byte(sent), []byte(token)) == 1
+}
+
+// authorize sets the bearer token of a request to the leader, if any
+func authorize(req *http.Request, token string) {
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+}
+
+// stream writes the entries of wal from offset from on to w until ctx ends
+func stream(ctx context.Context, wal *db.WALManager, from uint64, w http.ResponseWriter, flusher http.Flusher) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var mu sync.Mutex // serializes the writes of entries and heartbeats
+	heartbeats := make(chan struct{})
+	go func() {
+		defer close(heartbeats)
+		ticker := time.NewTicker(HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			mu.Lock()
+			_, err := w.Write([]byte("\n"))
+			if err == nil {
+				flusher.Flush()
+			}
+			mu.Unlock()
+			if err != nil {
+				cancel()
+				return
+			}
+		}
+	}()
+	// The response must not be written once the handler returns
+	defer func() {
+		cancel()
+		<-heartbeats
+	}()
+
+	encoder := json.NewEncoder(w)
+	return wal.Follow(ctx, from, func(entry *db.WALEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	})
+}
@@ -0,0 +1,81 @@
+package replication
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// SnapshotPath is the HTTP path under which a server sends a snapshot of
+// its data to new followers
+const SnapshotPath = "/replication/snapshot"
+
+// SnapshotHandler sends a snapshot of storage to a new follower that sends
+// token (see Authorized). A GET of SnapshotPath responds with an archive
+// written by db.StorageManager.WriteSnapshot; the follower streams the WAL
+// from the snapshot's offset on.
+func SnapshotHandler(storage *db.StorageManager, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !Authorized(r, token) {
+			http.Error(w, "missing or invalid replication token", http.StatusUnauthorized)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/gzip")
+		out := &startedWriter{w: w}
+		info, err := storage.WriteSnapshot(out)
+		if err != nil {
+			if !out.started {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			// The status is sent already; the follower sees a broken archive
+			log.Printf("Failed to send a snapshot to %s: %v\n", r.RemoteAddr, err)
+			return
+		}
+		log.Printf("Sent a snapshot at WAL offset %d to %s (%d files, %d bytes)\n", info.WALOffset, r.RemoteAddr, info.Files, info.Bytes)
+	})
+}
+
+// startedWriter records whether anything was written to a response
+type startedWriter struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (sw *startedWriter) Write(p []byte) (int, error) {
+	sw.started = true
+	return sw.w.Write(p)
+}
+
+// Bootstrap fills rootDir, which must hold no data (see db.IsEmptyRoot),
+// with a snapshot of the leader, so that a new follower only needs the WAL
+// entries the leader logged after it. token is the leader's replication
+// token. Call it before rootDir is opened.
+func Bootstrap(leader, token, rootDir string, config db.WALConfig) (*db.SnapshotInfo, error) {
+	leader = LeaderURL(leader)
+	req, err := http.NewRequest(http.MethodGet, leader+SnapshotPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	authorize(req, token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("leader responded %s: %s", resp.Status, bytes.TrimSpace(message))
+	}
+	return db.InstallSnapshot(resp.Body, rootDir, config)
+}
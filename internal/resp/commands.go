@@ -0,0 +1,376 @@
+package resp
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// command is a command the server answers
+type command struct {
+	// arity is the number of arguments including the name, or, if
+	// negative, the minimum number
+	arity   int
+	handler func(s *Server, w *writer, args [][]byte) error
+}
+
+var commands = map[string]command{
+	"PING":    {-1, (*Server).ping},
+	"ECHO":    {2, (*Server).echo},
+	"SELECT":  {2, (*Server).selectDB},
+	"HELLO":   {-1, (*Server).hello},
+	"CLIENT":  {-2, (*Server).client},
+	"COMMAND": {-1, (*Server).commandInfo},
+	"GET":     {2, (*Server).get},
+	"SET":     {-3, (*Server).set},
+	"DEL":     {-2, (*Server).del},
+	"EXISTS":  {-2, (*Server).exists},
+	"EXPIRE":  {3, (*Server).expire},
+	"PEXPIRE": {3, (*Server).expire},
+	"PERSIST": {2, (*Server).persist},
+	"TTL":     {2, (*Server).ttl},
+	"PTTL":    {2, (*Server).ttl},
+	"SCAN":    {-2, (*Server).scan},
+}
+
+// execute runs a command and writes its reply
+func (s *Server) execute(w *writer, name string, args [][]byte) error {
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command '%s'", args[0])
+	}
+	if (cmd.arity > 0 && len(args) != cmd.arity) || len(args) < -cmd.arity {
+		return fmt.Errorf("wrong number of arguments for '%s' command", strings.ToLower(name))
+	}
+	return cmd.handler(s, w, args)
+}
+
+func (s *Server) ping(w *writer, args [][]byte) error {
+	switch len(args) {
+	case 1:
+		w.writeSimple("PONG")
+	case 2:
+		w.writeBulk(args[1])
+	default:
+		return fmt.Errorf("wrong number of arguments for 'ping' command")
+	}
+	return nil
+}
+
+func (s *Server) echo(w *writer, args [][]byte) error {
+	w.writeBulk(args[1])
+	return nil
+}
+
+// selectDB only accepts database 0: all keys are in one collection
+func (s *Server) selectDB(w *writer, args [][]byte) error {
+	if string(args[1]) != "0" {
+		return fmt.Errorf("DB index is out of range")
+	}
+	w.writeSimple("OK")
+	return nil
+}
+
+// hello refuses every protocol version but 2, so clients that try RESP3
+// first fall back to RESP2
+func (s *Server) hello(w *writer, args [][]byte) error {
+	if len(args) > 1 && string(args[1]) != "2" {
+		return &replyError{"NOPROTO unsupported protocol version"}
+	}
+	return fmt.Errorf("unknown command 'HELLO'")
+}
+
+// client accepts the CLIENT subcommands libraries send on connecting, such
+// as SETNAME and SETINFO, without acting on them
+func (s *Server) client(w *writer, args [][]byte) error {
+	w.writeSimple("OK")
+	return nil
+}
+
+// commandInfo answers COMMAND with no command details, which clients take
+// as nothing known about the commands
+func (s *Server) commandInfo(w *writer, args [][]byte) error {
+	w.writeArrayLen(0)
+	return nil
+}
+
+func (s *Server) get(w *writer, args [][]byte) error {
+	k, err := s.lookup(string(args[1]), time.Now())
+	if err != nil {
+		return err
+	}
+	if k == nil {
+		w.writeBulk(nil)
+		return nil
+	}
+	value, err := k.value()
+	if err != nil {
+		return err
+	}
+	w.writeBulk(value)
+	return nil
+}
+
+// set stores a value: SET key value [NX | XX] [GET] [EX seconds | PX
+// milliseconds | EXAT unix-seconds | PXAT unix-milliseconds | KEEPTTL]
+func (s *Server) set(w *writer, args [][]byte) error {
+	name := string(args[1])
+	now := time.Now()
+
+	var nx, xx, get, keepTTL, expires bool
+	var at time.Time
+	for i := 3; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		switch option {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		case "GET":
+			get = true
+		case "KEEPTTL":
+			keepTTL = true
+		case "EX", "PX", "EXAT", "PXAT":
+			if expires || i+1 >= len(args) {
+				return fmt.Errorf("syntax error")
+			}
+			i++
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid expire time in 'set' command")
+			}
+			expires = true
+			switch option {
+			case "EX":
+				at = now.Add(time.Duration(n) * time.Second)
+			case "PX":
+				at = now.Add(time.Duration(n) * time.Millisecond)
+			case "EXAT":
+				at = time.Unix(n, 0)
+			case "PXAT":
+				at = time.UnixMilli(n)
+			}
+		default:
+			return fmt.Errorf("syntax error")
+		}
+	}
+	if (nx && xx) || (keepTTL && expires) {
+		return fmt.Errorf("syntax error")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k, err := s.lookup(name, now)
+	if err != nil {
+		return err
+	}
+	var old []byte
+	if get && k != nil {
+		if old, err = k.value(); err != nil {
+			return err
+		}
+	}
+	if (nx && k != nil) || (xx && k == nil) {
+		if get {
+			w.writeBulk(old)
+		} else {
+			w.writeBulk(nil)
+		}
+		return nil
+	}
+
+	fields := keyFields(args[2])
+	if keepTTL && k != nil && !k.expiresAt.IsZero() {
+		at = k.expiresAt
+	}
+	if !at.IsZero() {
+		fields[ExpiresField] = expiryValue(at)
+	}
+	if err := s.put(name, fields); err != nil {
+		return err
+	}
+
+	if get {
+		w.writeBulk(old)
+	} else {
+		w.writeSimple("OK")
+	}
+	return nil
+}
+
+func (s *Server) del(w *writer, args [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var deleted int64
+	for _, name := range args[1:] {
+		live, err := s.remove(string(name), now)
+		if err != nil {
+			return err
+		}
+		if live {
+			deleted++
+		}
+	}
+	w.writeInt(deleted)
+	return nil
+}
+
+func (s *Server) exists(w *writer, args [][]byte) error {
+	now := time.Now()
+	var count int64
+	for _, name := range args[1:] {
+		k, err := s.lookup(string(name), now)
+		if err != nil {
+			return err
+		}
+		if k != nil {
+			count++
+		}
+	}
+	w.writeInt(count)
+	return nil
+}
+
+// expire sets the expiry of a key, in seconds (EXPIRE) or milliseconds
+// (PEXPIRE) from now; a time not in the future deletes the key
+func (s *Server) expire(w *writer, args [][]byte) error {
+	n, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return fmt.Errorf("value is not an integer or out of range")
+	}
+	unit := time.Second
+	if strings.EqualFold(string(args[0]), "PEXPIRE") {
+		unit = time.Millisecond
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := string(args[1])
+	now := time.Now()
+	k, err := s.lookup(name, now)
+	if err != nil {
+		return err
+	}
+	if k == nil {
+		w.writeInt(0)
+		return nil
+	}
+	if n <= 0 {
+		_, err = s.remove(name, now)
+	} else {
+		err = s.setExpiry(name, now.Add(time.Duration(n)*unit))
+	}
+	if err != nil {
+		return err
+	}
+	w.writeInt(1)
+	return nil
+}
+
+func (s *Server) persist(w *writer, args [][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name := string(args[1])
+	k, err := s.lookup(name, time.Now())
+	if err != nil {
+		return err
+	}
+	if k == nil || k.expiresAt.IsZero() {
+		w.writeInt(0)
+		return nil
+	}
+	if err := s.setExpiry(name, time.Time{}); err != nil {
+		return err
+	}
+	w.writeInt(1)
+	return nil
+}
+
+// ttl replies with the time a key has left, in seconds (TTL) or
+// milliseconds (PTTL): -2 if it does not exist and -1 if it never expires
+func (s *Server) ttl(w *writer, args [][]byte) error {
+	now := time.Now()
+	k, err := s.lookup(string(args[1]), now)
+	if err != nil {
+		return err
+	}
+	switch {
+	case k == nil:
+		w.writeInt(-2)
+	case k.expiresAt.IsZero():
+		w.writeInt(-1)
+	case strings.EqualFold(string(args[0]), "PTTL"):
+		w.writeInt(k.expiresAt.Sub(now).Milliseconds())
+	default:
+		w.writeInt((k.expiresAt.Sub(now).Milliseconds() + 500) / 1000)
+	}
+	return nil
+}
+
+// scan iterates the keys in ID order: SCAN cursor [MATCH pattern] [COUNT
+// count] [TYPE type]. The cursor is the number of keys examined so far, so
+// keys added or removed during the iteration may shift others past it or
+// make them be returned twice.
+func (s *Server) scan(w *writer, args [][]byte) error {
+	cursor, err := strconv.Atoi(string(args[1]))
+	if err != nil || cursor < 0 {
+		return fmt.Errorf("invalid cursor")
+	}
+	pattern, count, keyType := "*", 10, ""
+	for i := 2; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			return fmt.Errorf("syntax error")
+		}
+		value := string(args[i+1])
+		switch strings.ToUpper(string(args[i])) {
+		case "MATCH":
+			pattern = value
+		case "COUNT":
+			if count, err = strconv.Atoi(value); err != nil || count < 1 {
+				return fmt.Errorf("value is not an integer or out of range")
+			}
+		case "TYPE":
+			keyType = strings.ToLower(value)
+		default:
+			return fmt.Errorf("syntax error")
+		}
+	}
+
+	var names [][]byte
+	next := 0
+	if coll := s.keys(); coll != nil && (keyType == "" || keyType == "string") {
+		docs, err := coll.Find(&db.Query{Sort: []db.SortField{{Field: "_id"}}, Skip: cursor, Limit: count})
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, doc := range docs {
+			at := expiresAt(doc)
+			if !at.IsZero() && !now.Before(at) {
+				continue
+			}
+			if matchGlob(pattern, doc.ID) {
+				names = append(names, []byte(doc.ID))
+			}
+		}
+		if len(docs) == count {
+			next = cursor + count
+		}
+	}
+
+	w.writeArrayLen(2)
+	w.writeBulk([]byte(strconv.Itoa(next)))
+	w.writeArrayLen(len(names))
+	for _, name := range names {
+		w.writeBulk(name)
+	}
+	return nil
+}
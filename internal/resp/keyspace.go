@@ -0,0 +1,330 @@
+package resp
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// Fields of a key's document
+const (
+	// ValueField holds a value that is valid UTF-8
+	ValueField = "value"
+	// BinaryValueField holds any other value, base64-encoded
+	BinaryValueField = "value_base64"
+	// ExpiresField holds when the key expires, in seconds since the Unix
+	// epoch; keys without it never expire
+	ExpiresField = "expires_at"
+)
+
+// expiryGrace is the TTL of the index on ExpiresField: documents are
+// deleted in the background once their expiry is this far in the past.
+// Reads treat them as gone right away.
+const expiryGrace = time.Millisecond
+
+// replyError is an error reply with its own code, such as WRONGTYPE
+type replyError struct {
+	msg string
+}
+
+func (e *replyError) Error() string {
+	return e.msg
+}
+
+var (
+	errWrongType = &replyError{"WRONGTYPE Operation against a key holding the wrong kind of value"}
+	errReadOnly  = &replyError{"READONLY You can't write against a read only replica."}
+)
+
+// key is a live key as stored
+type key struct {
+	doc *db.Document
+	// expiresAt is zero for keys without an expiry
+	expiresAt time.Time
+}
+
+// value returns the value of a key
+func (k *key) value() ([]byte, error) {
+	if value, ok := k.doc.Data[ValueField].(string); ok {
+		return []byte(value), nil
+	}
+	if encoded, ok := k.doc.Data[BinaryValueField].(string); ok {
+		return base64.StdEncoding.DecodeString(encoded)
+	}
+	return nil, errWrongType
+}
+
+// keyFields returns the fields storing a value
+func keyFields(value []byte) map[string]any {
+	if utf8.Valid(value) {
+		return map[string]any{ValueField: string(value)}
+	}
+	return map[string]any{BinaryValueField: base64.StdEncoding.EncodeToString(value)}
+}
+
+// expiresAt reads the expiry of a document
+func expiresAt(doc *db.Document) time.Time {
+	var seconds float64
+	switch v := doc.Data[ExpiresField].(type) {
+	case float64:
+		seconds = v
+	case int64:
+		seconds = float64(v)
+	case int:
+		seconds = float64(v)
+	default:
+		return time.Time{}
+	}
+	return time.UnixMilli(int64(seconds * 1000))
+}
+
+// expiryValue is how an expiry is stored
+func expiryValue(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+// keys returns the collection of the keys, or nil if it does not exist yet
+func (s *Server) keys() *db.Collection {
+	database := s.databases.Tenant(s.tenant).GetDatabase(s.database)
+	if database == nil {
+		return nil
+	}
+	coll, err := database.GetCollection(s.collection)
+	if err != nil {
+		return nil
+	}
+	return coll
+}
+
+// lookup returns a key, or nil if it does not exist or has expired
+func (s *Server) lookup(name string, now time.Time) (*key, error) {
+	coll := s.keys()
+	if coll == nil {
+		return nil, nil
+	}
+	doc, err := coll.FindByID(name)
+	if errors.Is(err, db.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	k := &key{doc: doc, expiresAt: expiresAt(doc)}
+	if !k.expiresAt.IsZero() && !now.Before(k.expiresAt) {
+		return nil, nil
+	}
+	return k, nil
+}
+
+// writableKeys returns the collection of the keys for a write, creating
+// the database and collection, with the TTL index, if needed (caller must
+// hold mu)
+func (s *Server) writableKeys() (*db.Collection, error) {
+	if s.storage.IsReplica() {
+		return nil, errReadOnly
+	}
+	if coll := s.keys(); coll != nil {
+		return coll, nil
+	}
+
+	scope := s.databases.Tenant(s.tenant)
+	database := scope.GetDatabase(s.database)
+	if database == nil {
+		var err error
+		if database, err = scope.CreateDatabase(s.database); err != nil {
+			return nil, err
+		}
+		if err := s.storage.LogCreateDatabase(database.Name); err != nil {
+			return nil, fmt.Errorf("failed to log create database: %w", err)
+		}
+	}
+	if _, err := database.GetCollection(s.collection); err != nil {
+		if err := database.CreateCollection(s.collection, nil); err != nil {
+			return nil, err
+		}
+		if err := s.storage.LogCreateCollection(database.Name, s.collection, nil); err != nil {
+			return nil, fmt.Errorf("failed to log create collection: %w", err)
+		}
+	}
+	coll, err := database.GetCollection(s.collection)
+	if err != nil {
+		return nil, err
+	}
+
+	indexName := ExpiresField + db.TTLIndexSuffix
+	if _, exists := coll.ListIndexes()[indexName]; !exists {
+		if err := coll.CreateTTLIndex(ExpiresField, expiryGrace); err != nil {
+			return nil, err
+		}
+		if err := s.storage.LogCreateIndex(database.Name, coll.Name, indexName, ExpiresField, db.IndexOptions{TTL: expiryGrace}); err != nil {
+			return nil, fmt.Errorf("failed to log create index: %w", err)
+		}
+	}
+	return coll, nil
+}
+
+// put stores fields as the content of a key, replacing any it had (caller
+// must hold mu)
+func (s *Server) put(name string, fields map[string]any) error {
+	coll, err := s.writableKeys()
+	if err != nil {
+		return err
+	}
+
+	if _, err := coll.FindByID(name); err == nil {
+		unset := make(map[string]any)
+		for _, field := range []string{ValueField, BinaryValueField, ExpiresField} {
+			if _, ok := fields[field]; !ok {
+				unset[field] = ""
+			}
+		}
+		err = coll.Update(name, map[string]any{db.UpdateOpSet: fields, db.UpdateOpUnset: unset})
+	} else {
+		err = coll.Insert(&db.Document{ID: name, Data: fields})
+	}
+	if err != nil {
+		return err
+	}
+	return s.logPut(coll, name)
+}
+
+// setExpiry sets or, for a zero time, removes the expiry of a key (caller
+// must hold mu)
+func (s *Server) setExpiry(name string, at time.Time) error {
+	coll, err := s.writableKeys()
+	if err != nil {
+		return err
+	}
+	updates := map[string]any{db.UpdateOpUnset: map[string]any{ExpiresField: ""}}
+	if !at.IsZero() {
+		updates = map[string]any{db.UpdateOpSet: map[string]any{ExpiresField: expiryValue(at)}}
+	}
+	if err := coll.Update(name, updates); err != nil {
+		return err
+	}
+	return s.logPut(coll, name)
+}
+
+// logPut logs the full document of a written key. It is logged as an
+// upsert, which replay stores as is, whether the write inserted or updated it.
+func (s *Server) logPut(coll *db.Collection, name string) error {
+	doc, err := coll.FindByID(name)
+	if err != nil {
+		return err
+	}
+	database := s.databases.Tenant(s.tenant).QualifiedName(s.database)
+	if err := s.storage.LogUpsert(database, coll.Name, doc); err != nil {
+		return fmt.Errorf("failed to log write: %w", err)
+	}
+	return nil
+}
+
+// remove deletes a key, reporting whether it was live (caller must hold mu)
+func (s *Server) remove(name string, now time.Time) (bool, error) {
+	if s.storage.IsReplica() {
+		return false, errReadOnly
+	}
+	k, err := s.lookup(name, now)
+	if err != nil {
+		return false, err
+	}
+	coll := s.keys()
+	if coll == nil {
+		return false, nil
+	}
+	if err := coll.Delete(name); err != nil {
+		if errors.Is(err, db.ErrNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	database := s.databases.Tenant(s.tenant).QualifiedName(s.database)
+	if err := s.storage.LogDelete(database, coll.Name, name); err != nil {
+		return false, fmt.Errorf("failed to log delete: %w", err)
+	}
+	return k != nil, nil
+}
+
+// matchGlob reports whether s matches a Redis glob pattern: * matches any
+// run of characters, ? any one character, [abc], [^a] and [a-z] sets of
+// characters, and \ escapes the next character
+func matchGlob(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchGlob(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			_, size := utf8.DecodeRuneInString(s)
+			pattern, s = pattern[1:], s[size:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+			r, size := utf8.DecodeRuneInString(s)
+			rest, ok := matchSet(pattern[1:], r)
+			if !ok {
+				return false
+			}
+			pattern, s = rest, s[size:]
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(s) == 0 || pattern[0] != s[0] {
+				return false
+			}
+			pattern, s = pattern[1:], s[1:]
+		}
+	}
+	return len(s) == 0
+}
+
+// matchSet matches r against the set at the start of pattern, just after
+// its '[', and returns the pattern after the set
+func matchSet(pattern string, r rune) (string, bool) {
+	negate := false
+	if len(pattern) > 0 && pattern[0] == '^' {
+		negate, pattern = true, pattern[1:]
+	}
+	matched := false
+	for len(pattern) > 0 && pattern[0] != ']' {
+		if pattern[0] == '\\' && len(pattern) > 1 {
+			pattern = pattern[1:]
+		}
+		lo, size := utf8.DecodeRuneInString(pattern)
+		pattern = pattern[size:]
+		hi := lo
+		if len(pattern) > 1 && pattern[0] == '-' && pattern[1] != ']' {
+			hi, size = utf8.DecodeRuneInString(pattern[1:])
+			pattern = pattern[1+size:]
+			if lo > hi {
+				lo, hi = hi, lo
+			}
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+	if len(pattern) > 0 {
+		pattern = pattern[1:] // the closing ']'
+	}
+	return pattern, matched != negate
+}
@@ -0,0 +1,253 @@
+// Package resp serves a key-value collection over the Redis protocol
+// (RESP2), so Redis clients can use CachyDB as a persistent cache. Each key
+// is a document of the collection, with the key as its _id, the value in a
+// "value" field and its expiry, if any, in an "expires_at" field under a
+// TTL index. Writes are logged to the WAL like those of the MCP tools.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// DefaultCollection is the collection keys are stored in
+const DefaultCollection = "kv"
+
+// Limits of a request, as in Redis
+const (
+	maxArgs    = 1024 * 1024
+	maxBulkLen = 512 << 20
+	maxInline  = 64 << 10
+)
+
+// Server serves the keys of a collection over the Redis protocol
+type Server struct {
+	databases *db.DatabaseManager
+	storage   *db.StorageManager
+	tenant    string
+	// database and collection hold the keys; both are created on the
+	// first write
+	database   string
+	collection string
+
+	// mu serializes writes, so that conditional writes such as SET NX see
+	// the keys as they are
+	mu sync.Mutex
+}
+
+// NewServer creates a server for the keys stored in collection of database,
+// in the databases of tenant
+func NewServer(databases *db.DatabaseManager, storage *db.StorageManager, tenant, database, collection string) *Server {
+	return &Server{
+		databases:  databases,
+		storage:    storage,
+		tenant:     tenant,
+		database:   database,
+		collection: collection,
+	}
+}
+
+// Serve listens on addr and serves clients until ctx ends
+func (s *Server) Serve(ctx context.Context, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen for RESP: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	conns := make(map[net.Conn]struct{})
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+	}()
+
+	log.Printf("CachyDB RESP server listening on %s (keys in %s/%s)\n", listener.Addr(), s.database, s.collection)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			wg.Wait()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("RESP server error: %w", err)
+		}
+
+		mu.Lock()
+		conns[conn] = struct{}{}
+		mu.Unlock()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.serveConn(conn)
+			mu.Lock()
+			delete(conns, conn)
+			mu.Unlock()
+		}()
+	}
+}
+
+// serveConn answers the commands of a client until it disconnects
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := &writer{bufio.NewWriter(conn)}
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			var protoErr *protocolError
+			if errors.As(err, &protoErr) {
+				// The stream cannot be parsed past a malformed request
+				w.writeError(fmt.Errorf("Protocol error: %s", protoErr.msg))
+				w.Flush()
+			} else if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				log.Printf("Warning: failed to read RESP command from %s: %v\n", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+
+		name := strings.ToUpper(string(args[0]))
+		if name == "QUIT" {
+			w.writeSimple("OK")
+			w.Flush()
+			return
+		}
+		if err := s.execute(w, name, args); err != nil {
+			w.writeError(err)
+		}
+
+		// Replies to pipelined commands are sent together
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// protocolError is a request that does not follow the protocol
+type protocolError struct {
+	msg string
+}
+
+func (e *protocolError) Error() string {
+	return e.msg
+}
+
+// readCommand reads a request: an array of bulk strings, or an inline
+// command of words separated by spaces as typed in telnet
+func readCommand(r *bufio.Reader) ([][]byte, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		var args [][]byte
+		for _, field := range strings.Fields(string(line)) {
+			args = append(args, []byte(field))
+		}
+		return args, nil
+	}
+
+	n, err := strconv.Atoi(string(line[1:]))
+	if err != nil || n > maxArgs {
+		return nil, &protocolError{"invalid multibulk length"}
+	}
+	args := make([][]byte, 0, max(n, 0))
+	for range n {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(line) == 0 || line[0] != '$' {
+			return nil, &protocolError{fmt.Sprintf("expected '$', got '%s'", line)}
+		}
+		size, err := strconv.Atoi(string(line[1:]))
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, &protocolError{"invalid bulk length"}
+		}
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(r, arg); err != nil {
+			return nil, err
+		}
+		args = append(args, arg[:size])
+	}
+	return args, nil
+}
+
+// readLine reads a line without its CRLF
+func readLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, chunk...)
+		if len(line) > maxInline {
+			return nil, &protocolError{"too big inline request"}
+		}
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}
+
+// writer writes RESP2 replies
+type writer struct {
+	*bufio.Writer
+}
+
+func (w *writer) writeSimple(s string) {
+	w.WriteString("+" + s + "\r\n")
+}
+
+// writeError writes err as an error reply. Errors starting with an
+// uppercase word, such as "WRONGTYPE ...", keep it as their code; others
+// get the generic ERR code.
+func (w *writer) writeError(err error) {
+	msg := strings.ReplaceAll(err.Error(), "\r\n", " ")
+	var codeErr *replyError
+	if !errors.As(err, &codeErr) {
+		msg = "ERR " + msg
+	}
+	w.WriteString("-" + msg + "\r\n")
+}
+
+func (w *writer) writeInt(n int64) {
+	w.WriteString(":" + strconv.FormatInt(n, 10) + "\r\n")
+}
+
+// writeBulk writes a bulk string, or the null bulk string for nil
+func (w *writer) writeBulk(b []byte) {
+	if b == nil {
+		w.WriteString("$-1\r\n")
+		return
+	}
+	w.WriteString("$" + strconv.Itoa(len(b)) + "\r\n")
+	w.Write(b)
+	w.WriteString("\r\n")
+}
+
+func (w *writer) writeArrayLen(n int) {
+	w.WriteString("*" + strconv.Itoa(n) + "\r\n")
+}
@@ -0,0 +1,521 @@
+// Package rest serves the documents of a CachyDB server over a plain JSON
+// HTTP API, for applications that do not speak MCP. Writes are logged to
+// the WAL like those of the MCP tools.
+//
+//	GET    /v1/databases
+//	GET    /v1/databases/{db}/collections
+//	GET    /v1/databases/{db}/collections/{coll}/documents?limit=&skip=
+//	POST   /v1/databases/{db}/collections/{coll}/documents
+//	POST   /v1/databases/{db}/collections/{coll}/documents/query
+//	POST   /v1/databases/{db}/collections/{coll}/documents/aggregate
+//	GET    /v1/databases/{db}/collections/{coll}/documents/{id}
+//	PATCH  /v1/databases/{db}/collections/{coll}/documents/{id}
+//	DELETE /v1/databases/{db}/collections/{coll}/documents/{id}
+//	POST   /v1/databases/{db}/topics/{topic}/messages
+//	GET    /v1/databases/{db}/topics/{topic}/messages?after=&limit=
+//	GET    /v1/databases/{db}/topics/{topic}/subscribe (WebSocket)
+//
+// Requests that list or query documents with "Accept: application/x-ndjson"
+// get the documents streamed one per line instead of in a single object.
+// With "Accept: application/vnd.apache.arrow.stream", documents and
+// aggregation results come as an Arrow IPC stream.
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"github.com/hop-/cachydb/pkg/db/exporter"
+)
+
+// PathPrefix is the HTTP path under which the API is served
+const PathPrefix = "/v1/"
+
+// TenantHeader selects the tenant of a request, as for the MCP HTTP transport
+const TenantHeader = "X-CachyDB-Tenant"
+
+// NDJSONType is the content type of streamed documents, one JSON object per line
+const NDJSONType = "application/x-ndjson"
+
+// maxBodySize bounds the size of request bodies
+const maxBodySize = 32 << 20
+
+// Handler serves the REST API
+type Handler struct {
+	databases *db.DatabaseManager
+	storage   *db.StorageManager
+	// tenant, if set, restricts every request to that tenant's databases
+	tenant        string
+	requireTenant bool
+	// readOnly refuses every write (see SetReadOnly)
+	readOnly bool
+	mux      *http.ServeMux
+}
+
+// NewHandler creates a handler for the databases of storage. If tenant is
+// not empty, all requests are restricted to that tenant's databases.
+func NewHandler(databases *db.DatabaseManager, storage *db.StorageManager, tenant string) *Handler {
+	h := &Handler{
+		databases: databases,
+		storage:   storage,
+		tenant:    tenant,
+		mux:       http.NewServeMux(),
+	}
+
+	const documents = "/v1/databases/{db}/collections/{coll}/documents"
+	h.mux.HandleFunc("GET /v1/databases", h.listDatabases)
+	h.mux.HandleFunc("GET /v1/databases/{db}/collections", h.listCollections)
+	h.mux.HandleFunc("GET "+documents, h.listDocuments)
+	h.mux.HandleFunc("POST "+documents, h.insertDocument)
+	h.mux.HandleFunc("POST "+documents+"/query", h.queryDocuments)
+	h.mux.HandleFunc("POST "+documents+"/aggregate", h.aggregateDocuments)
+	h.mux.HandleFunc("GET "+documents+"/{id}", h.getDocument)
+	h.mux.HandleFunc("PATCH "+documents+"/{id}", h.updateDocument)
+	h.mux.HandleFunc("DELETE "+documents+"/{id}", h.deleteDocument)
+
+	const topic = "/v1/databases/{db}/topics/{topic}"
+	h.mux.HandleFunc("POST "+topic+"/messages", h.publishMessage)
+	h.mux.HandleFunc("GET "+topic+"/messages", h.listMessages)
+	h.mux.HandleFunc("GET "+topic+"/subscribe", h.subscribe)
+	return h
+}
+
+// SetRequireTenant makes the handler reject requests without a tenant header
+// even while no tenant has databases
+func (h *Handler) SetRequireTenant(require bool) {
+	h.requireTenant = require
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// httpError is an error with the status it is reported with
+type httpError struct {
+	status int
+	err    error
+}
+
+func (e *httpError) Error() string {
+	return e.err.Error()
+}
+
+func (e *httpError) Unwrap() error {
+	return e.err
+}
+
+func errorf(status int, format string, args ...any) error {
+	return &httpError{status: status, err: fmt.Errorf(format, args...)}
+}
+
+// writeError responds with err as {"error": "..."} and the status it maps to
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	var httpErr *httpError
+	switch {
+	case errors.As(err, &httpErr):
+		status = httpErr.status
+	case errors.Is(err, db.ErrNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, db.ErrConflict):
+		status = http.StatusPreconditionFailed
+	case errors.Is(err, db.ErrDuplicateKey):
+		status = http.StatusConflict
+	case errors.Is(err, db.ErrReplica):
+		status = http.StatusForbidden
+	}
+	writeJSON(w, status, map[string]any{"error": err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Warning: failed to write REST response: %v\n", err)
+	}
+}
+
+// writeDocument responds with a document, its revision as the ETag
+func writeDocument(w http.ResponseWriter, status int, doc *db.Document) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(doc.Rev(), 10)))
+	writeJSON(w, status, doc)
+}
+
+// readJSON decodes the body of a request into v
+func readJSON(w http.ResponseWriter, r *http.Request, v any) error {
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v); err != nil {
+		return errorf(http.StatusBadRequest, "invalid JSON body: %v", err)
+	}
+	return nil
+}
+
+// scope returns the databases a request may use
+func (h *Handler) scope(r *http.Request) (*db.TenantScope, error) {
+	if h.tenant != "" {
+		return h.databases.Tenant(h.tenant), nil
+	}
+	tenant := r.Header.Get(TenantHeader)
+	if tenant == "" {
+		// Once tenants have databases, the unscoped view would expose them
+		if h.requireTenant || h.databases.HasTenants() {
+			return nil, errorf(http.StatusUnauthorized, "the %s header is required", TenantHeader)
+		}
+		return h.databases.Tenant(""), nil
+	}
+	if err := db.ValidateTenantName(tenant); err != nil {
+		return nil, &httpError{status: http.StatusBadRequest, err: err}
+	}
+	return h.databases.Tenant(tenant), nil
+}
+
+// database returns the database named in the path of a request
+func (h *Handler) database(r *http.Request) (*db.Database, error) {
+	scope, err := h.scope(r)
+	if err != nil {
+		return nil, err
+	}
+	name := r.PathValue("db")
+	database := scope.GetDatabase(name)
+	if database == nil {
+		return nil, errorf(http.StatusNotFound, "database '%s' not found", name)
+	}
+	return database, nil
+}
+
+// collection returns the database and collection named in the path of a request
+func (h *Handler) collection(r *http.Request) (*db.Database, *db.Collection, error) {
+	database, err := h.database(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	coll, err := database.GetCollection(r.PathValue("coll"))
+	if err != nil {
+		return nil, nil, &httpError{status: http.StatusNotFound, err: err}
+	}
+	return database, coll, nil
+}
+
+// SetReadOnly makes the handler refuse every request that changes data,
+// e.g. when the server only offers some MCP tools
+func (h *Handler) SetReadOnly(readOnly bool) {
+	h.readOnly = readOnly
+}
+
+// writable fails on a read-only handler, and on a read replica, whose data
+// only changes through its leader
+func (h *Handler) writable() error {
+	if h.readOnly {
+		return errorf(http.StatusForbidden, "the REST API is read-only on this server")
+	}
+	if h.storage.IsReplica() {
+		return errorf(http.StatusForbidden, "this server is a read replica; write to the leader")
+	}
+	return nil
+}
+
+// ifMatch returns the revision required by the If-Match header of a
+// request, or -1 if it has none
+func ifMatch(r *http.Request) (int64, error) {
+	value := r.Header.Get("If-Match")
+	if value == "" {
+		return -1, nil
+	}
+	rev, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+	if err != nil {
+		return 0, errorf(http.StatusBadRequest, "If-Match must be a document revision, not %s", value)
+	}
+	return rev, nil
+}
+
+func (h *Handler) listDatabases(w http.ResponseWriter, r *http.Request) {
+	scope, err := h.scope(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	names := scope.ListDatabases()
+	slices.Sort(names)
+	writeJSON(w, http.StatusOK, map[string]any{"databases": names})
+}
+
+func (h *Handler) listCollections(w http.ResponseWriter, r *http.Request) {
+	database, err := h.database(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	names := database.ListCollections()
+	slices.Sort(names)
+	writeJSON(w, http.StatusOK, map[string]any{"collections": names})
+}
+
+func (h *Handler) listDocuments(w http.ResponseWriter, r *http.Request) {
+	query := &db.Query{}
+	for param, limit := range map[string]*int{"limit": &query.Limit, "skip": &query.Skip} {
+		value := r.URL.Query().Get(param)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			writeError(w, errorf(http.StatusBadRequest, "%s must be a non-negative integer", param))
+			return
+		}
+		*limit = n
+	}
+	h.find(w, r, query)
+}
+
+func (h *Handler) queryDocuments(w http.ResponseWriter, r *http.Request) {
+	var query db.Query
+	if err := readJSON(w, r, &query); err != nil {
+		writeError(w, err)
+		return
+	}
+	if query.Filter != nil {
+		if err := query.Filter.Validate(); err != nil {
+			writeError(w, fmt.Errorf("invalid filter: %w", err))
+			return
+		}
+	}
+	h.find(w, r, &query)
+}
+
+func (h *Handler) find(w http.ResponseWriter, r *http.Request, query *db.Query) {
+	_, coll, err := h.collection(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	switch r.Header.Get("Accept") {
+	case NDJSONType:
+		stream(w, r, coll, query)
+		return
+	case exporter.ArrowStreamType:
+		writeArrow(w, func(out io.Writer) error {
+			_, err := exporter.WriteArrow(out, coll, &exporter.ArrowOptions{Query: query})
+			return err
+		})
+		return
+	}
+	docs, err := coll.Find(query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"documents": docs, "count": len(docs)})
+}
+
+func (h *Handler) aggregateDocuments(w http.ResponseWriter, r *http.Request) {
+	var agg db.Aggregation
+	if err := readJSON(w, r, &agg); err != nil {
+		writeError(w, err)
+		return
+	}
+	_, coll, err := h.collection(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	groups, err := coll.Aggregate(&agg)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if r.Header.Get("Accept") == exporter.ArrowStreamType {
+		writeArrow(w, func(out io.Writer) error {
+			return exporter.WriteArrowAggregate(out, &agg, groups)
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"groups": groups, "count": len(groups)})
+}
+
+// stream responds with the documents matching query one per line, reading
+// each from the collection as it is written rather than all up front
+func stream(w http.ResponseWriter, r *http.Request, coll *db.Collection, query *db.Query) {
+	docs, err := coll.FindIter(query)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", NDJSONType)
+	w.WriteHeader(http.StatusOK)
+
+	encoder := json.NewEncoder(w)
+	for doc := range docs {
+		if r.Context().Err() != nil {
+			return
+		}
+		if err := encoder.Encode(doc); err != nil {
+			// The status is sent already; the client sees the stream end
+			log.Printf("Warning: failed to stream documents: %v\n", err)
+			return
+		}
+	}
+}
+
+// writeArrow responds with the Arrow IPC stream written by write. An error
+// before anything is written is sent as a normal error response.
+func writeArrow(w http.ResponseWriter, write func(io.Writer) error) {
+	out := &arrowResponse{w: w}
+	if err := write(out); err != nil {
+		if !out.started {
+			writeError(w, err)
+			return
+		}
+		// The status is sent already; the client sees the stream end
+		log.Printf("Warning: failed to write Arrow stream: %v\n", err)
+	}
+}
+
+// arrowResponse sends the Arrow headers on the first write
+type arrowResponse struct {
+	w       http.ResponseWriter
+	started bool
+}
+
+func (a *arrowResponse) Write(p []byte) (int, error) {
+	if !a.started {
+		a.started = true
+		a.w.Header().Set("Content-Type", exporter.ArrowStreamType)
+		a.w.WriteHeader(http.StatusOK)
+	}
+	return a.w.Write(p)
+}
+
+func (h *Handler) getDocument(w http.ResponseWriter, r *http.Request) {
+	_, coll, err := h.collection(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	doc, err := coll.FindByID(r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeDocument(w, http.StatusOK, doc)
+}
+
+func (h *Handler) insertDocument(w http.ResponseWriter, r *http.Request) {
+	if err := h.writable(); err != nil {
+		writeError(w, err)
+		return
+	}
+	database, coll, err := h.collection(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var doc db.Document
+	if err := readJSON(w, r, &doc); err != nil {
+		writeError(w, err)
+		return
+	}
+	if doc.ID != "" {
+		if _, err := coll.FindByID(doc.ID); err == nil {
+			writeError(w, errorf(http.StatusConflict, "document with ID '%s' already exists", doc.ID))
+			return
+		}
+	}
+
+	if err := coll.Insert(&doc); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.storage.LogInsert(database.Name, coll.Name, &doc); err != nil {
+		writeError(w, errorf(http.StatusInternalServerError, "failed to log insert: %v", err))
+		return
+	}
+
+	w.Header().Set("Location", r.URL.Path+"/"+url.PathEscape(doc.ID))
+	writeDocument(w, http.StatusCreated, &doc)
+}
+
+// updateDocument sets the fields of the body, which takes the same forms as
+// the updates of Collection.Update, including update operators
+func (h *Handler) updateDocument(w http.ResponseWriter, r *http.Request) {
+	if err := h.writable(); err != nil {
+		writeError(w, err)
+		return
+	}
+	database, coll, err := h.collection(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	rev, err := ifMatch(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var updates map[string]any
+	if err := readJSON(w, r, &updates); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	if rev >= 0 {
+		err = coll.UpdateIfRev(id, rev, updates)
+	} else {
+		err = coll.Update(id, updates)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	doc, err := coll.FindByID(id)
+	if err != nil {
+		writeError(w, errorf(http.StatusInternalServerError, "failed to get updated document: %v", err))
+		return
+	}
+	if err := h.storage.LogUpdate(database.Name, coll.Name, doc); err != nil {
+		writeError(w, errorf(http.StatusInternalServerError, "failed to log update: %v", err))
+		return
+	}
+	writeDocument(w, http.StatusOK, doc)
+}
+
+func (h *Handler) deleteDocument(w http.ResponseWriter, r *http.Request) {
+	if err := h.writable(); err != nil {
+		writeError(w, err)
+		return
+	}
+	database, coll, err := h.collection(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	rev, err := ifMatch(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	if rev >= 0 {
+		err = coll.DeleteIfRev(id, rev)
+	} else {
+		err = coll.Delete(id)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := h.storage.LogDelete(database.Name, coll.Name, id); err != nil {
+		writeError(w, errorf(http.StatusInternalServerError, "failed to log delete: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,178 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/hop-/cachydb/pkg/db"
+	"golang.org/x/net/websocket"
+)
+
+// publishRequest is the body of a publish, and a message a WebSocket
+// client sends to publish
+type publishRequest struct {
+	// Topic is only read from WebSocket clients, defaulting to the topic of
+	// the connection
+	Topic   string `json:"topic,omitempty"`
+	Payload any    `json:"payload"`
+	Persist bool   `json:"persist,omitempty"`
+}
+
+func (h *Handler) publishMessage(w http.ResponseWriter, r *http.Request) {
+	if err := h.writable(); err != nil {
+		writeError(w, err)
+		return
+	}
+	database, err := h.database(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var req publishRequest
+	if err := readJSON(w, r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	msg, err := h.publish(database, r.PathValue("topic"), &req)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, msg)
+}
+
+func (h *Handler) listMessages(w http.ResponseWriter, r *http.Request) {
+	database, err := h.database(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil || limit < 0 {
+			writeError(w, errorf(http.StatusBadRequest, "limit must be a non-negative integer"))
+			return
+		}
+	}
+
+	messages, err := database.Messages(r.PathValue("topic"), r.URL.Query().Get("after"), limit)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"messages": messages, "count": len(messages)})
+}
+
+// subscribe streams the messages of a topic to a WebSocket client, one
+// JSON message per frame. With ?after=ID the persisted messages after that
+// one are sent first. The client may publish by sending messages of its
+// own, as in the body of a publish with an optional topic.
+func (h *Handler) subscribe(w http.ResponseWriter, r *http.Request) {
+	database, err := h.database(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	topic := r.PathValue("topic")
+	messages, cancel, err := database.Subscribe(topic)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer cancel()
+
+	// Any origin is accepted, as for the other endpoints
+	server := websocket.Server{Handler: func(ws *websocket.Conn) {
+		h.serveSubscription(ws, database, topic, r.URL.Query().Get("after"), messages, cancel)
+	}}
+	server.ServeHTTP(w, r)
+}
+
+// serveSubscription sends the messages of a subscription to a WebSocket
+// client and publishes those it sends, until either side closes
+func (h *Handler) serveSubscription(ws *websocket.Conn, database *db.Database, topic, after string, messages <-chan *db.Message, cancel func()) {
+	var sendMu sync.Mutex
+	send := func(v any) error {
+		sendMu.Lock()
+		defer sendMu.Unlock()
+		return websocket.JSON.Send(ws, v)
+	}
+
+	go func() {
+		// Ends the subscription, and so the sends, once the client is gone
+		defer cancel()
+		for {
+			var req publishRequest
+			if err := websocket.JSON.Receive(ws, &req); err != nil {
+				if !errors.Is(err, io.EOF) {
+					log.Printf("Warning: failed to read WebSocket message: %v\n", err)
+				}
+				return
+			}
+			if req.Topic == "" {
+				req.Topic = topic
+			}
+			err := h.writable()
+			if err == nil {
+				_, err = h.publish(database, req.Topic, &req)
+			}
+			if err != nil {
+				if err := send(map[string]any{"error": err.Error()}); err != nil {
+					return
+				}
+			}
+		}
+	}()
+
+	// Subscribed before reading the persisted messages, so none published
+	// in between is missed; the ones in both are sent once
+	last := after
+	if after != "" {
+		backlog, err := database.Messages(topic, after, 0)
+		if err != nil {
+			send(map[string]any{"error": err.Error()}) //nolint:errcheck
+			return
+		}
+		for _, msg := range backlog {
+			if err := send(msg); err != nil {
+				return
+			}
+			last = msg.ID
+		}
+	}
+	for msg := range messages {
+		if msg.ID <= last {
+			continue
+		}
+		if err := send(msg); err != nil {
+			return
+		}
+	}
+}
+
+// publish publishes a message to a topic of a database and logs it
+func (h *Handler) publish(database *db.Database, topic string, req *publishRequest) (*db.Message, error) {
+	if req.Persist {
+		if _, err := database.GetCollection(db.MessagesCollection); err != nil {
+			if err := database.CreateCollection(db.MessagesCollection, nil); err == nil {
+				if err := h.storage.LogCreateCollection(database.Name, db.MessagesCollection, nil); err != nil {
+					return nil, errorf(http.StatusInternalServerError, "failed to log create collection: %v", err)
+				}
+			}
+		}
+	}
+	msg, err := database.Publish(topic, req.Payload, req.Persist)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.storage.LogMessage(database, msg); err != nil {
+		return nil, &httpError{status: http.StatusInternalServerError, err: fmt.Errorf("failed to log message: %w", err)}
+	}
+	return msg, nil
+}
@@ -0,0 +1,26 @@
+// Package service runs CachyDB as a managed background service: a systemd unit
+// on Linux and a Service Control Manager service on Windows.
+package service
+
+import (
+	"context"
+	"errors"
+)
+
+// DefaultName is the service name used when none is given
+const DefaultName = "cachydb"
+
+// ErrUnsupported is returned on platforms without service integration
+var ErrUnsupported = errors.New("service integration is not supported on this platform")
+
+// Config describes a service to install
+type Config struct {
+	Name        string
+	Description string
+	Executable  string   // absolute path of the binary to run
+	Args        []string // arguments passed to the binary
+}
+
+// RunFunc runs the service until ctx is cancelled. It must call ready once
+// the service is able to serve requests.
+type RunFunc func(ctx context.Context, ready func()) error
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// UnitDir is where systemd unit files are installed
+const UnitDir = "/etc/systemd/system"
+
+// Install writes a systemd unit for the service and enables it
+func Install(cfg Config) error {
+	unit := fmt.Sprintf(`[Unit]
+Description=%s
+After=network.target
+
+[Service]
+Type=notify
+ExecStart=%s
+Restart=on-failure
+RestartSec=5
+
+[Install]
+WantedBy=multi-user.target
+`, cfg.Description, execLine(cfg.Executable, cfg.Args))
+
+	path := unitPath(cfg.Name)
+	if err := os.WriteFile(path, []byte(unit), 0644); err != nil {
+		return fmt.Errorf("failed to write unit file: %w", err)
+	}
+
+	if err := systemctl("daemon-reload"); err != nil {
+		return err
+	}
+	return systemctl("enable", cfg.Name)
+}
+
+// Uninstall stops and disables the service and removes its unit file
+func Uninstall(name string) error {
+	path := unitPath(name)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("service '%s' is not installed: %w", name, err)
+	}
+
+	if err := systemctl("disable", "--now", name); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("failed to remove unit file: %w", err)
+	}
+	return systemctl("daemon-reload")
+}
+
+// Run runs the service until SIGINT or SIGTERM, reporting readiness and
+// shutdown to systemd through sd_notify when started by a Type=notify unit
+func Run(name string, run RunFunc) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		<-ctx.Done()
+		sdNotify("STOPPING=1")
+	}()
+
+	return run(ctx, func() {
+		sdNotify("READY=1")
+	})
+}
+
+// sdNotify sends a state update to systemd. It is a no-op when the
+// process was not started by systemd.
+func sdNotify(state string) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return
+	}
+	if strings.HasPrefix(socket, "@") {
+		socket = "\x00" + socket[1:] // abstract namespace socket
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	conn.Write([]byte(state)) //nolint:errcheck
+}
+
+func unitPath(name string) string {
+	return filepath.Join(UnitDir, name+".service")
+}
+
+func systemctl(args ...string) error {
+	out, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// execLine quotes the command line for ExecStart
+func execLine(executable string, args []string) string {
+	parts := make([]string, 0, len(args)+1)
+	for _, arg := range append([]string{executable}, args...) {
+		if strings.ContainsAny(arg, " \t\"'\\") {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+		parts = append(parts, arg)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,26 @@
+//go:build !linux && !windows
+
+package service
+
+import (
+	"context"
+	"os/signal"
+	"syscall"
+)
+
+// Install is not supported on this platform
+func Install(cfg Config) error {
+	return ErrUnsupported
+}
+
+// Uninstall is not supported on this platform
+func Uninstall(name string) error {
+	return ErrUnsupported
+}
+
+// Run runs in the foreground until interrupted
+func Run(name string, run RunFunc) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	return run(ctx, func() {})
+}
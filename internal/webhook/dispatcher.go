@@ -0,0 +1,507 @@
+package webhook
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// Headers of webhook requests
+const (
+	// WebhookHeader holds the ID of the rule that triggered the request
+	WebhookHeader = "X-CachyDB-Webhook"
+	// DeliveryHeader holds the ID of the delivery. A delivery is sent until
+	// its endpoint accepts it, so receivers use it to drop duplicates.
+	DeliveryHeader = "X-CachyDB-Delivery"
+	// SignatureHeader holds "sha256=" and the hex HMAC-SHA256 of the body,
+	// keyed with the rule's secret, for rules that have one
+	SignatureHeader = "X-CachyDB-Signature"
+)
+
+// MaxAttempts is how many times a delivery is sent before it is marked
+// failed and left in the queue for inspection
+const MaxAttempts = 10
+
+const (
+	// cursorID is the document of QueueCollection holding the offset of the
+	// first WAL entry not yet checked against the rules
+	cursorID = "cursor"
+	// cursorSaveInterval bounds how often the cursor is saved while no
+	// change triggers a webhook
+	cursorSaveInterval = 5 * time.Second
+	// pollInterval is how often the queues are checked for deliveries due
+	pollInterval = time.Second
+	// maxRetryDelay bounds the exponential backoff between attempts
+	maxRetryDelay = time.Hour
+)
+
+// Delivery is a change queued to be POSTed to the endpoint of a rule
+type Delivery struct {
+	ID          string         `json:"-"`
+	Webhook     string         `json:"webhook"`
+	Event       db.ChangeEvent `json:"event"`
+	Attempts    int            `json:"attempts"`
+	NextAttempt time.Time      `json:"next_attempt"`
+	LastError   string         `json:"last_error,omitempty"`
+	// Failed marks a delivery that was attempted MaxAttempts times
+	Failed bool `json:"failed,omitempty"`
+}
+
+// Dispatcher checks every change logged to the WAL against the webhook
+// rules of its database, queues a delivery for each rule it triggers, and
+// sends the queued deliveries, retrying with backoff until their endpoint
+// responds with a 2xx status. A delivery is queued in the same transaction
+// that records how far the WAL was checked, so after a crash the changes
+// are checked again from there and none is lost or queued twice. It is
+// only sent at least once, though: a crash after the endpoint accepted it
+// but before it was removed sends it again.
+type Dispatcher struct {
+	databases *db.DatabaseManager
+	storage   *db.StorageManager
+	client    *http.Client
+	wake      chan struct{}
+
+	// State of the WAL reader, only used by its goroutine
+	rules     map[string][]*Rule // database -> rules, loaded when first needed
+	cursors   map[string]uint64  // database -> next offset to check
+	lastSaves map[string]time.Time
+}
+
+// NewDispatcher creates a dispatcher for the databases of storage
+func NewDispatcher(databases *db.DatabaseManager, storage *db.StorageManager) *Dispatcher {
+	return &Dispatcher{
+		databases: databases,
+		storage:   storage,
+		client:    &http.Client{Timeout: 10 * time.Second},
+		wake:      make(chan struct{}, 1),
+		rules:     make(map[string][]*Rule),
+		cursors:   make(map[string]uint64),
+		lastSaves: make(map[string]time.Time),
+	}
+}
+
+// Start checks changes and sends deliveries in the background until ctx
+// ends. A replica does neither until it is promoted, as it gets the queue
+// of its leader.
+func (d *Dispatcher) Start(ctx context.Context) {
+	go d.queueChanges(ctx)
+	go d.sendQueued(ctx)
+}
+
+// queueChanges follows the WAL and queues the deliveries of the changes
+func (d *Dispatcher) queueChanges(ctx context.Context) {
+	for {
+		if !d.waitForLeader(ctx) {
+			return
+		}
+
+		from := d.startOffset()
+		err := d.storage.WAL.Follow(ctx, from, d.checkEntry)
+		if ctx.Err() != nil {
+			return
+		}
+		if errors.Is(err, db.ErrWALTruncated) {
+			// The changes in the gap are gone; carry on after it
+			next := d.storage.WAL.NextOffset()
+			log.Printf("Warning: webhooks skip the changes up to WAL offset %d: %v\n", next, err)
+			for database := range d.cursors {
+				d.cursors[database] = max(d.cursors[database], next)
+			}
+			continue
+		}
+		log.Printf("Warning: failed to check changes for webhooks: %v; retrying in %s\n", err, cursorSaveInterval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(cursorSaveInterval):
+		}
+	}
+}
+
+// waitForLeader waits until the storage takes writes; it returns false if
+// ctx ends first
+func (d *Dispatcher) waitForLeader(ctx context.Context) bool {
+	for d.storage.IsReplica() {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(pollInterval):
+		}
+	}
+	return true
+}
+
+// startOffset loads the cursors of the databases with webhooks and returns
+// the oldest, or the next offset if no database has webhooks
+func (d *Dispatcher) startOffset() uint64 {
+	from := d.storage.WAL.NextOffset()
+	for _, name := range d.databases.ListDatabases() {
+		database := d.databases.GetDatabase(name)
+		if database == nil {
+			continue
+		}
+		rules, err := d.rulesOf(database)
+		if err != nil || len(rules) == 0 {
+			continue
+		}
+
+		cursor, ok := d.cursors[name]
+		if !ok {
+			cursor = readCursor(database, rules)
+			d.cursors[name] = cursor
+		}
+		from = min(from, cursor)
+	}
+	return from
+}
+
+// readCursor returns the saved cursor of a database, or, if none was saved
+// yet, the offset its oldest rule was created at
+func readCursor(database *db.Database, rules []*Rule) uint64 {
+	if coll, err := database.GetCollection(QueueCollection); err == nil {
+		if doc, err := coll.FindByID(cursorID); err == nil {
+			var cursor struct {
+				Offset uint64 `json:"offset"`
+			}
+			if decode(doc, &cursor) == nil {
+				return cursor.Offset
+			}
+		}
+	}
+
+	since := rules[0].Since
+	for _, rule := range rules[1:] {
+		since = min(since, rule.Since)
+	}
+	return since
+}
+
+// rulesOf returns the rules of a database, loading them when first needed
+func (d *Dispatcher) rulesOf(database *db.Database) ([]*Rule, error) {
+	if rules, ok := d.rules[database.Name]; ok {
+		return rules, nil
+	}
+	rules, err := Rules(database)
+	if err != nil {
+		return nil, err
+	}
+	d.rules[database.Name] = rules
+	return rules, nil
+}
+
+// checkEntry queues the deliveries of the changes a WAL entry logs
+func (d *Dispatcher) checkEntry(entry *db.WALEntry) error {
+	switch entry.Operation {
+	case db.WALOpDeleteDatabase, db.WALOpCreateDatabase:
+		d.forget(entry.Database)
+		return nil
+	case db.WALOpCreateCollection, db.WALOpDeleteCollection:
+		if IsSystemCollection(entry.Collection) {
+			d.forget(entry.Database)
+		}
+		return nil
+	}
+
+	events, err := db.ChangeEvents(entry)
+	if err != nil {
+		log.Printf("Warning: webhooks skip WAL entry at offset %d: %v\n", entry.Offset, err)
+		return nil
+	}
+	var changes []db.ChangeEvent
+	for _, event := range events {
+		switch event.Collection {
+		case RulesCollection:
+			// Rules change in WAL order, so later changes see them
+			delete(d.rules, entry.Database)
+		case QueueCollection:
+		default:
+			changes = append(changes, event)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	database := d.databases.GetDatabase(entry.Database)
+	if database == nil {
+		return nil
+	}
+	rules, err := d.rulesOf(database)
+	if err != nil {
+		log.Printf("Warning: failed to load the webhooks of database '%s': %v\n", entry.Database, err)
+		return nil
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	cursor, ok := d.cursors[entry.Database]
+	if !ok {
+		cursor = readCursor(database, rules)
+	}
+	if entry.Offset < cursor {
+		return nil // queued before a restart
+	}
+
+	var deliveries []*Delivery
+	for i := range changes {
+		for _, rule := range rules {
+			if !rule.Matches(&changes[i]) {
+				continue
+			}
+			event := changes[i]
+			event.Database = rule.Database
+			deliveries = append(deliveries, &Delivery{
+				ID:          uuid.New().String(),
+				Webhook:     rule.ID,
+				Event:       event,
+				NextAttempt: time.Now().UTC(),
+			})
+		}
+	}
+	if len(deliveries) == 0 && time.Since(d.lastSaves[entry.Database]) < cursorSaveInterval {
+		d.cursors[entry.Database] = cursor
+		return nil
+	}
+
+	if err := d.queue(database, deliveries, entry.Offset+1); err != nil {
+		log.Printf("Warning: failed to queue webhook deliveries for WAL entry at offset %d: %v\n", entry.Offset, err)
+		return nil
+	}
+	d.cursors[entry.Database] = entry.Offset + 1
+	d.lastSaves[entry.Database] = time.Now()
+	if len(deliveries) > 0 {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// forget drops what is cached about a database
+func (d *Dispatcher) forget(database string) {
+	delete(d.rules, database)
+	delete(d.cursors, database)
+	delete(d.lastSaves, database)
+}
+
+// queue adds deliveries to the queue of database and moves its cursor to
+// offset, all in one transaction
+func (d *Dispatcher) queue(database *db.Database, deliveries []*Delivery, offset uint64) error {
+	coll, err := database.GetCollection(QueueCollection)
+	if err != nil {
+		return err
+	}
+
+	tx := db.NewTransaction(database.Name)
+	for _, delivery := range deliveries {
+		data, err := encode(delivery)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Insert(coll, &db.Document{ID: delivery.ID, Data: data}); err != nil {
+			return err
+		}
+	}
+	cursor := map[string]any{"offset": offset}
+	if _, err := coll.FindByID(cursorID); err == nil {
+		err = tx.Update(coll, cursorID, cursor)
+	} else {
+		_, err = tx.Insert(coll, &db.Document{ID: cursorID, Data: cursor})
+	}
+	if err != nil {
+		return err
+	}
+
+	results, err := tx.Commit()
+	if err != nil {
+		return err
+	}
+	return d.storage.LogTransaction(database.Name, results)
+}
+
+// sendQueued sends the deliveries that are due, every pollInterval or when
+// new ones are queued
+func (d *Dispatcher) sendQueued(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+		if d.storage.IsReplica() {
+			continue
+		}
+
+		names := d.databases.ListDatabases()
+		slices.Sort(names)
+		for _, name := range names {
+			if database := d.databases.GetDatabase(name); database != nil {
+				d.sendDue(ctx, database)
+			}
+		}
+	}
+}
+
+// sendDue sends the due deliveries of a database in the order of their changes
+func (d *Dispatcher) sendDue(ctx context.Context, database *db.Database) {
+	coll, err := database.GetCollection(QueueCollection)
+	if err != nil {
+		return
+	}
+	deliveries, err := Deliveries(database)
+	if err != nil {
+		log.Printf("Warning: failed to read the webhook queue of database '%s': %v\n", database.Name, err)
+		return
+	}
+
+	now := time.Now()
+	var due []*Delivery
+	for _, delivery := range deliveries {
+		if !delivery.Failed && !delivery.NextAttempt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+
+	rules, err := Rules(database)
+	if err != nil {
+		log.Printf("Warning: failed to load the webhooks of database '%s': %v\n", database.Name, err)
+		return
+	}
+	for _, delivery := range due {
+		if ctx.Err() != nil {
+			return
+		}
+		i := slices.IndexFunc(rules, func(rule *Rule) bool { return rule.ID == delivery.Webhook })
+		if i < 0 {
+			d.remove(database, coll, delivery) // the rule was deleted
+			continue
+		}
+
+		if err := d.send(ctx, rules[i], delivery); err != nil {
+			d.retry(database, coll, delivery, err)
+			continue
+		}
+		d.remove(database, coll, delivery)
+	}
+}
+
+// Deliveries returns the queued deliveries of database, including the
+// failed ones, in the order of their changes
+func Deliveries(database *db.Database) ([]*Delivery, error) {
+	coll, err := database.GetCollection(QueueCollection)
+	if err != nil {
+		return nil, nil // no webhooks yet
+	}
+	docs, err := coll.Find(&db.Query{})
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]*Delivery, 0, len(docs))
+	for _, doc := range docs {
+		if doc.ID == cursorID {
+			continue
+		}
+		delivery := &Delivery{ID: doc.ID}
+		if err := decode(doc, delivery); err != nil {
+			return nil, fmt.Errorf("invalid webhook delivery '%s': %w", doc.ID, err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	slices.SortFunc(deliveries, func(a, b *Delivery) int {
+		if a.Event.Offset != b.Event.Offset {
+			return cmp.Compare(a.Event.Offset, b.Event.Offset)
+		}
+		return a.NextAttempt.Compare(b.NextAttempt)
+	})
+	return deliveries, nil
+}
+
+// send POSTs a delivery to the endpoint of its rule
+func (d *Dispatcher) send(ctx context.Context, rule *Rule, delivery *Delivery) error {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rule.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookHeader, rule.ID)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	if rule.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(rule.Secret))
+		mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096)) //nolint:errcheck // lets the connection be reused
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return nil
+}
+
+// remove deletes a delivery that was accepted or whose rule is gone
+func (d *Dispatcher) remove(database *db.Database, coll *db.Collection, delivery *Delivery) {
+	if err := coll.Delete(delivery.ID); err != nil {
+		return // removed concurrently, e.g. by a user
+	}
+	if err := d.storage.LogDelete(database.Name, QueueCollection, delivery.ID); err != nil {
+		log.Printf("Warning: failed to log the removal of webhook delivery '%s': %v\n", delivery.ID, err)
+	}
+}
+
+// retry records a failed attempt and schedules the next one, or marks the
+// delivery failed after MaxAttempts
+func (d *Dispatcher) retry(database *db.Database, coll *db.Collection, delivery *Delivery, cause error) {
+	delivery.Attempts++
+	delivery.LastError = cause.Error()
+	delivery.NextAttempt = time.Now().UTC().Add(min(time.Second<<(delivery.Attempts-1), maxRetryDelay))
+	if delivery.Attempts >= MaxAttempts {
+		delivery.Failed = true
+		log.Printf("Warning: webhook delivery '%s' failed %d times and is given up: %v\n", delivery.ID, delivery.Attempts, cause)
+	}
+
+	data, err := encode(delivery)
+	if err == nil {
+		err = coll.Update(delivery.ID, data)
+	}
+	if err != nil {
+		return // removed concurrently, e.g. by a user
+	}
+	// Logged whole, so replay restores it as it is now
+	doc, err := coll.FindByID(delivery.ID)
+	if err == nil {
+		err = d.storage.LogUpsert(database.Name, QueueCollection, doc)
+	}
+	if err != nil {
+		log.Printf("Warning: failed to log the attempt of webhook delivery '%s': %v\n", delivery.ID, err)
+	}
+}
@@ -0,0 +1,225 @@
+// Package webhook POSTs document changes to HTTP endpoints. Webhook rules
+// and the queue of pending deliveries are kept in system collections of
+// each database, so they are logged, saved and replicated like any other
+// data, and a delivery that was queued survives a crash until its endpoint
+// accepts it.
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// System collections of a database that has webhooks
+const (
+	// RulesCollection holds the webhook rules of a database
+	RulesCollection = "_webhooks"
+	// QueueCollection holds the deliveries not yet accepted by their
+	// endpoint, and the offset up to which changes were checked against
+	// the rules
+	QueueCollection = "_webhook_queue"
+)
+
+// IsSystemCollection reports whether a collection is managed by the webhook
+// subsystem. Changes to those collections never trigger webhooks.
+func IsSystemCollection(name string) bool {
+	return name == RulesCollection || name == QueueCollection
+}
+
+// Rule selects the changes of a collection that are POSTed to an endpoint
+type Rule struct {
+	ID string `json:"-"`
+	// Database is the database name the rule was created with, which is
+	// the name sent in change events
+	Database   string `json:"database"`
+	Collection string `json:"collection"`
+	// Operations limits the rule to insert, update, upsert or delete
+	// changes (empty: all of them)
+	Operations []string `json:"operations,omitempty"`
+	// Filter limits the rule to changes whose stored document matches it,
+	// so deletes only match rules without one
+	Filter *db.Filter `json:"filter,omitempty"`
+	URL    string     `json:"url"`
+	// Secret, if set, signs each request with an HMAC-SHA256 of its body
+	// (see SignatureHeader)
+	Secret string `json:"secret,omitempty"`
+	// Since is the WAL offset the rule was created at; earlier changes do
+	// not trigger it
+	Since   uint64    `json:"since"`
+	Created time.Time `json:"created"`
+}
+
+// Validate checks the URL, operations and filter of a rule
+func (r *Rule) Validate() error {
+	if r.Collection == "" {
+		return fmt.Errorf("collection is required")
+	}
+	if IsSystemCollection(r.Collection) {
+		return fmt.Errorf("collection '%s' is a system collection", r.Collection)
+	}
+	endpoint, err := url.Parse(r.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return fmt.Errorf("invalid webhook URL '%s': an http or https URL is required", r.URL)
+	}
+	for _, op := range r.Operations {
+		switch op {
+		case db.WALOpInsert, db.WALOpUpdate, db.WALOpUpsert, db.WALOpDelete:
+		default:
+			return fmt.Errorf("unknown operation '%s' (use insert, update, upsert or delete)", op)
+		}
+	}
+	if r.Filter != nil {
+		if err := r.Filter.Validate(); err != nil {
+			return fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether a change triggers the rule
+func (r *Rule) Matches(event *db.ChangeEvent) bool {
+	if event.Collection != r.Collection || event.Offset < r.Since {
+		return false
+	}
+	if len(r.Operations) > 0 && !slices.Contains(r.Operations, event.Operation) {
+		return false
+	}
+	if r.Filter != nil {
+		return event.Document != nil && r.Filter.Matches(event.Document)
+	}
+	return true
+}
+
+// AddRule stores a new rule in the rules collection of database, creating
+// the system collections if needed, and returns its ID. The rule applies
+// to changes logged from now on.
+func AddRule(storage *db.StorageManager, database *db.Database, rule *Rule) (string, error) {
+	if err := rule.Validate(); err != nil {
+		return "", err
+	}
+	coll, err := database.GetCollection(rule.Collection)
+	if err != nil {
+		return "", err
+	}
+	if coll.Ephemeral {
+		return "", fmt.Errorf("collection '%s' is ephemeral; its changes are not logged, so they cannot trigger webhooks", rule.Collection)
+	}
+	if err := ensureCollections(storage, database); err != nil {
+		return "", err
+	}
+
+	rule.ID = uuid.New().String()
+	rule.Since = storage.WAL.NextOffset()
+	rule.Created = time.Now().UTC()
+	if err := insert(storage, database, RulesCollection, rule.ID, rule); err != nil {
+		return "", err
+	}
+	return rule.ID, nil
+}
+
+// Rules returns the rules of database
+func Rules(database *db.Database) ([]*Rule, error) {
+	coll, err := database.GetCollection(RulesCollection)
+	if err != nil {
+		return nil, nil // no webhooks yet
+	}
+	docs, err := coll.Find(&db.Query{})
+	if err != nil {
+		return nil, err
+	}
+
+	rules := make([]*Rule, 0, len(docs))
+	for _, doc := range docs {
+		rule := &Rule{ID: doc.ID}
+		if err := decode(doc, rule); err != nil {
+			return nil, fmt.Errorf("invalid webhook rule '%s': %w", doc.ID, err)
+		}
+		rules = append(rules, rule)
+	}
+	slices.SortFunc(rules, func(a, b *Rule) int { return a.Created.Compare(b.Created) })
+	return rules, nil
+}
+
+// DeleteRule removes a rule from database. Its queued deliveries are
+// dropped instead of sent.
+func DeleteRule(storage *db.StorageManager, database *db.Database, id string) error {
+	coll, err := database.GetCollection(RulesCollection)
+	if err != nil {
+		return fmt.Errorf("webhook '%s' not found", id)
+	}
+	if err := coll.Delete(id); err != nil {
+		return fmt.Errorf("webhook '%s' not found", id)
+	}
+	if err := storage.LogDelete(database.Name, RulesCollection, id); err != nil {
+		return fmt.Errorf("failed to log delete: %w", err)
+	}
+	return nil
+}
+
+// ensureCollections creates the system collections of database
+func ensureCollections(storage *db.StorageManager, database *db.Database) error {
+	for _, name := range []string{RulesCollection, QueueCollection} {
+		if _, err := database.GetCollection(name); err == nil {
+			continue
+		}
+		if err := database.CreateCollection(name, nil); err != nil {
+			// Created concurrently
+			if _, getErr := database.GetCollection(name); getErr == nil {
+				continue
+			}
+			return err
+		}
+		if err := storage.LogCreateCollection(database.Name, name, nil); err != nil {
+			return fmt.Errorf("failed to log create collection: %w", err)
+		}
+	}
+	return nil
+}
+
+// insert stores v as a new document of a system collection
+func insert(storage *db.StorageManager, database *db.Database, collName, id string, v any) error {
+	coll, err := database.GetCollection(collName)
+	if err != nil {
+		return err
+	}
+	data, err := encode(v)
+	if err != nil {
+		return err
+	}
+	doc := &db.Document{ID: id, Data: data}
+	if err := coll.Insert(doc); err != nil {
+		return err
+	}
+	if err := storage.LogInsert(database.Name, collName, doc); err != nil {
+		return fmt.Errorf("failed to log insert: %w", err)
+	}
+	return nil
+}
+
+// encode converts v to document fields
+func encode(v any) (map[string]any, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]any
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// decode converts the fields of a document to v
+func decode(doc *db.Document, v any) error {
+	raw, err := json.Marshal(doc.Data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
@@ -0,0 +1,1332 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: cachydb/v1/cachydb.proto
+
+package cachydbpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type ListDatabasesRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDatabasesRequest) Reset() {
+	*x = ListDatabasesRequest{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDatabasesRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDatabasesRequest) ProtoMessage() {}
+
+func (x *ListDatabasesRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDatabasesRequest.ProtoReflect.Descriptor instead.
+func (*ListDatabasesRequest) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{0}
+}
+
+type ListDatabasesResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Databases     []string               `protobuf:"bytes,1,rep,name=databases,proto3" json:"databases,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListDatabasesResponse) Reset() {
+	*x = ListDatabasesResponse{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListDatabasesResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListDatabasesResponse) ProtoMessage() {}
+
+func (x *ListDatabasesResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListDatabasesResponse.ProtoReflect.Descriptor instead.
+func (*ListDatabasesResponse) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ListDatabasesResponse) GetDatabases() []string {
+	if x != nil {
+		return x.Databases
+	}
+	return nil
+}
+
+type CreateDatabaseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateDatabaseRequest) Reset() {
+	*x = CreateDatabaseRequest{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateDatabaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateDatabaseRequest) ProtoMessage() {}
+
+func (x *CreateDatabaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateDatabaseRequest.ProtoReflect.Descriptor instead.
+func (*CreateDatabaseRequest) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CreateDatabaseRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type CreateDatabaseResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateDatabaseResponse) Reset() {
+	*x = CreateDatabaseResponse{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateDatabaseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateDatabaseResponse) ProtoMessage() {}
+
+func (x *CreateDatabaseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateDatabaseResponse.ProtoReflect.Descriptor instead.
+func (*CreateDatabaseResponse) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{3}
+}
+
+type DeleteDatabaseRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteDatabaseRequest) Reset() {
+	*x = DeleteDatabaseRequest{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteDatabaseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteDatabaseRequest) ProtoMessage() {}
+
+func (x *DeleteDatabaseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteDatabaseRequest.ProtoReflect.Descriptor instead.
+func (*DeleteDatabaseRequest) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *DeleteDatabaseRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type DeleteDatabaseResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteDatabaseResponse) Reset() {
+	*x = DeleteDatabaseResponse{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteDatabaseResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteDatabaseResponse) ProtoMessage() {}
+
+func (x *DeleteDatabaseResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteDatabaseResponse.ProtoReflect.Descriptor instead.
+func (*DeleteDatabaseResponse) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{5}
+}
+
+type ListCollectionsRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCollectionsRequest) Reset() {
+	*x = ListCollectionsRequest{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCollectionsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCollectionsRequest) ProtoMessage() {}
+
+func (x *ListCollectionsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCollectionsRequest.ProtoReflect.Descriptor instead.
+func (*ListCollectionsRequest) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ListCollectionsRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+type ListCollectionsResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Collections   []string               `protobuf:"bytes,1,rep,name=collections,proto3" json:"collections,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ListCollectionsResponse) Reset() {
+	*x = ListCollectionsResponse{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ListCollectionsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ListCollectionsResponse) ProtoMessage() {}
+
+func (x *ListCollectionsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ListCollectionsResponse.ProtoReflect.Descriptor instead.
+func (*ListCollectionsResponse) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ListCollectionsResponse) GetCollections() []string {
+	if x != nil {
+		return x.Collections
+	}
+	return nil
+}
+
+type CreateCollectionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateCollectionRequest) Reset() {
+	*x = CreateCollectionRequest{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateCollectionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCollectionRequest) ProtoMessage() {}
+
+func (x *CreateCollectionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCollectionRequest.ProtoReflect.Descriptor instead.
+func (*CreateCollectionRequest) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *CreateCollectionRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *CreateCollectionRequest) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+type CreateCollectionResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CreateCollectionResponse) Reset() {
+	*x = CreateCollectionResponse{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CreateCollectionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CreateCollectionResponse) ProtoMessage() {}
+
+func (x *CreateCollectionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CreateCollectionResponse.ProtoReflect.Descriptor instead.
+func (*CreateCollectionResponse) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{9}
+}
+
+type InsertDocumentRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Database   string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Collection string                 `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
+	// document gets a generated ID if it has no "_id"
+	Document      *structpb.Struct `protobuf:"bytes,3,opt,name=document,proto3" json:"document,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InsertDocumentRequest) Reset() {
+	*x = InsertDocumentRequest{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InsertDocumentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertDocumentRequest) ProtoMessage() {}
+
+func (x *InsertDocumentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertDocumentRequest.ProtoReflect.Descriptor instead.
+func (*InsertDocumentRequest) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *InsertDocumentRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *InsertDocumentRequest) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *InsertDocumentRequest) GetDocument() *structpb.Struct {
+	if x != nil {
+		return x.Document
+	}
+	return nil
+}
+
+type InsertDocumentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Document      *structpb.Struct       `protobuf:"bytes,1,opt,name=document,proto3" json:"document,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InsertDocumentResponse) Reset() {
+	*x = InsertDocumentResponse{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InsertDocumentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InsertDocumentResponse) ProtoMessage() {}
+
+func (x *InsertDocumentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InsertDocumentResponse.ProtoReflect.Descriptor instead.
+func (*InsertDocumentResponse) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *InsertDocumentResponse) GetDocument() *structpb.Struct {
+	if x != nil {
+		return x.Document
+	}
+	return nil
+}
+
+type GetDocumentRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Database      string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Collection    string                 `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
+	Id            string                 `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDocumentRequest) Reset() {
+	*x = GetDocumentRequest{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDocumentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDocumentRequest) ProtoMessage() {}
+
+func (x *GetDocumentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDocumentRequest.ProtoReflect.Descriptor instead.
+func (*GetDocumentRequest) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *GetDocumentRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *GetDocumentRequest) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *GetDocumentRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+type GetDocumentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Document      *structpb.Struct       `protobuf:"bytes,1,opt,name=document,proto3" json:"document,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetDocumentResponse) Reset() {
+	*x = GetDocumentResponse{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetDocumentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDocumentResponse) ProtoMessage() {}
+
+func (x *GetDocumentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDocumentResponse.ProtoReflect.Descriptor instead.
+func (*GetDocumentResponse) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *GetDocumentResponse) GetDocument() *structpb.Struct {
+	if x != nil {
+		return x.Document
+	}
+	return nil
+}
+
+type UpdateDocumentRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Database   string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Collection string                 `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
+	Id         string                 `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	// updates sets fields by path, or applies update operators such as "$inc"
+	Updates *structpb.Struct `protobuf:"bytes,4,opt,name=updates,proto3" json:"updates,omitempty"`
+	// expected_rev, if set, makes the update fail with ABORTED unless the
+	// document is still at that revision
+	ExpectedRev   *int64 `protobuf:"varint,5,opt,name=expected_rev,json=expectedRev,proto3,oneof" json:"expected_rev,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateDocumentRequest) Reset() {
+	*x = UpdateDocumentRequest{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateDocumentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateDocumentRequest) ProtoMessage() {}
+
+func (x *UpdateDocumentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateDocumentRequest.ProtoReflect.Descriptor instead.
+func (*UpdateDocumentRequest) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *UpdateDocumentRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *UpdateDocumentRequest) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *UpdateDocumentRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *UpdateDocumentRequest) GetUpdates() *structpb.Struct {
+	if x != nil {
+		return x.Updates
+	}
+	return nil
+}
+
+func (x *UpdateDocumentRequest) GetExpectedRev() int64 {
+	if x != nil && x.ExpectedRev != nil {
+		return *x.ExpectedRev
+	}
+	return 0
+}
+
+type UpdateDocumentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Document      *structpb.Struct       `protobuf:"bytes,1,opt,name=document,proto3" json:"document,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UpdateDocumentResponse) Reset() {
+	*x = UpdateDocumentResponse{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UpdateDocumentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UpdateDocumentResponse) ProtoMessage() {}
+
+func (x *UpdateDocumentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UpdateDocumentResponse.ProtoReflect.Descriptor instead.
+func (*UpdateDocumentResponse) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *UpdateDocumentResponse) GetDocument() *structpb.Struct {
+	if x != nil {
+		return x.Document
+	}
+	return nil
+}
+
+type DeleteDocumentRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Database   string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Collection string                 `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
+	Id         string                 `protobuf:"bytes,3,opt,name=id,proto3" json:"id,omitempty"`
+	// expected_rev, if set, makes the delete fail with ABORTED unless the
+	// document is still at that revision
+	ExpectedRev   *int64 `protobuf:"varint,4,opt,name=expected_rev,json=expectedRev,proto3,oneof" json:"expected_rev,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteDocumentRequest) Reset() {
+	*x = DeleteDocumentRequest{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteDocumentRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteDocumentRequest) ProtoMessage() {}
+
+func (x *DeleteDocumentRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteDocumentRequest.ProtoReflect.Descriptor instead.
+func (*DeleteDocumentRequest) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *DeleteDocumentRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *DeleteDocumentRequest) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *DeleteDocumentRequest) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *DeleteDocumentRequest) GetExpectedRev() int64 {
+	if x != nil && x.ExpectedRev != nil {
+		return *x.ExpectedRev
+	}
+	return 0
+}
+
+type DeleteDocumentResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteDocumentResponse) Reset() {
+	*x = DeleteDocumentResponse{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteDocumentResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteDocumentResponse) ProtoMessage() {}
+
+func (x *DeleteDocumentResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteDocumentResponse.ProtoReflect.Descriptor instead.
+func (*DeleteDocumentResponse) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{17}
+}
+
+// Filter is a condition on a field, or an "and" or "or" group of filters
+type Filter struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Field string                 `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	// operator is eq, ne, gt, gte, lt, lte, in, nin, regex, prefix, suffix
+	// or contains
+	Operator      string          `protobuf:"bytes,2,opt,name=operator,proto3" json:"operator,omitempty"`
+	Value         *structpb.Value `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	And           []*Filter       `protobuf:"bytes,4,rep,name=and,proto3" json:"and,omitempty"`
+	Or            []*Filter       `protobuf:"bytes,5,rep,name=or,proto3" json:"or,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Filter) Reset() {
+	*x = Filter{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Filter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Filter) ProtoMessage() {}
+
+func (x *Filter) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Filter.ProtoReflect.Descriptor instead.
+func (*Filter) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *Filter) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *Filter) GetOperator() string {
+	if x != nil {
+		return x.Operator
+	}
+	return ""
+}
+
+func (x *Filter) GetValue() *structpb.Value {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Filter) GetAnd() []*Filter {
+	if x != nil {
+		return x.And
+	}
+	return nil
+}
+
+func (x *Filter) GetOr() []*Filter {
+	if x != nil {
+		return x.Or
+	}
+	return nil
+}
+
+type SortField struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Field         string                 `protobuf:"bytes,1,opt,name=field,proto3" json:"field,omitempty"`
+	Desc          bool                   `protobuf:"varint,2,opt,name=desc,proto3" json:"desc,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SortField) Reset() {
+	*x = SortField{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SortField) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SortField) ProtoMessage() {}
+
+func (x *SortField) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SortField.ProtoReflect.Descriptor instead.
+func (*SortField) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *SortField) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+func (x *SortField) GetDesc() bool {
+	if x != nil {
+		return x.Desc
+	}
+	return false
+}
+
+type FindRequest struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Database   string                 `protobuf:"bytes,1,opt,name=database,proto3" json:"database,omitempty"`
+	Collection string                 `protobuf:"bytes,2,opt,name=collection,proto3" json:"collection,omitempty"`
+	// filter selects the documents (optional; all of them if unset)
+	Filter        *Filter      `protobuf:"bytes,3,opt,name=filter,proto3" json:"filter,omitempty"`
+	Sort          []*SortField `protobuf:"bytes,4,rep,name=sort,proto3" json:"sort,omitempty"`
+	Limit         int32        `protobuf:"varint,5,opt,name=limit,proto3" json:"limit,omitempty"`
+	Skip          int32        `protobuf:"varint,6,opt,name=skip,proto3" json:"skip,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FindRequest) Reset() {
+	*x = FindRequest{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FindRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindRequest) ProtoMessage() {}
+
+func (x *FindRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindRequest.ProtoReflect.Descriptor instead.
+func (*FindRequest) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *FindRequest) GetDatabase() string {
+	if x != nil {
+		return x.Database
+	}
+	return ""
+}
+
+func (x *FindRequest) GetCollection() string {
+	if x != nil {
+		return x.Collection
+	}
+	return ""
+}
+
+func (x *FindRequest) GetFilter() *Filter {
+	if x != nil {
+		return x.Filter
+	}
+	return nil
+}
+
+func (x *FindRequest) GetSort() []*SortField {
+	if x != nil {
+		return x.Sort
+	}
+	return nil
+}
+
+func (x *FindRequest) GetLimit() int32 {
+	if x != nil {
+		return x.Limit
+	}
+	return 0
+}
+
+func (x *FindRequest) GetSkip() int32 {
+	if x != nil {
+		return x.Skip
+	}
+	return 0
+}
+
+type FindResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Document      *structpb.Struct       `protobuf:"bytes,1,opt,name=document,proto3" json:"document,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FindResponse) Reset() {
+	*x = FindResponse{}
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FindResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindResponse) ProtoMessage() {}
+
+func (x *FindResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_cachydb_v1_cachydb_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindResponse.ProtoReflect.Descriptor instead.
+func (*FindResponse) Descriptor() ([]byte, []int) {
+	return file_cachydb_v1_cachydb_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *FindResponse) GetDocument() *structpb.Struct {
+	if x != nil {
+		return x.Document
+	}
+	return nil
+}
+
+var File_cachydb_v1_cachydb_proto protoreflect.FileDescriptor
+
+const file_cachydb_v1_cachydb_proto_rawDesc = "" +
+	"\n" +
+	"\x18cachydb/v1/cachydb.proto\x12\n" +
+	"cachydb.v1\x1a\x1cgoogle/protobuf/struct.proto\"\x16\n" +
+	"\x14ListDatabasesRequest\"5\n" +
+	"\x15ListDatabasesResponse\x12\x1c\n" +
+	"\tdatabases\x18\x01 \x03(\tR\tdatabases\"+\n" +
+	"\x15CreateDatabaseRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\x18\n" +
+	"\x16CreateDatabaseResponse\"+\n" +
+	"\x15DeleteDatabaseRequest\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\"\x18\n" +
+	"\x16DeleteDatabaseResponse\"4\n" +
+	"\x16ListCollectionsRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\";\n" +
+	"\x17ListCollectionsResponse\x12 \n" +
+	"\vcollections\x18\x01 \x03(\tR\vcollections\"I\n" +
+	"\x17CreateCollectionRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\"\x1a\n" +
+	"\x18CreateCollectionResponse\"\x88\x01\n" +
+	"\x15InsertDocumentRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x02 \x01(\tR\n" +
+	"collection\x123\n" +
+	"\bdocument\x18\x03 \x01(\v2\x17.google.protobuf.StructR\bdocument\"M\n" +
+	"\x16InsertDocumentResponse\x123\n" +
+	"\bdocument\x18\x01 \x01(\v2\x17.google.protobuf.StructR\bdocument\"`\n" +
+	"\x12GetDocumentRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x02 \x01(\tR\n" +
+	"collection\x12\x0e\n" +
+	"\x02id\x18\x03 \x01(\tR\x02id\"J\n" +
+	"\x13GetDocumentResponse\x123\n" +
+	"\bdocument\x18\x01 \x01(\v2\x17.google.protobuf.StructR\bdocument\"\xcf\x01\n" +
+	"\x15UpdateDocumentRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x02 \x01(\tR\n" +
+	"collection\x12\x0e\n" +
+	"\x02id\x18\x03 \x01(\tR\x02id\x121\n" +
+	"\aupdates\x18\x04 \x01(\v2\x17.google.protobuf.StructR\aupdates\x12&\n" +
+	"\fexpected_rev\x18\x05 \x01(\x03H\x00R\vexpectedRev\x88\x01\x01B\x0f\n" +
+	"\r_expected_rev\"M\n" +
+	"\x16UpdateDocumentResponse\x123\n" +
+	"\bdocument\x18\x01 \x01(\v2\x17.google.protobuf.StructR\bdocument\"\x9c\x01\n" +
+	"\x15DeleteDocumentRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x02 \x01(\tR\n" +
+	"collection\x12\x0e\n" +
+	"\x02id\x18\x03 \x01(\tR\x02id\x12&\n" +
+	"\fexpected_rev\x18\x04 \x01(\x03H\x00R\vexpectedRev\x88\x01\x01B\x0f\n" +
+	"\r_expected_rev\"\x18\n" +
+	"\x16DeleteDocumentResponse\"\xb2\x01\n" +
+	"\x06Filter\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12\x1a\n" +
+	"\boperator\x18\x02 \x01(\tR\boperator\x12,\n" +
+	"\x05value\x18\x03 \x01(\v2\x16.google.protobuf.ValueR\x05value\x12$\n" +
+	"\x03and\x18\x04 \x03(\v2\x12.cachydb.v1.FilterR\x03and\x12\"\n" +
+	"\x02or\x18\x05 \x03(\v2\x12.cachydb.v1.FilterR\x02or\"5\n" +
+	"\tSortField\x12\x14\n" +
+	"\x05field\x18\x01 \x01(\tR\x05field\x12\x12\n" +
+	"\x04desc\x18\x02 \x01(\bR\x04desc\"\xca\x01\n" +
+	"\vFindRequest\x12\x1a\n" +
+	"\bdatabase\x18\x01 \x01(\tR\bdatabase\x12\x1e\n" +
+	"\n" +
+	"collection\x18\x02 \x01(\tR\n" +
+	"collection\x12*\n" +
+	"\x06filter\x18\x03 \x01(\v2\x12.cachydb.v1.FilterR\x06filter\x12)\n" +
+	"\x04sort\x18\x04 \x03(\v2\x15.cachydb.v1.SortFieldR\x04sort\x12\x14\n" +
+	"\x05limit\x18\x05 \x01(\x05R\x05limit\x12\x12\n" +
+	"\x04skip\x18\x06 \x01(\x05R\x04skip\"C\n" +
+	"\fFindResponse\x123\n" +
+	"\bdocument\x18\x01 \x01(\v2\x17.google.protobuf.StructR\bdocument2\xe4\x06\n" +
+	"\aCachyDB\x12T\n" +
+	"\rListDatabases\x12 .cachydb.v1.ListDatabasesRequest\x1a!.cachydb.v1.ListDatabasesResponse\x12W\n" +
+	"\x0eCreateDatabase\x12!.cachydb.v1.CreateDatabaseRequest\x1a\".cachydb.v1.CreateDatabaseResponse\x12W\n" +
+	"\x0eDeleteDatabase\x12!.cachydb.v1.DeleteDatabaseRequest\x1a\".cachydb.v1.DeleteDatabaseResponse\x12Z\n" +
+	"\x0fListCollections\x12\".cachydb.v1.ListCollectionsRequest\x1a#.cachydb.v1.ListCollectionsResponse\x12]\n" +
+	"\x10CreateCollection\x12#.cachydb.v1.CreateCollectionRequest\x1a$.cachydb.v1.CreateCollectionResponse\x12W\n" +
+	"\x0eInsertDocument\x12!.cachydb.v1.InsertDocumentRequest\x1a\".cachydb.v1.InsertDocumentResponse\x12N\n" +
+	"\vGetDocument\x12\x1e.cachydb.v1.GetDocumentRequest\x1a\x1f.cachydb.v1.GetDocumentResponse\x12W\n" +
+	"\x0eUpdateDocument\x12!.cachydb.v1.UpdateDocumentRequest\x1a\".cachydb.v1.UpdateDocumentResponse\x12W\n" +
+	"\x0eDeleteDocument\x12!.cachydb.v1.DeleteDocumentRequest\x1a\".cachydb.v1.DeleteDocumentResponse\x12;\n" +
+	"\x04Find\x12\x17.cachydb.v1.FindRequest\x1a\x18.cachydb.v1.FindResponse0\x01B1Z/github.com/hop-/cachydb/pkg/cachydbpb;cachydbpbb\x06proto3"
+
+var (
+	file_cachydb_v1_cachydb_proto_rawDescOnce sync.Once
+	file_cachydb_v1_cachydb_proto_rawDescData []byte
+)
+
+func file_cachydb_v1_cachydb_proto_rawDescGZIP() []byte {
+	file_cachydb_v1_cachydb_proto_rawDescOnce.Do(func() {
+		file_cachydb_v1_cachydb_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_cachydb_v1_cachydb_proto_rawDesc), len(file_cachydb_v1_cachydb_proto_rawDesc)))
+	})
+	return file_cachydb_v1_cachydb_proto_rawDescData
+}
+
+var file_cachydb_v1_cachydb_proto_msgTypes = make([]protoimpl.MessageInfo, 22)
+var file_cachydb_v1_cachydb_proto_goTypes = []any{
+	(*ListDatabasesRequest)(nil),     // 0: cachydb.v1.ListDatabasesRequest
+	(*ListDatabasesResponse)(nil),    // 1: cachydb.v1.ListDatabasesResponse
+	(*CreateDatabaseRequest)(nil),    // 2: cachydb.v1.CreateDatabaseRequest
+	(*CreateDatabaseResponse)(nil),   // 3: cachydb.v1.CreateDatabaseResponse
+	(*DeleteDatabaseRequest)(nil),    // 4: cachydb.v1.DeleteDatabaseRequest
+	(*DeleteDatabaseResponse)(nil),   // 5: cachydb.v1.DeleteDatabaseResponse
+	(*ListCollectionsRequest)(nil),   // 6: cachydb.v1.ListCollectionsRequest
+	(*ListCollectionsResponse)(nil),  // 7: cachydb.v1.ListCollectionsResponse
+	(*CreateCollectionRequest)(nil),  // 8: cachydb.v1.CreateCollectionRequest
+	(*CreateCollectionResponse)(nil), // 9: cachydb.v1.CreateCollectionResponse
+	(*InsertDocumentRequest)(nil),    // 10: cachydb.v1.InsertDocumentRequest
+	(*InsertDocumentResponse)(nil),   // 11: cachydb.v1.InsertDocumentResponse
+	(*GetDocumentRequest)(nil),       // 12: cachydb.v1.GetDocumentRequest
+	(*GetDocumentResponse)(nil),      // 13: cachydb.v1.GetDocumentResponse
+	(*UpdateDocumentRequest)(nil),    // 14: cachydb.v1.UpdateDocumentRequest
+	(*UpdateDocumentResponse)(nil),   // 15: cachydb.v1.UpdateDocumentResponse
+	(*DeleteDocumentRequest)(nil),    // 16: cachydb.v1.DeleteDocumentRequest
+	(*DeleteDocumentResponse)(nil),   // 17: cachydb.v1.DeleteDocumentResponse
+	(*Filter)(nil),                   // 18: cachydb.v1.Filter
+	(*SortField)(nil),                // 19: cachydb.v1.SortField
+	(*FindRequest)(nil),              // 20: cachydb.v1.FindRequest
+	(*FindResponse)(nil),             // 21: cachydb.v1.FindResponse
+	(*structpb.Struct)(nil),          // 22: google.protobuf.Struct
+	(*structpb.Value)(nil),           // 23: google.protobuf.Value
+}
+var file_cachydb_v1_cachydb_proto_depIdxs = []int32{
+	22, // 0: cachydb.v1.InsertDocumentRequest.document:type_name -> google.protobuf.Struct
+	22, // 1: cachydb.v1.InsertDocumentResponse.document:type_name -> google.protobuf.Struct
+	22, // 2: cachydb.v1.GetDocumentResponse.document:type_name -> google.protobuf.Struct
+	22, // 3: cachydb.v1.UpdateDocumentRequest.updates:type_name -> google.protobuf.Struct
+	22, // 4: cachydb.v1.UpdateDocumentResponse.document:type_name -> google.protobuf.Struct
+	23, // 5: cachydb.v1.Filter.value:type_name -> google.protobuf.Value
+	18, // 6: cachydb.v1.Filter.and:type_name -> cachydb.v1.Filter
+	18, // 7: cachydb.v1.Filter.or:type_name -> cachydb.v1.Filter
+	18, // 8: cachydb.v1.FindRequest.filter:type_name -> cachydb.v1.Filter
+	19, // 9: cachydb.v1.FindRequest.sort:type_name -> cachydb.v1.SortField
+	22, // 10: cachydb.v1.FindResponse.document:type_name -> google.protobuf.Struct
+	0,  // 11: cachydb.v1.CachyDB.ListDatabases:input_type -> cachydb.v1.ListDatabasesRequest
+	2,  // 12: cachydb.v1.CachyDB.CreateDatabase:input_type -> cachydb.v1.CreateDatabaseRequest
+	4,  // 13: cachydb.v1.CachyDB.DeleteDatabase:input_type -> cachydb.v1.DeleteDatabaseRequest
+	6,  // 14: cachydb.v1.CachyDB.ListCollections:input_type -> cachydb.v1.ListCollectionsRequest
+	8,  // 15: cachydb.v1.CachyDB.CreateCollection:input_type -> cachydb.v1.CreateCollectionRequest
+	10, // 16: cachydb.v1.CachyDB.InsertDocument:input_type -> cachydb.v1.InsertDocumentRequest
+	12, // 17: cachydb.v1.CachyDB.GetDocument:input_type -> cachydb.v1.GetDocumentRequest
+	14, // 18: cachydb.v1.CachyDB.UpdateDocument:input_type -> cachydb.v1.UpdateDocumentRequest
+	16, // 19: cachydb.v1.CachyDB.DeleteDocument:input_type -> cachydb.v1.DeleteDocumentRequest
+	20, // 20: cachydb.v1.CachyDB.Find:input_type -> cachydb.v1.FindRequest
+	1,  // 21: cachydb.v1.CachyDB.ListDatabases:output_type -> cachydb.v1.ListDatabasesResponse
+	3,  // 22: cachydb.v1.CachyDB.CreateDatabase:output_type -> cachydb.v1.CreateDatabaseResponse
+	5,  // 23: cachydb.v1.CachyDB.DeleteDatabase:output_type -> cachydb.v1.DeleteDatabaseResponse
+	7,  // 24: cachydb.v1.CachyDB.ListCollections:output_type -> cachydb.v1.ListCollectionsResponse
+	9,  // 25: cachydb.v1.CachyDB.CreateCollection:output_type -> cachydb.v1.CreateCollectionResponse
+	11, // 26: cachydb.v1.CachyDB.InsertDocument:output_type -> cachydb.v1.InsertDocumentResponse
+	13, // 27: cachydb.v1.CachyDB.GetDocument:output_type -> cachydb.v1.GetDocumentResponse
+	15, // 28: cachydb.v1.CachyDB.UpdateDocument:output_type -> cachydb.v1.UpdateDocumentResponse
+	17, // 29: cachydb.v1.CachyDB.DeleteDocument:output_type -> cachydb.v1.DeleteDocumentResponse
+	21, // 30: cachydb.v1.CachyDB.Find:output_type -> cachydb.v1.FindResponse
+	21, // [21:31] is the sub-list for method output_type
+	11, // [11:21] is the sub-list for method input_type
+	11, // [11:11] is the sub-list for extension type_name
+	11, // [11:11] is the sub-list for extension extendee
+	0,  // [0:11] is the sub-list for field type_name
+}
+
+func init() { file_cachydb_v1_cachydb_proto_init() }
+func file_cachydb_v1_cachydb_proto_init() {
+	if File_cachydb_v1_cachydb_proto != nil {
+		return
+	}
+	file_cachydb_v1_cachydb_proto_msgTypes[14].OneofWrappers = []any{}
+	file_cachydb_v1_cachydb_proto_msgTypes[16].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_cachydb_v1_cachydb_proto_rawDesc), len(file_cachydb_v1_cachydb_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   22,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_cachydb_v1_cachydb_proto_goTypes,
+		DependencyIndexes: file_cachydb_v1_cachydb_proto_depIdxs,
+		MessageInfos:      file_cachydb_v1_cachydb_proto_msgTypes,
+	}.Build()
+	File_cachydb_v1_cachydb_proto = out.File
+	file_cachydb_v1_cachydb_proto_goTypes = nil
+	file_cachydb_v1_cachydb_proto_depIdxs = nil
+}
@@ -0,0 +1,6 @@
+// Package cachydbpb holds the gRPC service of CachyDB, generated from
+// proto/cachydb/v1/cachydb.proto. Go clients dial the server's gRPC port
+// and use NewCachyDBClient.
+package cachydbpb
+
+//go:generate sh -c "cd ../.. && buf generate proto --template proto/buf.gen.yaml"
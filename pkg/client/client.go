@@ -0,0 +1,340 @@
+// Package client is a Go client for the REST API of a CachyDB server (see
+// the rest package of the server). It retries requests that are safe to
+// repeat when the server cannot be reached or is unavailable, and streams
+// query results through a Cursor.
+//
+//	c, err := client.Connect("http://localhost:7601")
+//	users := c.Collection("main", "users")
+//	doc, err := users.Insert(ctx, map[string]any{"name": "Ann", "age": 30})
+//	cursor, err := users.Find(ctx, &db.Query{Filters: []db.QueryFilter{{Field: "age", Operator: "gte", Value: 18}}})
+//	defer cursor.Close()
+//	for cursor.Next() {
+//		fmt.Println(cursor.Document())
+//	}
+//	err = cursor.Err()
+//
+// Errors returned by the server are *Error values, which match the errors
+// of the db package with errors.Is: db.ErrNotFound, db.ErrConflict,
+// db.ErrDuplicateKey and db.ErrReplica.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// Defaults of the client options
+const (
+	DefaultTimeout = 30 * time.Second
+	DefaultRetries = 3
+)
+
+// Backoff between the attempts of a retried request
+const (
+	minRetryDelay = 100 * time.Millisecond
+	maxRetryDelay = 5 * time.Second
+)
+
+// tenantHeader selects the tenant of a request
+const tenantHeader = "X-CachyDB-Tenant"
+
+// ndjsonType is the content type of streamed documents, one per line
+const ndjsonType = "application/x-ndjson"
+
+// Error is an error response of the server
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("server responded %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("server responded %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is matches the db package error the status of the response stands for
+func (e *Error) Is(target error) bool {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return target == db.ErrNotFound
+	case http.StatusPreconditionFailed:
+		return target == db.ErrConflict
+	case http.StatusConflict:
+		return target == db.ErrDuplicateKey
+	case http.StatusForbidden:
+		return target == db.ErrReplica
+	}
+	return false
+}
+
+// Client sends requests to the REST API of a server. It is safe for
+// concurrent use.
+type Client struct {
+	baseURL string
+	http    *http.Client
+	tenant  string
+	timeout time.Duration
+	retries int
+}
+
+// Option configures Connect
+type Option func(*Client)
+
+// WithTimeout bounds each attempt of a request (DefaultTimeout by default);
+// for Find, it bounds the time until the server starts sending results.
+// Zero disables the timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetries sets how many times a failed request that is safe to repeat
+// is retried (DefaultRetries by default)
+func WithRetries(retries int) Option {
+	return func(c *Client) {
+		c.retries = retries
+	}
+}
+
+// WithTenant restricts the client to the databases of a tenant
+func WithTenant(tenant string) Option {
+	return func(c *Client) {
+		c.tenant = tenant
+	}
+}
+
+// WithHTTPClient sets the HTTP client requests are sent with, e.g. for TLS
+// settings (http.DefaultClient by default)
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.http = httpClient
+	}
+}
+
+// Connect creates a client of the server at serverURL, e.g.
+// http://localhost:7601, and checks that the server can be reached. A
+// missing scheme means http.
+func Connect(serverURL string, opts ...Option) (*Client, error) {
+	if !strings.Contains(serverURL, "://") {
+		serverURL = "http://" + serverURL
+	}
+	serverURL = strings.TrimRight(serverURL, "/")
+	serverURL = strings.TrimSuffix(strings.TrimSuffix(serverURL, "/mcp"), "/v1")
+	if _, err := url.Parse(serverURL); err != nil {
+		return nil, fmt.Errorf("invalid server URL: %w", err)
+	}
+
+	c := &Client{
+		baseURL: serverURL,
+		http:    http.DefaultClient,
+		timeout: DefaultTimeout,
+		retries: DefaultRetries,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if _, err := c.ListDatabases(context.Background()); err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", serverURL, err)
+	}
+	return c, nil
+}
+
+// ListDatabases returns the names of the databases, sorted
+func (c *Client) ListDatabases(ctx context.Context) ([]string, error) {
+	var result struct {
+		Databases []string `json:"databases"`
+	}
+	if err := c.call(ctx, &request{method: http.MethodGet, path: "/v1/databases", retry: true}, &result); err != nil {
+		return nil, err
+	}
+	return result.Databases, nil
+}
+
+// ListCollections returns the names of the collections of a database, sorted
+func (c *Client) ListCollections(ctx context.Context, database string) ([]string, error) {
+	var result struct {
+		Collections []string `json:"collections"`
+	}
+	path := "/v1/databases/" + url.PathEscape(database) + "/collections"
+	if err := c.call(ctx, &request{method: http.MethodGet, path: path, retry: true}, &result); err != nil {
+		return nil, err
+	}
+	return result.Collections, nil
+}
+
+// Collection returns a handle on a collection of a database. It sends no
+// request; a missing collection is reported by the calls on the handle.
+func (c *Client) Collection(database, name string) *Collection {
+	return &Collection{client: c, Database: database, Name: name}
+}
+
+// request is a request to the API
+type request struct {
+	method string
+	path   string
+	body   any
+	// rev, if set, makes the request conditional on the document revision
+	rev *int64
+	// stream asks for documents one per line
+	stream bool
+	// retry allows sending the request again, for requests whose repetition
+	// has no effect beyond the first
+	retry bool
+}
+
+// call sends a request and decodes the JSON response into out, unless nil
+func (c *Client) call(ctx context.Context, req *request, out any) error {
+	resp, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("invalid response: %w", err)
+	}
+	return nil
+}
+
+// send sends a request, retrying it if allowed, and returns the successful
+// response. The attempt timeout keeps running while the body is read,
+// except for streams, which only ctx bounds once the response arrived.
+func (c *Client) send(ctx context.Context, req *request) (*http.Response, error) {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	delay := minRetryDelay
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, req, body)
+		if err == nil || !req.retry || attempt >= c.retries || !retryable(err) || ctx.Err() != nil {
+			return resp, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// attempt sends a request once
+func (c *Client) attempt(ctx context.Context, req *request, body []byte) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stop := cancel
+	var timedOut atomic.Bool
+	if c.timeout > 0 {
+		timer := time.AfterFunc(c.timeout, func() {
+			timedOut.Store(true)
+			cancel()
+		})
+		stop = func() {
+			timer.Stop()
+			cancel()
+		}
+		if req.stream {
+			defer timer.Stop()
+		}
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, c.baseURL+req.path, reader)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	if req.stream {
+		httpReq.Header.Set("Accept", ndjsonType)
+	}
+	if req.rev != nil {
+		httpReq.Header.Set("If-Match", strconv.Quote(strconv.FormatInt(*req.rev, 10)))
+	}
+	if c.tenant != "" {
+		httpReq.Header.Set(tenantHeader, c.tenant)
+	}
+
+	resp, err := c.http.Do(httpReq)
+	if err != nil {
+		stop()
+		if timedOut.Load() {
+			return nil, fmt.Errorf("%s %s: no response within %s", req.method, req.path, c.timeout)
+		}
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		defer stop()
+		defer resp.Body.Close()
+		return nil, responseError(resp)
+	}
+	resp.Body = &responseBody{ReadCloser: resp.Body, stop: stop}
+	return resp, nil
+}
+
+// responseBody ends the attempt of a request once its body is closed
+type responseBody struct {
+	io.ReadCloser
+	stop func()
+}
+
+func (b *responseBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.stop()
+	return err
+}
+
+// responseError reads the error of an unsuccessful response
+func responseError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(message, &body) == nil && body.Error != "" {
+		return &Error{StatusCode: resp.StatusCode, Message: body.Error}
+	}
+	return &Error{StatusCode: resp.StatusCode, Message: string(bytes.TrimSpace(message))}
+}
+
+// retryable reports whether a failed attempt may succeed when repeated:
+// the server could not be reached, or was overloaded or unavailable
+func retryable(err error) bool {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return true
+}
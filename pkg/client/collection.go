@@ -0,0 +1,174 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// Collection is a handle on a collection of a database of the server
+type Collection struct {
+	client   *Client
+	Database string
+	Name     string
+}
+
+func (c *Collection) documentsPath() string {
+	return "/v1/databases/" + url.PathEscape(c.Database) + "/collections/" + url.PathEscape(c.Name) + "/documents"
+}
+
+func (c *Collection) documentPath(id string) string {
+	return c.documentsPath() + "/" + url.PathEscape(id)
+}
+
+// Insert inserts a document, given as any value that encodes to a JSON
+// object, and returns it as stored, with its _id and _rev. A document
+// without _id gets a generated one. Inserts are not retried, since a
+// repeated insert could store the document twice.
+func (c *Collection) Insert(ctx context.Context, doc any) (*db.Document, error) {
+	var stored db.Document
+	if err := c.client.call(ctx, &request{method: http.MethodPost, path: c.documentsPath(), body: doc}, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// Get returns the document with the given ID
+func (c *Collection) Get(ctx context.Context, id string) (*db.Document, error) {
+	var doc db.Document
+	if err := c.client.call(ctx, &request{method: http.MethodGet, path: c.documentPath(id), retry: true}, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Update sets the fields of updates on a document, which take the same
+// forms as for db.Collection.Update, including update operators, and
+// returns the updated document. Updates are not retried, since operators
+// such as $inc would apply twice.
+func (c *Collection) Update(ctx context.Context, id string, updates map[string]any) (*db.Document, error) {
+	return c.update(ctx, &request{method: http.MethodPatch, path: c.documentPath(id), body: updates})
+}
+
+// UpdateIfRev is Update for a document that must still be at revision rev;
+// otherwise it fails with an error matching db.ErrConflict
+func (c *Collection) UpdateIfRev(ctx context.Context, id string, rev int64, updates map[string]any) (*db.Document, error) {
+	return c.update(ctx, &request{method: http.MethodPatch, path: c.documentPath(id), body: updates, rev: &rev})
+}
+
+func (c *Collection) update(ctx context.Context, req *request) (*db.Document, error) {
+	var doc db.Document
+	if err := c.client.call(ctx, req, &doc); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Delete deletes the document with the given ID
+func (c *Collection) Delete(ctx context.Context, id string) error {
+	return c.client.call(ctx, &request{method: http.MethodDelete, path: c.documentPath(id), retry: true}, nil)
+}
+
+// DeleteIfRev is Delete for a document that must still be at revision rev;
+// otherwise it fails with an error matching db.ErrConflict
+func (c *Collection) DeleteIfRev(ctx context.Context, id string, rev int64) error {
+	return c.client.call(ctx, &request{method: http.MethodDelete, path: c.documentPath(id), rev: &rev, retry: true}, nil)
+}
+
+// Find returns a cursor over the documents matching a query, or all
+// documents if query is nil. The server streams the documents as the
+// cursor reads them, so large results are never held in memory at once.
+// The cursor must be closed.
+func (c *Collection) Find(ctx context.Context, query *db.Query) (*Cursor, error) {
+	if query == nil {
+		query = &db.Query{}
+	}
+	resp, err := c.client.send(ctx, &request{
+		method: http.MethodPost,
+		path:   c.documentsPath() + "/query",
+		body:   query,
+		stream: true,
+		retry:  true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Cursor{body: resp.Body, decoder: json.NewDecoder(resp.Body)}, nil
+}
+
+// Cursor reads the documents of a query one at a time
+type Cursor struct {
+	body    io.ReadCloser
+	decoder *json.Decoder
+	raw     json.RawMessage
+	doc     *db.Document
+	err     error
+}
+
+// Next advances to the next document, returning false once there are no
+// more or reading failed (see Err). The cursor is closed when it returns
+// false.
+func (c *Cursor) Next() bool {
+	if c.body == nil {
+		return false
+	}
+	c.raw, c.doc = nil, nil
+	if err := c.decoder.Decode(&c.raw); err != nil {
+		if err != io.EOF {
+			c.err = fmt.Errorf("failed to read documents: %w", err)
+		}
+		c.Close()
+		return false
+	}
+
+	var doc db.Document
+	if err := json.Unmarshal(c.raw, &doc); err != nil {
+		c.err = fmt.Errorf("invalid document: %w", err)
+		c.Close()
+		return false
+	}
+	c.doc = &doc
+	return true
+}
+
+// Document returns the current document
+func (c *Cursor) Document() *db.Document {
+	return c.doc
+}
+
+// Decode decodes the current document into v, e.g. a struct with json tags
+func (c *Cursor) Decode(v any) error {
+	if c.raw == nil {
+		return fmt.Errorf("no current document")
+	}
+	return json.Unmarshal(c.raw, v)
+}
+
+// Err returns the error that stopped the cursor, if any
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// All reads the remaining documents and closes the cursor
+func (c *Cursor) All() ([]*db.Document, error) {
+	docs := make([]*db.Document, 0)
+	for c.Next() {
+		docs = append(docs, c.doc)
+	}
+	return docs, c.err
+}
+
+// Close stops reading documents. It is safe to call more than once.
+func (c *Cursor) Close() error {
+	if c.body == nil {
+		return nil
+	}
+	err := c.body.Close()
+	c.body = nil
+	return err
+}
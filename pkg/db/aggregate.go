@@ -0,0 +1,240 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Aggregation accumulator operators
+const (
+	AccCount    = "count"
+	AccSum      = "sum"
+	AccAvg      = "avg"
+	AccMin      = "min"
+	AccMax      = "max"
+	AccVariance = "variance"    // population variance
+	AccStdDev   = "stddev"      // population standard deviation
+	AccVarSamp  = "var_samp"    // sample variance (n-1)
+	AccStdSamp  = "stddev_samp" // sample standard deviation (n-1)
+	AccMedian   = "median"
+	AccPercent  = "percentile" // uses Accumulator.Percentile; "p50", "p95", "p99", ... are shorthands
+)
+
+// Accumulator computes one value per group
+type Accumulator struct {
+	Op         string  `json:"op"`
+	Field      string  `json:"field,omitempty"`      // numeric field to aggregate (not needed for count)
+	Percentile float64 `json:"percentile,omitempty"` // 0-100, for the percentile operator
+}
+
+// Aggregation groups the documents matching Filters by GroupBy and computes
+// the named accumulators for each group. With an empty GroupBy all matching
+// documents form a single group.
+type Aggregation struct {
+	Filters      []QueryFilter          `json:"filters,omitempty"`
+	GroupBy      string                 `json:"group_by,omitempty"`
+	Accumulators map[string]Accumulator `json:"accumulators"`
+}
+
+// AggregateGroup is the result for one group
+type AggregateGroup struct {
+	Key    any            `json:"key"` // value of the GroupBy field (nil if missing or not grouping)
+	Count  int            `json:"count"`
+	Values map[string]any `json:"values"` // accumulator name -> result (nil when no numeric values)
+}
+
+// Aggregate runs an aggregation over the collection. Groups are returned in
+// ascending key order. Non-numeric values are ignored by numeric accumulators.
+func (c *Collection) Aggregate(agg *Aggregation) ([]AggregateGroup, error) {
+	accumulators := make(map[string]Accumulator, len(agg.Accumulators))
+	for name, acc := range agg.Accumulators {
+		normalized, err := normalizeAccumulator(acc)
+		if err != nil {
+			return nil, fmt.Errorf("accumulator '%s': %w", name, err)
+		}
+		accumulators[name] = normalized
+	}
+
+	docs, err := c.Find(&Query{Filters: agg.Filters})
+	if err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		key    any
+		docs   []*Document
+		sortBy string
+	}
+	groups := make(map[string]*group)
+	for _, doc := range docs {
+		var key any
+		if agg.GroupBy != "" {
+			key, _ = doc.GetValue(agg.GroupBy)
+		}
+		groupKey := fmt.Sprintf("%T:%v", key, key)
+		g, exists := groups[groupKey]
+		if !exists {
+			g = &group{key: key, sortBy: fmt.Sprintf("%v", key)}
+			groups[groupKey] = g
+		}
+		g.docs = append(g.docs, doc)
+	}
+
+	ordered := make([]*group, 0, len(groups))
+	for _, g := range groups {
+		ordered = append(ordered, g)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		a, aNum := toFloat(ordered[i].key)
+		b, bNum := toFloat(ordered[j].key)
+		if aNum && bNum {
+			return a < b
+		}
+		return ordered[i].sortBy < ordered[j].sortBy
+	})
+
+	results := make([]AggregateGroup, 0, len(ordered))
+	for _, g := range ordered {
+		result := AggregateGroup{
+			Key:    g.key,
+			Count:  len(g.docs),
+			Values: make(map[string]any, len(accumulators)),
+		}
+		for name, acc := range accumulators {
+			result.Values[name] = accumulate(acc, g.docs)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// normalizeAccumulator validates an accumulator and expands shorthands
+func normalizeAccumulator(acc Accumulator) (Accumulator, error) {
+	acc.Op = strings.ToLower(acc.Op)
+
+	if len(acc.Op) > 1 && acc.Op[0] == 'p' && acc.Op != AccPercent {
+		p, err := strconv.ParseFloat(acc.Op[1:], 64)
+		if err != nil {
+			return acc, fmt.Errorf("unknown operator '%s'", acc.Op)
+		}
+		acc.Op = AccPercent
+		acc.Percentile = p
+	}
+
+	switch acc.Op {
+	case AccCount:
+		return acc, nil
+	case AccSum, AccAvg, AccMin, AccMax, AccVariance, AccStdDev, AccVarSamp, AccStdSamp, AccMedian:
+	case AccPercent:
+		if acc.Percentile < 0 || acc.Percentile > 100 {
+			return acc, fmt.Errorf("percentile must be between 0 and 100")
+		}
+	default:
+		return acc, fmt.Errorf("unknown operator '%s'", acc.Op)
+	}
+
+	if acc.Field == "" {
+		return acc, fmt.Errorf("operator '%s' requires a field", acc.Op)
+	}
+	return acc, nil
+}
+
+// accumulate computes one accumulator over a group of documents
+func accumulate(acc Accumulator, docs []*Document) any {
+	if acc.Op == AccCount {
+		if acc.Field == "" {
+			return len(docs)
+		}
+		count := 0
+		for _, doc := range docs {
+			if _, exists := doc.GetValue(acc.Field); exists {
+				count++
+			}
+		}
+		return count
+	}
+
+	values := make([]float64, 0, len(docs))
+	for _, doc := range docs {
+		if value, exists := doc.GetValue(acc.Field); exists {
+			if f, ok := toFloat(value); ok {
+				values = append(values, f)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+
+	switch acc.Op {
+	case AccSum:
+		return sumFloats(values)
+	case AccAvg:
+		return sumFloats(values) / float64(len(values))
+	case AccMin:
+		sort.Float64s(values)
+		return values[0]
+	case AccMax:
+		sort.Float64s(values)
+		return values[len(values)-1]
+	case AccVariance:
+		return variance(values, false)
+	case AccStdDev:
+		return math.Sqrt(variance(values, false))
+	case AccVarSamp:
+		if len(values) < 2 {
+			return nil
+		}
+		return variance(values, true)
+	case AccStdSamp:
+		if len(values) < 2 {
+			return nil
+		}
+		return math.Sqrt(variance(values, true))
+	case AccMedian:
+		return percentile(values, 50)
+	case AccPercent:
+		return percentile(values, acc.Percentile)
+	}
+	return nil
+}
+
+func sumFloats(values []float64) float64 {
+	total := 0.0
+	for _, v := range values {
+		total += v
+	}
+	return total
+}
+
+// variance computes the population (or, if sample is set, the sample) variance
+// using Welford's algorithm for numerical stability
+func variance(values []float64, sample bool) float64 {
+	mean, m2 := 0.0, 0.0
+	for i, v := range values {
+		delta := v - mean
+		mean += delta / float64(i+1)
+		m2 += delta * (v - mean)
+	}
+	if sample {
+		return m2 / float64(len(values)-1)
+	}
+	return m2 / float64(len(values))
+}
+
+// percentile returns the p-th percentile (0-100) using linear interpolation
+// between closest ranks. values is sorted in place.
+func percentile(values []float64, p float64) float64 {
+	sort.Float64s(values)
+	rank := p / 100 * float64(len(values)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Ceil(rank))
+	if lower == upper {
+		return values[lower]
+	}
+	return values[lower] + (values[upper]-values[lower])*(rank-float64(lower))
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// AttachmentsField holds a document's attachment references, keyed by attachment name
+const AttachmentsField = "_attachments"
+
+// Attachment describes a file attached to a document
+type Attachment struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"` // SHA-256 of the content
+	Size int64  `json:"size"`
+}
+
+// PutAttachment stores the content read from r as a named attachment of a
+// document, replacing any attachment with the same name. The content is
+// written to the collection's content-addressed blob store and referenced
+// from the document's _attachments field.
+func (c *Collection) PutAttachment(docID, name string, r io.Reader) (*Attachment, error) {
+	if name == "" {
+		return nil, fmt.Errorf("attachment name cannot be empty")
+	}
+
+	var attachment *Attachment
+	err := c.modify(docID, func(doc *Document) error {
+		if c.blobs == nil {
+			return fmt.Errorf("collection '%s' has no blob store", c.Name)
+		}
+
+		// Stored while holding the collection lock so that blob cleanup
+		// during a concurrent save cannot remove it before it is referenced
+		hash, size, err := c.blobs.Put(r)
+		if err != nil {
+			return err
+		}
+		attachment = &Attachment{Name: name, Hash: hash, Size: size}
+
+		attachments, _ := doc.Data[AttachmentsField].(map[string]any)
+		if attachments == nil {
+			attachments = make(map[string]any)
+		}
+		attachments[name] = map[string]any{BlobRefKey: hash, BlobSizeKey: float64(size)}
+		doc.Data[AttachmentsField] = attachments
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return attachment, nil
+}
+
+// GetAttachment opens a document's attachment for reading. The caller must close the reader.
+func (c *Collection) GetAttachment(docID, name string) (io.ReadCloser, *Attachment, error) {
+	attachments, err := c.ListAttachments(docID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, attachment := range attachments {
+		if attachment.Name != name {
+			continue
+		}
+
+		c.mu.RLock()
+		store := c.blobs
+		c.mu.RUnlock()
+		if store == nil {
+			return nil, nil, fmt.Errorf("collection '%s' has no blob store", c.Name)
+		}
+
+		r, err := store.Open(attachment.Hash)
+		if err != nil {
+			return nil, nil, err
+		}
+		return r, &attachment, nil
+	}
+
+	return nil, nil, fmt.Errorf("document '%s' has no attachment '%s'", docID, name)
+}
+
+// ListAttachments returns the attachments of a document sorted by name
+func (c *Collection) ListAttachments(docID string) ([]Attachment, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	doc, exists := c.Documents[docID]
+	if !exists {
+		return nil, &NotFoundError{DocumentID: docID}
+	}
+	doc, err := c.loadLocked(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	refs, _ := doc.Data[AttachmentsField].(map[string]any)
+	attachments := make([]Attachment, 0, len(refs))
+	for name, ref := range refs {
+		hash, ok := BlobRef(ref)
+		if !ok {
+			continue
+		}
+		size, _ := toFloat(ref.(map[string]any)[BlobSizeKey])
+		attachments = append(attachments, Attachment{Name: name, Hash: hash, Size: int64(size)})
+	}
+
+	sort.Slice(attachments, func(i, j int) bool { return attachments[i].Name < attachments[j].Name })
+	return attachments, nil
+}
+
+// DeleteAttachment removes an attachment from a document. The content is
+// deleted from disk once no document references it anymore.
+func (c *Collection) DeleteAttachment(docID, name string) error {
+	return c.modify(docID, func(doc *Document) error {
+		attachments, _ := doc.Data[AttachmentsField].(map[string]any)
+		if _, exists := attachments[name]; !exists {
+			return fmt.Errorf("document '%s' has no attachment '%s'", docID, name)
+		}
+
+		delete(attachments, name)
+		if len(attachments) == 0 {
+			delete(doc.Data, AttachmentsField)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,266 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// AuditDatabase is the system database holding the audit log. It belongs
+// to no tenant, so tenant-scoped clients never see it.
+const AuditDatabase = "_system"
+
+// AuditCollection is the collection of AuditDatabase holding one document
+// per recorded operation
+const AuditCollection = "audit_log"
+
+// Outcomes of an audited operation
+const (
+	AuditSuccess = "success"
+	AuditFailure = "error"
+)
+
+// auditTimeFormat is how audit timestamps are stored: fixed width, so they
+// sort as strings in time order
+const auditTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// auditPruneInterval is how often Record deletes expired entries
+const auditPruneInterval = time.Minute
+
+// AuditEntry is a mutating operation recorded in the audit log
+type AuditEntry struct {
+	ID        string    `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	// Tenant is the tenant the operation was scoped to, if any
+	Tenant string `json:"tenant,omitempty"`
+	// Actor names who performed the operation, e.g. the MCP client
+	Actor string `json:"actor,omitempty"`
+	// Session is the ID of the client's session, if any
+	Session string `json:"session,omitempty"`
+	// Tool is the operation, e.g. the MCP tool called
+	Tool       string `json:"tool"`
+	Database   string `json:"database,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	DocumentID string `json:"document_id,omitempty"`
+	// Outcome is AuditSuccess or AuditFailure, with the error in Error
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AuditQuery selects audit entries; empty fields match any entry
+type AuditQuery struct {
+	Tenant     string
+	Actor      string
+	Tool       string
+	Database   string
+	Collection string
+	DocumentID string
+	Outcome    string
+	// Since and Until bound the time of the entries, if not zero
+	Since time.Time
+	Until time.Time
+	// Limit caps the number of entries returned, newest first; 0 means no limit
+	Limit int
+}
+
+// AuditLog records operations in the append-only AuditCollection. Entries
+// are logged to the WAL like other writes, and deleted once older than the
+// retention.
+type AuditLog struct {
+	databases *DatabaseManager
+	storage   *StorageManager
+	// retention is how long entries are kept; 0 keeps them forever
+	retention time.Duration
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewAuditLog creates an audit log in the databases of storage, keeping
+// entries for retention (forever if 0)
+func NewAuditLog(databases *DatabaseManager, storage *StorageManager, retention time.Duration) *AuditLog {
+	return &AuditLog{databases: databases, storage: storage, retention: retention}
+}
+
+// Record appends an entry to the audit log, setting its ID and, if zero,
+// its timestamp. Expired entries are deleted along the way.
+func (a *AuditLog) Record(entry *AuditEntry) error {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	entry.Timestamp = entry.Timestamp.UTC()
+	entry.ID = uuid.New().String()
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	coll, err := a.collectionLocked()
+	if err != nil {
+		return err
+	}
+	doc := &Document{ID: entry.ID, Data: auditFields(entry)}
+	if err := coll.Insert(doc); err != nil {
+		return fmt.Errorf("failed to record audit entry: %w", err)
+	}
+	if err := a.storage.LogInsert(AuditDatabase, AuditCollection, doc); err != nil {
+		return fmt.Errorf("failed to log audit entry: %w", err)
+	}
+
+	if a.retention > 0 && time.Since(a.lastPrune) >= auditPruneInterval {
+		a.lastPrune = time.Now()
+		if _, err := a.pruneLocked(coll, a.lastPrune); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Query returns the entries matching q, newest first
+func (a *AuditLog) Query(q AuditQuery) ([]*AuditEntry, error) {
+	database := a.databases.GetDatabase(AuditDatabase)
+	if database == nil {
+		return nil, nil
+	}
+	coll, err := database.GetCollection(AuditCollection)
+	if err != nil {
+		return nil, nil
+	}
+
+	query := &Query{Sort: []SortField{{Field: "timestamp", Descending: true}}, Limit: q.Limit}
+	for field, value := range map[string]string{
+		"tenant":      q.Tenant,
+		"actor":       q.Actor,
+		"tool":        q.Tool,
+		"database":    q.Database,
+		"collection":  q.Collection,
+		"document_id": q.DocumentID,
+		"outcome":     q.Outcome,
+	} {
+		if value != "" {
+			query.Filters = append(query.Filters, QueryFilter{Field: field, Operator: "eq", Value: value})
+		}
+	}
+	if !q.Since.IsZero() {
+		query.Filters = append(query.Filters, QueryFilter{Field: "timestamp", Operator: "gte", Value: q.Since.UTC().Format(auditTimeFormat)})
+	}
+	if !q.Until.IsZero() {
+		query.Filters = append(query.Filters, QueryFilter{Field: "timestamp", Operator: "lte", Value: q.Until.UTC().Format(auditTimeFormat)})
+	}
+
+	docs, err := coll.Find(query)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*AuditEntry, len(docs))
+	for i, doc := range docs {
+		entries[i] = documentAuditEntry(doc)
+	}
+	return entries, nil
+}
+
+// Prune deletes the entries older than the retention at now and returns
+// how many it deleted
+func (a *AuditLog) Prune(now time.Time) (int, error) {
+	if a.retention <= 0 {
+		return 0, nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	database := a.databases.GetDatabase(AuditDatabase)
+	if database == nil {
+		return 0, nil
+	}
+	coll, err := database.GetCollection(AuditCollection)
+	if err != nil {
+		return 0, nil
+	}
+	return a.pruneLocked(coll, now)
+}
+
+// pruneLocked implements Prune (caller must hold mu)
+func (a *AuditLog) pruneLocked(coll *Collection, now time.Time) (int, error) {
+	cutoff := now.Add(-a.retention).UTC().Format(auditTimeFormat)
+	docs, err := coll.Find(&Query{Filters: []QueryFilter{{Field: "timestamp", Operator: "lt", Value: cutoff}}})
+	if err != nil {
+		return 0, err
+	}
+	for i, doc := range docs {
+		if err := coll.Delete(doc.ID); err != nil {
+			return i, fmt.Errorf("failed to delete expired audit entry: %w", err)
+		}
+		if err := a.storage.LogDelete(AuditDatabase, AuditCollection, doc.ID); err != nil {
+			return i, fmt.Errorf("failed to log audit entry deletion: %w", err)
+		}
+	}
+	return len(docs), nil
+}
+
+// collectionLocked returns the audit collection, creating it and its
+// database on first use (caller must hold mu)
+func (a *AuditLog) collectionLocked() (*Collection, error) {
+	database := a.databases.GetDatabase(AuditDatabase)
+	if database == nil {
+		database = a.databases.CreateDatabase(AuditDatabase)
+		if err := a.storage.LogCreateDatabase(AuditDatabase); err != nil {
+			return nil, fmt.Errorf("failed to log create database: %w", err)
+		}
+	}
+	coll, created, err := database.systemCollection(AuditCollection)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		if err := a.storage.LogCreateCollection(AuditDatabase, AuditCollection, nil); err != nil {
+			return nil, fmt.Errorf("failed to log create collection: %w", err)
+		}
+	}
+	return coll, nil
+}
+
+// auditFields returns the document fields of an audit entry
+func auditFields(entry *AuditEntry) map[string]any {
+	fields := map[string]any{
+		"timestamp": entry.Timestamp.Format(auditTimeFormat),
+		"tool":      entry.Tool,
+		"outcome":   entry.Outcome,
+	}
+	for field, value := range map[string]string{
+		"tenant":      entry.Tenant,
+		"actor":       entry.Actor,
+		"session":     entry.Session,
+		"database":    entry.Database,
+		"collection":  entry.Collection,
+		"document_id": entry.DocumentID,
+		"error":       entry.Error,
+	} {
+		if value != "" {
+			fields[field] = value
+		}
+	}
+	return fields
+}
+
+// documentAuditEntry reads an audit entry
+func documentAuditEntry(doc *Document) *AuditEntry {
+	field := func(name string) string {
+		value, _ := doc.Data[name].(string)
+		return value
+	}
+	timestamp, _ := time.Parse(auditTimeFormat, field("timestamp"))
+	return &AuditEntry{
+		ID:         doc.ID,
+		Timestamp:  timestamp,
+		Tenant:     field("tenant"),
+		Actor:      field("actor"),
+		Session:    field("session"),
+		Tool:       field("tool"),
+		Database:   field("database"),
+		Collection: field("collection"),
+		DocumentID: field("document_id"),
+		Outcome:    field("outcome"),
+		Error:      field("error"),
+	}
+}
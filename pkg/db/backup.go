@@ -0,0 +1,252 @@
+package db
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// BackupManifestFile is the file at the top of a backup archive that
+// describes its contents
+const BackupManifestFile = "backup.json"
+
+// BackupVersion is the version of the backup archive layout written by this build
+const BackupVersion = 1
+
+// BackupManifest describes a backup archive. Document counts include the
+// changes in the archive's WAL tail, so they are the counts a restore of
+// the archive must end up with.
+type BackupManifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	// WALOffset is the offset of the first WAL entry not in the backup
+	WALOffset uint64 `json:"wal_offset"`
+	// WALEntries counts the entries of the WAL tail in the archive
+	WALEntries int `json:"wal_entries"`
+	// Databases maps each database in the archive to the document count of
+	// each of its collections
+	Databases map[string]map[string]int `json:"databases"`
+}
+
+// Backup writes a gzipped tar archive of a consistent snapshot of the root
+// directory to w, while writes keep being served (see Snapshot). With a
+// database name only that database is included; "" includes all of them.
+//
+// The archive holds the manifest (BackupManifestFile), the directory of
+// each database, the WAL checkpoint, and a WAL file with the entries for
+// those databases that were logged but not saved when the snapshot was
+// taken.
+func (sm *StorageManager) Backup(w io.Writer, database string) (*BackupManifest, error) {
+	snapDir, err := stagingDir(sm.RootDir, "backup")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	defer os.RemoveAll(snapDir)
+
+	info, err := sm.Snapshot(snapDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// Loading the snapshot counts its documents as a restore will see them
+	snap, err := NewReadOnlyStorageManager(snapDir)
+	if err != nil {
+		return nil, err
+	}
+	defer snap.Close()
+	snap.Format = sm.Format
+	snap.LazyLoad = true
+
+	dm, err := snap.LoadAllDatabases()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load snapshot: %w", err)
+	}
+
+	names := []string{database}
+	if database == "" {
+		names = dm.ListDatabases()
+		sort.Strings(names)
+	}
+
+	manifest := &BackupManifest{
+		Version:   BackupVersion,
+		Created:   info.Created,
+		WALOffset: info.WALOffset,
+		Databases: make(map[string]map[string]int, len(names)),
+	}
+	for _, name := range names {
+		db := dm.GetDatabase(name)
+		if db == nil {
+			return nil, fmt.Errorf("database '%s' not found", name)
+		}
+		counts := make(map[string]int)
+		for _, collName := range db.ListCollections() {
+			coll, err := db.GetCollection(collName)
+			if err != nil {
+				return nil, err
+			}
+			counts[collName] = coll.Count()
+		}
+		manifest.Databases[name] = counts
+	}
+
+	// Only the entries the checkpoint has not covered are needed
+	entries, err := snap.WAL.ReadFrom(snap.WAL.GetCheckpoint().Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot WAL: %w", err)
+	}
+	var tail bytes.Buffer
+	firstOffset := uint64(0)
+	for _, entry := range entries {
+		if _, included := manifest.Databases[entry.Database]; !included {
+			continue
+		}
+		if manifest.WALEntries == 0 {
+			firstOffset = entry.Offset
+		}
+		if _, err := writeWALEntry(&tail, entry); err != nil {
+			return nil, err
+		}
+		manifest.WALEntries++
+	}
+	// A storage manager that has not replayed its WAL may not know the
+	// offsets of the entries in it
+	if n := len(entries); n > 0 && entries[n-1].Offset >= manifest.WALOffset {
+		manifest.WALOffset = entries[n-1].Offset + 1
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTarFile(tw, BackupManifestFile, data, manifest.Created); err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(snapDir, name)); os.IsNotExist(err) {
+			continue // only in the WAL tail so far
+		}
+		if err := addTarDir(tw, snapDir, name); err != nil {
+			return nil, fmt.Errorf("failed to archive database '%s': %w", name, err)
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(snapDir, WALCheckpointFile)); err == nil {
+		if err := writeTarFile(tw, WALCheckpointFile, data, manifest.Created); err != nil {
+			return nil, err
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	if manifest.WALEntries > 0 {
+		if err := writeTarFile(tw, walFileName(firstOffset), tail.Bytes(), manifest.Created); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// BackupFile writes a backup archive (see Backup) to path, replacing it
+// atomically so a failed backup never leaves a truncated archive behind
+func (sm *StorageManager) BackupFile(path, database string) (*BackupManifest, error) {
+	var manifest *BackupManifest
+	err := writeFileAtomic(path, func(w io.Writer) error {
+		var err error
+		manifest, err = sm.Backup(w, database)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// stagingDir creates a temporary directory for a backup or restore, next
+// to the root directory where possible so that files can be hard-linked or
+// renamed between the two
+func stagingDir(rootDir, kind string) (string, error) {
+	root, err := filepath.Abs(rootDir)
+	if err != nil {
+		return "", err
+	}
+	if dir, err := os.MkdirTemp(filepath.Dir(root), "."+filepath.Base(root)+"-"+kind+"-*"); err == nil {
+		return dir, nil
+	}
+	return os.MkdirTemp("", "cachydb-"+kind+"-*")
+}
+
+// writeTarFile adds a regular file with the given content to an archive
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// addTarDir adds the directory name of root, and everything in it, to an archive
+func addTarDir(tw *tar.Writer, root, name string) error {
+	return filepath.WalkDir(filepath.Join(root, name), func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !entry.IsDir() && !entry.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
@@ -13,28 +13,61 @@ const (
 	// Magic number for collection data files
 	CollectionMagic = 0x43414348 // "CACH" in hex
 
-	// Version for binary format
+	// Version for binary format written by this build
 	BinaryFormatVersion = 1
 
+	// Oldest binary format version this build can read
+	MinBinaryFormatVersion = 1
+
 	// Header size: magic(4) + version(2) + flags(2) = 8 bytes
 	HeaderSize = 8
 
 	// Document entry header: offset(8) + size(4) + compressed_size(4) + checksum(4) = 20 bytes
 	DocEntryHeaderSize = 20
+
+	// FlagCompressed is set in the header flags when documents are compressed;
+	// on its own it means gzip (see codecFlags for the other codecs)
+	FlagCompressed = 1
 )
 
 // BinaryHeader represents the file header for binary storage
 type BinaryHeader struct {
 	Magic   uint32 // Magic number to identify file type
 	Version uint16 // Format version
-	Flags   uint16 // Flags (bit 0: compressed)
+	Flags   uint16 // Flags (bit 0: compressed, bits 2-3: codec other than gzip)
+}
+
+// FormatVersionError reports a data file whose format version this build cannot read
+type FormatVersionError struct {
+	Path    string
+	Version uint16
+}
+
+func (e *FormatVersionError) Error() string {
+	if e.Version > BinaryFormatVersion {
+		return fmt.Sprintf("%s uses binary format version %d, but this build of CachyDB only supports versions %d to %d. Please upgrade CachyDB to load this data",
+			e.Path, e.Version, MinBinaryFormatVersion, BinaryFormatVersion)
+	}
+	return fmt.Sprintf("%s uses binary format version %d, which is older than the oldest supported version %d. Run 'cachydb utils migrate' with a CachyDB release that still supports it first",
+		e.Path, e.Version, MinBinaryFormatVersion)
+}
+
+// checkFormatVersion validates a header read from path
+func checkFormatVersion(path string, header *BinaryHeader) error {
+	if header.Magic != CollectionMagic {
+		return fmt.Errorf("invalid magic number: expected 0x%X, got 0x%X", CollectionMagic, header.Magic)
+	}
+	if header.Version < MinBinaryFormatVersion || header.Version > BinaryFormatVersion {
+		return &FormatVersionError{Path: path, Version: header.Version}
+	}
+	return nil
 }
 
 // DocumentEntry represents a single document entry in the binary file
 type DocumentEntry struct {
 	Offset         int64  // Offset in the data file
 	Size           uint32 // Original size
-	CompressedSize uint32 // Size after compression (0 if not compressed)
+	CompressedSize uint32 // Stored size (equal to Size in uncompressed files)
 	Checksum       uint32 // CRC32 checksum
 }
 
@@ -49,10 +82,19 @@ type BinaryCollectionWriter struct {
 	indexFile *os.File
 	offset    int64
 	index     *OffsetIndex
+	codec     Codec
+	// replacing is the data file path a recoded file is renamed over on
+	// the next Flush; the file being written sits next to it until then
+	replacing string
 }
 
-// NewBinaryCollectionWriter creates a new binary collection writer
+// NewBinaryCollectionWriter creates a new binary collection writer that compresses documents
 func NewBinaryCollectionWriter(dataDir, dbName, collName string) (*BinaryCollectionWriter, error) {
+	return newBinaryCollectionWriter(dataDir, dbName, collName, CodecGzip)
+}
+
+// newBinaryCollectionWriter creates a binary collection writer that stores documents with a codec
+func newBinaryCollectionWriter(dataDir, dbName, collName string, codec Codec) (*BinaryCollectionWriter, error) {
 	collDir := filepath.Join(dataDir, dbName, collName)
 	if err := os.MkdirAll(collDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create collection directory: %w", err)
@@ -79,20 +121,58 @@ func NewBinaryCollectionWriter(dataDir, dbName, collName string) (*BinaryCollect
 		index: &OffsetIndex{
 			Entries: make(map[string]*DocumentEntry),
 		},
+		codec: codec,
+	}
+
+	fresh := stat.Size() == 0
+	recoding := false
+	if !fresh {
+		header, err := readHeader(dataFile)
+		if err != nil {
+			dataFile.Close()
+			return nil, fmt.Errorf("failed to read header: %w", err)
+		}
+		if err := checkFormatVersion(dataPath, header); err != nil {
+			dataFile.Close()
+			return nil, err
+		}
+
+		// Never append entries compressed with another codec than the rest
+		// of the file: write a new one next to it, renamed over it by Flush
+		// like a compaction. Callers write every document of the collection,
+		// so nothing is lost, and a crash before the rename keeps the old file.
+		if codecOf(header.Flags) != codec {
+			dataFile.Close()
+			dataFile, err = os.OpenFile(dataPath+compactSuffix, os.O_CREATE|os.O_RDWR|os.O_TRUNC, 0644)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create data file for codec change: %w", err)
+			}
+			writer.dataFile, writer.replacing = dataFile, dataPath
+			fresh, recoding = true, true
+		}
 	}
 
 	// Write header if file is new
-	if stat.Size() == 0 {
+	if fresh {
+		if _, err := dataFile.Seek(0, io.SeekStart); err != nil {
+			dataFile.Close()
+			return nil, err
+		}
 		if err := writer.writeHeader(); err != nil {
 			dataFile.Close()
 			return nil, fmt.Errorf("failed to write header: %w", err)
 		}
+	} else if _, err := dataFile.Seek(0, io.SeekEnd); err != nil {
+		dataFile.Close()
+		return nil, err
 	}
 
-	// Try to load existing index
-	existingIndex, err := LoadOffsetIndex(dataDir, dbName, collName)
-	if err == nil {
-		writer.index = existingIndex
+	// Try to load existing index (a recoded file starts with an empty one)
+	if !recoding {
+		existingIndex, err := LoadOffsetIndex(dataDir, dbName, collName)
+		if err == nil {
+			writer.index = existingIndex
+		}
 	}
 
 	return writer, nil
@@ -103,7 +183,7 @@ func (w *BinaryCollectionWriter) writeHeader() error {
 	header := BinaryHeader{
 		Magic:   CollectionMagic,
 		Version: BinaryFormatVersion,
-		Flags:   1, // Bit 0 set = compressed
+		Flags:   codecFlags(w.codec),
 	}
 
 	buf := make([]byte, HeaderSize)
@@ -128,20 +208,20 @@ func (w *BinaryCollectionWriter) WriteDocument(doc *Document) error {
 		return fmt.Errorf("failed to marshal document: %w", err)
 	}
 
-	// Compress the data
-	compressedData, err := Compress(jsonData)
+	// Compress the data (stored as is when compression is off)
+	storedData, err := w.codec.compress(jsonData)
 	if err != nil {
 		return fmt.Errorf("failed to compress document: %w", err)
 	}
 
 	// Calculate checksum
-	checksum := crc32.ChecksumIEEE(compressedData)
+	checksum := crc32.ChecksumIEEE(storedData)
 
 	// Create entry header
 	entryBuf := make([]byte, DocEntryHeaderSize)
 	binary.LittleEndian.PutUint64(entryBuf[0:8], uint64(w.offset))
 	binary.LittleEndian.PutUint32(entryBuf[8:12], uint32(len(jsonData)))
-	binary.LittleEndian.PutUint32(entryBuf[12:16], uint32(len(compressedData)))
+	binary.LittleEndian.PutUint32(entryBuf[12:16], uint32(len(storedData)))
 	binary.LittleEndian.PutUint32(entryBuf[16:20], checksum)
 
 	// Write entry header + compressed data
@@ -149,7 +229,7 @@ func (w *BinaryCollectionWriter) WriteDocument(doc *Document) error {
 		return fmt.Errorf("failed to write entry header: %w", err)
 	}
 
-	if _, err := w.dataFile.Write(compressedData); err != nil {
+	if _, err := w.dataFile.Write(storedData); err != nil {
 		return fmt.Errorf("failed to write compressed data: %w", err)
 	}
 
@@ -157,27 +237,42 @@ func (w *BinaryCollectionWriter) WriteDocument(doc *Document) error {
 	w.index.Entries[doc.ID] = &DocumentEntry{
 		Offset:         w.offset,
 		Size:           uint32(len(jsonData)),
-		CompressedSize: uint32(len(compressedData)),
+		CompressedSize: uint32(len(storedData)),
 		Checksum:       checksum,
 	}
 
 	// Update offset for next write
-	w.offset += int64(DocEntryHeaderSize + len(compressedData))
+	w.offset += int64(DocEntryHeaderSize + len(storedData))
 
 	return nil
 }
 
-// Flush syncs the data file and saves the index
+// Flush syncs the data file and saves the index. A recoded data file and
+// its index are renamed over the old ones, data first, as compactBinary does.
 func (w *BinaryCollectionWriter) Flush(dataDir, dbName, collName string) error {
 	if err := w.dataFile.Sync(); err != nil {
 		return fmt.Errorf("failed to sync data file: %w", err)
 	}
 
-	if err := SaveOffsetIndex(w.index, dataDir, dbName, collName); err != nil {
-		return fmt.Errorf("failed to save index: %w", err)
+	if w.replacing == "" {
+		if err := SaveOffsetIndex(w.index, dataDir, dbName, collName); err != nil {
+			return fmt.Errorf("failed to save index: %w", err)
+		}
+		return nil
 	}
 
-	return nil
+	indexPath := filepath.Join(dataDir, dbName, collName, "collection.idx")
+	if err := writeOffsetIndex(w.index, indexPath+compactSuffix); err != nil {
+		return fmt.Errorf("failed to save index: %w", err)
+	}
+	if err := os.Rename(w.replacing+compactSuffix, w.replacing); err != nil {
+		return fmt.Errorf("failed to replace data file: %w", err)
+	}
+	w.replacing = ""
+	if err := os.Rename(indexPath+compactSuffix, indexPath); err != nil {
+		return fmt.Errorf("failed to replace index: %w", err)
+	}
+	return syncDir(filepath.Dir(indexPath))
 }
 
 // Close closes the writer and saves the index
@@ -193,6 +288,8 @@ func (w *BinaryCollectionWriter) Close(dataDir, dbName, collName string) error {
 type BinaryCollectionReader struct {
 	dataFile *os.File
 	index    *OffsetIndex
+	version  uint16 // format version of the data file
+	codec    Codec
 }
 
 // NewBinaryCollectionReader creates a new binary collection reader
@@ -216,9 +313,9 @@ func NewBinaryCollectionReader(dataDir, dbName, collName string) (*BinaryCollect
 		return nil, fmt.Errorf("failed to read header: %w", err)
 	}
 
-	if header.Magic != CollectionMagic {
+	if err := checkFormatVersion(dataPath, header); err != nil {
 		dataFile.Close()
-		return nil, fmt.Errorf("invalid magic number: expected 0x%X, got 0x%X", CollectionMagic, header.Magic)
+		return nil, err
 	}
 
 	// Load index
@@ -231,9 +328,47 @@ func NewBinaryCollectionReader(dataDir, dbName, collName string) (*BinaryCollect
 	return &BinaryCollectionReader{
 		dataFile: dataFile,
 		index:    index,
+		version:  header.Version,
+		codec:    codecOf(header.Flags),
 	}, nil
 }
 
+// Version returns the format version of the data file being read
+func (r *BinaryCollectionReader) Version() uint16 {
+	return r.version
+}
+
+// BinaryFormatVersionOf returns the format version of a collection's data file
+func BinaryFormatVersionOf(dataDir, dbName, collName string) (uint16, error) {
+	f, err := os.Open(filepath.Join(dataDir, dbName, collName, "collection.data"))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	header, err := readHeader(f)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read header: %w", err)
+	}
+	return header.Version, nil
+}
+
+// binaryFileNeedsRewrite reports whether a writer would start a collection's
+// data file over because it uses another codec
+func binaryFileNeedsRewrite(dataDir, dbName, collName string, codec Codec) bool {
+	f, err := os.Open(filepath.Join(dataDir, dbName, collName, "collection.data"))
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	header, err := readHeader(f)
+	if err != nil {
+		return false
+	}
+	return codecOf(header.Flags) != codec
+}
+
 // readHeader reads and validates the file header
 func readHeader(f *os.File) (*BinaryHeader, error) {
 	buf := make([]byte, HeaderSize)
@@ -264,14 +399,14 @@ func (r *BinaryCollectionReader) ReadDocument(docID string) (*Document, error) {
 	}
 
 	// Verify checksum
-	compressedData := buf[DocEntryHeaderSize:]
-	checksum := crc32.ChecksumIEEE(compressedData)
+	storedData := buf[DocEntryHeaderSize:]
+	checksum := crc32.ChecksumIEEE(storedData)
 	if checksum != entry.Checksum {
 		return nil, fmt.Errorf("checksum mismatch for document %s", docID)
 	}
 
 	// Decompress
-	jsonData, err := Decompress(compressedData)
+	jsonData, err := r.codec.decompress(storedData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decompress document: %w", err)
 	}
@@ -307,47 +442,46 @@ func (r *BinaryCollectionReader) Close() error {
 
 // SaveOffsetIndex saves the offset index to disk
 func SaveOffsetIndex(index *OffsetIndex, dataDir, dbName, collName string) error {
-	indexPath := filepath.Join(dataDir, dbName, collName, "collection.idx")
-
-	f, err := os.Create(indexPath)
-	if err != nil {
-		return fmt.Errorf("failed to create index file: %w", err)
-	}
-	defer f.Close()
-
-	// Write number of entries
-	numEntries := uint32(len(index.Entries))
-	if err := binary.Write(f, binary.LittleEndian, numEntries); err != nil {
-		return fmt.Errorf("failed to write entry count: %w", err)
-	}
+	return writeOffsetIndex(index, filepath.Join(dataDir, dbName, collName, "collection.idx"))
+}
 
-	// Write each entry
-	for docID, entry := range index.Entries {
-		// Write document ID length + ID
-		idLen := uint32(len(docID))
-		if err := binary.Write(f, binary.LittleEndian, idLen); err != nil {
-			return err
-		}
-		if _, err := f.Write([]byte(docID)); err != nil {
-			return err
+// writeOffsetIndex atomically writes an offset index file at path
+func writeOffsetIndex(index *OffsetIndex, indexPath string) error {
+	return writeFileAtomic(indexPath, func(w io.Writer) error {
+		// Write number of entries
+		numEntries := uint32(len(index.Entries))
+		if err := binary.Write(w, binary.LittleEndian, numEntries); err != nil {
+			return fmt.Errorf("failed to write entry count: %w", err)
 		}
 
-		// Write entry data
-		if err := binary.Write(f, binary.LittleEndian, entry.Offset); err != nil {
-			return err
-		}
-		if err := binary.Write(f, binary.LittleEndian, entry.Size); err != nil {
-			return err
+		// Write each entry
+		for docID, entry := range index.Entries {
+			// Write document ID length + ID
+			idLen := uint32(len(docID))
+			if err := binary.Write(w, binary.LittleEndian, idLen); err != nil {
+				return err
+			}
+			if _, err := w.Write([]byte(docID)); err != nil {
+				return err
+			}
+
+			// Write entry data
+			if err := binary.Write(w, binary.LittleEndian, entry.Offset); err != nil {
+				return err
+			}
+			if err := binary.Write(w, binary.LittleEndian, entry.Size); err != nil {
+				return err
+			}
+			if err := binary.Write(w, binary.LittleEndian, entry.CompressedSize); err != nil {
+				return err
+			}
+			if err := binary.Write(w, binary.LittleEndian, entry.Checksum); err != nil {
+				return err
+			}
 		}
-		if err := binary.Write(f, binary.LittleEndian, entry.CompressedSize); err != nil {
-			return err
-		}
-		if err := binary.Write(f, binary.LittleEndian, entry.Checksum); err != nil {
-			return err
-		}
-	}
 
-	return nil
+		return nil
+	})
 }
 
 // LoadOffsetIndex loads the offset index from disk
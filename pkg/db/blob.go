@@ -0,0 +1,318 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Keys of a blob reference object, which replaces a value stored in a blob file:
+// {"$blob": "<sha256>", "$size": <bytes>}
+const (
+	BlobRefKey  = "$blob"
+	BlobSizeKey = "$size"
+)
+
+// BlobDirName is the directory inside a collection directory holding blob files
+const BlobDirName = "blobs"
+
+// minSpillSize is the smallest encoded value spilled to satisfy MaxInlineSize;
+// anything smaller would not shrink the document once replaced by a reference
+const minSpillSize = 128
+
+// BlobPolicy decides which document values are spilled to blob files
+type BlobPolicy struct {
+	// MaxInlineSize is the largest encoded document size (in bytes) kept inline.
+	// Larger documents have their biggest top-level fields moved to blobs until
+	// they fit. Zero disables the size limit.
+	MaxInlineSize int `json:"max_inline_size,omitempty"`
+	// Fields are always stored as blobs, whatever their size
+	Fields []string `json:"fields,omitempty"`
+}
+
+// BlobStore keeps content-addressed blob files in a directory
+type BlobStore struct {
+	dir string
+}
+
+// NewBlobStore creates a blob store rooted at dir (created on first write)
+func NewBlobStore(dir string) *BlobStore {
+	return &BlobStore{dir: dir}
+}
+
+// Put stores data and returns its SHA-256 hash. Storing the same content
+// twice is a no-op. The file is synced before Put returns.
+func (bs *BlobStore) Put(r io.Reader) (string, int64, error) {
+	if err := os.MkdirAll(bs.dir, 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(bs.dir, ".tmp-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create blob file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to write blob: %w", err)
+	}
+
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if _, err := os.Stat(bs.path(sum)); err == nil {
+		return sum, size, nil
+	}
+	if err := os.Rename(tmp.Name(), bs.path(sum)); err != nil {
+		return "", 0, fmt.Errorf("failed to store blob: %w", err)
+	}
+	if err := syncDir(bs.dir); err != nil {
+		return "", 0, fmt.Errorf("failed to store blob: %w", err)
+	}
+	return sum, size, nil
+}
+
+// Open returns a reader for a stored blob. The caller must close it.
+func (bs *BlobStore) Open(hash string) (io.ReadCloser, error) {
+	if !validBlobHash(hash) {
+		return nil, fmt.Errorf("invalid blob hash '%s'", hash)
+	}
+	f, err := os.Open(bs.path(hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("blob '%s' not found", hash)
+		}
+		return nil, err
+	}
+	return f, nil
+}
+
+// Collect removes every blob whose hash is not in referenced
+func (bs *BlobStore) Collect(referenced map[string]bool) error {
+	entries, err := os.ReadDir(bs.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || referenced[name] {
+			continue
+		}
+		if err := os.Remove(filepath.Join(bs.dir, name)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// sibling returns the blob store of another collection of the same
+// database, whose directory is next to this store's collection directory
+func (bs *BlobStore) sibling(collName string) *BlobStore {
+	dbDir := filepath.Dir(filepath.Dir(bs.dir))
+	return NewBlobStore(filepath.Join(dbDir, collName, BlobDirName))
+}
+
+func (bs *BlobStore) path(hash string) string {
+	return filepath.Join(bs.dir, hash)
+}
+
+func validBlobHash(hash string) bool {
+	if len(hash) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// BlobRef returns the blob hash if value is a blob reference
+func BlobRef(value any) (string, bool) {
+	m, ok := value.(map[string]any)
+	if !ok {
+		return "", false
+	}
+	hash, ok := m[BlobRefKey].(string)
+	return hash, ok
+}
+
+// SetBlobStore attaches the store used for spilled values and attachments
+func (c *Collection) SetBlobStore(store *BlobStore) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blobs = store
+}
+
+// SetBlobPolicy configures blob spillover for documents written from now on.
+// A nil policy keeps every value inline.
+func (c *Collection) SetBlobPolicy(policy *BlobPolicy) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if policy != nil && c.blobs == nil {
+		return fmt.Errorf("collection '%s' has no blob store", c.Name)
+	}
+	if policy != nil && policy.MaxInlineSize < 0 {
+		return fmt.Errorf("max inline size cannot be negative")
+	}
+	c.BlobPolicy = policy
+	return nil
+}
+
+// ResolveBlobs returns a copy of doc with every blob reference replaced by its value
+func (c *Collection) ResolveBlobs(doc *Document) (*Document, error) {
+	c.mu.RLock()
+	store := c.blobs
+	c.mu.RUnlock()
+
+	resolved := doc.DeepClone()
+	if store == nil {
+		return resolved, nil
+	}
+	if err := resolveBlobsIn(store, resolved.Data); err != nil {
+		return nil, fmt.Errorf("document '%s': %w", doc.ID, err)
+	}
+	return resolved, nil
+}
+
+// resolveBlobsIn replaces top-level blob references in data with their values
+func resolveBlobsIn(store *BlobStore, data map[string]any) error {
+	for field, value := range data {
+		hash, ok := BlobRef(value)
+		if !ok {
+			continue
+		}
+
+		r, err := store.Open(hash)
+		if err != nil {
+			return fmt.Errorf("field '%s': %w", field, err)
+		}
+		var decoded any
+		err = json.NewDecoder(r).Decode(&decoded)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("field '%s': failed to decode blob: %w", field, err)
+		}
+		data[field] = decoded
+	}
+	return nil
+}
+
+// spillLocked moves values out of doc into blob files according to the
+// collection's blob policy (caller must hold mu)
+func (c *Collection) spillLocked(doc *Document) error {
+	policy := c.BlobPolicy
+	if policy == nil || c.blobs == nil {
+		return nil
+	}
+
+	for _, field := range policy.Fields {
+		if value, exists := doc.Data[field]; exists {
+			if err := c.spillField(doc, field, value); err != nil {
+				return err
+			}
+		}
+	}
+
+	if policy.MaxInlineSize <= 0 {
+		return nil
+	}
+
+	for {
+		encoded, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if len(encoded) <= policy.MaxInlineSize {
+			return nil
+		}
+
+		// Spill the largest remaining inline field
+		field, size := "", minSpillSize
+		fields := make([]string, 0, len(doc.Data))
+		for name := range doc.Data {
+			fields = append(fields, name)
+		}
+		sort.Strings(fields)
+		for _, name := range fields {
+			if _, isRef := BlobRef(doc.Data[name]); isRef || name == AttachmentsField || name == RevField {
+				continue
+			}
+			value, _ := json.Marshal(doc.Data[name])
+			if len(value) > size {
+				field, size = name, len(value)
+			}
+		}
+		if field == "" {
+			return nil // nothing left worth spilling
+		}
+		if err := c.spillField(doc, field, doc.Data[field]); err != nil {
+			return err
+		}
+	}
+}
+
+// spillField stores one value as a blob and replaces it with a reference
+func (c *Collection) spillField(doc *Document, field string, value any) error {
+	if _, isRef := BlobRef(value); isRef {
+		return nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("field '%s': %w", field, err)
+	}
+	hash, size, err := c.blobs.Put(strings.NewReader(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("field '%s': %w", field, err)
+	}
+
+	doc.Data[field] = map[string]any{BlobRefKey: hash, BlobSizeKey: float64(size)}
+	return nil
+}
+
+// referencedBlobsLocked returns the hashes of all blobs referenced by the collection
+// (caller must hold mu)
+func (c *Collection) referencedBlobsLocked() (map[string]bool, error) {
+	referenced := make(map[string]bool)
+	var walk func(value any)
+	walk = func(value any) {
+		switch v := value.(type) {
+		case map[string]any:
+			if hash, ok := BlobRef(v); ok {
+				referenced[hash] = true
+				return
+			}
+			for _, item := range v {
+				walk(item)
+			}
+		case []any:
+			for _, item := range v {
+				walk(item)
+			}
+		}
+	}
+
+	for _, doc := range c.Documents {
+		doc, err := c.loadLocked(doc)
+		if err != nil {
+			return nil, err
+		}
+		walk(doc.Data)
+	}
+	return referenced, nil
+}
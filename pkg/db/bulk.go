@@ -0,0 +1,53 @@
+package db
+
+import (
+	"fmt"
+)
+
+// WriteOp is one write of a BulkWrite
+type WriteOp struct {
+	Op       string         `json:"op"`                 // TxInsert, TxUpdate or TxDelete
+	ID       string         `json:"id,omitempty"`       // required for updates and deletes; generated for inserts without one
+	Document map[string]any `json:"document,omitempty"` // insert only
+	Updates  map[string]any `json:"updates,omitempty"`  // update only, in the same forms as Update
+}
+
+// BulkWrite applies a batch of inserts, updates and deletes in order under
+// a single lock acquisition. The batch is all or nothing: if an operation
+// fails, the ones before it are undone and the error is returned. Log the
+// results with StorageManager.LogTransaction, which writes them as one WAL
+// entry.
+func (c *Collection) BulkWrite(writes []WriteOp) ([]TxResult, error) {
+	ops := make([]txOp, len(writes))
+	for i, write := range writes {
+		op := txOp{kind: write.Op, coll: c, id: write.ID}
+		var err error
+		switch write.Op {
+		case TxInsert:
+			if err = CheckUserFields(write.Document); err == nil {
+				op.doc = &Document{ID: write.ID, Data: write.Document}
+			}
+		case TxUpdate:
+			op.mutate, err = userUpdate(write.Updates)
+		case TxDelete:
+		default:
+			err = fmt.Errorf("unknown operation '%s'", write.Op)
+		}
+		if err == nil && write.ID == "" && write.Op != TxInsert {
+			err = fmt.Errorf("%s needs an id", write.Op)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("bulk write operation %d: %w", i+1, err)
+		}
+		ops[i] = op
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	results, err := applyTxOpsLocked(ops)
+	if err != nil {
+		return nil, fmt.Errorf("bulk write %w", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ChangeEvent is a change to one document, as logged to the WAL
+type ChangeEvent struct {
+	Offset     uint64    `json:"offset"`
+	Timestamp  time.Time `json:"timestamp"`
+	Database   string    `json:"database"`
+	Collection string    `json:"collection"`
+	// Operation is insert, update, upsert or delete
+	Operation  string `json:"operation"`
+	DocumentID string `json:"document_id"`
+	// Document is the stored document after the change; nil for deletes
+	Document *Document `json:"document,omitempty"`
+}
+
+// ChangeEvents returns the document changes a WAL entry logs, one for each
+// document a transaction wrote. Entries that change no documents, such as
+// a created collection, have none.
+func ChangeEvents(entry *WALEntry) ([]ChangeEvent, error) {
+	event := ChangeEvent{
+		Offset:     entry.Offset,
+		Timestamp:  entry.Timestamp,
+		Database:   entry.Database,
+		Collection: entry.Collection,
+		Operation:  entry.Operation,
+		DocumentID: entry.DocumentID,
+	}
+
+	switch entry.Operation {
+	case WALOpInsert, WALOpUpdate, WALOpUpsert:
+		var doc Document
+		if err := json.Unmarshal(entry.Data, &doc); err != nil {
+			return nil, fmt.Errorf("failed to decode document: %w", err)
+		}
+		event.Document = &doc
+		return []ChangeEvent{event}, nil
+
+	case WALOpDelete:
+		return []ChangeEvent{event}, nil
+
+	case WALOpTransaction:
+		var results []TxResult
+		if err := json.Unmarshal(entry.Data, &results); err != nil {
+			return nil, fmt.Errorf("failed to decode transaction: %w", err)
+		}
+		events := make([]ChangeEvent, len(results))
+		for i, result := range results {
+			events[i] = event
+			events[i].Collection = result.Collection
+			events[i].Operation = result.Op
+			events[i].DocumentID = result.ID
+			events[i].Document = result.Document
+		}
+		return events, nil
+	}
+	return nil, nil
+}
+
+// WatchChanges calls fn with every document change logged to the WAL from
+// now on, in order, until ctx ends or fn returns an error, and returns that
+// error. Changes reach fn once their entry is written to the WAL file, so
+// batched entries arrive up to the flush interval late. Changes to
+// ephemeral collections are not logged and never reach fn.
+func (sm *StorageManager) WatchChanges(ctx context.Context, fn func(ChangeEvent) error) error {
+	return sm.WAL.Follow(ctx, sm.WAL.NextOffset(), func(entry *WALEntry) error {
+		events, err := ChangeEvents(entry)
+		if err != nil {
+			return fmt.Errorf("WAL entry at offset %d: %w", entry.Offset, err)
+		}
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Follow calls fn with every entry of the WAL from offset from on, in
+// order, first those already in the WAL files and then each one as it is
+// written, until ctx ends or fn returns an error, and returns that error.
+// If entries from offset from on were already removed by retention, it
+// returns ErrWALTruncated when it comes to the gap.
+func (wm *WALManager) Follow(ctx context.Context, from uint64, fn func(*WALEntry) error) error {
+	next := from
+	deliver := func(entry *WALEntry) error {
+		if entry.Offset < next {
+			return nil // delivered already
+		}
+		if entry.Offset > next {
+			return fmt.Errorf("%w: the WAL continues at offset %d, after offset %d", ErrWALTruncated, entry.Offset, next)
+		}
+		next = entry.Offset + 1
+		return fn(entry)
+	}
+
+	for {
+		// Subscribed before reading the files, so no entry written in
+		// between is missed; the ones in both are delivered once
+		live, cancel := wm.Subscribe()
+		entries, err := wm.ReadFrom(next)
+		if err != nil {
+			cancel()
+			return err
+		}
+		for _, entry := range entries {
+			if err := deliver(entry); err != nil {
+				cancel()
+				return err
+			}
+		}
+
+		err = followLive(ctx, live, deliver)
+		cancel()
+		if err != nil {
+			return err
+		}
+		// Dropped for falling behind: catch up from the files again
+	}
+}
+
+// followLive delivers the entries arriving on live until ctx ends, deliver
+// fails, or live is closed
+func followLive(ctx context.Context, live <-chan *WALEntry, deliver func(*WALEntry) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case entry, ok := <-live:
+			if !ok {
+				return nil
+			}
+			if err := deliver(entry); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"fmt"
+	"time"
+)
+
+// CheckpointPolicy triggers checkpoints between the periodic syncs, so a
+// write-heavy server saves its changes and bounds the WAL to replay after a
+// crash before the next sync is due. Zero fields are disabled.
+type CheckpointPolicy struct {
+	// Entries checkpoints once this many WAL entries were logged since the
+	// last checkpoint
+	Entries int `json:"entries,omitempty"`
+	// Bytes checkpoints once this many bytes were written to the WAL since
+	// the last checkpoint
+	Bytes int64 `json:"bytes,omitempty"`
+}
+
+// What triggered a checkpoint (CheckpointStats.LastTrigger)
+const (
+	CheckpointTriggerInterval = "interval" // the periodic sync
+	CheckpointTriggerEntries  = "entries"  // CheckpointPolicy.Entries
+	CheckpointTriggerBytes    = "bytes"    // CheckpointPolicy.Bytes
+	CheckpointTriggerManual   = "manual"   // ForceCheckpoint
+)
+
+// CheckpointStats reports the checkpoints of a storage manager since it was
+// created. The duration of a checkpoint covers saving the changed
+// collections as well as moving the WAL checkpoint.
+type CheckpointStats struct {
+	Count         int64         `json:"count"`
+	Offset        uint64        `json:"offset"` // first WAL offset not yet saved
+	Last          time.Time     `json:"last,omitzero"`
+	LastTrigger   string        `json:"last_trigger,omitempty"`
+	LastDuration  time.Duration `json:"last_duration"`
+	TotalDuration time.Duration `json:"total_duration"`
+	// PendingEntries and PendingBytes were logged since the last checkpoint
+	PendingEntries uint64 `json:"pending_entries"`
+	PendingBytes   int64  `json:"pending_bytes"`
+}
+
+// CheckpointStats returns the checkpoint statistics
+func (sm *StorageManager) CheckpointStats() CheckpointStats {
+	sm.checkpointMu.Lock()
+	stats := sm.checkpointStats
+	sm.checkpointMu.Unlock()
+
+	stats.Offset = sm.WAL.GetCheckpoint().Offset
+	stats.PendingEntries, stats.PendingBytes = sm.WAL.SinceCheckpoint()
+	return stats
+}
+
+// recordCheckpoint adds a checkpoint that took duration to the statistics
+func (sm *StorageManager) recordCheckpoint(trigger string, duration time.Duration) {
+	sm.checkpointMu.Lock()
+	defer sm.checkpointMu.Unlock()
+
+	sm.checkpointStats.Count++
+	sm.checkpointStats.Last = time.Now()
+	sm.checkpointStats.LastTrigger = trigger
+	sm.checkpointStats.LastDuration = duration
+	sm.checkpointStats.TotalDuration += duration
+}
+
+// ForceCheckpoint saves every changed database and collection now and moves
+// the WAL checkpoint past the entries logged so far, instead of waiting for
+// the next sync. Unlike the background sync, it fails without moving the
+// checkpoint when a collection cannot be saved.
+func (sm *StorageManager) ForceCheckpoint() (CheckpointStats, error) {
+	if sm.ReadOnly {
+		return CheckpointStats{}, ErrReadOnly
+	}
+	if sm.dbManager == nil {
+		return CheckpointStats{}, fmt.Errorf("no databases are loaded")
+	}
+	if err := sm.syncAndCheckpoint(CheckpointTriggerManual, true); err != nil {
+		return CheckpointStats{}, err
+	}
+	return sm.CheckpointStats(), nil
+}
+
+// checkCheckpointPolicy wakes the background syncer when the WAL grew past
+// a threshold of the checkpoint policy. A checkpoint already requested is
+// not requested again.
+func (sm *StorageManager) checkCheckpointPolicy() {
+	policy := sm.CheckpointPolicy
+	if policy.Entries <= 0 && policy.Bytes <= 0 {
+		return
+	}
+
+	entries, bytes := sm.WAL.SinceCheckpoint()
+	var trigger string
+	switch {
+	case policy.Entries > 0 && entries >= uint64(policy.Entries):
+		trigger = CheckpointTriggerEntries
+	case policy.Bytes > 0 && bytes >= policy.Bytes:
+		trigger = CheckpointTriggerBytes
+	default:
+		return
+	}
+
+	select {
+	case sm.checkpointNow <- trigger:
+	default:
+	}
+}
@@ -0,0 +1,166 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Collation controls how strings are ordered and compared by sorts and
+// filters. It can be set per collection and overridden per query.
+type Collation struct {
+	// Locale is a BCP 47 language tag such as "en", "de" or "sv" ("" uses the root collation)
+	Locale string `json:"locale,omitempty"`
+	// CaseInsensitive treats "a" and "A" as equal
+	CaseInsensitive bool `json:"case_insensitive,omitempty"`
+	// Numeric compares digit sequences by value, so "file2" sorts before "file10"
+	Numeric bool `json:"numeric,omitempty"`
+}
+
+// SortField orders query results by one field
+type SortField struct {
+	Field      string `json:"field"`
+	Descending bool   `json:"desc,omitempty"`
+}
+
+// Validate checks that the locale is a well-formed language tag
+func (c *Collation) Validate() error {
+	if c.Locale == "" {
+		return nil
+	}
+	if _, err := language.Parse(c.Locale); err != nil {
+		return fmt.Errorf("invalid collation locale '%s': %w", c.Locale, err)
+	}
+	return nil
+}
+
+// newCollator builds a collator for c. Collators are not safe for concurrent
+// use, so each query builds its own.
+func (c *Collation) newCollator() (*collate.Collator, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	tag := language.Und
+	if c.Locale != "" {
+		tag = language.Make(c.Locale)
+	}
+
+	var opts []collate.Option
+	if c.CaseInsensitive {
+		opts = append(opts, collate.IgnoreCase)
+	}
+	if c.Numeric {
+		opts = append(opts, collate.Numeric)
+	}
+	return collate.New(tag, opts...), nil
+}
+
+// SetCollation sets the collection's default collation (nil restores plain byte-wise ordering)
+func (c *Collection) SetCollation(collation *Collation) error {
+	if collation != nil {
+		if err := collation.Validate(); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Collation = collation
+	return nil
+}
+
+// collatorFor returns the collator for a query: the query's collation if set,
+// else the collection's, else nil (caller must hold mu)
+func (c *Collection) collatorFor(query *Query) (*collate.Collator, error) {
+	collation := query.Collation
+	if collation == nil {
+		collation = c.Collation
+	}
+	if collation == nil {
+		return nil, nil
+	}
+	return collation.newCollator()
+}
+
+// sortDocuments orders docs by the given fields. Documents missing a field
+// sort before those that have it; values of different types are ordered
+// null < boolean < number < string < anything else.
+func sortDocuments(docs []*Document, fields []SortField, col *collate.Collator) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		for _, field := range fields {
+			a, _ := docs[i].GetValue(field.Field)
+			b, _ := docs[j].GetValue(field.Field)
+			cmp := compareSortValues(a, b, col)
+			if cmp == 0 {
+				continue
+			}
+			if field.Descending {
+				return cmp > 0
+			}
+			return cmp < 0
+		}
+		return false
+	})
+}
+
+// compareSortValues compares two values of possibly different types
+func compareSortValues(a, b any, col *collate.Collator) int {
+	rankA, rankB := sortRank(a), sortRank(b)
+	if rankA != rankB {
+		return rankA - rankB
+	}
+
+	switch rankA {
+	case 0:
+		return 0
+	case 1:
+		x, y := a.(bool), b.(bool)
+		if x == y {
+			return 0
+		}
+		if !x {
+			return -1
+		}
+		return 1
+	case 2:
+		x, _ := toFloat(a)
+		y, _ := toFloat(b)
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	case 3:
+		return compareStrings(a.(string), b.(string), col)
+	}
+	return strings.Compare(fmt.Sprintf("%v", a), fmt.Sprintf("%v", b))
+}
+
+func sortRank(value any) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case string:
+		return 3
+	}
+	if _, ok := toFloat(value); ok {
+		return 2
+	}
+	return 4
+}
+
+// compareStrings compares with col, or byte-wise when col is nil
+func compareStrings(a, b string, col *collate.Collator) int {
+	if col == nil {
+		return strings.Compare(a, b)
+	}
+	return col.CompareString(a, b)
+}
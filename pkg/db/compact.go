@@ -0,0 +1,249 @@
+package db
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Suffix of the files a binary compaction writes before renaming them into place
+const compactSuffix = ".compact"
+
+// CompactionResult reports the size of a collection's data and index files
+// before and after compaction
+type CompactionResult struct {
+	Database    string `json:"database"`
+	Collection  string `json:"collection"`
+	BytesBefore int64  `json:"bytes_before"`
+	BytesAfter  int64  `json:"bytes_after"`
+}
+
+// Reclaimed returns the number of bytes compaction freed
+func (r *CompactionResult) Reclaimed() int64 {
+	return r.BytesBefore - r.BytesAfter
+}
+
+// CompactCollection rewrites a collection's files without deleted documents
+// and superseded versions of updated ones. Changes not saved yet are left
+// for the next save. The collection is locked while its files are
+// rewritten, so it can run on a live server.
+func (sm *StorageManager) CompactCollection(dbName string, coll *Collection) (*CompactionResult, error) {
+	if sm.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	result := &CompactionResult{Database: dbName, Collection: coll.Name}
+	if coll.Ephemeral || coll.unloaded {
+		return result, nil // never stored, or unchanged since loading
+	}
+
+	sm.snapshotMu.RLock()
+	defer sm.snapshotMu.RUnlock()
+
+	// A merge needs the segment state of an earlier save
+	if sm.Format == FormatSegmented && !coll.segments.tracking() {
+		if err := sm.saveCollectionLocked(dbName, coll); err != nil {
+			return nil, fmt.Errorf("failed to save collection before compaction: %w", err)
+		}
+	}
+
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+
+	collDir := filepath.Join(sm.RootDir, dbName, coll.Name)
+	result.BytesBefore = compactableBytes(collDir)
+
+	switch sm.Format {
+	case FormatBinary:
+		if err := compactBinary(sm.RootDir, dbName, coll.Name); err != nil {
+			return nil, err
+		}
+		// Evicted bodies are read back at their new offsets
+		if coll.cache != nil {
+			if err := sm.attachCacheReader(dbName, coll); err != nil {
+				return nil, err
+			}
+		}
+	case FormatSegmented:
+		coll.segments.mu.Lock()
+		err := coll.writeBaseSegmentLocked(collDir, sm.codecFor(coll))
+		coll.segments.mu.Unlock()
+		if err != nil {
+			return nil, fmt.Errorf("failed to merge segments: %w", err)
+		}
+	}
+
+	result.BytesAfter = compactableBytes(collDir)
+	return result, nil
+}
+
+// CompactDatabase compacts every collection of a database, in name order
+func (sm *StorageManager) CompactDatabase(db *Database) ([]*CompactionResult, error) {
+	return sm.CompactDatabaseWithProgress(context.Background(), db, nil)
+}
+
+// CompactDatabaseWithProgress is CompactDatabase reporting the collections
+// compacted to progress. If ctx is canceled it stops with ctx.Err() before
+// the next collection; the collections compacted so far stay compacted.
+func (sm *StorageManager) CompactDatabaseWithProgress(ctx context.Context, db *Database, progress ProgressFunc) ([]*CompactionResult, error) {
+	names := db.ListCollections()
+	sort.Strings(names)
+
+	results := make([]*CompactionResult, 0, len(names))
+	progress.Report(0, int64(len(names)))
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		coll, err := db.GetCollection(name)
+		if err != nil {
+			return nil, err
+		}
+		result, err := sm.CompactCollection(db.Name, coll)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compact collection '%s': %w", name, err)
+		}
+		results = append(results, result)
+		progress.Report(int64(len(results)), int64(len(names)))
+	}
+	return results, nil
+}
+
+// compactableBytes is the size of the data and index files of a collection
+func compactableBytes(collDir string) int64 {
+	size := &StorageSize{}
+	if err := collectionFileSizes(collDir, size); err != nil {
+		return 0
+	}
+	return size.DataBytes + size.IndexBytes
+}
+
+// compactBinary copies the entries the offset index points to into a new
+// data file, dropping the older versions and deleted documents that saves
+// leave behind. The new data and index files are written next to the old
+// ones and renamed over them, data first; finishCompaction completes or
+// discards a compaction cut short between the two.
+func compactBinary(dataDir, dbName, collName string) error {
+	collDir := filepath.Join(dataDir, dbName, collName)
+	dataPath := filepath.Join(collDir, "collection.data")
+	indexPath := filepath.Join(collDir, "collection.idx")
+
+	if err := finishCompaction(collDir); err != nil {
+		return err
+	}
+
+	src, err := os.Open(dataPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // nothing written yet
+		}
+		return fmt.Errorf("failed to open data file: %w", err)
+	}
+	defer src.Close()
+
+	header, err := readHeader(src)
+	if err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	if err := checkFormatVersion(dataPath, header); err != nil {
+		return err
+	}
+
+	index, err := LoadOffsetIndex(dataDir, dbName, collName)
+	if err != nil {
+		return fmt.Errorf("failed to load offset index: %w", err)
+	}
+
+	// Copy entries in file order so the old file is read sequentially
+	ids := make([]string, 0, len(index.Entries))
+	for id := range index.Entries {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return index.Entries[ids[i]].Offset < index.Entries[ids[j]].Offset
+	})
+
+	tmpData := dataPath + compactSuffix
+	dst, err := os.Create(tmpData)
+	if err != nil {
+		return fmt.Errorf("failed to create compacted data file: %w", err)
+	}
+	defer os.Remove(tmpData) // no-op once renamed
+	defer dst.Close()
+
+	headerBuf := make([]byte, HeaderSize)
+	if _, err := src.ReadAt(headerBuf, 0); err != nil {
+		return fmt.Errorf("failed to read header: %w", err)
+	}
+	if _, err := dst.Write(headerBuf); err != nil {
+		return fmt.Errorf("failed to write compacted data file: %w", err)
+	}
+
+	compacted := &OffsetIndex{Entries: make(map[string]*DocumentEntry, len(ids))}
+	offset := int64(HeaderSize)
+	for _, id := range ids {
+		entry := index.Entries[id]
+		buf := make([]byte, DocEntryHeaderSize+int(entry.CompressedSize))
+		if _, err := src.ReadAt(buf, entry.Offset); err != nil {
+			return fmt.Errorf("failed to read document %s: %w", id, err)
+		}
+		if crc32.ChecksumIEEE(buf[DocEntryHeaderSize:]) != entry.Checksum {
+			return fmt.Errorf("checksum mismatch for document %s", id)
+		}
+
+		binary.LittleEndian.PutUint64(buf[0:8], uint64(offset))
+		if _, err := dst.Write(buf); err != nil {
+			return fmt.Errorf("failed to write compacted data file: %w", err)
+		}
+		compacted.Entries[id] = &DocumentEntry{
+			Offset:         offset,
+			Size:           entry.Size,
+			CompressedSize: entry.CompressedSize,
+			Checksum:       entry.Checksum,
+		}
+		offset += int64(len(buf))
+	}
+	if err := dst.Sync(); err != nil {
+		return fmt.Errorf("failed to sync compacted data file: %w", err)
+	}
+
+	tmpIndex := indexPath + compactSuffix
+	if err := writeOffsetIndex(compacted, tmpIndex); err != nil {
+		os.Remove(tmpIndex)
+		return fmt.Errorf("failed to write compacted offset index: %w", err)
+	}
+
+	if err := os.Rename(tmpData, dataPath); err != nil {
+		os.Remove(tmpIndex)
+		return fmt.Errorf("failed to replace data file: %w", err)
+	}
+	if err := os.Rename(tmpIndex, indexPath); err != nil {
+		return fmt.Errorf("failed to replace offset index: %w", err)
+	}
+	return syncDir(filepath.Dir(dataPath))
+}
+
+// finishCompaction cleans up after a binary compaction, or a codec change
+// rewriting the data file (see newBinaryCollectionWriter), that was cut short.
+// If the compacted data file was not renamed yet, the old files are intact
+// and the new ones are dropped; if it was, the compacted index belongs to it
+// and is put in place.
+func finishCompaction(collDir string) error {
+	tmpData := filepath.Join(collDir, "collection.data"+compactSuffix)
+	tmpIndex := filepath.Join(collDir, "collection.idx"+compactSuffix)
+
+	if _, err := os.Stat(tmpData); err == nil {
+		os.Remove(tmpIndex)
+		return os.Remove(tmpData)
+	}
+	if _, err := os.Stat(tmpIndex); err == nil {
+		if err := os.Rename(tmpIndex, filepath.Join(collDir, "collection.idx")); err != nil {
+			return fmt.Errorf("failed to finish interrupted compaction: %w", err)
+		}
+	}
+	return nil
+}
@@ -3,9 +3,129 @@ package db
 import (
 	"bytes"
 	"compress/gzip"
+	"fmt"
 	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
 )
 
+// Codec names how documents are compressed in binary data files and segments
+type Codec string
+
+const (
+	CodecNone Codec = "none"
+	CodecGzip Codec = "gzip"
+	CodecZstd Codec = "zstd"
+	CodecLZ4  Codec = "lz4"
+)
+
+// Codecs lists the supported codecs
+var Codecs = []Codec{CodecNone, CodecGzip, CodecZstd, CodecLZ4}
+
+// ParseCodec validates a codec name; "" is returned as is and means the
+// storage default
+func ParseCodec(name string) (Codec, error) {
+	if name == "" {
+		return "", nil
+	}
+	for _, codec := range Codecs {
+		if Codec(name) == codec {
+			return codec, nil
+		}
+	}
+	return "", fmt.Errorf("unknown codec '%s' (available: none, gzip, zstd, lz4)", name)
+}
+
+// Header flags recording the codec of a data file or segment. Gzip files
+// only set FlagCompressed, as before codecs were configurable; the other
+// codecs set it together with their own bit.
+const (
+	FlagZstd = 4
+	FlagLZ4  = 8
+)
+
+// codecFlags returns the header flags for a codec
+func codecFlags(codec Codec) uint16 {
+	switch codec {
+	case CodecGzip:
+		return FlagCompressed
+	case CodecZstd:
+		return FlagCompressed | FlagZstd
+	case CodecLZ4:
+		return FlagCompressed | FlagLZ4
+	}
+	return 0
+}
+
+// codecOf returns the codec recorded in header flags
+func codecOf(flags uint16) Codec {
+	switch {
+	case flags&FlagCompressed == 0:
+		return CodecNone
+	case flags&FlagZstd != 0:
+		return CodecZstd
+	case flags&FlagLZ4 != 0:
+		return CodecLZ4
+	}
+	return CodecGzip
+}
+
+// zstd encoders and decoders are expensive to create and safe for
+// concurrent EncodeAll/DecodeAll calls, so one of each is shared
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) { return zstd.NewWriter(nil) })
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) { return zstd.NewReader(nil) })
+)
+
+// compress compresses data with the codec
+func (codec Codec) compress(data []byte) ([]byte, error) {
+	switch codec {
+	case CodecGzip:
+		return Compress(data)
+	case CodecZstd:
+		encoder, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		return encoder.EncodeAll(data, nil), nil
+	case CodecLZ4:
+		var buf bytes.Buffer
+		writer := lz4.NewWriter(&buf)
+		if _, err := writer.Write(data); err != nil {
+			writer.Close()
+			return nil, err
+		}
+		if err := writer.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return data, nil
+}
+
+// decompress decompresses data compressed with the codec
+func (codec Codec) decompress(data []byte) ([]byte, error) {
+	switch codec {
+	case CodecGzip:
+		return Decompress(data)
+	case CodecZstd:
+		decoder, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		return decoder.DecodeAll(data, nil)
+	case CodecLZ4:
+		var buf bytes.Buffer
+		if _, err := io.Copy(&buf, lz4.NewReader(bytes.NewReader(data))); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return data, nil
+}
+
 // Compress compresses data using gzip
 func Compress(data []byte) ([]byte, error) {
 	var buf bytes.Buffer
@@ -38,3 +158,28 @@ func Decompress(data []byte) ([]byte, error) {
 
 	return buf.Bytes(), nil
 }
+
+// SetCodec sets the codec documents of the collection are stored with; ""
+// uses the storage manager's default. Data already on disk is read with the
+// codec it was written with, and rewritten with the new one as it is saved.
+func (c *Collection) SetCodec(codec Codec) error {
+	if _, err := ParseCodec(string(codec)); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Codec = codec
+	return nil
+}
+
+// codecFor returns the codec a collection is saved with
+func (sm *StorageManager) codecFor(coll *Collection) Codec {
+	if coll.Codec != "" {
+		return coll.Codec
+	}
+	if sm.Compression {
+		return CodecGzip
+	}
+	return CodecNone
+}
@@ -0,0 +1,333 @@
+package db
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ConflictStrategy decides what CopyDatabase does with a document whose ID
+// already exists in the target collection
+type ConflictStrategy string
+
+// Conflict strategies
+const (
+	// ConflictFail fails the copy before anything is written
+	ConflictFail ConflictStrategy = "error"
+	// ConflictSkip keeps the target's document
+	ConflictSkip ConflictStrategy = "skip"
+	// ConflictOverwrite replaces the target's document with the source's
+	ConflictOverwrite ConflictStrategy = "overwrite"
+)
+
+// CopyOptions configure CopyDatabase
+type CopyOptions struct {
+	// Collections limits the copy to these collections (nil copies all of them)
+	Collections []string
+	// OnConflict handles IDs present in both databases (default ConflictFail)
+	OnConflict ConflictStrategy
+}
+
+// CopyCounts reports what CopyDatabase did with one collection
+type CopyCounts struct {
+	// Created is set when the collection did not exist in the target
+	Created     bool `json:"created"`
+	Copied      int  `json:"copied"`
+	Skipped     int  `json:"skipped"`
+	Overwritten int  `json:"overwritten"`
+}
+
+// CopyDatabase copies the collections of src, a database loaded from any
+// root directory, into dst, a database of this storage manager, merging
+// them with collections that already exist there. Missing collections are
+// created with the source's schema, options and indexes; existing ones keep
+// theirs and gain the source's indexes they lack. Documents keep their IDs
+// and revisions, and blob values and attachments are copied into the
+// target's blob stores. Nothing is saved: the caller saves dst.
+//
+// With ConflictFail, colliding IDs are looked for before anything is
+// changed. A document that the target rejects, e.g. for its schema or a
+// unique index, fails the copy midway; dst should then not be saved.
+func (sm *StorageManager) CopyDatabase(src, dst *Database, opts CopyOptions) (map[string]*CopyCounts, error) {
+	switch opts.OnConflict {
+	case "":
+		opts.OnConflict = ConflictFail
+	case ConflictFail, ConflictSkip, ConflictOverwrite:
+	default:
+		return nil, fmt.Errorf("unknown conflict strategy '%s' (use error, skip or overwrite)", opts.OnConflict)
+	}
+
+	names := opts.Collections
+	if len(names) == 0 {
+		names = src.ListCollections()
+		sort.Strings(names)
+	}
+	sources := make([]*Collection, 0, len(names))
+	for _, name := range names {
+		coll, err := src.GetCollection(name)
+		if err != nil {
+			return nil, err
+		}
+		if !coll.Ephemeral {
+			sources = append(sources, coll)
+		}
+	}
+
+	if opts.OnConflict == ConflictFail {
+		for _, from := range sources {
+			if err := checkCopyConflicts(from, dst); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	results := make(map[string]*CopyCounts, len(sources))
+	for _, from := range sources {
+		counts, err := sm.copyCollection(from, dst, opts.OnConflict)
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy collection '%s': %w", from.Name, err)
+		}
+		results[from.Name] = counts
+	}
+	return results, nil
+}
+
+// checkCopyConflicts fails if a document of from has an ID already used in
+// the collection of the same name in dst
+func checkCopyConflicts(from *Collection, dst *Database) error {
+	to, err := dst.GetCollection(from.Name)
+	if err != nil {
+		return nil // created by the copy
+	}
+
+	from.mu.RLock()
+	defer from.mu.RUnlock()
+	to.mu.RLock()
+	defer to.mu.RUnlock()
+
+	var conflicts []string
+	for id := range from.Documents {
+		if _, exists := to.Documents[id]; exists {
+			conflicts = append(conflicts, id)
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("%d document(s) of collection '%s' already exist in the target, e.g. '%s'; choose to skip or overwrite them",
+			len(conflicts), from.Name, conflicts[0])
+	}
+	return nil
+}
+
+// copyCollection copies the documents and indexes of from into the
+// collection of the same name in dst, creating it if needed
+func (sm *StorageManager) copyCollection(from *Collection, dst *Database, onConflict ConflictStrategy) (*CopyCounts, error) {
+	counts := &CopyCounts{}
+	to, err := dst.GetCollection(from.Name)
+	if err != nil {
+		if err := dst.CreateCollection(from.Name, from.Schema); err != nil {
+			return nil, err
+		}
+		if to, err = dst.GetCollection(from.Name); err != nil {
+			return nil, err
+		}
+		to.IDStrategy = from.IDStrategy
+		to.BlobPolicy = from.BlobPolicy
+		to.Collation = from.Collation
+		to.MemoryBudget = from.MemoryBudget
+		to.Codec = from.Codec
+		counts.Created = true
+	}
+	sm.AttachBlobStore(dst.Name, to)
+
+	// Indexes first, so unique indexes check the copied documents
+	if err := copyIndexes(from, to); err != nil {
+		return nil, err
+	}
+
+	docs, err := from.FindIter(&Query{Sort: []SortField{{Field: "_id"}}})
+	if err != nil {
+		return nil, err
+	}
+	for doc := range docs {
+		to.mu.RLock()
+		_, exists := to.Documents[doc.ID]
+		to.mu.RUnlock()
+		if exists && onConflict == ConflictSkip {
+			counts.Skipped++
+			continue
+		}
+		if exists && onConflict == ConflictFail {
+			return nil, fmt.Errorf("document '%s' already exists", doc.ID)
+		}
+
+		// Spilled values are stored again under the target's blob policy
+		resolved, err := from.ResolveBlobs(doc)
+		if err != nil {
+			return nil, err
+		}
+		if err := copyAttachments(from, to, resolved); err != nil {
+			return nil, fmt.Errorf("document '%s': %w", doc.ID, err)
+		}
+
+		if exists {
+			err = to.put(resolved)
+			counts.Overwritten++
+		} else {
+			err = to.insert(resolved)
+			counts.Copied++
+		}
+		if err != nil {
+			return nil, fmt.Errorf("document '%s': %w", doc.ID, err)
+		}
+	}
+	return counts, nil
+}
+
+// CloneCollection copies the documents of the collection src that match
+// filter (nil copies all of them) into a new collection dst of the same
+// database, created with the schema, options and indexes of src, and
+// returns how many were copied. Documents keep their IDs and revisions,
+// and blob values and attachments are stored again in the clone's blob
+// store. If a document cannot be copied, dst is removed again. Nothing is
+// logged or saved: the caller logs the clone's creation, indexes and
+// documents.
+func (db *Database) CloneCollection(src, dst string, filter *Filter) (int, error) {
+	if src == dst {
+		return 0, fmt.Errorf("cannot clone collection '%s' onto itself", src)
+	}
+	if filter != nil {
+		if err := filter.Validate(); err != nil {
+			return 0, fmt.Errorf("invalid filter: %w", err)
+		}
+	}
+
+	from, err := db.GetCollection(src)
+	if err != nil {
+		return 0, err
+	}
+	if err := db.CreateCollection(dst, from.Schema); err != nil {
+		return 0, err
+	}
+	to, err := db.GetCollection(dst)
+	if err != nil {
+		return 0, err
+	}
+
+	copied, err := cloneInto(from, to, filter)
+	if err != nil {
+		db.DeleteCollection(dst)
+		// The clone was never saved, so its directory only has the blobs
+		// stored so far
+		if to.blobs != nil {
+			os.RemoveAll(filepath.Dir(to.blobs.dir))
+		}
+		return 0, err
+	}
+	return copied, nil
+}
+
+// cloneInto copies the options, indexes and documents matching filter of
+// from into to, a new collection
+func cloneInto(from, to *Collection, filter *Filter) (int, error) {
+	from.mu.RLock()
+	to.IDStrategy = from.IDStrategy
+	to.BlobPolicy = from.BlobPolicy
+	to.Collation = from.Collation
+	to.Codec = from.Codec
+	budget := from.MemoryBudget
+	if from.blobs != nil {
+		to.blobs = from.blobs.sibling(to.Name)
+	}
+	from.mu.RUnlock()
+	if err := to.SetMemoryBudget(budget); err != nil {
+		return 0, err
+	}
+
+	// Indexes first, so unique indexes check the copied documents
+	if err := copyIndexes(from, to); err != nil {
+		return 0, err
+	}
+
+	docs, err := from.FindIter(&Query{Filter: filter, Sort: []SortField{{Field: "_id"}}})
+	if err != nil {
+		return 0, err
+	}
+	copied := 0
+	for doc := range docs {
+		// Spilled values are stored again in the clone's blob store
+		resolved, err := from.ResolveBlobs(doc)
+		if err != nil {
+			return 0, err
+		}
+		if err := copyAttachments(from, to, resolved); err != nil {
+			return 0, fmt.Errorf("document '%s': %w", doc.ID, err)
+		}
+		if err := to.insert(resolved); err != nil {
+			return 0, fmt.Errorf("document '%s': %w", doc.ID, err)
+		}
+		copied++
+	}
+	return copied, nil
+}
+
+// copyIndexes creates the indexes of from that to lacks, in name order
+func copyIndexes(from, to *Collection) error {
+	from.mu.RLock()
+	indexes := make([]*Index, 0, len(from.Indexes))
+	for _, idx := range from.Indexes {
+		indexes = append(indexes, idx)
+	}
+	from.mu.RUnlock()
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i].Name < indexes[j].Name })
+	for _, idx := range indexes {
+		to.mu.RLock()
+		_, exists := to.Indexes[idx.Name]
+		to.mu.RUnlock()
+		if exists {
+			continue
+		}
+		opts := IndexOptions{Type: idx.Type, Unique: idx.Unique, TTL: idx.TTL}
+		if err := to.CreateIndexWithOptions(idx.Name, idx.FieldName, opts); err != nil {
+			return fmt.Errorf("failed to create index '%s': %w", idx.Name, err)
+		}
+	}
+	return nil
+}
+
+// copyAttachments stores the attachments of doc, a document of from, in the
+// blob store of to
+func copyAttachments(from, to *Collection, doc *Document) error {
+	attachments, _ := doc.Data[AttachmentsField].(map[string]any)
+	if len(attachments) == 0 {
+		return nil
+	}
+
+	from.mu.RLock()
+	source := from.blobs
+	from.mu.RUnlock()
+	to.mu.RLock()
+	target := to.blobs
+	to.mu.RUnlock()
+	if source == nil {
+		return fmt.Errorf("collection '%s' has no blob store", from.Name)
+	}
+
+	for name, ref := range attachments {
+		hash, ok := BlobRef(ref)
+		if !ok {
+			continue
+		}
+		r, err := source.Open(hash)
+		if err != nil {
+			return fmt.Errorf("attachment '%s': %w", name, err)
+		}
+		_, _, err = target.Put(r)
+		r.Close()
+		if err != nil {
+			return fmt.Errorf("attachment '%s': %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package db
+
+import "fmt"
+
+// SequencesCollection is the system collection holding the sequences of a
+// database, one document per sequence with its last value in SequenceField
+const SequencesCollection = "_sequences"
+
+// SequenceField holds the last value handed out by a sequence
+const SequenceField = "value"
+
+// Increment atomically adds delta to a numeric field of a document and
+// returns the new value. A missing document is created with the field set
+// to delta, and a missing field starts at 0. The field is named by a path
+// as in Update. The change is made in memory; log the document with
+// StorageManager.LogUpsert to make it durable, as DB.Increment does.
+func (c *Collection) Increment(id, field string, delta float64) (float64, error) {
+	if id == "" {
+		return 0, fmt.Errorf("document ID cannot be empty")
+	}
+	inc, err := userUpdate(map[string]any{UpdateOpInc: map[string]any{field: delta}})
+	if err != nil {
+		return 0, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.Documents[id]; exists {
+		err = c.modifyLocked(id, inc)
+	} else {
+		doc := &Document{ID: id, Data: make(map[string]any)}
+		if err := inc(doc); err != nil {
+			return 0, err
+		}
+		err = c.insertLocked(doc, true)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// Read back, as a hook may have changed the value
+	stored, err := c.loadLocked(c.Documents[id])
+	if err != nil {
+		return 0, err
+	}
+	value, _ := getPath(stored.Data, field)
+	result, _ := toFloat(value)
+	return result, nil
+}
+
+// NextSequence atomically advances the sequence name of the database and
+// returns its new value: 1 on first use, then 2, 3, and so on. Sequences are
+// documents of SequencesCollection, which is created on first use. As with
+// Increment, the change is made in memory; DB.NextSequence also logs it.
+func (db *Database) NextSequence(name string) (int64, error) {
+	if name == "" {
+		return 0, fmt.Errorf("sequence name cannot be empty")
+	}
+	coll, _, err := db.sequences()
+	if err != nil {
+		return 0, err
+	}
+	value, err := coll.Increment(name, SequenceField, 1)
+	if err != nil {
+		return 0, err
+	}
+	return int64(value), nil
+}
+
+// sequences returns the collection of the sequences, creating it if needed,
+// and whether it was created
+func (db *Database) sequences() (*Collection, bool, error) {
+	return db.systemCollection(SequencesCollection)
+}
+
+// systemCollection returns a collection CachyDB keeps for a feature,
+// creating it if needed, and whether it was created
+func (db *Database) systemCollection(name string) (*Collection, bool, error) {
+	if coll, err := db.GetCollection(name); err == nil {
+		return coll, false, nil
+	}
+	if err := db.CreateCollection(name, nil); err != nil {
+		// Created concurrently
+		if coll, getErr := db.GetCollection(name); getErr == nil {
+			return coll, false, nil
+		}
+		return nil, false, err
+	}
+	coll, err := db.GetCollection(name)
+	return coll, true, err
+}
+
+// Increment is Collection.Increment on a collection of a database, logging
+// the resulting document to the WAL. It is logged whole rather than as a
+// delta, so a replayed increment is never applied twice.
+func (d *DB) Increment(database, collection, id, field string, delta float64) (float64, error) {
+	db := d.GetDatabase(database)
+	if db == nil {
+		return 0, fmt.Errorf("database '%s' not found", database)
+	}
+	coll, err := db.GetCollection(collection)
+	if err != nil {
+		return 0, err
+	}
+	value, err := coll.Increment(id, field, delta)
+	if err != nil {
+		return 0, err
+	}
+	return value, logStoredDocument(d.Storage, db.Name, coll, id)
+}
+
+// NextSequence is Database.NextSequence on a database, logging the
+// sequence to the WAL. A value is returned only once logged, so with sync
+// durability it is never handed out again after a crash.
+func (d *DB) NextSequence(database, name string) (int64, error) {
+	db := d.GetDatabase(database)
+	if db == nil {
+		return 0, fmt.Errorf("database '%s' not found", database)
+	}
+	coll, created, err := db.sequences()
+	if err != nil {
+		return 0, err
+	}
+	if created {
+		if err := d.Storage.LogCreateCollection(db.Name, SequencesCollection, nil); err != nil {
+			return 0, fmt.Errorf("failed to log create collection: %w", err)
+		}
+	}
+	value, err := db.NextSequence(name)
+	if err != nil {
+		return 0, err
+	}
+	return value, logStoredDocument(d.Storage, db.Name, coll, name)
+}
+
+// logStoredDocument logs a document as it is stored now
+func logStoredDocument(storage *StorageManager, dbName string, coll *Collection, id string) error {
+	doc, err := coll.FindByID(id)
+	if err != nil {
+		return err
+	}
+	if err := storage.LogUpsert(dbName, coll.Name, doc); err != nil {
+		return fmt.Errorf("failed to log %s: %w", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Survivor policies choose which document of a duplicate cluster is kept
+const (
+	SurvivorFirst        = "first"         // lowest document ID
+	SurvivorLast         = "last"          // highest document ID
+	SurvivorMostComplete = "most-complete" // most fields; ties go to the lowest ID
+)
+
+// DuplicateCluster is a group of documents sharing the same values for a field tuple
+type DuplicateCluster struct {
+	Key         []any    `json:"key"`                // values of the fields, in order
+	DocumentIDs []string `json:"document_ids"`       // sorted ascending
+	Survivor    string   `json:"survivor,omitempty"` // set by ResolveDuplicates
+}
+
+// DedupeOptions configures ResolveDuplicates
+type DedupeOptions struct {
+	// Survivor is the survivor policy (default SurvivorFirst)
+	Survivor string `json:"survivor,omitempty"`
+	// Merge copies fields the survivor lacks from the other documents, in ID
+	// order, before they are deleted. Reserved fields are never copied.
+	Merge bool `json:"merge,omitempty"`
+}
+
+// FindDuplicates groups the documents of coll by the values of fields and
+// returns every group with more than one document, ordered by key. Documents
+// missing any of the fields are ignored. Numbers compare by value, so 1 and
+// 1.0 are duplicates.
+func FindDuplicates(coll *Collection, fields []string) ([]DuplicateCluster, error) {
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("at least one field is required")
+	}
+
+	coll.mu.RLock()
+	groups := make(map[string]*DuplicateCluster)
+	for _, doc := range coll.Documents {
+		doc, err := coll.loadLocked(doc)
+		if err != nil {
+			coll.mu.RUnlock()
+			return nil, err
+		}
+		key := make([]any, len(fields))
+		complete := true
+		for i, field := range fields {
+			value, exists := doc.GetValue(field)
+			if !exists {
+				complete = false
+				break
+			}
+			key[i] = normalizeJSONValue(value)
+		}
+		if !complete {
+			continue
+		}
+
+		encoded, err := json.Marshal(key)
+		if err != nil {
+			coll.mu.RUnlock()
+			return nil, fmt.Errorf("document '%s': %w", doc.ID, err)
+		}
+		cluster, exists := groups[string(encoded)]
+		if !exists {
+			cluster = &DuplicateCluster{Key: key}
+			groups[string(encoded)] = cluster
+		}
+		cluster.DocumentIDs = append(cluster.DocumentIDs, doc.ID)
+	}
+	coll.mu.RUnlock()
+
+	clusters := make([]DuplicateCluster, 0)
+	for _, cluster := range groups {
+		if len(cluster.DocumentIDs) > 1 {
+			sort.Strings(cluster.DocumentIDs)
+			clusters = append(clusters, *cluster)
+		}
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		for k := range fields {
+			if cmp := compareSortValues(clusters[i].Key[k], clusters[j].Key[k], nil); cmp != 0 {
+				return cmp < 0
+			}
+		}
+		return false
+	})
+
+	return clusters, nil
+}
+
+// ResolveDuplicates keeps one document per cluster, chosen by opts.Survivor,
+// and deletes the rest. Each cluster's Survivor is filled in. It returns the
+// IDs of the deleted documents.
+func ResolveDuplicates(coll *Collection, clusters []DuplicateCluster, opts DedupeOptions) ([]string, error) {
+	policy := opts.Survivor
+	if policy == "" {
+		policy = SurvivorFirst
+	}
+	switch policy {
+	case SurvivorFirst, SurvivorLast, SurvivorMostComplete:
+	default:
+		return nil, fmt.Errorf("unknown survivor policy '%s' (available: %s, %s, %s)", policy, SurvivorFirst, SurvivorLast, SurvivorMostComplete)
+	}
+
+	var removed []string
+	for i := range clusters {
+		cluster := &clusters[i]
+		docs := make([]*Document, 0, len(cluster.DocumentIDs))
+		for _, id := range cluster.DocumentIDs {
+			doc, err := coll.FindByID(id)
+			if err != nil {
+				return removed, err
+			}
+			docs = append(docs, doc)
+		}
+		sort.Slice(docs, func(a, b int) bool { return docs[a].ID < docs[b].ID })
+
+		survivor := chooseSurvivor(docs, policy)
+		cluster.Survivor = survivor.ID
+
+		if opts.Merge {
+			updates := make(map[string]any)
+			for _, doc := range docs {
+				for field, value := range doc.Data {
+					if IsReservedField(field) {
+						continue
+					}
+					if _, exists := survivor.Data[field]; exists {
+						continue
+					}
+					if _, exists := updates[field]; !exists {
+						updates[field] = value
+					}
+				}
+			}
+			if len(updates) > 0 {
+				if err := coll.Update(survivor.ID, updates); err != nil {
+					return removed, fmt.Errorf("failed to merge into '%s': %w", survivor.ID, err)
+				}
+			}
+		}
+
+		for _, doc := range docs {
+			if doc.ID == survivor.ID {
+				continue
+			}
+			if err := coll.Delete(doc.ID); err != nil {
+				return removed, err
+			}
+			removed = append(removed, doc.ID)
+		}
+	}
+
+	return removed, nil
+}
+
+// chooseSurvivor picks the document to keep from docs sorted by ID
+func chooseSurvivor(docs []*Document, policy string) *Document {
+	switch policy {
+	case SurvivorFirst:
+		return docs[0]
+	case SurvivorLast:
+		return docs[len(docs)-1]
+	case SurvivorMostComplete:
+		best := docs[0]
+		for _, doc := range docs[1:] {
+			if len(doc.Data) > len(best.Data) {
+				best = doc
+			}
+		}
+		return best
+	}
+	return docs[0]
+}
@@ -0,0 +1,219 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// DefaultEmbeddingField is the document field holding a vector when none is specified
+const DefaultEmbeddingField = "embedding"
+
+// Embedder converts text into a vector embedding
+type Embedder interface {
+	Embed(ctx context.Context, text string) ([]float64, error)
+}
+
+// EmbedderFunc adapts an ordinary function (e.g. a local model hook) to the Embedder interface
+type EmbedderFunc func(ctx context.Context, text string) ([]float64, error)
+
+// Embed calls f(ctx, text)
+func (f EmbedderFunc) Embed(ctx context.Context, text string) ([]float64, error) {
+	return f(ctx, text)
+}
+
+// HTTPEmbedder calls a remote embedding endpoint.
+// The request body is {"model": ..., "input": ...}; both the plain
+// {"embedding": [...]} and the OpenAI-style {"data": [{"embedding": [...]}]}
+// response shapes are accepted.
+type HTTPEmbedder struct {
+	URL    string
+	Model  string
+	APIKey string
+	Client *http.Client
+}
+
+// NewHTTPEmbedder creates a new HTTP embedder
+func NewHTTPEmbedder(url, model, apiKey string) *HTTPEmbedder {
+	return &HTTPEmbedder{
+		URL:    url,
+		Model:  model,
+		APIKey: apiKey,
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Embed requests an embedding for text from the remote endpoint
+func (e *HTTPEmbedder) Embed(ctx context.Context, text string) ([]float64, error) {
+	body, err := json.Marshal(map[string]any{
+		"model": e.Model,
+		"input": text,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal embedding request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+
+	client := e.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embedding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("embedding endpoint returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Embedding []float64 `json:"embedding"`
+		Data      []struct {
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode embedding response: %w", err)
+	}
+
+	if len(result.Embedding) > 0 {
+		return result.Embedding, nil
+	}
+	if len(result.Data) > 0 && len(result.Data[0].Embedding) > 0 {
+		return result.Data[0].Embedding, nil
+	}
+
+	return nil, fmt.Errorf("embedding response contained no vector")
+}
+
+// SearchResult is a document paired with its relevance score
+type SearchResult struct {
+	Document *Document `json:"document"`
+	Score    float64   `json:"score"`
+}
+
+// VectorSearch ranks documents by cosine similarity between vector and the
+// embedding stored in field, returning at most limit results (0 = no limit)
+func (c *Collection) VectorSearch(field string, vector []float64, limit int) ([]SearchResult, error) {
+	if len(vector) == 0 {
+		return nil, fmt.Errorf("query vector is empty")
+	}
+	if field == "" {
+		field = DefaultEmbeddingField
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	results := make([]SearchResult, 0)
+	for _, doc := range c.Documents {
+		doc, err := c.loadLocked(doc)
+		if err != nil {
+			return nil, err
+		}
+		value, exists := doc.GetValue(field)
+		if !exists {
+			continue
+		}
+
+		docVector, ok := toFloatSlice(value)
+		if !ok || len(docVector) != len(vector) {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Document: doc.Clone(),
+			Score:    cosineSimilarity(vector, docVector),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if limit > 0 && limit < len(results) {
+		results = results[:limit]
+	}
+
+	return results, nil
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b
+func cosineSimilarity(a, b []float64) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// toFloatSlice converts a stored array value into a float vector
+func toFloatSlice(value any) ([]float64, bool) {
+	switch v := value.(type) {
+	case []float64:
+		return v, true
+	case []any:
+		out := make([]float64, len(v))
+		for i, item := range v {
+			f, ok := toFloat(item)
+			if !ok {
+				return nil, false
+			}
+			out[i] = f
+		}
+		return out, true
+	}
+	return nil, false
+}
+
+// toFloat converts a numeric value to float64
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,438 @@
+package exporter
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// ArrowStreamType is the content type of an Arrow IPC stream
+const ArrowStreamType = "application/vnd.apache.arrow.stream"
+
+// DefaultArrowBatchSize is the number of rows in each record batch of an
+// Arrow export when none is given
+const DefaultArrowBatchSize = 10000
+
+// ArrowOptions configures an Arrow export
+type ArrowOptions struct {
+	// Query selects the documents to export (default: all). They are
+	// written in ID order unless it sorts them.
+	Query *db.Query
+	// BatchSize is the number of rows in each record batch
+	// (default DefaultArrowBatchSize)
+	BatchSize int
+}
+
+// WriteArrow writes the documents matching a query as an Arrow IPC stream,
+// with the columns of a CSV export. Schema strings, numbers and booleans
+// become utf8, float64 and bool columns, and dates millisecond UTC
+// timestamps when every value parses as RFC 3339 (or YYYY-MM-DD), utf8
+// otherwise. Fields without a schema are typed the same way from the values
+// found. Objects, arrays and columns of mixed types are written as JSON
+// text. Like WriteCSV, the documents are read
+// twice, one at a time, and blob values are written inline. It returns the
+// number of documents written.
+func WriteArrow(w io.Writer, coll *db.Collection, opts *ArrowOptions) (int, error) {
+	if opts == nil {
+		opts = &ArrowOptions{}
+	}
+	query := &db.Query{}
+	if opts.Query != nil {
+		*query = *opts.Query
+	}
+	// Both passes must see the same documents, even under a limit
+	if len(query.Sort) == 0 {
+		query.Sort = []db.SortField{{Field: "_id"}}
+	}
+	batchSize := opts.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultArrowBatchSize
+	}
+
+	columns, err := arrowDocumentColumns(coll, query)
+	if err != nil {
+		return 0, err
+	}
+
+	aw := newArrowWriter(w, columns)
+	docs, err := coll.FindIter(query)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for doc := range docs {
+		resolved, err := coll.ResolveBlobs(doc)
+		if err != nil {
+			return count, err
+		}
+
+		row := make([]any, len(columns))
+		row[0] = resolved.ID
+		for i, column := range columns[1:] {
+			row[i+1] = resolved.Data[column.name]
+		}
+		if err := aw.append(row); err != nil {
+			return count, fmt.Errorf("document '%s': %w", doc.ID, err)
+		}
+		count++
+
+		if aw.rows >= batchSize {
+			if err := aw.flush(); err != nil {
+				return count, err
+			}
+		}
+	}
+
+	return count, aw.close()
+}
+
+// WriteArrowAggregate writes the result of an aggregation as an Arrow IPC
+// stream of one record batch, one row per group: the group key (named after
+// the GroupBy field, left out when not grouping), "count", then the
+// accumulators sorted by name. Counts are int64 and the other accumulators
+// float64; the key is typed from its values like a document field.
+func WriteArrowAggregate(w io.Writer, agg *db.Aggregation, groups []db.AggregateGroup) error {
+	var columns []*arrowColumn
+	if agg.GroupBy != "" {
+		key := &arrowColumn{name: agg.GroupBy}
+		for _, group := range groups {
+			key.observe(group.Key)
+		}
+		key.settle("")
+		columns = append(columns, key)
+	}
+	columns = append(columns, &arrowColumn{name: "count", kind: arrowInteger})
+
+	names := make([]string, 0, len(agg.Accumulators))
+	for name := range agg.Accumulators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		kind := arrowNumber
+		if agg.Accumulators[name].Op == db.AccCount {
+			kind = arrowInteger
+		}
+		columns = append(columns, &arrowColumn{name: name, kind: kind})
+	}
+
+	aw := newArrowWriter(w, columns)
+	for _, group := range groups {
+		var row []any
+		if agg.GroupBy != "" {
+			row = append(row, group.Key)
+		}
+		row = append(row, group.Count)
+		for _, name := range names {
+			row = append(row, group.Values[name])
+		}
+		if err := aw.append(row); err != nil {
+			return err
+		}
+	}
+	return aw.close()
+}
+
+// arrowKind is the Arrow type of a column
+type arrowKind int
+
+const (
+	arrowString arrowKind = iota
+	arrowNumber
+	arrowInteger
+	arrowBoolean
+	arrowTimestamp
+	arrowJSON // any value, as JSON text
+)
+
+// arrowColumn is a column of an Arrow export. While its kind is inferred,
+// it records the types of the values seen.
+type arrowColumn struct {
+	name string
+	kind arrowKind
+
+	numbers, booleans, strings, others bool
+	// undated is set when a string does not parse as a date
+	undated bool
+}
+
+// observe records the type of a value of the column
+func (c *arrowColumn) observe(value any) {
+	switch v := value.(type) {
+	case nil:
+	case bool:
+		c.booleans = true
+	case string:
+		c.strings = true
+		if _, ok := parseArrowDate(v); !ok {
+			c.undated = true
+		}
+	case time.Time:
+		c.strings = true
+	default:
+		if _, ok := arrowFloat(value); ok {
+			c.numbers = true
+		} else {
+			c.others = true
+		}
+	}
+}
+
+// settle picks the kind of the column from its schema type, if any, and
+// the values observed
+func (c *arrowColumn) settle(declared db.FieldType) {
+	mixed := 0
+	for _, seen := range []bool{c.numbers, c.booleans, c.strings, c.others} {
+		if seen {
+			mixed++
+		}
+	}
+
+	switch {
+	case declared == db.TypeObject || declared == db.TypeArray:
+		c.kind = arrowJSON
+	case declared == db.TypeString:
+		c.kind = arrowString
+	case declared == db.TypeNumber:
+		c.kind = arrowNumber
+	case declared == db.TypeBoolean:
+		c.kind = arrowBoolean
+	case declared == db.TypeDate && !c.numbers && !c.booleans && !c.others && !c.undated:
+		c.kind = arrowTimestamp
+	case mixed > 1 || c.others:
+		c.kind = arrowJSON
+	case c.numbers:
+		c.kind = arrowNumber
+	case c.booleans:
+		c.kind = arrowBoolean
+	default:
+		c.kind = arrowString
+	}
+}
+
+func (c *arrowColumn) dataType() arrow.DataType {
+	switch c.kind {
+	case arrowNumber:
+		return arrow.PrimitiveTypes.Float64
+	case arrowInteger:
+		return arrow.PrimitiveTypes.Int64
+	case arrowBoolean:
+		return arrow.FixedWidthTypes.Boolean
+	case arrowTimestamp:
+		return arrow.FixedWidthTypes.Timestamp_ms
+	}
+	return arrow.BinaryTypes.String
+}
+
+// arrowDocumentColumns returns the columns of an Arrow export of the
+// documents matching query, in the order of csvFields, with their kinds
+func arrowDocumentColumns(coll *db.Collection, query *db.Query) ([]*arrowColumn, error) {
+	var schemaFields []string
+	byName := make(map[string]*arrowColumn)
+	if coll.Schema != nil {
+		for name := range coll.Schema.Fields {
+			schemaFields = append(schemaFields, name)
+			byName[name] = &arrowColumn{name: name}
+		}
+	}
+	sort.Strings(schemaFields)
+
+	docs, err := coll.FindIter(query)
+	if err != nil {
+		return nil, err
+	}
+	var others []string
+	for doc := range docs {
+		for name, value := range doc.Data {
+			column, ok := byName[name]
+			if !ok {
+				if db.IsReservedField(name) {
+					continue
+				}
+				column = &arrowColumn{name: name}
+				byName[name] = column
+				others = append(others, name)
+			}
+			// Blob values are only read when written
+			if _, isBlob := db.BlobRef(value); !isBlob {
+				column.observe(value)
+			}
+		}
+	}
+	sort.Strings(others)
+
+	columns := []*arrowColumn{{name: "_id", kind: arrowString}}
+	for _, name := range append(schemaFields, others...) {
+		var declared db.FieldType
+		if coll.Schema != nil {
+			declared = coll.Schema.Fields[name].Type
+		}
+		byName[name].settle(declared)
+		columns = append(columns, byName[name])
+	}
+	return columns, nil
+}
+
+// arrowWriter builds record batches of rows and writes them to an IPC stream
+type arrowWriter struct {
+	columns []*arrowColumn
+	schema  *arrow.Schema
+	builder *array.RecordBuilder
+	writer  *ipc.Writer
+	rows    int
+}
+
+func newArrowWriter(w io.Writer, columns []*arrowColumn) *arrowWriter {
+	fields := make([]arrow.Field, len(columns))
+	for i, column := range columns {
+		fields[i] = arrow.Field{Name: column.name, Type: column.dataType(), Nullable: true}
+	}
+	schema := arrow.NewSchema(fields, nil)
+	mem := memory.NewGoAllocator()
+	return &arrowWriter{
+		columns: columns,
+		schema:  schema,
+		builder: array.NewRecordBuilder(mem, schema),
+		writer:  ipc.NewWriter(w, ipc.WithSchema(schema), ipc.WithAllocator(mem)),
+	}
+}
+
+// append adds a row, one value per column, to the current batch
+func (aw *arrowWriter) append(row []any) error {
+	for i, column := range aw.columns {
+		if err := appendArrowValue(aw.builder.Field(i), column.kind, row[i]); err != nil {
+			return fmt.Errorf("field '%s': %w", column.name, err)
+		}
+	}
+	aw.rows++
+	return nil
+}
+
+// flush writes the current batch, if it has rows
+func (aw *arrowWriter) flush() error {
+	if aw.rows == 0 {
+		return nil
+	}
+	record := aw.builder.NewRecordBatch()
+	defer record.Release()
+	aw.rows = 0
+	return aw.writer.Write(record)
+}
+
+// close writes the last batch and ends the stream
+func (aw *arrowWriter) close() error {
+	defer aw.builder.Release()
+	if err := aw.flush(); err != nil {
+		return err
+	}
+	return aw.writer.Close()
+}
+
+// appendArrowValue appends a value to a column builder of the given kind.
+// Values that do not fit the kind, which the schema or the inference rule
+// out, are appended as nulls.
+func appendArrowValue(b array.Builder, kind arrowKind, value any) error {
+	if value == nil {
+		b.AppendNull()
+		return nil
+	}
+
+	switch kind {
+	case arrowNumber:
+		if f, ok := arrowFloat(value); ok {
+			b.(*array.Float64Builder).Append(f)
+			return nil
+		}
+	case arrowInteger:
+		if f, ok := arrowFloat(value); ok {
+			b.(*array.Int64Builder).Append(int64(f))
+			return nil
+		}
+	case arrowBoolean:
+		if v, ok := value.(bool); ok {
+			b.(*array.BooleanBuilder).Append(v)
+			return nil
+		}
+	case arrowTimestamp:
+		var t time.Time
+		ok := false
+		switch v := value.(type) {
+		case time.Time:
+			t, ok = v, true
+		case string:
+			t, ok = parseArrowDate(v)
+		}
+		if ok {
+			b.(*array.TimestampBuilder).Append(arrow.Timestamp(t.UnixMilli()))
+			return nil
+		}
+	case arrowString:
+		switch v := value.(type) {
+		case string:
+			b.(*array.StringBuilder).Append(v)
+			return nil
+		case time.Time:
+			b.(*array.StringBuilder).Append(v.Format(time.RFC3339Nano))
+			return nil
+		}
+	case arrowJSON:
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		b.(*array.StringBuilder).Append(string(encoded))
+		return nil
+	}
+
+	b.AppendNull()
+	return nil
+}
+
+// parseArrowDate parses a date value as RFC 3339 or YYYY-MM-DD
+func parseArrowDate(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339Nano, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// arrowFloat converts a numeric value to float64
+func arrowFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int8:
+		return float64(v), true
+	case int16:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint:
+		return float64(v), true
+	case uint8:
+		return float64(v), true
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	}
+	return 0, false
+}
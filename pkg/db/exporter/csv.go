@@ -0,0 +1,144 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// CSVOptions configures a CSV export
+type CSVOptions struct {
+	// Mapping names the column of each field, in the form read by
+	// importer.LoadCSVMapping ({"Column": "field"}), so the same mapping
+	// file renames columns back on import. Unmapped fields keep their name.
+	Mapping map[string]string
+	// Delimiter separates columns (default ',')
+	Delimiter rune
+}
+
+// WriteCSV writes the documents of a collection as CSV with a header line,
+// one row per document in ID order. The columns are "_id", the schema
+// fields, then every other top-level field found in the documents, which
+// are read twice, one at a time: once to collect the columns and once to
+// write the rows. Objects and arrays are written as JSON, missing values as
+// empty cells. It returns the number of documents written.
+func WriteCSV(w io.Writer, coll *db.Collection, opts *CSVOptions) (int, error) {
+	if opts == nil {
+		opts = &CSVOptions{}
+	}
+	query := &db.Query{Sort: []db.SortField{{Field: "_id"}}}
+
+	fields, err := csvFields(coll, query)
+	if err != nil {
+		return 0, err
+	}
+
+	columns := make(map[string]string, len(opts.Mapping))
+	for column, field := range opts.Mapping {
+		if field != "" {
+			columns[field] = column
+		}
+	}
+	header := make([]string, len(fields))
+	for i, field := range fields {
+		header[i] = field
+		if column, ok := columns[field]; ok {
+			header[i] = column
+		}
+	}
+
+	cw := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		cw.Comma = opts.Delimiter
+	}
+	if err := cw.Write(header); err != nil {
+		return 0, err
+	}
+
+	docs, err := coll.FindIter(query)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	record := make([]string, len(fields))
+	for doc := range docs {
+		resolved, err := coll.ResolveBlobs(doc)
+		if err != nil {
+			return count, err
+		}
+
+		record[0] = resolved.ID
+		for i, field := range fields[1:] {
+			cell, err := csvCell(resolved.Data[field])
+			if err != nil {
+				return count, fmt.Errorf("document '%s' field '%s': %w", doc.ID, field, err)
+			}
+			record[i+1] = cell
+		}
+		if err := cw.Write(record); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	cw.Flush()
+	return count, cw.Error()
+}
+
+// csvFields returns the columns of a CSV export: "_id", the sorted schema
+// fields, then the other (non-reserved) fields of the documents, sorted
+func csvFields(coll *db.Collection, query *db.Query) ([]string, error) {
+	var schemaFields []string
+	seen := make(map[string]bool)
+	if coll.Schema != nil {
+		for name := range coll.Schema.Fields {
+			schemaFields = append(schemaFields, name)
+			seen[name] = true
+		}
+	}
+	sort.Strings(schemaFields)
+
+	docs, err := coll.FindIter(query)
+	if err != nil {
+		return nil, err
+	}
+	var others []string
+	for doc := range docs {
+		for name := range doc.Data {
+			if !seen[name] && !db.IsReservedField(name) {
+				seen[name] = true
+				others = append(others, name)
+			}
+		}
+	}
+	sort.Strings(others)
+
+	return append(append([]string{"_id"}, schemaFields...), others...), nil
+}
+
+// csvCell renders a value as a CSV cell, encoding objects and arrays as JSON
+func csvCell(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%v", v), nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
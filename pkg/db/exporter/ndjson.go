@@ -0,0 +1,52 @@
+package exporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// WriteNDJSON writes the documents of a collection as newline-delimited
+// JSON, one object per line in ID order with the ID in "_id". Documents are
+// read one at a time, so bodies the collection keeps on disk (see
+// Collection.SetMemoryBudget) are not all loaded at once. Blob references
+// are replaced by their values and reserved fields are left out, so the
+// output can be imported again with importer.ImportNDJSON. It returns the
+// number of documents written.
+func WriteNDJSON(w io.Writer, coll *db.Collection) (int, error) {
+	docs, err := coll.FindIter(&db.Query{Sort: []db.SortField{{Field: "_id"}}})
+	if err != nil {
+		return 0, err
+	}
+
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	enc.SetEscapeHTML(false)
+
+	count := 0
+	for doc := range docs {
+		resolved, err := coll.ResolveBlobs(doc)
+		if err != nil {
+			return count, err
+		}
+
+		line := make(map[string]any, len(resolved.Data)+1)
+		for k, v := range resolved.Data {
+			if !db.IsReservedField(k) {
+				line[k] = v
+			}
+		}
+		line["_id"] = resolved.ID
+
+		// Encode terminates each value with a newline
+		if err := enc.Encode(line); err != nil {
+			return count, fmt.Errorf("document '%s': %w", doc.ID, err)
+		}
+		count++
+	}
+
+	return count, bw.Flush()
+}
@@ -0,0 +1,157 @@
+// Package exporter writes CachyDB data to other databases and interchange formats.
+package exporter
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// SQLiteDataColumn holds the JSON of all fields not covered by the collection schema
+const SQLiteDataColumn = "data"
+
+// WriteSQLite writes a SQL script that recreates the given collections of a
+// database as SQLite tables. Each collection becomes a table with an "_id"
+// primary key, one typed column per schema field, and a JSON "data" column
+// holding every field that is not part of the schema. If collections is
+// empty, all collections are exported.
+func WriteSQLite(w io.Writer, database *db.Database, collections []string) error {
+	if len(collections) == 0 {
+		collections = database.ListCollections()
+	}
+	sort.Strings(collections)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "BEGIN TRANSACTION;")
+
+	for _, collName := range collections {
+		coll, err := database.GetCollection(collName)
+		if err != nil {
+			return err
+		}
+		if err := writeSQLiteTable(bw, coll); err != nil {
+			return fmt.Errorf("failed to export collection '%s': %w", collName, err)
+		}
+	}
+
+	fmt.Fprintln(bw, "COMMIT;")
+	return bw.Flush()
+}
+
+// writeSQLiteTable writes the CREATE TABLE and INSERT statements for one collection
+func writeSQLiteTable(w *bufio.Writer, coll *db.Collection) error {
+	var fields []string
+	if coll.Schema != nil {
+		for name := range coll.Schema.Fields {
+			if name != SQLiteDataColumn {
+				fields = append(fields, name)
+			}
+		}
+	}
+	sort.Strings(fields)
+
+	table := quoteIdent(coll.Name)
+	columns := []string{quoteIdent("_id") + " TEXT PRIMARY KEY"}
+	for _, name := range fields {
+		columns = append(columns, quoteIdent(name)+" "+sqliteType(coll.Schema.Fields[name].Type))
+	}
+	columns = append(columns, quoteIdent(SQLiteDataColumn)+" TEXT")
+
+	fmt.Fprintf(w, "DROP TABLE IF EXISTS %s;\n", table)
+	fmt.Fprintf(w, "CREATE TABLE %s (\n  %s\n);\n", table, strings.Join(columns, ",\n  "))
+
+	docs, err := coll.Find(&db.Query{})
+	if err != nil {
+		return err
+	}
+	sort.Slice(docs, func(i, j int) bool { return docs[i].ID < docs[j].ID })
+
+	for _, doc := range docs {
+		values := []string{quoteString(doc.ID)}
+
+		rest := make(map[string]any, len(doc.Data))
+		for k, v := range doc.Data {
+			rest[k] = v
+		}
+
+		for _, name := range fields {
+			value, exists := doc.Data[name]
+			delete(rest, name)
+			if !exists {
+				values = append(values, "NULL")
+				continue
+			}
+			literal, err := sqliteLiteral(value)
+			if err != nil {
+				return fmt.Errorf("document '%s' field '%s': %w", doc.ID, name, err)
+			}
+			values = append(values, literal)
+		}
+
+		if len(rest) == 0 {
+			values = append(values, "NULL")
+		} else {
+			encoded, err := json.Marshal(rest)
+			if err != nil {
+				return fmt.Errorf("document '%s': %w", doc.ID, err)
+			}
+			values = append(values, quoteString(string(encoded)))
+		}
+
+		fmt.Fprintf(w, "INSERT INTO %s VALUES (%s);\n", table, strings.Join(values, ", "))
+	}
+
+	return nil
+}
+
+// sqliteType maps a schema field type to a SQLite column type
+func sqliteType(fieldType db.FieldType) string {
+	switch fieldType {
+	case db.TypeNumber:
+		return "REAL"
+	case db.TypeBoolean:
+		return "INTEGER"
+	}
+	return "TEXT" // strings, dates, and JSON-encoded objects and arrays
+}
+
+// sqliteLiteral renders a value as a SQLite literal
+func sqliteLiteral(value any) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "NULL", nil
+	case string:
+		return quoteString(v), nil
+	case bool:
+		if v {
+			return "1", nil
+		}
+		return "0", nil
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), nil
+	case float32, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return fmt.Sprintf("%v", v), nil
+	}
+
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	return quoteString(string(encoded)), nil
+}
+
+// quoteIdent quotes a SQL identifier
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteString quotes a SQL string literal
+func quoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
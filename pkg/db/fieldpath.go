@@ -0,0 +1,154 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FieldPathSeparator separates the segments of a field path used by Update,
+// e.g. "settings.theme" or "items.0.qty". Numeric segments index arrays.
+const FieldPathSeparator = "."
+
+// splitFieldPath splits a field path into its segments
+func splitFieldPath(path string) ([]string, error) {
+	parts := strings.Split(path, FieldPathSeparator)
+	for _, part := range parts {
+		if part == "" {
+			return nil, fmt.Errorf("invalid field path '%s'", path)
+		}
+	}
+	return parts, nil
+}
+
+// getPath returns the value at a field path
+func getPath(data map[string]any, path string) (any, bool) {
+	parts, err := splitFieldPath(path)
+	if err != nil {
+		return nil, false
+	}
+
+	var current any = data
+	for _, part := range parts {
+		next, exists, err := pathChild(current, part)
+		if err != nil || !exists {
+			return nil, false
+		}
+		current = next
+	}
+	return current, true
+}
+
+// setPath sets the value at a field path. Missing objects along the path are
+// created; array indices must already exist.
+func setPath(data map[string]any, path string, value any) error {
+	parts, err := splitFieldPath(path)
+	if err != nil {
+		return err
+	}
+
+	var current any = data
+	for i, part := range parts[:len(parts)-1] {
+		next, exists, err := pathChild(current, part)
+		if err != nil {
+			return fmt.Errorf("'%s': %w", strings.Join(parts[:i], FieldPathSeparator), err)
+		}
+		if !exists {
+			// Only objects report missing children
+			next = make(map[string]any)
+			current.(map[string]any)[part] = next
+		}
+		current = next
+	}
+
+	last := parts[len(parts)-1]
+	switch container := current.(type) {
+	case map[string]any:
+		container[last] = value
+	case []any:
+		index, err := pathIndex(last, len(container))
+		if err != nil {
+			return err
+		}
+		container[index] = value
+	default:
+		return fmt.Errorf("'%s': not an object or array", strings.Join(parts[:len(parts)-1], FieldPathSeparator))
+	}
+	return nil
+}
+
+// unsetPath removes the value at a field path. Array elements are set to
+// null so later elements keep their indices. Missing paths are ignored.
+func unsetPath(data map[string]any, path string) error {
+	parts, err := splitFieldPath(path)
+	if err != nil {
+		return err
+	}
+
+	var current any = data
+	for _, part := range parts[:len(parts)-1] {
+		next, exists, err := pathChild(current, part)
+		if err != nil || !exists {
+			return nil
+		}
+		current = next
+	}
+
+	last := parts[len(parts)-1]
+	switch container := current.(type) {
+	case map[string]any:
+		delete(container, last)
+	case []any:
+		if index, err := pathIndex(last, len(container)); err == nil {
+			container[index] = nil
+		}
+	}
+	return nil
+}
+
+// pathChild returns the child of an object or array for one path segment.
+// exists is false only for a missing object key.
+func pathChild(container any, part string) (value any, exists bool, err error) {
+	switch c := container.(type) {
+	case map[string]any:
+		value, exists = c[part]
+		return value, exists, nil
+	case []any:
+		index, err := pathIndex(part, len(c))
+		if err != nil {
+			return nil, false, err
+		}
+		return c[index], true, nil
+	}
+	return nil, false, fmt.Errorf("not an object or array")
+}
+
+func pathIndex(part string, length int) (int, error) {
+	index, err := strconv.Atoi(part)
+	if err != nil || index < 0 {
+		return 0, fmt.Errorf("invalid array index '%s'", part)
+	}
+	if index >= length {
+		return 0, fmt.Errorf("array index %d out of bounds", index)
+	}
+	return index, nil
+}
+
+// checkPathConflicts rejects field paths where one is a prefix of another,
+// such as "settings" and "settings.theme", since the result would depend
+// on the order they are applied in
+func checkPathConflicts(paths []string) error {
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		seen[path] = true
+	}
+	for _, path := range paths {
+		parts := strings.Split(path, FieldPathSeparator)
+		for i := 1; i < len(parts); i++ {
+			if parent := strings.Join(parts[:i], FieldPathSeparator); seen[parent] {
+				return fmt.Errorf("field paths '%s' and '%s' conflict", parent, path)
+			}
+		}
+	}
+	return nil
+}
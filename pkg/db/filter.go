@@ -0,0 +1,143 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"golang.org/x/text/collate"
+)
+
+// maxFilterDepth bounds the nesting of filter groups
+const maxFilterDepth = 32
+
+// maxCachedRegexes bounds the compiled regex cache; it is emptied when full
+const maxCachedRegexes = 256
+
+var (
+	regexCache   = make(map[string]*regexp.Regexp)
+	regexCacheMu sync.RWMutex
+)
+
+// Filter is a node of a boolean filter tree. A node with a Field is a single
+// condition, like a QueryFilter; And requires every child to match and Or at
+// least one. When several parts are set they must all hold, so
+// (a = 1 OR b = 2) AND c > 3 is written as
+//
+//	{"or": [{"field": "a", ...}, {"field": "b", ...}], "and": [{"field": "c", ...}]}
+type Filter struct {
+	QueryFilter
+	And []Filter `json:"and,omitempty"`
+	Or  []Filter `json:"or,omitempty"`
+}
+
+// Validate checks that every node of the tree has a condition or children
+func (f *Filter) Validate() error {
+	return f.validate(0)
+}
+
+func (f *Filter) validate(depth int) error {
+	if depth > maxFilterDepth {
+		return fmt.Errorf("filter is nested more than %d levels deep", maxFilterDepth)
+	}
+	if f.Field == "" && len(f.And) == 0 && len(f.Or) == 0 {
+		return fmt.Errorf("filter must have a field, an \"and\" group or an \"or\" group")
+	}
+	if f.Field != "" {
+		if f.Operator == "" {
+			return fmt.Errorf("filter on field '%s' has no operator", f.Field)
+		}
+		if err := validateQueryFilter(f.QueryFilter); err != nil {
+			return err
+		}
+	}
+	for i := range f.And {
+		if err := f.And[i].validate(depth + 1); err != nil {
+			return err
+		}
+	}
+	for i := range f.Or {
+		if err := f.Or[i].validate(depth + 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateQueryFilter checks the value of the string operators
+func validateQueryFilter(filter QueryFilter) error {
+	switch filter.Operator {
+	case "regex":
+		if _, err := compileRegex(filter.Value); err != nil {
+			return fmt.Errorf("filter on field '%s': %w", filter.Field, err)
+		}
+	case "in", "nin":
+		if _, ok := filterValues(filter.Value); !ok {
+			return fmt.Errorf("filter on field '%s': %s needs an array value", filter.Field, filter.Operator)
+		}
+	case "prefix", "suffix", "contains":
+		if _, ok := filter.Value.(string); !ok {
+			return fmt.Errorf("filter on field '%s': %s needs a string value", filter.Field, filter.Operator)
+		}
+	}
+	return nil
+}
+
+// compileRegex returns the compiled form of a regex filter value, caching
+// it so a query does not recompile the pattern for every document
+func compileRegex(value any) (*regexp.Regexp, error) {
+	pattern, ok := value.(string)
+	if !ok {
+		return nil, fmt.Errorf("regex needs a string pattern")
+	}
+
+	regexCacheMu.RLock()
+	re, exists := regexCache[pattern]
+	regexCacheMu.RUnlock()
+	if exists {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+
+	regexCacheMu.Lock()
+	defer regexCacheMu.Unlock()
+	if len(regexCache) >= maxCachedRegexes {
+		clear(regexCache)
+	}
+	regexCache[pattern] = re
+	return re, nil
+}
+
+// Matches reports whether doc satisfies the filter, comparing strings
+// byte-wise. A nil filter matches every document.
+func (f *Filter) Matches(doc *Document) bool {
+	return f.matches(doc, nil)
+}
+
+// matches evaluates the tree against a document. A nil filter matches everything.
+func (f *Filter) matches(doc *Document, col *collate.Collator) bool {
+	if f == nil {
+		return true
+	}
+	if f.Field != "" && !matchesFilter(doc, f.QueryFilter, col) {
+		return false
+	}
+	for i := range f.And {
+		if !f.And[i].matches(doc, col) {
+			return false
+		}
+	}
+	if len(f.Or) == 0 {
+		return true
+	}
+	for i := range f.Or {
+		if f.Or[i].matches(doc, col) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package db
+
+// FindOneAndUpdateOptions configures FindOneAndUpdate
+type FindOneAndUpdateOptions struct {
+	// ReturnNew returns the document as it is after the update instead of before
+	ReturnNew bool `json:"return_new,omitempty"`
+}
+
+// FindOneAndUpdate updates the first document matching query (in query
+// order, so Sort picks which one) and returns a copy of it from before the
+// update, or after it with opts.ReturnNew. The lookup and the update happen
+// under one lock, so two concurrent calls never update the same document
+// based on the same state, e.g. when claiming jobs from a queue:
+//
+//	job, err := jobs.FindOneAndUpdate(
+//		&Query{Filters: []QueryFilter{{Field: "status", Operator: "eq", Value: "pending"}}},
+//		map[string]any{"status": "running"},
+//		FindOneAndUpdateOptions{ReturnNew: true})
+//
+// updates takes the same forms as in Update. It returns nil and no error if
+// no document matches.
+func (c *Collection) FindOneAndUpdate(query *Query, updates map[string]any, opts FindOneAndUpdateOptions) (*Document, error) {
+	mutate, err := userUpdate(updates)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	matches, _, err := c.findLocked(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	old := matches[0]
+	if err := c.modifyLocked(old.ID, mutate); err != nil {
+		return nil, err
+	}
+
+	if opts.ReturnNew {
+		return c.Documents[old.ID].Clone(), nil
+	}
+	return old.Clone(), nil
+}
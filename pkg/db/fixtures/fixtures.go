@@ -0,0 +1,305 @@
+// Package fixtures generates realistic fake documents for CachyDB collections.
+// It is used by the `utils seed` command and is handy for tests and demos.
+package fixtures
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+var (
+	firstNames = []string{"Alice", "Bob", "Carol", "David", "Emma", "Frank", "Grace", "Henry", "Irene", "Jack", "Karen", "Liam", "Maria", "Noah", "Olivia", "Peter"}
+	lastNames  = []string{"Smith", "Johnson", "Brown", "Garcia", "Miller", "Davis", "Wilson", "Moore", "Taylor", "Anderson", "Thomas", "Martin", "Lee", "Walker"}
+	cities     = []string{"New York", "London", "Paris", "Berlin", "Tokyo", "Sydney", "Toronto", "Madrid", "Rome", "Amsterdam", "Yerevan", "Seoul"}
+	countries  = []string{"USA", "UK", "France", "Germany", "Japan", "Australia", "Canada", "Spain", "Italy", "Netherlands", "Armenia", "Korea"}
+	domains    = []string{"example.com", "mail.test", "demo.org", "sample.net"}
+	words      = []string{"alpha", "beta", "gamma", "delta", "quick", "brown", "lazy", "bright", "silent", "river", "mountain", "cloud", "stone", "forest", "ocean", "signal"}
+	statuses   = []string{"active", "inactive", "pending", "archived"}
+)
+
+// Epoch is the time generated dates lead up to, unless WithNow sets another.
+// It is fixed so that a seed yields the same documents on every run.
+var Epoch = time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// Generator produces fake documents. A Generator created with the same seed
+// and options always yields the same sequence of documents.
+type Generator struct {
+	rng *rand.Rand
+	now time.Time
+	// patterns caches the parsed patterns of string fields
+	patterns map[string]*pattern
+}
+
+// Option configures NewGenerator
+type Option func(*Generator)
+
+// WithNow makes generated dates lead up to now instead of Epoch
+func WithNow(now time.Time) Option {
+	return func(g *Generator) {
+		g.now = now.UTC()
+	}
+}
+
+// NewGenerator creates a new generator with the given seed
+func NewGenerator(seed uint64, opts ...Option) *Generator {
+	g := &Generator{
+		rng:      rand.New(rand.NewPCG(seed, seed^0x9E3779B97F4A7C15)),
+		now:      Epoch,
+		patterns: make(map[string]*pattern),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+	return g
+}
+
+// Document generates a single document conforming to schema.
+// With a nil schema a generic person-like document is produced.
+func (g *Generator) Document(schema *db.Schema) *db.Document {
+	if schema == nil || len(schema.Fields) == 0 {
+		return &db.Document{Data: g.genericData()}
+	}
+
+	// Fields are filled in name order, so the same seed draws the same
+	// values for them
+	names := make([]string, 0, len(schema.Fields))
+	for name := range schema.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	data := make(map[string]any, len(schema.Fields))
+	for _, name := range names {
+		field := schema.Fields[name]
+		// Leave roughly one in five optional fields empty
+		if !field.Required && g.rng.IntN(5) == 0 {
+			continue
+		}
+		data[name] = g.Value(name, field)
+	}
+
+	return &db.Document{Data: data}
+}
+
+// Documents generates n documents conforming to schema
+func (g *Generator) Documents(schema *db.Schema, n int) []*db.Document {
+	docs := make([]*db.Document, n)
+	for i := range docs {
+		docs[i] = g.Document(schema)
+	}
+	return docs
+}
+
+// Value generates a value for a field, using the field name as a hint for
+// realistic data. Object fields with a nested schema and array fields with
+// an element type get values conforming to them, fields with an enum get
+// one of its values, numbers are kept within their min and max, strings
+// match the field's pattern and length limits, and unique fields get values
+// unlikely to repeat.
+func (g *Generator) Value(name string, field db.Field) any {
+	lower := strings.ToLower(name)
+
+	if len(field.Enum) > 0 {
+		return field.Enum[g.rng.IntN(len(field.Enum))]
+	}
+
+	// Values of unique fields get a random part, so documents seldom
+	// collide on the field's unique index
+	if field.Unique && field.Type == db.TypeNumber && field.Min == nil && field.Max == nil {
+		return float64(g.rng.IntN(1 << 30))
+	}
+
+	switch field.Type {
+	case db.TypeString:
+		return g.stringValue(lower, field)
+	case db.TypeNumber:
+		n := g.numberFor(lower)
+		if field.Min != nil {
+			n = max(n, *field.Min)
+		}
+		if field.Max != nil {
+			n = min(n, *field.Max)
+		}
+		return n
+	case db.TypeBoolean:
+		return g.rng.IntN(2) == 0
+	case db.TypeDate:
+		return g.date().Format(time.RFC3339)
+	case db.TypeArray:
+		n := 1 + g.rng.IntN(3)
+		tags := make([]any, n)
+		for i := range tags {
+			if field.Items != nil {
+				tags[i] = g.Value(name, *field.Items)
+			} else {
+				tags[i] = g.pick(words)
+			}
+		}
+		return tags
+	case db.TypeObject:
+		if field.Schema != nil {
+			return g.Document(field.Schema).Data
+		}
+		return map[string]any{
+			"city":    g.pick(cities),
+			"country": g.pick(countries),
+		}
+	}
+
+	return nil
+}
+
+// stringValue generates a string for a field. A pattern takes precedence
+// over the name; otherwise the value is picked by stringFor and fitted to
+// the length limits. Unique values get a random part, inside the local part
+// of email addresses so that they stay valid.
+func (g *Generator) stringValue(name string, field db.Field) string {
+	if field.Pattern != "" {
+		return g.patternString(field)
+	}
+
+	value := g.stringFor(name)
+	suffix := ""
+	if field.Unique {
+		random := fmt.Sprintf("%08x", g.rng.Uint32())
+		if local, domain, ok := strings.Cut(value, "@"); ok && strings.Contains(name, "email") {
+			value = local + "." + random + "@" + domain
+		} else {
+			suffix = "-" + random
+		}
+	}
+	return g.fitLength(value, suffix, field)
+}
+
+// fitLength pads value with words, or cuts it, so that value followed by
+// suffix is within the field's length limits. The suffix is kept whole
+// unless it is longer than the limit itself.
+func (g *Generator) fitLength(value, suffix string, field db.Field) string {
+	for utf8.RuneCountInString(value+suffix) < field.MinLength {
+		value += " " + g.pick(words)
+	}
+	if field.MaxLength <= 0 {
+		return value + suffix
+	}
+
+	keep := max(field.MaxLength-utf8.RuneCountInString(suffix), 0)
+	if runes := []rune(value); len(runes) > keep {
+		value = string(runes[:keep])
+	}
+	runes := []rune(value + suffix)
+	if len(runes) > field.MaxLength {
+		runes = runes[len(runes)-field.MaxLength:]
+	}
+	return string(runes)
+}
+
+// stringFor picks a plausible string based on the field name
+func (g *Generator) stringFor(name string) string {
+	switch {
+	case strings.Contains(name, "email"):
+		return g.email()
+	case strings.Contains(name, "first"):
+		return g.pick(firstNames)
+	case strings.Contains(name, "last") || strings.Contains(name, "surname"):
+		return g.pick(lastNames)
+	case strings.Contains(name, "name") || strings.Contains(name, "author"):
+		return g.pick(firstNames) + " " + g.pick(lastNames)
+	case strings.Contains(name, "city"):
+		return g.pick(cities)
+	case strings.Contains(name, "country"):
+		return g.pick(countries)
+	case strings.Contains(name, "phone"):
+		return fmt.Sprintf("+1-555-%03d-%04d", g.rng.IntN(1000), g.rng.IntN(10000))
+	case strings.Contains(name, "url") || strings.Contains(name, "website"):
+		return fmt.Sprintf("https://%s/%s", g.pick(domains), g.pick(words))
+	case strings.Contains(name, "status"):
+		return g.pick(statuses)
+	case strings.Contains(name, "date") || strings.HasSuffix(name, "_at"):
+		return g.date().Format(time.RFC3339)
+	case strings.Contains(name, "title") || strings.Contains(name, "subject"):
+		title := g.sentence(3)
+		return strings.ToUpper(title[:1]) + title[1:]
+	case strings.Contains(name, "description") || strings.Contains(name, "body") || strings.Contains(name, "text"):
+		return g.sentence(12)
+	}
+	return g.pick(words)
+}
+
+// numberFor picks a plausible number based on the field name
+func (g *Generator) numberFor(name string) float64 {
+	switch {
+	case strings.Contains(name, "age"):
+		return float64(18 + g.rng.IntN(63))
+	case strings.Contains(name, "price") || strings.Contains(name, "amount") || strings.Contains(name, "cost"):
+		return float64(g.rng.IntN(100000)) / 100
+	case strings.Contains(name, "rating") || strings.Contains(name, "score"):
+		return float64(g.rng.IntN(51)) / 10
+	case strings.Contains(name, "year"):
+		return float64(1970 + g.rng.IntN(g.now.Year()-1969))
+	case strings.Contains(name, "count") || strings.Contains(name, "quantity") || strings.Contains(name, "stock"):
+		return float64(g.rng.IntN(500))
+	}
+	return float64(g.rng.IntN(1000))
+}
+
+// genericData produces a schema-less document
+func (g *Generator) genericData() map[string]any {
+	first, last := g.pick(firstNames), g.pick(lastNames)
+	return map[string]any{
+		"name":       first + " " + last,
+		"email":      strings.ToLower(first+"."+last) + "@" + g.pick(domains),
+		"age":        float64(18 + g.rng.IntN(63)),
+		"city":       g.pick(cities),
+		"active":     g.rng.IntN(2) == 0,
+		"created_at": g.date().Format(time.RFC3339),
+	}
+}
+
+func (g *Generator) email() string {
+	return strings.ToLower(g.pick(firstNames)+"."+g.pick(lastNames)) +
+		fmt.Sprintf("%d@", g.rng.IntN(1000)) + g.pick(domains)
+}
+
+// date returns a random time within the last two years
+func (g *Generator) date() time.Time {
+	offset := time.Duration(g.rng.Int64N(int64(2 * 365 * 24 * time.Hour)))
+	return g.now.Add(-offset).Truncate(time.Second)
+}
+
+func (g *Generator) sentence(n int) string {
+	parts := make([]string, n)
+	for i := range parts {
+		parts[i] = g.pick(words)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (g *Generator) pick(values []string) string {
+	return values[g.rng.IntN(len(values))]
+}
+
+// Load inserts documents into a collection, returning how many were inserted.
+// It stops at the first insert error.
+func Load(coll *db.Collection, docs []*db.Document) (int, error) {
+	for i, doc := range docs {
+		if err := coll.Insert(doc); err != nil {
+			return i, fmt.Errorf("failed to insert document %d: %w", i, err)
+		}
+	}
+	return len(docs), nil
+}
+
+// Seed generates n documents matching the collection's schema and loads them
+func Seed(coll *db.Collection, n int, seed uint64) ([]*db.Document, error) {
+	docs := NewGenerator(seed).Documents(coll.Schema, n)
+	if _, err := Load(coll, docs); err != nil {
+		return nil, err
+	}
+	return docs, nil
+}
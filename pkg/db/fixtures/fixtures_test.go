@@ -0,0 +1,138 @@
+package fixtures
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+func ptr(f float64) *float64 { return &f }
+
+// testSchema covers the field types and constraints the generator honours
+func testSchema() *db.Schema {
+	return &db.Schema{Fields: map[string]db.Field{
+		"name":    {Type: db.TypeString, Required: true},
+		"email":   {Type: db.TypeString, Required: true, Unique: true},
+		"age":     {Type: db.TypeNumber, Min: ptr(21), Max: ptr(30)},
+		"status":  {Type: db.TypeString, Enum: []any{"new", "done"}},
+		"active":  {Type: db.TypeBoolean},
+		"created": {Type: db.TypeDate},
+		"address": {Type: db.TypeObject, Schema: &db.Schema{Fields: map[string]db.Field{
+			"city": {Type: db.TypeString, Required: true},
+			"zip":  {Type: db.TypeNumber, Min: ptr(1000), Max: ptr(9999)},
+		}}},
+		"scores": {Type: db.TypeArray, Items: &db.Field{Type: db.TypeNumber, Min: ptr(0), Max: ptr(5)}},
+		"sku":    {Type: db.TypeString, Unique: true, Pattern: `^[A-Z]{3}-\d{4}$`},
+		"code":   {Type: db.TypeString, Pattern: `^(ab|cd)+x?$`, MinLength: 6, MaxLength: 9},
+		"title":  {Type: db.TypeString, Unique: true, MinLength: 30, MaxLength: 40},
+		"city":   {Type: db.TypeString, MaxLength: 3},
+	}}
+}
+
+func TestDocumentsAreDeterministic(t *testing.T) {
+	schema := testSchema()
+	first := NewGenerator(42).Documents(schema, 50)
+	second := NewGenerator(42).Documents(schema, 50)
+
+	for i := range first {
+		if !reflect.DeepEqual(first[i].Data, second[i].Data) {
+			t.Fatalf("document %d differs between generators with the same seed:\n%v\n%v", i, first[i].Data, second[i].Data)
+		}
+	}
+}
+
+func TestDatesDependOnlyOnTheSeed(t *testing.T) {
+	first := NewGenerator(3).Value("created", db.Field{Type: db.TypeDate})
+	second := NewGenerator(3).Value("created", db.Field{Type: db.TypeDate})
+	if first != second {
+		t.Fatalf("dates differ between runs with the same seed: %v, %v", first, second)
+	}
+
+	now := time.Date(2020, time.June, 1, 0, 0, 0, 0, time.UTC)
+	value := NewGenerator(3, WithNow(now)).Value("created", db.Field{Type: db.TypeDate})
+	date, err := time.Parse(time.RFC3339, value.(string))
+	if err != nil {
+		t.Fatalf("date %v is not RFC 3339: %v", value, err)
+	}
+	if date.After(now) || date.Before(now.AddDate(-2, 0, 0)) {
+		t.Fatalf("date %v is not in the two years before %v", date, now)
+	}
+}
+
+func TestUniqueEmailsStayValid(t *testing.T) {
+	g := NewGenerator(5)
+	for range 100 {
+		email := g.Value("email", db.Field{Type: db.TypeString, Unique: true}).(string)
+		local, domain, ok := strings.Cut(email, "@")
+		if !ok || local == "" || !slices.Contains(domains, domain) {
+			t.Fatalf("unique email %q is not a valid address", email)
+		}
+	}
+}
+
+func TestDocumentsMatchSchema(t *testing.T) {
+	schema := testSchema()
+	for i, doc := range NewGenerator(7).Documents(schema, 500) {
+		if err := schema.ValidateDocument(doc); err != nil {
+			t.Fatalf("document %d does not match the schema: %v (%v)", i, err, doc.Data)
+		}
+	}
+}
+
+func TestDocumentWithoutSchema(t *testing.T) {
+	doc := NewGenerator(1).Document(nil)
+	for _, field := range []string{"name", "email", "age", "city", "active", "created_at"} {
+		if _, ok := doc.Data[field]; !ok {
+			t.Errorf("generic document has no '%s' field: %v", field, doc.Data)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	database := db.NewDatabaseManager().CreateDatabase("test")
+	if err := database.CreateCollection("people", testSchema()); err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	coll, err := database.GetCollection("people")
+	if err != nil {
+		t.Fatalf("failed to get collection: %v", err)
+	}
+
+	docs, err := Seed(coll, 200, 3)
+	if err != nil {
+		t.Fatalf("failed to seed: %v", err)
+	}
+	if len(docs) != 200 || coll.Count() != 200 {
+		t.Fatalf("seeded %d documents, collection has %d, want 200", len(docs), coll.Count())
+	}
+}
+
+func TestLoadStopsAtFirstError(t *testing.T) {
+	database := db.NewDatabaseManager().CreateDatabase("test")
+	schema := &db.Schema{Fields: map[string]db.Field{"code": {Type: db.TypeString, Unique: true}}}
+	if err := database.CreateCollection("codes", schema); err != nil {
+		t.Fatalf("failed to create collection: %v", err)
+	}
+	coll, err := database.GetCollection("codes")
+	if err != nil {
+		t.Fatalf("failed to get collection: %v", err)
+	}
+
+	docs := []*db.Document{
+		{Data: map[string]any{"code": "a"}},
+		{Data: map[string]any{"code": "b"}},
+		{Data: map[string]any{"code": "a"}},
+		{Data: map[string]any{"code": "c"}},
+	}
+	loaded, err := Load(coll, docs)
+	if err == nil {
+		t.Fatal("expected the duplicate code to fail")
+	}
+	if loaded != 2 || coll.Count() != 2 {
+		t.Fatalf("loaded %d documents, collection has %d, want 2", loaded, coll.Count())
+	}
+}
@@ -0,0 +1,129 @@
+package fixtures
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+const (
+	// maxRepeat is how many times *, + and {n,} repeat at most beyond their
+	// minimum, unless the field's minimum length asks for more
+	maxRepeat = 3
+	// patternAttempts bounds the expansions drawn for a field whose pattern
+	// and length limits are hard to meet together
+	patternAttempts = 100
+	// anyChars are the characters '.' expands to
+	anyChars = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// pattern is a string field's pattern, parsed for expansion and compiled
+// to check the results
+type pattern struct {
+	tree *syntax.Regexp
+	re   *regexp.Regexp
+}
+
+// patternString generates a string matching the field's pattern within its
+// length limits. Random expansions of the pattern are drawn until one fits;
+// if none does, the last one is returned, and the schema rejects it on
+// insert with an error naming the field. An invalid pattern yields "".
+func (g *Generator) patternString(field db.Field) string {
+	p, cached := g.patterns[field.Pattern]
+	if !cached {
+		p = parsePattern(field.Pattern)
+		g.patterns[field.Pattern] = p
+	}
+	if p == nil {
+		return ""
+	}
+
+	extra := max(maxRepeat, field.MinLength)
+	var value string
+	for range patternAttempts {
+		var b strings.Builder
+		g.expand(&b, p.tree, extra)
+		value = b.String()
+
+		length := utf8.RuneCountInString(value)
+		if length >= field.MinLength && (field.MaxLength <= 0 || length <= field.MaxLength) && p.re.MatchString(value) {
+			break
+		}
+	}
+	return value
+}
+
+func parsePattern(expr string) *pattern {
+	tree, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil
+	}
+	return &pattern{tree: tree, re: re}
+}
+
+// expand appends a random string matched by re to b. Unbounded repeats add
+// up to extra repetitions. Anchors, word boundaries and empty matches add
+// nothing.
+func (g *Generator) expand(b *strings.Builder, re *syntax.Regexp, extra int) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			b.WriteRune(r)
+		}
+	case syntax.OpCharClass:
+		b.WriteRune(g.classRune(re.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteByte(anyChars[g.rng.IntN(len(anyChars))])
+	case syntax.OpCapture:
+		g.expand(b, re.Sub[0], extra)
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			g.expand(b, sub, extra)
+		}
+	case syntax.OpAlternate:
+		g.expand(b, re.Sub[g.rng.IntN(len(re.Sub))], extra)
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		lo, hi := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			lo, hi = 0, -1
+		case syntax.OpPlus:
+			lo, hi = 1, -1
+		case syntax.OpQuest:
+			lo, hi = 0, 1
+		}
+		if hi < 0 {
+			hi = lo + extra
+		}
+		for range lo + g.rng.IntN(hi-lo+1) {
+			g.expand(b, re.Sub[0], extra)
+		}
+	}
+}
+
+// classRune picks a rune of a character class, given as pairs of range
+// bounds, preferring printable ASCII
+func (g *Generator) classRune(ranges []rune) rune {
+	var printable []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		for r := max(ranges[i], ' '); r <= min(ranges[i+1], '~'); r++ {
+			printable = append(printable, r)
+		}
+	}
+	if len(printable) > 0 {
+		return printable[g.rng.IntN(len(printable))]
+	}
+	if len(ranges) < 2 {
+		return 'x' // a class matching nothing; the value fails the pattern
+	}
+
+	i := 2 * g.rng.IntN(len(ranges)/2)
+	return ranges[i] + rune(g.rng.IntN(int(ranges[i+1]-ranges[i])+1))
+}
@@ -0,0 +1,373 @@
+package db
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FsckOptions selects what Fsck checks
+type FsckOptions struct {
+	Database   string // only this database ("" for all)
+	Collection string // only this collection of Database ("" for all)
+	// Repair rewrites index files that disagree with the documents, rebuilding
+	// them from the documents that could be read
+	Repair bool
+}
+
+// FsckIssue describes one problem found on disk
+type FsckIssue struct {
+	Database   string `json:"database,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	DocumentID string `json:"document_id,omitempty"`
+	File       string `json:"file"` // relative to the root directory
+	Problem    string `json:"problem"`
+	Repaired   bool   `json:"repaired,omitempty"`
+}
+
+// FsckReport summarizes an integrity check of a data directory
+type FsckReport struct {
+	Collections int         `json:"collections"`
+	Documents   int         `json:"documents"` // documents read back intact
+	WALFiles    int         `json:"wal_files"`
+	WALEntries  int         `json:"wal_entries"`
+	Issues      []FsckIssue `json:"issues,omitempty"`
+}
+
+// OK reports whether every issue found was repaired
+func (r *FsckReport) OK() bool {
+	for _, issue := range r.Issues {
+		if !issue.Repaired {
+			return false
+		}
+	}
+	return true
+}
+
+// fsckCollection collects the issues of one collection
+type fsckCollection struct {
+	report *FsckReport
+	root   string
+	dbName string
+	name   string
+	dir    string
+}
+
+func (fc *fsckCollection) issue(path, docID, format string, args ...any) {
+	rel, err := filepath.Rel(fc.root, path)
+	if err != nil {
+		rel = path
+	}
+	fc.report.Issues = append(fc.report.Issues, FsckIssue{
+		Database:   fc.dbName,
+		Collection: fc.name,
+		DocumentID: docID,
+		File:       rel,
+		Problem:    fmt.Sprintf(format, args...),
+	})
+}
+
+// Fsck checks the files of a data directory without loading it: every
+// document of binary data files is read back and checked against its
+// checksum and offset index entry, persisted indexes are compared with the
+// documents in both directions, segments and JSON documents are parsed, and
+// every WAL file is read through. Problems are collected in the report
+// rather than returned as an error. The directory should not be in use by
+// a server while it is checked.
+func (sm *StorageManager) Fsck(opts FsckOptions) (*FsckReport, error) {
+	if opts.Repair && sm.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	databases := []string{opts.Database}
+	if opts.Database == "" {
+		var err error
+		if databases, err = sm.ListStoredDatabases(); err != nil {
+			return nil, err
+		}
+		sort.Strings(databases)
+	}
+
+	report := &FsckReport{}
+	for _, dbName := range databases {
+		collections := []string{opts.Collection}
+		if opts.Collection == "" {
+			entries, err := os.ReadDir(filepath.Join(sm.RootDir, dbName))
+			if err != nil {
+				return nil, fmt.Errorf("failed to read database '%s': %w", dbName, err)
+			}
+			collections = collections[:0]
+			for _, entry := range entries {
+				if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+					collections = append(collections, entry.Name())
+				}
+			}
+		}
+
+		for _, collName := range collections {
+			fc := &fsckCollection{
+				report: report,
+				root:   sm.RootDir,
+				dbName: dbName,
+				name:   collName,
+				dir:    filepath.Join(sm.RootDir, dbName, collName),
+			}
+			if err := sm.fsckCollection(fc, opts.Repair); err != nil {
+				return nil, fmt.Errorf("failed to check collection '%s' of database '%s': %w", collName, dbName, err)
+			}
+			report.Collections++
+		}
+	}
+
+	sm.fsckWAL(report)
+	return report, nil
+}
+
+func (sm *StorageManager) fsckCollection(fc *fsckCollection, repair bool) error {
+	metaPath := filepath.Join(fc.dir, "collection.meta.json")
+	meta, err := sm.readCollectionMeta(fc.dbName, fc.name)
+	if err != nil {
+		fc.issue(metaPath, "", "%v", err)
+		return nil
+	}
+
+	switch meta.Format {
+	case FormatBinary:
+		docs, unreadable := fsckBinaryData(fc)
+		if docs != nil {
+			return fsckIndexes(fc, meta, docs, unreadable, repair)
+		}
+	case FormatSegmented:
+		fsckSegments(fc)
+	default:
+		docsPath := filepath.Join(fc.dir, "documents.json")
+		var docs []*Document
+		if err := sm.readJSON(docsPath, &docs); err != nil {
+			if !os.IsNotExist(err) {
+				fc.issue(docsPath, "", "unreadable documents: %v", err)
+			}
+			return nil
+		}
+		fc.report.Documents += len(docs)
+	}
+	return nil
+}
+
+// fsckBinaryData reads back every document of a binary data file. It
+// returns the intact documents and the IDs of the unreadable ones, or nil
+// documents if the files cannot be checked at all.
+func fsckBinaryData(fc *fsckCollection) (map[string]*Document, map[string]bool) {
+	dataPath := filepath.Join(fc.dir, "collection.data")
+	info, err := os.Stat(dataPath)
+	if os.IsNotExist(err) {
+		if _, err := os.Stat(filepath.Join(fc.dir, "collection.idx")); err == nil {
+			fc.issue(dataPath, "", "data file is missing but its offset index exists")
+			return nil, nil
+		}
+		return map[string]*Document{}, nil // never saved
+	}
+
+	reader, err := NewBinaryCollectionReader(fc.root, fc.dbName, fc.name)
+	if err != nil {
+		fc.issue(dataPath, "", "%v", err)
+		return nil, nil
+	}
+	defer reader.Close()
+
+	ids := make([]string, 0, len(reader.index.Entries))
+	for id := range reader.index.Entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	docs := make(map[string]*Document, len(ids))
+	unreadable := make(map[string]bool)
+	head := make([]byte, DocEntryHeaderSize)
+	for _, id := range ids {
+		entry := reader.index.Entries[id]
+		problem := ""
+		switch {
+		case entry.Offset < HeaderSize || entry.Offset+DocEntryHeaderSize+int64(entry.CompressedSize) > info.Size():
+			problem = fmt.Sprintf("offset index entry points past the end of the data file (offset %d)", entry.Offset)
+		default:
+			if _, err := reader.dataFile.ReadAt(head, entry.Offset); err != nil {
+				problem = fmt.Sprintf("failed to read entry header: %v", err)
+			} else if binary.LittleEndian.Uint64(head[0:8]) != uint64(entry.Offset) ||
+				binary.LittleEndian.Uint32(head[12:16]) != entry.CompressedSize ||
+				binary.LittleEndian.Uint32(head[16:20]) != entry.Checksum {
+				problem = fmt.Sprintf("entry header at offset %d does not match the offset index", entry.Offset)
+			} else if doc, err := reader.ReadDocument(id); err != nil {
+				problem = err.Error()
+			} else if doc.ID != id {
+				problem = fmt.Sprintf("entry at offset %d holds document '%s'", entry.Offset, doc.ID)
+			} else {
+				docs[id] = doc
+			}
+		}
+		if problem != "" {
+			fc.issue(dataPath, id, "%s", problem)
+			unreadable[id] = true
+		}
+	}
+	fc.report.Documents += len(docs)
+	return docs, unreadable
+}
+
+// fsckIndexes compares the persisted indexes of a binary collection with
+// indexes built from its documents, and rewrites the ones that differ when
+// repairing
+func fsckIndexes(fc *fsckCollection, meta *collectionMeta, docs map[string]*Document, unreadable map[string]bool, repair bool) error {
+	indexDir := filepath.Join(fc.dir, "indexes")
+	names := make([]string, 0, len(meta.Indexes))
+	for name := range meta.Indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(indexDir, name+".json")
+		fresh := NewIndex(name, meta.Indexes[name])
+		if opts, exists := meta.IndexOptions[name]; exists && opts.Validate() == nil {
+			fresh.Type, fresh.Unique, fresh.TTL = opts.Type, opts.Unique, opts.TTL
+		}
+		for _, doc := range docs {
+			fresh.AddToIndex(doc)
+		}
+
+		first := len(fc.report.Issues)
+		idx, err := LoadIndexFromDisk(fc.root, fc.dbName, fc.name, name)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			fc.issue(path, "", "index '%s' is missing", name)
+		case err != nil:
+			fc.issue(path, "", "%v", err)
+		case idx.stale:
+			continue // older format, rebuilt from the documents on load
+		default:
+			compareIndex(fc, path, idx, fresh, docs, unreadable)
+		}
+
+		if repair && len(fc.report.Issues) > first {
+			if err := fresh.SaveToDisk(fc.root, fc.dbName, fc.name); err != nil {
+				return fmt.Errorf("failed to rebuild index %s: %w", name, err)
+			}
+			for i := first; i < len(fc.report.Issues); i++ {
+				fc.report.Issues[i].Repaired = true
+			}
+		}
+	}
+
+	// Left behind by an index that was dropped
+	entries, err := os.ReadDir(indexDir)
+	if err != nil && !os.IsNotExist(err) {
+		fc.issue(indexDir, "", "failed to read index directory: %v", err)
+	}
+	for _, entry := range entries {
+		name, isIndex := strings.CutSuffix(entry.Name(), ".json")
+		if _, defined := meta.Indexes[name]; isIndex && !entry.IsDir() && !defined {
+			fc.issue(filepath.Join(indexDir, entry.Name()), "", "index '%s' is not defined in the collection metadata", name)
+		}
+	}
+	return nil
+}
+
+// compareIndex reports every entry of a stored index that is not in the
+// index built from the documents, and the other way around
+func compareIndex(fc *fsckCollection, path string, stored, fresh *Index, docs map[string]*Document, unreadable map[string]bool) {
+	var issues []FsckIssue
+	add := func(docID, format string, args ...any) {
+		issues = append(issues, FsckIssue{DocumentID: docID, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	for key, ids := range stored.Data {
+		for id := range ids {
+			if unreadable[id] {
+				continue // already reported
+			}
+			if docs[id] == nil {
+				add(id, "index '%s' references a document that does not exist", stored.Name)
+			} else if _, ok := fresh.Data[key][id]; !ok {
+				add(id, "index '%s' lists the document under value '%s', which it no longer has", stored.Name, key)
+			}
+		}
+	}
+	for key, ids := range fresh.Data {
+		for id := range ids {
+			if _, ok := stored.Data[key][id]; !ok {
+				add(id, "document is missing from index '%s'", stored.Name)
+			}
+		}
+	}
+
+	sort.Slice(issues, func(i, j int) bool {
+		if issues[i].DocumentID != issues[j].DocumentID {
+			return issues[i].DocumentID < issues[j].DocumentID
+		}
+		return issues[i].Problem < issues[j].Problem
+	})
+	for _, issue := range issues {
+		fc.issue(path, issue.DocumentID, "%s", issue.Problem)
+	}
+}
+
+// fsckSegments reads every record of a segmented collection's segments,
+// including ones older than the newest base segment that are left over
+func fsckSegments(fc *fsckCollection) {
+	numbers, err := listSegments(fc.dir)
+	if err != nil {
+		fc.issue(fc.dir, "", "failed to list segments: %v", err)
+		return
+	}
+
+	failed := false
+	for i, number := range numbers {
+		path := segmentPath(fc.dir, number)
+		if _, _, err := readSegment(path, make(map[string]*Document), i == len(numbers)-1); err != nil {
+			fc.issue(path, "", "%v", err)
+			failed = true
+		}
+	}
+	if failed {
+		return
+	}
+
+	// Counted the way they are loaded
+	docs, err := loadSegments(fc.dir, &segmentState{})
+	if err != nil {
+		fc.issue(fc.dir, "", "%v", err)
+		return
+	}
+	fc.report.Documents += len(docs)
+}
+
+// fsckWAL reads every WAL file through, checking each entry's checksum
+func (sm *StorageManager) fsckWAL(report *FsckReport) {
+	sm.WAL.mu.RLock()
+	files, err := sm.WAL.getWALFilesLocked()
+	sm.WAL.mu.RUnlock()
+	if err != nil {
+		report.Issues = append(report.Issues, FsckIssue{File: ".", Problem: fmt.Sprintf("failed to list WAL files: %v", err)})
+		return
+	}
+
+	for _, name := range files {
+		report.WALFiles++
+		entries, err := sm.WAL.readWALFile(filepath.Join(sm.WAL.rootDir, name), 0)
+		var tail *walTornTail
+		if errors.As(err, &tail) {
+			report.Issues = append(report.Issues, FsckIssue{File: name, Problem: fmt.Sprintf("torn entry at byte %d, left by a crash mid-append; it is dropped when the WAL is next replayed: %v", tail.Size, tail.Err)})
+			report.WALEntries += len(entries)
+			continue
+		}
+		if err != nil {
+			report.Issues = append(report.Issues, FsckIssue{File: name, Problem: fmt.Sprintf("unreadable WAL entry: %v", err)})
+			continue
+		}
+		report.WALEntries += len(entries)
+	}
+}
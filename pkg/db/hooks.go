@@ -0,0 +1,130 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrVetoed is matched (with errors.Is) by every *HookError
+var ErrVetoed = errors.New("vetoed by hook")
+
+// HookError reports a write that a hook of the collection refused
+type HookError struct {
+	DocumentID string
+	Operation  string // insert, update or delete
+	Err        error  // returned by the hook
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("%s of document '%s' vetoed by hook: %v", e.Operation, e.DocumentID, e.Err)
+}
+
+func (e *HookError) Is(target error) bool {
+	return target == ErrVetoed
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// HookEvent is a write to a document, as passed to the hooks of its collection
+type HookEvent struct {
+	Collection string
+	Operation  string // insert, update or delete
+	DocumentID string
+	// Old is the document before the write; nil for inserts
+	Old *Document
+	// New is the document after the write; nil for deletes. Before hooks
+	// may modify it, and the write stores it as they leave it.
+	New *Document
+}
+
+// Hook is called with a write to a document of the collection it is
+// registered on. It runs while the collection's write lock is held, so it
+// must not read or write the same collection. Returning an error vetoes the
+// write: a before hook prevents it, and an after hook undoes it.
+type Hook func(event *HookEvent) error
+
+// collectionHooks holds the hooks registered on a collection by operation
+type collectionHooks struct {
+	before map[string][]Hook
+	after  map[string][]Hook
+}
+
+// OnBeforeInsert registers a hook called before a document is inserted,
+// with the document in New. It may set fields or veto the insert; the
+// schema and unique indexes are checked after it.
+func (c *Collection) OnBeforeInsert(hook Hook) {
+	c.addHook(true, TxInsert, hook)
+}
+
+// OnAfterInsert registers a hook called once a document is inserted
+func (c *Collection) OnAfterInsert(hook Hook) {
+	c.addHook(false, TxInsert, hook)
+}
+
+// OnBeforeUpdate registers a hook called before a document is updated,
+// with the stored document in Old and the updated one in New. It may
+// change New or veto the update; the schema and unique indexes are checked
+// after it.
+func (c *Collection) OnBeforeUpdate(hook Hook) {
+	c.addHook(true, TxUpdate, hook)
+}
+
+// OnAfterUpdate registers a hook called once a document is updated
+func (c *Collection) OnAfterUpdate(hook Hook) {
+	c.addHook(false, TxUpdate, hook)
+}
+
+// OnBeforeDelete registers a hook called before a document is deleted,
+// with the document in Old
+func (c *Collection) OnBeforeDelete(hook Hook) {
+	c.addHook(true, TxDelete, hook)
+}
+
+// OnAfterDelete registers a hook called once a document is deleted
+func (c *Collection) OnAfterDelete(hook Hook) {
+	c.addHook(false, TxDelete, hook)
+}
+
+func (c *Collection) addHook(before bool, op string, hook Hook) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	hooks := &c.hooks.after
+	if before {
+		hooks = &c.hooks.before
+	}
+	if *hooks == nil {
+		*hooks = make(map[string][]Hook)
+	}
+	(*hooks)[op] = append((*hooks)[op], hook)
+}
+
+// runHooksLocked calls the before or after hooks of an operation in the
+// order they were registered, stopping at the first that vetoes it (caller
+// must hold mu). Hooks get copies of the stored documents, so only before
+// hooks can change what is stored, through New.
+func (c *Collection) runHooksLocked(before bool, op, id string, oldDoc, newDoc *Document) error {
+	hooks := c.hooks.after[op]
+	if before {
+		hooks = c.hooks.before[op]
+	}
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	event := &HookEvent{Collection: c.Name, Operation: op, DocumentID: id, New: newDoc}
+	if oldDoc != nil {
+		event.Old = oldDoc.DeepClone()
+	}
+	if newDoc != nil && !before {
+		event.New = newDoc.DeepClone()
+	}
+	for _, hook := range hooks {
+		if err := hook(event); err != nil {
+			return &HookError{DocumentID: id, Operation: op, Err: err}
+		}
+	}
+	return nil
+}
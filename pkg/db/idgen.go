@@ -0,0 +1,220 @@
+package db
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Built-in document ID strategies
+const (
+	IDStrategyUUID      = "uuid"      // random UUIDv4 (default)
+	IDStrategyUUIDv7    = "uuidv7"    // time-ordered UUIDv7
+	IDStrategyULID      = "ulid"      // time-ordered ULID, monotonic within a millisecond
+	IDStrategySnowflake = "snowflake" // 64-bit Twitter-style snowflake as a decimal string
+)
+
+// IDGenerator produces a new document ID
+type IDGenerator func() (string, error)
+
+// IDGeneratorRegistry holds the ID strategies available to collections
+type IDGeneratorRegistry struct {
+	generators map[string]IDGenerator
+	mu         sync.RWMutex
+}
+
+var idGenerators = &IDGeneratorRegistry{
+	generators: map[string]IDGenerator{
+		IDStrategyUUID: func() (string, error) {
+			return uuid.New().String(), nil
+		},
+		IDStrategyUUIDv7: func() (string, error) {
+			id, err := uuid.NewV7()
+			if err != nil {
+				return "", err
+			}
+			return id.String(), nil
+		},
+		IDStrategyULID:      newULID,
+		IDStrategySnowflake: newSnowflake,
+	},
+}
+
+// RegisterIDGenerator registers a custom ID strategy (e.g. one that draws IDs from
+// an external system). Collections select it by name with SetIDStrategy.
+// Registering an existing name replaces that strategy.
+func RegisterIDGenerator(name string, generator IDGenerator) {
+	idGenerators.mu.Lock()
+	defer idGenerators.mu.Unlock()
+	idGenerators.generators[name] = generator
+}
+
+// GetIDGenerator retrieves an ID strategy by name
+func GetIDGenerator(name string) (IDGenerator, bool) {
+	idGenerators.mu.RLock()
+	defer idGenerators.mu.RUnlock()
+	generator, exists := idGenerators.generators[name]
+	return generator, exists
+}
+
+// ListIDStrategies returns the names of all registered ID strategies
+func ListIDStrategies() []string {
+	idGenerators.mu.RLock()
+	defer idGenerators.mu.RUnlock()
+
+	names := make([]string, 0, len(idGenerators.generators))
+	for name := range idGenerators.generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SetIDStrategy selects how IDs are generated for documents inserted without one.
+// An empty name restores the default (random UUID).
+func (c *Collection) SetIDStrategy(name string) error {
+	if name != "" {
+		if _, exists := GetIDGenerator(name); !exists {
+			return fmt.Errorf("unknown ID strategy '%s'", name)
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.IDStrategy = name
+	return nil
+}
+
+// newIDLocked generates an ID using the collection's strategy (caller must hold mu)
+func (c *Collection) newIDLocked() (string, error) {
+	name := c.IDStrategy
+	if name == "" {
+		name = IDStrategyUUID
+	}
+
+	generator, exists := GetIDGenerator(name)
+	if !exists {
+		return "", fmt.Errorf("unknown ID strategy '%s'", name)
+	}
+
+	id, err := generator()
+	if err != nil {
+		return "", fmt.Errorf("failed to generate ID: %w", err)
+	}
+	if id == "" {
+		return "", fmt.Errorf("ID strategy '%s' returned an empty ID", name)
+	}
+	return id, nil
+}
+
+// crockford is the ULID base32 alphabet
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+var ulidState struct {
+	mu       sync.Mutex
+	lastTime int64
+	entropy  [10]byte
+}
+
+// newULID returns a ULID. IDs generated within the same millisecond
+// increment the random part so that they stay strictly ordered.
+func newULID() (string, error) {
+	ulidState.mu.Lock()
+	defer ulidState.mu.Unlock()
+
+	now := time.Now().UnixMilli()
+	if now > ulidState.lastTime {
+		ulidState.lastTime = now
+		if _, err := rand.Read(ulidState.entropy[:]); err != nil {
+			return "", err
+		}
+	} else {
+		// Same (or earlier, after a clock step back) millisecond: increment entropy
+		i := len(ulidState.entropy) - 1
+		for ; i >= 0; i-- {
+			ulidState.entropy[i]++
+			if ulidState.entropy[i] != 0 {
+				break
+			}
+		}
+		if i < 0 {
+			return "", fmt.Errorf("ULID entropy exhausted within one millisecond")
+		}
+	}
+
+	var raw [16]byte
+	ts := uint64(ulidState.lastTime)
+	for i := 5; i >= 0; i-- {
+		raw[i] = byte(ts)
+		ts >>= 8
+	}
+	copy(raw[6:], ulidState.entropy[:])
+
+	// 128 bits encode to 26 characters; the first character carries only 3 bits
+	hi := binary.BigEndian.Uint64(raw[:8])
+	lo := binary.BigEndian.Uint64(raw[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1F]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:]), nil
+}
+
+// SnowflakeEpoch is the epoch of snowflake timestamps (2020-01-01T00:00:00Z)
+var SnowflakeEpoch = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+var snowflakeState struct {
+	mu       sync.Mutex
+	node     int64
+	lastTime int64
+	sequence int64
+}
+
+// SetSnowflakeNode sets the 10-bit node ID embedded in snowflake IDs, so that
+// several instances can generate IDs without collisions
+func SetSnowflakeNode(node int64) error {
+	if node < 0 || node > 1023 {
+		return fmt.Errorf("snowflake node ID must be between 0 and 1023")
+	}
+	snowflakeState.mu.Lock()
+	defer snowflakeState.mu.Unlock()
+	snowflakeState.node = node
+	return nil
+}
+
+// newSnowflake returns a snowflake ID: 41 bits of milliseconds since
+// SnowflakeEpoch, 10 bits of node ID and a 12-bit sequence number
+func newSnowflake() (string, error) {
+	snowflakeState.mu.Lock()
+	defer snowflakeState.mu.Unlock()
+
+	now := time.Since(SnowflakeEpoch).Milliseconds()
+	if now < snowflakeState.lastTime {
+		now = snowflakeState.lastTime // never go backwards on clock adjustments
+	}
+
+	if now == snowflakeState.lastTime {
+		snowflakeState.sequence = (snowflakeState.sequence + 1) & 0xFFF
+		if snowflakeState.sequence == 0 {
+			// Sequence exhausted for this millisecond: wait for the next one
+			for now <= snowflakeState.lastTime {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Since(SnowflakeEpoch).Milliseconds()
+			}
+		}
+	} else {
+		snowflakeState.sequence = 0
+	}
+	snowflakeState.lastTime = now
+
+	id := now<<22 | snowflakeState.node<<12 | snowflakeState.sequence
+	return strconv.FormatInt(id, 10), nil
+}
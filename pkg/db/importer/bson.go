@@ -0,0 +1,337 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"time"
+)
+
+// BSON element types (https://bsonspec.org/spec.html)
+const (
+	bsonDouble     = 0x01
+	bsonString     = 0x02
+	bsonDocument   = 0x03
+	bsonArray      = 0x04
+	bsonBinary     = 0x05
+	bsonUndefined  = 0x06
+	bsonObjectID   = 0x07
+	bsonBoolean    = 0x08
+	bsonDateTime   = 0x09
+	bsonNull       = 0x0A
+	bsonRegex      = 0x0B
+	bsonDBPointer  = 0x0C
+	bsonJavaScript = 0x0D
+	bsonSymbol     = 0x0E
+	bsonCodeScope  = 0x0F
+	bsonInt32      = 0x10
+	bsonTimestamp  = 0x11
+	bsonInt64      = 0x12
+	bsonDecimal128 = 0x13
+	bsonMinKey     = 0xFF
+	bsonMaxKey     = 0x7F
+)
+
+// maxBSONDocumentSize guards against corrupt length prefixes
+const maxBSONDocumentSize = 64 * 1024 * 1024
+
+// BSONReader reads consecutive BSON documents, as written by mongodump
+type BSONReader struct {
+	r *bufio.Reader
+}
+
+// NewBSONReader creates a reader over a stream of BSON documents
+func NewBSONReader(r io.Reader) *BSONReader {
+	return &BSONReader{r: bufio.NewReader(r)}
+}
+
+// Next decodes the next document. It returns io.EOF when the stream is exhausted.
+// Values are converted to their JSON-like equivalents: ObjectIDs become hex
+// strings, dates become RFC 3339 strings and all numbers become float64.
+func (br *BSONReader) Next() (map[string]any, error) {
+	var sizeBuf [4]byte
+	if _, err := io.ReadFull(br.r, sizeBuf[:]); err != nil {
+		if err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("failed to read document length: %w", err)
+	}
+
+	size := int(binary.LittleEndian.Uint32(sizeBuf[:]))
+	if size < 5 || size > maxBSONDocumentSize {
+		return nil, fmt.Errorf("invalid BSON document length %d", size)
+	}
+
+	buf := make([]byte, size)
+	copy(buf, sizeBuf[:])
+	if _, err := io.ReadFull(br.r, buf[4:]); err != nil {
+		return nil, fmt.Errorf("truncated BSON document: %w", err)
+	}
+
+	doc, _, err := decodeDocument(buf)
+	return doc, err
+}
+
+// DecodeBSON decodes a single BSON document
+func DecodeBSON(data []byte) (map[string]any, error) {
+	doc, _, err := decodeDocument(data)
+	return doc, err
+}
+
+// decodeDocument decodes an embedded document, returning it and its encoded size
+func decodeDocument(data []byte) (map[string]any, int, error) {
+	if len(data) < 5 {
+		return nil, 0, fmt.Errorf("BSON document too short")
+	}
+	size := int(binary.LittleEndian.Uint32(data))
+	if size < 5 || size > len(data) || data[size-1] != 0 {
+		return nil, 0, fmt.Errorf("invalid BSON document length %d", size)
+	}
+
+	doc := make(map[string]any)
+	pos := 4
+	for pos < size-1 {
+		elemType := data[pos]
+		pos++
+
+		name, n, err := readCString(data[pos:size])
+		if err != nil {
+			return nil, 0, err
+		}
+		pos += n
+
+		value, n, err := decodeValue(elemType, data[pos:size-1])
+		if err != nil {
+			return nil, 0, fmt.Errorf("field '%s': %w", name, err)
+		}
+		pos += n
+
+		doc[name] = value
+	}
+
+	return doc, size, nil
+}
+
+// decodeArray decodes a BSON array (a document keyed by "0", "1", ...)
+func decodeArray(data []byte) ([]any, int, error) {
+	doc, size, err := decodeDocument(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	arr := make([]any, len(doc))
+	for i := range arr {
+		value, exists := doc[fmt.Sprint(i)]
+		if !exists {
+			return nil, 0, fmt.Errorf("array is missing index %d", i)
+		}
+		arr[i] = value
+	}
+	return arr, size, nil
+}
+
+// decodeValue decodes one element value, returning it and the bytes consumed
+func decodeValue(elemType byte, data []byte) (any, int, error) {
+	need := func(n int) error {
+		if len(data) < n {
+			return fmt.Errorf("truncated value of type 0x%02X", elemType)
+		}
+		return nil
+	}
+
+	switch elemType {
+	case bsonDouble:
+		if err := need(8); err != nil {
+			return nil, 0, err
+		}
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), 8, nil
+
+	case bsonString, bsonJavaScript, bsonSymbol:
+		return readString(data)
+
+	case bsonDocument:
+		return decodeDocument(data)
+
+	case bsonArray:
+		return decodeArray(data)
+
+	case bsonBinary:
+		if err := need(5); err != nil {
+			return nil, 0, err
+		}
+		length := int(binary.LittleEndian.Uint32(data))
+		if err := need(5 + length); err != nil {
+			return nil, 0, err
+		}
+		subtype := data[4]
+		payload := data[5 : 5+length]
+		// UUID subtypes are rendered in their canonical form
+		if (subtype == 0x03 || subtype == 0x04) && length == 16 {
+			h := hex.EncodeToString(payload)
+			return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32], 5 + length, nil
+		}
+		return base64.StdEncoding.EncodeToString(payload), 5 + length, nil
+
+	case bsonUndefined, bsonNull, bsonMinKey, bsonMaxKey:
+		return nil, 0, nil
+
+	case bsonObjectID:
+		if err := need(12); err != nil {
+			return nil, 0, err
+		}
+		return hex.EncodeToString(data[:12]), 12, nil
+
+	case bsonBoolean:
+		if err := need(1); err != nil {
+			return nil, 0, err
+		}
+		return data[0] != 0, 1, nil
+
+	case bsonDateTime:
+		if err := need(8); err != nil {
+			return nil, 0, err
+		}
+		millis := int64(binary.LittleEndian.Uint64(data))
+		return time.UnixMilli(millis).UTC().Format(time.RFC3339Nano), 8, nil
+
+	case bsonRegex:
+		pattern, n1, err := readCString(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		options, n2, err := readCString(data[n1:])
+		if err != nil {
+			return nil, 0, err
+		}
+		return "/" + pattern + "/" + options, n1 + n2, nil
+
+	case bsonDBPointer:
+		ref, n, err := readString(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(data) < n+12 {
+			return nil, 0, fmt.Errorf("truncated DBPointer")
+		}
+		return map[string]any{"$ref": ref, "$id": hex.EncodeToString(data[n : n+12])}, n + 12, nil
+
+	case bsonCodeScope:
+		if err := need(4); err != nil {
+			return nil, 0, err
+		}
+		total := int(binary.LittleEndian.Uint32(data))
+		if err := need(total); err != nil {
+			return nil, 0, err
+		}
+		code, n, err := readString(data[4:total])
+		if err != nil {
+			return nil, 0, err
+		}
+		scope, _, err := decodeDocument(data[4+n : total])
+		if err != nil {
+			return nil, 0, err
+		}
+		return map[string]any{"$code": code, "$scope": scope}, total, nil
+
+	case bsonInt32:
+		if err := need(4); err != nil {
+			return nil, 0, err
+		}
+		return float64(int32(binary.LittleEndian.Uint32(data))), 4, nil
+
+	case bsonTimestamp:
+		if err := need(8); err != nil {
+			return nil, 0, err
+		}
+		increment := binary.LittleEndian.Uint32(data)
+		seconds := binary.LittleEndian.Uint32(data[4:])
+		return map[string]any{"t": float64(seconds), "i": float64(increment)}, 8, nil
+
+	case bsonInt64:
+		if err := need(8); err != nil {
+			return nil, 0, err
+		}
+		return float64(int64(binary.LittleEndian.Uint64(data))), 8, nil
+
+	case bsonDecimal128:
+		if err := need(16); err != nil {
+			return nil, 0, err
+		}
+		low := binary.LittleEndian.Uint64(data)
+		high := binary.LittleEndian.Uint64(data[8:])
+		return decimal128String(high, low), 16, nil
+	}
+
+	return nil, 0, fmt.Errorf("unsupported BSON type 0x%02X", elemType)
+}
+
+// readCString reads a NUL-terminated string
+func readCString(data []byte) (string, int, error) {
+	for i, b := range data {
+		if b == 0 {
+			return string(data[:i]), i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated BSON cstring")
+}
+
+// readString reads a length-prefixed, NUL-terminated string
+func readString(data []byte) (string, int, error) {
+	if len(data) < 5 {
+		return "", 0, fmt.Errorf("truncated BSON string")
+	}
+	length := int(binary.LittleEndian.Uint32(data))
+	if length < 1 || 4+length > len(data) || data[4+length-1] != 0 {
+		return "", 0, fmt.Errorf("invalid BSON string length %d", length)
+	}
+	return string(data[4 : 4+length-1]), 4 + length, nil
+}
+
+// decimal128String renders an IEEE 754-2008 decimal128 value as a decimal string
+func decimal128String(high, low uint64) string {
+	negative := high>>63 == 1
+	sign := ""
+	if negative {
+		sign = "-"
+	}
+
+	var exponent int
+	coefficient := new(big.Int)
+	switch {
+	case (high>>58)&0x1F == 0x1F:
+		return "NaN"
+	case (high>>58)&0x1F == 0x1E:
+		return sign + "Infinity"
+	case (high>>61)&0x3 == 0x3:
+		// Non-canonical large-coefficient form; the value is zero by spec
+		exponent = int((high>>47)&0x3FFF) - 6176
+	default:
+		exponent = int((high>>49)&0x3FFF) - 6176
+		coefficient.SetUint64(high & 0x1FFFFFFFFFFFF)
+		coefficient.Lsh(coefficient, 64)
+		coefficient.Or(coefficient, new(big.Int).SetUint64(low))
+	}
+
+	digits := coefficient.String()
+	switch {
+	case exponent == 0:
+		return sign + digits
+	case exponent > 0:
+		return sign + digits + "E+" + fmt.Sprint(exponent)
+	}
+
+	point := len(digits) + exponent
+	if point > 0 {
+		return sign + digits[:point] + "." + digits[point:]
+	}
+	zeros := make([]byte, -point)
+	for i := range zeros {
+		zeros[i] = '0'
+	}
+	return sign + "0." + string(zeros) + digits
+}
@@ -0,0 +1,235 @@
+package importer
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// CSVOptions configures a CSV import
+type CSVOptions struct {
+	// Mapping renames CSV columns to document fields. Columns mapped to an
+	// empty string are skipped; unmapped columns keep their header name.
+	Mapping map[string]string `json:"mapping,omitempty"`
+	// Delimiter separates columns (default ',')
+	Delimiter rune `json:"-"`
+	// IDColumn names the column (after mapping) used as the document ID (default "_id")
+	IDColumn string `json:"id_column,omitempty"`
+	// StopOnError aborts the import at the first bad row instead of skipping it
+	StopOnError bool `json:"stop_on_error,omitempty"`
+	// Inserted, if set, is called with each document inserted, e.g. to log
+	// it to the WAL; an error from it aborts the import
+	Inserted func(doc *db.Document) error `json:"-"`
+}
+
+// RowError reports a CSV row or NDJSON line that could not be imported
+type RowError struct {
+	Row int    `json:"row"` // 1-based line number, including the CSV header
+	Err string `json:"error"`
+}
+
+// LoadCSVMapping reads a column mapping from a JSON file of the form {"Column": "field"}
+func LoadCSVMapping(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping file: %w", err)
+	}
+
+	var mapping map[string]string
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse mapping file: %w", err)
+	}
+	return mapping, nil
+}
+
+// ImportCSV imports rows from a CSV stream with a header line into coll.
+// Values are coerced to the collection schema's field types; for fields not
+// in the schema (or collections without one) types are inferred.
+func ImportCSV(coll *db.Collection, r io.Reader, opts *CSVOptions) (*Result, error) {
+	if opts == nil {
+		opts = &CSVOptions{}
+	}
+	idColumn := opts.IDColumn
+	if idColumn == "" {
+		idColumn = "_id"
+	}
+
+	reader := csv.NewReader(r)
+	if opts.Delimiter != 0 {
+		reader.Comma = opts.Delimiter
+	}
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	fields := make([]string, len(header))
+	for i, column := range header {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		fields[i] = column
+		if mapped, ok := opts.Mapping[column]; ok {
+			fields[i] = mapped
+		}
+	}
+
+	for i, field := range fields {
+		if field != idColumn && db.IsReservedField(field) {
+			return nil, fmt.Errorf("column '%s' maps to reserved field '%s'; map it to another name", header[i], field)
+		}
+	}
+
+	result := &Result{Collections: 1}
+	line := 1
+	for {
+		record, err := reader.Read()
+		line++
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if err != nil && !errors.As(err, &parseErr) {
+			return result, fmt.Errorf("row %d: %w", line, err)
+		}
+		if err == nil {
+			var doc *db.Document
+			if doc, err = csvRecordToDocument(coll.Schema, fields, record, idColumn); err == nil {
+				err = coll.Insert(doc)
+			}
+			if err == nil && opts.Inserted != nil {
+				if err := opts.Inserted(doc); err != nil {
+					return result, fmt.Errorf("row %d: %w", line, err)
+				}
+			}
+		}
+
+		if err != nil {
+			if opts.StopOnError {
+				return result, fmt.Errorf("row %d: %w", line, err)
+			}
+			result.Errors = append(result.Errors, RowError{Row: line, Err: err.Error()})
+			continue
+		}
+		result.Documents++
+	}
+
+	return result, nil
+}
+
+// csvRecordToDocument builds a document from one CSV record
+func csvRecordToDocument(schema *db.Schema, fields, record []string, idColumn string) (*db.Document, error) {
+	if len(record) > len(fields) {
+		return nil, fmt.Errorf("row has %d columns, header has %d", len(record), len(fields))
+	}
+
+	doc := &db.Document{Data: make(map[string]any)}
+	for i, raw := range record {
+		field := fields[i]
+		if field == "" {
+			continue
+		}
+		if field == idColumn {
+			doc.ID = raw
+			continue
+		}
+		if raw == "" {
+			continue // empty cells are treated as missing values
+		}
+
+		var value any
+		var err error
+		if schemaField, ok := schemaFieldFor(schema, field); ok {
+			value, err = CoerceValue(raw, schemaField.Type)
+		} else {
+			value = InferValue(raw)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("column '%s': %w", field, err)
+		}
+		doc.Data[field] = value
+	}
+
+	return doc, nil
+}
+
+func schemaFieldFor(schema *db.Schema, name string) (db.Field, bool) {
+	if schema == nil {
+		return db.Field{}, false
+	}
+	field, ok := schema.Fields[name]
+	return field, ok
+}
+
+// CoerceValue converts a text cell to the given field type
+func CoerceValue(raw string, fieldType db.FieldType) (any, error) {
+	switch fieldType {
+	case db.TypeString:
+		return raw, nil
+	case db.TypeNumber:
+		f, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a number", raw)
+		}
+		return f, nil
+	case db.TypeBoolean:
+		switch strings.ToLower(strings.TrimSpace(raw)) {
+		case "true", "t", "yes", "y", "1":
+			return true, nil
+		case "false", "f", "no", "n", "0":
+			return false, nil
+		}
+		return nil, fmt.Errorf("'%s' is not a boolean", raw)
+	case db.TypeDate:
+		value := strings.TrimSpace(raw)
+		for _, layout := range []string{time.RFC3339Nano, time.DateTime, time.DateOnly} {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t.UTC().Format(time.RFC3339Nano), nil
+			}
+		}
+		return nil, fmt.Errorf("'%s' is not a date", raw)
+	case db.TypeObject, db.TypeArray:
+		var value any
+		if err := json.Unmarshal([]byte(raw), &value); err != nil || !db.ValidateType(value, fieldType) {
+			return nil, fmt.Errorf("'%s' is not a JSON %s", raw, fieldType)
+		}
+		return value, nil
+	}
+	return nil, fmt.Errorf("unknown field type '%s'", fieldType)
+}
+
+// InferValue guesses the type of a text cell: booleans, numbers, and JSON
+// objects/arrays are decoded; everything else stays a string
+func InferValue(raw string) any {
+	trimmed := strings.TrimSpace(raw)
+	switch strings.ToLower(trimmed) {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+
+	if f, err := strconv.ParseFloat(trimmed, 64); err == nil && !strings.ContainsAny(trimmed, "xXnN_") {
+		// Keep values with leading zeros (zip codes, phone numbers) as strings
+		if !(len(trimmed) > 1 && trimmed[0] == '0' && trimmed[1] != '.') {
+			return f
+		}
+	}
+
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var value any
+		if err := json.Unmarshal([]byte(trimmed), &value); err == nil {
+			return value
+		}
+	}
+
+	return raw
+}
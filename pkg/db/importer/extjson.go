@@ -0,0 +1,233 @@
+package importer
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+	"unicode"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// ImportMongoExport imports the output of mongoexport into coll: MongoDB
+// Extended JSON documents, one per line or as a single array (--jsonArray).
+// Both the canonical and the relaxed form are read. Typed values are
+// converted like BSONReader converts them, so ObjectIDs become hex strings
+// (and the _id the document ID), dates RFC 3339 strings, and all numbers
+// float64.
+func ImportMongoExport(coll *db.Collection, r io.Reader) (*Result, error) {
+	reader := bufio.NewReader(r)
+	decoder := json.NewDecoder(reader)
+
+	// mongoexport --jsonArray wraps the documents in one array
+	array := false
+	for {
+		c, _, err := reader.ReadRune()
+		if err == io.EOF {
+			return &Result{Collections: 1}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if c == '\ufeff' || unicode.IsSpace(c) {
+			continue
+		}
+		reader.UnreadRune()
+		array = c == '['
+		break
+	}
+	if array {
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{Collections: 1}
+	dropped := make(map[string]bool)
+	for n := 1; ; n++ {
+		if array && !decoder.More() {
+			break
+		}
+		var raw map[string]any
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF && !array {
+				break
+			}
+			return result, fmt.Errorf("document %d: %w", n, err)
+		}
+
+		for k, v := range raw {
+			value, err := convertExtJSON(v)
+			if err != nil {
+				return result, fmt.Errorf("document %d: field '%s': %w", n, k, err)
+			}
+			raw[k] = value
+		}
+		insertMongoDocument(coll, raw, dropped, result)
+	}
+
+	warnDroppedFields(coll.Name, dropped, result)
+	return result, nil
+}
+
+// convertExtJSON replaces the Extended JSON type wrappers ({"$oid": ...},
+// {"$date": ...}, {"$numberLong": ...}, ...) in a decoded value
+func convertExtJSON(value any) (any, error) {
+	switch v := value.(type) {
+	case []any:
+		for i, item := range v {
+			converted, err := convertExtJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = converted
+		}
+		return v, nil
+	case map[string]any:
+		if converted, ok, err := convertExtJSONWrapper(v); ok || err != nil {
+			return converted, err
+		}
+		for k, item := range v {
+			converted, err := convertExtJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = converted
+		}
+		return v, nil
+	}
+	return value, nil
+}
+
+// convertExtJSONWrapper converts an object that is an Extended JSON type
+// wrapper; ok is false for ordinary objects
+func convertExtJSONWrapper(obj map[string]any) (value any, ok bool, err error) {
+	if len(obj) == 0 || len(obj) > 2 {
+		return nil, false, nil
+	}
+
+	if len(obj) == 1 {
+		for key, inner := range obj {
+			switch key {
+			case "$oid", "$symbol", "$numberDecimal", "$uuid":
+				s, isString := inner.(string)
+				if !isString {
+					return nil, true, fmt.Errorf("%s must be a string", key)
+				}
+				return s, true, nil
+			case "$numberInt", "$numberLong", "$numberDouble":
+				return extJSONNumber(key, inner)
+			case "$date":
+				return extJSONDate(inner)
+			case "$binary":
+				// Canonical form: {"$binary": {"base64": ..., "subType": ...}}
+				binary, isObject := inner.(map[string]any)
+				if !isObject {
+					return nil, true, fmt.Errorf("$binary must be an object")
+				}
+				data, _ := binary["base64"].(string)
+				subType, _ := binary["subType"].(string)
+				return extJSONBinary(data, subType)
+			case "$regularExpression":
+				regex, _ := inner.(map[string]any)
+				pattern, _ := regex["pattern"].(string)
+				options, _ := regex["options"].(string)
+				return "/" + pattern + "/" + options, true, nil
+			case "$timestamp":
+				ts, _ := inner.(map[string]any)
+				return map[string]any{"t": ts["t"], "i": ts["i"]}, true, nil
+			case "$code":
+				return inner, true, nil
+			case "$minKey", "$maxKey", "$undefined":
+				return nil, true, nil
+			case "$dbPointer":
+				pointer, _ := inner.(map[string]any)
+				id, err := convertExtJSON(pointer["$id"])
+				return map[string]any{"$ref": pointer["$ref"], "$id": id}, true, err
+			}
+		}
+		return nil, false, nil
+	}
+
+	// Legacy two-member wrappers
+	if data, isString := obj["$binary"].(string); isString {
+		if subType, isString := obj["$type"].(string); isString {
+			return extJSONBinary(data, subType)
+		}
+	}
+	if pattern, isString := obj["$regex"].(string); isString {
+		if options, isString := obj["$options"].(string); isString {
+			return "/" + pattern + "/" + options, true, nil
+		}
+	}
+	if code, isString := obj["$code"].(string); isString {
+		if scope, exists := obj["$scope"]; exists {
+			converted, err := convertExtJSON(scope)
+			return map[string]any{"$code": code, "$scope": converted}, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// extJSONNumber converts a {"$numberInt"/"$numberLong"/"$numberDouble": "..."} wrapper
+func extJSONNumber(key string, inner any) (any, bool, error) {
+	s, isString := inner.(string)
+	if !isString {
+		return nil, true, fmt.Errorf("%s must be a string", key)
+	}
+	switch s {
+	case "Infinity", "-Infinity", "NaN":
+		return s, true, nil // not representable in JSON
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, true, fmt.Errorf("%s '%s' is not a number", key, s)
+	}
+	return f, true, nil
+}
+
+// extJSONDate converts the value of a $date wrapper: an ISO-8601 string
+// (relaxed form) or milliseconds since the epoch (canonical form)
+func extJSONDate(inner any) (any, bool, error) {
+	var millis int64
+	switch v := inner.(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.999Z0700"} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t.UTC().Format(time.RFC3339Nano), true, nil
+			}
+		}
+		return nil, true, fmt.Errorf("$date '%s' is not a date", v)
+	case float64:
+		millis = int64(v)
+	case map[string]any:
+		s, _ := v["$numberLong"].(string)
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, true, fmt.Errorf("$date has an invalid $numberLong '%s'", s)
+		}
+		millis = n
+	default:
+		return nil, true, fmt.Errorf("$date has an unsupported value")
+	}
+	return time.UnixMilli(millis).UTC().Format(time.RFC3339Nano), true, nil
+}
+
+// extJSONBinary converts base64 binary data; UUID subtypes are rendered in
+// their canonical form like BSONReader does
+func extJSONBinary(data, subType string) (any, bool, error) {
+	payload, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return nil, true, fmt.Errorf("$binary is not valid base64")
+	}
+	if (subType == "03" || subType == "04" || subType == "3" || subType == "4") && len(payload) == 16 {
+		h := hex.EncodeToString(payload)
+		return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:32], true, nil
+	}
+	return data, true, nil
+}
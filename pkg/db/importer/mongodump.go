@@ -0,0 +1,267 @@
+// Package importer loads data from other databases and interchange formats into CachyDB.
+package importer
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// Result summarizes an import
+type Result struct {
+	Collections int        `json:"collections"`
+	Documents   int        `json:"documents"`
+	Indexes     int        `json:"indexes"`
+	Warnings    []string   `json:"warnings,omitempty"`
+	Errors      []RowError `json:"errors,omitempty"`
+}
+
+func (r *Result) warnf(format string, args ...any) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// mongoMetadata is the content of a mongodump <collection>.metadata.json file
+type mongoMetadata struct {
+	CollectionName string `json:"collectionName"`
+	Indexes        []struct {
+		Name   string          `json:"name"`
+		Key    json.RawMessage `json:"key"`
+		Unique bool            `json:"unique"`
+	} `json:"indexes"`
+}
+
+// ImportMongoDump imports every collection found in a mongodump database directory
+// (the directory containing <collection>.bson and <collection>.metadata.json files)
+// into database. Gzipped dumps (mongodump --gzip) are supported.
+func ImportMongoDump(database *db.Database, dir string) (*Result, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dump directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		if strings.HasSuffix(name, ".bson") || strings.HasSuffix(name, ".bson.gz") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	result := &Result{}
+	for _, name := range names {
+		collName := strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".bson")
+		if strings.HasPrefix(collName, "system.") {
+			continue
+		}
+
+		if err := importMongoCollection(database, dir, collName, name, result); err != nil {
+			return result, fmt.Errorf("failed to import collection '%s': %w", collName, err)
+		}
+	}
+
+	return result, nil
+}
+
+// ImportBSONFile imports a single mongodump .bson (or .bson.gz) file into the named collection,
+// applying index definitions from the sibling .metadata.json file when present
+func ImportBSONFile(database *db.Database, collName, path string) (*Result, error) {
+	result := &Result{}
+	if err := importMongoCollection(database, filepath.Dir(path), collName, filepath.Base(path), result); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// importMongoCollection imports one collection's documents and indexes
+func importMongoCollection(database *db.Database, dir, collName, bsonName string, result *Result) error {
+	coll, err := database.GetCollection(collName)
+	if err != nil {
+		if err := database.CreateCollection(collName, nil); err != nil {
+			return err
+		}
+		if coll, err = database.GetCollection(collName); err != nil {
+			return err
+		}
+	}
+	result.Collections++
+
+	f, err := os.Open(filepath.Join(dir, bsonName))
+	if err != nil {
+		return fmt.Errorf("failed to open BSON file: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if strings.HasSuffix(bsonName, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("failed to open gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	dropped := make(map[string]bool)
+	reader := NewBSONReader(r)
+	for {
+		raw, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("document %d: %w", result.Documents+1, err)
+		}
+
+		insertMongoDocument(coll, raw, dropped, result)
+	}
+
+	warnDroppedFields(collName, dropped, result)
+	return importMongoIndexes(coll, dir, strings.TrimSuffix(strings.TrimSuffix(bsonName, ".gz"), ".bson"), result)
+}
+
+// insertMongoDocument inserts a decoded MongoDB document into coll, dropping
+// reserved fields (recorded in dropped); a document that cannot be inserted
+// is reported as a warning
+func insertMongoDocument(coll *db.Collection, raw map[string]any, dropped map[string]bool, result *Result) {
+	doc := MongoDocument(raw)
+	for _, field := range db.SanitizeUserFields(doc.Data) {
+		dropped[field] = true
+	}
+	if err := coll.Insert(doc); err != nil {
+		result.warnf("%s: skipped document '%s': %v", coll.Name, doc.ID, err)
+		return
+	}
+	result.Documents++
+}
+
+// warnDroppedFields reports the reserved fields dropped from a collection's documents
+func warnDroppedFields(collName string, dropped map[string]bool, result *Result) {
+	if len(dropped) == 0 {
+		return
+	}
+	fields := make([]string, 0, len(dropped))
+	for field := range dropped {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	result.warnf("%s: dropped reserved field(s) %s (names starting with '%s' are reserved)",
+		collName, strings.Join(fields, ", "), db.ReservedFieldPrefix)
+}
+
+// importMongoIndexes recreates single-field indexes from a metadata file
+func importMongoIndexes(coll *db.Collection, dir, baseName string, result *Result) error {
+	data, err := os.ReadFile(filepath.Join(dir, baseName+".metadata.json"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			data, err = readGzipFile(filepath.Join(dir, baseName+".metadata.json.gz"))
+			if os.IsNotExist(err) {
+				return nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read metadata: %w", err)
+		}
+	}
+
+	var meta mongoMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	for _, index := range meta.Indexes {
+		fields, err := orderedKeys(index.Key)
+		if err != nil {
+			result.warnf("%s: skipped index '%s': %v", coll.Name, index.Name, err)
+			continue
+		}
+		if len(fields) == 1 && fields[0] == "_id" {
+			continue // _id is always indexed
+		}
+		if len(fields) != 1 {
+			result.warnf("%s: skipped compound index '%s' (only single-field indexes are supported)", coll.Name, index.Name)
+			continue
+		}
+
+		if err := coll.CreateIndex(index.Name, fields[0]); err != nil {
+			result.warnf("%s: skipped index '%s': %v", coll.Name, index.Name, err)
+			continue
+		}
+		result.Indexes++
+	}
+
+	return nil
+}
+
+// MongoDocument converts a decoded MongoDB document into a CachyDB document,
+// turning its _id (ObjectID or otherwise) into the string document ID
+func MongoDocument(raw map[string]any) *db.Document {
+	doc := &db.Document{Data: raw}
+	if id, exists := raw["_id"]; exists {
+		switch v := id.(type) {
+		case string:
+			doc.ID = v
+		case float64:
+			doc.ID = fmt.Sprintf("%v", v)
+		default:
+			encoded, _ := json.Marshal(v)
+			doc.ID = string(encoded)
+		}
+		delete(raw, "_id")
+	}
+	return doc
+}
+
+// orderedKeys returns the keys of a JSON object in document order
+func orderedKeys(raw json.RawMessage) ([]string, error) {
+	decoder := json.NewDecoder(strings.NewReader(string(raw)))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, fmt.Errorf("index key is not an object")
+	}
+
+	var keys []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, _ := token.(string)
+		keys = append(keys, key)
+
+		var skip json.RawMessage
+		if err := decoder.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+func readGzipFile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	return io.ReadAll(gz)
+}
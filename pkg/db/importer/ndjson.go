@@ -0,0 +1,65 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/hop-/cachydb/pkg/db"
+)
+
+// NDJSONOptions configures an NDJSON import
+type NDJSONOptions struct {
+	// StopOnError aborts the import at the first bad line instead of skipping it
+	StopOnError bool `json:"stop_on_error,omitempty"`
+	// Inserted, if set, is called with each document inserted, e.g. to log
+	// it to the WAL; an error from it aborts the import
+	Inserted func(doc *db.Document) error `json:"-"`
+}
+
+// ImportNDJSON imports newline-delimited JSON objects from r into coll,
+// reading one line at a time so the input is never held in memory as a
+// whole. The "_id" member, when it is a string, becomes the document ID;
+// blank lines are skipped.
+func ImportNDJSON(coll *db.Collection, r io.Reader, opts *NDJSONOptions) (*Result, error) {
+	if opts == nil {
+		opts = &NDJSONOptions{}
+	}
+
+	reader := bufio.NewReaderSize(r, 64*1024)
+	result := &Result{Collections: 1}
+	for line := 1; ; line++ {
+		data, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return result, fmt.Errorf("line %d: %w", line, readErr)
+		}
+
+		if data = bytes.TrimSpace(data); len(data) > 0 {
+			var doc db.Document
+			err := json.Unmarshal(data, &doc)
+			if err == nil {
+				err = coll.Insert(&doc)
+			}
+			if err == nil && opts.Inserted != nil {
+				if err := opts.Inserted(&doc); err != nil {
+					return result, fmt.Errorf("line %d: %w", line, err)
+				}
+			}
+
+			if err != nil {
+				if opts.StopOnError {
+					return result, fmt.Errorf("line %d: %w", line, err)
+				}
+				result.Errors = append(result.Errors, RowError{Row: line, Err: err.Error()})
+			} else {
+				result.Documents++
+			}
+		}
+
+		if readErr == io.EOF {
+			return result, nil
+		}
+	}
+}
@@ -1,12 +1,95 @@
 package db
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 )
 
+// IndexFormatVersion is the version of index files written by this build.
+// Version 1 files (no version field) held a single document ID per value and
+// are rebuilt from the documents on load.
+const IndexFormatVersion = 2
+
+// IndexType selects how an index stores its values
+type IndexType string
+
+const (
+	// IndexHash answers equality lookups (eq, in)
+	IndexHash IndexType = "hash"
+	// IndexOrdered also keeps values sorted, so range filters (gt, gte, lt,
+	// lte) and sorting on the field can use it
+	IndexOrdered IndexType = "ordered"
+)
+
+// IndexOptions configures a new index
+type IndexOptions struct {
+	Type IndexType `json:"type,omitempty"` // defaults to IndexHash
+	// Unique rejects documents whose value is already indexed for another
+	// document. Documents without the field are not constrained.
+	Unique bool `json:"unique,omitempty"`
+	// TTL makes the index a TTL index: documents expire TTL after the time
+	// in the indexed field (see CreateTTLIndex)
+	TTL time.Duration `json:"ttl,omitempty"`
+}
+
+// ErrDuplicateKey is matched (with errors.Is) by every *DuplicateKeyError
+var ErrDuplicateKey = errors.New("duplicate key")
+
+// DuplicateKeyError reports a write that would give two documents the same
+// value in a unique index
+type DuplicateKeyError struct {
+	Index      string
+	Field      string
+	Value      any
+	DocumentID string // the document that already has the value
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("duplicate key: unique index '%s' already has %s = %v (document '%s')", e.Index, e.Field, e.Value, e.DocumentID)
+}
+
+func (e *DuplicateKeyError) Is(target error) bool {
+	return target == ErrDuplicateKey
+}
+
+// Validate checks the options and fills in defaults
+func (o *IndexOptions) Validate() error {
+	switch o.Type {
+	case "":
+		o.Type = IndexHash
+	case IndexHash, IndexOrdered:
+	default:
+		return fmt.Errorf("unknown index type '%s' (expected %s or %s)", o.Type, IndexHash, IndexOrdered)
+	}
+	if o.TTL < 0 {
+		return fmt.Errorf("index TTL must not be negative")
+	}
+	return nil
+}
+
+// orderedEntry is one value of an ordered index
+type orderedEntry struct {
+	value any
+	docID string
+}
+
+// compareEntries orders entries by value, using the sort order of query
+// results, then by document ID
+func compareEntries(a, b orderedEntry) int {
+	if cmp := compareSortValues(a.value, b.value, nil); cmp != 0 {
+		return cmp
+	}
+	return strings.Compare(a.docID, b.docID)
+}
+
 // AddToIndex adds a document to an index
 func (idx *Index) AddToIndex(doc *Document) error {
 	idx.mu.Lock()
@@ -19,7 +102,25 @@ func (idx *Index) AddToIndex(doc *Document) error {
 
 	// Convert value to string for hash-based indexing
 	key := fmt.Sprintf("%v", value)
-	idx.Data[key] = doc.ID
+	ids, exists := idx.Data[key]
+	if !exists {
+		ids = make(map[string]struct{})
+		idx.Data[key] = ids
+	}
+	ids[doc.ID] = struct{}{}
+
+	if idx.Type == IndexOrdered {
+		entry := orderedEntry{value: value, docID: doc.ID}
+		i := sort.Search(len(idx.entries), func(i int) bool {
+			return compareEntries(idx.entries[i], entry) >= 0
+		})
+		if i < len(idx.entries) && compareEntries(idx.entries[i], entry) == 0 {
+			return nil
+		}
+		idx.entries = append(idx.entries, orderedEntry{})
+		copy(idx.entries[i+1:], idx.entries[i:])
+		idx.entries[i] = entry
+	}
 
 	return nil
 }
@@ -35,23 +136,171 @@ func (idx *Index) RemoveFromIndex(doc *Document) error {
 	}
 
 	key := fmt.Sprintf("%v", value)
-	delete(idx.Data, key)
+	if ids, exists := idx.Data[key]; exists {
+		delete(ids, doc.ID)
+		if len(ids) == 0 {
+			delete(idx.Data, key)
+		}
+	}
+
+	if idx.Type == IndexOrdered {
+		entry := orderedEntry{value: value, docID: doc.ID}
+		i := sort.Search(len(idx.entries), func(i int) bool {
+			return compareEntries(idx.entries[i], entry) >= 0
+		})
+		if i < len(idx.entries) && idx.entries[i].docID == doc.ID {
+			idx.entries = append(idx.entries[:i], idx.entries[i+1:]...)
+		}
+	}
 
 	return nil
 }
 
-// Find finds a document ID by indexed field value
-func (idx *Index) Find(value any) (string, bool) {
+// Find returns the sorted IDs of the documents with an indexed field value,
+// or nil if there are none
+func (idx *Index) Find(value any) []string {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
 	key := fmt.Sprintf("%v", value)
-	docID, exists := idx.Data[key]
-	return docID, exists
+	return sortedIDs(idx.Data[key])
 }
 
-// CreateIndex creates a new index on a collection
+func sortedIDs(set map[string]struct{}) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Range returns the IDs of the documents whose indexed value compares to
+// value as op (gt, gte, lt or lte) says, in ascending order of the value.
+// Values of a different type than value never match. ok is false if the
+// index is not ordered or op is not a range operator.
+func (idx *Index) Range(op string, value any) (ids []string, ok bool) {
+	if idx.Type != IndexOrdered {
+		return nil, false
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	entries := idx.entries
+	rank := sortRank(value)
+	start := sort.Search(len(entries), func(i int) bool { return sortRank(entries[i].value) >= rank })
+	end := sort.Search(len(entries), func(i int) bool { return sortRank(entries[i].value) > rank })
+	from := func(pred func(cmp int) bool) int {
+		return sort.Search(len(entries), func(i int) bool {
+			return pred(compareSortValues(entries[i].value, value, nil))
+		})
+	}
+
+	switch op {
+	case "gt":
+		start = from(func(cmp int) bool { return cmp > 0 })
+	case "gte":
+		start = from(func(cmp int) bool { return cmp >= 0 })
+	case "lt":
+		end = from(func(cmp int) bool { return cmp >= 0 })
+	case "lte":
+		end = from(func(cmp int) bool { return cmp > 0 })
+	default:
+		return nil, false
+	}
+
+	for i := start; i < end; i++ {
+		ids = append(ids, entries[i].docID)
+	}
+	return ids, true
+}
+
+// orderedIDs returns the IDs of all indexed documents in ascending order of
+// their value
+func (idx *Index) orderedIDs() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	ids := make([]string, len(idx.entries))
+	for i, entry := range idx.entries {
+		ids[i] = entry.docID
+	}
+	return ids
+}
+
+// rebuild discards the index contents and indexes docs again
+func (idx *Index) rebuild(docs map[string]*Document) {
+	idx.mu.Lock()
+	idx.Data = make(map[string]map[string]struct{})
+	idx.entries = nil
+	idx.mu.Unlock()
+
+	for _, doc := range docs {
+		idx.AddToIndex(doc)
+	}
+}
+
+// Options returns the options the index was created with
+func (idx *Index) Options() IndexOptions {
+	return IndexOptions{Type: idx.Type, Unique: idx.Unique, TTL: idx.TTL}
+}
+
+// checkUnique returns a *DuplicateKeyError if the index is unique and
+// another document already has doc's value
+func (idx *Index) checkUnique(doc *Document) error {
+	if !idx.Unique {
+		return nil
+	}
+	value, exists := doc.GetValue(idx.FieldName)
+	if !exists {
+		return nil
+	}
+
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	for _, id := range sortedIDs(idx.Data[fmt.Sprintf("%v", value)]) {
+		if id != doc.ID {
+			return &DuplicateKeyError{Index: idx.Name, Field: idx.FieldName, Value: value, DocumentID: id}
+		}
+	}
+	return nil
+}
+
+// checkUniqueLocked checks doc against every unique index (caller must hold mu)
+func (c *Collection) checkUniqueLocked(doc *Document) error {
+	for _, idx := range c.Indexes {
+		if err := idx.checkUnique(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateIndex creates a new hash index on a collection
 func (c *Collection) CreateIndex(indexName, fieldName string) error {
+	return c.CreateIndexWithOptions(indexName, fieldName, IndexOptions{})
+}
+
+// CreateIndexWithOptions creates a new index of the given type on a
+// collection. Creating a unique index fails with a *DuplicateKeyError if
+// existing documents share a value.
+func (c *Collection) CreateIndexWithOptions(indexName, fieldName string, opts IndexOptions) error {
+	return c.CreateIndexWithProgress(context.Background(), indexName, fieldName, opts, nil)
+}
+
+// CreateIndexWithProgress is CreateIndexWithOptions reporting the existing
+// documents added to the index to progress. If ctx is canceled it stops
+// with ctx.Err() without creating the index.
+func (c *Collection) CreateIndexWithProgress(ctx context.Context, indexName, fieldName string, opts IndexOptions, progress ProgressFunc) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -60,12 +309,30 @@ func (c *Collection) CreateIndex(indexName, fieldName string) error {
 	}
 
 	idx := NewIndex(indexName, fieldName)
+	idx.Type = opts.Type
+	idx.Unique = opts.Unique
+	idx.TTL = opts.TTL
 
 	// Build index from existing documents
+	total := int64(len(c.Documents))
+	var done int64
+	progress.Report(done, total)
 	for _, doc := range c.Documents {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		doc, err := c.loadLocked(doc)
+		if err != nil {
+			return err
+		}
+		if err := idx.checkUnique(doc); err != nil {
+			return err
+		}
 		if err := idx.AddToIndex(doc); err != nil {
 			return fmt.Errorf("failed to add document to index: %w", err)
 		}
+		done++
+		progress.Report(done, total)
 	}
 
 	c.Indexes[indexName] = idx
@@ -80,6 +347,9 @@ func (c *Collection) DropIndex(indexName string) error {
 	if indexName == "_id" {
 		return fmt.Errorf("cannot drop the automatic _id index")
 	}
+	if fieldName, unique := c.Schema.uniqueIndexField(indexName); unique {
+		return fmt.Errorf("cannot drop index '%s': it enforces the unique field '%s' of the schema", indexName, fieldName)
+	}
 
 	if _, exists := c.Indexes[indexName]; !exists {
 		return fmt.Errorf("index '%s' does not exist", indexName)
@@ -89,6 +359,78 @@ func (c *Collection) DropIndex(indexName string) error {
 	return nil
 }
 
+// ListIndexes returns a map of index name to indexed field
+func (c *Collection) ListIndexes() map[string]string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	indexes := make(map[string]string, len(c.Indexes))
+	for name, idx := range c.Indexes {
+		indexes[name] = idx.FieldName
+	}
+	return indexes
+}
+
+// GetIndex returns an index of the collection by name
+func (c *Collection) GetIndex(name string) (*Index, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	idx, exists := c.Indexes[name]
+	return idx, exists
+}
+
+// RebuildIndexes discards the contents of every index of the collection and
+// builds them again from its documents, for index files that drifted from
+// the data after a crash. If documents violate a unique index it fails with
+// a *DuplicateKeyError and leaves the indexes unchanged.
+func (c *Collection) RebuildIndexes() error {
+	return c.RebuildIndexesWithProgress(context.Background(), nil)
+}
+
+// RebuildIndexesWithProgress is RebuildIndexes reporting the documents
+// indexed to progress. If ctx is canceled it stops with ctx.Err() and leaves
+// the indexes unchanged.
+func (c *Collection) RebuildIndexesWithProgress(ctx context.Context, progress ProgressFunc) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	rebuilt := make(map[string]*Index, len(c.Indexes))
+	for name, old := range c.Indexes {
+		idx := NewIndex(name, old.FieldName)
+		idx.Type = old.Type
+		idx.Unique = old.Unique
+		idx.TTL = old.TTL
+		rebuilt[name] = idx
+	}
+
+	total := int64(len(c.Documents))
+	var done int64
+	progress.Report(done, total)
+	for _, doc := range c.Documents {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		doc, err := c.loadLocked(doc)
+		if err != nil {
+			return err
+		}
+		for _, idx := range rebuilt {
+			if err := idx.checkUnique(doc); err != nil {
+				return err
+			}
+			if err := idx.AddToIndex(doc); err != nil {
+				return fmt.Errorf("failed to add document to index: %w", err)
+			}
+		}
+		done++
+		progress.Report(done, total)
+	}
+
+	c.Indexes = rebuilt
+	return nil
+}
+
 // updateIndexes updates all indexes when a document is modified
 func (c *Collection) updateIndexes(oldDoc, newDoc *Document) error {
 	for _, idx := range c.Indexes {
@@ -108,9 +450,13 @@ func (c *Collection) updateIndexes(oldDoc, newDoc *Document) error {
 
 // IndexData represents the serializable format of an index
 type IndexData struct {
-	Name      string            `json:"name"`
-	FieldName string            `json:"field_name"`
-	Data      map[string]string `json:"data"`
+	Version   int                 `json:"version"`
+	Name      string              `json:"name"`
+	FieldName string              `json:"field_name"`
+	Type      IndexType           `json:"type,omitempty"`
+	Unique    bool                `json:"unique,omitempty"`
+	TTL       time.Duration       `json:"ttl,omitempty"`
+	Data      map[string][]string `json:"data"` // field value -> sorted document IDs
 }
 
 // Serialize converts an index to its serializable format
@@ -118,21 +464,44 @@ func (idx *Index) Serialize() (*IndexData, error) {
 	idx.mu.RLock()
 	defer idx.mu.RUnlock()
 
+	data := make(map[string][]string, len(idx.Data))
+	for key, ids := range idx.Data {
+		data[key] = sortedIDs(ids)
+	}
+
 	return &IndexData{
+		Version:   IndexFormatVersion,
 		Name:      idx.Name,
 		FieldName: idx.FieldName,
-		Data:      idx.Data,
+		Type:      idx.Type,
+		Unique:    idx.Unique,
+		TTL:       idx.TTL,
+		Data:      data,
 	}, nil
 }
 
-// Deserialize loads an index from its serialized format
+// Deserialize loads an index from its serialized format. The sorted entries
+// of an ordered index are not stored; rebuild the index from its documents.
 func (idx *Index) Deserialize(data *IndexData) error {
 	idx.mu.Lock()
 	defer idx.mu.Unlock()
 
 	idx.Name = data.Name
 	idx.FieldName = data.FieldName
-	idx.Data = data.Data
+	idx.Type = data.Type
+	idx.Unique = data.Unique
+	idx.TTL = data.TTL
+	if idx.Type == "" {
+		idx.Type = IndexHash
+	}
+	idx.Data = make(map[string]map[string]struct{}, len(data.Data))
+	for key, ids := range data.Data {
+		set := make(map[string]struct{}, len(ids))
+		for _, id := range ids {
+			set[id] = struct{}{}
+		}
+		idx.Data[key] = set
+	}
 
 	return nil
 }
@@ -157,7 +526,10 @@ func (idx *Index) SaveToDisk(dataDir, dbName, collName string) error {
 		return fmt.Errorf("failed to marshal index: %w", err)
 	}
 
-	if err := os.WriteFile(indexPath, jsonData, 0644); err != nil {
+	if err := writeFileAtomic(indexPath, func(w io.Writer) error {
+		_, err := w.Write(jsonData)
+		return err
+	}); err != nil {
 		return fmt.Errorf("failed to write index file: %w", err)
 	}
 
@@ -173,8 +545,30 @@ func LoadIndexFromDisk(dataDir, dbName, collName, indexName string) (*Index, err
 		return nil, fmt.Errorf("failed to read index file: %w", err)
 	}
 
+	var header struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(jsonData, &header); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal index: %w", err)
+	}
+	if header.Version > IndexFormatVersion {
+		return nil, fmt.Errorf("index file has format version %d, this build supports up to %d", header.Version, IndexFormatVersion)
+	}
+
 	var data IndexData
-	if err := json.Unmarshal(jsonData, &data); err != nil {
+	if header.Version < IndexFormatVersion {
+		// Older files map each value to a single ID; keep only the definition
+		var legacy struct {
+			Name      string    `json:"name"`
+			FieldName string    `json:"field_name"`
+			Type      IndexType `json:"type"`
+			Unique    bool      `json:"unique"`
+		}
+		if err := json.Unmarshal(jsonData, &legacy); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal index: %w", err)
+		}
+		data = IndexData{Name: legacy.Name, FieldName: legacy.FieldName, Type: legacy.Type, Unique: legacy.Unique}
+	} else if err := json.Unmarshal(jsonData, &data); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal index: %w", err)
 	}
 
@@ -182,6 +576,7 @@ func LoadIndexFromDisk(dataDir, dbName, collName, indexName string) (*Index, err
 	if err := idx.Deserialize(&data); err != nil {
 		return nil, fmt.Errorf("failed to deserialize index: %w", err)
 	}
+	idx.stale = header.Version < IndexFormatVersion
 
 	return idx, nil
 }
@@ -0,0 +1,221 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// JobRetention is how long a JobManager keeps a job after it finished
+const JobRetention = time.Hour
+
+// Statuses of a job
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+	JobCanceled  = "canceled"
+)
+
+// ErrJobNotFound is returned for an unknown job ID
+var ErrJobNotFound = errors.New("job not found")
+
+// JobFunc does the work of a job. It should stop and return ctx.Err() once
+// ctx is canceled, and report its progress to progress.
+type JobFunc func(ctx context.Context, progress ProgressFunc) (any, error)
+
+// Job is an operation run in the background by a JobManager
+type Job struct {
+	ID   string
+	Kind string
+	// Unit names what the progress of the job counts, e.g. "documents"
+	Unit    string
+	Started time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu       sync.Mutex
+	progress int64
+	total    int64
+	finished time.Time
+	result   any
+	err      error
+}
+
+// JobInfo describes the state of a job
+type JobInfo struct {
+	ID         string     `json:"id"`
+	Kind       string     `json:"kind"`
+	Status     string     `json:"status"`
+	Done       int64      `json:"done"`
+	Total      int64      `json:"total"`
+	Unit       string     `json:"unit,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Result     any        `json:"result,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Info returns the state of the job
+func (j *Job) Info() JobInfo {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	info := JobInfo{
+		ID:        j.ID,
+		Kind:      j.Kind,
+		Status:    JobRunning,
+		Done:      j.progress,
+		Total:     j.total,
+		Unit:      j.Unit,
+		StartedAt: j.Started.UTC(),
+	}
+	if j.finished.IsZero() {
+		return info
+	}
+
+	finished := j.finished.UTC()
+	info.FinishedAt = &finished
+	switch {
+	case errors.Is(j.err, context.Canceled):
+		info.Status = JobCanceled
+		info.Error = j.err.Error()
+	case j.err != nil:
+		info.Status = JobFailed
+		info.Error = j.err.Error()
+	default:
+		info.Status = JobSucceeded
+		info.Result = j.result
+	}
+	return info
+}
+
+// Err returns the error the job failed with, or nil if it is running or
+// succeeded
+func (j *Job) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.err
+}
+
+// Done returns a channel closed once the job finished
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// report records the progress of the job
+func (j *Job) report(done, total int64) {
+	j.mu.Lock()
+	j.progress, j.total = done, total
+	j.mu.Unlock()
+}
+
+// JobManager runs jobs in the background and keeps track of them until
+// JobRetention after they finished
+type JobManager struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewJobManager creates a job manager without jobs
+func NewJobManager() *JobManager {
+	return &JobManager{jobs: make(map[string]*Job)}
+}
+
+// Start runs a job of the given kind in the background. unit names what
+// its progress counts.
+func (jm *JobManager) Start(kind, unit string, run JobFunc) *Job {
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &Job{
+		ID:      uuid.New().String(),
+		Kind:    kind,
+		Unit:    unit,
+		Started: time.Now(),
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	jm.mu.Lock()
+	jm.pruneLocked(job.Started)
+	jm.jobs[job.ID] = job
+	jm.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		result, err := run(ctx, job.report)
+		if err != nil && ctx.Err() != nil && !errors.Is(err, context.Canceled) {
+			// Work that failed because it was stopped counts as canceled
+			err = fmt.Errorf("%w: %v", context.Canceled, err)
+		}
+
+		job.mu.Lock()
+		job.finished = time.Now()
+		job.result, job.err = result, err
+		job.mu.Unlock()
+		close(job.done)
+	}()
+	return job
+}
+
+// Get returns the job with the given ID
+func (jm *JobManager) Get(id string) (*Job, error) {
+	jm.mu.Lock()
+	defer jm.mu.Unlock()
+
+	job, exists := jm.jobs[id]
+	if !exists {
+		return nil, fmt.Errorf("%w: '%s'", ErrJobNotFound, id)
+	}
+	return job, nil
+}
+
+// List returns the jobs, newest first
+func (jm *JobManager) List() []*Job {
+	jm.mu.Lock()
+	jm.pruneLocked(time.Now())
+	jobs := make([]*Job, 0, len(jm.jobs))
+	for _, job := range jm.jobs {
+		jobs = append(jobs, job)
+	}
+	jm.mu.Unlock()
+
+	sort.Slice(jobs, func(i, j int) bool {
+		return jobs[i].Started.After(jobs[j].Started)
+	})
+	return jobs
+}
+
+// Cancel asks a running job to stop. The job finishes as canceled once its
+// work notices; work it completed before is kept.
+func (jm *JobManager) Cancel(id string) error {
+	job, err := jm.Get(id)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-job.done:
+		return fmt.Errorf("job '%s' already finished", id)
+	default:
+	}
+	job.cancel()
+	return nil
+}
+
+// pruneLocked forgets the jobs finished longer than JobRetention before
+// now (caller must hold mu)
+func (jm *JobManager) pruneLocked(now time.Time) {
+	for id, job := range jm.jobs {
+		job.mu.Lock()
+		expired := !job.finished.IsZero() && now.Sub(job.finished) > JobRetention
+		job.mu.Unlock()
+		if expired {
+			delete(jm.jobs, id)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package db
+
+import "fmt"
+
+// collectionPlaceholder builds a collection from its metadata file alone.
+// It has the schema and options of the stored collection but no documents
+// or indexes until Database.GetCollection loads it.
+func (sm *StorageManager) collectionPlaceholder(dbName, collName string) (*Collection, error) {
+	meta, err := sm.readCollectionMeta(dbName, collName)
+	if err != nil {
+		return nil, err
+	}
+
+	coll := NewCollection(meta.Name, meta.Schema)
+	coll.IDStrategy = meta.IDStrategy
+	coll.BlobPolicy = meta.BlobPolicy
+	coll.Collation = meta.Collation
+	coll.MemoryBudget = meta.MemoryBudget
+	coll.Codec = meta.Codec
+	coll.unloaded = true
+	return coll, nil
+}
+
+// loadCollection replaces a placeholder with the fully loaded collection.
+// A failed load leaves the placeholder, so the next access tries again.
+func (db *Database) loadCollection(name string) (*Collection, error) {
+	db.loadMu.Lock()
+	defer db.loadMu.Unlock()
+
+	// Another caller may have loaded it while this one waited
+	db.mu.RLock()
+	placeholder, exists := db.Collections[name]
+	db.mu.RUnlock()
+	if !exists {
+		return nil, &CollectionNotFoundError{Collection: name}
+	}
+	if !placeholder.unloaded {
+		return placeholder, nil
+	}
+	if db.loader == nil {
+		return nil, fmt.Errorf("collection '%s' cannot be loaded", name)
+	}
+
+	coll, err := db.loader(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load collection '%s': %w", name, err)
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// The collection may have been dropped (or dropped and created again)
+	// during the load
+	current, exists := db.Collections[name]
+	if !exists {
+		return nil, &CollectionNotFoundError{Collection: name}
+	}
+	if current != placeholder {
+		return current, nil
+	}
+	db.Collections[name] = coll
+	return coll, nil
+}
+
+// loadedCollection returns a collection if it exists and is loaded, without
+// loading a placeholder
+func (db *Database) loadedCollection(name string) *Collection {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	coll := db.Collections[name]
+	if coll == nil || coll.unloaded {
+		return nil
+	}
+	return coll
+}
+
+// LoadedCollections returns how many of the database's collections are
+// loaded, and how many it has in all; the others are placeholders until
+// they are first used (see StorageManager.LazyLoad)
+func (db *Database) LoadedCollections() (loaded, total int) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	for _, coll := range db.Collections {
+		if !coll.unloaded {
+			loaded++
+		}
+	}
+	return loaded, len(db.Collections)
+}
@@ -0,0 +1,281 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LeasesCollection is the system collection holding the leases of a
+// database, one document per lease named by its ID
+const LeasesCollection = "_leases"
+
+// Fields of a lease document
+const (
+	LeaseTokenField   = "token"
+	LeaseOwnerField   = "owner"
+	LeaseExpiresField = "expires_at" // seconds since the Unix epoch
+)
+
+// LeaseExpiryGrace is the TTL of the index on LeaseExpiresField: lease
+// documents are deleted once expired this long, so leases of workers that
+// died are cleaned up. An expired lease can be acquired again right away.
+const LeaseExpiryGrace = time.Minute
+
+var (
+	// ErrLeaseHeld is matched (with errors.Is) by every *LeaseHeldError
+	ErrLeaseHeld = errors.New("lease is held")
+	// ErrLeaseLost is returned when renewing or releasing a lease that has
+	// expired or was acquired by someone else since
+	ErrLeaseLost = errors.New("lease expired or is held by someone else")
+)
+
+// LeaseHeldError reports an acquire of a lease another holder has
+type LeaseHeldError struct {
+	Name    string
+	Owner   string
+	Expires time.Time
+}
+
+func (e *LeaseHeldError) Error() string {
+	holder := ""
+	if e.Owner != "" {
+		holder = " by " + e.Owner
+	}
+	return fmt.Sprintf("lease '%s' is held%s until %s", e.Name, holder, e.Expires.Format(time.RFC3339Nano))
+}
+
+func (e *LeaseHeldError) Is(target error) bool {
+	return target == ErrLeaseHeld
+}
+
+// Lease is the exclusive hold on a name until it expires. Leases let
+// workers sharing a server coordinate: the one holding a lease does the
+// work it names, renewing it while the work lasts and releasing it when done.
+type Lease struct {
+	Name string `json:"name"`
+	// Token proves the hold; only its holder can renew or release the lease
+	Token   string    `json:"token"`
+	Owner   string    `json:"owner,omitempty"`
+	Expires time.Time `json:"expires"`
+
+	database *Database
+	// storage, if set, logs the changes of Renew and Release
+	storage *StorageManager
+}
+
+// AcquireLease takes the lease name for ttl, failing with a
+// *LeaseHeldError if it is held and not expired. owner optionally tells
+// others who holds it. The change is made in memory; DB.AcquireLease also
+// logs it.
+func (db *Database) AcquireLease(name, owner string, ttl time.Duration) (*Lease, error) {
+	if name == "" {
+		return nil, fmt.Errorf("lease name cannot be empty")
+	}
+	if ttl <= 0 {
+		return nil, fmt.Errorf("lease TTL must be positive")
+	}
+	coll, _, err := db.leases()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	lease := &Lease{
+		Name:     name,
+		Token:    uuid.New().String(),
+		Owner:    owner,
+		Expires:  now.Add(ttl),
+		database: db,
+	}
+	fields := map[string]any{
+		LeaseTokenField:   lease.Token,
+		LeaseExpiresField: leaseTime(lease.Expires),
+	}
+	if owner != "" {
+		fields[LeaseOwnerField] = owner
+	}
+
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+
+	stored, exists := coll.Documents[name]
+	if !exists {
+		return lease, coll.insertLocked(&Document{ID: name, Data: fields}, true)
+	}
+	stored, err = coll.loadLocked(stored)
+	if err != nil {
+		return nil, err
+	}
+	if expires := leaseExpires(stored); now.Before(expires) {
+		owner, _ := stored.Data[LeaseOwnerField].(string)
+		return nil, &LeaseHeldError{Name: name, Owner: owner, Expires: expires}
+	}
+	return lease, coll.modifyLocked(name, func(doc *Document) error {
+		delete(doc.Data, LeaseOwnerField)
+		maps.Copy(doc.Data, fields)
+		return nil
+	})
+}
+
+// RenewLease extends a lease held with token to ttl from now, failing with
+// ErrLeaseLost if it expired or was taken by someone else. It returns the
+// new expiry.
+func (db *Database) RenewLease(name, token string, ttl time.Duration) (time.Time, error) {
+	if ttl <= 0 {
+		return time.Time{}, fmt.Errorf("lease TTL must be positive")
+	}
+	coll, err := db.GetCollection(LeasesCollection)
+	if err != nil {
+		return time.Time{}, ErrLeaseLost
+	}
+
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+
+	now := time.Now()
+	if _, err := coll.heldLeaseLocked(name, token, now); err != nil {
+		return time.Time{}, err
+	}
+	expires := now.Add(ttl)
+	err = coll.modifyLocked(name, func(doc *Document) error {
+		doc.Data[LeaseExpiresField] = leaseTime(expires)
+		return nil
+	})
+	return expires, err
+}
+
+// ReleaseLease gives up a lease held with token, so others can acquire it
+// at once. It fails with ErrLeaseLost if the lease expired or was taken by
+// someone else.
+func (db *Database) ReleaseLease(name, token string) error {
+	coll, err := db.GetCollection(LeasesCollection)
+	if err != nil {
+		return ErrLeaseLost
+	}
+
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+
+	stored, err := coll.heldLeaseLocked(name, token, time.Now())
+	if err != nil {
+		return err
+	}
+	return coll.deleteLocked(stored, true)
+}
+
+// heldLeaseLocked returns the document of a lease if it is held with token
+// and not expired (caller must hold mu)
+func (c *Collection) heldLeaseLocked(name, token string, now time.Time) (*Document, error) {
+	stored, exists := c.Documents[name]
+	if !exists {
+		return nil, ErrLeaseLost
+	}
+	stored, err := c.loadLocked(stored)
+	if err != nil {
+		return nil, err
+	}
+	if held, _ := stored.Data[LeaseTokenField].(string); held != token || !now.Before(leaseExpires(stored)) {
+		return nil, ErrLeaseLost
+	}
+	return stored, nil
+}
+
+// Renew extends the lease to ttl from now (see Database.RenewLease)
+func (l *Lease) Renew(ttl time.Duration) error {
+	expires, err := l.database.RenewLease(l.Name, l.Token, ttl)
+	if err != nil {
+		return err
+	}
+	l.Expires = expires
+	if l.storage == nil {
+		return nil
+	}
+	coll, err := l.database.GetCollection(LeasesCollection)
+	if err != nil {
+		return err
+	}
+	return logStoredDocument(l.storage, l.database.Name, coll, l.Name)
+}
+
+// Release gives up the lease (see Database.ReleaseLease)
+func (l *Lease) Release() error {
+	if err := l.database.ReleaseLease(l.Name, l.Token); err != nil {
+		return err
+	}
+	if l.storage == nil {
+		return nil
+	}
+	if err := l.storage.LogDelete(l.database.Name, LeasesCollection, l.Name); err != nil {
+		return fmt.Errorf("failed to log lease release: %w", err)
+	}
+	return nil
+}
+
+// leases returns the collection of the leases, creating it with its TTL
+// index if needed, and whether it was created
+func (db *Database) leases() (*Collection, bool, error) {
+	coll, created, err := db.systemCollection(LeasesCollection)
+	if err != nil || !created {
+		return coll, created, err
+	}
+	if err := coll.CreateTTLIndex(LeaseExpiresField, LeaseExpiryGrace); err != nil {
+		return nil, false, err
+	}
+	return coll, true, nil
+}
+
+// leaseTime is how the expiry of a lease is stored
+func leaseTime(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+// leaseExpires reads the expiry of a lease document
+func leaseExpires(doc *Document) time.Time {
+	seconds, _ := toFloat(doc.Data[LeaseExpiresField])
+	return time.UnixMilli(int64(seconds * 1000))
+}
+
+// AcquireLease is Database.AcquireLease on a database, logging the lease to
+// the WAL. Renew and Release on the returned lease log their changes too.
+func (d *DB) AcquireLease(database, name, owner string, ttl time.Duration) (*Lease, error) {
+	db := d.GetDatabase(database)
+	if db == nil {
+		return nil, fmt.Errorf("database '%s' not found", database)
+	}
+	coll, created, err := db.leases()
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		if err := d.Storage.LogLeasesCollection(db.Name); err != nil {
+			return nil, err
+		}
+	}
+
+	lease, err := db.AcquireLease(name, owner, ttl)
+	if err != nil {
+		return nil, err
+	}
+	lease.storage = d.Storage
+	if err := logStoredDocument(d.Storage, db.Name, coll, name); err != nil {
+		return nil, err
+	}
+	return lease, nil
+}
+
+// LogLeasesCollection logs the creation of the lease collection of a
+// database, with its TTL index
+func (sm *StorageManager) LogLeasesCollection(dbName string) error {
+	if err := sm.LogCreateCollection(dbName, LeasesCollection, nil); err != nil {
+		return fmt.Errorf("failed to log create collection: %w", err)
+	}
+	indexName := LeaseExpiresField + TTLIndexSuffix
+	if err := sm.LogCreateIndex(dbName, LeasesCollection, indexName, LeaseExpiresField, IndexOptions{TTL: LeaseExpiryGrace}); err != nil {
+		return fmt.Errorf("failed to log create index: %w", err)
+	}
+	return nil
+}
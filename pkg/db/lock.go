@@ -0,0 +1,51 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LockFileName is the file in a data directory that a storage manager holds
+// a lock on, so two processes never write the same WAL and data files
+const LockFileName = "cachydb.lock"
+
+// ErrLocked is matched (with errors.Is) by every *LockedError
+var ErrLocked = errors.New("data directory is locked")
+
+// ErrReadOnly is returned by writes through a read-only storage manager
+var ErrReadOnly = errors.New("storage is read-only")
+
+// LockedError reports a data directory that another process already holds
+type LockedError struct {
+	Dir string
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("data directory %s is already in use by another CachyDB process; stop it first, or open the directory read-only (--read-only) to inspect it", e.Dir)
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// lockDir takes the lock of a data directory. The lock is held until the
+// returned file is closed, and is released by the OS if the process dies.
+func lockDir(rootDir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(rootDir, LockFileName), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	held, err := tryLock(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock data directory: %w", err)
+	}
+	if !held {
+		f.Close()
+		return nil, &LockedError{Dir: rootDir}
+	}
+	return f, nil
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package db
+
+import "os"
+
+// tryLock does nothing on platforms without file locks
+func tryLock(f *os.File) (held bool, err error) {
+	return true, nil
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// docCache keeps the resident document bodies of a collection within its
+// memory budget by evicting the least recently used ones. Only bodies whose
+// current version is in the binary data file are evicted; they are read
+// back from it on access.
+type docCache struct {
+	used    int64                    // estimated size of the bodies in lru
+	lru     *list.List               // *cacheEntry, most recently used first
+	entries map[string]*list.Element // document ID -> element of lru
+	reader  *BinaryCollectionReader  // data file the bodies are read back from
+	mu      sync.Mutex
+}
+
+// cacheEntry is a document body that can be evicted while doc is still the
+// stored version of the document
+type cacheEntry struct {
+	doc  *Document
+	size int64
+}
+
+func newDocCache() *docCache {
+	return &docCache{
+		lru:     list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// SetMemoryBudget limits the estimated memory used by document bodies to
+// budget bytes (0 = unlimited). Over budget, the least recently used
+// bodies already saved in binary storage are dropped from memory and read
+// back on access; documents written since the last save always stay.
+func (c *Collection) SetMemoryBudget(budget int64) error {
+	if budget < 0 {
+		return fmt.Errorf("memory budget cannot be negative")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.MemoryBudget = budget
+	if budget > 0 && c.cache == nil {
+		c.cache = newDocCache()
+	}
+	c.evictLocked()
+	return nil
+}
+
+// EvictedCount returns the number of documents whose bodies are not in memory
+func (c *Collection) EvictedCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	count := 0
+	if c.cache != nil {
+		for _, doc := range c.Documents {
+			if doc.evicted {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// loadLocked returns doc with its body, reading an evicted body back from
+// the data file without keeping it (caller must hold mu)
+func (c *Collection) loadLocked(doc *Document) (*Document, error) {
+	if !doc.evicted {
+		return doc, nil
+	}
+
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	if c.cache.reader == nil {
+		return nil, fmt.Errorf("document '%s' is not in memory and the collection has no data file", doc.ID)
+	}
+	loaded, err := c.cache.reader.ReadDocument(doc.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reload document '%s': %w", doc.ID, err)
+	}
+	return loaded, nil
+}
+
+// promote keeps an evicted body that was read back in memory again,
+// evicting others if needed
+func (c *Collection) promote(loaded *Document) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if current, exists := c.Documents[loaded.ID]; !exists || !current.evicted {
+		return // written or deleted since it was read
+	}
+	c.Documents[loaded.ID] = loaded
+	c.noteStored(loaded)
+	c.evictLocked()
+}
+
+// noteStored records that doc is the version of its document in the data
+// file, so its body may be evicted
+func (c *Collection) noteStored(doc *Document) {
+	if c.cache == nil || doc.evicted {
+		return
+	}
+
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	size := estimateBodySize(doc)
+	if elem, exists := c.cache.entries[doc.ID]; exists {
+		// Saving again is not a use, so the position is kept
+		entry := elem.Value.(*cacheEntry)
+		c.cache.used += size - entry.size
+		entry.doc, entry.size = doc, size
+		return
+	}
+	c.cache.entries[doc.ID] = c.cache.lru.PushFront(&cacheEntry{doc: doc, size: size})
+	c.cache.used += size
+}
+
+// touch marks a document as recently used
+func (c *Collection) touch(id string) {
+	if c.cache == nil {
+		return
+	}
+
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	if elem, exists := c.cache.entries[id]; exists {
+		c.cache.lru.MoveToFront(elem)
+	}
+}
+
+// forget drops a document whose stored version was replaced or deleted
+func (c *Collection) forget(id string) {
+	if c.cache == nil {
+		return
+	}
+
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	if elem, exists := c.cache.entries[id]; exists {
+		c.cache.used -= elem.Value.(*cacheEntry).size
+		c.cache.lru.Remove(elem)
+		delete(c.cache.entries, id)
+	}
+}
+
+// evictLocked drops the least recently used bodies until the collection is
+// within its memory budget (caller must hold mu)
+func (c *Collection) evictLocked() {
+	if c.cache == nil || c.MemoryBudget <= 0 {
+		return
+	}
+
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	if c.cache.reader == nil {
+		return
+	}
+	for c.cache.used > c.MemoryBudget {
+		elem := c.cache.lru.Back()
+		if elem == nil {
+			break
+		}
+		entry := elem.Value.(*cacheEntry)
+		c.cache.used -= entry.size
+		c.cache.lru.Remove(elem)
+		delete(c.cache.entries, entry.doc.ID)
+
+		if c.Documents[entry.doc.ID] == entry.doc {
+			c.Documents[entry.doc.ID] = &Document{ID: entry.doc.ID, evicted: true}
+		}
+	}
+}
+
+// evict is evictLocked for callers that do not hold mu
+func (c *Collection) evict() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.evictLocked()
+}
+
+// setCacheReader replaces the reader evicted bodies are read back from
+func (c *Collection) setCacheReader(reader *BinaryCollectionReader) {
+	c.cache.mu.Lock()
+	defer c.cache.mu.Unlock()
+
+	if c.cache.reader != nil {
+		c.cache.reader.Close()
+	}
+	c.cache.reader = reader
+}
+
+// estimateBodySize estimates the memory used by a document body by the
+// length of its JSON encoding
+func estimateBodySize(doc *Document) int64 {
+	data, err := json.Marshal(doc.Data)
+	if err != nil {
+		return 0
+	}
+	return int64(len(data))
+}
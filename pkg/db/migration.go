@@ -1,13 +1,20 @@
 package db
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 )
 
 // CurrentSchemaVersion is the latest schema version
 const CurrentSchemaVersion = 1
 
+// MigrationBackupDir is the directory inside the root that holds pre-migration backups
+const MigrationBackupDir = ".migration-backup"
+
 // MigrationFunc is a function that migrates from one version to the next
 type MigrationFunc func(dbManager *DatabaseManager, storage *StorageManager) error
 
@@ -62,6 +69,12 @@ func GetMigration(fromVersion int) (MigrationFunc, bool) {
 // MigrationManager handles database schema migrations
 type MigrationManager struct {
 	storage *StorageManager
+
+	// progress is reported the migration steps done of those planned by
+	// the migration running (see SetProgress)
+	progress ProgressFunc
+	done     int64
+	total    int64
 }
 
 // NewMigrationManager creates a new migration manager
@@ -71,8 +84,133 @@ func NewMigrationManager(storage *StorageManager) *MigrationManager {
 	}
 }
 
-// MigrateDatabase migrates a database from its current version to the target version
+// SetProgress reports the progress of migrations to progress, counting
+// each migration step as a unit of work
+func (mm *MigrationManager) SetProgress(progress ProgressFunc) {
+	mm.progress = progress
+}
+
+// startProgress begins reporting a migration planned by plans
+func (mm *MigrationManager) startProgress(plans ...*MigrationPlan) {
+	mm.done, mm.total = 0, 0
+	for _, plan := range plans {
+		mm.total += int64(len(plan.Steps))
+	}
+	if mm.total > 0 {
+		mm.progress.Report(mm.done, mm.total)
+	}
+}
+
+// advance reports one more unit of work of the running migration done
+func (mm *MigrationManager) advance() {
+	mm.done++
+	mm.progress.Report(mm.done, mm.total)
+}
+
+// MigrationPlan describes what migrating a database would do
+type MigrationPlan struct {
+	Database       string `json:"database"`
+	CurrentVersion int    `json:"current_version"`
+	TargetVersion  int    `json:"target_version"`
+	Steps          []int  `json:"steps"`   // from-versions of the migrations that would run
+	Missing        []int  `json:"missing"` // from-versions with no registered migration
+}
+
+// NeedsMigration reports whether the database is behind the target version
+func (p *MigrationPlan) NeedsMigration() bool {
+	return p.CurrentVersion < p.TargetVersion
+}
+
+// Err returns why the plan cannot be applied, or nil if it can
+func (p *MigrationPlan) Err() error {
+	if p.CurrentVersion > p.TargetVersion {
+		return fmt.Errorf("cannot downgrade database from version %d to %d", p.CurrentVersion, p.TargetVersion)
+	}
+	if len(p.Missing) > 0 {
+		return fmt.Errorf("no migration found from version %d to %d", p.Missing[0], p.Missing[0]+1)
+	}
+	return nil
+}
+
+// PlanDatabase reports what migrating a database to the target version would do, without writing anything
+func (mm *MigrationManager) PlanDatabase(dbName string, targetVersion int) (*MigrationPlan, error) {
+	currentVersion, err := mm.GetDatabaseVersion(dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &MigrationPlan{
+		Database:       dbName,
+		CurrentVersion: currentVersion,
+		TargetVersion:  targetVersion,
+	}
+	for version := currentVersion; version < targetVersion; version++ {
+		if _, exists := GetMigration(version); exists {
+			plan.Steps = append(plan.Steps, version)
+		} else {
+			plan.Missing = append(plan.Missing, version)
+		}
+	}
+
+	return plan, nil
+}
+
+// PlanAllDatabases reports what migrating every database on disk would do, without writing anything
+func (mm *MigrationManager) PlanAllDatabases(targetVersion int) ([]*MigrationPlan, error) {
+	dbNames, err := mm.storage.ListStoredDatabases()
+	if err != nil {
+		return nil, err
+	}
+
+	plans := make([]*MigrationPlan, 0, len(dbNames))
+	for _, dbName := range dbNames {
+		plan, err := mm.PlanDatabase(dbName, targetVersion)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan migration for database '%s': %w", dbName, err)
+		}
+		plans = append(plans, plan)
+	}
+
+	return plans, nil
+}
+
+// MigrateDatabase migrates a database from its current version to the target version.
+// If any step fails, the database is rolled back to its pre-migration state.
 func (mm *MigrationManager) MigrateDatabase(dbName string, targetVersion int) error {
+	return mm.MigrateDatabaseContext(context.Background(), dbName, targetVersion)
+}
+
+// MigrateDatabaseContext is MigrateDatabase stopping before the next step
+// once ctx is canceled, which rolls the database back like a failed step
+func (mm *MigrationManager) MigrateDatabaseContext(ctx context.Context, dbName string, targetVersion int) error {
+	plan, err := mm.PlanDatabase(dbName, targetVersion)
+	if err != nil {
+		return err
+	}
+	if !plan.NeedsMigration() {
+		return mm.migrateDatabase(ctx, dbName, targetVersion)
+	}
+	if err := plan.Err(); err != nil {
+		return err
+	}
+
+	backups, err := mm.backupDatabases([]string{dbName})
+	if err != nil {
+		return err
+	}
+
+	mm.startProgress(plan)
+
+	if err := mm.migrateDatabase(ctx, dbName, targetVersion); err != nil {
+		return mm.rollback(backups, err)
+	}
+
+	mm.discardBackups(backups)
+	return nil
+}
+
+// migrateDatabase applies the migration steps for one database without taking a backup
+func (mm *MigrationManager) migrateDatabase(ctx context.Context, dbName string, targetVersion int) error {
 	fmt.Printf("Starting migration for database '%s'...\n", dbName)
 
 	// Load database
@@ -105,6 +243,9 @@ func (mm *MigrationManager) MigrateDatabase(dbName string, targetVersion int) er
 
 	// Apply migrations iteratively from currentVersion to targetVersion
 	for version := currentVersion; version < targetVersion; version++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		fmt.Printf("Applying migration from version %d to %d...\n", version, version+1)
 
 		migrationFunc, exists := GetMigration(version)
@@ -125,14 +266,23 @@ func (mm *MigrationManager) MigrateDatabase(dbName string, targetVersion int) er
 		}
 
 		fmt.Printf("Successfully migrated to version %d\n", version+1)
+		mm.advance()
 	}
 
 	fmt.Printf("Database '%s' successfully migrated to version %d\n", dbName, targetVersion)
 	return nil
 }
 
-// MigrateAllDatabases migrates all databases to the target version
+// MigrateAllDatabases migrates all databases to the target version.
+// The operation is all-or-nothing: if any database fails to migrate, every
+// database is rolled back to its pre-migration state.
 func (mm *MigrationManager) MigrateAllDatabases(targetVersion int) error {
+	return mm.MigrateAllDatabasesContext(context.Background(), targetVersion)
+}
+
+// MigrateAllDatabasesContext is MigrateAllDatabases stopping before the next
+// step once ctx is canceled, which rolls every database back
+func (mm *MigrationManager) MigrateAllDatabasesContext(ctx context.Context, targetVersion int) error {
 	fmt.Printf("Starting migration of all databases to version %d...\n", targetVersion)
 
 	// Load all databases
@@ -141,14 +291,41 @@ func (mm *MigrationManager) MigrateAllDatabases(targetVersion int) error {
 		return fmt.Errorf("failed to load databases: %w", err)
 	}
 
+	// Check every plan up front so nothing is touched if any database cannot be migrated
+	var toMigrate []string
+	var plans []*MigrationPlan
+	for _, dbName := range dbManager.ListDatabases() {
+		plan, err := mm.PlanDatabase(dbName, targetVersion)
+		if err != nil {
+			return fmt.Errorf("failed to plan migration for database '%s': %w", dbName, err)
+		}
+		if err := plan.Err(); err != nil {
+			return fmt.Errorf("cannot migrate database '%s': %w", dbName, err)
+		}
+		if plan.NeedsMigration() {
+			toMigrate = append(toMigrate, dbName)
+			plans = append(plans, plan)
+		}
+	}
+	sort.Strings(toMigrate)
+
+	backups, err := mm.backupDatabases(toMigrate)
+	if err != nil {
+		return err
+	}
+
+	mm.startProgress(plans...)
+
 	migratedCount := 0
-	for dbName := range dbManager.Databases {
-		if err := mm.MigrateDatabase(dbName, targetVersion); err != nil {
-			return fmt.Errorf("failed to migrate database '%s': %w", dbName, err)
+	for _, dbName := range toMigrate {
+		if err := mm.migrateDatabase(ctx, dbName, targetVersion); err != nil {
+			return mm.rollback(backups, fmt.Errorf("failed to migrate database '%s': %w", dbName, err))
 		}
 		migratedCount++
 	}
 
+	mm.discardBackups(backups)
+
 	fmt.Printf("Successfully migrated %d database(s) to version %d\n", migratedCount, targetVersion)
 	return nil
 }
@@ -181,3 +358,203 @@ func (mm *MigrationManager) ListMigrations() []int {
 
 	return versions
 }
+
+// backupDatabases copies each database directory into the migration backup area,
+// returning a map of database name to backup path
+func (mm *MigrationManager) backupDatabases(dbNames []string) (map[string]string, error) {
+	if mm.storage.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	backupRoot := filepath.Join(mm.storage.RootDir, MigrationBackupDir)
+	backups := make(map[string]string, len(dbNames))
+
+	for _, dbName := range dbNames {
+		backupPath := filepath.Join(backupRoot, dbName)
+		if err := os.RemoveAll(backupPath); err != nil {
+			mm.discardBackups(backups)
+			return nil, fmt.Errorf("failed to clear old backup of database '%s': %w", dbName, err)
+		}
+		if err := copyDir(filepath.Join(mm.storage.RootDir, dbName), backupPath); err != nil {
+			mm.discardBackups(backups)
+			return nil, fmt.Errorf("failed to back up database '%s': %w", dbName, err)
+		}
+		backups[dbName] = backupPath
+	}
+
+	return backups, nil
+}
+
+// rollback restores every backed-up database and returns cause annotated with the rollback outcome
+func (mm *MigrationManager) rollback(backups map[string]string, cause error) error {
+	var failed []string
+	for dbName, backupPath := range backups {
+		dbDir := filepath.Join(mm.storage.RootDir, dbName)
+		if err := os.RemoveAll(dbDir); err != nil {
+			failed = append(failed, dbName)
+			continue
+		}
+		if err := os.Rename(backupPath, dbDir); err != nil {
+			failed = append(failed, dbName)
+		}
+	}
+
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("%w (rollback failed for %v, backups kept in %s)",
+			cause, failed, filepath.Join(mm.storage.RootDir, MigrationBackupDir))
+	}
+
+	os.Remove(filepath.Join(mm.storage.RootDir, MigrationBackupDir))
+	fmt.Printf("Migration failed, rolled back %d database(s) to their pre-migration state\n", len(backups))
+	return fmt.Errorf("%w (rolled back to pre-migration state)", cause)
+}
+
+// discardBackups removes backups after a successful migration
+func (mm *MigrationManager) discardBackups(backups map[string]string) {
+	for _, backupPath := range backups {
+		os.RemoveAll(backupPath)
+	}
+	os.Remove(filepath.Join(mm.storage.RootDir, MigrationBackupDir))
+}
+
+// VerificationMismatch describes a document that differs between the JSON and binary copies
+type VerificationMismatch struct {
+	Collection string `json:"collection"`
+	DocumentID string `json:"document_id"`
+	Reason     string `json:"reason"`
+}
+
+// VerificationReport summarizes a deep comparison of a database's JSON and binary data
+type VerificationReport struct {
+	Database    string                 `json:"database"`
+	Collections int                    `json:"collections"` // collections with both a JSON and a binary copy
+	Documents   int                    `json:"documents"`   // documents compared
+	Mismatches  []VerificationMismatch `json:"mismatches,omitempty"`
+}
+
+// OK reports whether no divergence was found
+func (r *VerificationReport) OK() bool {
+	return len(r.Mismatches) == 0
+}
+
+func (r *VerificationReport) mismatch(collName, docID, format string, args ...any) {
+	r.Mismatches = append(r.Mismatches, VerificationMismatch{
+		Collection: collName,
+		DocumentID: docID,
+		Reason:     fmt.Sprintf(format, args...),
+	})
+}
+
+// VerifyMigration deeply compares the legacy JSON documents (documents.json) of every
+// collection in a database with the binary output written by the JSON to binary
+// conversion. Every document ID and field value is compared; divergences are
+// collected in the report rather than returned as an error.
+func (mm *MigrationManager) VerifyMigration(dbName string) (*VerificationReport, error) {
+	dbDir := filepath.Join(mm.storage.RootDir, dbName)
+	entries, err := os.ReadDir(dbDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read database directory: %w", err)
+	}
+
+	report := &VerificationReport{Database: dbName}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if err := mm.verifyCollection(dbName, entry.Name(), report); err != nil {
+			return nil, fmt.Errorf("failed to verify collection '%s': %w", entry.Name(), err)
+		}
+	}
+
+	return report, nil
+}
+
+// verifyCollection compares one collection's JSON and binary documents
+func (mm *MigrationManager) verifyCollection(dbName, collName string, report *VerificationReport) error {
+	var jsonDocs []*Document
+	if err := mm.storage.readJSON(filepath.Join(mm.storage.RootDir, dbName, collName, "documents.json"), &jsonDocs); err != nil {
+		if os.IsNotExist(err) {
+			return nil // never stored as JSON, nothing to verify
+		}
+		return fmt.Errorf("failed to read JSON documents: %w", err)
+	}
+
+	reader, err := NewBinaryCollectionReader(mm.storage.RootDir, dbName, collName)
+	if err != nil {
+		if os.IsNotExist(err) {
+			report.mismatch(collName, "", "binary data file is missing")
+			return nil
+		}
+		return fmt.Errorf("failed to open binary data: %w", err)
+	}
+	defer reader.Close()
+
+	report.Collections++
+
+	expected := make(map[string]*Document, len(jsonDocs))
+	for _, doc := range jsonDocs {
+		expected[doc.ID] = doc
+	}
+
+	ids := make([]string, 0, len(expected)+len(reader.index.Entries))
+	for id := range expected {
+		ids = append(ids, id)
+	}
+	for id := range reader.index.Entries {
+		if _, exists := expected[id]; !exists {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		report.Documents++
+
+		want, inJSON := expected[id]
+		if _, inBinary := reader.index.Entries[id]; !inBinary {
+			report.mismatch(collName, id, "missing from binary data")
+			continue
+		}
+		got, err := reader.ReadDocument(id)
+		if err != nil {
+			report.mismatch(collName, id, "unreadable in binary data: %v", err)
+			continue
+		}
+		if !inJSON {
+			report.mismatch(collName, id, "present in binary data but not in JSON")
+			continue
+		}
+
+		compareDocumentFields(collName, want, got, report)
+	}
+
+	return nil
+}
+
+// compareDocumentFields reports every field whose value differs between two copies of a document
+func compareDocumentFields(collName string, want, got *Document, report *VerificationReport) {
+	fields := make([]string, 0, len(want.Data))
+	for field := range want.Data {
+		fields = append(fields, field)
+	}
+	for field := range got.Data {
+		if _, exists := want.Data[field]; !exists {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		wantValue, inJSON := want.Data[field]
+		gotValue, inBinary := got.Data[field]
+		switch {
+		case !inBinary:
+			report.mismatch(collName, want.ID, "field '%s' missing from binary data", field)
+		case !inJSON:
+			report.mismatch(collName, want.ID, "field '%s' present in binary data but not in JSON", field)
+		case !jsonEqual(wantValue, gotValue):
+			report.mismatch(collName, want.ID, "field '%s' differs: JSON %v, binary %v", field, wantValue, gotValue)
+		}
+	}
+}
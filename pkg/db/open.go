@@ -0,0 +1,190 @@
+package db
+
+import (
+	"fmt"
+	"sync"
+)
+
+// DB is an open CachyDB instance: the in-memory databases together with the
+// storage manager and WAL that persist them. It embeds the DatabaseManager, so
+// databases are created and looked up directly on the handle.
+type DB struct {
+	*DatabaseManager
+	Storage *StorageManager
+
+	saveOnClose bool
+	closeOnce   sync.Once
+	closeErr    error
+}
+
+// Option configures Open
+type Option func(*openOptions)
+
+type openOptions struct {
+	format         StorageFormat
+	profile        *Profile
+	lazyLoad       *bool
+	backgroundSync bool
+	saveOnClose    bool
+	readOnly       bool
+}
+
+// WithFormat sets the storage format used for new data (binary by default)
+func WithFormat(format StorageFormat) Option {
+	return func(o *openOptions) {
+		o.format = format
+	}
+}
+
+// WithProfile applies a durability profile (see GetProfile) to the storage manager
+func WithProfile(profile Profile) Option {
+	return func(o *openOptions) {
+		o.profile = &profile
+	}
+}
+
+// WithLazyLoad controls whether collections are loaded on first access
+// rather than when the DB is opened; it overrides the profile's setting
+func WithLazyLoad(enabled bool) Option {
+	return func(o *openOptions) {
+		o.lazyLoad = &enabled
+	}
+}
+
+// WithBackgroundSync enables or disables periodic syncing of data marked
+// dirty through the StorageManager Log* methods (enabled by default)
+func WithBackgroundSync(enabled bool) Option {
+	return func(o *openOptions) {
+		o.backgroundSync = enabled
+	}
+}
+
+// WithSaveOnClose controls whether Close writes every database to disk before
+// shutting down (enabled by default). Changes made directly on collections are
+// not logged to the WAL, so disabling this loses them unless saved explicitly.
+func WithSaveOnClose(enabled bool) Option {
+	return func(o *openOptions) {
+		o.saveOnClose = enabled
+	}
+}
+
+// WithReadOnly opens the data directory without locking it, for reading
+// data that another process may be using (see NewReadOnlyStorageManager).
+// Background sync and saving on close are turned off, and writes through
+// the StorageManager fail with ErrReadOnly.
+func WithReadOnly() Option {
+	return func(o *openOptions) {
+		o.readOnly = true
+	}
+}
+
+// Open opens (or creates) the data directory at path, replays the WAL, loads
+// every database, and starts the background syncer. Call Close when done.
+// It fails with a *LockedError if another process has the directory open.
+func Open(path string, opts ...Option) (*DB, error) {
+	options := openOptions{
+		format:         FormatBinary,
+		backgroundSync: true,
+		saveOnClose:    true,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	newStorage := NewStorageManagerWithWAL
+	if options.readOnly {
+		newStorage = NewReadOnlyStorageManagerWithWAL
+		options.backgroundSync, options.saveOnClose = false, false
+	}
+	var wal WALConfig
+	if options.profile != nil {
+		wal = options.profile.WAL
+	}
+	storage, err := newStorage(path, wal)
+	if err != nil {
+		return nil, err
+	}
+	storage.Format = options.format
+	if options.profile != nil {
+		storage.ApplyProfile(*options.profile)
+	}
+	if options.lazyLoad != nil {
+		storage.LazyLoad = *options.lazyLoad
+	}
+
+	manager, err := storage.LoadAllDatabases()
+	if err != nil {
+		storage.Close()
+		return nil, fmt.Errorf("failed to load databases: %w", err)
+	}
+
+	if options.backgroundSync {
+		storage.StartBackgroundSync(manager)
+	}
+
+	return &DB{
+		DatabaseManager: manager,
+		Storage:         storage,
+		saveOnClose:     options.saveOnClose,
+	}, nil
+}
+
+// Save writes every database to disk and checkpoints the WAL
+func (d *DB) Save() error {
+	// Held across both, so a snapshot never sees the saves without the checkpoint
+	d.Storage.snapshotMu.RLock()
+	defer d.Storage.snapshotMu.RUnlock()
+
+	if err := d.Storage.saveAllDatabasesLocked(d.DatabaseManager); err != nil {
+		return err
+	}
+	return d.Storage.checkpointLocked()
+}
+
+// DeleteDatabase removes a database from memory and from disk
+func (d *DB) DeleteDatabase(name string) error {
+	if !d.DatabaseManager.DeleteDatabase(name) {
+		return fmt.Errorf("database '%s' not found", name)
+	}
+	if err := d.Storage.LogDeleteDatabase(name); err != nil {
+		return err
+	}
+	return d.Storage.DeleteDatabase(name)
+}
+
+// DeleteCollection removes a collection of a database from memory and
+// from disk
+func (d *DB) DeleteCollection(dbName, collName string) error {
+	database := d.DatabaseManager.GetDatabase(dbName)
+	if database == nil {
+		return fmt.Errorf("database '%s' not found", dbName)
+	}
+	coll, err := database.DeleteCollection(collName)
+	if err != nil {
+		return err
+	}
+	// Ephemeral collections were never logged or saved
+	if coll.Ephemeral {
+		return nil
+	}
+	if err := d.Storage.LogDeleteCollection(dbName, collName); err != nil {
+		return err
+	}
+	return d.Storage.DeleteCollection(dbName, collName)
+}
+
+// Close saves all databases (unless disabled with WithSaveOnClose), stops the
+// background syncer, and closes the WAL. It is safe to call more than once.
+func (d *DB) Close() error {
+	d.closeOnce.Do(func() {
+		if d.saveOnClose {
+			if err := d.Save(); err != nil {
+				d.closeErr = fmt.Errorf("failed to save databases: %w", err)
+			}
+		}
+		if err := d.Storage.Close(); err != nil && d.closeErr == nil {
+			d.closeErr = err
+		}
+	})
+	return d.closeErr
+}
@@ -0,0 +1,421 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// JSON Patch operation types (RFC 6902)
+const (
+	PatchOpAdd     = "add"
+	PatchOpRemove  = "remove"
+	PatchOpReplace = "replace"
+	PatchOpMove    = "move"
+	PatchOpCopy    = "copy"
+	PatchOpTest    = "test"
+)
+
+// PatchOperation is a single JSON Patch operation
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// patchUpdate returns the mutation that applies the JSON Patch (RFC 6902)
+// of a $patch update, which must be its only key. The patch is applied
+// atomically: if any operation fails, the document is unchanged.
+func patchUpdate(updates map[string]any) (func(doc *Document) error, error) {
+	if len(updates) != 1 {
+		return nil, fmt.Errorf("%s cannot be combined with other updates", UpdateOpPatch)
+	}
+	var patch []PatchOperation
+	switch value := updates[UpdateOpPatch].(type) {
+	case []PatchOperation:
+		patch = value
+	case []any:
+		var err error
+		if patch, err = ParsePatch(value); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s needs an array of patch operations", UpdateOpPatch)
+	}
+
+	return func(doc *Document) error {
+		var root any = doc.Data
+		for i, op := range patch {
+			var err error
+			root, err = applyPatchOperation(root, op)
+			if err != nil {
+				return fmt.Errorf("patch operation %d (%s %s) failed: %w", i, op.Op, op.Path, err)
+			}
+		}
+
+		data, ok := root.(map[string]any)
+		if !ok {
+			return fmt.Errorf("patch must leave the document as an object")
+		}
+		// A patch that replaced the root keeps the document's own reserved
+		// fields, such as _rev and _attachments
+		for name, value := range doc.Data {
+			if IsReservedField(name) {
+				data[name] = value
+			}
+		}
+		doc.Data = data
+		return nil
+	}, nil
+}
+
+// ParsePatch converts a decoded JSON array into patch operations
+func ParsePatch(raw []any) ([]PatchOperation, error) {
+	patch := make([]PatchOperation, 0, len(raw))
+	for i, item := range raw {
+		opMap, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("patch operation %d is not an object", i)
+		}
+
+		op := PatchOperation{}
+		op.Op, _ = opMap["op"].(string)
+		op.Path, _ = opMap["path"].(string)
+		op.From, _ = opMap["from"].(string)
+		op.Value = opMap["value"]
+		patch = append(patch, op)
+	}
+	return patch, nil
+}
+
+// applyPatchOperation applies one operation and returns the (possibly replaced) root
+func applyPatchOperation(root any, op PatchOperation) (any, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) > 0 && IsReservedField(path[0]) {
+		return nil, &ReservedFieldError{Field: path[0]}
+	}
+
+	root, err = applyPointerOperation(root, path, op)
+	if err != nil {
+		return nil, err
+	}
+
+	// An operation on the root replaces the whole document, which must not
+	// bring reserved fields with it either
+	if len(path) == 0 && op.Op != PatchOpTest {
+		if data, ok := root.(map[string]any); ok {
+			if err := CheckUserFields(data); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return root, nil
+}
+
+// applyPointerOperation applies one operation at path, parsed from op.Path
+func applyPointerOperation(root any, path []string, op PatchOperation) (any, error) {
+	switch op.Op {
+	case PatchOpAdd:
+		return pointerAdd(root, path, deepCopyValue(op.Value))
+
+	case PatchOpRemove:
+		root, _, err := pointerRemove(root, path)
+		return root, err
+
+	case PatchOpReplace:
+		if _, err := pointerGet(root, path); err != nil {
+			return nil, err
+		}
+		if len(path) == 0 {
+			return deepCopyValue(op.Value), nil
+		}
+		root, _, err := pointerRemove(root, path)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(root, path, deepCopyValue(op.Value))
+
+	case PatchOpMove:
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		if len(from) > 0 && IsReservedField(from[0]) {
+			return nil, &ReservedFieldError{Field: from[0]}
+		}
+		if isPointerPrefix(from, path) && len(from) < len(path) {
+			return nil, fmt.Errorf("cannot move a value into one of its children")
+		}
+		root, value, err := pointerRemove(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(root, path, value)
+
+	case PatchOpCopy:
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, err
+		}
+		value, err := pointerGet(root, from)
+		if err != nil {
+			return nil, err
+		}
+		return pointerAdd(root, path, deepCopyValue(value))
+
+	case PatchOpTest:
+		value, err := pointerGet(root, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(value, op.Value) {
+			return nil, fmt.Errorf("test failed: value does not match")
+		}
+		return root, nil
+	}
+
+	return nil, fmt.Errorf("unknown patch operation '%s'", op.Op)
+}
+
+// parsePointer splits a JSON Pointer (RFC 6901) into unescaped tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer '%s'", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		token = strings.ReplaceAll(token, "~1", "/")
+		tokens[i] = strings.ReplaceAll(token, "~0", "~")
+	}
+	return tokens, nil
+}
+
+// isPointerPrefix reports whether prefix is an ancestor of (or equal to) path
+func isPointerPrefix(prefix, path []string) bool {
+	if len(prefix) > len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// pointerGet returns the value at path
+func pointerGet(root any, path []string) (any, error) {
+	current := root
+	for _, token := range path {
+		switch node := current.(type) {
+		case map[string]any:
+			value, exists := node[token]
+			if !exists {
+				return nil, fmt.Errorf("path element '%s' not found", token)
+			}
+			current = value
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			current = node[i]
+		default:
+			return nil, fmt.Errorf("cannot traverse into non-container at '%s'", token)
+		}
+	}
+	return current, nil
+}
+
+// pointerAdd inserts value at path, returning the new root
+func pointerAdd(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := pointerGet(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+		return root, nil
+	case []any:
+		i, err := arrayIndex(last, len(node), true)
+		if err != nil {
+			return nil, err
+		}
+		updated := make([]any, 0, len(node)+1)
+		updated = append(updated, node[:i]...)
+		updated = append(updated, value)
+		updated = append(updated, node[i:]...)
+		return pointerSet(root, path[:len(path)-1], updated)
+	}
+
+	return nil, fmt.Errorf("cannot add to non-container at '%s'", last)
+}
+
+// pointerRemove deletes the value at path, returning the new root and the removed value
+func pointerRemove(root any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, fmt.Errorf("cannot remove the document root")
+	}
+
+	parent, err := pointerGet(root, path[:len(path)-1])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		value, exists := node[last]
+		if !exists {
+			return nil, nil, fmt.Errorf("path element '%s' not found", last)
+		}
+		delete(node, last)
+		return root, value, nil
+	case []any:
+		i, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[i]
+		updated := make([]any, 0, len(node)-1)
+		updated = append(updated, node[:i]...)
+		updated = append(updated, node[i+1:]...)
+		root, err = pointerSet(root, path[:len(path)-1], updated)
+		return root, value, err
+	}
+
+	return nil, nil, fmt.Errorf("cannot remove from non-container at '%s'", last)
+}
+
+// pointerSet replaces the value at an existing path, returning the new root
+func pointerSet(root any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+
+	parent, err := pointerGet(root, path[:len(path)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := path[len(path)-1]
+
+	switch node := parent.(type) {
+	case map[string]any:
+		node[last] = value
+	case []any:
+		i, err := arrayIndex(last, len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = value
+	default:
+		return nil, fmt.Errorf("cannot set value in non-container at '%s'", last)
+	}
+	return root, nil
+}
+
+// arrayIndex parses an array index token; "-" refers to the end when appending
+func arrayIndex(token string, length int, forAdd bool) (int, error) {
+	if token == "-" && forAdd {
+		return length, nil
+	}
+
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || (token != "0" && strings.HasPrefix(token, "0")) {
+		return 0, fmt.Errorf("invalid array index '%s'", token)
+	}
+
+	max := length - 1
+	if forAdd {
+		max = length
+	}
+	if i > max {
+		return 0, fmt.Errorf("array index %d out of bounds", i)
+	}
+	return i, nil
+}
+
+// jsonEqual compares two values using JSON semantics (all numbers compare as float64)
+func jsonEqual(a, b any) bool {
+	if fa, ok := toFloat(a); ok {
+		fb, ok := toFloat(b)
+		return ok && fa == fb
+	}
+	return reflect.DeepEqual(normalizeJSONValue(a), normalizeJSONValue(b))
+}
+
+// normalizeJSONValue converts numbers to float64 throughout a value
+func normalizeJSONValue(value any) any {
+	if f, ok := toFloat(value); ok {
+		return f
+	}
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = normalizeJSONValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = normalizeJSONValue(item)
+		}
+		return out
+	}
+	return value
+}
+
+// MergePatch applies a JSON Merge Patch (RFC 7396) to a document: nested
+// objects are merged recursively and explicit nulls delete the target key
+func (c *Collection) MergePatch(id string, patch map[string]any) error {
+	if err := CheckUserFields(patch); err != nil {
+		return err
+	}
+
+	return c.modify(id, func(doc *Document) error {
+		doc.Data = mergePatchObject(doc.Data, patch)
+		return nil
+	})
+}
+
+// mergePatchObject merges patch into target following RFC 7396
+func mergePatchObject(target, patch map[string]any) map[string]any {
+	if target == nil {
+		target = make(map[string]any)
+	}
+
+	for key, value := range patch {
+		if value == nil {
+			delete(target, key)
+			continue
+		}
+
+		patchObj, ok := value.(map[string]any)
+		if !ok {
+			target[key] = deepCopyValue(value)
+			continue
+		}
+
+		targetObj, _ := target[key].(map[string]any)
+		target[key] = mergePatchObject(targetObj, patchObj)
+	}
+
+	return target
+}
@@ -0,0 +1,120 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// WALSyncPolicy controls when logged writes reach the disk
+type WALSyncPolicy string
+
+const (
+	// WALSyncAlways writes and fsyncs every logged change before the call returns
+	WALSyncAlways WALSyncPolicy = "always"
+	// WALSyncBatch buffers logged changes and writes them every WALFlushInterval
+	// without fsync; a crash can lose the most recent changes
+	WALSyncBatch WALSyncPolicy = "batch"
+	// WALSyncGroup writes and fsyncs every logged change before the call
+	// returns, like WALSyncAlways, but concurrent writers share fsyncs (see
+	// WALManager.AppendEntryGroup)
+	WALSyncGroup WALSyncPolicy = "group"
+)
+
+// Durability modes name the WAL sync policies in configuration and requests
+const (
+	DurabilitySync  = "sync"  // WALSyncAlways
+	DurabilityGroup = "group" // WALSyncGroup
+	DurabilityAsync = "async" // WALSyncBatch
+)
+
+// ParseDurability returns the WAL sync policy of a durability mode
+func ParseDurability(mode string) (WALSyncPolicy, error) {
+	switch mode {
+	case DurabilitySync:
+		return WALSyncAlways, nil
+	case DurabilityGroup:
+		return WALSyncGroup, nil
+	case DurabilityAsync:
+		return WALSyncBatch, nil
+	}
+	return "", fmt.Errorf("unknown durability '%s' (use %s, %s or %s)", mode, DurabilitySync, DurabilityGroup, DurabilityAsync)
+}
+
+// Built-in durability profiles
+const (
+	ProfileDev  = "dev"
+	ProfileProd = "prod"
+
+	// DefaultProfile is used when no profile is selected
+	DefaultProfile = ProfileProd
+)
+
+// Profile bundles the storage settings that trade durability for speed
+type Profile struct {
+	Name string `json:"name"`
+	// WALSync is the WAL fsync policy
+	WALSync WALSyncPolicy `json:"wal_sync"`
+	// WAL sizes and flushes the WAL; it takes effect when the storage
+	// manager is created (NewStorageManagerWithWAL), not in ApplyProfile
+	WAL WALConfig `json:"wal"`
+	// SyncInterval is how often changed collections are written to their data files
+	SyncInterval time.Duration `json:"sync_interval"`
+	// Checkpoint writes them earlier once the WAL grows past its thresholds
+	Checkpoint CheckpointPolicy `json:"checkpoint"`
+	// Compression gzips documents in binary data files
+	Compression bool `json:"compression"`
+	// Verbose logs every background sync, not just failures
+	Verbose bool `json:"verbose"`
+	// LazyLoad reads only collection metadata at startup and loads the
+	// documents of a collection when it is first accessed
+	LazyLoad bool `json:"lazy_load"`
+}
+
+var profiles = map[string]Profile{
+	ProfileDev: {
+		Name:         ProfileDev,
+		WALSync:      WALSyncBatch,
+		SyncInterval: 30 * time.Second,
+		Compression:  false,
+		Verbose:      true,
+		LazyLoad:     false,
+	},
+	ProfileProd: {
+		Name:         ProfileProd,
+		WALSync:      WALSyncAlways,
+		SyncInterval: StorageSyncInterval,
+		Compression:  true,
+		Verbose:      false,
+		LazyLoad:     true,
+	},
+}
+
+// GetProfile returns a built-in profile by name
+func GetProfile(name string) (Profile, bool) {
+	profile, exists := profiles[name]
+	return profile, exists
+}
+
+// ListProfiles returns the names of the built-in profiles, sorted
+func ListProfiles() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ApplyProfile configures the storage manager with a profile's settings.
+// Call it before loading data and starting the background syncer.
+func (sm *StorageManager) ApplyProfile(profile Profile) {
+	sm.WALSync = profile.WALSync
+	sm.CheckpointPolicy = profile.Checkpoint
+	sm.Compression = profile.Compression
+	sm.Verbose = profile.Verbose
+	sm.LazyLoad = profile.LazyLoad
+	if profile.SyncInterval > 0 {
+		sm.syncTicker.Reset(profile.SyncInterval)
+	}
+}
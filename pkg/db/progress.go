@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+	"io"
+)
+
+// ProgressFunc is called as a long operation advances, with how many of its
+// total units of work are done. It is called from the goroutine doing the
+// work, possibly while locks are held, so it must return quickly; a nil
+// ProgressFunc reports nothing.
+type ProgressFunc func(done, total int64)
+
+// Report calls progress if it is set
+func (progress ProgressFunc) Report(done, total int64) {
+	if progress != nil {
+		progress(done, total)
+	}
+}
+
+// progressReader reports the bytes read from a stream to a ProgressFunc
+type progressReader struct {
+	ctx      context.Context
+	r        io.Reader
+	progress ProgressFunc
+	read     int64
+	total    int64
+}
+
+// NewProgressReader returns a reader of r reporting the bytes read of total
+// to progress, e.g. for a file of a known size. Once ctx is canceled, reads
+// fail with ctx.Err(), which stops the work consuming the stream.
+func NewProgressReader(ctx context.Context, r io.Reader, total int64, progress ProgressFunc) io.Reader {
+	return &progressReader{ctx: ctx, r: r, progress: progress, total: total}
+}
+
+func (pr *progressReader) Read(p []byte) (int, error) {
+	if err := pr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	n, err := pr.r.Read(p)
+	if n > 0 {
+		pr.read += int64(n)
+		pr.progress.Report(pr.read, pr.total)
+	}
+	return n, err
+}
@@ -0,0 +1,308 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MessagesCollection is the system collection holding the persisted
+// messages of a database's topics, one document per message named by its ID
+const MessagesCollection = "_messages"
+
+// Fields of a message document
+const (
+	MessageTopicField     = "topic"
+	MessagePayloadField   = "payload"
+	MessageTimestampField = "timestamp"
+)
+
+// MaxStoredMessages caps MessagesCollection: once it holds more messages,
+// the oldest are deleted
+const MaxStoredMessages = 10000
+
+// topicSubscriberBuffer is how many messages a topic subscriber may fall
+// behind before it is dropped
+const topicSubscriberBuffer = 256
+
+// Message is a message published to a topic
+type Message struct {
+	// ID increases with every message published to the database, so
+	// messages sort by ID in the order they were published. Numbering
+	// resumes after the last persisted message on restart, so only the IDs
+	// of persisted messages are never reused.
+	ID        string    `json:"id"`
+	Topic     string    `json:"topic"`
+	Payload   any       `json:"payload"`
+	Timestamp time.Time `json:"timestamp"`
+	// Persisted is set for messages stored in MessagesCollection
+	Persisted bool `json:"persisted"`
+
+	// trimmed are the stored messages deleted to make room for this one
+	trimmed []string
+}
+
+// topics is the message broker of a database
+type topics struct {
+	mu sync.Mutex
+	// seq is the number of the last message published, once loaded
+	seq    uint64
+	loaded bool
+	// subscribers receive the messages of the topic pattern they map to
+	subscribers map[chan *Message]string
+}
+
+// Publish sends payload to the subscribers of topic and returns the
+// message. Subscribers that fell behind are dropped rather than waited for.
+// If persist is set, the message is also stored in MessagesCollection,
+// created on first use, where Messages finds it later. As with other
+// writes, it is stored in memory; log it with StorageManager.LogMessage, as
+// DB.Publish does.
+func (db *Database) Publish(topic string, payload any, persist bool) (*Message, error) {
+	if err := validateTopic(topic); err != nil {
+		return nil, err
+	}
+
+	t := &db.topics
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := db.loadSeqLocked(); err != nil {
+		return nil, err
+	}
+	msg := &Message{
+		ID:        messageID(t.seq + 1),
+		Topic:     topic,
+		Payload:   payload,
+		Timestamp: time.Now().UTC(),
+		Persisted: persist,
+	}
+
+	if persist {
+		coll, _, err := db.systemCollection(MessagesCollection)
+		if err != nil {
+			return nil, err
+		}
+		if err := coll.Insert(&Document{ID: msg.ID, Data: messageFields(msg)}); err != nil {
+			return nil, err
+		}
+		if msg.trimmed, err = trimMessages(coll); err != nil {
+			return nil, err
+		}
+	}
+	t.seq++
+
+	for ch, pattern := range t.subscribers {
+		if !matchTopic(pattern, topic) {
+			continue
+		}
+		select {
+		case ch <- msg:
+		default:
+			// Fell behind: dropped, so it knows to catch up with Messages
+			delete(t.subscribers, ch)
+			close(ch)
+		}
+	}
+	return msg, nil
+}
+
+// Subscribe returns a channel that receives the messages published to
+// topic from now on, in order, until cancel is called. A topic ending in
+// "*" subscribes to every topic starting with the rest of it, and "*"
+// alone to all topics. A subscriber that falls more than
+// topicSubscriberBuffer messages behind is dropped and its channel closed;
+// it can catch up on persisted messages with Messages and subscribe again.
+func (db *Database) Subscribe(topic string) (<-chan *Message, func(), error) {
+	if topic != "*" {
+		if err := validateTopic(strings.TrimSuffix(topic, "*")); err != nil {
+			return nil, nil, err
+		}
+	}
+	ch := make(chan *Message, topicSubscriberBuffer)
+
+	t := &db.topics
+	t.mu.Lock()
+	if t.subscribers == nil {
+		t.subscribers = make(map[chan *Message]string)
+	}
+	t.subscribers[ch] = topic
+	t.mu.Unlock()
+
+	cancel := func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		if _, exists := t.subscribers[ch]; exists {
+			delete(t.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel, nil
+}
+
+// Messages returns up to limit persisted messages of topic published
+// after the message with ID after (from the oldest kept if empty), in
+// order. topic may end in "*" as for Subscribe. A limit of 0 means no limit.
+func (db *Database) Messages(topic, after string, limit int) ([]*Message, error) {
+	coll, err := db.GetCollection(MessagesCollection)
+	if err != nil {
+		return nil, nil
+	}
+
+	query := &Query{Sort: []SortField{{Field: "_id"}}, Limit: limit}
+	switch {
+	case topic == "*":
+	case strings.HasSuffix(topic, "*"):
+		query.Filters = append(query.Filters, QueryFilter{Field: MessageTopicField, Operator: "prefix", Value: strings.TrimSuffix(topic, "*")})
+	default:
+		query.Filters = append(query.Filters, QueryFilter{Field: MessageTopicField, Operator: "eq", Value: topic})
+	}
+	if after != "" {
+		query.Filters = append(query.Filters, QueryFilter{Field: "_id", Operator: "gt", Value: after})
+	}
+
+	docs, err := coll.Find(query)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]*Message, len(docs))
+	for i, doc := range docs {
+		messages[i] = documentMessage(doc)
+	}
+	return messages, nil
+}
+
+// loadSeqLocked resumes the message numbering after the last persisted
+// message (caller must hold topics.mu)
+func (db *Database) loadSeqLocked() error {
+	t := &db.topics
+	if t.loaded {
+		return nil
+	}
+	if coll, err := db.GetCollection(MessagesCollection); err == nil {
+		docs, err := coll.Find(&Query{Sort: []SortField{{Field: "_id", Descending: true}}, Limit: 1})
+		if err != nil {
+			return err
+		}
+		if len(docs) > 0 {
+			t.seq, _ = strconv.ParseUint(docs[0].ID, 10, 64)
+		}
+	}
+	t.loaded = true
+	return nil
+}
+
+// trimMessages deletes the oldest messages beyond MaxStoredMessages and
+// returns their IDs
+func trimMessages(coll *Collection) ([]string, error) {
+	excess := coll.Count() - MaxStoredMessages
+	if excess <= 0 {
+		return nil, nil
+	}
+	docs, err := coll.Find(&Query{Sort: []SortField{{Field: "_id"}}, Limit: excess})
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		if err := coll.Delete(doc.ID); err != nil {
+			return ids, err
+		}
+		ids = append(ids, doc.ID)
+	}
+	return ids, nil
+}
+
+// messageID formats the number of a message so IDs sort as numbers
+func messageID(seq uint64) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+// messageFields returns the document fields of a persisted message
+func messageFields(msg *Message) map[string]any {
+	return map[string]any{
+		MessageTopicField:     msg.Topic,
+		MessagePayloadField:   msg.Payload,
+		MessageTimestampField: msg.Timestamp.Format(time.RFC3339Nano),
+	}
+}
+
+// documentMessage reads a persisted message
+func documentMessage(doc *Document) *Message {
+	topic, _ := doc.Data[MessageTopicField].(string)
+	timestamp, _ := doc.Data[MessageTimestampField].(string)
+	at, _ := time.Parse(time.RFC3339Nano, timestamp)
+	return &Message{
+		ID:        doc.ID,
+		Topic:     topic,
+		Payload:   doc.Data[MessagePayloadField],
+		Timestamp: at,
+		Persisted: true,
+	}
+}
+
+// validateTopic checks the name of a topic messages are published to
+func validateTopic(topic string) error {
+	if topic == "" {
+		return fmt.Errorf("topic cannot be empty")
+	}
+	if strings.Contains(topic, "*") {
+		return fmt.Errorf("topic '%s' cannot contain '*'", topic)
+	}
+	return nil
+}
+
+// matchTopic reports whether a subscription to pattern receives the
+// messages of topic
+func matchTopic(pattern, topic string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(topic, prefix)
+	}
+	return pattern == topic
+}
+
+// Publish is Database.Publish on a database, logging a persisted message
+// to the WAL
+func (d *DB) Publish(database, topic string, payload any, persist bool) (*Message, error) {
+	db := d.GetDatabase(database)
+	if db == nil {
+		return nil, fmt.Errorf("database '%s' not found", database)
+	}
+	if persist {
+		if _, created, err := db.systemCollection(MessagesCollection); err != nil {
+			return nil, err
+		} else if created {
+			if err := d.Storage.LogCreateCollection(db.Name, MessagesCollection, nil); err != nil {
+				return nil, fmt.Errorf("failed to log create collection: %w", err)
+			}
+		}
+	}
+	msg, err := db.Publish(topic, payload, persist)
+	if err != nil {
+		return nil, err
+	}
+	return msg, d.Storage.LogMessage(db, msg)
+}
+
+// LogMessage logs a message published to a database if it was persisted,
+// with the deletions of the oldest messages that made room for it
+func (sm *StorageManager) LogMessage(db *Database, msg *Message) error {
+	if !msg.Persisted {
+		return nil
+	}
+	coll, err := db.GetCollection(MessagesCollection)
+	if err != nil {
+		return err
+	}
+	if err := logStoredDocument(sm, db.Name, coll, msg.ID); err != nil {
+		return err
+	}
+	for _, id := range msg.trimmed {
+		if err := sm.LogDelete(db.Name, MessagesCollection, id); err != nil {
+			return fmt.Errorf("failed to log message deletion: %w", err)
+		}
+	}
+	return nil
+}
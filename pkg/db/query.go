@@ -1,25 +1,108 @@
 package db
 
 import (
+	"container/list"
+	"errors"
 	"fmt"
+	"iter"
+	"reflect"
+	"slices"
 	"strings"
 
-	"github.com/google/uuid"
+	"golang.org/x/text/collate"
 )
 
-// Insert inserts a document into the collection
+// ErrNotFound is matched (with errors.Is) by every *NotFoundError and
+// *CollectionNotFoundError
+var ErrNotFound = errors.New("not found")
+
+// NotFoundError reports a document ID that is not in the collection
+type NotFoundError struct {
+	DocumentID string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("document with ID '%s' not found", e.DocumentID)
+}
+
+func (e *NotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// CollectionNotFoundError reports a collection that is not in the database
+type CollectionNotFoundError struct {
+	Collection string
+}
+
+func (e *CollectionNotFoundError) Error() string {
+	return fmt.Sprintf("collection '%s' does not exist", e.Collection)
+}
+
+func (e *CollectionNotFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// DocumentExistsError reports an insert of a document ID the collection
+// already has. It matches ErrDuplicateKey, as the ID is a unique key.
+type DocumentExistsError struct {
+	DocumentID string
+}
+
+func (e *DocumentExistsError) Error() string {
+	return fmt.Sprintf("document with ID '%s' already exists", e.DocumentID)
+}
+
+func (e *DocumentExistsError) Is(target error) bool {
+	return target == ErrDuplicateKey
+}
+
+// Insert inserts a document into the collection. Fields in the reserved
+// namespace (see ReservedFieldPrefix) are rejected.
 func (c *Collection) Insert(doc *Document) error {
+	if err := CheckUserFields(doc.Data); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.insertLocked(doc, true)
+}
+
+// insert inserts a document without checking for reserved fields or running
+// hooks, for trusted callers such as WAL replay
+func (c *Collection) insert(doc *Document) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	return c.insertLocked(doc, false)
+}
+
+// insertLocked adds a new document (caller must hold mu). A user insert
+// runs the collection's hooks; a replayed one does not, as they ran when
+// it was first written.
+func (c *Collection) insertLocked(doc *Document, user bool) error {
 	// Generate ID if not provided
 	if doc.ID == "" {
-		doc.ID = uuid.New().String()
+		id, err := c.newIDLocked()
+		if err != nil {
+			return err
+		}
+		doc.ID = id
 	}
 
 	// Check if document already exists
 	if _, exists := c.Documents[doc.ID]; exists {
-		return fmt.Errorf("document with ID '%s' already exists", doc.ID)
+		return &DocumentExistsError{DocumentID: doc.ID}
+	}
+
+	if doc.Data == nil {
+		doc.Data = make(map[string]any)
+	}
+	if user {
+		if err := c.runHooksLocked(true, TxInsert, doc.ID, nil, doc); err != nil {
+			return err
+		}
 	}
 
 	// Validate against schema
@@ -29,6 +112,20 @@ func (c *Collection) Insert(doc *Document) error {
 		}
 	}
 
+	if err := c.checkUniqueLocked(doc); err != nil {
+		return err
+	}
+
+	// New documents start at revision 1; replayed ones keep theirs
+	if _, exists := doc.Data[RevField]; !exists {
+		doc.setRev(1)
+	}
+
+	// Move large values to blob files (in place, so callers log the small form)
+	if err := c.spillLocked(doc); err != nil {
+		return fmt.Errorf("failed to store blobs: %w", err)
+	}
+
 	// Add document
 	c.Documents[doc.ID] = doc
 
@@ -38,20 +135,39 @@ func (c *Collection) Insert(doc *Document) error {
 		return fmt.Errorf("failed to update indexes: %w", err)
 	}
 
+	c.noteChanged(doc.ID)
+	if user {
+		if err := c.runHooksLocked(false, TxInsert, doc.ID, nil, doc); err != nil {
+			c.restoreLocked(doc.ID, nil)
+			return err
+		}
+	}
 	return nil
 }
 
 // FindByID finds a document by ID
 func (c *Collection) FindByID(id string) (*Document, error) {
 	c.mu.RLock()
-	defer c.mu.RUnlock()
-
 	doc, exists := c.Documents[id]
 	if !exists {
-		return nil, fmt.Errorf("document with ID '%s' not found", id)
+		c.mu.RUnlock()
+		return nil, &NotFoundError{DocumentID: id}
+	}
+	loaded, err := c.loadLocked(doc)
+	if err != nil {
+		c.mu.RUnlock()
+		return nil, err
 	}
+	result := loaded.Clone()
+	c.mu.RUnlock()
 
-	return doc.Clone(), nil
+	// A document read back from storage is kept in memory again
+	if doc.evicted {
+		c.promote(loaded)
+	} else {
+		c.touch(id)
+	}
+	return result, nil
 }
 
 // Find finds documents matching a query
@@ -59,64 +175,122 @@ func (c *Collection) Find(query *Query) ([]*Document, error) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
-	results := make([]*Document, 0)
+	matches, _, err := c.findLocked(query)
+	if err != nil {
+		return nil, err
+	}
 
-	// If no filters, return all documents
-	if len(query.Filters) == 0 {
-		for _, doc := range c.Documents {
-			results = append(results, doc.Clone())
-		}
-	} else {
-		// Try to use index for first filter if possible
-		firstFilter := query.Filters[0]
-		if firstFilter.Operator == "eq" {
-			// Check if there's an index for this field
-			var candidateDocs []*Document
-			indexFound := false
-
-			for _, idx := range c.Indexes {
-				if idx.FieldName == firstFilter.Field {
-					docID, found := idx.Find(firstFilter.Value)
-					if found {
-						if doc, exists := c.Documents[docID]; exists {
-							candidateDocs = []*Document{doc}
-							indexFound = true
-							break
-						}
-					} else {
-						// Index exists but no match found
-						return results, nil
-					}
-				}
-			}
+	results := make([]*Document, len(matches))
+	for i, doc := range matches {
+		results[i] = doc.Clone()
+		c.touch(doc.ID)
+	}
+	return results, nil
+}
 
-			if !indexFound {
-				// No index, scan all documents
-				for _, doc := range c.Documents {
-					candidateDocs = append(candidateDocs, doc)
-				}
+// FindIter is like Find but yields the results one at a time, so only the
+// IDs of the matching documents are held in memory rather than copies of
+// the documents. The collection is not locked between documents: a document
+// that is deleted or stops matching the query during iteration is skipped,
+// and writes made by the loop body are allowed.
+func (c *Collection) FindIter(query *Query) (iter.Seq[*Document], error) {
+	c.mu.RLock()
+	matches, col, err := c.findLocked(query)
+	ids := make([]string, len(matches))
+	for i, doc := range matches {
+		ids[i] = doc.ID
+	}
+	c.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return func(yield func(*Document) bool) {
+		for _, id := range ids {
+			c.mu.RLock()
+			doc, exists := c.Documents[id]
+			if exists {
+				// A body that cannot be read back is skipped like a deleted document
+				doc, _ = c.loadLocked(doc)
 			}
+			if doc != nil && matchesAllFilters(doc, query.Filters, col) && query.Filter.matches(doc, col) {
+				doc = doc.Clone()
+			} else {
+				doc = nil
+			}
+			c.mu.RUnlock()
 
-			// Apply all filters
-			for _, doc := range candidateDocs {
-				if matchesAllFilters(doc, query.Filters) {
-					results = append(results, doc.Clone())
-				}
+			if doc != nil && !yield(doc) {
+				return
 			}
+		}
+	}, nil
+}
+
+// findLocked returns the stored (not cloned) documents matching a query in
+// result order, and the collator used (caller must hold mu)
+func (c *Collection) findLocked(query *Query) ([]*Document, *collate.Collator, error) {
+	col, err := c.collatorFor(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, filter := range query.Filters {
+		if err := validateQueryFilter(filter); err != nil {
+			return nil, nil, err
+		}
+	}
+	if query.Filter != nil {
+		if err := query.Filter.Validate(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	results := make([]*Document, 0)
+
+	// Try to use an index for the first flat filter, then for the sort order.
+	// Documents read from an ordered index are already sorted.
+	var candidateDocs []*Document
+	indexed, sorted := false, false
+	if col == nil && len(query.Filters) > 0 {
+		candidateDocs, indexed = c.indexCandidatesLocked(query.Filters[0])
+		sorted = indexed && isRangeOperator(query.Filters[0].Operator) &&
+			len(query.Sort) == 1 && query.Sort[0].Field == query.Filters[0].Field
+	}
+	if !indexed {
+		if idx := c.sortIndexLocked(query.Sort, col); idx != nil {
+			candidateDocs = c.docsInIndexOrderLocked(idx)
+			sorted = true
 		} else {
-			// Non-equality first filter, scan all documents
+			// No index, scan all documents
 			for _, doc := range c.Documents {
-				if matchesAllFilters(doc, query.Filters) {
-					results = append(results, doc.Clone())
-				}
+				candidateDocs = append(candidateDocs, doc)
 			}
 		}
 	}
 
+	// Apply all filters
+	for _, doc := range candidateDocs {
+		doc, err := c.loadLocked(doc)
+		if err != nil {
+			return nil, nil, err
+		}
+		if matchesAllFilters(doc, query.Filters, col) && query.Filter.matches(doc, col) {
+			results = append(results, doc)
+		}
+	}
+
+	if sorted {
+		if query.Sort[0].Descending {
+			slices.Reverse(results)
+		}
+	} else if len(query.Sort) > 0 {
+		sortDocuments(results, query.Sort, col)
+	}
+
 	// Apply skip and limit
 	if query.Skip > 0 {
 		if query.Skip >= len(results) {
-			return []*Document{}, nil
+			return []*Document{}, col, nil
 		}
 		results = results[query.Skip:]
 	}
@@ -125,56 +299,172 @@ func (c *Collection) Find(query *Query) ([]*Document, error) {
 		results = results[:query.Limit]
 	}
 
-	return results, nil
+	return results, col, nil
 }
 
-// Update updates a document
+// Update updates a document. updates either holds field values to set or,
+// if its keys are update operators such as "$inc" (see UpdateOpInc),
+// operators applied atomically under the collection lock. Fields are named
+// by paths such as "settings.theme" or "items.0.qty" (see FieldPathSeparator).
+// A "$patch" key applies a JSON Patch to the document instead (see
+// UpdateOpPatch). Fields in the reserved namespace are rejected.
 func (c *Collection) Update(id string, updates map[string]any) error {
+	mutate, err := userUpdate(updates)
+	if err != nil {
+		return err
+	}
+	return c.modify(id, mutate)
+}
+
+// userUpdate checks updates passed to Update and returns the mutation that
+// applies them
+func userUpdate(updates map[string]any) (func(doc *Document) error, error) {
+	if _, ok := updates[UpdateOpPatch]; ok {
+		return patchUpdate(updates)
+	}
+	if HasUpdateOperators(updates) {
+		if err := validateUpdateOperators(updates); err != nil {
+			return nil, err
+		}
+		return func(doc *Document) error {
+			return applyUpdateOperators(doc.Data, updates)
+		}, nil
+	}
+	if err := CheckUserFields(updates); err != nil {
+		return nil, err
+	}
+	return setFields(updates)
+}
+
+// setFields returns a mutation that sets the field paths of updates
+func setFields(updates map[string]any) (func(doc *Document) error, error) {
+	if err := checkPathConflicts(sortedKeys(updates)); err != nil {
+		return nil, err
+	}
+	return func(doc *Document) error {
+		for key, value := range updates {
+			if key == "_id" {
+				return fmt.Errorf("cannot update _id field")
+			}
+			if err := setPath(doc.Data, key, value); err != nil {
+				return fmt.Errorf("field '%s': %w", key, err)
+			}
+		}
+		return nil
+	}, nil
+}
+
+// modify applies mutate to a copy of the document, validates the result and
+// swaps it in, leaving the stored document untouched on any error
+func (c *Collection) modify(id string, mutate func(doc *Document) error) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	doc, exists := c.Documents[id]
+	return c.modifyLocked(id, mutate)
+}
+
+// modifyLocked is modify for callers that already hold mu
+func (c *Collection) modifyLocked(id string, mutate func(doc *Document) error) error {
+	return c.replaceLocked(id, mutate, true)
+}
+
+// replaceLocked implements modifyLocked. A user write increments the
+// document's revision and runs the collection's hooks; a replayed one
+// leaves the revision as mutate set it.
+func (c *Collection) replaceLocked(id string, mutate func(doc *Document) error, user bool) error {
+	stored, exists := c.Documents[id]
 	if !exists {
-		return fmt.Errorf("document with ID '%s' not found", id)
+		return &NotFoundError{DocumentID: id}
+	}
+	oldDoc, err := c.loadLocked(stored)
+	if err != nil {
+		return err
 	}
 
-	oldDoc := doc.Clone()
-
-	// Apply updates
-	for key, value := range updates {
-		if key == "_id" {
-			return fmt.Errorf("cannot update _id field")
+	doc := oldDoc.DeepClone()
+	if c.blobs != nil {
+		// Mutations and validation see the real values of spilled fields
+		if err := resolveBlobsIn(c.blobs, doc.Data); err != nil {
+			return fmt.Errorf("failed to load blobs: %w", err)
+		}
+	}
+	if err := mutate(doc); err != nil {
+		return err
+	}
+	if user {
+		doc.setRev(oldDoc.Rev() + 1)
+		if err := c.runHooksLocked(true, TxUpdate, id, oldDoc, doc); err != nil {
+			return err
 		}
-		doc.Data[key] = value
 	}
 
 	// Validate against schema
 	if c.Schema != nil {
 		if err := c.Schema.ValidateDocument(doc); err != nil {
-			// Rollback
-			c.Documents[id] = oldDoc
 			return fmt.Errorf("schema validation failed: %w", err)
 		}
 	}
 
+	if err := c.checkUniqueLocked(doc); err != nil {
+		return err
+	}
+
+	if err := c.spillLocked(doc); err != nil {
+		return fmt.Errorf("failed to store blobs: %w", err)
+	}
+
 	// Update indexes
 	if err := c.updateIndexes(oldDoc, doc); err != nil {
 		// Rollback
-		c.Documents[id] = oldDoc
+		c.updateIndexes(doc, oldDoc)
 		return fmt.Errorf("failed to update indexes: %w", err)
 	}
 
+	c.Documents[id] = doc
+	c.forget(id)
+	c.noteChanged(id)
+	if user {
+		if err := c.runHooksLocked(false, TxUpdate, id, oldDoc, doc); err != nil {
+			c.restoreLocked(id, stored)
+			return err
+		}
+	}
 	return nil
 }
 
 // Delete deletes a document by ID
 func (c *Collection) Delete(id string) error {
+	return c.deleteByID(id, true)
+}
+
+// delete deletes a document without running hooks, for trusted callers
+// such as WAL replay
+func (c *Collection) delete(id string) error {
+	return c.deleteByID(id, false)
+}
+
+func (c *Collection) deleteByID(id string, user bool) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	doc, exists := c.Documents[id]
 	if !exists {
-		return fmt.Errorf("document with ID '%s' not found", id)
+		return &NotFoundError{DocumentID: id}
+	}
+	return c.deleteLocked(doc, user)
+}
+
+// deleteLocked removes a stored document (caller must hold mu). A user
+// delete runs the collection's hooks; a replayed one does not.
+func (c *Collection) deleteLocked(stored *Document, user bool) error {
+	doc, err := c.loadLocked(stored)
+	if err != nil {
+		return err
+	}
+	if user {
+		if err := c.runHooksLocked(true, TxDelete, doc.ID, doc, nil); err != nil {
+			return err
+		}
 	}
 
 	// Update indexes
@@ -182,10 +472,49 @@ func (c *Collection) Delete(id string) error {
 		return fmt.Errorf("failed to update indexes: %w", err)
 	}
 
-	delete(c.Documents, id)
+	delete(c.Documents, doc.ID)
+	c.forget(doc.ID)
+	c.noteChanged(doc.ID)
+	if user {
+		if err := c.runHooksLocked(false, TxDelete, doc.ID, doc, nil); err != nil {
+			c.restoreLocked(doc.ID, stored)
+			return err
+		}
+	}
 	return nil
 }
 
+// Truncate removes every document of the collection and the entries of
+// its indexes, keeping the index definitions, and returns how many
+// documents were removed. Unlike deleting them one by one, it runs no
+// hooks and is logged as a single WAL entry (see
+// StorageManager.LogTruncateCollection); the next save starts the
+// collection's stored files over instead of appending to them.
+func (c *Collection) Truncate() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	count := len(c.Documents)
+	c.Documents = make(map[string]*Document)
+	for _, idx := range c.Indexes {
+		idx.rebuild(nil)
+	}
+	if c.cache != nil {
+		c.cache.mu.Lock()
+		c.cache.lru.Init()
+		c.cache.entries = make(map[string]*list.Element)
+		c.cache.used = 0
+		c.cache.mu.Unlock()
+	}
+
+	// The next save writes a base segment without the removed documents
+	c.segments.mu.Lock()
+	c.segments.changed = nil
+	c.segments.mu.Unlock()
+	c.truncated.Store(true)
+	return count
+}
+
 // Count returns the number of documents in the collection
 func (c *Collection) Count() int {
 	c.mu.RLock()
@@ -193,10 +522,11 @@ func (c *Collection) Count() int {
 	return len(c.Documents)
 }
 
-// matchesAllFilters checks if a document matches all filters
-func matchesAllFilters(doc *Document, filters []QueryFilter) bool {
+// matchesAllFilters checks if a document matches all filters. String
+// values are compared with col when it is not nil.
+func matchesAllFilters(doc *Document, filters []QueryFilter, col *collate.Collator) bool {
 	for _, filter := range filters {
-		if !matchesFilter(doc, filter) {
+		if !matchesFilter(doc, filter, col) {
 			return false
 		}
 	}
@@ -204,7 +534,7 @@ func matchesAllFilters(doc *Document, filters []QueryFilter) bool {
 }
 
 // matchesFilter checks if a document matches a single filter
-func matchesFilter(doc *Document, filter QueryFilter) bool {
+func matchesFilter(doc *Document, filter QueryFilter, col *collate.Collator) bool {
 	value, exists := doc.GetValue(filter.Field)
 	if !exists {
 		return false
@@ -212,42 +542,204 @@ func matchesFilter(doc *Document, filter QueryFilter) bool {
 
 	switch filter.Operator {
 	case "eq":
-		return fmt.Sprintf("%v", value) == fmt.Sprintf("%v", filter.Value)
+		return valuesEqual(value, filter.Value, col)
 	case "ne":
-		return fmt.Sprintf("%v", value) != fmt.Sprintf("%v", filter.Value)
+		return !valuesEqual(value, filter.Value, col)
 	case "gt":
-		return compareValues(value, filter.Value) > 0
+		cmp, ok := compareValues(value, filter.Value, col)
+		return ok && cmp > 0
 	case "gte":
-		return compareValues(value, filter.Value) >= 0
+		cmp, ok := compareValues(value, filter.Value, col)
+		return ok && cmp >= 0
 	case "lt":
-		return compareValues(value, filter.Value) < 0
+		cmp, ok := compareValues(value, filter.Value, col)
+		return ok && cmp < 0
 	case "lte":
-		return compareValues(value, filter.Value) <= 0
-	case "in":
+		cmp, ok := compareValues(value, filter.Value, col)
+		return ok && cmp <= 0
+	case "in", "nin":
 		// Check if value is in the filter.Value array
-		if arr, ok := filter.Value.([]any); ok {
-			valueStr := fmt.Sprintf("%v", value)
+		found := false
+		if arr, ok := filterValues(filter.Value); ok {
 			for _, item := range arr {
-				if fmt.Sprintf("%v", item) == valueStr {
-					return true
+				if valuesEqual(value, item, col) {
+					found = true
+					break
 				}
 			}
 		}
-		return false
+		return found == (filter.Operator == "in")
+	case "regex":
+		s, isString := value.(string)
+		re, err := compileRegex(filter.Value)
+		return isString && err == nil && re.MatchString(s)
+	case "prefix":
+		s, isString := value.(string)
+		pattern, ok := filter.Value.(string)
+		return isString && ok && strings.HasPrefix(s, pattern)
+	case "suffix":
+		s, isString := value.(string)
+		pattern, ok := filter.Value.(string)
+		return isString && ok && strings.HasSuffix(s, pattern)
+	case "contains":
+		s, isString := value.(string)
+		pattern, ok := filter.Value.(string)
+		return isString && ok && strings.Contains(s, pattern)
 	}
 
 	return false
 }
 
-// compareValues compares two values (simple numeric/string comparison)
-func compareValues(a, b any) int {
-	aStr := fmt.Sprintf("%v", a)
-	bStr := fmt.Sprintf("%v", b)
-	return strings.Compare(aStr, bStr)
+// filterValues returns the elements of an in/nin filter value, which may be
+// any slice or array
+func filterValues(value any) ([]any, bool) {
+	if arr, ok := value.([]any); ok {
+		return arr, true
+	}
+
+	v := reflect.ValueOf(value)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, false
+	}
+	arr := make([]any, v.Len())
+	for i := range arr {
+		arr[i] = v.Index(i).Interface()
+	}
+	return arr, true
+}
+
+// indexCandidatesLocked returns the documents an index yields for an eq, in
+// or range filter; indexed is false when no index can answer it (caller must
+// hold mu). Range filters need an ordered index and yield documents in
+// ascending order of the field.
+func (c *Collection) indexCandidatesLocked(filter QueryFilter) (docs []*Document, indexed bool) {
+	if isRangeOperator(filter.Operator) {
+		for _, idx := range c.Indexes {
+			if idx.FieldName != filter.Field {
+				continue
+			}
+			if ids, ok := idx.Range(filter.Operator, filter.Value); ok {
+				return c.documentsLocked(ids), true
+			}
+		}
+		return nil, false
+	}
+
+	var values []any
+	switch filter.Operator {
+	case "eq":
+		values = []any{filter.Value}
+	case "in":
+		if values, indexed = filterValues(filter.Value); !indexed {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+
+	for _, idx := range c.Indexes {
+		if idx.FieldName != filter.Field {
+			continue
+		}
+
+		seen := make(map[string]bool)
+		for _, value := range values {
+			for _, docID := range idx.Find(value) {
+				if seen[docID] {
+					continue
+				}
+				seen[docID] = true
+				if doc, exists := c.Documents[docID]; exists {
+					docs = append(docs, doc)
+				}
+			}
+		}
+		return docs, true
+	}
+	return nil, false
+}
+
+// sortIndexLocked returns an ordered index that yields documents in the
+// order of sort, or nil. Only single-field sorts without a collation qualify.
+func (c *Collection) sortIndexLocked(sort []SortField, col *collate.Collator) *Index {
+	if len(sort) != 1 || col != nil {
+		return nil
+	}
+	for _, idx := range c.Indexes {
+		if idx.Type == IndexOrdered && idx.FieldName == sort[0].Field {
+			return idx
+		}
+	}
+	return nil
+}
+
+// docsInIndexOrderLocked returns all documents in ascending order of the
+// field of an ordered index, those missing the field first
+func (c *Collection) docsInIndexOrderLocked(idx *Index) []*Document {
+	ordered := idx.orderedIDs()
+	indexed := make(map[string]bool, len(ordered))
+	for _, id := range ordered {
+		indexed[id] = true
+	}
+
+	docs := make([]*Document, 0, len(c.Documents))
+	for id, doc := range c.Documents {
+		if !indexed[id] {
+			docs = append(docs, doc)
+		}
+	}
+	return append(docs, c.documentsLocked(ordered)...)
+}
+
+// documentsLocked looks up documents by ID, skipping unknown IDs
+func (c *Collection) documentsLocked(ids []string) []*Document {
+	docs := make([]*Document, 0, len(ids))
+	for _, id := range ids {
+		if doc, exists := c.Documents[id]; exists {
+			docs = append(docs, doc)
+		}
+	}
+	return docs
+}
+
+func isRangeOperator(op string) bool {
+	return op == "gt" || op == "gte" || op == "lt" || op == "lte"
+}
+
+// valuesEqual compares two values by their string form, using col for strings
+func valuesEqual(a, b any, col *collate.Collator) bool {
+	if col != nil {
+		if x, ok := a.(string); ok {
+			if y, ok := b.(string); ok {
+				return col.CompareString(x, y) == 0
+			}
+		}
+	}
+	return fmt.Sprintf("%v", a) == fmt.Sprintf("%v", b)
+}
+
+// compareValues orders two values of the same type: numbers numerically,
+// strings with col or byte-wise. ok is false when the types differ, so a
+// range filter never matches a value of another type.
+func compareValues(a, b any, col *collate.Collator) (cmp int, ok bool) {
+	if sortRank(a) != sortRank(b) {
+		return 0, false
+	}
+	return compareSortValues(a, b, col), true
 }
 
 // CreateCollection creates a new collection in the database
 func (db *Database) CreateCollection(name string, schema *Schema) error {
+	return db.createCollection(name, schema, false)
+}
+
+// CreateEphemeralCollection creates a collection that lives only in memory
+// (see Collection.Ephemeral)
+func (db *Database) CreateEphemeralCollection(name string, schema *Schema) error {
+	return db.createCollection(name, schema, true)
+}
+
+func (db *Database) createCollection(name string, schema *Schema, ephemeral bool) error {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
@@ -261,33 +753,46 @@ func (db *Database) CreateCollection(name string, schema *Schema) error {
 		}
 	}
 
-	db.Collections[name] = NewCollection(name, schema)
+	coll := NewCollection(name, schema)
+	coll.Ephemeral = ephemeral
+	for _, fieldName := range schema.uniqueFields() {
+		idx := NewIndex(UniqueIndexName(fieldName), fieldName)
+		idx.Unique = true
+		coll.Indexes[idx.Name] = idx
+	}
+	db.Collections[name] = coll
 	return nil
 }
 
-// DropCollection drops a collection from the database
-func (db *Database) DropCollection(name string) error {
+// DeleteCollection removes a collection and its documents from the
+// database and returns it. Its files are left on disk; see
+// StorageManager.DeleteCollection.
+func (db *Database) DeleteCollection(name string) (*Collection, error) {
 	db.mu.Lock()
 	defer db.mu.Unlock()
 
-	if _, exists := db.Collections[name]; !exists {
-		return fmt.Errorf("collection '%s' does not exist", name)
+	coll, exists := db.Collections[name]
+	if !exists {
+		return nil, &CollectionNotFoundError{Collection: name}
 	}
 
 	delete(db.Collections, name)
-	return nil
+	return coll, nil
 }
 
-// GetCollection gets a collection by name
+// GetCollection gets a collection by name, loading its documents first if
+// the database was loaded lazily and this is the first access
 func (db *Database) GetCollection(name string) (*Collection, error) {
 	db.mu.RLock()
-	defer db.mu.RUnlock()
-
 	coll, exists := db.Collections[name]
+	db.mu.RUnlock()
 	if !exists {
-		return nil, fmt.Errorf("collection '%s' does not exist", name)
+		return nil, &CollectionNotFoundError{Collection: name}
 	}
 
+	if coll.unloaded {
+		return db.loadCollection(name)
+	}
 	return coll, nil
 }
 
@@ -0,0 +1,374 @@
+package db
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ErrReplica is returned by writes to a storage manager that follows a
+// leader (see StorageManager.SetReplica); changes come from the leader only
+var ErrReplica = errors.New("storage is a read replica; write to the leader")
+
+// ErrWALTruncated reports that entries a follower needs were already
+// removed from the leader's WAL by retention (see WALManager.OldestOffset)
+var ErrWALTruncated = errors.New("WAL entries are no longer retained")
+
+// replicaSnapshotFile describes the snapshot in an archive written by
+// WriteSnapshot
+const replicaSnapshotFile = "snapshot.json"
+
+// walSubscriberBuffer is how many entries a WAL subscriber may fall behind
+// before it is dropped
+const walSubscriberBuffer = 4096
+
+// Subscribe returns a channel that receives every entry written to the WAL
+// file from now on, in offset order, until cancel is called. A subscriber
+// that falls more than walSubscriberBuffer entries behind is dropped and
+// its channel closed; it can catch up with ReadFrom and subscribe again.
+func (wm *WALManager) Subscribe() (<-chan *WALEntry, func()) {
+	ch := make(chan *WALEntry, walSubscriberBuffer)
+
+	wm.mu.Lock()
+	if wm.subscribers == nil {
+		wm.subscribers = make(map[chan *WALEntry]struct{})
+	}
+	wm.subscribers[ch] = struct{}{}
+	wm.mu.Unlock()
+
+	cancel := func() {
+		wm.mu.Lock()
+		defer wm.mu.Unlock()
+		if _, exists := wm.subscribers[ch]; exists {
+			delete(wm.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, cancel
+}
+
+// publishLocked hands written entries to the subscribers (caller must hold mu)
+func (wm *WALManager) publishLocked(entries []*WALEntry) {
+	for ch := range wm.subscribers {
+		for _, entry := range entries {
+			select {
+			case ch <- entry:
+				continue
+			default:
+			}
+			// Too far behind: drop it rather than block the writers
+			delete(wm.subscribers, ch)
+			close(ch)
+			break
+		}
+	}
+}
+
+// NextOffset returns the offset the next appended entry gets
+func (wm *WALManager) NextOffset() uint64 {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+	return wm.currentOffset
+}
+
+// OldestOffset returns the offset at which the oldest retained WAL file
+// starts. Entries before it were removed by retention (or archived), so
+// ReadFrom cannot return them.
+func (wm *WALManager) OldestOffset() (uint64, error) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	files, err := wm.getWALFilesLocked()
+	if err != nil {
+		return 0, err
+	}
+	if len(files) == 0 {
+		return wm.currentOffset, nil
+	}
+	return walFileOffset(files[0])
+}
+
+// walFileOffset returns the offset a WAL file was created at, from its
+// name (see walFileName)
+func walFileOffset(name string) (uint64, error) {
+	base := strings.TrimSuffix(name, ".log")
+	offset, err := strconv.ParseUint(base[strings.LastIndex(base, "-")+1:], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid WAL file name %s", name)
+	}
+	return offset, nil
+}
+
+// AppendReplicated logs an entry streamed from a leader under the leader's
+// offset and timestamp. It is batched like AppendEntry: a follower that
+// crashes before it is written fetches it from the leader again.
+func (wm *WALManager) AppendReplicated(entry *WALEntry) error {
+	if wm.readOnly {
+		return ErrReadOnly
+	}
+
+	wm.batchMu.Lock()
+	defer wm.batchMu.Unlock()
+
+	wm.mu.Lock()
+	if entry.Offset < wm.currentOffset {
+		wm.mu.Unlock()
+		return fmt.Errorf("WAL entry at offset %d is already logged", entry.Offset)
+	}
+	wm.currentOffset = entry.Offset + 1
+	wm.countAppendLocked()
+	wm.mu.Unlock()
+
+	wm.batch = append(wm.batch, entry)
+	if len(wm.batch) >= wm.config.BatchSize {
+		return wm.flushBatchLocked()
+	}
+	return nil
+}
+
+// SetReplica makes the storage manager follow a leader, or, with false,
+// promotes it to take writes itself. A replica refuses Log* calls with
+// ErrReplica and does not expire documents; it gets every change, TTL
+// deletions included, through ApplyReplicated.
+func (sm *StorageManager) SetReplica(replica bool) {
+	sm.replica.Store(replica)
+}
+
+// IsReplica reports whether the storage manager follows a leader
+func (sm *StorageManager) IsReplica() bool {
+	return sm.replica.Load()
+}
+
+// ApplyReplicated applies an entry streamed from a leader's WAL: it changes
+// the databases in memory like a replayed entry, is logged to this WAL
+// under the leader's offset, and is saved by the background syncer. An
+// entry at an offset this WAL already has is ignored, so a stream can be
+// resumed from any earlier offset. An entry that cannot be applied is not
+// logged and returns the error, so the stream stops there instead of
+// leaving the replica without the change; applying it again is safe.
+func (sm *StorageManager) ApplyReplicated(entry *WALEntry) error {
+	if sm.ReadOnly {
+		return ErrReadOnly
+	}
+	if sm.dbManager == nil {
+		return fmt.Errorf("no databases are loaded")
+	}
+	if entry.Offset < sm.WAL.NextOffset() {
+		return nil // applied before the stream was resumed
+	}
+
+	change, applyErr := applyEntry(entry, sm.dbManager)
+
+	// Marked dirty before the entry is logged, so a checkpoint past the
+	// entry never comes before the save of its change. A transaction that
+	// failed partway is saved as far as it got.
+	if change != nil {
+		switch {
+		case change.deleted:
+			if err := sm.DeleteDatabase(change.database); err != nil {
+				return fmt.Errorf("failed to delete database '%s': %w", change.database, err)
+			}
+		case change.whole:
+			sm.MarkDirty(change.database, "")
+		case len(change.deletedCollections) > 0:
+			for _, collName := range change.deletedCollections {
+				if err := sm.DeleteCollection(change.database, collName); err != nil {
+					return fmt.Errorf("failed to delete collection '%s' of database '%s': %w", collName, change.database, err)
+				}
+			}
+		default:
+			for _, collName := range change.collections {
+				sm.MarkDirty(change.database, collName)
+			}
+		}
+	}
+
+	if applyErr != nil && !errors.Is(applyErr, errReplaySkip) {
+		return fmt.Errorf("%s on %s: %w", entry.Operation, entryTarget(entry), applyErr)
+	}
+	return sm.WAL.AppendReplicated(entry)
+}
+
+// WriteSnapshot takes a snapshot of the root directory (see Snapshot) and
+// writes it to w as a gzipped tar archive, from which InstallSnapshot sets
+// up a replica. Saves only wait while the snapshot is taken, not while it
+// is written to w. The replica follows the WAL from the returned WALOffset.
+func (sm *StorageManager) WriteSnapshot(w io.Writer) (*SnapshotInfo, error) {
+	dir, err := stagingDir(sm.RootDir, "snapshot")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	info, err := sm.Snapshot(dir)
+	if err != nil {
+		return nil, err
+	}
+	info.Dir = ""
+	data, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	if err := writeTarFile(tw, replicaSnapshotFile, data, info.Created); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if err := addTarDir(tw, dir, entry.Name()); err != nil {
+			return nil, fmt.Errorf("failed to write snapshot: %w", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return info, nil
+}
+
+// InstallSnapshot unpacks an archive written by WriteSnapshot into rootDir,
+// and its WAL into config.Dir if set, so that opening rootDir loads the
+// snapshot and replays its WAL. rootDir must hold no data (see
+// IsEmptyRoot); it is locked while the files are moved in.
+func InstallSnapshot(r io.Reader, rootDir string, config WALConfig) (*SnapshotInfo, error) {
+	walDir, err := walDirOf(rootDir, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(rootDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create root directory: %w", err)
+	}
+	lock, err := lockDir(rootDir)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Close()
+	if walDir != rootDir {
+		if err := os.MkdirAll(walDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create WAL directory: %w", err)
+		}
+		walLock, err := lockDir(walDir)
+		if err != nil {
+			return nil, err
+		}
+		defer walLock.Close()
+	}
+
+	empty, err := IsEmptyRoot(rootDir, config)
+	if err != nil {
+		return nil, err
+	}
+	if !empty {
+		return nil, fmt.Errorf("cannot install a snapshot into %s, which already holds data", rootDir)
+	}
+
+	staging, err := stagingDir(rootDir, "snapshot")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	info := &SnapshotInfo{}
+	found, err := extractArchive(r, staging, "snapshot", replicaSnapshotFile, info)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("not a snapshot archive: %s is missing", replicaSnapshotFile)
+	}
+
+	entries, err := os.ReadDir(staging)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		src := filepath.Join(staging, name)
+		if entry.IsDir() {
+			if err := moveDir(src, filepath.Join(rootDir, name)); err != nil {
+				return nil, fmt.Errorf("failed to move database '%s' into place: %w", name, err)
+			}
+			continue
+		}
+
+		dst := filepath.Join(rootDir, name)
+		if strings.HasPrefix(name, WALFilePrefix) || name == WALCheckpointFile {
+			dst = filepath.Join(walDir, name)
+		}
+		if os.Rename(src, dst) != nil {
+			if err := copyFile(src, dst, 0644); err != nil {
+				return nil, fmt.Errorf("failed to move %s into place: %w", name, err)
+			}
+		}
+	}
+	for _, dir := range []string{rootDir, walDir} {
+		if err := syncDir(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	info.Dir = rootDir
+	return info, nil
+}
+
+// IsEmptyRoot reports whether rootDir, with its WAL in config.Dir if set,
+// holds no data: no databases, no logged WAL entries and no checkpoint past
+// the first entry. A directory that does not exist is empty.
+func IsEmptyRoot(rootDir string, config WALConfig) (bool, error) {
+	walDir, err := walDirOf(rootDir, config)
+	if err != nil {
+		return false, err
+	}
+
+	entries, err := os.ReadDir(rootDir)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read root directory: %w", err)
+	}
+	for _, entry := range entries {
+		// Hidden directories are not databases (see LoadAllDatabases)
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			return false, nil
+		}
+	}
+
+	entries, err = os.ReadDir(walDir)
+	if err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("failed to read WAL directory: %w", err)
+	}
+	for _, entry := range entries {
+		switch {
+		case entry.Name() == WALCheckpointFile:
+			data, err := os.ReadFile(filepath.Join(walDir, WALCheckpointFile))
+			if err != nil {
+				return false, err
+			}
+			var cp WALCheckpoint
+			if err := json.Unmarshal(data, &cp); err != nil || cp.Offset > 0 {
+				return false, nil
+			}
+		case strings.HasPrefix(entry.Name(), WALFilePrefix):
+			info, err := entry.Info()
+			if err != nil {
+				return false, err
+			}
+			if info.Size() > 0 {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
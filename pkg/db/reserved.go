@@ -0,0 +1,56 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ReservedFieldPrefix marks top-level document fields managed by CachyDB
+// itself (such as _id and _attachments). Users cannot write fields whose
+// names start with it, so new system fields can be added without colliding
+// with existing data.
+const ReservedFieldPrefix = "_"
+
+// ReservedFieldError reports a user write to a reserved field
+type ReservedFieldError struct {
+	Field string
+}
+
+func (e *ReservedFieldError) Error() string {
+	return fmt.Sprintf("field '%s' is reserved: names starting with '%s' are managed by CachyDB", e.Field, ReservedFieldPrefix)
+}
+
+// IsReservedField reports whether a top-level field name is in the reserved namespace
+func IsReservedField(name string) bool {
+	return strings.HasPrefix(name, ReservedFieldPrefix)
+}
+
+// CheckUserFields returns a *ReservedFieldError for the first (in sorted
+// order) reserved field in data
+func CheckUserFields(data map[string]any) error {
+	if reserved := reservedFields(data); len(reserved) > 0 {
+		return &ReservedFieldError{Field: reserved[0]}
+	}
+	return nil
+}
+
+// SanitizeUserFields removes reserved fields from data and returns their names
+func SanitizeUserFields(data map[string]any) []string {
+	reserved := reservedFields(data)
+	for _, name := range reserved {
+		delete(data, name)
+	}
+	return reserved
+}
+
+func reservedFields(data map[string]any) []string {
+	var reserved []string
+	for name := range data {
+		if IsReservedField(name) {
+			reserved = append(reserved, name)
+		}
+	}
+	sort.Strings(reserved)
+	return reserved
+}
@@ -0,0 +1,371 @@
+package db
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RestoreOptions select what Restore takes from a backup archive
+type RestoreOptions struct {
+	// Database restores only this database of the archive ("" restores all of them)
+	Database string
+	// Into restores the database under another name; the archive must hold
+	// a single database unless Database is set
+	Into string
+	// Force replaces existing databases instead of failing
+	Force bool
+	// WALArchive is a directory of WAL files kept by a DirWALArchiver. Its
+	// entries logged after the backup are replayed too, recovering the
+	// databases to a later point in time.
+	WALArchive string
+	// Until stops the replay of WALArchive after the last entry logged at
+	// or before this time (zero replays all of it)
+	Until time.Time
+}
+
+// RestoreResult reports the databases a restore wrote
+type RestoreResult struct {
+	// Databases maps each restored database, by the name it was restored
+	// under, to the document count of each of its collections
+	Databases map[string]map[string]int `json:"databases"`
+	// Replaced lists the existing databases that were overwritten
+	Replaced []string `json:"replaced,omitempty"`
+	// WALEntries counts the entries of the archive's WAL tail that were replayed
+	WALEntries int `json:"wal_entries"`
+	// ArchivedEntries counts the entries of RestoreOptions.WALArchive that
+	// were replayed, and RecoveredTo is the time the last one was logged
+	ArchivedEntries int       `json:"archived_entries,omitempty"`
+	RecoveredTo     time.Time `json:"recovered_to,omitzero"`
+}
+
+// Restore unpacks a backup archive written by Backup into the root
+// directory. The archive is unpacked into a staging directory first, where
+// its WAL tail is replayed and the document count of every collection is
+// checked against the manifest; only then are the databases moved into the
+// root directory. Existing databases are only replaced with opts.Force.
+//
+// With opts.WALArchive, the archived WAL entries logged after the backup
+// (up to opts.Until) are replayed in the staging directory as well. The
+// document counts then differ from the manifest and are not checked.
+//
+// The storage manager's own WAL should have been replayed (LoadAllDatabases),
+// so that none of its entries are applied to the restored databases later.
+func (sm *StorageManager) Restore(r io.Reader, opts RestoreOptions) (*RestoreResult, error) {
+	if sm.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	staging, err := stagingDir(sm.RootDir, "restore")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	defer os.RemoveAll(staging)
+
+	manifest, err := extractBackup(r, staging)
+	if err != nil {
+		return nil, err
+	}
+
+	// Map the databases of the archive to the names they are restored under
+	names := []string{opts.Database}
+	if opts.Database == "" {
+		names = make([]string, 0, len(manifest.Databases))
+		for name := range manifest.Databases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	} else if _, exists := manifest.Databases[opts.Database]; !exists {
+		return nil, fmt.Errorf("database '%s' is not in the backup", opts.Database)
+	}
+	targets := make(map[string]string, len(names))
+	for _, name := range names {
+		targets[name] = name
+	}
+	if opts.Into != "" {
+		if len(names) != 1 {
+			return nil, fmt.Errorf("the backup holds %d databases; choose the one to restore under a new name", len(names))
+		}
+		if strings.HasPrefix(opts.Into, ".") || strings.ContainsAny(opts.Into, `/\`) {
+			return nil, fmt.Errorf("invalid database name '%s'", opts.Into)
+		}
+		targets[names[0]] = opts.Into
+	}
+
+	result := &RestoreResult{
+		Databases:  make(map[string]map[string]int, len(names)),
+		WALEntries: manifest.WALEntries,
+	}
+	for _, name := range names {
+		if sm.DatabaseExists(targets[name]) {
+			if !opts.Force {
+				return nil, fmt.Errorf("database '%s' already exists; restore with --force to replace it", targets[name])
+			}
+			result.Replaced = append(result.Replaced, targets[name])
+		}
+	}
+
+	if opts.WALArchive != "" {
+		if err := stageArchivedWAL(staging, manifest, names, opts, result); err != nil {
+			return nil, err
+		}
+	}
+
+	// Replaying the WAL tail saves its entries into the staged files
+	counts, err := sm.verifyRestore(staging, manifest, names, result.ArchivedEntries == 0)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range names {
+		target := targets[name]
+		src := filepath.Join(staging, name)
+		if target != name {
+			if err := sm.renameStagedDatabase(src, target); err != nil {
+				return nil, err
+			}
+		}
+
+		// The replaced database is moved into the staging directory and
+		// removed with it
+		dst := filepath.Join(sm.RootDir, target)
+		if sm.DatabaseExists(target) {
+			if err := os.Rename(dst, filepath.Join(staging, ".replaced-"+target)); err != nil {
+				return nil, fmt.Errorf("failed to move database '%s' aside: %w", target, err)
+			}
+		}
+		if err := moveDir(src, dst); err != nil {
+			return nil, fmt.Errorf("failed to restore database '%s': %w", target, err)
+		}
+		result.Databases[target] = counts[name]
+	}
+	if err := syncDir(sm.RootDir); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RestoreFile restores the backup archive at path (see Restore)
+func (sm *StorageManager) RestoreFile(path string, opts RestoreOptions) (*RestoreResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return sm.Restore(f, opts)
+}
+
+// stageArchivedWAL writes the entries of opts.WALArchive that follow the
+// backup, for the databases in names, to a WAL file in staging
+func stageArchivedWAL(staging string, manifest *BackupManifest, names []string, opts RestoreOptions, result *RestoreResult) error {
+	if !opts.Until.IsZero() && opts.Until.Before(manifest.Created) {
+		return fmt.Errorf("cannot recover to %s, before the backup was taken at %s",
+			opts.Until.Format(time.RFC3339), manifest.Created.Format(time.RFC3339))
+	}
+	entries, err := readArchivedWAL(opts.WALArchive, manifest.WALOffset, opts.Until)
+	if err != nil {
+		return err
+	}
+
+	included := make(map[string]bool, len(names))
+	for _, name := range names {
+		included[name] = true
+	}
+	var tail bytes.Buffer
+	first := uint64(0)
+	for _, entry := range entries {
+		if !included[entry.Database] {
+			continue
+		}
+		if result.ArchivedEntries == 0 {
+			first = entry.Offset
+		}
+		if _, err := writeWALEntry(&tail, entry); err != nil {
+			return err
+		}
+		result.ArchivedEntries++
+		result.RecoveredTo = entry.Timestamp
+	}
+	if result.ArchivedEntries == 0 {
+		return nil
+	}
+	// Named after the archive's tail, so it is replayed after it
+	path := filepath.Join(staging, walFileName(first))
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(tail.Bytes())
+		return err
+	})
+}
+
+// verifyRestore loads the unpacked archive in staging, replaying its WAL,
+// and returns the document counts of the databases in names. With exact,
+// the counts must match the manifest.
+func (sm *StorageManager) verifyRestore(staging string, manifest *BackupManifest, names []string, exact bool) (map[string]map[string]int, error) {
+	stage, err := NewStorageManager(staging)
+	if err != nil {
+		return nil, err
+	}
+	defer stage.Close()
+	stage.Format = sm.Format
+	stage.Compression = sm.Compression
+
+	dm, err := stage.LoadAllDatabases()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load backup: %w", err)
+	}
+
+	counts := make(map[string]map[string]int, len(names))
+	for _, name := range names {
+		db := dm.GetDatabase(name)
+		if db == nil {
+			return nil, fmt.Errorf("verification failed: database '%s' is missing from the backup", name)
+		}
+		expected := manifest.Databases[name]
+		if got := len(db.ListCollections()); exact && got != len(expected) {
+			return nil, fmt.Errorf("verification failed: database '%s' has %d collection(s), the manifest lists %d", name, got, len(expected))
+		}
+		counts[name] = make(map[string]int)
+		for _, collName := range db.ListCollections() {
+			coll, err := db.GetCollection(collName)
+			if err != nil {
+				return nil, fmt.Errorf("verification failed: %w", err)
+			}
+			counts[name][collName] = coll.Count()
+		}
+		if !exact {
+			continue
+		}
+		for collName, count := range expected {
+			got, exists := counts[name][collName]
+			if !exists {
+				return nil, fmt.Errorf("verification failed: collection '%s/%s' is missing", name, collName)
+			}
+			if got != count {
+				return nil, fmt.Errorf("verification failed: collection '%s/%s' has %d document(s), the manifest lists %d", name, collName, got, count)
+			}
+		}
+	}
+	return counts, nil
+}
+
+// extractBackup unpacks a backup archive into dir and returns its manifest
+func extractBackup(r io.Reader, dir string) (*BackupManifest, error) {
+	manifest := &BackupManifest{}
+	found, err := extractArchive(r, dir, "backup", BackupManifestFile, manifest)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("not a backup archive: %s is missing", BackupManifestFile)
+	}
+	if manifest.Version > BackupVersion {
+		return nil, fmt.Errorf("backup archive has version %d, but this build reads up to version %d. Please upgrade CachyDB to restore it",
+			manifest.Version, BackupVersion)
+	}
+	return manifest, nil
+}
+
+// extractArchive unpacks a gzipped tar archive into dir, except for the
+// file named meta, which is decoded into v. It reports whether meta was in
+// the archive. kind names the archive in errors.
+func extractArchive(r io.Reader, dir, kind, meta string, v any) (bool, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return false, fmt.Errorf("not a %s archive: %w", kind, err)
+	}
+	defer gz.Close()
+
+	found := false
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to read %s archive: %w", kind, err)
+		}
+
+		name := filepath.FromSlash(strings.TrimSuffix(header.Name, "/"))
+		if !filepath.IsLocal(name) {
+			return false, fmt.Errorf("%s archive has an invalid path '%s'", kind, header.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return false, err
+			}
+		case tar.TypeReg:
+			if name == meta {
+				if err := json.NewDecoder(tr).Decode(v); err != nil {
+					return false, fmt.Errorf("failed to read %s manifest: %w", kind, err)
+				}
+				found = true
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return false, err
+			}
+			if err := extractFile(tr, target); err != nil {
+				return false, err
+			}
+		}
+	}
+	return found, nil
+}
+
+// extractFile writes the content of the current archive entry to path
+func extractFile(r io.Reader, path string) error {
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to extract %s: %w", path, err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// renameStagedDatabase records a new name in the metadata of a staged
+// database; the name of its directory is changed as it is moved into place
+func (sm *StorageManager) renameStagedDatabase(dbDir, name string) error {
+	metaPath := filepath.Join(dbDir, "db.meta.json")
+	meta := map[string]any{}
+	if data, err := os.ReadFile(metaPath); err == nil {
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return fmt.Errorf("failed to read database metadata: %w", err)
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	meta["name"] = name
+	return sm.writeJSON(metaPath, meta)
+}
+
+// moveDir moves the directory src to dst, copying it when the two are on
+// different file systems
+func moveDir(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+	if err := copyDir(src, dst); err != nil {
+		os.RemoveAll(dst)
+		return err
+	}
+	return os.RemoveAll(src)
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+)
+
+// RevField holds a document's revision: 1 when it is inserted and one more
+// on every write. Documents written before revisions existed start at 0.
+const RevField = "_rev"
+
+// ErrConflict is matched (with errors.Is) by every *ConflictError
+var ErrConflict = errors.New("conflict")
+
+// ConflictError reports a conditional write to a document that was changed
+// since the caller read it
+type ConflictError struct {
+	DocumentID string
+	Expected   int64 // revision the caller read
+	Actual     int64 // current revision
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict: document '%s' is at revision %d, not %d", e.DocumentID, e.Actual, e.Expected)
+}
+
+func (e *ConflictError) Is(target error) bool {
+	return target == ErrConflict
+}
+
+// Rev returns the document's revision
+func (d *Document) Rev() int64 {
+	rev, _ := toFloat(d.Data[RevField])
+	return int64(rev)
+}
+
+func (d *Document) setRev(rev int64) {
+	d.Data[RevField] = rev
+}
+
+// UpdateIfRev is Update for a document that must still be at revision rev,
+// as read by the caller; otherwise it fails with a *ConflictError
+func (c *Collection) UpdateIfRev(id string, rev int64, updates map[string]any) error {
+	mutate, err := userUpdate(updates)
+	if err != nil {
+		return err
+	}
+	return c.modify(id, func(doc *Document) error {
+		if err := checkRev(doc, rev); err != nil {
+			return err
+		}
+		return mutate(doc)
+	})
+}
+
+// DeleteIfRev is Delete for a document that must still be at revision rev,
+// as read by the caller; otherwise it fails with a *ConflictError
+func (c *Collection) DeleteIfRev(id string, rev int64) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	doc, exists := c.Documents[id]
+	if !exists {
+		return &NotFoundError{DocumentID: id}
+	}
+	doc, err := c.loadLocked(doc)
+	if err != nil {
+		return err
+	}
+	if err := checkRev(doc, rev); err != nil {
+		return err
+	}
+	return c.deleteLocked(doc, true)
+}
+
+func checkRev(doc *Document, rev int64) error {
+	if actual := doc.Rev(); actual != rev {
+		return &ConflictError{DocumentID: doc.ID, Expected: rev, Actual: actual}
+	}
+	return nil
+}
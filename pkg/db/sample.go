@@ -0,0 +1,45 @@
+package db
+
+import "math/rand/v2"
+
+// Sample returns up to n documents of the collection chosen uniformly at
+// random, e.g. to inspect a large collection without scanning it in ID
+// order. It picks the documents with reservoir sampling in one pass over
+// the collection's index of IDs, so only the chosen documents are copied;
+// evicted bodies are read back from the data file but not kept in memory,
+// so sampling does not push the hot documents out of the cache.
+func (c *Collection) Sample(n int) ([]*Document, error) {
+	if n <= 0 {
+		return []*Document{}, nil
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	reservoir := make([]*Document, 0, min(n, len(c.Documents)))
+	seen := 0
+	for _, doc := range c.Documents {
+		seen++
+		if len(reservoir) < n {
+			reservoir = append(reservoir, doc)
+		} else if i := rand.IntN(seen); i < n {
+			reservoir[i] = doc
+		}
+	}
+
+	// The first n documents are in map order; shuffle them so the
+	// result's order carries no meaning either
+	rand.Shuffle(len(reservoir), func(i, j int) {
+		reservoir[i], reservoir[j] = reservoir[j], reservoir[i]
+	})
+
+	results := make([]*Document, len(reservoir))
+	for i, doc := range reservoir {
+		loaded, err := c.loadLocked(doc)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = loaded.Clone()
+	}
+	return results, nil
+}
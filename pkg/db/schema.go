@@ -1,26 +1,139 @@
 package db
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 )
 
+// ErrSchemaViolation is matched (with errors.Is) by every *SchemaViolationError
+var ErrSchemaViolation = errors.New("schema violation")
+
+// Field constraints named by SchemaViolationError.Constraint
+const (
+	ConstraintEnum      = "enum"
+	ConstraintMin       = "min"
+	ConstraintMax       = "max"
+	ConstraintMinLength = "min_length"
+	ConstraintMaxLength = "max_length"
+	ConstraintPattern   = "pattern"
+)
+
+// SchemaViolationError reports a document that does not match the schema of
+// its collection
+type SchemaViolationError struct {
+	Field string
+	// Expected is the type the schema requires, or empty if a required
+	// field is missing or the value breaks a constraint
+	Expected FieldType
+	// Constraint names the constraint the value breaks (see ConstraintEnum
+	// and the others), and Limit is its setting: the allowed values, the
+	// bound or the pattern
+	Constraint string
+	Limit      any
+	// Value is the value that breaks the constraint
+	Value any
+}
+
+func (e *SchemaViolationError) Error() string {
+	str, _ := e.Value.(string)
+	switch e.Constraint {
+	case ConstraintEnum:
+		return fmt.Sprintf("field '%s' is %s, expected one of %s", e.Field, formatValue(e.Value), formatValue(e.Limit))
+	case ConstraintMin:
+		return fmt.Sprintf("field '%s' is %s, below the minimum of %s", e.Field, formatValue(e.Value), formatValue(e.Limit))
+	case ConstraintMax:
+		return fmt.Sprintf("field '%s' is %s, above the maximum of %s", e.Field, formatValue(e.Value), formatValue(e.Limit))
+	case ConstraintMinLength:
+		return fmt.Sprintf("field '%s' has %d characters, fewer than the minimum length of %v", e.Field, utf8.RuneCountInString(str), e.Limit)
+	case ConstraintMaxLength:
+		return fmt.Sprintf("field '%s' has %d characters, more than the maximum length of %v", e.Field, utf8.RuneCountInString(str), e.Limit)
+	case ConstraintPattern:
+		return fmt.Sprintf("field '%s' is %s, which does not match the pattern %s", e.Field, formatValue(e.Value), formatValue(e.Limit))
+	}
+	if e.Expected == "" {
+		return fmt.Sprintf("required field '%s' is missing", e.Field)
+	}
+	return fmt.Sprintf("field '%s' has invalid type, expected %s", e.Field, e.Expected)
+}
+
+func (e *SchemaViolationError) Is(target error) bool {
+	return target == ErrSchemaViolation
+}
+
+// formatValue formats a value for an error message as JSON, so strings
+// are quoted
+func formatValue(value any) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return string(encoded)
+}
+
+// UniqueIndexPrefix starts the names of the indexes created for the unique
+// fields of a schema
+const UniqueIndexPrefix = "_unique_"
+
+// UniqueIndexName returns the name of the index enforcing a unique field
+func UniqueIndexName(fieldName string) string {
+	return UniqueIndexPrefix + fieldName
+}
+
+// uniqueFields returns the names of the schema's unique fields, sorted
+func (s *Schema) uniqueFields() []string {
+	if s == nil {
+		return nil
+	}
+	var names []string
+	for name, field := range s.Fields {
+		if field.Unique {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// uniqueIndexField returns the unique field whose index is named indexName
+func (s *Schema) uniqueIndexField(indexName string) (string, bool) {
+	fieldName, ok := strings.CutPrefix(indexName, UniqueIndexPrefix)
+	if !ok || s == nil || !s.Fields[fieldName].Unique {
+		return "", false
+	}
+	return fieldName, true
+}
+
 // ValidateDocument validates a document against a schema
 func (s *Schema) ValidateDocument(doc *Document) error {
 	if s == nil {
 		return nil // No schema, no validation
 	}
+	return s.validateFields("", doc.GetValue)
+}
 
-	// Check required fields
+// validateFields validates the fields of a document or nested object, got
+// with get, reporting them under their path from the document's root
+func (s *Schema) validateFields(prefix string, get func(name string) (any, bool)) error {
 	for fieldName, field := range s.Fields {
-		value, exists := doc.GetValue(fieldName)
+		path := prefix + fieldName
+		value, exists := get(fieldName)
 
 		if field.Required && !exists {
-			return fmt.Errorf("required field '%s' is missing", fieldName)
+			return &SchemaViolationError{Field: path}
 		}
 
 		if exists {
-			if !ValidateType(value, field.Type) {
-				return fmt.Errorf("field '%s' has invalid type, expected %s", fieldName, field.Type)
+			if _, isRef := BlobRef(value); isRef {
+				continue // spilled to a blob file, validated when it was written
+			}
+			if err := field.validateValue(path, value); err != nil {
+				return err
 			}
 		}
 	}
@@ -28,6 +141,94 @@ func (s *Schema) ValidateDocument(doc *Document) error {
 	return nil
 }
 
+// validateValue validates the value of a field, and of the fields and
+// elements inside it, found at path
+func (f *Field) validateValue(path string, value any) error {
+	if !ValidateType(value, f.Type) {
+		return &SchemaViolationError{Field: path, Expected: f.Type}
+	}
+	if err := f.checkConstraints(path, value); err != nil {
+		return err
+	}
+
+	switch {
+	case f.Type == TypeObject && f.Schema != nil:
+		object := value.(map[string]any)
+		return f.Schema.validateFields(path+FieldPathSeparator, func(name string) (any, bool) {
+			value, exists := object[name]
+			return value, exists
+		})
+	case f.Type == TypeArray && f.Items != nil:
+		elements := reflect.ValueOf(value)
+		for i := range elements.Len() {
+			if err := f.Items.validateValue(path+FieldPathSeparator+strconv.Itoa(i), elements.Index(i).Interface()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkConstraints checks a value of the field's type against the field's
+// enum, range, length and pattern constraints
+func (f *Field) checkConstraints(fieldName string, value any) error {
+	violation := func(constraint string, limit any) error {
+		return &SchemaViolationError{Field: fieldName, Constraint: constraint, Limit: limit, Value: value}
+	}
+
+	if len(f.Enum) > 0 && !enumContains(f.Enum, value) {
+		return violation(ConstraintEnum, f.Enum)
+	}
+
+	if number, ok := toFloat(value); ok {
+		if f.Min != nil && number < *f.Min {
+			return violation(ConstraintMin, *f.Min)
+		}
+		if f.Max != nil && number > *f.Max {
+			return violation(ConstraintMax, *f.Max)
+		}
+	}
+
+	if str, ok := value.(string); ok && f.Type == TypeString {
+		length := utf8.RuneCountInString(str)
+		if f.MinLength > 0 && length < f.MinLength {
+			return violation(ConstraintMinLength, f.MinLength)
+		}
+		if f.MaxLength > 0 && length > f.MaxLength {
+			return violation(ConstraintMaxLength, f.MaxLength)
+		}
+		if f.Pattern != "" {
+			re, err := compileRegex(f.Pattern)
+			if err != nil {
+				return fmt.Errorf("field '%s': %w", fieldName, err)
+			}
+			if !re.MatchString(str) {
+				return violation(ConstraintPattern, f.Pattern)
+			}
+		}
+	}
+
+	return nil
+}
+
+// enumContains reports whether value is one of the allowed values. Numbers
+// are compared by value, so 1 matches 1.0.
+func enumContains(allowed []any, value any) bool {
+	number, isNumber := toFloat(value)
+	for _, candidate := range allowed {
+		if isNumber {
+			if other, ok := toFloat(candidate); ok && other == number {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
 // ValidateSchema validates the schema structure itself
 func (s *Schema) Validate() error {
 	if s == nil {
@@ -38,20 +239,108 @@ func (s *Schema) Validate() error {
 		return fmt.Errorf("schema must have at least one field")
 	}
 
+	for fieldName := range s.Fields {
+		if IsReservedField(fieldName) {
+			return &ReservedFieldError{Field: fieldName}
+		}
+	}
+	return s.validate("")
+}
+
+// validate checks the fields of a schema, or of the nested schema of an
+// object field at prefix
+func (s *Schema) validate(prefix string) error {
 	for fieldName, field := range s.Fields {
 		if fieldName == "" {
 			return fmt.Errorf("field name cannot be empty")
 		}
+		if field.Unique && prefix != "" {
+			return fmt.Errorf("field '%s': only top-level fields can be unique", prefix+fieldName)
+		}
+		if err := field.validate(prefix + fieldName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks the type, constraints, nested schema and element type of
+// the field at path
+func (f *Field) validate(path string) error {
+	switch f.Type {
+	case TypeString, TypeNumber, TypeBoolean, TypeObject, TypeArray, TypeDate:
+		// Valid types
+	default:
+		return fmt.Errorf("invalid field type '%s' for field '%s'", f.Type, path)
+	}
+
+	if err := f.validateConstraints(); err != nil {
+		return fmt.Errorf("field '%s': %w", path, err)
+	}
 
-		if fieldName == "_id" {
-			return fmt.Errorf("field name '_id' is reserved")
+	if f.Unique && (f.Type == TypeObject || f.Type == TypeArray) {
+		return fmt.Errorf("field '%s': %s fields cannot be unique", path, f.Type)
+	}
+
+	if f.Schema != nil {
+		if f.Type != TypeObject {
+			return fmt.Errorf("field '%s': a nested schema only applies to object fields, not %s", path, f.Type)
+		}
+		if len(f.Schema.Fields) == 0 {
+			return fmt.Errorf("field '%s': nested schema must have at least one field", path)
+		}
+		if err := f.Schema.validate(path + FieldPathSeparator); err != nil {
+			return err
+		}
+	}
+
+	if f.Items != nil {
+		if f.Type != TypeArray {
+			return fmt.Errorf("field '%s': items only apply to array fields, not %s", path, f.Type)
+		}
+		if f.Items.Unique {
+			return fmt.Errorf("field '%s': array elements cannot be unique", path)
+		}
+		if err := f.Items.validate(path + FieldPathSeparator + "items"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateConstraints checks that the field's constraints suit its type and
+// can be met
+func (f *Field) validateConstraints() error {
+	for _, allowed := range f.Enum {
+		if !ValidateType(allowed, f.Type) {
+			return fmt.Errorf("enum value %s is not of type %s", formatValue(allowed), f.Type)
+		}
+	}
+
+	if f.Min != nil || f.Max != nil {
+		if f.Type != TypeNumber {
+			return fmt.Errorf("min and max only apply to number fields, not %s", f.Type)
 		}
+		if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+			return fmt.Errorf("min %v is greater than max %v", *f.Min, *f.Max)
+		}
+	}
 
-		switch field.Type {
-		case TypeString, TypeNumber, TypeBoolean, TypeObject, TypeArray, TypeDate:
-			// Valid types
-		default:
-			return fmt.Errorf("invalid field type '%s' for field '%s'", field.Type, fieldName)
+	if f.MinLength != 0 || f.MaxLength != 0 || f.Pattern != "" {
+		if f.Type != TypeString {
+			return fmt.Errorf("min_length, max_length and pattern only apply to string fields, not %s", f.Type)
+		}
+		if f.MinLength < 0 || f.MaxLength < 0 {
+			return fmt.Errorf("min_length and max_length cannot be negative")
+		}
+		if f.MaxLength > 0 && f.MinLength > f.MaxLength {
+			return fmt.Errorf("min_length %d is greater than max_length %d", f.MinLength, f.MaxLength)
+		}
+		if f.Pattern != "" {
+			if _, err := compileRegex(f.Pattern); err != nil {
+				return fmt.Errorf("pattern: %w", err)
+			}
 		}
 	}
 
@@ -0,0 +1,305 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// Kinds of schema field changes
+const (
+	FieldAdded    = "added"
+	FieldRemoved  = "removed"
+	FieldRetyped  = "retyped"
+	FieldRequired = "required" // only the required flag changed
+	// FieldConstrained is a change of only the enum, range, length or
+	// pattern constraints, or of the element type of an array
+	FieldConstrained = "constraints"
+)
+
+// FieldChange describes how one field differs between two schemas
+type FieldChange struct {
+	Field string `json:"field"`
+	Kind  string `json:"kind"`
+	From  *Field `json:"from,omitempty"` // nil for added fields
+	To    *Field `json:"to,omitempty"`   // nil for removed fields
+}
+
+// CollectionDiff lists the field changes of one collection
+type CollectionDiff struct {
+	Collection string        `json:"collection"`
+	Changes    []FieldChange `json:"changes"`
+}
+
+// SchemaDiff is the difference between the collection schemas of two sources
+type SchemaDiff struct {
+	AddedCollections   []string         `json:"added_collections,omitempty"`   // only in the target
+	RemovedCollections []string         `json:"removed_collections,omitempty"` // only in the source
+	Collections        []CollectionDiff `json:"collections,omitempty"`         // in both, with field changes
+}
+
+// Empty reports whether the two sources have identical schemas
+func (d *SchemaDiff) Empty() bool {
+	return len(d.AddedCollections) == 0 && len(d.RemovedCollections) == 0 && len(d.Collections) == 0
+}
+
+// DiffSchemas compares two schemas field by field, ordered by field name.
+// A nil schema has no fields. The nested schemas of object fields are
+// compared too, with their fields named by path, e.g. "address.city".
+func DiffSchemas(from, to *Schema) []FieldChange {
+	return diffSchemas("", from, to)
+}
+
+// diffSchemas is DiffSchemas for the nested schemas of the object field at
+// prefix
+func diffSchemas(prefix string, from, to *Schema) []FieldChange {
+	fromFields, toFields := schemaFields(from), schemaFields(to)
+
+	names := make(map[string]bool)
+	for name := range fromFields {
+		names[name] = true
+	}
+	for name := range toFields {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	var changes []FieldChange
+	for _, name := range sorted {
+		path := prefix + name
+		before, inFrom := fromFields[name]
+		after, inTo := toFields[name]
+		switch {
+		case !inFrom:
+			changes = append(changes, FieldChange{Field: path, Kind: FieldAdded, To: &after})
+			continue
+		case !inTo:
+			changes = append(changes, FieldChange{Field: path, Kind: FieldRemoved, From: &before})
+			continue
+		case before.Type != after.Type:
+			changes = append(changes, FieldChange{Field: path, Kind: FieldRetyped, From: &before, To: &after})
+			continue
+		}
+
+		// The nested schemas are compared field by field below
+		beforeOwn, afterOwn := before, after
+		beforeOwn.Schema, afterOwn.Schema = nil, nil
+		switch {
+		case before.Required != after.Required:
+			changes = append(changes, FieldChange{Field: path, Kind: FieldRequired, From: &before, To: &after})
+		case !reflect.DeepEqual(beforeOwn, afterOwn):
+			changes = append(changes, FieldChange{Field: path, Kind: FieldConstrained, From: &before, To: &after})
+		}
+		changes = append(changes, diffSchemas(path+FieldPathSeparator, before.Schema, after.Schema)...)
+	}
+	return changes
+}
+
+// DiffCollectionSchemas compares two sets of collection schemas keyed by collection name
+func DiffCollectionSchemas(from, to map[string]*Schema) *SchemaDiff {
+	diff := &SchemaDiff{}
+
+	names := make([]string, 0, len(from)+len(to))
+	for name := range from {
+		names = append(names, name)
+	}
+	for name := range to {
+		if _, exists := from[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fromSchema, inFrom := from[name]
+		toSchema, inTo := to[name]
+		switch {
+		case !inFrom:
+			diff.AddedCollections = append(diff.AddedCollections, name)
+		case !inTo:
+			diff.RemovedCollections = append(diff.RemovedCollections, name)
+		default:
+			if changes := DiffSchemas(fromSchema, toSchema); len(changes) > 0 {
+				diff.Collections = append(diff.Collections, CollectionDiff{Collection: name, Changes: changes})
+			}
+		}
+	}
+	return diff
+}
+
+// CollectionSchemas returns the schema of every collection in the database
+// (nil for schemaless collections)
+func (db *Database) CollectionSchemas() map[string]*Schema {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	schemas := make(map[string]*Schema, len(db.Collections))
+	for name, coll := range db.Collections {
+		schemas[name] = coll.Schema
+	}
+	return schemas
+}
+
+func schemaFields(s *Schema) map[string]Field {
+	if s == nil {
+		return nil
+	}
+	return s.Fields
+}
+
+// jsonSchema is the subset of JSON Schema understood by ParseJSONSchema
+type jsonSchema struct {
+	Type       any                    `json:"type"`
+	Format     string                 `json:"format"`
+	Properties map[string]*jsonSchema `json:"properties"`
+	Required   []string               `json:"required"`
+	Enum       []any                  `json:"enum"`
+	Minimum    *float64               `json:"minimum"`
+	Maximum    *float64               `json:"maximum"`
+	MinLength  int                    `json:"minLength"`
+	MaxLength  int                    `json:"maxLength"`
+	Pattern    string                 `json:"pattern"`
+	Items      *jsonSchema            `json:"items"`
+}
+
+// ParseJSONSchema converts a JSON Schema document into collection schemas.
+// The document is either a single object schema with "properties" (returned
+// under the key "") or an object mapping collection names to such schemas.
+// Property types map to field types as follows: string (format "date" or
+// "date-time" gives date), number and integer, boolean, object, and array.
+// The enum, minimum, maximum, minLength, maxLength and pattern keywords
+// become the matching field constraints; the properties of an object
+// property become its nested schema, and the items of an array its
+// element type.
+func ParseJSONSchema(data []byte) (map[string]*Schema, error) {
+	var single jsonSchema
+	if err := json.Unmarshal(data, &single); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON Schema: %w", err)
+	}
+	if single.Properties != nil {
+		schema, err := single.toSchema()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]*Schema{"": schema}, nil
+	}
+
+	var multi map[string]*jsonSchema
+	if err := json.Unmarshal(data, &multi); err != nil {
+		return nil, fmt.Errorf("failed to parse JSON Schema: %w", err)
+	}
+	schemas := make(map[string]*Schema, len(multi))
+	for name, js := range multi {
+		if name == "$schema" || name == "$id" {
+			continue
+		}
+		if js == nil || js.Properties == nil {
+			return nil, fmt.Errorf("collection '%s': schema has no properties", name)
+		}
+		schema, err := js.toSchema()
+		if err != nil {
+			return nil, fmt.Errorf("collection '%s': %w", name, err)
+		}
+		schemas[name] = schema
+	}
+	return schemas, nil
+}
+
+// toSchema converts an object schema's properties into a collection schema
+func (js *jsonSchema) toSchema() (*Schema, error) {
+	schema := &Schema{Fields: make(map[string]Field, len(js.Properties))}
+	for name, prop := range js.Properties {
+		field, err := prop.toField()
+		if err != nil {
+			return nil, fmt.Errorf("property '%s': %w", name, err)
+		}
+		schema.Fields[name] = field
+	}
+	for _, name := range js.Required {
+		field, exists := schema.Fields[name]
+		if !exists {
+			return nil, fmt.Errorf("required property '%s' is not defined", name)
+		}
+		field.Required = true
+		schema.Fields[name] = field
+	}
+	return schema, nil
+}
+
+// toField converts a property into a schema field. The properties of an
+// object become its nested schema, and the items of an array its element
+// type.
+func (js *jsonSchema) toField() (Field, error) {
+	fieldType, err := js.fieldType()
+	if err != nil {
+		return Field{}, err
+	}
+	field := Field{
+		Type:      fieldType,
+		Enum:      js.Enum,
+		Min:       js.Minimum,
+		Max:       js.Maximum,
+		MinLength: js.MinLength,
+		MaxLength: js.MaxLength,
+		Pattern:   js.Pattern,
+	}
+
+	if fieldType == TypeObject && len(js.Properties) > 0 {
+		if field.Schema, err = js.toSchema(); err != nil {
+			return Field{}, err
+		}
+	}
+	if fieldType == TypeArray && js.Items != nil {
+		items, err := js.Items.toField()
+		if err != nil {
+			return Field{}, fmt.Errorf("items: %w", err)
+		}
+		field.Items = &items
+	}
+	return field, nil
+}
+
+// fieldType maps a property's JSON Schema type to a field type. Union types
+// such as ["string", "null"] use their first non-null member.
+func (js *jsonSchema) fieldType() (FieldType, error) {
+	if js == nil {
+		return "", fmt.Errorf("missing type")
+	}
+
+	var typeName string
+	switch t := js.Type.(type) {
+	case string:
+		typeName = t
+	case []any:
+		for _, member := range t {
+			if s, ok := member.(string); ok && s != "null" {
+				typeName = s
+				break
+			}
+		}
+	}
+
+	switch typeName {
+	case "string":
+		if js.Format == "date" || js.Format == "date-time" {
+			return TypeDate, nil
+		}
+		return TypeString, nil
+	case "number", "integer":
+		return TypeNumber, nil
+	case "boolean":
+		return TypeBoolean, nil
+	case "object":
+		return TypeObject, nil
+	case "array":
+		return TypeArray, nil
+	case "":
+		return "", fmt.Errorf("missing type")
+	}
+	return "", fmt.Errorf("unsupported type '%s'", typeName)
+}
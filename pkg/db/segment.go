@@ -0,0 +1,472 @@
+package db
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// Magic number for segment files
+	SegmentMagic = 0x43534547 // "CSEG" in hex
+
+	// Version of the segment format written by this build
+	SegmentFormatVersion = 1
+
+	// FlagBaseSegment is set in the header flags of a segment that holds
+	// every live document of the collection; older segments are ignored
+	FlagBaseSegment = 2
+
+	// SegmentSize is the size at which saves stop appending to the newest
+	// segment and start a new one
+	SegmentSize = 4 << 20
+
+	// Segment record header: op(1) + id_len(4) + size(4) + stored_size(4) + checksum(4) = 17 bytes
+	segmentRecordHeaderSize = 17
+
+	segmentFilePrefix = "segment-"
+	segmentFileSuffix = ".seg"
+)
+
+// Segment record operations
+const (
+	segmentOpPut    = 1
+	segmentOpDelete = 2
+)
+
+// segmentState tracks a collection stored in segment files: the newest
+// (tail) segment that saves append to, and the documents written since the
+// last save. A nil changed set means the next save writes a base segment.
+type segmentState struct {
+	changed    map[string]bool
+	next       int    // number of the next new segment
+	tail       string // path of the newest segment
+	tailSize   int64  // length of the valid records in tail
+	tailCodec  Codec
+	baseBytes  int64 // size of the newest base segment when it was written
+	deltaBytes int64 // bytes appended since
+	mu         sync.Mutex
+}
+
+// noteChanged records a written document for the next segment save
+// (caller must hold mu)
+func (c *Collection) noteChanged(id string) {
+	if c.segments.changed != nil {
+		c.segments.changed[id] = true
+	}
+}
+
+// tracking reports whether the collection has been saved in segments, so
+// a save can append to them
+func (s *segmentState) tracking() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.changed != nil
+}
+
+func segmentPath(collDir string, number int) string {
+	return filepath.Join(collDir, fmt.Sprintf("%s%06d%s", segmentFilePrefix, number, segmentFileSuffix))
+}
+
+// listSegments returns the numbers of a collection's segment files, oldest first
+func listSegments(collDir string) ([]int, error) {
+	entries, err := os.ReadDir(collDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var numbers []int
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, segmentFilePrefix) || !strings.HasSuffix(name, segmentFileSuffix) {
+			continue
+		}
+		number, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, segmentFilePrefix), segmentFileSuffix))
+		if err != nil {
+			continue
+		}
+		numbers = append(numbers, number)
+	}
+	sort.Ints(numbers)
+	return numbers, nil
+}
+
+// removeSegments deletes a collection's segment files numbered below before
+// (all of them when before is 0)
+func removeSegments(collDir string, before int) error {
+	numbers, err := listSegments(collDir)
+	if err != nil {
+		return err
+	}
+	for _, number := range numbers {
+		if before > 0 && number >= before {
+			continue
+		}
+		if err := os.Remove(segmentPath(collDir, number)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func readSegmentHeader(path string) (*BinaryHeader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	header, err := readHeader(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read header of %s: %w", filepath.Base(path), err)
+	}
+	if header.Magic != SegmentMagic {
+		return nil, fmt.Errorf("invalid magic number in %s: expected 0x%X, got 0x%X", filepath.Base(path), SegmentMagic, header.Magic)
+	}
+	if header.Version != SegmentFormatVersion {
+		return nil, fmt.Errorf("%s uses segment format version %d, but this build of CachyDB only supports version %d",
+			filepath.Base(path), header.Version, SegmentFormatVersion)
+	}
+	return header, nil
+}
+
+// loadSegments reads a collection's documents from its segment files,
+// starting at the newest base segment, and sets up state for appending to
+// them. An incomplete record at the end of the newest segment (a save cut
+// short by a crash) is ignored; the WAL still holds those writes.
+func loadSegments(collDir string, state *segmentState) (map[string]*Document, error) {
+	docs := make(map[string]*Document)
+	state.changed = make(map[string]bool)
+	state.next = 1
+
+	numbers, err := listSegments(collDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list segments: %w", err)
+	}
+	// A new segment whose header was cut short holds nothing
+	if len(numbers) > 0 {
+		if info, err := os.Stat(segmentPath(collDir, numbers[len(numbers)-1])); err == nil && info.Size() < HeaderSize {
+			numbers = numbers[:len(numbers)-1]
+		}
+	}
+	if len(numbers) == 0 {
+		return docs, nil
+	}
+
+	start := 0
+	for i := len(numbers) - 1; i >= 0; i-- {
+		header, err := readSegmentHeader(segmentPath(collDir, numbers[i]))
+		if err != nil {
+			return nil, err
+		}
+		if header.Flags&FlagBaseSegment != 0 {
+			start = i
+			break
+		}
+	}
+
+	for i, number := range numbers[start:] {
+		path := segmentPath(collDir, number)
+		last := start+i == len(numbers)-1
+		size, codec, err := readSegment(path, docs, last)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			state.baseBytes = size
+		} else {
+			state.deltaBytes += size
+		}
+		if last {
+			state.tail, state.tailSize, state.tailCodec = path, size, codec
+		}
+	}
+	state.next = numbers[len(numbers)-1] + 1
+	return docs, nil
+}
+
+// readSegment applies the records of one segment to docs and returns the
+// length of its valid records and its codec
+func readSegment(path string, docs map[string]*Document, last bool) (int64, Codec, error) {
+	header, err := readSegmentHeader(path)
+	if err != nil {
+		return 0, "", err
+	}
+	codec := codecOf(header.Flags)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", err
+	}
+	defer f.Close()
+	if _, err := f.Seek(HeaderSize, io.SeekStart); err != nil {
+		return 0, "", err
+	}
+
+	reader := bufio.NewReader(f)
+	offset := int64(HeaderSize)
+	for {
+		op, id, jsonData, n, err := readSegmentRecord(reader, codec)
+		if err == io.EOF {
+			return offset, codec, nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) && last {
+			return offset, codec, nil
+		}
+		if err != nil {
+			return 0, "", fmt.Errorf("%s at offset %d: %w", filepath.Base(path), offset, err)
+		}
+
+		switch op {
+		case segmentOpPut:
+			var doc Document
+			if err := doc.UnmarshalJSON(jsonData); err != nil {
+				return 0, "", fmt.Errorf("%s: failed to unmarshal document %s: %w", filepath.Base(path), id, err)
+			}
+			docs[id] = &doc
+		case segmentOpDelete:
+			delete(docs, id)
+		default:
+			return 0, "", fmt.Errorf("%s at offset %d: unknown record operation %d", filepath.Base(path), offset, op)
+		}
+		offset += n
+	}
+}
+
+// readSegmentRecord reads one record and returns its operation, document
+// ID, uncompressed JSON (puts only) and length
+func readSegmentRecord(r *bufio.Reader, codec Codec) (byte, string, []byte, int64, error) {
+	head := make([]byte, segmentRecordHeaderSize)
+	if n, err := io.ReadFull(r, head); err != nil {
+		if err == io.EOF && n == 0 {
+			return 0, "", nil, 0, io.EOF
+		}
+		return 0, "", nil, 0, io.ErrUnexpectedEOF
+	}
+	op := head[0]
+	idLen := binary.LittleEndian.Uint32(head[1:5])
+	storedSize := binary.LittleEndian.Uint32(head[9:13])
+	checksum := binary.LittleEndian.Uint32(head[13:17])
+
+	body := make([]byte, int(idLen)+int(storedSize))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return 0, "", nil, 0, io.ErrUnexpectedEOF
+	}
+	if crc32.ChecksumIEEE(body) != checksum {
+		return 0, "", nil, 0, fmt.Errorf("checksum mismatch")
+	}
+
+	id := string(body[:idLen])
+	jsonData := body[idLen:]
+	if op == segmentOpPut {
+		var err error
+		if jsonData, err = codec.decompress(jsonData); err != nil {
+			return 0, "", nil, 0, fmt.Errorf("failed to decompress document %s: %w", id, err)
+		}
+	}
+	return op, id, jsonData, int64(len(head) + len(body)), nil
+}
+
+// appendSegmentRecord encodes a put (doc is not nil) or delete record
+func appendSegmentRecord(buf *bytes.Buffer, id string, doc *Document, codec Codec) error {
+	op := byte(segmentOpDelete)
+	var jsonData, storedData []byte
+	if doc != nil {
+		op = segmentOpPut
+		var err error
+		if jsonData, err = doc.MarshalJSON(); err != nil {
+			return fmt.Errorf("failed to marshal document: %w", err)
+		}
+		if storedData, err = codec.compress(jsonData); err != nil {
+			return fmt.Errorf("failed to compress document: %w", err)
+		}
+	}
+
+	checksum := crc32.ChecksumIEEE([]byte(id))
+	checksum = crc32.Update(checksum, crc32.IEEETable, storedData)
+
+	head := make([]byte, segmentRecordHeaderSize)
+	head[0] = op
+	binary.LittleEndian.PutUint32(head[1:5], uint32(len(id)))
+	binary.LittleEndian.PutUint32(head[5:9], uint32(len(jsonData)))
+	binary.LittleEndian.PutUint32(head[9:13], uint32(len(storedData)))
+	binary.LittleEndian.PutUint32(head[13:17], checksum)
+
+	buf.Write(head)
+	buf.WriteString(id)
+	buf.Write(storedData)
+	return nil
+}
+
+func segmentHeader(codec Codec, base bool) []byte {
+	flags := codecFlags(codec)
+	if base {
+		flags |= FlagBaseSegment
+	}
+	buf := make([]byte, HeaderSize)
+	binary.LittleEndian.PutUint32(buf[0:4], SegmentMagic)
+	binary.LittleEndian.PutUint16(buf[4:6], SegmentFormatVersion)
+	binary.LittleEndian.PutUint16(buf[6:8], flags)
+	return buf
+}
+
+// saveSegmentsLocked persists a collection in segment files: the given
+// documents (nil for all written since the last save) are appended to the
+// newest segment, and once the appended records outgrow the base segment
+// every live document is merged into a new base segment (caller must hold
+// mu, at least for reading)
+func (c *Collection) saveSegmentsLocked(collDir string, codec Codec, ids []string) error {
+	s := &c.segments
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.changed == nil {
+		return c.writeBaseSegmentLocked(collDir, codec)
+	}
+	if ids == nil {
+		ids = make([]string, 0, len(s.changed))
+		for id := range s.changed {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	sort.Strings(ids)
+
+	var buf bytes.Buffer
+	for _, id := range ids {
+		doc := c.Documents[id]
+		if doc != nil {
+			var err error
+			if doc, err = c.loadLocked(doc); err != nil {
+				return err
+			}
+		}
+		if err := appendSegmentRecord(&buf, id, doc, codec); err != nil {
+			return err
+		}
+	}
+
+	if s.tail == "" || s.tailSize >= SegmentSize || s.tailCodec != codec {
+		s.tail = segmentPath(collDir, s.next)
+		s.tailSize = 0
+		s.tailCodec = codec
+		s.next++
+	}
+
+	f, err := os.OpenFile(s.tail, os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open segment: %w", err)
+	}
+	defer f.Close()
+	if s.tailSize == 0 {
+		if err := syncDir(collDir); err != nil {
+			return fmt.Errorf("failed to sync collection directory: %w", err)
+		}
+	}
+
+	data := buf.Bytes()
+	if s.tailSize == 0 {
+		data = append(segmentHeader(codec, false), data...)
+	}
+	// Drops anything after the valid records, such as a torn earlier save
+	if err := f.Truncate(s.tailSize); err != nil {
+		return fmt.Errorf("failed to truncate segment: %w", err)
+	}
+	if _, err := f.WriteAt(data, s.tailSize); err != nil {
+		return fmt.Errorf("failed to write segment: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync segment: %w", err)
+	}
+
+	s.tailSize += int64(len(data))
+	s.deltaBytes += int64(len(data))
+	for _, id := range ids {
+		delete(s.changed, id)
+	}
+
+	if s.deltaBytes > SegmentSize && s.deltaBytes > s.baseBytes {
+		return c.writeBaseSegmentLocked(collDir, codec)
+	}
+	return nil
+}
+
+// writeBaseSegmentLocked writes every live document to a new base segment
+// and removes the older segments (caller must hold mu and segments.mu)
+func (c *Collection) writeBaseSegmentLocked(collDir string, codec Codec) error {
+	s := &c.segments
+	if s.next == 0 {
+		numbers, err := listSegments(collDir)
+		if err != nil {
+			return fmt.Errorf("failed to list segments: %w", err)
+		}
+		s.next = 1
+		if len(numbers) > 0 {
+			s.next = numbers[len(numbers)-1] + 1
+		}
+	}
+
+	ids := make([]string, 0, len(c.Documents))
+	for id := range c.Documents {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var buf bytes.Buffer
+	buf.Write(segmentHeader(codec, true))
+	for _, id := range ids {
+		doc, err := c.loadLocked(c.Documents[id])
+		if err != nil {
+			return err
+		}
+		if err := appendSegmentRecord(&buf, id, doc, codec); err != nil {
+			return err
+		}
+	}
+
+	// Written atomically so a crash never leaves a partial base
+	path := segmentPath(collDir, s.next)
+	if err := writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(buf.Bytes())
+		return err
+	}); err != nil {
+		return fmt.Errorf("failed to write segment: %w", err)
+	}
+
+	if err := removeSegments(collDir, s.next); err != nil {
+		return fmt.Errorf("failed to remove merged segments: %w", err)
+	}
+	// The base also replaces the files of the binary and JSON formats
+	for _, name := range []string{"collection.data", "collection.idx", "documents.json", "indexes"} {
+		if err := os.RemoveAll(filepath.Join(collDir, name)); err != nil {
+			return fmt.Errorf("failed to remove %s: %w", name, err)
+		}
+	}
+
+	size := int64(buf.Len())
+	s.tail, s.tailSize, s.tailCodec = path, size, codec
+	s.baseBytes, s.deltaBytes = size, 0
+	s.next++
+	if s.changed == nil {
+		s.changed = make(map[string]bool)
+	} else {
+		clear(s.changed)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// StorageSize reports the disk usage of a database or collection in bytes
+type StorageSize struct {
+	DataBytes  int64            `json:"data_bytes"`        // collection.data, segment files or legacy documents.json
+	IndexBytes int64            `json:"index_bytes"`       // offset index plus persisted secondary indexes
+	Indexes    map[string]int64 `json:"indexes,omitempty"` // persisted index name -> bytes
+	MetaBytes  int64            `json:"meta_bytes"`        // metadata files
+	BlobBytes  int64            `json:"blob_bytes"`        // spilled values and attachments
+	WALBytes   int64            `json:"wal_bytes"`         // retained WAL entries for this database or collection
+}
+
+// Total returns the combined size of all parts
+func (s *StorageSize) Total() int64 {
+	return s.DataBytes + s.IndexBytes + s.MetaBytes + s.BlobBytes + s.WALBytes
+}
+
+func (s *StorageSize) add(other *StorageSize) {
+	s.DataBytes += other.DataBytes
+	s.IndexBytes += other.IndexBytes
+	s.MetaBytes += other.MetaBytes
+	s.BlobBytes += other.BlobBytes
+	s.WALBytes += other.WALBytes
+}
+
+// SizeOf reports the disk usage of a collection. If collName is empty, the
+// usage of the whole database is reported (per-index sizes are omitted).
+// The WAL share counts the retained log entries that belong to it.
+func (sm *StorageManager) SizeOf(dbName, collName string) (*StorageSize, error) {
+	dbDir := filepath.Join(sm.RootDir, dbName)
+	if _, err := os.Stat(dbDir); err != nil {
+		return nil, fmt.Errorf("database '%s' does not exist on disk", dbName)
+	}
+
+	size := &StorageSize{}
+	if collName != "" {
+		if err := collectionFileSizes(filepath.Join(dbDir, collName), size); err != nil {
+			return nil, err
+		}
+	} else {
+		size.MetaBytes += fileSize(filepath.Join(dbDir, "db.meta.json"))
+
+		entries, err := os.ReadDir(dbDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read database directory: %w", err)
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+			collSize := &StorageSize{}
+			if err := collectionFileSizes(filepath.Join(dbDir, entry.Name()), collSize); err != nil {
+				return nil, err
+			}
+			size.add(collSize)
+		}
+	}
+
+	walBytes, err := sm.WAL.Usage(dbName, collName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure WAL usage: %w", err)
+	}
+	size.WALBytes = walBytes
+
+	return size, nil
+}
+
+// collectionFileSizes measures the files of one collection directory
+func collectionFileSizes(collDir string, size *StorageSize) error {
+	if _, err := os.Stat(collDir); err != nil {
+		return fmt.Errorf("collection '%s' does not exist on disk", filepath.Base(collDir))
+	}
+
+	size.DataBytes += fileSize(filepath.Join(collDir, "collection.data"))
+	size.DataBytes += fileSize(filepath.Join(collDir, "documents.json"))
+	segments, err := listSegments(collDir)
+	if err != nil {
+		return fmt.Errorf("failed to list segments: %w", err)
+	}
+	for _, number := range segments {
+		size.DataBytes += fileSize(segmentPath(collDir, number))
+	}
+	size.IndexBytes += fileSize(filepath.Join(collDir, "collection.idx"))
+	size.MetaBytes += fileSize(filepath.Join(collDir, "collection.meta.json"))
+
+	blobs, err := os.ReadDir(filepath.Join(collDir, BlobDirName))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to read blob directory: %w", err)
+	}
+	for _, entry := range blobs {
+		if !entry.IsDir() {
+			size.BlobBytes += fileSize(filepath.Join(collDir, BlobDirName, entry.Name()))
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(collDir, "indexes"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read index directory: %w", err)
+	}
+
+	size.Indexes = make(map[string]int64)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		bytes := fileSize(filepath.Join(collDir, "indexes", entry.Name()))
+		size.Indexes[strings.TrimSuffix(entry.Name(), ".json")] = bytes
+		size.IndexBytes += bytes
+	}
+
+	return nil
+}
+
+// fileSize returns the size of a file, or 0 if it does not exist
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Usage returns the number of bytes the retained WAL files spend on entries
+// for a database, or for a single collection if collName is not empty
+func (wm *WALManager) Usage(dbName, collName string) (int64, error) {
+	if err := wm.Flush(); err != nil {
+		return 0, err
+	}
+
+	entries, err := wm.ReadFrom(0)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, entry := range entries {
+		if entry.Database != dbName || (collName != "" && entry.Collection != collName) {
+			continue
+		}
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return 0, err
+		}
+		total += int64(8 + len(data)) // length and checksum header plus payload
+	}
+
+	return total, nil
+}
@@ -0,0 +1,277 @@
+package db
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SnapshotInfo describes a snapshot written by StorageManager.Snapshot
+type SnapshotInfo struct {
+	Dir     string    `json:"dir"`
+	Created time.Time `json:"created"`
+	// WALOffset is the offset of the first WAL entry not in the snapshot
+	WALOffset uint64 `json:"wal_offset"`
+	Files     int    `json:"files"`
+	// Linked counts the files hard-linked rather than copied
+	Linked int   `json:"linked"`
+	Bytes  int64 `json:"bytes"`
+}
+
+// Snapshot writes a point-in-time copy of the root directory to dir, which
+// must be empty or not exist, and must lie outside the root directory. The
+// copy can be opened like any root directory; the WAL entries logged up to
+// the snapshot are replayed when it is.
+//
+// Saves, compactions and checkpoints wait while the files are copied, so
+// the data files do not change, but writes keep being served and logged to
+// the WAL. The WAL is cut at the last entry written when the snapshot
+// starts. Files that are only ever replaced whole, such as indexes, blobs
+// and segments that are no longer appended to, are hard-linked when dir is
+// on the same file system; the rest are copied.
+func (sm *StorageManager) Snapshot(dir string) (*SnapshotInfo, error) {
+	if sm.ReadOnly {
+		// Without the lock, another process may change files mid-copy
+		return nil, fmt.Errorf("cannot snapshot a directory opened read-only: %w", ErrReadOnly)
+	}
+	if err := checkSnapshotDir(sm.RootDir, dir); err != nil {
+		return nil, err
+	}
+
+	sm.snapshotMu.Lock()
+	defer sm.snapshotMu.Unlock()
+
+	wal, err := sm.WAL.snapshot()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAL position: %w", err)
+	}
+	defer wal.close()
+
+	info := &SnapshotInfo{Dir: dir, Created: time.Now(), WALOffset: wal.offset}
+	if err := sm.copySnapshot(dir, wal, info); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return info, nil
+}
+
+// checkSnapshotDir rejects a snapshot directory that has files in it or is
+// inside rootDir, where the snapshot would copy itself
+func checkSnapshotDir(rootDir, dir string) error {
+	root, err := filepath.Abs(rootDir)
+	if err != nil {
+		return err
+	}
+	target, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(root, target); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("snapshot directory %s is inside the root directory", dir)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read snapshot directory: %w", err)
+	}
+	if len(entries) > 0 {
+		return fmt.Errorf("snapshot directory %s is not empty", dir)
+	}
+	return nil
+}
+
+// copySnapshot copies the root directory into dir (caller must hold
+// snapshotMu). WAL files are copied from the handles in wal rather than
+// from the directory.
+func (sm *StorageManager) copySnapshot(dir string, wal *walSnapshot, info *SnapshotInfo) error {
+	var dirs []string
+	tails := make(map[string]string) // collection directory -> newest segment
+	err := filepath.WalkDir(sm.RootDir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(sm.RootDir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dir, rel)
+
+		if entry.IsDir() {
+			dirs = append(dirs, target)
+			return os.MkdirAll(target, 0755)
+		}
+		if !entry.Type().IsRegular() || skipInSnapshot(rel) {
+			return nil
+		}
+
+		fileInfo, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		info.Files++
+		info.Bytes += fileInfo.Size()
+
+		appended, err := appendedInPlace(path, tails)
+		if err != nil {
+			return err
+		}
+		if !appended && os.Link(path, target) == nil {
+			info.Linked++
+			return nil
+		}
+		return copyFile(path, target, 0644)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %w", sm.RootDir, err)
+	}
+
+	for i, file := range wal.files {
+		size := wal.sizes[i]
+		if err := copyFilePrefix(file, filepath.Join(dir, filepath.Base(file.Name())), size); err != nil {
+			return fmt.Errorf("failed to copy WAL: %w", err)
+		}
+		info.Files++
+		info.Bytes += size
+	}
+	// The checkpoint of a separate WAL directory goes to the snapshot's root
+	// along with the WAL files, so the snapshot opens on its own
+	if sm.WAL.rootDir != sm.RootDir {
+		checkpoint := filepath.Join(sm.WAL.rootDir, WALCheckpointFile)
+		if _, err := os.Stat(checkpoint); err == nil {
+			if err := copyFile(checkpoint, filepath.Join(dir, WALCheckpointFile), 0644); err != nil {
+				return fmt.Errorf("failed to copy WAL checkpoint: %w", err)
+			}
+			info.Files++
+		}
+	}
+
+	// New directory entries must survive a crash like the files themselves
+	for _, d := range dirs {
+		if err := syncDir(d); err != nil {
+			return fmt.Errorf("failed to sync snapshot directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// skipInSnapshot reports whether a file of the root directory (relative
+// path) is left out of snapshots: the lock file, temporary files of
+// unfinished writes, and WAL files, which are copied separately
+func skipInSnapshot(rel string) bool {
+	name := filepath.Base(rel)
+	if strings.HasPrefix(name, ".") && strings.Contains(name, ".tmp-") {
+		return true
+	}
+	if filepath.Dir(rel) == "." {
+		return name == LockFileName || strings.HasPrefix(name, WALFilePrefix)
+	}
+	return false
+}
+
+// appendedInPlace reports whether saves write into the existing file at
+// path instead of replacing it: binary data files and the newest segment of
+// a collection. Hard links to those would change with the original.
+func appendedInPlace(path string, tails map[string]string) (bool, error) {
+	name := filepath.Base(path)
+	if name == "collection.data" {
+		return true, nil
+	}
+	if !strings.HasPrefix(name, segmentFilePrefix) || !strings.HasSuffix(name, segmentFileSuffix) {
+		return false, nil
+	}
+
+	collDir := filepath.Dir(path)
+	tail, seen := tails[collDir]
+	if !seen {
+		numbers, err := listSegments(collDir)
+		if err != nil {
+			return false, err
+		}
+		if len(numbers) > 0 {
+			tail = segmentPath(collDir, numbers[len(numbers)-1])
+		}
+		tails[collDir] = tail
+	}
+	return path == tail, nil
+}
+
+// copyFilePrefix copies the first size bytes of src to a new file and syncs it
+func copyFilePrefix(src *os.File, dst string, size int64) error {
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, io.NewSectionReader(src, 0, size)); err != nil {
+		out.Close()
+		return fmt.Errorf("failed to copy %s: %w", src.Name(), err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// walSnapshot holds the WAL files as of a point in time. They are kept open
+// so that a rotation cannot remove them before they are copied.
+type walSnapshot struct {
+	offset uint64 // offset of the next entry to be logged
+	files  []*os.File
+	sizes  []int64 // bytes of each file written by then
+}
+
+// snapshot writes out pending entries and opens every WAL file, recording
+// how much of the current one holds complete entries
+func (wm *WALManager) snapshot() (*walSnapshot, error) {
+	// Entries are given offsets under batchMu, so none is in flight
+	wm.batchMu.Lock()
+	defer wm.batchMu.Unlock()
+	if err := wm.flushBatchLocked(); err != nil {
+		return nil, err
+	}
+
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	names, err := wm.getWALFilesLocked()
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &walSnapshot{offset: wm.currentOffset}
+	for _, name := range names {
+		path := filepath.Join(wm.rootDir, name)
+		file, err := os.Open(path)
+		if err != nil {
+			snap.close()
+			return nil, err
+		}
+		snap.files = append(snap.files, file)
+
+		if wm.currentFile != nil && path == wm.currentFile.Name() {
+			snap.sizes = append(snap.sizes, wm.currentSize)
+			continue
+		}
+		stat, err := file.Stat()
+		if err != nil {
+			snap.close()
+			return nil, err
+		}
+		snap.sizes = append(snap.sizes, stat.Size())
+	}
+	return snap, nil
+}
+
+func (s *walSnapshot) close() {
+	for _, file := range s.files {
+		file.Close()
+	}
+}
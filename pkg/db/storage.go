@@ -2,11 +2,15 @@ package db
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,9 +20,19 @@ type StorageFormat string
 const (
 	FormatJSON   StorageFormat = "json"
 	FormatBinary StorageFormat = "binary"
+	// FormatSegmented appends the documents changed since the last save to
+	// segment files instead of rewriting the collection (see segment.go)
+	FormatSegmented StorageFormat = "segmented"
 
 	// StorageSyncInterval is how often to sync dirty data to storage
 	StorageSyncInterval = 5 * time.Second
+
+	// ExpiryInterval is how often documents past the TTL of a TTL index are deleted
+	ExpiryInterval = 30 * time.Second
+
+	// LoadWorkers bounds how many databases, and how many collections of
+	// each database, are loaded at once at startup
+	LoadWorkers = 8
 )
 
 // DirtyEntry tracks a dirty database/collection that needs to be saved
@@ -26,6 +40,21 @@ type DirtyEntry struct {
 	Database   string
 	Collection string // empty means entire database
 	Timestamp  time.Time
+	// Documents holds the IDs of the changed documents of a collection;
+	// nil means the whole collection is dirty
+	Documents map[string]bool
+}
+
+// merge adds the changes of a later entry for the same key
+func (e *DirtyEntry) merge(later *DirtyEntry) {
+	e.Timestamp = later.Timestamp
+	if e.Documents == nil || later.Documents == nil {
+		e.Documents = nil
+		return
+	}
+	for id := range later.Documents {
+		e.Documents[id] = true
+	}
 }
 
 // StorageManager handles persistence
@@ -39,37 +68,171 @@ type StorageManager struct {
 	syncTicker *time.Ticker
 	stopChan   chan struct{}
 	wg         sync.WaitGroup
+	lock       *os.File // held lock file; nil for read-only managers
+	walLock    *os.File // held lock file of a separate WAL directory
+
+	// snapshotMu is held for reading while data files, indexes and the
+	// checkpoint change, and for writing by Snapshot while it copies them
+	snapshotMu sync.RWMutex
+
+	// checkpointNow wakes the background syncer for a checkpoint triggered
+	// by the CheckpointPolicy; checkpointMu guards checkpointStats
+	checkpointNow   chan string
+	checkpointMu    sync.Mutex
+	checkpointStats CheckpointStats
+
+	// lastReplay summarizes the WAL replay of LoadAllDatabases
+	lastReplay *ReplayResult
+	// replica is set while following a leader (see SetReplica)
+	replica atomic.Bool
+
+	// ReadOnly managers do not lock the directory and refuse every write
+	// with ErrReadOnly (see NewReadOnlyStorageManager)
+	ReadOnly bool
+
+	// WALSync decides whether logged changes are fsynced before Log* methods return
+	WALSync WALSyncPolicy
+	// CheckpointPolicy checkpoints between the periodic syncs once the WAL
+	// grows past its thresholds
+	CheckpointPolicy CheckpointPolicy
+	// Compression gzips documents written to binary data files and segments
+	// of collections that do not set their own Codec
+	Compression bool
+	// Verbose logs every background sync, not just failures
+	Verbose bool
+	// LazyLoad makes LoadDatabase read only collection metadata; documents
+	// are loaded when a collection is first accessed
+	LazyLoad bool
 }
 
-// NewStorageManager creates a new storage manager
+// NewStorageManager creates a new storage manager. It locks the root
+// directory until Close, and fails with a *LockedError if another process
+// (or storage manager) holds it.
 func NewStorageManager(rootDir string) (*StorageManager, error) {
+	return NewStorageManagerWithWAL(rootDir, WALConfig{})
+}
+
+// NewStorageManagerWithWAL creates a storage manager whose WAL is tuned by
+// config (see WALConfig). A separate WAL directory (config.Dir) is locked
+// along with the root directory.
+func NewStorageManagerWithWAL(rootDir string, config WALConfig) (*StorageManager, error) {
+	walDir, err := walDirOf(rootDir, config)
+	if err != nil {
+		return nil, err
+	}
 	if err := os.MkdirAll(rootDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create root directory: %w", err)
 	}
 
-	wal, err := NewWALManager(rootDir)
+	lock, err := lockDir(rootDir)
 	if err != nil {
+		return nil, err
+	}
+	var walLock *os.File
+	if walDir != rootDir {
+		if err := os.MkdirAll(walDir, 0755); err != nil {
+			lock.Close()
+			return nil, fmt.Errorf("failed to create WAL directory: %w", err)
+		}
+		if walLock, err = lockDir(walDir); err != nil {
+			lock.Close()
+			return nil, err
+		}
+	}
+
+	wal, err := NewWALManager(walDir, config)
+	if err != nil {
+		lock.Close()
+		if walLock != nil {
+			walLock.Close()
+		}
 		return nil, fmt.Errorf("failed to create WAL manager: %w", err)
 	}
 
-	sm := &StorageManager{
+	sm := newStorageManager(rootDir, wal)
+	sm.lock = lock
+	sm.walLock = walLock
+	return sm, nil
+}
+
+// walDirOf returns the directory of the WAL of rootDir: config.Dir, or the
+// root directory itself. A WAL directory inside the root directory would be
+// loaded as a database, so it is rejected.
+func walDirOf(rootDir string, config WALConfig) (string, error) {
+	if config.Dir == "" {
+		return rootDir, nil
+	}
+	root, err := filepath.Abs(rootDir)
+	if err != nil {
+		return "", err
+	}
+	dir, err := filepath.Abs(config.Dir)
+	if err != nil {
+		return "", err
+	}
+	if dir == root {
+		return rootDir, nil
+	}
+	if rel, err := filepath.Rel(root, dir); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("WAL directory %s is inside the root directory", config.Dir)
+	}
+	return config.Dir, nil
+}
+
+// NewReadOnlyStorageManager opens an existing root directory without
+// locking it, for inspecting data that another process may be using. WAL
+// entries not yet saved are replayed into memory only, and every write
+// fails with ErrReadOnly. A process writing concurrently can make loads
+// fail or see a mix of old and new files; retry in that case.
+func NewReadOnlyStorageManager(rootDir string) (*StorageManager, error) {
+	return NewReadOnlyStorageManagerWithWAL(rootDir, WALConfig{})
+}
+
+// NewReadOnlyStorageManagerWithWAL opens a root directory like
+// NewReadOnlyStorageManager, reading its WAL from config.Dir if set
+func NewReadOnlyStorageManagerWithWAL(rootDir string, config WALConfig) (*StorageManager, error) {
+	if _, err := os.Stat(rootDir); err != nil {
+		return nil, fmt.Errorf("failed to open root directory: %w", err)
+	}
+	walDir, err := walDirOf(rootDir, config)
+	if err != nil {
+		return nil, err
+	}
+
+	wal, err := newReadOnlyWALManager(walDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create WAL manager: %w", err)
+	}
+
+	sm := newStorageManager(rootDir, wal)
+	sm.ReadOnly = true
+	return sm, nil
+}
+
+func newStorageManager(rootDir string, wal *WALManager) *StorageManager {
+	return &StorageManager{
 		RootDir:    rootDir,
 		WAL:        wal,
 		Format:     FormatBinary, // Use binary format by default
 		dirty:      make(map[string]*DirtyEntry),
 		syncTicker: time.NewTicker(StorageSyncInterval),
 		stopChan:   make(chan struct{}),
-	}
 
-	return sm, nil
+		checkpointNow: make(chan string, 1),
+
+		WALSync:     WALSyncAlways,
+		Compression: true,
+	}
 }
 
-// StartBackgroundSync starts the background storage syncer
-// Must be called after LoadAllDatabases sets dbManager
+// StartBackgroundSync starts the background storage syncer and the expiry
+// of documents under TTL indexes
+// Must be called after LoadAllDatabases
 func (sm *StorageManager) StartBackgroundSync(dbManager *DatabaseManager) {
 	sm.dbManager = dbManager
-	sm.wg.Add(1)
+	sm.wg.Add(2)
 	go sm.backgroundStorageSyncer()
+	go sm.backgroundExpirer()
 }
 
 // backgroundStorageSyncer periodically saves dirty data to storage
@@ -80,20 +243,34 @@ func (sm *StorageManager) backgroundStorageSyncer() {
 		select {
 		case <-sm.stopChan:
 			// Final sync before shutdown
-			sm.syncDirtyToStorage()
+			sm.syncDirtyToStorage(CheckpointTriggerInterval)
 			return
 		case <-sm.syncTicker.C:
-			sm.syncDirtyToStorage()
+			sm.syncDirtyToStorage(CheckpointTriggerInterval)
+		case trigger := <-sm.checkpointNow:
+			sm.syncDirtyToStorage(trigger)
 		}
 	}
 }
 
 // syncDirtyToStorage saves all dirty entries to storage and checkpoints
-func (sm *StorageManager) syncDirtyToStorage() {
+func (sm *StorageManager) syncDirtyToStorage(trigger string) {
+	if err := sm.syncAndCheckpoint(trigger, false); err != nil {
+		fmt.Printf("Failed to checkpoint after storage sync: %v\n", err)
+	}
+}
+
+// syncAndCheckpoint saves all dirty entries to storage and checkpoints.
+// Entries that fail to save are marked dirty again; with strict set, they
+// also keep the checkpoint where it was. Without dirty entries, only a
+// strict call checkpoints.
+func (sm *StorageManager) syncAndCheckpoint(trigger string, strict bool) error {
+	start := time.Now()
+
 	sm.dirtyMu.Lock()
-	if len(sm.dirty) == 0 {
+	if len(sm.dirty) == 0 && !strict {
 		sm.dirtyMu.Unlock()
-		return
+		return nil
 	}
 
 	// Copy dirty entries
@@ -105,45 +282,79 @@ func (sm *StorageManager) syncDirtyToStorage() {
 	sm.dirtyMu.Unlock()
 
 	if sm.dbManager == nil {
-		return
+		return nil
 	}
 
+	// Collections are looked up first, as loading one may take snapshotMu
+	databases := make(map[string]*Database, len(toSync))
+	collections := make(map[string]*Collection, len(toSync))
+	for key, entry := range toSync {
+		db := sm.dbManager.GetDatabase(entry.Database)
+		if db == nil {
+			continue
+		}
+		databases[key] = db
+		if entry.Collection != "" {
+			if coll, err := db.GetCollection(entry.Collection); err == nil {
+				collections[key] = coll
+			}
+		}
+	}
+
+	// Held until the checkpoint, so a snapshot never has the saved files
+	// with the checkpoint from before them
+	sm.snapshotMu.RLock()
+	defer sm.snapshotMu.RUnlock()
+
 	// Save each dirty entry
+	failed := 0
 	for key, entry := range toSync {
 		var err error
 		if entry.Collection == "" {
 			// Save entire database
-			db := sm.dbManager.GetDatabase(entry.Database)
-			if db != nil {
-				err = sm.SaveDatabase(db)
+			if db := databases[key]; db != nil {
+				err = sm.saveDatabaseLocked(db)
 			}
-		} else {
-			// Save specific collection
-			db := sm.dbManager.GetDatabase(entry.Database)
-			if db != nil {
-				coll, cerr := db.GetCollection(entry.Collection)
-				if cerr == nil {
-					err = sm.SaveCollection(entry.Database, coll)
-				}
+		} else if coll := collections[key]; coll != nil {
+			// Save specific collection, or just its changed documents
+			if entry.Documents != nil {
+				err = sm.saveDocumentsLocked(entry.Database, coll, entry.Documents)
+			} else {
+				err = sm.saveCollectionLocked(entry.Database, coll)
 			}
 		}
 		if err != nil {
-			// Re-add to dirty on failure
+			// Re-add to dirty on failure, keeping changes marked since
 			sm.dirtyMu.Lock()
+			if later, exists := sm.dirty[key]; exists {
+				entry.merge(later)
+			}
 			sm.dirty[key] = entry
 			sm.dirtyMu.Unlock()
 			fmt.Printf("Failed to sync %s to storage: %v\n", key, err)
+			failed++
 		}
 	}
+	if sm.Verbose {
+		log.Printf("Synced %d of %d changed database(s)/collection(s) to storage\n", len(toSync)-failed, len(toSync))
+	}
+
+	if strict && failed > 0 {
+		return fmt.Errorf("failed to save %d of %d changed database(s)/collection(s); the checkpoint was not moved", failed, len(toSync))
+	}
 
 	// Checkpoint after successful sync
-	if err := sm.Checkpoint(); err != nil {
-		fmt.Printf("Failed to checkpoint after storage sync: %v\n", err)
+	if err := sm.checkpointLocked(); err != nil {
+		return err
 	}
+	sm.recordCheckpoint(trigger, time.Since(start))
+	return nil
 }
 
-// MarkDirty marks a database or collection as needing to be saved
-func (sm *StorageManager) MarkDirty(dbName, collName string) {
+// MarkDirty marks a database or collection as needing to be saved. Passing
+// the IDs of the changed documents lets a collection stored in segments save
+// only those; without them the whole collection is saved.
+func (sm *StorageManager) MarkDirty(dbName, collName string, docIDs ...string) {
 	sm.dirtyMu.Lock()
 	defer sm.dirtyMu.Unlock()
 
@@ -152,11 +363,51 @@ func (sm *StorageManager) MarkDirty(dbName, collName string) {
 		key = dbName + "/" + collName
 	}
 
-	sm.dirty[key] = &DirtyEntry{
+	entry := &DirtyEntry{
 		Database:   dbName,
 		Collection: collName,
 		Timestamp:  time.Now(),
 	}
+	if collName != "" && len(docIDs) > 0 {
+		entry.Documents = make(map[string]bool, len(docIDs))
+		for _, id := range docIDs {
+			entry.Documents[id] = true
+		}
+	}
+
+	if existing, exists := sm.dirty[key]; exists {
+		existing.merge(entry)
+		return
+	}
+	sm.dirty[key] = entry
+}
+
+// DirtyStats is the backlog of changes waiting to be saved by the
+// background syncer
+type DirtyStats struct {
+	// Entries are the databases and collections marked dirty
+	Entries int `json:"entries"`
+	// Documents are the changed documents of collections saved document by
+	// document; collections saved whole are only counted in Entries
+	Documents int `json:"documents"`
+}
+
+// DirtyStats returns the changes not yet saved to the data files
+func (sm *StorageManager) DirtyStats() DirtyStats {
+	sm.dirtyMu.Lock()
+	defer sm.dirtyMu.Unlock()
+
+	stats := DirtyStats{Entries: len(sm.dirty)}
+	for _, entry := range sm.dirty {
+		stats.Documents += len(entry.Documents)
+	}
+	return stats
+}
+
+// LastReplay returns the summary of the WAL replay done by
+// LoadAllDatabases, or nil before it ran
+func (sm *StorageManager) LastReplay() *ReplayResult {
+	return sm.lastReplay
 }
 
 // Close closes the storage manager and flushes WAL
@@ -171,14 +422,37 @@ func (sm *StorageManager) Close() error {
 	}
 
 	// Close WAL
+	var err error
 	if sm.WAL != nil {
-		return sm.WAL.Close()
+		err = sm.WAL.Close()
 	}
-	return nil
+
+	// Released last, once nothing more is written
+	if sm.lock != nil {
+		sm.lock.Close()
+		sm.lock = nil
+	}
+	if sm.walLock != nil {
+		sm.walLock.Close()
+		sm.walLock = nil
+	}
+	return err
 }
 
 // SaveDatabase saves the entire database to disk
 func (sm *StorageManager) SaveDatabase(db *Database) error {
+	sm.snapshotMu.RLock()
+	defer sm.snapshotMu.RUnlock()
+	return sm.saveDatabaseLocked(db)
+}
+
+// saveDatabaseLocked saves the entire database to disk (caller must hold
+// snapshotMu for reading)
+func (sm *StorageManager) saveDatabaseLocked(db *Database) error {
+	if sm.ReadOnly {
+		return ErrReadOnly
+	}
+
 	dbDir := filepath.Join(sm.RootDir, db.Name)
 	if err := os.MkdirAll(dbDir, 0755); err != nil {
 		return fmt.Errorf("failed to create database directory: %w", err)
@@ -199,7 +473,7 @@ func (sm *StorageManager) SaveDatabase(db *Database) error {
 	defer db.mu.RUnlock()
 
 	for _, coll := range db.Collections {
-		if err := sm.SaveCollection(db.Name, coll); err != nil {
+		if err := sm.saveCollectionLocked(db.Name, coll); err != nil {
 			return fmt.Errorf("failed to save collection '%s': %w", coll.Name, err)
 		}
 	}
@@ -209,30 +483,58 @@ func (sm *StorageManager) SaveDatabase(db *Database) error {
 
 // SaveCollection saves a collection to disk
 func (sm *StorageManager) SaveCollection(dbName string, coll *Collection) error {
+	sm.snapshotMu.RLock()
+	defer sm.snapshotMu.RUnlock()
+	return sm.saveCollectionLocked(dbName, coll)
+}
+
+// saveCollectionLocked saves a collection to disk (caller must hold
+// snapshotMu for reading)
+func (sm *StorageManager) saveCollectionLocked(dbName string, coll *Collection) error {
+	if sm.ReadOnly {
+		return ErrReadOnly
+	}
+
+	// Placeholders of lazily loaded collections are unchanged on disk
+	if coll.Ephemeral || coll.unloaded {
+		return nil
+	}
+
 	collDir := filepath.Join(sm.RootDir, dbName, coll.Name)
 	if err := os.MkdirAll(collDir, 0755); err != nil {
 		return fmt.Errorf("failed to create collection directory: %w", err)
 	}
 
+	sm.AttachBlobStore(dbName, coll)
+
+	// Runs after the lock below is released
+	defer coll.evict()
+
 	coll.mu.RLock()
 	defer coll.mu.RUnlock()
 
 	// Save collection metadata (schema and index definitions)
 	metaPath := filepath.Join(collDir, "collection.meta.json")
-	meta := struct {
-		Name    string            `json:"name"`
-		Schema  *Schema           `json:"schema,omitempty"`
-		Indexes map[string]string `json:"indexes"` // index name -> field name
-		Format  StorageFormat     `json:"format"`  // Storage format
-	}{
-		Name:    coll.Name,
-		Schema:  coll.Schema,
-		Indexes: make(map[string]string),
-		Format:  sm.Format,
+	meta := collectionMeta{
+		Name:         coll.Name,
+		Schema:       coll.Schema,
+		Indexes:      make(map[string]string),
+		Format:       sm.Format,
+		IDStrategy:   coll.IDStrategy,
+		BlobPolicy:   coll.BlobPolicy,
+		Collation:    coll.Collation,
+		MemoryBudget: coll.MemoryBudget,
+		Codec:        coll.Codec,
 	}
 
 	for name, idx := range coll.Indexes {
 		meta.Indexes[name] = idx.FieldName
+		if opts := idx.Options(); opts != (IndexOptions{Type: IndexHash}) {
+			if meta.IndexOptions == nil {
+				meta.IndexOptions = make(map[string]IndexOptions)
+			}
+			meta.IndexOptions[name] = opts
+		}
 	}
 
 	if err := sm.writeJSON(metaPath, meta); err != nil {
@@ -241,34 +543,96 @@ func (sm *StorageManager) SaveCollection(dbName string, coll *Collection) error
 
 	// Save based on format
 	if sm.Format == FormatBinary {
-		// Save to binary format with compression
-		writer, err := NewBinaryCollectionWriter(sm.RootDir, dbName, coll.Name)
+		// Evicted bodies are only in the data file; read them first if the
+		// writer is going to start the file over
+		var reloaded map[string]*Document
+		if coll.cache != nil && binaryFileNeedsRewrite(sm.RootDir, dbName, coll.Name, sm.codecFor(coll)) {
+			reloaded = make(map[string]*Document)
+			for id, doc := range coll.Documents {
+				if doc.evicted {
+					body, err := coll.loadLocked(doc)
+					if err != nil {
+						return err
+					}
+					reloaded[id] = body
+				}
+			}
+		}
+
+		// A truncated collection starts its files over instead of keeping
+		// the removed documents until a compaction
+		if coll.truncated.Load() {
+			for _, name := range []string{"collection.data", "collection.idx"} {
+				if err := os.Remove(filepath.Join(collDir, name)); err != nil && !os.IsNotExist(err) {
+					return fmt.Errorf("failed to reset %s: %w", name, err)
+				}
+			}
+		}
+
+		// Save to binary format with the collection's codec
+		writer, err := newBinaryCollectionWriter(sm.RootDir, dbName, coll.Name, sm.codecFor(coll))
 		if err != nil {
 			return fmt.Errorf("failed to create binary writer: %w", err)
 		}
 		defer writer.Close(sm.RootDir, dbName, coll.Name)
 
-		for _, doc := range coll.Documents {
+		for id, doc := range coll.Documents {
+			if doc.evicted {
+				if doc = reloaded[id]; doc == nil {
+					continue // unchanged since it was written, so its entry is kept
+				}
+			}
 			if err := writer.WriteDocument(doc); err != nil {
 				return fmt.Errorf("failed to write document: %w", err)
 			}
 		}
 
+		// Drop offsets of deleted documents so they are not loaded again
+		for docID := range writer.index.Entries {
+			if _, exists := coll.Documents[docID]; !exists {
+				delete(writer.index.Entries, docID)
+			}
+		}
+
 		if err := writer.Flush(sm.RootDir, dbName, coll.Name); err != nil {
 			return fmt.Errorf("failed to flush writer: %w", err)
 		}
 
+		// Saved bodies may now be evicted, and are read back at their new offsets
+		if coll.cache != nil {
+			if err := sm.attachCacheReader(dbName, coll); err != nil {
+				return err
+			}
+			for _, doc := range coll.Documents {
+				coll.noteStored(doc)
+			}
+		}
+
 		// Save indexes to disk
 		for _, idx := range coll.Indexes {
 			if err := idx.SaveToDisk(sm.RootDir, dbName, coll.Name); err != nil {
 				return fmt.Errorf("failed to save index %s: %w", idx.Name, err)
 			}
 		}
+
+		// Left over if the collection was stored in segments before
+		if err := removeSegments(collDir, 0); err != nil {
+			return fmt.Errorf("failed to remove old segments: %w", err)
+		}
+	} else if sm.Format == FormatSegmented {
+		// Only changed documents are written; indexes are rebuilt on load
+		if err := coll.saveSegmentsLocked(collDir, sm.codecFor(coll), nil); err != nil {
+			return fmt.Errorf("failed to save segments: %w", err)
+		}
 	} else {
 		// Save to JSON format (legacy)
 		docsPath := filepath.Join(collDir, "documents.json")
 		docs := make([]*Document, 0, len(coll.Documents))
 		for _, doc := range coll.Documents {
+			doc, err := coll.loadLocked(doc)
+			if err != nil {
+				return err
+			}
 			docs = append(docs, doc)
 		}
 
@@ -277,6 +641,134 @@ func (sm *StorageManager) SaveCollection(dbName string, coll *Collection) error
 		}
 	}
 
+	// Remove blobs no longer referenced by any document
+	referenced, err := coll.referencedBlobsLocked()
+	if err != nil {
+		return fmt.Errorf("failed to clean up blobs: %w", err)
+	}
+	if err := coll.blobs.Collect(referenced); err != nil {
+		return fmt.Errorf("failed to clean up blobs: %w", err)
+	}
+
+	coll.truncated.Store(false)
+	return nil
+}
+
+// LogOption adjusts how a Log* method writes its WAL entry
+type LogOption func(*logOptions)
+
+type logOptions struct {
+	sync WALSyncPolicy
+}
+
+// LogSync logs the entry with the given sync policy instead of the storage
+// manager's WALSync, e.g. to make a single write durable on a server that
+// batches its WAL writes
+func LogSync(policy WALSyncPolicy) LogOption {
+	return func(o *logOptions) {
+		o.sync = policy
+	}
+}
+
+// appendWAL logs an entry according to the WAL sync policy. Entries for
+// ephemeral collections are dropped.
+func (sm *StorageManager) appendWAL(entry *WALEntry, opts ...LogOption) error {
+	if sm.IsReplica() {
+		return ErrReplica
+	}
+	if entry.Collection != "" && sm.isEphemeral(entry.Database, entry.Collection) {
+		return nil
+	}
+
+	options := logOptions{sync: sm.WALSync}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	var err error
+	switch options.sync {
+	case WALSyncBatch:
+		err = sm.WAL.AppendEntry(entry)
+	case WALSyncGroup:
+		err = sm.WAL.AppendEntryGroup(entry)
+	default:
+		err = sm.WAL.AppendEntrySync(entry)
+	}
+	if err != nil {
+		return err
+	}
+
+	sm.checkCheckpointPolicy()
+	return nil
+}
+
+// AttachBlobStore gives a collection its on-disk blob store if it has none yet
+func (sm *StorageManager) AttachBlobStore(dbName string, coll *Collection) {
+	coll.mu.Lock()
+	defer coll.mu.Unlock()
+	if coll.blobs == nil {
+		coll.blobs = NewBlobStore(filepath.Join(sm.RootDir, dbName, coll.Name, BlobDirName))
+	}
+}
+
+// attachCacheReader points a collection with a memory budget at its data
+// file, which evicted document bodies are read back from
+func (sm *StorageManager) attachCacheReader(dbName string, coll *Collection) error {
+	reader, err := NewBinaryCollectionReader(sm.RootDir, dbName, coll.Name)
+	if err != nil {
+		return fmt.Errorf("failed to open data file for reloading documents: %w", err)
+	}
+	coll.setCacheReader(reader)
+	return nil
+}
+
+// isEphemeral reports whether a collection of the loaded databases is
+// ephemeral; it is false before LoadAllDatabases
+func (sm *StorageManager) isEphemeral(dbName, collName string) bool {
+	if sm.dbManager == nil {
+		return false
+	}
+	db := sm.dbManager.GetDatabase(dbName)
+	if db == nil {
+		return false
+	}
+	coll, err := db.GetCollection(collName)
+	return err == nil && coll.Ephemeral
+}
+
+// SaveDocuments saves the given documents of a collection. A collection
+// stored in segments only appends them, without rewriting its metadata or
+// cleaning up blobs; in other formats the whole collection is saved.
+func (sm *StorageManager) SaveDocuments(dbName string, coll *Collection, docIDs map[string]bool) error {
+	sm.snapshotMu.RLock()
+	defer sm.snapshotMu.RUnlock()
+	return sm.saveDocumentsLocked(dbName, coll, docIDs)
+}
+
+// saveDocumentsLocked saves the given documents of a collection (caller
+// must hold snapshotMu for reading)
+func (sm *StorageManager) saveDocumentsLocked(dbName string, coll *Collection, docIDs map[string]bool) error {
+	if sm.ReadOnly {
+		return ErrReadOnly
+	}
+	if sm.Format != FormatSegmented || !coll.segments.tracking() {
+		return sm.saveCollectionLocked(dbName, coll)
+	}
+	if coll.Ephemeral || coll.unloaded {
+		return nil
+	}
+
+	ids := make([]string, 0, len(docIDs))
+	for id := range docIDs {
+		ids = append(ids, id)
+	}
+
+	coll.mu.RLock()
+	defer coll.mu.RUnlock()
+
+	collDir := filepath.Join(sm.RootDir, dbName, coll.Name)
+	if err := coll.saveSegmentsLocked(collDir, sm.codecFor(coll), ids); err != nil {
+		return fmt.Errorf("failed to save segments: %w", err)
+	}
 	return nil
 }
 
@@ -326,32 +818,67 @@ func (sm *StorageManager) LoadDatabase(dbName string) (*Database, error) {
 		return nil, fmt.Errorf("failed to read database directory: %w", err)
 	}
 
+	var names []string
 	for _, entry := range entries {
-		if entry.IsDir() {
-			coll, err := sm.LoadCollection(dbName, entry.Name())
-			if err != nil {
-				return nil, fmt.Errorf("failed to load collection '%s': %w", entry.Name(), err)
-			}
-			db.Collections[coll.Name] = coll
+		if !entry.IsDir() {
+			continue
+		}
+		// A collection is only saved some time after it is created, but its
+		// blob files are written right away; its creation is in the WAL
+		if _, err := os.Stat(filepath.Join(dbDir, entry.Name(), "collection.meta.json")); os.IsNotExist(err) {
+			fmt.Printf("Warning: skipping directory '%s' of database '%s': it has no collection metadata\n", entry.Name(), dbName)
+			continue
+		}
+		names = append(names, entry.Name())
+	}
+
+	colls := make([]*Collection, len(names))
+	errs := make([]error, len(names))
+	forEachConcurrently(len(names), func(i int) {
+		if sm.LazyLoad {
+			colls[i], errs[i] = sm.collectionPlaceholder(dbName, names[i])
+		} else {
+			colls[i], errs[i] = sm.LoadCollection(dbName, names[i])
+		}
+	})
+	for i, name := range names {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("failed to load collection '%s': %w", name, errs[i])
+		}
+		db.Collections[colls[i].Name] = colls[i]
+	}
+
+	if sm.LazyLoad {
+		db.loader = func(collName string) (*Collection, error) {
+			return sm.LoadCollection(dbName, collName)
 		}
 	}
 
 	return db, nil
 }
 
-// LoadCollection loads a collection from disk
-func (sm *StorageManager) LoadCollection(dbName, collName string) (*Collection, error) {
-	collDir := filepath.Join(sm.RootDir, dbName, collName)
-
-	// Load metadata
-	metaPath := filepath.Join(collDir, "collection.meta.json")
-	var meta struct {
-		Name    string            `json:"name"`
-		Schema  *Schema           `json:"schema,omitempty"`
-		Indexes map[string]string `json:"indexes"`
-		Format  StorageFormat     `json:"format"`
-	}
+// collectionMeta is the content of collection.meta.json: a collection's
+// schema, options and index definitions
+type collectionMeta struct {
+	Name       string            `json:"name"`
+	Schema     *Schema           `json:"schema,omitempty"`
+	Indexes    map[string]string `json:"indexes"` // index name -> field name
+	Format     StorageFormat     `json:"format"`  // Storage format
+	IDStrategy string            `json:"id_strategy,omitempty"`
+	BlobPolicy *BlobPolicy       `json:"blob_policy,omitempty"`
+	Collation  *Collation        `json:"collation,omitempty"`
+
+	MemoryBudget int64 `json:"memory_budget,omitempty"`
+	Codec        Codec `json:"codec,omitempty"`
+
+	// Options of indexes that are not plain hash indexes
+	IndexOptions map[string]IndexOptions `json:"index_options,omitempty"`
+}
 
+// readCollectionMeta reads a collection's metadata file
+func (sm *StorageManager) readCollectionMeta(dbName, collName string) (*collectionMeta, error) {
+	metaPath := filepath.Join(sm.RootDir, dbName, collName, "collection.meta.json")
+	var meta collectionMeta
 	if err := sm.readJSON(metaPath, &meta); err != nil {
 		return nil, fmt.Errorf("failed to load collection metadata: %w", err)
 	}
@@ -360,11 +887,38 @@ func (sm *StorageManager) LoadCollection(dbName, collName string) (*Collection,
 	if meta.Format == "" {
 		meta.Format = FormatJSON
 	}
+	return &meta, nil
+}
+
+// LoadCollection loads a collection from disk
+func (sm *StorageManager) LoadCollection(dbName, collName string) (*Collection, error) {
+	collDir := filepath.Join(sm.RootDir, dbName, collName)
+
+	meta, err := sm.readCollectionMeta(dbName, collName)
+	if err != nil {
+		return nil, err
+	}
 
 	coll := NewCollection(meta.Name, meta.Schema)
+	coll.IDStrategy = meta.IDStrategy
+	coll.BlobPolicy = meta.BlobPolicy
+	coll.Collation = meta.Collation
+	coll.MemoryBudget = meta.MemoryBudget
+	coll.Codec = meta.Codec
+	coll.blobs = NewBlobStore(filepath.Join(collDir, BlobDirName))
 
 	// Load based on format
 	if meta.Format == FormatBinary {
+		// Left to the process that owns the directory when read-only
+		if !sm.ReadOnly {
+			sm.snapshotMu.RLock()
+			err := finishCompaction(collDir)
+			sm.snapshotMu.RUnlock()
+			if err != nil {
+				return nil, err
+			}
+		}
+
 		// Load from binary format
 		reader, err := NewBinaryCollectionReader(sm.RootDir, dbName, collName)
 		if err != nil {
@@ -394,6 +948,11 @@ func (sm *StorageManager) LoadCollection(dbName, collName string) (*Collection,
 		// Replace default _id index if it was loaded
 		for name, idx := range indexes {
 			coll.Indexes[name] = idx
+			if idx.Type == IndexOrdered || idx.stale {
+				// Sorted entries are not persisted, and older index
+				// files lost documents that shared a value
+				idx.rebuild(coll.Documents)
+			}
 		}
 
 		// If _id index wasn't loaded, rebuild it
@@ -402,6 +961,32 @@ func (sm *StorageManager) LoadCollection(dbName, collName string) (*Collection,
 				coll.Indexes["_id"].AddToIndex(doc)
 			}
 		}
+
+		// Drop bodies beyond the memory budget now that indexes are built
+		if coll.MemoryBudget > 0 && len(coll.Documents) > 0 {
+			coll.cache = newDocCache()
+			if err := sm.attachCacheReader(dbName, coll); err != nil {
+				return nil, err
+			}
+			for _, doc := range coll.Documents {
+				coll.noteStored(doc)
+			}
+			coll.evict()
+		}
+	} else if meta.Format == FormatSegmented {
+		// Changes are only tracked for collections saved in segments again
+		var untracked segmentState
+		state := &untracked
+		if sm.Format == FormatSegmented {
+			state = &coll.segments
+		}
+		docs, err := loadSegments(collDir, state)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read segments: %w", err)
+		}
+		coll.Documents = docs
+
+		rebuildIndexes(coll, meta)
 	} else {
 		// Load from JSON format (legacy)
 		docsPath := filepath.Join(collDir, "documents.json")
@@ -418,26 +1003,36 @@ func (sm *StorageManager) LoadCollection(dbName, collName string) (*Collection,
 			coll.Documents[doc.ID] = doc
 		}
 
-		// Recreate indexes (except _id which already exists)
-		for indexName, fieldName := range meta.Indexes {
-			if indexName != "_id" {
-				idx := NewIndex(indexName, fieldName)
-				for _, doc := range coll.Documents {
-					idx.AddToIndex(doc)
-				}
-				coll.Indexes[indexName] = idx
-			} else {
-				// Rebuild _id index
-				for _, doc := range coll.Documents {
-					coll.Indexes["_id"].AddToIndex(doc)
-				}
-			}
-		}
+		rebuildIndexes(coll, meta)
 	}
 
 	return coll, nil
 }
 
+// rebuildIndexes recreates a collection's indexes from their definitions
+// in the metadata (except _id which already exists, and is only filled)
+func rebuildIndexes(coll *Collection, meta *collectionMeta) {
+	for indexName, fieldName := range meta.Indexes {
+		if indexName != "_id" {
+			idx := NewIndex(indexName, fieldName)
+			if opts, exists := meta.IndexOptions[indexName]; exists && opts.Validate() == nil {
+				idx.Type = opts.Type
+				idx.Unique = opts.Unique
+				idx.TTL = opts.TTL
+			}
+			for _, doc := range coll.Documents {
+				idx.AddToIndex(doc)
+			}
+			coll.Indexes[indexName] = idx
+		} else {
+			// Rebuild _id index
+			for _, doc := range coll.Documents {
+				coll.Indexes["_id"].AddToIndex(doc)
+			}
+		}
+	}
+}
+
 // DatabaseExists checks if a database exists on disk
 func (sm *StorageManager) DatabaseExists(dbName string) bool {
 	dbDir := filepath.Join(sm.RootDir, dbName)
@@ -447,10 +1042,30 @@ func (sm *StorageManager) DatabaseExists(dbName string) bool {
 
 // DeleteDatabase deletes a database from disk
 func (sm *StorageManager) DeleteDatabase(dbName string) error {
+	if sm.ReadOnly {
+		return ErrReadOnly
+	}
+
+	sm.snapshotMu.RLock()
+	defer sm.snapshotMu.RUnlock()
+
 	dbDir := filepath.Join(sm.RootDir, dbName)
 	return os.RemoveAll(dbDir)
 }
 
+// DeleteCollection deletes a collection, with its indexes and blobs, from disk
+func (sm *StorageManager) DeleteCollection(dbName, collName string) error {
+	if sm.ReadOnly {
+		return ErrReadOnly
+	}
+
+	sm.snapshotMu.RLock()
+	defer sm.snapshotMu.RUnlock()
+
+	collDir := filepath.Join(sm.RootDir, dbName, collName)
+	return os.RemoveAll(collDir)
+}
+
 // LoadAllDatabases loads all databases from disk into a DatabaseManager
 func (sm *StorageManager) LoadAllDatabases() (*DatabaseManager, error) {
 	dm := NewDatabaseManager()
@@ -466,36 +1081,98 @@ func (sm *StorageManager) LoadAllDatabases() (*DatabaseManager, error) {
 		return nil, fmt.Errorf("failed to read root directory: %w", err)
 	}
 
+	var names []string
 	for _, entry := range entries {
 		// Skip WAL files (wal-*.bin and wal.checkpoint)
 		if strings.HasPrefix(entry.Name(), WALFilePrefix) || entry.Name() == WALCheckpointFile {
 			continue
 		}
 
+		// Skip hidden directories (e.g. migration backups)
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
 		if entry.IsDir() {
-			db, err := sm.LoadDatabase(entry.Name())
-			if err != nil {
-				return nil, fmt.Errorf("failed to load database '%s': %w", entry.Name(), err)
-			}
-			dm.Databases[db.Name] = db
+			names = append(names, entry.Name())
+		}
+	}
+
+	// Every database that fails to load is reported, not just the first
+	dbs := make([]*Database, len(names))
+	errs := make([]error, len(names))
+	forEachConcurrently(len(names), func(i int) {
+		db, err := sm.LoadDatabase(names[i])
+		if err != nil {
+			errs[i] = fmt.Errorf("failed to load database '%s': %w", names[i], err)
+			return
 		}
+		dbs[i] = db
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	for _, db := range dbs {
+		dm.Databases[db.Name] = db
 	}
 
 	// Replay WAL to restore any operations not yet persisted
-	if err := sm.WAL.Replay(dm, sm); err != nil {
+	replay, err := sm.WAL.Replay(dm, sm)
+	if err != nil {
 		return nil, fmt.Errorf("failed to replay WAL: %w", err)
 	}
+	sm.lastReplay = replay
 
+	sm.dbManager = dm
 	return dm, nil
 }
 
+// forEachConcurrently calls fn for every index in [0, n) on at most
+// LoadWorkers goroutines at a time and waits for all of them
+func forEachConcurrently(n int, fn func(i int)) {
+	sem := make(chan struct{}, LoadWorkers)
+	var wg sync.WaitGroup
+	for i := range n {
+		sem <- struct{}{}
+		wg.Go(func() {
+			defer func() { <-sem }()
+			fn(i)
+		})
+	}
+	wg.Wait()
+}
+
+// ListStoredDatabases returns the names of all databases on disk without loading them
+func (sm *StorageManager) ListStoredDatabases() ([]string, error) {
+	entries, err := os.ReadDir(sm.RootDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read root directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
 // SaveAllDatabases saves all databases from a DatabaseManager
 func (sm *StorageManager) SaveAllDatabases(dm *DatabaseManager) error {
+	sm.snapshotMu.RLock()
+	defer sm.snapshotMu.RUnlock()
+	return sm.saveAllDatabasesLocked(dm)
+}
+
+// saveAllDatabasesLocked saves all databases from a DatabaseManager (caller
+// must hold snapshotMu for reading)
+func (sm *StorageManager) saveAllDatabasesLocked(dm *DatabaseManager) error {
 	dm.mu.RLock()
 	defer dm.mu.RUnlock()
 
 	for _, db := range dm.Databases {
-		if err := sm.SaveDatabase(db); err != nil {
+		if err := sm.saveDatabaseLocked(db); err != nil {
 			return fmt.Errorf("failed to save database '%s': %w", db.Name, err)
 		}
 	}
@@ -506,7 +1183,7 @@ func (sm *StorageManager) SaveAllDatabases(dm *DatabaseManager) error {
 // WAL Integration Methods (Sync writes for durability)
 
 // LogInsert logs an insert operation to WAL (sync) and marks collection dirty
-func (sm *StorageManager) LogInsert(dbName, collName string, doc *Document) error {
+func (sm *StorageManager) LogInsert(dbName, collName string, doc *Document, opts ...LogOption) error {
 	docData, err := json.Marshal(doc)
 	if err != nil {
 		return fmt.Errorf("failed to marshal document: %w", err)
@@ -520,16 +1197,16 @@ func (sm *StorageManager) LogInsert(dbName, collName string, doc *Document) erro
 		Data:       docData,
 	}
 
-	if err := sm.WAL.AppendEntrySync(entry); err != nil {
+	if err := sm.appendWAL(entry, opts...); err != nil {
 		return err
 	}
 
-	sm.MarkDirty(dbName, collName)
+	sm.MarkDirty(dbName, collName, doc.ID)
 	return nil
 }
 
 // LogUpdate logs an update operation to WAL (sync) and marks collection dirty
-func (sm *StorageManager) LogUpdate(dbName, collName string, doc *Document) error {
+func (sm *StorageManager) LogUpdate(dbName, collName string, doc *Document, opts ...LogOption) error {
 	docData, err := json.Marshal(doc)
 	if err != nil {
 		return fmt.Errorf("failed to marshal document: %w", err)
@@ -543,16 +1220,75 @@ func (sm *StorageManager) LogUpdate(dbName, collName string, doc *Document) erro
 		Data:       docData,
 	}
 
-	if err := sm.WAL.AppendEntrySync(entry); err != nil {
+	if err := sm.appendWAL(entry, opts...); err != nil {
 		return err
 	}
 
-	sm.MarkDirty(dbName, collName)
+	sm.MarkDirty(dbName, collName, doc.ID)
+	return nil
+}
+
+// LogUpsert logs the document resulting from an upsert to WAL (sync) and
+// marks collection dirty. Replay stores it whether or not it already exists.
+func (sm *StorageManager) LogUpsert(dbName, collName string, doc *Document, opts ...LogOption) error {
+	docData, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to marshal document: %w", err)
+	}
+
+	entry := &WALEntry{
+		Database:   dbName,
+		Collection: collName,
+		Operation:  WALOpUpsert,
+		DocumentID: doc.ID,
+		Data:       docData,
+	}
+
+	if err := sm.appendWAL(entry, opts...); err != nil {
+		return err
+	}
+
+	sm.MarkDirty(dbName, collName, doc.ID)
+	return nil
+}
+
+// LogTransaction logs the results of a committed transaction or a BulkWrite
+// to WAL as a single entry (sync) and marks the collections written dirty
+func (sm *StorageManager) LogTransaction(dbName string, results []TxResult, opts ...LogOption) error {
+	var persistent []TxResult
+	for _, result := range results {
+		if !sm.isEphemeral(dbName, result.Collection) {
+			persistent = append(persistent, result)
+		}
+	}
+	if len(persistent) == 0 {
+		return nil
+	}
+	results = persistent
+
+	data, err := json.Marshal(results)
+	if err != nil {
+		return fmt.Errorf("failed to marshal transaction: %w", err)
+	}
+
+	entry := &WALEntry{
+		Database:  dbName,
+		Operation: WALOpTransaction,
+		Data:      data,
+	}
+
+	if err := sm.appendWAL(entry, opts...); err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		sm.MarkDirty(dbName, result.Collection, result.ID)
+	}
 	return nil
 }
 
 // LogDelete logs a delete operation to WAL (sync) and marks collection dirty
-func (sm *StorageManager) LogDelete(dbName, collName, docID string) error {
+func (sm *StorageManager) LogDelete(dbName, collName, docID string, opts ...LogOption) error {
 	entry := &WALEntry{
 		Database:   dbName,
 		Collection: collName,
@@ -560,7 +1296,24 @@ func (sm *StorageManager) LogDelete(dbName, collName, docID string) error {
 		DocumentID: docID,
 	}
 
-	if err := sm.WAL.AppendEntrySync(entry); err != nil {
+	if err := sm.appendWAL(entry, opts...); err != nil {
+		return err
+	}
+
+	sm.MarkDirty(dbName, collName, docID)
+	return nil
+}
+
+// LogTruncateCollection logs a truncate collection operation to WAL (sync)
+// and marks collection dirty
+func (sm *StorageManager) LogTruncateCollection(dbName, collName string) error {
+	entry := &WALEntry{
+		Database:   dbName,
+		Collection: collName,
+		Operation:  WALOpTruncateCollection,
+	}
+
+	if err := sm.appendWAL(entry); err != nil {
 		return err
 	}
 
@@ -575,7 +1328,7 @@ func (sm *StorageManager) LogCreateDatabase(dbName string) error {
 		Operation: WALOpCreateDatabase,
 	}
 
-	if err := sm.WAL.AppendEntrySync(entry); err != nil {
+	if err := sm.appendWAL(entry); err != nil {
 		return err
 	}
 
@@ -590,7 +1343,7 @@ func (sm *StorageManager) LogDeleteDatabase(dbName string) error {
 		Operation: WALOpDeleteDatabase,
 	}
 
-	return sm.WAL.AppendEntrySync(entry)
+	return sm.appendWAL(entry)
 }
 
 // LogCreateCollection logs a create collection operation to WAL (sync) and marks database dirty
@@ -611,7 +1364,7 @@ func (sm *StorageManager) LogCreateCollection(dbName, collName string, schema *S
 		Data:       schemaData,
 	}
 
-	if err := sm.WAL.AppendEntrySync(entry); err != nil {
+	if err := sm.appendWAL(entry); err != nil {
 		return err
 	}
 
@@ -619,12 +1372,24 @@ func (sm *StorageManager) LogCreateCollection(dbName, collName string, schema *S
 	return nil
 }
 
-// LogCreateIndex logs a create index operation to WAL (sync) and marks collection dirty
-func (sm *StorageManager) LogCreateIndex(dbName, collName, indexName, fieldName string) error {
-	indexData := map[string]string{
-		"index_name": indexName,
-		"field_name": fieldName,
+// LogDeleteCollection logs a delete collection operation to WAL (sync)
+func (sm *StorageManager) LogDeleteCollection(dbName, collName string) error {
+	entry := &WALEntry{
+		Database:   dbName,
+		Collection: collName,
+		Operation:  WALOpDeleteCollection,
 	}
+
+	return sm.appendWAL(entry)
+}
+
+// LogCreateIndex logs a create index operation to WAL (sync) and marks collection dirty
+func (sm *StorageManager) LogCreateIndex(dbName, collName, indexName, fieldName string, opts IndexOptions) error {
+	indexData := struct {
+		IndexName string `json:"index_name"`
+		FieldName string `json:"field_name"`
+		IndexOptions
+	}{indexName, fieldName, opts}
 	data, err := json.Marshal(indexData)
 	if err != nil {
 		return fmt.Errorf("failed to marshal index data: %w", err)
@@ -637,7 +1402,7 @@ func (sm *StorageManager) LogCreateIndex(dbName, collName, indexName, fieldName
 		Data:       data,
 	}
 
-	if err := sm.WAL.AppendEntrySync(entry); err != nil {
+	if err := sm.appendWAL(entry); err != nil {
 		return err
 	}
 
@@ -647,6 +1412,14 @@ func (sm *StorageManager) LogCreateIndex(dbName, collName, indexName, fieldName
 
 // Checkpoint creates a checkpoint in the WAL at the current offset
 func (sm *StorageManager) Checkpoint() error {
+	sm.snapshotMu.RLock()
+	defer sm.snapshotMu.RUnlock()
+	return sm.checkpointLocked()
+}
+
+// checkpointLocked creates a checkpoint in the WAL at the current offset
+// (caller must hold snapshotMu for reading)
+func (sm *StorageManager) checkpointLocked() error {
 	sm.WAL.mu.RLock()
 	currentOffset := sm.WAL.currentOffset
 	sm.WAL.mu.RUnlock()
@@ -656,15 +1429,11 @@ func (sm *StorageManager) Checkpoint() error {
 
 // Helper functions
 func (sm *StorageManager) writeJSON(path string, data any) error {
-	file, err := os.Create(path)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	return encoder.Encode(data)
+	return writeFileAtomic(path, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	})
 }
 
 func (sm *StorageManager) readJSON(path string, target any) error {
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TenantSeparator separates the tenant from the database name in qualified database names
+const TenantSeparator = "~"
+
+// TenantScope restricts database management to the databases owned by one tenant.
+// Tenant databases are stored under the qualified name "<tenant>~<database>", so
+// tenants can reuse database names without seeing each other's data.
+// A scope with an empty tenant is unrestricted and sees every database.
+type TenantScope struct {
+	manager *DatabaseManager
+	tenant  string
+}
+
+// ValidateTenantName checks that a tenant name can be used for namespacing
+func ValidateTenantName(tenant string) error {
+	if tenant == "" {
+		return fmt.Errorf("tenant name cannot be empty")
+	}
+	if strings.Contains(tenant, TenantSeparator) || strings.ContainsAny(tenant, `/\.`) {
+		return fmt.Errorf("tenant name '%s' contains invalid characters", tenant)
+	}
+	return nil
+}
+
+// HasTenants reports whether any database belongs to a tenant, i.e. whether
+// the server hosts tenants whose data the unscoped view would expose
+func (dm *DatabaseManager) HasTenants() bool {
+	dm.mu.RLock()
+	defer dm.mu.RUnlock()
+
+	for name := range dm.Databases {
+		if strings.Contains(name, TenantSeparator) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tenant returns a scope limited to the given tenant's databases
+func (dm *DatabaseManager) Tenant(tenant string) *TenantScope {
+	return &TenantScope{manager: dm, tenant: tenant}
+}
+
+// Name returns the tenant name (empty for the unrestricted scope)
+func (ts *TenantScope) Name() string {
+	return ts.tenant
+}
+
+// QualifiedName returns the internal database name for a tenant-visible name
+func (ts *TenantScope) QualifiedName(name string) string {
+	if ts.tenant == "" {
+		return name
+	}
+	return ts.tenant + TenantSeparator + name
+}
+
+// DisplayName returns the tenant-visible name of an internal database name
+func (ts *TenantScope) DisplayName(qualified string) string {
+	if ts.tenant == "" {
+		return qualified
+	}
+	return strings.TrimPrefix(qualified, ts.tenant+TenantSeparator)
+}
+
+// owns reports whether an internal database name belongs to this scope
+func (ts *TenantScope) owns(qualified string) bool {
+	if ts.tenant == "" {
+		return true
+	}
+	return strings.HasPrefix(qualified, ts.tenant+TenantSeparator)
+}
+
+// validateName rejects names that would escape the tenant namespace, either
+// by naming a tenant's database or, since a database is a directory, by
+// resolving to a path outside of its own directory. The separator is rejected
+// in the unrestricted scope too: qualified names are only built by
+// QualifiedName, never accepted from callers.
+func (ts *TenantScope) validateName(name string) error {
+	if name == "" {
+		return fmt.Errorf("database name cannot be empty")
+	}
+	if name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("database name '%s' may not be '.' or '..' or contain path separators", name)
+	}
+	if strings.Contains(name, TenantSeparator) {
+		return fmt.Errorf("database name '%s' may not contain '%s'", name, TenantSeparator)
+	}
+	return nil
+}
+
+// GetDatabase gets a database of this tenant by name, returns nil if not found
+func (ts *TenantScope) GetDatabase(name string) *Database {
+	if ts.validateName(name) != nil {
+		return nil
+	}
+	return ts.manager.GetDatabase(ts.QualifiedName(name))
+}
+
+// CreateDatabase creates a new database for this tenant or returns the existing one
+func (ts *TenantScope) CreateDatabase(name string) (*Database, error) {
+	if err := ts.validateName(name); err != nil {
+		return nil, err
+	}
+	return ts.manager.CreateDatabase(ts.QualifiedName(name)), nil
+}
+
+// ListDatabases returns the tenant-visible names of this tenant's databases
+func (ts *TenantScope) ListDatabases() []string {
+	all := ts.manager.ListDatabases()
+
+	names := make([]string, 0, len(all))
+	for _, name := range all {
+		if ts.owns(name) {
+			names = append(names, ts.DisplayName(name))
+		}
+	}
+	return names
+}
+
+// DeleteDatabase removes a database of this tenant
+func (ts *TenantScope) DeleteDatabase(name string) bool {
+	if ts.validateName(name) != nil {
+		return false
+	}
+	return ts.manager.DeleteDatabase(ts.QualifiedName(name))
+}
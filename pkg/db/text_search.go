@@ -0,0 +1,153 @@
+package db
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// BM25 tuning parameters
+const (
+	BM25K1 = 1.2
+	BM25B  = 0.75
+)
+
+// TextQuery represents a full-text search request
+type TextQuery struct {
+	Text     string   `json:"text"`
+	Fields   []string `json:"fields,omitempty"` // empty means all string fields
+	MinScore float64  `json:"min_score,omitempty"`
+	Limit    int      `json:"limit,omitempty"`
+}
+
+// TextSearch finds documents matching the query terms, ordered by BM25 relevance
+func (c *Collection) TextSearch(query *TextQuery) ([]SearchResult, error) {
+	terms := tokenize(query.Text)
+	if len(terms) == 0 {
+		return nil, fmt.Errorf("search text contains no terms")
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	// Tokenize every document once and gather corpus statistics
+	type docTerms struct {
+		doc    *Document
+		freqs  map[string]int
+		length int
+	}
+
+	corpus := make([]docTerms, 0, len(c.Documents))
+	docFreq := make(map[string]int)
+	totalLength := 0
+
+	for _, doc := range c.Documents {
+		doc, err := c.loadLocked(doc)
+		if err != nil {
+			return nil, err
+		}
+		tokens := tokenize(documentText(doc, query.Fields))
+		freqs := make(map[string]int)
+		for _, token := range tokens {
+			freqs[token]++
+		}
+		for _, term := range terms {
+			if freqs[term] > 0 {
+				docFreq[term]++
+			}
+		}
+		corpus = append(corpus, docTerms{doc: doc, freqs: freqs, length: len(tokens)})
+		totalLength += len(tokens)
+	}
+
+	if len(corpus) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	avgLength := float64(totalLength) / float64(len(corpus))
+	n := float64(len(corpus))
+
+	results := make([]SearchResult, 0)
+	for _, entry := range corpus {
+		score := 0.0
+		for _, term := range terms {
+			tf := float64(entry.freqs[term])
+			if tf == 0 {
+				continue
+			}
+			df := float64(docFreq[term])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			norm := 1 - BM25B
+			if avgLength > 0 {
+				norm += BM25B * float64(entry.length) / avgLength
+			}
+			score += idf * (tf * (BM25K1 + 1)) / (tf + BM25K1*norm)
+		}
+
+		if score <= 0 || score < query.MinScore {
+			continue
+		}
+
+		results = append(results, SearchResult{
+			Document: entry.doc.Clone(),
+			Score:    score,
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if query.Limit > 0 && query.Limit < len(results) {
+		results = results[:query.Limit]
+	}
+
+	return results, nil
+}
+
+// documentText concatenates the searchable string content of a document
+func documentText(doc *Document, fields []string) string {
+	var sb strings.Builder
+	if len(fields) == 0 {
+		for _, value := range doc.Data {
+			appendText(&sb, value)
+		}
+	} else {
+		for _, field := range fields {
+			if value, exists := doc.GetValue(field); exists {
+				appendText(&sb, value)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// appendText writes string values (including nested ones) to sb
+func appendText(sb *strings.Builder, value any) {
+	switch v := value.(type) {
+	case string:
+		sb.WriteString(v)
+		sb.WriteByte(' ')
+	case []any:
+		for _, item := range v {
+			appendText(sb, item)
+		}
+	case []string:
+		for _, item := range v {
+			appendText(sb, item)
+		}
+	case map[string]any:
+		for _, item := range v {
+			appendText(sb, item)
+		}
+	}
+}
+
+// tokenize splits text into lowercase alphanumeric terms
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -0,0 +1,215 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of transaction operations
+const (
+	TxInsert = "insert"
+	TxUpdate = "update"
+	TxDelete = "delete"
+)
+
+// Transaction stages document writes to collections of one database and
+// applies them all or none at Commit. Reads do not see staged writes.
+type Transaction struct {
+	ID       string
+	Database string
+	Started  time.Time
+
+	ops  []txOp
+	done bool
+	mu   sync.Mutex
+}
+
+// txOp is one staged write
+type txOp struct {
+	kind   string
+	coll   *Collection
+	id     string
+	doc    *Document                 // insert only
+	mutate func(doc *Document) error // update only
+}
+
+// TxResult describes the outcome of one committed operation
+type TxResult struct {
+	Collection string    `json:"collection"`
+	Op         string    `json:"op"`
+	ID         string    `json:"id"`
+	Document   *Document `json:"document,omitempty"` // stored document after the operation; nil for deletes
+}
+
+// NewTransaction starts a transaction on a database
+func NewTransaction(database string) *Transaction {
+	return &Transaction{
+		ID:       uuid.New().String(),
+		Database: database,
+		Started:  time.Now(),
+	}
+}
+
+// Insert stages an insert and returns the document ID, generating one with
+// the collection's ID strategy if doc has none
+func (tx *Transaction) Insert(coll *Collection, doc *Document) (string, error) {
+	if err := CheckUserFields(doc.Data); err != nil {
+		return "", err
+	}
+
+	doc = doc.Clone()
+	if doc.ID == "" {
+		coll.mu.Lock()
+		id, err := coll.newIDLocked()
+		coll.mu.Unlock()
+		if err != nil {
+			return "", err
+		}
+		doc.ID = id
+	}
+
+	return doc.ID, tx.stage(txOp{kind: TxInsert, coll: coll, id: doc.ID, doc: doc})
+}
+
+// Update stages an update; updates takes the same forms as in Collection.Update
+func (tx *Transaction) Update(coll *Collection, id string, updates map[string]any) error {
+	mutate, err := userUpdate(updates)
+	if err != nil {
+		return err
+	}
+	return tx.stage(txOp{kind: TxUpdate, coll: coll, id: id, mutate: mutate})
+}
+
+// Delete stages a delete
+func (tx *Transaction) Delete(coll *Collection, id string) error {
+	return tx.stage(txOp{kind: TxDelete, coll: coll, id: id})
+}
+
+func (tx *Transaction) stage(op txOp) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return fmt.Errorf("transaction '%s' is already finished", tx.ID)
+	}
+	tx.ops = append(tx.ops, op)
+	return nil
+}
+
+// Len returns the number of staged operations
+func (tx *Transaction) Len() int {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	return len(tx.ops)
+}
+
+// Rollback discards the staged operations
+func (tx *Transaction) Rollback() {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	tx.ops = nil
+	tx.done = true
+}
+
+// Commit applies the staged operations in order while holding the locks of
+// every collection involved. If any operation fails, the ones before it are
+// undone and the error is returned. Either way the transaction is finished.
+func (tx *Transaction) Commit() ([]TxResult, error) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+
+	if tx.done {
+		return nil, fmt.Errorf("transaction '%s' is already finished", tx.ID)
+	}
+	tx.done = true
+
+	// Lock collections in name order so concurrent commits cannot deadlock
+	colls := make(map[string]*Collection)
+	for _, op := range tx.ops {
+		colls[op.coll.Name] = op.coll
+	}
+	names := make([]string, 0, len(colls))
+	for name := range colls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		colls[name].mu.Lock()
+		defer colls[name].mu.Unlock()
+	}
+
+	results, err := applyTxOpsLocked(tx.ops)
+	if err != nil {
+		return nil, fmt.Errorf("transaction %w", err)
+	}
+	return results, nil
+}
+
+// applyTxOpsLocked applies operations in order. If one fails, the ones
+// before it are undone. The caller must hold the lock of every collection
+// involved.
+func applyTxOpsLocked(ops []txOp) ([]TxResult, error) {
+	var undo []func()
+	results := make([]TxResult, 0, len(ops))
+	for i, op := range ops {
+		c := op.coll
+		before := c.Documents[op.id]
+
+		var err error
+		switch op.kind {
+		case TxInsert:
+			doc := op.doc.Clone()
+			err = c.insertLocked(doc, true)
+			op.id = doc.ID
+		case TxUpdate:
+			err = c.modifyLocked(op.id, op.mutate)
+		case TxDelete:
+			if before == nil {
+				err = &NotFoundError{DocumentID: op.id}
+			} else {
+				err = c.deleteLocked(before, true)
+			}
+		}
+		if err != nil {
+			for j := len(undo) - 1; j >= 0; j-- {
+				undo[j]()
+			}
+			return nil, fmt.Errorf("operation %d (%s %s in '%s') failed: %w", i+1, op.kind, op.id, c.Name, err)
+		}
+
+		undo = append(undo, func() { c.restoreLocked(op.id, before) })
+		result := TxResult{Collection: c.Name, Op: op.kind, ID: op.id}
+		if after := c.Documents[op.id]; after != nil {
+			result.Document = after.Clone()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// restoreLocked puts back a document as it was before a write; a nil doc
+// means it did not exist (caller must hold mu)
+func (c *Collection) restoreLocked(id string, doc *Document) {
+	// Written documents are in memory; an evicted one is put back evicted
+	// and only read back to restore its index entries
+	body := doc
+	if doc != nil {
+		if loaded, err := c.loadLocked(doc); err == nil {
+			body = loaded
+		}
+	}
+	c.updateIndexes(c.Documents[id], body)
+	if doc == nil {
+		delete(c.Documents, id)
+	} else {
+		c.Documents[id] = doc
+	}
+	c.forget(id)
+	c.noteChanged(id)
+}
@@ -0,0 +1,148 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TTLIndexSuffix is appended to the field name to name a TTL index
+const TTLIndexSuffix = "_ttl"
+
+// CreateTTLIndex creates an index on field, named field + "_ttl", under
+// which documents expire ttl after the time the field holds: an RFC 3339
+// string, a time.Time or a number of seconds since the Unix epoch.
+// Documents without a readable time never expire. Expired documents are
+// deleted by ExpireDocuments, which the background sync calls periodically.
+func (c *Collection) CreateTTLIndex(field string, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("TTL must be positive")
+	}
+	return c.CreateIndexWithOptions(field+TTLIndexSuffix, field, IndexOptions{TTL: ttl})
+}
+
+// ExpireDocuments deletes the documents that have expired at now under any
+// TTL index of the collection and returns their IDs
+func (c *Collection) ExpireDocuments(now time.Time) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var expired []string
+	for _, idx := range c.Indexes {
+		if idx.TTL <= 0 {
+			continue
+		}
+		for _, id := range idx.allIDs() {
+			doc, exists := c.Documents[id]
+			if !exists {
+				continue // already expired by another TTL index
+			}
+			doc, err := c.loadLocked(doc)
+			if err != nil {
+				return expired, err
+			}
+			value, _ := doc.GetValue(idx.FieldName)
+			at, ok := expiryBase(value)
+			if !ok || now.Before(at.Add(idx.TTL)) {
+				continue
+			}
+			if err := c.deleteLocked(doc, true); err != nil {
+				if errors.Is(err, ErrVetoed) {
+					continue // kept by a hook; tried again on the next pass
+				}
+				return expired, err
+			}
+			expired = append(expired, id)
+		}
+	}
+	return expired, nil
+}
+
+// allIDs returns the sorted IDs of every indexed document
+func (idx *Index) allIDs() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	var ids []string
+	for _, set := range idx.Data {
+		for id := range set {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// expiryBase reads the time a TTL index counts from
+func expiryBase(value any) (time.Time, bool) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, true
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	}
+	if seconds, ok := toFloat(value); ok {
+		return time.Unix(0, int64(seconds*float64(time.Second))), true
+	}
+	return time.Time{}, false
+}
+
+// expireDocuments deletes expired documents from every collection, logging
+// each collection's deletions to WAL as one entry
+func (sm *StorageManager) expireDocuments() {
+	// A replica gets the leader's expiries through its WAL
+	if sm.dbManager == nil || sm.IsReplica() {
+		return
+	}
+
+	now := time.Now()
+	for _, dbName := range sm.dbManager.ListDatabases() {
+		db := sm.dbManager.GetDatabase(dbName)
+		if db == nil {
+			continue
+		}
+		for _, collName := range db.ListCollections() {
+			// Collections not loaded yet are left alone rather than loaded
+			// just to expire documents
+			coll := db.loadedCollection(collName)
+			if coll == nil {
+				continue
+			}
+
+			ids, err := coll.ExpireDocuments(now)
+			if err != nil {
+				fmt.Printf("Failed to expire documents in %s/%s: %v\n", dbName, collName, err)
+			}
+			if len(ids) == 0 {
+				continue
+			}
+
+			results := make([]TxResult, len(ids))
+			for i, id := range ids {
+				results[i] = TxResult{Collection: collName, Op: TxDelete, ID: id}
+			}
+			if err := sm.LogTransaction(dbName, results); err != nil {
+				fmt.Printf("Failed to log expired documents in %s/%s: %v\n", dbName, collName, err)
+			}
+		}
+	}
+}
+
+// backgroundExpirer periodically deletes expired documents
+func (sm *StorageManager) backgroundExpirer() {
+	defer sm.wg.Done()
+
+	ticker := time.NewTicker(ExpiryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-sm.stopChan:
+			return
+		case <-ticker.C:
+			sm.expireDocuments()
+		}
+	}
+}
@@ -3,6 +3,7 @@ package db
 import (
 	"encoding/json"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,6 +11,8 @@ import (
 type Document struct {
 	ID   string         `json:"_id"`
 	Data map[string]any `json:"data"`
+
+	evicted bool // Data was dropped to stay within the memory budget; see loadLocked
 }
 
 // FieldType represents the type of a field in the schema
@@ -29,6 +32,25 @@ const (
 type Field struct {
 	Type     FieldType `json:"type"`
 	Required bool      `json:"required"`
+	// Unique gives the field a unique index, created with the collection
+	// and named by UniqueIndexName (top-level fields only)
+	Unique bool `json:"unique,omitempty"`
+	// Enum lists the only values the field may hold (empty allows any)
+	Enum []any `json:"enum,omitempty"`
+	// Min and Max bound the value of a number field (nil is unbounded)
+	Min *float64 `json:"min,omitempty"`
+	Max *float64 `json:"max,omitempty"`
+	// MinLength and MaxLength bound the length of a string field in
+	// characters (0 is unbounded)
+	MinLength int `json:"min_length,omitempty"`
+	MaxLength int `json:"max_length,omitempty"`
+	// Pattern is a regular expression (RE2 syntax) a string field must match
+	Pattern string `json:"pattern,omitempty"`
+	// Schema describes the fields of an object field (nil allows any object)
+	Schema *Schema `json:"schema,omitempty"`
+	// Items describes every element of an array field (nil allows any
+	// elements); its Required flag is unused
+	Items *Field `json:"items,omitempty"`
 }
 
 // Schema represents a collection schema
@@ -38,9 +60,14 @@ type Schema struct {
 
 // Index represents an index on a collection
 type Index struct {
-	Name      string            `json:"name"`
-	FieldName string            `json:"field_name"`
-	Data      map[string]string `json:"-"` // maps field value to document ID
+	Name      string                         `json:"name"`
+	FieldName string                         `json:"field_name"`
+	Type      IndexType                      `json:"type"`
+	Unique    bool                           `json:"unique"`
+	TTL       time.Duration                  `json:"ttl,omitempty"`
+	Data      map[string]map[string]struct{} `json:"-"` // maps field value to the set of document IDs
+	entries   []orderedEntry                 // ordered indexes only: entries sorted by value, then document ID
+	stale     bool                           // loaded from an older index file; rebuild from the documents
 	mu        sync.RWMutex
 }
 
@@ -50,6 +77,26 @@ type Collection struct {
 	Schema    *Schema              `json:"schema,omitempty"`
 	Documents map[string]*Document `json:"-"` // maps document ID to document
 	Indexes   map[string]*Index    `json:"indexes"`
+	// IDStrategy names the generator for IDs of documents inserted without one (default "uuid")
+	IDStrategy string `json:"id_strategy,omitempty"`
+	// BlobPolicy moves large values out of documents into blob files (nil keeps everything inline)
+	BlobPolicy *BlobPolicy `json:"blob_policy,omitempty"`
+	// Collation is the default string ordering for sorts and comparisons (nil compares byte-wise)
+	Collation *Collation `json:"collation,omitempty"`
+	// Ephemeral collections live only in memory: their writes are not logged
+	// to the WAL and they are never saved, so they are gone after a restart
+	Ephemeral bool `json:"ephemeral,omitempty"`
+	// MemoryBudget bounds the memory used by document bodies in bytes (0 = unlimited; see SetMemoryBudget)
+	MemoryBudget int64 `json:"memory_budget,omitempty"`
+	// Codec compresses the stored documents ("" uses StorageManager.Compression; see SetCodec)
+	Codec    Codec `json:"codec,omitempty"`
+	blobs    *BlobStore
+	cache    *docCache
+	unloaded bool // placeholder holding only metadata; see Database.GetCollection
+	segments segmentState
+	hooks    collectionHooks
+	// truncated starts the stored files over on the next save; see Truncate
+	truncated atomic.Bool
 	mu        sync.RWMutex
 }
 
@@ -59,6 +106,14 @@ type Database struct {
 	SchemaVersion int                    `json:"schema_version"` // Schema version for migrations
 	Collections   map[string]*Collection `json:"collections"`
 	mu            sync.RWMutex
+
+	// loader loads collections that are still placeholders (see
+	// StorageManager.LazyLoad); loadMu serializes loads
+	loader func(name string) (*Collection, error)
+	loadMu sync.Mutex
+
+	// topics delivers the messages published to the database (see Publish)
+	topics topics
 }
 
 // DatabaseManager manages multiple databases
@@ -70,15 +125,18 @@ type DatabaseManager struct {
 // QueryFilter represents a query filter
 type QueryFilter struct {
 	Field    string `json:"field"`
-	Operator string `json:"operator"` // "eq", "ne", "gt", "lt", "gte", "lte", "in"
+	Operator string `json:"operator"` // "eq", "ne", "gt", "lt", "gte", "lte", "in", "nin", "regex", "prefix", "suffix", "contains"
 	Value    any    `json:"value"`
 }
 
 // Query represents a query
 type Query struct {
-	Filters []QueryFilter `json:"filters"`
-	Limit   int           `json:"limit"`
-	Skip    int           `json:"skip"`
+	Filters   []QueryFilter `json:"filters"`
+	Filter    *Filter       `json:"filter,omitempty"` // nested AND/OR conditions, combined with Filters
+	Sort      []SortField   `json:"sort,omitempty"`
+	Collation *Collation    `json:"collation,omitempty"` // overrides the collection's collation
+	Limit     int           `json:"limit"`
+	Skip      int           `json:"skip"`
 }
 
 // MarshalJSON customizes JSON marshaling for Document
@@ -112,7 +170,8 @@ func NewIndex(name, fieldName string) *Index {
 	return &Index{
 		Name:      name,
 		FieldName: fieldName,
-		Data:      make(map[string]string),
+		Type:      IndexHash,
+		Data:      make(map[string]map[string]struct{}),
 	}
 }
 
@@ -212,6 +271,33 @@ func (d *Document) Clone() *Document {
 	return clone
 }
 
+// DeepClone creates a copy of the document that shares no nested maps or slices
+func (d *Document) DeepClone() *Document {
+	return &Document{
+		ID:   d.ID,
+		Data: deepCopyValue(d.Data).(map[string]any),
+	}
+}
+
+// deepCopyValue recursively copies maps and slices
+func deepCopyValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			out[k] = deepCopyValue(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = deepCopyValue(item)
+		}
+		return out
+	}
+	return value
+}
+
 // ValidateType checks if a value matches the expected field type
 func ValidateType(value any, fieldType FieldType) bool {
 	switch fieldType {
@@ -0,0 +1,177 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Update operators, applied by Update when the keys of updates start with '$'
+const (
+	UpdateOpSet      = "$set"      // set fields to values
+	UpdateOpUnset    = "$unset"    // remove fields (the values are ignored)
+	UpdateOpInc      = "$inc"      // add a number to numeric fields, missing fields start at 0
+	UpdateOpPush     = "$push"     // append to array fields, missing fields start empty
+	UpdateOpPull     = "$pull"     // remove every element equal to a value from array fields
+	UpdateOpAddToSet = "$addToSet" // append to array fields unless an equal element exists
+
+	// UpdateOpPatch takes a JSON Patch (RFC 6902) array, as []any or
+	// []PatchOperation, instead of an object of fields. It replaces the
+	// whole update rather than combining with other operators.
+	UpdateOpPatch = "$patch"
+)
+
+// updateOpOrder is the order operators are applied in
+var updateOpOrder = []string{UpdateOpSet, UpdateOpUnset, UpdateOpInc, UpdateOpPush, UpdateOpAddToSet, UpdateOpPull}
+
+// eachModifier lets $push and $addToSet take several values: {"$each": [...]}
+const eachModifier = "$each"
+
+// HasUpdateOperators reports whether updates uses update operators rather
+// than plain field values
+func HasUpdateOperators(updates map[string]any) bool {
+	for key := range updates {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+	return false
+}
+
+// validateUpdateOperators checks that every key is a known operator taking
+// an object of field paths, that no field is targeted twice (directly or
+// through a parent path), and that no field is reserved
+func validateUpdateOperators(updates map[string]any) error {
+	targeted := make(map[string]string)
+	for _, op := range sortedKeys(updates) {
+		if !isUpdateOperator(op) {
+			if !strings.HasPrefix(op, "$") {
+				return fmt.Errorf("cannot mix update operators with plain field '%s'", op)
+			}
+			return fmt.Errorf("unknown update operator '%s'", op)
+		}
+		fields, ok := updates[op].(map[string]any)
+		if !ok {
+			return fmt.Errorf("%s needs an object of fields", op)
+		}
+		if err := CheckUserFields(fields); err != nil {
+			return err
+		}
+		for field, arg := range fields {
+			if other, exists := targeted[field]; exists {
+				return fmt.Errorf("field '%s' is updated by both %s and %s", field, other, op)
+			}
+			targeted[field] = op
+			if op == UpdateOpInc {
+				if _, ok := toFloat(arg); !ok {
+					return fmt.Errorf("%s on field '%s' needs a number", op, field)
+				}
+			}
+		}
+	}
+
+	paths := make([]string, 0, len(targeted))
+	for field := range targeted {
+		paths = append(paths, field)
+	}
+	return checkPathConflicts(paths)
+}
+
+func isUpdateOperator(op string) bool {
+	for _, known := range updateOpOrder {
+		if op == known {
+			return true
+		}
+	}
+	return false
+}
+
+// applyUpdateOperators applies validated update operators to a document's data
+func applyUpdateOperators(data map[string]any, updates map[string]any) error {
+	for _, op := range updateOpOrder {
+		fields, _ := updates[op].(map[string]any)
+		for _, field := range sortedKeys(fields) {
+			if err := applyUpdateOperator(data, op, field, fields[field]); err != nil {
+				return fmt.Errorf("%s on field '%s': %w", op, field, err)
+			}
+		}
+	}
+	return nil
+}
+
+func applyUpdateOperator(data map[string]any, op, field string, arg any) error {
+	current, exists := getPath(data, field)
+
+	switch op {
+	case UpdateOpSet:
+		return setPath(data, field, arg)
+	case UpdateOpUnset:
+		return unsetPath(data, field)
+	case UpdateOpInc:
+		delta, _ := toFloat(arg)
+		if !exists {
+			return setPath(data, field, delta)
+		}
+		value, ok := toFloat(current)
+		if !ok {
+			return fmt.Errorf("field is not a number")
+		}
+		return setPath(data, field, value+delta)
+	case UpdateOpPush, UpdateOpAddToSet, UpdateOpPull:
+		var arr []any
+		if exists {
+			var ok bool
+			if arr, ok = filterValues(current); !ok {
+				return fmt.Errorf("field is not an array")
+			}
+		}
+		if op == UpdateOpPull {
+			kept := make([]any, 0, len(arr))
+			for _, item := range arr {
+				if !jsonEqual(item, arg) {
+					kept = append(kept, item)
+				}
+			}
+			if exists {
+				return setPath(data, field, kept)
+			}
+			return nil
+		}
+
+		values := []any{arg}
+		if each, ok := arg.(map[string]any); ok && len(each) == 1 {
+			if items, ok := each[eachModifier]; ok {
+				if values, ok = filterValues(items); !ok {
+					return fmt.Errorf("%s needs an array", eachModifier)
+				}
+			}
+		}
+		arr = append([]any(nil), arr...)
+		for _, value := range values {
+			if op == UpdateOpAddToSet && containsJSONValue(arr, value) {
+				continue
+			}
+			arr = append(arr, value)
+		}
+		return setPath(data, field, arr)
+	}
+	return nil
+}
+
+func containsJSONValue(arr []any, value any) bool {
+	for _, item := range arr {
+		if jsonEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,63 @@
+package db
+
+import "fmt"
+
+// Upsert updates the first document matching query (in query order) with
+// the fields of doc, or inserts doc if nothing matches. The lookup and the
+// write happen under one lock, so concurrent upserts cannot both insert.
+// It returns a copy of the stored document and whether it was inserted.
+// Fields in the reserved namespace are rejected.
+func (c *Collection) Upsert(query *Query, doc *Document) (*Document, bool, error) {
+	if HasUpdateOperators(doc.Data) {
+		return nil, false, fmt.Errorf("upsert does not support update operators")
+	}
+	if err := CheckUserFields(doc.Data); err != nil {
+		return nil, false, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	matches, _, err := c.findLocked(query)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if len(matches) == 0 {
+		newDoc := doc.Clone()
+		if err := c.insertLocked(newDoc, true); err != nil {
+			return nil, false, err
+		}
+		return newDoc.Clone(), true, nil
+	}
+
+	id := matches[0].ID
+	if doc.ID != "" && doc.ID != id {
+		return nil, false, fmt.Errorf("upsert matched document '%s' but the document has ID '%s'", id, doc.ID)
+	}
+	err = c.modifyLocked(id, func(stored *Document) error {
+		for key, value := range doc.Data {
+			stored.Data[key] = value
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return c.Documents[id].Clone(), false, nil
+}
+
+// put stores doc as the full content of its document, inserting it if it
+// does not exist; used to replay upserts
+func (c *Collection) put(doc *Document) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.Documents[doc.ID]; !exists {
+		return c.insertLocked(doc, false)
+	}
+	return c.replaceLocked(doc.ID, func(stored *Document) error {
+		stored.Data = doc.Data
+		return nil
+	}, false)
+}
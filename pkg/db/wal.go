@@ -4,14 +4,18 @@ import (
 	"bufio"
 	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"hash/crc32"
 	"io"
+	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -25,20 +29,64 @@ const (
 	WALFilePrefix     = "wal-"
 	WALBatchSize      = 100                    // Batch writes
 	WALFlushInterval  = 100 * time.Millisecond // Flush every 100ms
+
+	// WALGroupCommitWindow is how long a group commit waits for concurrent
+	// writers to join its fsync (see AppendEntryGroup)
+	WALGroupCommitWindow = time.Millisecond
 )
 
 // WALOperation types
 const (
-	WALOpInsert           = "insert"
-	WALOpUpdate           = "update"
-	WALOpDelete           = "delete"
-	WALOpCreateDatabase   = "create_database"
-	WALOpDeleteDatabase   = "delete_database"
-	WALOpCreateCollection = "create_collection"
-	WALOpDeleteCollection = "delete_collection"
-	WALOpCreateIndex      = "create_index"
+	WALOpInsert             = "insert"
+	WALOpUpdate             = "update"
+	WALOpDelete             = "delete"
+	WALOpCreateDatabase     = "create_database"
+	WALOpDeleteDatabase     = "delete_database"
+	WALOpCreateCollection   = "create_collection"
+	WALOpDeleteCollection   = "delete_collection"
+	WALOpTruncateCollection = "truncate_collection"
+	WALOpCreateIndex        = "create_index"
+	WALOpUpsert             = "upsert"
+	WALOpTransaction        = "transaction"
 )
 
+// WALConfig tunes the size and flushing of a WAL. Zero fields take the
+// defaults WALMaxSize, WALRetentionCount, WALBatchSize and WALFlushInterval.
+type WALConfig struct {
+	// Dir holds the WAL files and checkpoint instead of the root directory,
+	// e.g. on a faster disk (see NewStorageManagerWithWAL)
+	Dir string `json:"dir,omitempty"`
+	// MaxSize is the size in bytes at which the current WAL file is rotated
+	MaxSize int64 `json:"max_size,omitempty"`
+	// RetentionCount is how many WAL files rotation keeps (see WALArchiver)
+	RetentionCount int `json:"retention_count,omitempty"`
+	// BatchSize is how many batched entries are buffered before they are
+	// written without waiting for FlushInterval
+	BatchSize int `json:"batch_size,omitempty"`
+	// FlushInterval is how often batched entries are written
+	FlushInterval time.Duration `json:"flush_interval,omitempty"`
+}
+
+// withDefaults fills in the zero fields of a WAL config and rejects negative ones
+func (c WALConfig) withDefaults() (WALConfig, error) {
+	if c.MaxSize < 0 || c.RetentionCount < 0 || c.BatchSize < 0 || c.FlushInterval < 0 {
+		return c, fmt.Errorf("WAL size, retention, batch size and flush interval must not be negative")
+	}
+	if c.MaxSize == 0 {
+		c.MaxSize = WALMaxSize
+	}
+	if c.RetentionCount == 0 {
+		c.RetentionCount = WALRetentionCount
+	}
+	if c.BatchSize == 0 {
+		c.BatchSize = WALBatchSize
+	}
+	if c.FlushInterval == 0 {
+		c.FlushInterval = WALFlushInterval
+	}
+	return c, nil
+}
+
 // WALEntry represents a single write-ahead log entry
 type WALEntry struct {
 	Offset     uint64    `json:"offset"`
@@ -70,10 +118,35 @@ type WALManager struct {
 	mu            sync.RWMutex
 	flushTicker   *time.Ticker
 	stopChan      chan struct{}
+	readOnly      bool // see newReadOnlyWALManager
+	config        WALConfig
+	// sinceCheckpoint counts the bytes written since the last checkpoint
+	sinceCheckpoint int64
+	// appended and appendRate count the appended entries (see Stats)
+	appended   uint64
+	appendRate rateMeter
+	// subscribers receive the entries written to the file (see Subscribe)
+	subscribers map[chan *WALEntry]struct{}
+
+	// Archiver, when set, takes the WAL files past the retention count instead
+	// of them being deleted. Set it before entries are appended.
+	Archiver WALArchiver
+
+	// Group commit (see AppendEntryGroup): groupMu is held by the caller
+	// syncing for the group, syncedOffset is the offset below which every
+	// entry is on disk, and groupPending counts group writers in flight
+	groupMu      sync.Mutex
+	syncedOffset uint64
+	groupPending atomic.Int64
 }
 
 // NewWALManager creates a new WAL manager
-func NewWALManager(rootDir string) (*WALManager, error) {
+func NewWALManager(rootDir string, config WALConfig) (*WALManager, error) {
+	config, err := config.withDefaults()
+	if err != nil {
+		return nil, err
+	}
+
 	// WAL files are stored directly in rootDir (no separate wal subdirectory)
 	if err := os.MkdirAll(rootDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create data directory: %w", err)
@@ -81,9 +154,10 @@ func NewWALManager(rootDir string) (*WALManager, error) {
 
 	wm := &WALManager{
 		rootDir:     rootDir,
-		batch:       make([]*WALEntry, 0, WALBatchSize),
+		config:      config,
+		batch:       make([]*WALEntry, 0, config.BatchSize),
 		stopChan:    make(chan struct{}),
-		flushTicker: time.NewTicker(WALFlushInterval),
+		flushTicker: time.NewTicker(config.FlushInterval),
 	}
 
 	// Load checkpoint
@@ -102,8 +176,27 @@ func NewWALManager(rootDir string) (*WALManager, error) {
 	return wm, nil
 }
 
+// newReadOnlyWALManager creates a WAL manager that can read and replay the
+// WAL of rootDir but not append to it or move its checkpoint
+func newReadOnlyWALManager(rootDir string) (*WALManager, error) {
+	wm := &WALManager{
+		rootDir:     rootDir,
+		stopChan:    make(chan struct{}),
+		flushTicker: time.NewTicker(WALFlushInterval),
+		readOnly:    true,
+	}
+	if err := wm.loadCheckpoint(); err != nil {
+		return nil, err
+	}
+	return wm, nil
+}
+
 // AppendEntry appends an entry to the WAL (batched)
 func (wm *WALManager) AppendEntry(entry *WALEntry) error {
+	if wm.readOnly {
+		return ErrReadOnly
+	}
+
 	wm.batchMu.Lock()
 	defer wm.batchMu.Unlock()
 
@@ -111,6 +204,7 @@ func (wm *WALManager) AppendEntry(entry *WALEntry) error {
 	wm.mu.Lock()
 	entry.Offset = wm.currentOffset
 	wm.currentOffset++
+	wm.countAppendLocked()
 	wm.mu.Unlock()
 
 	entry.Timestamp = time.Now()
@@ -119,7 +213,7 @@ func (wm *WALManager) AppendEntry(entry *WALEntry) error {
 	wm.batch = append(wm.batch, entry)
 
 	// Flush if batch is full
-	if len(wm.batch) >= WALBatchSize {
+	if len(wm.batch) >= wm.config.BatchSize {
 		return wm.flushBatchLocked()
 	}
 
@@ -129,6 +223,10 @@ func (wm *WALManager) AppendEntry(entry *WALEntry) error {
 // AppendEntrySync appends an entry to the WAL and flushes immediately (sync)
 // This ensures durability - when this returns, the entry is on disk
 func (wm *WALManager) AppendEntrySync(entry *WALEntry) error {
+	if wm.readOnly {
+		return ErrReadOnly
+	}
+
 	wm.batchMu.Lock()
 	defer wm.batchMu.Unlock()
 
@@ -136,6 +234,7 @@ func (wm *WALManager) AppendEntrySync(entry *WALEntry) error {
 	wm.mu.Lock()
 	entry.Offset = wm.currentOffset
 	wm.currentOffset++
+	wm.countAppendLocked()
 	wm.mu.Unlock()
 
 	entry.Timestamp = time.Now()
@@ -160,6 +259,62 @@ func (wm *WALManager) AppendEntrySync(entry *WALEntry) error {
 	return nil
 }
 
+// AppendEntryGroup appends an entry to the WAL and returns once it is on
+// disk, like AppendEntrySync, but concurrent callers share fsyncs: one of
+// them writes and syncs the entries of every caller waiting at that time.
+// While other group writers are in flight it first waits
+// WALGroupCommitWindow so that more of them join; a lone writer syncs at once.
+func (wm *WALManager) AppendEntryGroup(entry *WALEntry) error {
+	if wm.readOnly {
+		return ErrReadOnly
+	}
+
+	wm.groupPending.Add(1)
+	defer wm.groupPending.Add(-1)
+
+	wm.batchMu.Lock()
+	wm.mu.Lock()
+	entry.Offset = wm.currentOffset
+	wm.currentOffset++
+	wm.countAppendLocked()
+	wm.mu.Unlock()
+	entry.Timestamp = time.Now()
+	wm.batch = append(wm.batch, entry)
+	wm.batchMu.Unlock()
+
+	return wm.syncThrough(entry.Offset)
+}
+
+// syncThrough writes and fsyncs pending entries unless a sync by another
+// group writer already covered the entry at offset
+func (wm *WALManager) syncThrough(offset uint64) error {
+	wm.groupMu.Lock()
+	defer wm.groupMu.Unlock()
+	if wm.syncedOffset > offset {
+		return nil
+	}
+	if wm.groupPending.Load() > 1 {
+		time.Sleep(WALGroupCommitWindow)
+	}
+
+	wm.batchMu.Lock()
+	defer wm.batchMu.Unlock()
+	if err := wm.flushBatchLocked(); err != nil {
+		return err
+	}
+
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+	if wm.currentFile != nil {
+		if err := wm.currentFile.Sync(); err != nil {
+			return fmt.Errorf("failed to sync WAL to disk: %w", err)
+		}
+	}
+	// Offsets are assigned under batchMu, so every entry below is written
+	wm.syncedOffset = wm.currentOffset
+	return nil
+}
+
 // Flush forces a flush of pending entries
 func (wm *WALManager) Flush() error {
 	wm.batchMu.Lock()
@@ -181,6 +336,7 @@ func (wm *WALManager) flushBatchLocked() error {
 			return err
 		}
 	}
+	wm.publishLocked(wm.batch)
 
 	// Clear batch
 	wm.batch = wm.batch[:0]
@@ -191,7 +347,7 @@ func (wm *WALManager) flushBatchLocked() error {
 	}
 
 	// Check if rotation needed
-	if wm.currentSize >= WALMaxSize {
+	if wm.currentSize >= wm.config.MaxSize {
 		if err := wm.rotateLocked(); err != nil {
 			return err
 		}
@@ -200,12 +356,30 @@ func (wm *WALManager) flushBatchLocked() error {
 	return nil
 }
 
+// countAppendLocked counts an appended entry (caller must hold mu)
+func (wm *WALManager) countAppendLocked() {
+	wm.appended++
+	wm.appendRate.add(time.Now(), 1)
+}
+
 // writeEntryLocked writes a single entry (caller must hold mu)
 func (wm *WALManager) writeEntryLocked(entry *WALEntry) error {
+	n, err := writeWALEntry(wm.writer, entry)
+	if err != nil {
+		return err
+	}
+
+	wm.currentSize += n
+	wm.sinceCheckpoint += n
+	return nil
+}
+
+// writeWALEntry serializes an entry to w and returns the bytes written
+func writeWALEntry(w io.Writer, entry *WALEntry) (int64, error) {
 	// Serialize entry
 	data, err := json.Marshal(entry)
 	if err != nil {
-		return fmt.Errorf("failed to marshal WAL entry: %w", err)
+		return 0, fmt.Errorf("failed to marshal WAL entry: %w", err)
 	}
 
 	// Calculate checksum
@@ -213,18 +387,17 @@ func (wm *WALManager) writeEntryLocked(entry *WALEntry) error {
 
 	// Write: [length:4][checksum:4][data:N]
 	length := uint32(len(data))
-	if err := binary.Write(wm.writer, binary.LittleEndian, length); err != nil {
-		return err
+	if err := binary.Write(w, binary.LittleEndian, length); err != nil {
+		return 0, err
 	}
-	if err := binary.Write(wm.writer, binary.LittleEndian, entry.Checksum); err != nil {
-		return err
+	if err := binary.Write(w, binary.LittleEndian, entry.Checksum); err != nil {
+		return 0, err
 	}
-	if _, err := wm.writer.Write(data); err != nil {
-		return err
+	if _, err := w.Write(data); err != nil {
+		return 0, err
 	}
 
-	wm.currentSize += int64(8 + len(data)) // 4+4+N
-	return nil
+	return int64(8 + len(data)), nil // 4+4+N
 }
 
 // backgroundFlusher periodically flushes pending entries
@@ -239,31 +412,62 @@ func (wm *WALManager) backgroundFlusher() {
 	}
 }
 
-// ReadFrom reads WAL entries starting from the given offset
+// ReadFrom reads WAL entries starting from the given offset. Entries after
+// a torn tail (see walTornTail) are left out of the file that has one.
 func (wm *WALManager) ReadFrom(startOffset uint64) ([]*WALEntry, error) {
+	entries, _, err := wm.readEntries(startOffset)
+	return entries, err
+}
+
+// readEntries reads WAL entries starting from the given offset, and returns
+// the torn tails found in the WAL files
+func (wm *WALManager) readEntries(startOffset uint64) ([]*WALEntry, []*walTornTail, error) {
 	wm.mu.RLock()
 	defer wm.mu.RUnlock()
 
 	files, err := wm.getWALFilesLocked()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	var entries []*WALEntry
+	var torn []*walTornTail
 
 	for _, filename := range files {
 		path := filepath.Join(wm.rootDir, filename)
 		fileEntries, err := wm.readWALFile(path, startOffset)
-		if err != nil {
-			return nil, err
+		var tail *walTornTail
+		if errors.As(err, &tail) {
+			torn = append(torn, tail)
+		} else if err != nil {
+			return nil, nil, err
 		}
 		entries = append(entries, fileEntries...)
 	}
 
-	return entries, nil
+	return entries, torn, nil
+}
+
+// walTornTail reports an entry at the end of a WAL file that was cut short
+// or left as garbage by a crash during its append. Such an entry was never
+// acknowledged as durable, so the file is only valid up to Size.
+type walTornTail struct {
+	Path string
+	Size int64 // bytes of complete entries before the torn one
+	Err  error
 }
 
-// readWALFile reads entries from a specific WAL file
+func (e *walTornTail) Error() string {
+	return fmt.Sprintf("torn entry at byte %d of %s: %v", e.Size, filepath.Base(e.Path), e.Err)
+}
+
+func (e *walTornTail) Unwrap() error {
+	return e.Err
+}
+
+// readWALFile reads entries from a specific WAL file. An entry that cannot
+// be read at the end of the file returns the entries before it along with a
+// *walTornTail; one followed by more data is corruption and fails.
 func (wm *WALManager) readWALFile(path string, startOffset uint64) ([]*WALEntry, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -271,53 +475,136 @@ func (wm *WALManager) readWALFile(path string, startOffset uint64) ([]*WALEntry,
 	}
 	defer file.Close()
 
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
 	var entries []*WALEntry
 	reader := bufio.NewReader(file)
+	var pos int64 // start of the entry being read
 
 	for {
-		// Read length
-		var length uint32
-		if err := binary.Read(reader, binary.LittleEndian, &length); err != nil {
-			if err == io.EOF {
-				break
+		entry, n, err := readWALEntry(reader)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if walTailIsTorn(file, pos, pos+n, stat.Size(), err) {
+				return entries, &walTornTail{Path: path, Size: pos, Err: err}
 			}
-			return nil, err
+			return nil, fmt.Errorf("%s at byte %d: %w", filepath.Base(path), pos, err)
 		}
+		pos += n
 
-		// Read checksum
-		var checksum uint32
-		if err := binary.Read(reader, binary.LittleEndian, &checksum); err != nil {
-			return nil, err
+		// Filter by offset
+		if entry.Offset >= startOffset {
+			entries = append(entries, entry)
 		}
+	}
 
-		// Read data
-		data := make([]byte, length)
-		if _, err := io.ReadFull(reader, data); err != nil {
-			return nil, err
-		}
+	return entries, nil
+}
 
-		// Verify checksum
-		if crc32.ChecksumIEEE(data) != checksum {
-			return nil, fmt.Errorf("WAL entry checksum mismatch")
-		}
+// readWALEntry reads one [length:4][checksum:4][data:N] entry and returns
+// the bytes it spans. A clean end of input returns io.EOF; an entry cut
+// short returns io.ErrUnexpectedEOF.
+func readWALEntry(reader io.Reader) (*WALEntry, int64, error) {
+	// Read length
+	var length uint32
+	if err := binary.Read(reader, binary.LittleEndian, &length); err != nil {
+		return nil, 0, err
+	}
 
-		// Deserialize entry
-		var entry WALEntry
-		if err := json.Unmarshal(data, &entry); err != nil {
-			return nil, err
+	// Read checksum
+	var checksum uint32
+	if err := binary.Read(reader, binary.LittleEndian, &checksum); err != nil {
+		return nil, 4, io.ErrUnexpectedEOF
+	}
+	n := int64(8) + int64(length)
+
+	// Read data
+	data := make([]byte, length)
+	if _, err := io.ReadFull(reader, data); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
 		}
+		return nil, n, err
+	}
 
-		// Filter by offset
-		if entry.Offset >= startOffset {
-			entries = append(entries, &entry)
+	// Verify checksum
+	if crc32.ChecksumIEEE(data) != checksum {
+		return nil, n, fmt.Errorf("WAL entry checksum mismatch")
+	}
+
+	// Deserialize entry
+	var entry WALEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, n, err
+	}
+	return &entry, n, nil
+}
+
+// walTailIsTorn decides whether an unreadable entry spanning [start, end)
+// of a WAL file of the given size is what a crash mid-append leaves behind:
+// an entry running past the end of the file, a bad last entry, or zeroed
+// space the file system allocated but never wrote
+func walTailIsTorn(file *os.File, start, end, size int64, err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || end >= size {
+		return true
+	}
+	rest := io.NewSectionReader(file, start, size-start)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := rest.Read(buf)
+		for _, b := range buf[:n] {
+			if b != 0 {
+				return false
+			}
+		}
+		if err != nil {
+			return err == io.EOF
 		}
 	}
+}
 
-	return entries, nil
+// truncateFile cuts a file to size and syncs it
+func truncateFile(path string, size int64) error {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := f.Truncate(size); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// truncateTornTails cuts the torn entries off the end of WAL files, so new
+// entries appended to them can be read again
+func (wm *WALManager) truncateTornTails(torn []*walTornTail) error {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	for _, tail := range torn {
+		log.Printf("Warning: dropping %v\n", tail)
+		if err := truncateFile(tail.Path, tail.Size); err != nil {
+			return fmt.Errorf("failed to truncate torn WAL entry: %w", err)
+		}
+		if wm.currentFile != nil && wm.currentFile.Name() == tail.Path {
+			wm.currentSize = tail.Size
+		}
+	}
+	return nil
 }
 
 // Checkpoint marks the given offset as successfully synced
 func (wm *WALManager) Checkpoint(offset uint64) error {
+	if wm.readOnly {
+		return ErrReadOnly
+	}
+
 	wm.mu.Lock()
 	defer wm.mu.Unlock()
 
@@ -325,6 +612,7 @@ func (wm *WALManager) Checkpoint(offset uint64) error {
 		Offset:    offset,
 		Timestamp: time.Now(),
 	}
+	wm.sinceCheckpoint = 0
 
 	return wm.saveCheckpointLocked()
 }
@@ -340,6 +628,19 @@ func (wm *WALManager) GetCheckpoint() *WALCheckpoint {
 	return wm.checkpoint
 }
 
+// SinceCheckpoint returns the number of entries logged and the bytes
+// written to the WAL since the last checkpoint
+func (wm *WALManager) SinceCheckpoint() (uint64, int64) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	var entries uint64
+	if wm.checkpoint != nil && wm.currentOffset > wm.checkpoint.Offset {
+		entries = wm.currentOffset - wm.checkpoint.Offset
+	}
+	return entries, wm.sinceCheckpoint
+}
+
 // rotateLocked creates a new WAL file (caller must hold mu)
 func (wm *WALManager) rotateLocked() error {
 	// Close current file
@@ -347,6 +648,11 @@ func (wm *WALManager) rotateLocked() error {
 		wm.writer.Flush()
 	}
 	if wm.currentFile != nil {
+		// Callers sync only the new file, so the entries flushed into this
+		// one must reach the disk first
+		if err := wm.currentFile.Sync(); err != nil {
+			return fmt.Errorf("failed to sync WAL to disk: %w", err)
+		}
 		wm.currentFile.Close()
 	}
 
@@ -361,14 +667,17 @@ func (wm *WALManager) rotateLocked() error {
 
 // openCurrentWAL opens or creates the current WAL file
 func (wm *WALManager) openCurrentWAL() error {
-	timestamp := time.Now().Unix()
-	filename := fmt.Sprintf("%s%d-%06d.log", WALFilePrefix, timestamp, wm.currentOffset)
-	path := filepath.Join(wm.rootDir, filename)
+	path := filepath.Join(wm.rootDir, walFileName(wm.currentOffset))
 
 	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		return fmt.Errorf("failed to open WAL file: %w", err)
 	}
+	// Entries synced to a new file must not be lost with its directory entry
+	if err := syncDir(wm.rootDir); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to sync WAL directory: %w", err)
+	}
 
 	stat, err := file.Stat()
 	if err != nil {
@@ -383,6 +692,11 @@ func (wm *WALManager) openCurrentWAL() error {
 	return nil
 }
 
+// walFileName names a new WAL file whose first entry has the given offset
+func walFileName(offset uint64) string {
+	return fmt.Sprintf("%s%d-%06d.log", WALFilePrefix, time.Now().Unix(), offset)
+}
+
 // getWALFilesLocked returns sorted list of WAL files (caller must hold mu)
 func (wm *WALManager) getWALFilesLocked() ([]string, error) {
 	entries, err := os.ReadDir(wm.rootDir)
@@ -408,15 +722,15 @@ func (wm *WALManager) cleanupOldWALsLocked() error {
 		return err
 	}
 
-	if len(files) <= WALRetentionCount {
+	if len(files) <= wm.config.RetentionCount {
 		return nil
 	}
 
-	// Remove oldest files
-	toRemove := files[:len(files)-WALRetentionCount]
+	// Remove (or archive) oldest files
+	toRemove := files[:len(files)-wm.config.RetentionCount]
 	for _, filename := range toRemove {
 		path := filepath.Join(wm.rootDir, filename)
-		if err := os.Remove(path); err != nil {
+		if err := wm.archiveOrRemoveLocked(path); err != nil {
 			return fmt.Errorf("failed to remove old WAL file: %w", err)
 		}
 	}
@@ -442,7 +756,8 @@ func (wm *WALManager) loadCheckpoint() error {
 	}
 
 	wm.checkpoint = &cp
-	wm.currentOffset = cp.Offset + 1
+	// Entries before the checkpoint offset are saved; it is the next one
+	wm.currentOffset = cp.Offset
 
 	return nil
 }
@@ -455,7 +770,10 @@ func (wm *WALManager) saveCheckpointLocked() error {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	return writeFileAtomic(path, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
 }
 
 // Close closes the WAL manager
@@ -481,155 +799,344 @@ func (wm *WALManager) Close() error {
 	return nil
 }
 
-// Replay replays WAL entries to restore database state
-func (wm *WALManager) Replay(dm *DatabaseManager, storage *StorageManager) error {
+// ReplayResult summarizes a WAL replay
+type ReplayResult struct {
+	// Applied entries changed the databases
+	Applied int `json:"applied"`
+	// Skipped entries were already applied before the crash, e.g. an
+	// insert of a document that exists or a delete of one that does not
+	Skipped int `json:"skipped"`
+}
+
+// errReplaySkip reports an entry whose change is already in the databases
+var errReplaySkip = errors.New("already applied")
+
+// replayChange is what applying a WAL entry changed in a database
+type replayChange struct {
+	database string
+	// whole saves the entire database instead of just collections
+	whole bool
+	// deleted removes the database from storage
+	deleted     bool
+	collections []string
+	// deletedCollections are removed from storage, before the collections
+	// are saved
+	deletedCollections []string
+}
+
+// replaySaves gathers the changes of every replayed entry, so each database
+// or collection is saved once after the replay instead of after every entry
+type replaySaves map[string]*replayChange
+
+// add merges the change of an entry
+func (saves replaySaves) add(change *replayChange) {
+	if change == nil {
+		return
+	}
+	merged, exists := saves[change.database]
+	if !exists {
+		merged = &replayChange{database: change.database}
+		saves[change.database] = merged
+	}
+	merged.whole = merged.whole || change.whole
+	merged.deleted = merged.deleted || change.deleted
+	for _, name := range change.collections {
+		if !slices.Contains(merged.collections, name) {
+			merged.collections = append(merged.collections, name)
+		}
+	}
+	for _, name := range change.deletedCollections {
+		if !slices.Contains(merged.deletedCollections, name) {
+			merged.deletedCollections = append(merged.deletedCollections, name)
+		}
+	}
+}
+
+// save writes the changed databases and collections, as they are in dm
+// after the replay, to storage
+func (saves replaySaves) save(dm *DatabaseManager, storage *StorageManager) error {
+	names := make([]string, 0, len(saves))
+	for name := range saves {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		change := saves[name]
+		// A database deleted during the replay leaves its old files behind
+		// even if it was created again
+		if change.deleted {
+			if err := storage.DeleteDatabase(name); err != nil {
+				return fmt.Errorf("failed to delete database '%s': %w", name, err)
+			}
+		}
+		// Likewise for a collection; it is saved below if it was created again
+		for _, collName := range change.deletedCollections {
+			if err := storage.DeleteCollection(name, collName); err != nil {
+				return fmt.Errorf("failed to delete collection '%s' of database '%s': %w", collName, name, err)
+			}
+		}
+		db := dm.GetDatabase(name)
+		if db == nil {
+			continue
+		}
+		if change.whole || change.deleted {
+			if err := storage.SaveDatabase(db); err != nil {
+				return fmt.Errorf("failed to save database '%s': %w", name, err)
+			}
+			continue
+		}
+		for _, collName := range change.collections {
+			coll, err := db.GetCollection(collName)
+			if err != nil {
+				continue
+			}
+			if err := storage.SaveCollection(name, coll); err != nil {
+				return fmt.Errorf("failed to save collection '%s' of database '%s': %w", collName, name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Replay replays WAL entries to restore database state. An entry that was
+// already applied before the crash is skipped. An entry that cannot be
+// applied fails the replay before anything is saved, leaving the WAL and
+// the checkpoint as they are, since the entries after it would build on a
+// change that is missing.
+func (wm *WALManager) Replay(dm *DatabaseManager, storage *StorageManager) (*ReplayResult, error) {
+	result := &ReplayResult{}
 	checkpoint := wm.GetCheckpoint()
 
 	// Read entries after checkpoint
-	entries, err := wm.ReadFrom(checkpoint.Offset)
+	entries, torn, err := wm.readEntries(checkpoint.Offset)
 	if err != nil {
-		return fmt.Errorf("failed to read WAL for replay: %w", err)
+		return nil, fmt.Errorf("failed to read WAL for replay: %w", err)
+	}
+
+	// A crash mid-append leaves a torn entry behind; everything before it is
+	// replayed. A read-only manager leaves the files to their owner.
+	if len(torn) > 0 && !wm.readOnly {
+		if err := wm.truncateTornTails(torn); err != nil {
+			return nil, err
+		}
 	}
 
 	if len(entries) == 0 {
-		return nil // Nothing to replay
+		return result, nil // Nothing to replay
 	}
 
-	// Replay each entry
+	// Apply every entry in memory, then save what they changed. A read-only
+	// storage manager keeps the changes in memory and leaves saving them and
+	// the checkpoint to the process that owns the directory.
+	saves := make(replaySaves)
 	for _, entry := range entries {
-		if err := wm.replayEntry(entry, dm, storage); err != nil {
-			return fmt.Errorf("failed to replay entry at offset %d: %w", entry.Offset, err)
+		change, err := applyEntry(entry, dm)
+		saves.add(change)
+		switch {
+		case errors.Is(err, errReplaySkip):
+			result.Skipped++
+		case err != nil:
+			return nil, fmt.Errorf("failed to replay WAL entry at offset %d (%s on %s): %w",
+				entry.Offset, entry.Operation, entryTarget(entry), err)
+		default:
+			result.Applied++
 		}
 	}
+	if result.Skipped > 0 {
+		log.Printf("Replayed %d WAL entries: %d applied, %d already applied\n",
+			len(entries), result.Applied, result.Skipped)
+	}
+	if wm.readOnly {
+		return result, nil
+	}
+	if err := saves.save(dm, storage); err != nil {
+		return nil, fmt.Errorf("failed to save replayed entries: %w", err)
+	}
 
-	// Update checkpoint to latest offset
-	if len(entries) > 0 {
-		lastOffset := entries[len(entries)-1].Offset
-		if err := wm.Checkpoint(lastOffset); err != nil {
-			return fmt.Errorf("failed to checkpoint after replay: %w", err)
-		}
+	// Checkpoint past the replayed entries, which are saved now
+	lastOffset := entries[len(entries)-1].Offset
+
+	// New entries must not reuse the offsets of the replayed ones
+	wm.mu.Lock()
+	if wm.currentOffset <= lastOffset {
+		wm.currentOffset = lastOffset + 1
 	}
+	wm.mu.Unlock()
 
-	return nil
+	if err := wm.Checkpoint(lastOffset + 1); err != nil {
+		return nil, fmt.Errorf("failed to checkpoint after replay: %w", err)
+	}
+
+	return result, nil
+}
+
+// entryTarget names the database, collection and document of an entry
+func entryTarget(entry *WALEntry) string {
+	target := entry.Database
+	if entry.Collection != "" {
+		target += "/" + entry.Collection
+	}
+	if entry.DocumentID != "" {
+		target += " " + entry.DocumentID
+	}
+	return target
 }
 
-// replayEntry replays a single WAL entry
-func (wm *WALManager) replayEntry(entry *WALEntry, dm *DatabaseManager, storage *StorageManager) error {
+// applyEntry applies a single WAL entry to the databases in memory and
+// returns what it changed. It fails with errReplaySkip if the change is
+// already there. An entry that fails partway, e.g. a transaction, returns
+// the changes made before the failure along with the error.
+func applyEntry(entry *WALEntry, dm *DatabaseManager) (*replayChange, error) {
 	switch entry.Operation {
 	case WALOpCreateDatabase:
-		db := dm.CreateDatabase(entry.Database)
-		return storage.SaveDatabase(db)
+		if dm.GetDatabase(entry.Database) != nil {
+			return nil, errReplaySkip
+		}
+		dm.CreateDatabase(entry.Database)
+		return &replayChange{database: entry.Database, whole: true}, nil
 
 	case WALOpDeleteDatabase:
-		dm.DeleteDatabase(entry.Database)
-		return storage.DeleteDatabase(entry.Database)
+		if !dm.DeleteDatabase(entry.Database) {
+			return nil, errReplaySkip
+		}
+		return &replayChange{database: entry.Database, deleted: true}, nil
+	}
+
+	db := dm.GetDatabase(entry.Database)
+	if db == nil {
+		return nil, fmt.Errorf("database '%s' does not exist", entry.Database)
+	}
 
+	switch entry.Operation {
 	case WALOpCreateCollection:
-		db := dm.GetDatabase(entry.Database)
-		if db == nil {
-			return fmt.Errorf("database %s not found during replay", entry.Database)
+		// The entry holds the collection's schema, if it has one
+		var schema *Schema
+		if len(entry.Data) > 0 {
+			schema = &Schema{}
+			if err := json.Unmarshal(entry.Data, schema); err != nil {
+				return nil, err
+			}
 		}
 
-		// Deserialize collection data
-		var collData struct {
-			Name   string  `json:"name"`
-			Schema *Schema `json:"schema"`
+		if _, err := db.GetCollection(entry.Collection); err == nil {
+			return nil, errReplaySkip
 		}
-		if err := json.Unmarshal(entry.Data, &collData); err != nil {
-			return err
+		if err := db.CreateCollection(entry.Collection, schema); err != nil {
+			return nil, err
 		}
+		return &replayChange{database: db.Name, collections: []string{entry.Collection}}, nil
 
-		if err := db.CreateCollection(collData.Name, collData.Schema); err != nil {
-			return err
+	case WALOpDeleteCollection:
+		if _, err := db.DeleteCollection(entry.Collection); err != nil {
+			return nil, errReplaySkip
 		}
-		return storage.SaveDatabase(db)
+		return &replayChange{database: db.Name, deletedCollections: []string{entry.Collection}}, nil
 
-	case WALOpInsert:
-		db := dm.GetDatabase(entry.Database)
-		if db == nil {
-			return fmt.Errorf("database %s not found during replay", entry.Database)
+	case WALOpTransaction:
+		// The entry holds the outcome of every operation, in order
+		var results []TxResult
+		if err := json.Unmarshal(entry.Data, &results); err != nil {
+			return nil, err
 		}
 
-		coll, err := db.GetCollection(entry.Collection)
-		if err != nil {
-			return err
+		change := &replayChange{database: db.Name}
+		touched := make(map[string]bool)
+		for _, result := range results {
+			coll, err := db.GetCollection(result.Collection)
+			if err != nil {
+				return change, err
+			}
+			if result.Document != nil {
+				err = coll.put(result.Document)
+			} else if _, findErr := coll.FindByID(result.ID); findErr == nil {
+				err = coll.delete(result.ID)
+			}
+			if !touched[coll.Name] {
+				touched[coll.Name] = true
+				change.collections = append(change.collections, coll.Name)
+			}
+			if err != nil {
+				return change, err
+			}
 		}
+		return change, nil
 
+	case WALOpInsert, WALOpUpdate, WALOpUpsert, WALOpDelete, WALOpCreateIndex, WALOpTruncateCollection:
+		// Changes to a single collection, below
+	default:
+		return nil, fmt.Errorf("unknown WAL operation: %s", entry.Operation)
+	}
+
+	coll, err := db.GetCollection(entry.Collection)
+	if err != nil {
+		return nil, err
+	}
+	change := &replayChange{database: db.Name, collections: []string{coll.Name}}
+
+	switch entry.Operation {
+	case WALOpInsert:
 		// Deserialize document
 		var doc Document
 		if err := json.Unmarshal(entry.Data, &doc); err != nil {
-			return err
+			return nil, err
 		}
 
-		if err := coll.Insert(&doc); err != nil {
-			return err
+		if _, err := coll.FindByID(doc.ID); err == nil {
+			return nil, errReplaySkip
 		}
-		return storage.SaveCollection(entry.Database, coll)
-
-	case WALOpUpdate:
-		db := dm.GetDatabase(entry.Database)
-		if db == nil {
-			return fmt.Errorf("database %s not found during replay", entry.Database)
-		}
-
-		coll, err := db.GetCollection(entry.Collection)
-		if err != nil {
-			return err
+		if err := coll.insert(&doc); err != nil {
+			return nil, err
 		}
+		return change, nil
 
-		// Deserialize updates
-		var updates map[string]any
-		if err := json.Unmarshal(entry.Data, &updates); err != nil {
-			return err
+	case WALOpUpdate, WALOpUpsert:
+		// The entry holds the whole resulting document
+		var doc Document
+		if err := json.Unmarshal(entry.Data, &doc); err != nil {
+			return nil, err
 		}
 
-		if err := coll.Update(entry.DocumentID, updates); err != nil {
-			return err
+		if err := coll.put(&doc); err != nil {
+			return nil, err
 		}
-		return storage.SaveCollection(entry.Database, coll)
+		return change, nil
 
 	case WALOpDelete:
-		db := dm.GetDatabase(entry.Database)
-		if db == nil {
-			return fmt.Errorf("database %s not found during replay", entry.Database)
+		if _, err := coll.FindByID(entry.DocumentID); err != nil {
+			return nil, errReplaySkip
 		}
-
-		coll, err := db.GetCollection(entry.Collection)
-		if err != nil {
-			return err
+		if err := coll.delete(entry.DocumentID); err != nil {
+			return nil, err
 		}
+		return change, nil
 
-		if err := coll.Delete(entry.DocumentID); err != nil {
-			return err
-		}
-		return storage.SaveCollection(entry.Database, coll)
+	case WALOpTruncateCollection:
+		coll.Truncate()
+		return change, nil
 
 	case WALOpCreateIndex:
-		db := dm.GetDatabase(entry.Database)
-		if db == nil {
-			return fmt.Errorf("database %s not found during replay", entry.Database)
-		}
-
-		coll, err := db.GetCollection(entry.Collection)
-		if err != nil {
-			return err
-		}
-
 		// Deserialize index data
 		var indexData struct {
 			IndexName string `json:"index_name"`
 			FieldName string `json:"field_name"`
+			IndexOptions
 		}
 		if err := json.Unmarshal(entry.Data, &indexData); err != nil {
-			return err
+			return nil, err
 		}
 
-		if err := coll.CreateIndex(indexData.IndexName, indexData.FieldName); err != nil {
-			return err
+		coll.mu.RLock()
+		_, exists := coll.Indexes[indexData.IndexName]
+		coll.mu.RUnlock()
+		if exists {
+			return nil, errReplaySkip
 		}
-		return storage.SaveCollection(entry.Database, coll)
-
-	default:
-		return fmt.Errorf("unknown WAL operation: %s", entry.Operation)
+		if err := coll.CreateIndexWithOptions(indexData.IndexName, indexData.FieldName, indexData.IndexOptions); err != nil {
+			return nil, err
+		}
+		return change, nil
 	}
+
+	return nil, fmt.Errorf("unknown WAL operation: %s", entry.Operation)
 }
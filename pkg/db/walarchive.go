@@ -0,0 +1,114 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// WALArchiver takes the WAL files that rotation moves past the retention
+// count (WALConfig.RetentionCount), instead of them being deleted, e.g. to keep every
+// change since a base backup for point-in-time recovery (see
+// RestoreOptions.WALArchive). An implementation may upload the files to
+// object storage; NewDirWALArchiver keeps them in a directory.
+type WALArchiver interface {
+	// Archive stores the closed WAL file at path. The file is removed from
+	// the root directory afterwards if it is still there; when Archive
+	// fails, it is kept and archived again on the next rotation.
+	Archive(path string) error
+}
+
+// DirWALArchiver archives WAL files by moving them into a directory
+type DirWALArchiver struct {
+	Dir string
+}
+
+// NewDirWALArchiver creates an archiver moving WAL files into dir, which is
+// created if needed
+func NewDirWALArchiver(dir string) (*DirWALArchiver, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create WAL archive directory: %w", err)
+	}
+	return &DirWALArchiver{Dir: dir}, nil
+}
+
+// Archive moves the WAL file at path into the archive directory, copying it
+// when the two are on different file systems
+func (a *DirWALArchiver) Archive(path string) error {
+	target := filepath.Join(a.Dir, filepath.Base(path))
+	if err := os.Rename(path, target); err != nil {
+		if err := copyFile(path, target, 0644); err != nil {
+			os.Remove(target)
+			return err
+		}
+	}
+	return syncDir(a.Dir)
+}
+
+// archiveOrRemoveLocked hands a WAL file past retention to the archiver, if
+// any, and removes it (caller must hold mu). A file the archiver fails to
+// take stays in place, so it is retried instead of lost.
+func (wm *WALManager) archiveOrRemoveLocked(path string) error {
+	if wm.Archiver != nil {
+		if err := wm.Archiver.Archive(path); err != nil {
+			fmt.Printf("Failed to archive WAL file %s, keeping it: %v\n", filepath.Base(path), err)
+			return nil
+		}
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// readArchivedWAL reads the entries of the WAL files in an archive
+// directory that follow a backup ending before offset from, up to the last
+// one logged at or before until (zero reads them all). It fails when the
+// archive does not reach back to from, since the changes in between would
+// be missing.
+func readArchivedWAL(dir string, from uint64, until time.Time) ([]*WALEntry, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAL archive: %w", err)
+	}
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasPrefix(file.Name(), WALFilePrefix) {
+			names = append(names, file.Name())
+		}
+	}
+	sort.Strings(names)
+
+	wm := &WALManager{rootDir: dir, readOnly: true}
+	var entries []*WALEntry
+	oldest := uint64(0)
+	found := false
+files:
+	for _, name := range names {
+		fileEntries, err := wm.readWALFile(filepath.Join(dir, name), 0)
+		var tail *walTornTail
+		if err != nil && !errors.As(err, &tail) {
+			return nil, fmt.Errorf("failed to read archived WAL: %w", err)
+		}
+		for _, entry := range fileEntries {
+			if !found || entry.Offset < oldest {
+				oldest, found = entry.Offset, true
+			}
+			if entry.Offset < from {
+				continue // in the backup already
+			}
+			if !until.IsZero() && entry.Timestamp.After(until) {
+				break files
+			}
+			entries = append(entries, entry)
+		}
+	}
+	if found && oldest > from {
+		return nil, fmt.Errorf("the WAL archive starts at offset %d, after the end of the backup at offset %d; the files in between are missing", oldest, from)
+	}
+	return entries, nil
+}
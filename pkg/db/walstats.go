@@ -0,0 +1,89 @@
+package db
+
+import "time"
+
+// walRateWindow is the number of past seconds the append rate averages over
+const walRateWindow = 10
+
+// WALStats is a point-in-time view of the WAL, for spotting a background
+// syncer that falls behind the writes
+type WALStats struct {
+	// Appended counts the entries appended since the WAL was opened
+	Appended uint64 `json:"appended"`
+	// AppendRate is the entries appended per second, averaged over the
+	// last walRateWindow seconds
+	AppendRate float64 `json:"append_rate"`
+	// Offset is the offset the next entry gets
+	Offset uint64 `json:"offset"`
+	// SegmentSize is the size in bytes of the WAL file being appended to
+	SegmentSize int64 `json:"segment_size"`
+	// BatchLength counts the batched entries not yet written to the file
+	BatchLength int `json:"batch_length"`
+	// CheckpointOffset is the first offset not yet saved to the data files
+	CheckpointOffset uint64 `json:"checkpoint_offset"`
+	// CheckpointLag counts the entries logged since the last checkpoint,
+	// and CheckpointLagBytes the bytes written for them
+	CheckpointLag      uint64 `json:"checkpoint_lag"`
+	CheckpointLagBytes int64  `json:"checkpoint_lag_bytes"`
+	// CheckpointAge is the time since the last checkpoint (zero before the first)
+	CheckpointAge time.Duration `json:"checkpoint_age"`
+}
+
+// rateMeter counts events per second over the last walRateWindow seconds
+type rateMeter struct {
+	counts  [walRateWindow]uint64
+	seconds [walRateWindow]int64
+}
+
+// add counts n events at now
+func (m *rateMeter) add(now time.Time, n uint64) {
+	second := now.Unix()
+	i := second % walRateWindow
+	if m.seconds[i] != second {
+		m.seconds[i] = second
+		m.counts[i] = 0
+	}
+	m.counts[i] += n
+}
+
+// rate returns the events per second over the complete seconds of the window
+func (m *rateMeter) rate(now time.Time) float64 {
+	second := now.Unix()
+	var total uint64
+	for i, at := range m.seconds {
+		if age := second - at; age >= 1 && age <= walRateWindow {
+			total += m.counts[i]
+		}
+	}
+	return float64(total) / walRateWindow
+}
+
+// Stats returns the current WAL statistics
+func (wm *WALManager) Stats() WALStats {
+	wm.batchMu.Lock()
+	batchLength := len(wm.batch)
+	wm.batchMu.Unlock()
+
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	now := time.Now()
+	stats := WALStats{
+		Appended:           wm.appended,
+		AppendRate:         wm.appendRate.rate(now),
+		Offset:             wm.currentOffset,
+		SegmentSize:        wm.currentSize,
+		BatchLength:        batchLength,
+		CheckpointLagBytes: wm.sinceCheckpoint,
+	}
+	if wm.checkpoint != nil {
+		stats.CheckpointOffset = wm.checkpoint.Offset
+		if wm.currentOffset > wm.checkpoint.Offset {
+			stats.CheckpointLag = wm.currentOffset - wm.checkpoint.Offset
+		}
+		if !wm.checkpoint.Timestamp.IsZero() {
+			stats.CheckpointAge = now.Sub(wm.checkpoint.Timestamp)
+		}
+	}
+	return stats
+}